@@ -6,20 +6,30 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	_ "github.com/lib/pq"
 	_ "github.com/proullon/ramsql/driver"
 )
 
-func benchmarkInsert(b *testing.B, driver string, nbRows int) {
+// benchmarkDSN returns the data source name to use with driver. ramsql rejects
+// options it does not know, such as sslmode
+func benchmarkDSN(driver string) string {
+	if driver != "postgres" {
+		return "bench"
+	}
+
 	u := os.Getenv("PG_USER")
 	pwd := os.Getenv("PG_PASSWORD")
 	ip := os.Getenv("PG_IP")
 	port := os.Getenv("PG_PORT")
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/postgres?sslmode=disable", u, pwd, ip, port)
-	db, err := sql.Open(driver, dsn)
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/postgres?sslmode=disable", u, pwd, ip, port)
+}
+
+func benchmarkInsert(b *testing.B, driver string, nbRows int) {
+	db, err := sql.Open(driver, benchmarkDSN(driver))
 	if err != nil {
 		b.Fatalf("sql.Open: %s", err)
 	}
@@ -57,13 +67,7 @@ func benchmarkInsert(b *testing.B, driver string, nbRows int) {
 }
 
 func benchmarkSelect(b *testing.B, driver string, n int) {
-	u := os.Getenv("PG_USER")
-	pwd := os.Getenv("PG_PASSWORD")
-	ip := os.Getenv("PG_IP")
-	port := os.Getenv("PG_PORT")
-
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/postgres?sslmode=disable", u, pwd, ip, port)
-	db, err := sql.Open(driver, dsn)
+	db, err := sql.Open(driver, benchmarkDSN(driver))
 	if err != nil {
 		b.Fatalf("sql.Open: %s", err)
 	}
@@ -119,6 +123,42 @@ func benchmarkSelect(b *testing.B, driver string, n int) {
 	}
 }
 
+func benchmarkInsertUnique(b *testing.B, driver string, nbRows int) {
+	db, err := sql.Open(driver, benchmarkDSN(driver))
+	if err != nil {
+		b.Fatalf("sql.Open: %s", err)
+	}
+
+	db.Exec(`DROP TABLE account`)
+	_, err = db.Exec(`CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT UNIQUE)`)
+	if err != nil {
+		b.Fatalf("sql.Exec: %s", err)
+	}
+
+	values := make([]string, nbRows)
+	for i := range values {
+		values[i] = fmt.Sprintf("('%d@foobar.com')", i)
+	}
+	_, err = db.Exec(`INSERT INTO account (email) VALUES ` + strings.Join(values, ", "))
+	if err != nil {
+		b.Fatalf("cannot insert rows: %s", err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		query := `INSERT INTO account (email) VALUES ($1)`
+		_, err = db.Exec(query, fmt.Sprintf("%d@foobaz.com", n))
+		if err != nil {
+			b.Fatalf("cannot insert rows: %s", err)
+		}
+	}
+
+	_, err = db.Exec(`DROP TABLE account`)
+	if err != nil {
+		b.Fatalf("sql.Exec: %s", err)
+	}
+}
+
 func BenchmarkRamSQLSelect(b *testing.B) {
 	benchmarkSelect(b, "ramsql", 100)
 }
@@ -134,3 +174,11 @@ func BenchmarkRamSQLInsert10(b *testing.B) {
 func BenchmarkPostgresInsert10(b *testing.B) {
 	benchmarkInsert(b, "postgres", 10)
 }
+
+func BenchmarkRamSQLInsertUnique5000(b *testing.B) {
+	benchmarkInsertUnique(b, "ramsql", 5000)
+}
+
+func BenchmarkPostgresInsertUnique5000(b *testing.B) {
+	benchmarkInsertUnique(b, "postgres", 5000)
+}
@@ -2,6 +2,8 @@ package ramsql
 
 import (
 	"database/sql"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -49,3 +51,100 @@ func TestAutoIncrementSimple(t *testing.T) {
 		t.Fatalf("Last insterted id should be 2, not %d", lastID)
 	}
 }
+
+func TestSerialSequence(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSerialSequence")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE account (id SERIAL PRIMARY KEY, email TEXT)")
+	if err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	insert := func(query string, expected int64) {
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("Cannot insert into table account: %s", err)
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			t.Fatalf("Cannot fetch last inserted id: %s\n", err)
+		}
+		if lastID != expected {
+			t.Fatalf("Last inserted id should be %d, not %d", expected, lastID)
+		}
+	}
+
+	insert("INSERT INTO account (email) VALUES ('foo@bar.com')", 1)
+	insert("INSERT INTO account (email) VALUES ('roger@gmail.com')", 2)
+
+	// Deleted ids are not allocated again
+	_, err = db.Exec("DELETE FROM account WHERE id = 2")
+	if err != nil {
+		t.Fatalf("Cannot delete from table account: %s", err)
+	}
+	insert("INSERT INTO account (email) VALUES ('bob@gmail.com')", 3)
+
+	// Explicit id advances the sequence past it
+	insert("INSERT INTO account (id, email) VALUES (10, 'alice@gmail.com')", 10)
+	insert("INSERT INTO account (email) VALUES ('eve@gmail.com')", 11)
+	insert("INSERT INTO account (id, email) VALUES (5, 'carol@gmail.com')", 5)
+	insert("INSERT INTO account (email) VALUES ('dave@gmail.com')", 12)
+}
+
+func TestSerialConcurrentInserts(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSerialConcurrentInserts")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT)")
+	if err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ids := make(map[int64]bool)
+	errs := make(chan error, 50)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := db.Exec(fmt.Sprintf("INSERT INTO account (email) VALUES ('user%d@example.com')", i))
+			if err != nil {
+				errs <- err
+				return
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ids[id] {
+				errs <- fmt.Errorf("id %d allocated twice", id)
+			}
+			ids[id] = true
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Concurrent insert: %s", err)
+	}
+	if len(ids) != 50 {
+		t.Fatalf("Expected 50 distinct ids, got %d", len(ids))
+	}
+}
@@ -1,8 +1,13 @@
 package ramsql
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/proullon/ramsql/engine/log"
 	"github.com/proullon/ramsql/engine/protocol"
@@ -10,8 +15,8 @@ import (
 
 // Conn implements sql/driver Conn interface
 type Conn struct {
-	// Mutex is locked when a Statement is created
-	// then released on Statement.Exec or Statement.Query
+	// Mutex is locked while a Statement is executed,
+	// until Statement.Exec or Statement.Query returns
 	mutex sync.Mutex
 
 	// Socket is the network connection to RamSQL engine
@@ -37,7 +42,8 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 
 // Close invalidates and potentially stops any current
 // prepared statements and transactions, marking this
-// connection as no longer in use.
+// connection as no longer in use. Engine rolls back the
+// transaction in progress, if any.
 //
 // Because the sql package maintains a free pool of
 // connections and only calls Close when there's a surplus of
@@ -54,12 +60,69 @@ func (c *Conn) Close() error {
 	return nil
 }
 
+// ResetSession is called by the sql package before connection is reused from the pool.
+// It rolls back the transaction left in progress, if any, like one begun with a
+// BEGIN statement, so that its writes are discarded rather than seen by the next user.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Statement is not logged, since it is not executed by user
+	if err := c.conn.WriteExec("ROLLBACK"); err != nil {
+		return driver.ErrBadConn
+	}
+	if _, _, err := c.conn.ReadResult(); err != nil {
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
 // Begin starts and returns a new transaction.
 func (c *Conn) Begin() (driver.Tx, error) {
 
+	if err := c.exec("BEGIN"); err != nil {
+		return nil, err
+	}
+
 	tx := Tx{
 		conn: c,
 	}
 
 	return &tx, nil
 }
+
+// BeginTx starts a transaction, read only if opts say so.
+// Only the default isolation level is supported.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if sql.IsolationLevel(opts.Isolation) != sql.LevelDefault {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+
+	stmt := "BEGIN"
+	if opts.ReadOnly {
+		stmt = "BEGIN READ ONLY"
+	}
+	if err := c.exec(stmt); err != nil {
+		return nil, err
+	}
+
+	return &Tx{conn: c}, nil
+}
+
+// exec sends a statement not returning rows to server, and waits for its answer
+func (c *Conn) exec(stmt string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	start := time.Now()
+	if err := c.conn.WriteExec(stmt); err != nil {
+		err = fmt.Errorf("Cannot send query to server: %s", err)
+		logQuery(stmt, nil, start, 0, err)
+		return err
+	}
+
+	_, rowsAffected, err := c.conn.ReadResult()
+	logQuery(stmt, nil, start, rowsAffected, err)
+	return err
+}
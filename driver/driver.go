@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -54,6 +57,20 @@ type connConf struct {
 	Password string
 	User     string
 	Timeout  time.Duration
+
+	// Mode of the database, only in memory
+	Mode string
+	// ReadOnly is set if connections cannot write
+	ReadOnly bool
+	// Shared is set if connections opened with the same DSN use the same database,
+	// otherwise each connection has its own
+	Shared bool
+	// ForeignKeys is set if FOREIGN KEY constraints are enforced
+	ForeignKeys bool
+	// Seed of RANDOM() values if given, so that they are reproducible
+	Seed *int64
+	// StatementTimeout aborts statements running longer, if not zero
+	StatementTimeout time.Duration
 }
 
 // Open return an active connection so RamSQL server
@@ -68,7 +85,9 @@ func (rs *Driver) Open(dsn string) (conn driver.Conn, err error) {
 		return nil, err
 	}
 
-	dsnServer, exist := rs.servers[dsn]
+	// Read only connections share the database opened without mode
+	name := serverName(dsn)
+	dsnServer, exist := rs.servers[name]
 	if !exist {
 		driverEndpoint, engineEndpoint, err := endpoints(connConf)
 		if err != nil {
@@ -81,6 +100,11 @@ func (rs *Driver) Open(dsn string) (conn driver.Conn, err error) {
 			rs.Unlock()
 			return nil, err
 		}
+		server.SetForeignKeys(connConf.ForeignKeys)
+		if connConf.Seed != nil {
+			server.SetRandomSeed(*connConf.Seed)
+		}
+		server.SetStatementTimeout(connConf.StatementTimeout)
 
 		driverConn, err := driverEndpoint.New(dsn)
 		if err != nil {
@@ -92,15 +116,52 @@ func (rs *Driver) Open(dsn string) (conn driver.Conn, err error) {
 			endpoint: driverEndpoint,
 			server:   server,
 		}
-		rs.servers[dsn] = s
+		if connConf.Shared {
+			rs.servers[name] = s
+		}
 
 		rs.Unlock()
-		return newConn(driverConn, s), nil
+		return openConn(driverConn, s, connConf)
 	}
 
 	rs.Unlock()
 	driverConn, err := dsnServer.endpoint.New(dsn)
-	return newConn(driverConn, dsnServer), err
+	if err != nil {
+		return nil, err
+	}
+	return openConn(driverConn, dsnServer, connConf)
+}
+
+// openConn returns a connection to server, configured as given
+func openConn(driverConn protocol.DriverConn, s *Server, conf *connConf) (driver.Conn, error) {
+	c := newConn(driverConn, s)
+	if conf.ReadOnly {
+		if err := c.(*Conn).exec("SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY"); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// serverName returns the name of the database opened with dsn, which is dsn without mode option
+func serverName(dsn string) string {
+	i := strings.IndexByte(dsn, '?')
+	if i < 0 {
+		return dsn
+	}
+
+	var options []string
+	for _, o := range strings.Split(dsn[i+1:], "&") {
+		if !strings.HasPrefix(o, "mode=") {
+			options = append(options, o)
+		}
+	}
+	if len(options) == 0 {
+		return dsn[:i]
+	}
+	return dsn[:i+1] + strings.Join(options, "&")
 }
 
 func endpoints(conf *connConf) (protocol.DriverEndpoint, protocol.EngineEndpoint, error) {
@@ -130,8 +191,29 @@ func endpoints(conf *connConf) (protocol.DriverEndpoint, protocol.EngineEndpoint
 // Currently implemented options:
 //   laddr   - local address/port (eg. 1.2.3.4:0)
 //   timeout - connect timeout in format accepted by time.ParseDuration
+//
+// Database options may be given as a query string, with an optional ramsql:// prefix:
+//
+//   ramsql://DBNAME?mode=memory&shared=true&foreign_keys=on
+//   DBNAME?shared=false&seed=42
+//
+// Currently implemented database options:
+//   mode         - memory, or ro for read only connections to the database opened without mode
+//   shared       - connections opened with the same DSN share the same database (default true)
+//   foreign_keys - enforce FOREIGN KEY constraints (default on)
+//   seed         - seed of RANDOM() values, the same seed yielding the same sequence
+//   statement_timeout - abort statements running longer, given as a duration like 500ms,
+//                       or in milliseconds (default 0, no limit)
 func parseConnectionURI(uri string) (*connConf, error) {
-	c := &connConf{}
+	c := &connConf{Mode: "memory", Shared: true, ForeignKeys: true}
+
+	uri = strings.TrimPrefix(uri, "ramsql://")
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		if err := c.parseOptions(uri[i+1:]); err != nil {
+			return nil, err
+		}
+		uri = uri[:i]
+	}
 
 	if uri == "" {
 		log.Info("Empty data source name, using 'default' engine")
@@ -186,6 +268,76 @@ func parseConnectionURI(uri string) (*connConf, error) {
 	return c, nil
 }
 
+// parseOptions sets database options given as a query string
+func (c *connConf) parseOptions(query string) error {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid options %s: %s", query, err)
+	}
+
+	for k, v := range values {
+		value := v[len(v)-1]
+		switch k {
+		case "mode":
+			switch value {
+			case "memory":
+			case "ro":
+				c.ReadOnly = true
+			default:
+				return fmt.Errorf("invalid value for option mode: %s", value)
+			}
+		case "shared":
+			c.Shared, err = parseBoolOption(k, value)
+		case "foreign_keys":
+			c.ForeignKeys, err = parseBoolOption(k, value)
+		case "seed":
+			var seed int64
+			if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return fmt.Errorf("invalid value for option seed: %s", value)
+			}
+			c.Seed = &seed
+		case "statement_timeout":
+			c.StatementTimeout, err = parseDurationOption(k, value)
+		default:
+			return errors.New("Unknown option: " + k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseBoolOption returns the value of a boolean option, given like a boolean or as on or off
+func parseBoolOption(name string, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for option %s: %s", name, value)
+	}
+	return b, nil
+}
+
+// parseDurationOption returns the value of a duration option, in milliseconds if it has no unit
+func parseDurationOption(name string, value string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid value for option %s: %s", name, value)
+	}
+	return d, nil
+}
+
 func (s *Server) openingConn() {
 
 	s.Lock()
@@ -1,9 +1,14 @@
 package ramsql
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -803,6 +808,68 @@ func TestOffset(t *testing.T) {
 	}
 }
 
+func TestLimitOffset(t *testing.T) {
+	log.UseTestLogger(t)
+
+	batch := []string{
+		`CREATE TABLE pokemon (id BIGSERIAL, name TEXT)`,
+		`INSERT INTO pokemon (name) VALUES ('Charmander')`,
+		`INSERT INTO pokemon (name) VALUES ('Bulbasaur')`,
+		`INSERT INTO pokemon (name) VALUES ('Squirtle')`,
+		`INSERT INTO pokemon (name) VALUES ('Pikachu')`,
+		`INSERT INTO pokemon (name) VALUES ('Eevee')`,
+	}
+
+	db, err := sql.Open("ramsql", "TestLimitOffset")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT name FROM pokemon ORDER BY name LIMIT 2 OFFSET 1`:  {"Charmander", "Eevee"},
+		`SELECT name FROM pokemon ORDER BY name OFFSET 1 LIMIT 2`:  {"Charmander", "Eevee"},
+		`SELECT name FROM pokemon ORDER BY name LIMIT 1, 2`:        {"Charmander", "Eevee"},
+		`SELECT name FROM pokemon ORDER BY name LIMIT 10 OFFSET 3`: {"Pikachu", "Squirtle"},
+		`SELECT name FROM pokemon ORDER BY name LIMIT 2 OFFSET 10`: nil,
+		`SELECT name FROM pokemon ORDER BY name LIMIT 0`:           nil,
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("sql.Query: %s", err)
+		}
+
+		var names []string
+		for rows.Next() {
+			var name string
+			err = rows.Scan(&name)
+			if err != nil {
+				t.Fatalf("rows.Scan: %s", err)
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+
+		if len(names) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, names)
+			}
+		}
+	}
+}
+
 func TestUnique(t *testing.T) {
 	log.UseTestLogger(t)
 
@@ -896,3 +963,975 @@ func TestJSON(t *testing.T) {
 		t.Fatalf("Unexpected values (second unmarshal): %+v\n", s)
 	}
 }
+
+func TestContextCancel(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestContextCancel")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	// Joining these tables together gives millions of rows
+	var values []string
+	for i := 0; i < 200; i++ {
+		values = append(values, fmt.Sprintf("(%d)", i))
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err = db.Exec(fmt.Sprintf(`CREATE TABLE %s (id INT)`, name)); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+		if _, err = db.Exec(fmt.Sprintf(`INSERT INTO %s (id) VALUES %s`, name, strings.Join(values, ", "))); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = db.QueryContext(ctx, `SELECT a.id FROM a, b, c WHERE a.id = 1000`)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected query to stop promptly, took %s", d)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start = time.Now()
+	_, err = db.ExecContext(ctx, `UPDATE a SET id = b.id FROM b, c WHERE a.id = c.id + 1000`)
+	if err != context.Canceled {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected statement to stop promptly, took %s", d)
+	}
+
+	// Connections are still usable, and canceled statement had no effect
+	var n int
+	err = db.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM a WHERE id >= 1000`).Scan(&n)
+	if err != nil {
+		t.Fatalf("cannot query after cancellation: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no row updated, got %d", n)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestStatementTimeout?statement_timeout=50ms")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	// Joining these tables together gives millions of rows
+	var values []string
+	for i := 0; i < 200; i++ {
+		values = append(values, fmt.Sprintf("(%d)", i))
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err = db.Exec(fmt.Sprintf(`CREATE TABLE %s (id INT)`, name)); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+		if _, err = db.Exec(fmt.Sprintf(`INSERT INTO %s (id) VALUES %s`, name, strings.Join(values, ", "))); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	start := time.Now()
+	_, err = db.Query(`SELECT a.id FROM a, b, c WHERE a.id = 1000`)
+	var e *Error
+	if !errors.As(err, &e) || e.Code != QueryCanceled || e.Message != "canceling statement due to statement timeout" {
+		t.Fatalf("expected statement timeout, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected query to stop promptly, took %s", d)
+	}
+
+	_, err = db.Exec(`UPDATE a SET id = b.id FROM b, c WHERE a.id = c.id + 1000`)
+	if !errors.As(err, &e) || e.Code != QueryCanceled {
+		t.Fatalf("expected statement timeout, got %v", err)
+	}
+
+	// Each statement has its own time limit, and timed out one had no effect
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM a WHERE id >= 1000`).Scan(&n); err != nil {
+		t.Fatalf("cannot query after timeout: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no row updated, got %d", n)
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestPreparedStatement")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	// A single connection is used by all statements
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(`CREATE TABLE account (id INT, email TEXT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	// Statement closed without being executed leaves connection usable
+	unused, err := db.Prepare(`SELECT * FROM account`)
+	if err != nil {
+		t.Fatalf("cannot prepare statement: %s", err)
+	}
+	if err = unused.Close(); err != nil {
+		t.Fatalf("cannot close statement: %s", err)
+	}
+
+	// Statement executed several times
+	stmt, err := db.Prepare(`INSERT INTO account (id, email) VALUES ($1, $2)`)
+	if err != nil {
+		t.Fatalf("cannot prepare statement: %s", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err = stmt.Exec(i, fmt.Sprintf("user%d@bar.com", i)); err != nil {
+			t.Fatalf("cannot execute statement %d times: %s", i, err)
+		}
+	}
+	if err = stmt.Close(); err != nil {
+		t.Fatalf("cannot close statement: %s", err)
+	}
+	if err = stmt.Close(); err != nil {
+		t.Fatalf("cannot close statement twice: %s", err)
+	}
+	if _, err = stmt.Exec(4, "user4@bar.com"); err == nil {
+		t.Fatalf("expected error executing closed statement")
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil {
+		t.Fatalf("cannot count accounts: %s", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 accounts, got %d", n)
+	}
+}
+
+func TestArgumentsQuoting(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestArgumentsQuoting")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(`CREATE TABLE account (id INT, name TEXT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	names := []string{`O'Brien`, `$2`, `pay$$'s`, `costs 5$`, `'quoted'`, `$$`}
+	for i, name := range names {
+		if _, err = db.Exec(`INSERT INTO account (id, name) VALUES ($1, $2)`, i, name); err != nil {
+			t.Fatalf("cannot insert name <%s>: %s", name, err)
+		}
+	}
+
+	for i, name := range names {
+		var id int
+		if err = db.QueryRow(`SELECT id FROM account WHERE name = $1`, name).Scan(&id); err != nil {
+			t.Fatalf("cannot select name <%s>: %s", name, err)
+		}
+		if id != i {
+			t.Fatalf("expected id %d for name <%s>, got %d", i, name, id)
+		}
+
+		var got string
+		if err = db.QueryRow(`SELECT name FROM account WHERE id = ?`, i).Scan(&got); err != nil {
+			t.Fatalf("cannot select id %d: %s", i, err)
+		}
+		if got != name {
+			t.Fatalf("expected name <%s>, got <%s>", name, got)
+		}
+	}
+
+	// Markers in literals are not replaced
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account WHERE name = '$2' OR name = $1`, `O'Brien`).Scan(&n); err != nil {
+		t.Fatalf("cannot select with marker in literal: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows, got %d", n)
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestNamedArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id INT, parent INT, email TEXT)`,
+		`INSERT INTO account (id, parent, email) VALUES (1, 0, 'foo@bar.com'), (2, 1, 'bar@bar.com'), (3, 2, 'baz@bar.com')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM account WHERE id = @id OR parent = @id`, sql.Named("id", 2)).Scan(&n)
+	if err != nil {
+		t.Fatalf("cannot query with named argument: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows, got %d", n)
+	}
+
+	res, err := db.Exec(`UPDATE account SET email = :email WHERE id = :id`, sql.Named("id", 3), sql.Named("email", "qux@bar.com"))
+	if err != nil {
+		t.Fatalf("cannot update with named arguments: %s", err)
+	}
+	if ra, _ := res.RowsAffected(); ra != 1 {
+		t.Fatalf("expected 1 row affected, got %d", ra)
+	}
+
+	var email string
+	if err = db.QueryRow(`SELECT email FROM account WHERE id = @id`, sql.Named("id", 3)).Scan(&email); err != nil {
+		t.Fatalf("cannot select with named argument: %s", err)
+	}
+	if email != "qux@bar.com" {
+		t.Fatalf("expected updated email, got %s", email)
+	}
+
+	// Named and positional markers cannot be mixed
+	_, err = db.Exec(`UPDATE account SET email = @email WHERE id = $1`, sql.Named("email", "x@bar.com"), 1)
+	if err == nil {
+		t.Fatalf("expected error mixing named and positional parameters")
+	}
+}
+
+func TestBinaryArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestBinaryArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE message (id INT, payload BYTEA)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	payloads := [][]byte{
+		{0x08, 0x96, 0x01, 0x12, 0x00, 0xff},
+		[]byte("it's $$ quoted\n"),
+		{},
+		[]byte("2020-01-01"),
+		[]byte("<nil>"),
+	}
+	for i, p := range payloads {
+		_, err = db.Exec(`INSERT INTO message (id, payload) VALUES ($1, $2)`, i, p)
+		if err != nil {
+			t.Fatalf("cannot insert binary payload %v: %s", p, err)
+		}
+	}
+
+	for i, p := range payloads {
+		var payload []byte
+		err = db.QueryRow(`SELECT payload FROM message WHERE id = ?`, i).Scan(&payload)
+		if err != nil {
+			t.Fatalf("cannot select binary payload: %s", err)
+		}
+		if !bytes.Equal(payload, p) {
+			t.Fatalf("expected payload %v, got %v", p, payload)
+		}
+	}
+
+	var id int
+	err = db.QueryRow(`SELECT id FROM message WHERE payload = $1`, payloads[1]).Scan(&id)
+	if err != nil {
+		t.Fatalf("cannot select by binary payload: %s", err)
+	}
+	if id != 1 {
+		t.Fatalf("expected id 1, got %d", id)
+	}
+}
+
+func TestTimeArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTimeArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE event (id INT, created_at TIMESTAMP)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	times := []time.Time{
+		time.Date(2021, time.March, 4, 12, 30, 15, 123456789, time.UTC),
+		time.Date(2021, time.March, 4, 14, 30, 16, 0, time.FixedZone("CEST", 2*60*60)),
+		time.Now(),
+	}
+	for i, tm := range times {
+		_, err = db.Exec(`INSERT INTO event (id, created_at) VALUES (?, ?)`, i, tm)
+		if err != nil {
+			t.Fatalf("cannot insert time %s: %s", tm, err)
+		}
+	}
+
+	for i, tm := range times {
+		var createdAt time.Time
+		err = db.QueryRow(`SELECT created_at FROM event WHERE id = $1`, i).Scan(&createdAt)
+		if err != nil {
+			t.Fatalf("cannot select time: %s", err)
+		}
+		if !createdAt.Equal(tm) {
+			t.Fatalf("expected %s, got %s", tm, createdAt)
+		}
+		_, offset := createdAt.Zone()
+		_, expectedOffset := tm.Zone()
+		if offset != expectedOffset {
+			t.Fatalf("expected time zone of %s, got %s", tm, createdAt)
+		}
+	}
+
+	var createdAt time.Time
+	if err = db.QueryRow(`SELECT created_at FROM event WHERE id = 0`).Scan(&createdAt); err != nil {
+		t.Fatalf("cannot select time: %s", err)
+	}
+	if createdAt.Location() != time.UTC {
+		t.Fatalf("expected UTC time, got %s", createdAt)
+	}
+
+	var id int
+	err = db.QueryRow(`SELECT id FROM event WHERE created_at = $1`, times[1]).Scan(&id)
+	if err != nil {
+		t.Fatalf("cannot select by time: %s", err)
+	}
+	if id != 1 {
+		t.Fatalf("expected id 1, got %d", id)
+	}
+
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM event WHERE created_at > $1`, times[0]).Scan(&n)
+	if err != nil {
+		t.Fatalf("cannot select by time: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 later events, got %d", n)
+	}
+}
+
+func TestBoolArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestBoolArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE flag (id INT, active BOOLEAN DEFAULT false)`,
+		`INSERT INTO flag (id, active) VALUES (1, TRUE), (2, 'f'), (3, 'yes')`,
+		`INSERT INTO flag (id) VALUES (4)`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	_, err = db.Exec(`INSERT INTO flag (id, active) VALUES ($1, $2)`, 5, true)
+	if err != nil {
+		t.Fatalf("cannot insert bool argument: %s", err)
+	}
+	_, err = db.Exec(`UPDATE flag SET active = ? WHERE id = ?`, false, 3)
+	if err != nil {
+		t.Fatalf("cannot update with bool argument: %s", err)
+	}
+
+	expected := map[bool][]int{true: {1, 5}, false: {2, 3, 4}}
+	for active, ids := range expected {
+		rows, err := db.Query(`SELECT id FROM flag WHERE active = $1 ORDER BY id`, active)
+		if err != nil {
+			t.Fatalf("cannot select with bool argument: %s", err)
+		}
+		var got []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("cannot scan id: %s", err)
+			}
+			got = append(got, id)
+		}
+		rows.Close()
+		if fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Fatalf("expected %v rows with active = %v, got %v", ids, active, got)
+		}
+	}
+
+	var active bool
+	if err = db.QueryRow(`SELECT active FROM flag WHERE id = 5`).Scan(&active); err != nil {
+		t.Fatalf("cannot scan bool: %s", err)
+	}
+	if !active {
+		t.Fatalf("expected active flag")
+	}
+
+	_, err = db.Exec(`INSERT INTO flag (id, active) VALUES (6, 'maybe')`)
+	if err == nil {
+		t.Fatalf("expected error inserting invalid boolean")
+	}
+}
+
+func TestNullArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestNullArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id INT, name TEXT)`,
+		`INSERT INTO account (id, name) VALUES (1, 'null'), (2, '<nil>')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	_, err = db.Exec(`INSERT INTO account (id, name) VALUES ($1, $2)`, 3, nil)
+	if err != nil {
+		t.Fatalf("cannot insert nil argument: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO account (id, name) VALUES (?, ?)`, 4, nil)
+	if err != nil {
+		t.Fatalf("cannot insert nil argument: %s", err)
+	}
+
+	var name sql.NullString
+	if err = db.QueryRow(`SELECT name FROM account WHERE id = 3`).Scan(&name); err != nil {
+		t.Fatalf("cannot select name: %s", err)
+	}
+	if name.Valid {
+		t.Fatalf("expected NULL name, got %s", name.String)
+	}
+	if err = db.QueryRow(`SELECT name FROM account WHERE id = 2`).Scan(&name); err != nil {
+		t.Fatalf("cannot select name: %s", err)
+	}
+	if !name.Valid || name.String != "<nil>" {
+		t.Fatalf("expected <nil> name, got %v", name)
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account WHERE name IS NULL`).Scan(&n); err != nil {
+		t.Fatalf("cannot count NULL names: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 NULL names, got %d", n)
+	}
+
+	// Comparison with NULL is unknown, so it never matches
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account WHERE name = $1`, nil).Scan(&n); err != nil {
+		t.Fatalf("cannot count names equal to NULL: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no row matching NULL, got %d", n)
+	}
+
+	res, err := db.Exec(`UPDATE account SET name = 'foo' WHERE name = ?`, nil)
+	if err != nil {
+		t.Fatalf("cannot update with nil argument: %s", err)
+	}
+	if ra, _ := res.RowsAffected(); ra != 0 {
+		t.Fatalf("expected no row affected, got %d", ra)
+	}
+}
+
+func TestFloatArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestFloatArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE measure (id INT, value FLOAT)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	a := 0.1
+	values := []float64{a + 0.2, 1e21, 1.5e-7, 123456789.123456789, float64(float32(0.1))}
+	for i, v := range values {
+		query := `INSERT INTO measure (id, value) VALUES ($1, $2)`
+		if i%2 == 1 {
+			query = `INSERT INTO measure (id, value) VALUES (?, ?)`
+		}
+		if _, err = db.Exec(query, i, v); err != nil {
+			t.Fatalf("cannot insert float %v: %s", v, err)
+		}
+	}
+	if _, err = db.Exec(`INSERT INTO measure (id, value) VALUES (?, ?)`, len(values), float32(0.1)); err != nil {
+		t.Fatalf("cannot insert float32: %s", err)
+	}
+
+	for i, v := range values {
+		var value float64
+		if err = db.QueryRow(`SELECT value FROM measure WHERE id = $1`, i).Scan(&value); err != nil {
+			t.Fatalf("cannot select float: %s", err)
+		}
+		if value != v {
+			t.Fatalf("expected %v, got %v", v, value)
+		}
+	}
+
+	var value float32
+	if err = db.QueryRow(`SELECT value FROM measure WHERE id = $1`, len(values)).Scan(&value); err != nil {
+		t.Fatalf("cannot select float32: %s", err)
+	}
+	if value != float32(0.1) {
+		t.Fatalf("expected %v, got %v", float32(0.1), value)
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM measure WHERE value > ?`, 1e20).Scan(&n); err != nil {
+		t.Fatalf("cannot compare with float: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 value greater than 1e20, got %d", n)
+	}
+}
+
+func TestNegativeArguments(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestNegativeArguments")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(`CREATE TABLE balance (id INT, amount INT, rate FLOAT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	for i, query := range []string{`INSERT INTO balance (id, amount, rate) VALUES (?, ?, ?)`, `INSERT INTO balance (id, amount, rate) VALUES ($1, $2, $3)`} {
+		if _, err = db.Exec(query, i, -5-i, -0.25*float64(i+1)); err != nil {
+			t.Fatalf("cannot insert negative values with <%s>: %s", query, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		var id int
+		var rate float64
+		if err = db.QueryRow(`SELECT id, rate FROM balance WHERE amount = ?`, -5-i).Scan(&id, &rate); err != nil {
+			t.Fatalf("cannot select negative amount: %s", err)
+		}
+		if id != i || rate != -0.25*float64(i+1) {
+			t.Fatalf("expected balance %d at rate %v, got %d at %v", i, -0.25*float64(i+1), id, rate)
+		}
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM balance WHERE rate < ?`, -0.3).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 rate lower than -0.3, got %d (%v)", n, err)
+	}
+}
+
+func TestColumnTypeDatabaseTypeName(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestColumnTypeDatabaseTypeName")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id INT, email varchar(255), active BOOLEAN, created_at TIMESTAMP)`,
+		`CREATE TABLE address (id BIGSERIAL, account_id INT, street TEXT)`,
+		`INSERT INTO account (id, email, active, created_at) VALUES (1, 'foo@bar.com', true, NOW())`,
+		`INSERT INTO address (account_id, street) VALUES (1, 'rue du Bac')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT * FROM account`: {"INT", "VARCHAR", "BOOLEAN", "TIMESTAMP"},
+		`SELECT address.id, account.email, street FROM account JOIN address ON address.account_id = account.id ORDER BY street LIMIT 1`: {"BIGSERIAL", "VARCHAR", "TEXT"},
+		`SELECT id, COUNT(*) FROM account GROUP BY id`:                                              {"INT", ""},
+		`INSERT INTO address (account_id, street) VALUES (1, 'rue de Rivoli') RETURNING id, street`: {"BIGSERIAL", "TEXT"},
+	}
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("cannot query <%s>: %s", query, err)
+		}
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			t.Fatalf("cannot get column types of <%s>: %s", query, err)
+		}
+		if len(types) != len(expected) {
+			t.Fatalf("expected %d column types for <%s>, got %d", len(expected), query, len(types))
+		}
+		for i, ct := range types {
+			if ct.DatabaseTypeName() != expected[i] {
+				t.Fatalf("expected type %s for column %s of <%s>, got <%s>", expected[i], ct.Name(), query, ct.DatabaseTypeName())
+			}
+		}
+		rows.Close()
+	}
+}
+
+func TestColumnTypeScanType(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestColumnTypeScanType")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id BIGSERIAL, email TEXT, balance FLOAT, active BOOLEAN, created_at TIMESTAMP, avatar BYTEA)`,
+		`INSERT INTO account (email, balance, active, created_at, avatar) VALUES ('foo@bar.com', 12.5, true, NOW(), X'00ff')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT *, COUNT(*) FROM account GROUP BY id, email, balance, active, created_at, avatar`)
+	if err != nil {
+		t.Fatalf("cannot query: %s", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("cannot get column types: %s", err)
+	}
+	expected := []reflect.Type{
+		reflect.TypeOf(int64(0)),
+		reflect.TypeOf(""),
+		reflect.TypeOf(float64(0)),
+		reflect.TypeOf(false),
+		reflect.TypeOf(time.Time{}),
+		reflect.TypeOf([]byte(nil)),
+		reflect.TypeOf((*interface{})(nil)).Elem(),
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("expected %d columns, got %d", len(expected), len(types))
+	}
+
+	dest := make([]interface{}, len(types))
+	for i, ct := range types {
+		if ct.ScanType() != expected[i] {
+			t.Fatalf("expected scan type %s for column %s, got %s", expected[i], ct.Name(), ct.ScanType())
+		}
+		dest[i] = reflect.New(ct.ScanType()).Interface()
+	}
+
+	if !rows.Next() {
+		t.Fatalf("expected a row")
+	}
+	if err = rows.Scan(dest...); err != nil {
+		t.Fatalf("cannot scan in values of scan types: %s", err)
+	}
+	if *dest[0].(*int64) != 1 || *dest[2].(*float64) != 12.5 || !*dest[3].(*bool) {
+		t.Fatalf("unexpected scanned values %v", dest)
+	}
+}
+
+func TestColumnTypeNullable(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestColumnTypeNullable")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id SERIAL, email TEXT NOT NULL, name TEXT)`,
+		`INSERT INTO account (email, name) VALUES ('foo@bar.com', 'foo')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT id, email, name, COUNT(*) FROM account GROUP BY id, email, name`)
+	if err != nil {
+		t.Fatalf("cannot query: %s", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("cannot get column types: %s", err)
+	}
+
+	expected := []struct {
+		nullable bool
+		ok       bool
+	}{
+		{false, true},
+		{false, true},
+		{true, true},
+		{false, false},
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("expected %d columns, got %d", len(expected), len(types))
+	}
+	for i, ct := range types {
+		nullable, ok := ct.Nullable()
+		if nullable != expected[i].nullable || ok != expected[i].ok {
+			t.Fatalf("expected nullable %v (known %v) for column %s, got %v (known %v)", expected[i].nullable, expected[i].ok, ct.Name(), nullable, ok)
+		}
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestExecScript")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	script := `
+-- Schema; with a semicolon in comment
+CREATE TABLE account (id INT PRIMARY KEY, email TEXT);
+CREATE TABLE address (id INT, account_id INT, street TEXT);
+/* Fixtures */
+INSERT INTO account (id, email) VALUES (1, 'foo;bar@baz.com');
+INSERT INTO address (id, account_id, street) VALUES (1, 1, 'rue du Bac'), (2, 1, 'rue de Rivoli');
+`
+	res, err := db.Exec(script)
+	if err != nil {
+		t.Fatalf("cannot execute script: %s", err)
+	}
+	if ra, _ := res.RowsAffected(); ra != 2 {
+		t.Fatalf("expected 2 rows affected by last statement, got %d", ra)
+	}
+
+	var email string
+	if err = db.QueryRow(`SELECT email FROM account WHERE id = 1`).Scan(&email); err != nil {
+		t.Fatalf("cannot select email: %s", err)
+	}
+	if email != "foo;bar@baz.com" {
+		t.Fatalf("expected email with semicolon, got %s", email)
+	}
+
+	// Execution stops at the first failing statement
+	_, err = db.Exec(`INSERT INTO account (id, email) VALUES (2, 'a@b.c'); INSERT INTO nope (id) VALUES (1); INSERT INTO account (id, email) VALUES (3, 'd@e.f')`)
+	if err == nil {
+		t.Fatalf("expected error of failing statement")
+	}
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil {
+		t.Fatalf("cannot count accounts: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 accounts, got %d", n)
+	}
+}
+
+func TestLoadSQL(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestLoadSQL")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	seed := `
+-- Accounts
+CREATE TABLE account (
+	id BIGSERIAL PRIMARY KEY,
+	email TEXT NOT NULL,
+	created_at TIMESTAMP
+);
+
+BEGIN;
+INSERT INTO account (email, created_at) VALUES ('foo@bar.com', '2020-02-29 13:37:00'); /* first */
+INSERT INTO account (email, created_at)
+	VALUES ('bar@baz.com', NULL);
+COMMIT;
+`
+	if err = LoadSQL(db, strings.NewReader(seed)); err != nil {
+		t.Fatalf("cannot load seed: %s", err)
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("expected 2 accounts, got %d (%v)", n, err)
+	}
+
+	broken := `INSERT INTO account (email) VALUES ('baz@qux.com');
+
+INSERT INTO account (email)
+	VALUES (NULL);
+INSERT INTO account (email) VALUES ('never@inserted.com');
+`
+	err = LoadSQL(db, strings.NewReader(broken))
+	if err == nil {
+		t.Fatalf("expected error of failing statement")
+	}
+	if !strings.HasPrefix(err.Error(), "statement 2 at line 3: ") {
+		t.Fatalf("expected error of statement 2 at line 3, got: %s", err)
+	}
+	if err = db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 3 {
+		t.Fatalf("expected 3 accounts, got %d (%v)", n, err)
+	}
+}
+
+func TestParseConnectionURIOptions(t *testing.T) {
+	c, err := parseConnectionURI("ramsql://TestParseConnectionURIOptions?mode=memory&shared=false&foreign_keys=off")
+	if err != nil {
+		t.Fatalf("cannot parse DSN: %s", err)
+	}
+	if c.Mode != "memory" || c.Shared || c.ForeignKeys {
+		t.Fatalf("unexpected options: %+v", c)
+	}
+
+	c, err = parseConnectionURI("TestParseConnectionURIOptions")
+	if err != nil {
+		t.Fatalf("cannot parse DSN: %s", err)
+	}
+	if c.Mode != "memory" || !c.Shared || !c.ForeignKeys || c.Seed != nil {
+		t.Fatalf("unexpected default options: %+v", c)
+	}
+
+	c, err = parseConnectionURI("TestParseConnectionURIOptions?seed=-42")
+	if err != nil {
+		t.Fatalf("cannot parse DSN: %s", err)
+	}
+	if c.Seed == nil || *c.Seed != -42 {
+		t.Fatalf("unexpected seed: %+v", c)
+	}
+
+	for dsn, timeout := range map[string]time.Duration{"db?statement_timeout=500ms": 500 * time.Millisecond, "db?statement_timeout=250": 250 * time.Millisecond, "db?statement_timeout=0": 0} {
+		c, err = parseConnectionURI(dsn)
+		if err != nil {
+			t.Fatalf("cannot parse DSN: %s", err)
+		}
+		if c.StatementTimeout != timeout {
+			t.Fatalf("%s: expected statement timeout %s, got %s", dsn, timeout, c.StatementTimeout)
+		}
+	}
+
+	for _, dsn := range []string{"db?cache=shared", "db?mode=disk", "db?shared=maybe", "db?foreign_keys", "db?seed=1.5", "db?statement_timeout=soon", "db?statement_timeout=-1s"} {
+		if _, err = parseConnectionURI(dsn); err == nil {
+			t.Fatalf("expected error parsing %s", dsn)
+		}
+	}
+}
+
+func TestDSNOptions(t *testing.T) {
+	log.UseTestLogger(t)
+
+	ctx := context.Background()
+	count := func(c *sql.Conn) error {
+		var n int
+		return c.QueryRowContext(ctx, `SELECT COUNT(*) FROM account`).Scan(&n)
+	}
+
+	// Connections of a shared database see the same tables
+	shared, err := sql.Open("ramsql", "ramsql://TestDSNOptions?shared=true")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer shared.Close()
+
+	c1, err := shared.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer c1.Close()
+	c2, err := shared.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer c2.Close()
+
+	if _, err = c1.ExecContext(ctx, `CREATE TABLE account (id INT)`); err != nil {
+		t.Fatalf("cannot create table: %s", err)
+	}
+	if err = count(c2); err != nil {
+		t.Fatalf("expected table to be shared: %s", err)
+	}
+
+	// Each connection of an unshared database has its own
+	isolated, err := sql.Open("ramsql", "ramsql://TestDSNOptions?shared=false")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer isolated.Close()
+
+	c3, err := isolated.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer c3.Close()
+	c4, err := isolated.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer c4.Close()
+
+	if _, err = c3.ExecContext(ctx, `CREATE TABLE account (id INT)`); err != nil {
+		t.Fatalf("cannot create table: %s", err)
+	}
+	if err = count(c3); err != nil {
+		t.Fatalf("cannot count accounts: %s", err)
+	}
+	if err = count(c4); err == nil {
+		t.Fatalf("expected table to be seen by its connection only")
+	}
+
+	// Unknown options are reported when connecting
+	wrong, err := sql.Open("ramsql", "TestDSNOptions?cache=shared")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer wrong.Close()
+
+	if err = wrong.Ping(); err == nil || !strings.Contains(err.Error(), "cache") {
+		t.Fatalf("expected error about unknown option, got %v", err)
+	}
+}
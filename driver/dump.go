@@ -0,0 +1,51 @@
+package ramsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/proullon/ramsql/engine"
+)
+
+// Dump writes schemas and rows of the database db is opened on to w,
+// so that they can be restored with Load
+func Dump(db *sql.DB, w io.Writer) error {
+	return withEngine(db, func(e *engine.Engine) error {
+		return e.Dump(w)
+	})
+}
+
+// Load replaces content of the database db is opened on with the one
+// read from r, as written by Dump
+func Load(db *sql.DB, r io.Reader) error {
+	return withEngine(db, func(e *engine.Engine) error {
+		return e.Load(r)
+	})
+}
+
+// DumpSQL writes statements reproducing tables and rows of the database db is
+// opened on to w, so that they can be loaded with LoadSQL, or by PostgreSQL
+func DumpSQL(db *sql.DB, w io.Writer) error {
+	return withEngine(db, func(e *engine.Engine) error {
+		return e.DumpSQL(w)
+	})
+}
+
+// withEngine calls f with the engine db is opened on
+func withEngine(db *sql.DB, f func(e *engine.Engine) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*Conn)
+		if !ok || c.parent == nil {
+			return fmt.Errorf("not a ramsql connection")
+		}
+		return f(c.parent.server)
+	})
+}
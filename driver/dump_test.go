@@ -0,0 +1,259 @@
+package ramsql
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestDumpLoad(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDumpLoad")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	created := time.Date(2020, 2, 29, 13, 37, 0, 0, time.UTC)
+	script := `
+CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT NOT NULL UNIQUE, nickname TEXT, active BOOLEAN DEFAULT true, created_at TIMESTAMP DEFAULT NOW());
+CREATE INDEX account_nickname_idx ON account (nickname);
+CREATE VIEW active_account AS SELECT email FROM account WHERE active = true;
+CREATE TRIGGER no_root BEFORE INSERT ON account FOR EACH ROW WHEN (NEW.nickname = 'root') RAISE 'reserved nickname';
+INSERT INTO account (email, nickname) VALUES ('foo@bar.com', 'foo');
+INSERT INTO account (email, nickname, active) VALUES ('bar@baz.com', NULL, false);
+`
+	if _, err = db.Exec(script); err != nil {
+		t.Fatalf("cannot execute script: %s", err)
+	}
+	if _, err = db.Exec(`UPDATE account SET created_at = $1 WHERE id = 1`, created); err != nil {
+		t.Fatalf("cannot set creation date: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err = Dump(db, &buf); err != nil {
+		t.Fatalf("cannot dump database: %s", err)
+	}
+
+	// Database is changed after dump, to check load restores it
+	if _, err = db.Exec(`INSERT INTO account (email) VALUES ('lost@bar.com')`); err != nil {
+		t.Fatalf("cannot insert account: %s", err)
+	}
+
+	restored, err := sql.Open("ramsql", "TestDumpLoadRestored")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer restored.Close()
+
+	for _, d := range []*sql.DB{restored, db} {
+		if err = Load(d, bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("cannot load database: %s", err)
+		}
+
+		var n int
+		if err = d.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 2 {
+			t.Fatalf("expected 2 accounts, got %d (%v)", n, err)
+		}
+
+		var nickname sql.NullString
+		var at time.Time
+		if err = d.QueryRow(`SELECT nickname, created_at FROM account WHERE email = 'foo@bar.com'`).Scan(&nickname, &at); err != nil {
+			t.Fatalf("cannot select account: %s", err)
+		}
+		if nickname.String != "foo" || !at.Equal(created) {
+			t.Fatalf("expected foo created at %s, got %v at %s", created, nickname, at)
+		}
+		if err = d.QueryRow(`SELECT nickname FROM account WHERE nickname IS NULL`).Scan(&nickname); err != nil || nickname.Valid {
+			t.Fatalf("expected NULL nickname, got %v (%v)", nickname, err)
+		}
+		if err = d.QueryRow(`SELECT COUNT(*) FROM account WHERE nickname = 'foo'`).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("expected 1 account found with index, got %d (%v)", n, err)
+		}
+		if err = d.QueryRow(`SELECT COUNT(*) FROM active_account`).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("expected 1 active account, got %d (%v)", n, err)
+		}
+
+		// Sequences and defaults are restored
+		var id int64
+		var active bool
+		if err = d.QueryRow(`INSERT INTO account (email) VALUES ('new@bar.com') RETURNING id, active, created_at`).Scan(&id, &active, &at); err != nil {
+			t.Fatalf("cannot insert account: %s", err)
+		}
+		if id != 3 || !active || time.Since(at) > time.Minute {
+			t.Fatalf("expected account 3, active and created now, got %d, %v, %s", id, active, at)
+		}
+
+		// Constraints and triggers are restored
+		if _, err = d.Exec(`INSERT INTO account (email) VALUES ('foo@bar.com')`); err == nil {
+			t.Fatalf("expected UNIQUE constraint violation")
+		}
+		if _, err = d.Exec(`INSERT INTO account (email, nickname) VALUES ('root@bar.com', 'root')`); err == nil || !strings.Contains(err.Error(), "reserved nickname") {
+			t.Fatalf("expected trigger to raise reserved nickname, got %v", err)
+		}
+		if _, err = d.Exec(`INSERT INTO account (nickname) VALUES ('anonymous')`); err == nil {
+			t.Fatalf("expected NOT NULL constraint violation")
+		}
+	}
+
+	if err = Load(db, bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Fatalf("expected error loading garbage")
+	}
+}
+
+func TestDumpSQL(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDumpSQL")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	script := `
+CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT NOT NULL UNIQUE, nickname TEXT DEFAULT 'anonymous', created_at TIMESTAMP DEFAULT NOW());
+CREATE TABLE membership (account_id INT, team TEXT, UNIQUE (account_id, team));
+CREATE TABLE access (account_id BIGINT REFERENCES account ON DELETE CASCADE, since TIMESTAMP);
+CREATE INDEX account_nickname_idx ON account (nickname);
+CREATE UNIQUE INDEX membership_team_idx ON membership (team);
+CREATE TRIGGER no_root BEFORE INSERT ON account FOR EACH ROW WHEN (NEW.nickname = 'root') RAISE 'reserved nickname';
+CREATE TRIGGER "Defaults" BEFORE UPDATE ON membership FOR EACH ROW SET NEW.team = 'core';
+INSERT INTO account (email, nickname, created_at) VALUES ('foo@bar.com', 'it''s me', '2020-02-29 13:37:00 +0000 UTC');
+INSERT INTO account (email, nickname, created_at) VALUES ('bar@baz.com', NULL, NULL);
+INSERT INTO membership (account_id, team) VALUES (1, 'core');
+`
+	if _, err = db.Exec(script); err != nil {
+		t.Fatalf("cannot execute script: %s", err)
+	}
+
+	expected := `CREATE TABLE "account" (
+	"id" BIGSERIAL,
+	"email" TEXT NOT NULL UNIQUE,
+	"nickname" TEXT DEFAULT 'anonymous',
+	"created_at" TIMESTAMP DEFAULT now(),
+	PRIMARY KEY ("id")
+);
+INSERT INTO "account" ("id", "email", "nickname", "created_at") VALUES (1, 'foo@bar.com', 'it''s me', '2020-02-29 13:37:00 +0000 UTC');
+INSERT INTO "account" ("id", "email", "nickname", "created_at") VALUES (2, 'bar@baz.com', NULL, NULL);
+CREATE INDEX "account_nickname_idx" ON "account" ("nickname");
+CREATE TRIGGER "no_root" BEFORE INSERT ON "account" FOR EACH ROW WHEN (NEW.nickname = 'root') RAISE 'reserved nickname';
+
+CREATE TABLE "access" (
+	"account_id" BIGINT,
+	"since" TIMESTAMP,
+	FOREIGN KEY ("account_id") REFERENCES "account" ("id") ON DELETE CASCADE
+);
+
+CREATE TABLE "membership" (
+	"account_id" INT,
+	"team" TEXT,
+	UNIQUE ("account_id", "team")
+);
+INSERT INTO "membership" ("account_id", "team") VALUES ('1', 'core');
+CREATE UNIQUE INDEX "membership_team_idx" ON "membership" ("team");
+CREATE TRIGGER "Defaults" BEFORE UPDATE ON "membership" FOR EACH ROW SET NEW.team = 'core';
+
+`
+	var buf bytes.Buffer
+	if err = DumpSQL(db, &buf); err != nil {
+		t.Fatalf("cannot dump database: %s", err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("expected dump\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	// Dump is loaded again as it is
+	restored, err := sql.Open("ramsql", "TestDumpSQLRestored")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer restored.Close()
+
+	if err = LoadSQL(restored, &buf); err != nil {
+		t.Fatalf("cannot load dump: %s", err)
+	}
+	buf.Reset()
+	if err = DumpSQL(restored, &buf); err != nil {
+		t.Fatalf("cannot dump restored database: %s", err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("expected restored dump\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	var at time.Time
+	if err = restored.QueryRow(`SELECT created_at FROM account WHERE id = 1`).Scan(&at); err != nil {
+		t.Fatalf("cannot select creation date: %s", err)
+	}
+	if !at.Equal(time.Date(2020, 2, 29, 13, 37, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected creation date %s", at)
+	}
+	var id int64
+	if err = restored.QueryRow(`INSERT INTO account (email) VALUES ('new@bar.com') RETURNING id`).Scan(&id); err != nil || id != 3 {
+		t.Fatalf("expected account 3, got %d (%v)", id, err)
+	}
+	if _, err = restored.Exec(`INSERT INTO account (email, nickname) VALUES ('root@bar.com', 'root')`); err == nil || !strings.Contains(err.Error(), "reserved nickname") {
+		t.Fatalf("expected trigger to raise reserved nickname, got %v", err)
+	}
+}
+
+func TestDumpSQLValues(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDumpSQLValues")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(`CREATE TABLE event (id INT, at TIMESTAMP, payload BYTEA)`); err != nil {
+		t.Fatalf("cannot create table: %s", err)
+	}
+	at := []time.Time{
+		time.Date(2020, 2, 29, 13, 37, 0, 0, time.UTC),
+		time.Date(2021, 6, 1, 8, 30, 0, 500, time.FixedZone("", 2*3600)),
+	}
+	payload := [][]byte{{0x00, 0x27, 0xff, 0x0a}, []byte("it's")}
+	for i := range at {
+		if _, err = db.Exec(`INSERT INTO event (id, at, payload) VALUES ($1, $2, $3)`, i, at[i], payload[i]); err != nil {
+			t.Fatalf("cannot insert event %d: %s", i, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = DumpSQL(db, &buf); err != nil {
+		t.Fatalf("cannot dump database: %s", err)
+	}
+	if !strings.Contains(buf.String(), `VALUES ('0', '2020-02-29 13:37:00 +0000 UTC', X'0027ff0a');`) {
+		t.Fatalf("unexpected dump\n%s", buf.String())
+	}
+
+	restored, err := sql.Open("ramsql", "TestDumpSQLValuesRestored")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer restored.Close()
+
+	if err = LoadSQL(restored, &buf); err != nil {
+		t.Fatalf("cannot load dump: %s", err)
+	}
+
+	// Values are found with the arguments they were inserted with
+	for i := range at {
+		var id int
+		if err = restored.QueryRow(`SELECT id FROM event WHERE at = $1`, at[i]).Scan(&id); err != nil || id != i {
+			t.Fatalf("expected event %d at %s, got %d (%v)", i, at[i], id, err)
+		}
+		var p []byte
+		if err = restored.QueryRow(`SELECT payload FROM event WHERE payload = $1`, payload[i]).Scan(&p); err != nil {
+			t.Fatalf("cannot select payload %x: %s", payload[i], err)
+		}
+		if !bytes.Equal(p, payload[i]) {
+			t.Fatalf("expected payload %x, got %x", payload[i], p)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package ramsql
+
+import (
+	"github.com/proullon/ramsql/engine"
+)
+
+// Error is returned by Exec and Query when engine fails to execute a statement.
+// Like pq.Error, its code classifies it, so it can be checked with errors.As:
+//
+//	var e *ramsql.Error
+//	if errors.As(err, &e) && e.Code == ramsql.UniqueViolation {
+//		...
+//	}
+type Error = engine.Error
+
+// ErrorCode is the SQLSTATE code of an Error
+type ErrorCode = engine.ErrorCode
+
+// Codes of errors returned by engine
+const (
+	InvalidTextRepresentation  = engine.InvalidTextRepresentation
+	NotNullViolation           = engine.NotNullViolation
+	ForeignKeyViolation        = engine.ForeignKeyViolation
+	UniqueViolation            = engine.UniqueViolation
+	CheckViolation             = engine.CheckViolation
+	ReadOnlySQLTransaction     = engine.ReadOnlySQLTransaction
+	InFailedSQLTransaction     = engine.InFailedSQLTransaction
+	DependentObjectsStillExist = engine.DependentObjectsStillExist
+	DeadlockDetected           = engine.DeadlockDetected
+	QueryCanceled              = engine.QueryCanceled
+	SyntaxError                = engine.SyntaxError
+	DatatypeMismatch           = engine.DatatypeMismatch
+	DuplicateColumn            = engine.DuplicateColumn
+	AmbiguousColumn            = engine.AmbiguousColumn
+	UndefinedColumn            = engine.UndefinedColumn
+	UndefinedObject            = engine.UndefinedObject
+	DuplicateObject            = engine.DuplicateObject
+	UndefinedFunction          = engine.UndefinedFunction
+	UndefinedTable             = engine.UndefinedTable
+	DuplicateTable             = engine.DuplicateTable
+	InternalError              = engine.InternalError
+)
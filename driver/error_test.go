@@ -0,0 +1,82 @@
+package ramsql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestError(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestError")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	script := `
+CREATE TABLE account (id INT PRIMARY KEY, email TEXT NOT NULL, age INT CHECK (age > 0));
+CREATE TABLE session (id INT PRIMARY KEY, account_id INT REFERENCES account (id));
+CREATE TABLE visit (id SERIAL, page TEXT);
+INSERT INTO account (id, email, age) VALUES (1, 'foo@bar.com', 42);
+`
+	if _, err = db.Exec(script); err != nil {
+		t.Fatalf("cannot execute script: %s", err)
+	}
+
+	expected := []struct {
+		query      string
+		code       ErrorCode
+		name       string
+		table      string
+		column     string
+		constraint string
+	}{
+		{`INSERT INTO account (id, email) VALUES (1, 'bar@baz.com')`, UniqueViolation, "unique_violation", "account", "", "account_pkey"},
+		{`INSERT INTO account (id) VALUES (2)`, NotNullViolation, "not_null_violation", "account", "email", ""},
+		{`INSERT INTO account (id, email, age) VALUES (2, 'bar@baz.com', 0)`, CheckViolation, "check_violation", "account", "", "account_age_check"},
+		{`INSERT INTO session (id, account_id) VALUES (1, 2)`, ForeignKeyViolation, "foreign_key_violation", "session", "", "session_account_id_fkey"},
+		{`SELECT * FROM missing`, UndefinedTable, "undefined_table", "missing", "", ""},
+		{`SELECT missing FROM account`, UndefinedColumn, "undefined_column", "", "missing", ""},
+		{`CREATE TABLE account (id INT)`, DuplicateTable, "duplicate_table", "account", "", ""},
+		{`SELEKT * FROM account`, SyntaxError, "syntax_error", "", "", ""},
+		{`SELECT CAST(email AS INTEGER) FROM account`, InvalidTextRepresentation, "invalid_text_representation", "", "", ""},
+		{`INSERT INTO visit (id, page) VALUES ('first', 'home')`, InvalidTextRepresentation, "invalid_text_representation", "", "", ""},
+	}
+	for _, exp := range expected {
+		_, err := db.Exec(exp.query)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected '%s' to fail with an Error, got %v", exp.query, err)
+		}
+		if e.Code != exp.code || e.Code.Name() != exp.name || e.Table != exp.table || e.Column != exp.column || e.Constraint != exp.constraint {
+			t.Fatalf("'%s': unexpected error %+v", exp.query, e)
+		}
+		if e.Error() != err.Error() || e.Message == "" {
+			t.Fatalf("'%s': expected message, got %s", exp.query, e.Message)
+		}
+	}
+
+	// Syntax errors are located in statement
+	_, err = db.Exec("SELECT id\nFROM account\nWHERE id = = 1")
+	var se *Error
+	if !errors.As(err, &se) || se.Code != SyntaxError || se.Position != 35 || se.Line != 3 || se.ColumnPosition != 12 {
+		t.Fatalf("expected located syntax error, got %+v", se)
+	}
+	for query, position := range map[string]int{`SELEKT * FROM account`: 1, `SELECT * FROM account WHERE`: 28, `SELECT * FROM account a b`: 25} {
+		_, err = db.Exec(query)
+		if !errors.As(err, &se) || se.Code != SyntaxError || se.Position != position || se.Line != 1 || se.ColumnPosition != position {
+			t.Fatalf("'%s': expected syntax error at %d, got %v", query, position, err)
+		}
+	}
+
+	// Queries return them too
+	_, err = db.Query(`SELECT * FROM missing`)
+	var e *Error
+	if !errors.As(err, &e) || e.Code != UndefinedTable {
+		t.Fatalf("expected undefined table error, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package ramsql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -37,3 +39,27 @@ func InitSchemas(db *sql.DB, sqlfile string) error {
 
 	return nil
 }
+
+// LoadSQL executes each statement of the SQL script read from r, in order, on a single
+// connection. Statements may span several lines and contain comments. Execution stops at
+// the first failing statement, which is reported with its position and line in script.
+func LoadSQL(db *sql.DB, r io.Reader) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for i, s := range scriptStatements(string(content)) {
+		if _, err := conn.ExecContext(context.Background(), s.query); err != nil {
+			return fmt.Errorf("statement %d at line %d: %s", i+1, s.line, err)
+		}
+	}
+
+	return nil
+}
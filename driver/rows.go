@@ -5,30 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"sync"
+	"time"
 
-	"github.com/proullon/ramsql/engine/log"
 	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
 )
 
 // Rows implements the sql/driver Rows interface
 type Rows struct {
-	rowsChannel chan []string
+	rowsChannel chan []*string
 	columns     []string
+	types       []protocol.ColumnType
 
 	sync.Mutex
 }
 
-func newRows(channel chan []string) *Rows {
-	r := &Rows{rowsChannel: channel}
-	c, ok := <-channel
-	if !ok {
-		log.Critical("Cannot receive column names form channel")
-		return nil
-	}
-
-	r.columns = c
-	return r
+func newRows(columns []string, channel chan []*string, types []protocol.ColumnType) *Rows {
+	return &Rows{rowsChannel: channel, columns: columns, types: types}
 }
 
 // Columns returns the names of the columns. The number of
@@ -39,6 +34,60 @@ func (r *Rows) Columns() []string {
 	return r.columns
 }
 
+// ColumnTypeDatabaseTypeName returns the database system type name of
+// the column, like INT or TEXT, as declared in table. An empty string
+// is returned for computed values, whose type is unknown.
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < 0 || index >= len(r.types) {
+		return ""
+	}
+
+	return r.types[index].DatabaseTypeName
+}
+
+// ColumnTypeNullable returns true if the column may be NULL, that is
+// if it was not declared NOT NULL. It is unknown for computed values.
+func (r *Rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if index < 0 || index >= len(r.types) {
+		return false, false
+	}
+
+	return r.types[index].Nullable, r.types[index].HasNullable
+}
+
+// ColumnTypeScanType returns the Go type of values of the column,
+// as declared in table. Decimal values are scanned as strings, so that
+// they are not rounded, and computed values in an interface{}.
+func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.ColumnTypeDatabaseTypeName(index) {
+	case "INT", "INTEGER", "INT2", "INT4", "INT8", "SMALLINT", "BIGINT", "SMALLSERIAL", "SERIAL", "BIGSERIAL":
+		return reflect.TypeOf(int64(0))
+	case "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE":
+		return reflect.TypeOf(float64(0))
+	case "BOOL", "BOOLEAN":
+		return reflect.TypeOf(false)
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE", "DATETIME":
+		return reflect.TypeOf(time.Time{})
+	case "BYTEA", "BLOB":
+		return reflect.TypeOf([]byte(nil))
+	case "TEXT", "VARCHAR", "CHAR", "CHARACTER", "UUID", "JSON", "DECIMAL", "NUMERIC":
+		return reflect.TypeOf("")
+	}
+
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+// isDate returns true if values of the column may be dates,
+// that is if it is declared as one or if its type is unknown
+func (r *Rows) isDate(index int) bool {
+	switch r.ColumnTypeDatabaseTypeName(index) {
+	case "", "TIMESTAMP", "TIMESTAMPTZ", "DATE", "DATETIME":
+		return true
+	}
+
+	return false
+}
+
 // Close closes the rows iterator.
 func (r *Rows) Close() error {
 	r.Lock()
@@ -48,13 +97,11 @@ func (r *Rows) Close() error {
 		return nil
 	}
 
-	_, ok := <-r.rowsChannel
-	if !ok {
-		return nil
+	// Drain remaining rows, so UnlimitedRowsChannel stops listening to the connection
+	// and does not take the answer of next statement
+	for range r.rowsChannel {
 	}
 
-	// Tels UnlimitedRowsChannel to close itself
-	//r.rowsChannel <- []string{}
 	r.rowsChannel = nil
 	return nil
 }
@@ -87,19 +134,20 @@ func (r *Rows) Next(dest []driver.Value) (err error) {
 	}
 
 	for i, v := range value {
-		if v == "<nil>" {
+		if v == nil {
 			dest[i] = nil
 			continue
 		}
 
-		// TODO: make rowsChannel send virtualRows,
-		// so we have the type and don't blindy try to parse date here
-		if t, err := parser.ParseDate(string(v)); err == nil {
-			dest[i] = *t
-		} else {
-
-			dest[i] = []byte(v)
+		// Values of computed columns, which type is unknown, may be dates as well
+		if r.isDate(i) {
+			if t, err := parser.ParseDate(*v); err == nil {
+				dest[i] = *t
+				continue
+			}
 		}
+
+		dest[i] = []byte(*v)
 	}
 
 	return nil
@@ -0,0 +1,87 @@
+package ramsql
+
+import (
+	"strings"
+)
+
+// scriptStatement is a statement of a script, with the line it starts at
+type scriptStatement struct {
+	query string
+	line  int
+}
+
+// splitStatements returns the statements of a script, separated by semicolons.
+// Semicolons in string literals are part of them, and comments are removed.
+func splitStatements(script string) []string {
+	var statements []string
+	for _, s := range scriptStatements(script) {
+		statements = append(statements, s.query)
+	}
+
+	return statements
+}
+
+// scriptStatements returns the statements of a script like splitStatements,
+// with the line each one starts at
+func scriptStatements(script string) []scriptStatement {
+	var statements []scriptStatement
+	var stmt strings.Builder
+	start := -1
+
+	end := func() {
+		if s := strings.TrimSpace(stmt.String()); s != "" {
+			statements = append(statements, scriptStatement{query: s, line: strings.Count(script[:start], "\n") + 1})
+		}
+		stmt.Reset()
+		start = -1
+	}
+
+	for i := 0; i < len(script); i++ {
+		if start < 0 && !strings.HasPrefix(script[i:], "--") && !strings.HasPrefix(script[i:], "/*") &&
+			!strings.ContainsRune(" \t\r\n;", rune(script[i])) {
+			start = i
+		}
+
+		switch {
+		case script[i] == '\'' || script[i] == '"':
+			j := closingQuote(script, i)
+			if j == len(script) {
+				j--
+			}
+			stmt.WriteString(script[i : j+1])
+			i = j
+		case strings.HasPrefix(script[i:], "$$"):
+			j := strings.Index(script[i+2:], "$$")
+			if j < 0 {
+				stmt.WriteString(script[i:])
+				i = len(script)
+				continue
+			}
+			stmt.WriteString(script[i : i+j+4])
+			i += j + 3
+		case strings.HasPrefix(script[i:], "--"):
+			j := strings.IndexByte(script[i:], '\n')
+			if j < 0 {
+				i = len(script)
+				continue
+			}
+			stmt.WriteByte(' ')
+			i += j
+		case strings.HasPrefix(script[i:], "/*"):
+			j := strings.Index(script[i+2:], "*/")
+			if j < 0 {
+				i = len(script)
+				continue
+			}
+			stmt.WriteByte(' ')
+			i += j + 3
+		case script[i] == ';':
+			end()
+		default:
+			stmt.WriteByte(script[i])
+		}
+	}
+	end()
+
+	return statements
+}
@@ -0,0 +1,54 @@
+package ramsql
+
+import (
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- account holds users; one per email
+CREATE TABLE account (id INT, email TEXT);
+/* notes; free text */
+INSERT INTO account (id, email) VALUES (1, 'foo;bar@baz.com'), (2, $$it's;$$);;
+UPDATE account SET email = 'a''b;c' WHERE id = 1
+`
+	expected := []string{
+		`CREATE TABLE account (id INT, email TEXT)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo;bar@baz.com'), (2, $$it's;$$)`,
+		`UPDATE account SET email = 'a''b;c' WHERE id = 1`,
+	}
+
+	statements := splitStatements(script)
+	if len(statements) != len(expected) {
+		t.Fatalf("Expected %d statements, got %d: %q", len(expected), len(statements), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Fatalf("Expected statement <%s>, got <%s>", expected[i], statements[i])
+		}
+	}
+}
+
+func TestScriptStatementsLines(t *testing.T) {
+	script := `-- seed
+CREATE TABLE account (
+	id INT,
+	email TEXT
+);
+
+/* first
+   accounts */ INSERT INTO account (id, email) VALUES (1, 'foo
+bar'); INSERT INTO account (id, email) VALUES (2, 'baz');
+`
+	expected := []int{2, 8, 9}
+
+	statements := scriptStatements(script)
+	if len(statements) != len(expected) {
+		t.Fatalf("Expected %d statements, got %d: %v", len(expected), len(statements), statements)
+	}
+	for i := range expected {
+		if statements[i].line != expected[i] {
+			t.Fatalf("Expected statement <%s> at line %d, got %d", statements[i].query, expected[i], statements[i].line)
+		}
+	}
+}
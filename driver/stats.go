@@ -0,0 +1,18 @@
+package ramsql
+
+import (
+	"database/sql"
+
+	"github.com/proullon/ramsql/engine"
+)
+
+// Stats returns statistics of the database db is opened on
+func Stats(db *sql.DB) (engine.Stats, error) {
+	var s engine.Stats
+	err := withEngine(db, func(e *engine.Engine) error {
+		s = e.Stats()
+		return nil
+	})
+
+	return s, err
+}
@@ -0,0 +1,57 @@
+package ramsql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestStats(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestStats")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	script := `
+CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT);
+CREATE TABLE session (id BIGSERIAL, account_id INT);
+CREATE INDEX account_email_idx ON account (email);
+CREATE VIEW accounts AS SELECT email FROM account;
+INSERT INTO account (email) VALUES ('foo@bar.com'), ('bar@baz.com'), ('baz@qux.com');
+INSERT INTO session (account_id) VALUES (1), (2);
+`
+	if _, err = db.Exec(script); err != nil {
+		t.Fatalf("cannot execute script: %s", err)
+	}
+
+	s, err := Stats(db)
+	if err != nil {
+		t.Fatalf("cannot get stats: %s", err)
+	}
+	if s.Tables != 2 || s.Views != 1 || s.Indexes != 1 || s.Rows["account"] != 3 || s.Rows["session"] != 2 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	if s.Memory <= 0 {
+		t.Fatalf("expected memory estimate, got %d", s.Memory)
+	}
+	memory := s.Memory
+
+	if _, err = db.Exec(`DELETE FROM account WHERE id = 1; TRUNCATE session`); err != nil {
+		t.Fatalf("cannot delete rows: %s", err)
+	}
+
+	s, err = Stats(db)
+	if err != nil {
+		t.Fatalf("cannot get stats: %s", err)
+	}
+	if s.Rows["account"] != 2 || s.Rows["session"] != 0 {
+		t.Fatalf("unexpected row counts after delete: %v", s.Rows)
+	}
+	if s.Memory >= memory {
+		t.Fatalf("expected memory estimate to decrease from %d, got %d", memory, s.Memory)
+	}
+}
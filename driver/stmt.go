@@ -1,13 +1,18 @@
 package ramsql
 
 import (
+	"context"
 	"database/sql/driver"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/proullon/ramsql/engine/log"
+	"github.com/proullon/ramsql/engine/parser"
 )
 
 // Stmt implements the Statement interface of sql/driver
@@ -15,28 +20,133 @@ type Stmt struct {
 	conn     *Conn
 	query    string
 	numInput int
+	closed   bool
 }
 
+// marker is a parameter marker of a query, either $1, ?, or named like @id or :id
+type marker struct {
+	start int
+	end   int
+	// position of argument starting at 1, or 0 for ? which takes next one
+	index int
+	name  string
+}
+
+// markers returns parameter markers of query, ignoring the ones in string literals,
+// quoted identifiers and comments
+func markers(query string) []marker {
+	var res []marker
+
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\'' || query[i] == '"':
+			i = closingQuote(query, i)
+		case strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			if end < 0 {
+				return res
+			}
+			i += end + 3
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return res
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return res
+			}
+			i += end + 3
+		case strings.HasPrefix(query[i:], "::"):
+			// Type cast
+			i++
+		case query[i] == '@' || query[i] == ':':
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || unicode.IsLetter(rune(query[j])) || (j > i+1 && unicode.IsDigit(rune(query[j])))) {
+				j++
+			}
+			if j == i+1 {
+				continue
+			}
+			res = append(res, marker{start: i, end: j, name: query[i+1 : j]})
+			i = j - 1
+		case query[i] == '?':
+			res = append(res, marker{start: i, end: i + 1})
+		case query[i] == '$':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				continue
+			}
+			index, err := strconv.Atoi(query[i+1 : j])
+			if err != nil {
+				continue
+			}
+			res = append(res, marker{start: i, end: j, index: index})
+			i = j - 1
+		}
+	}
+
+	return res
+}
+
+// closingQuote returns the position of the quote closing the one at given position,
+// doubled quotes being part of quoted string
+func closingQuote(query string, start int) int {
+	quote := query[start]
+
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+
+	return len(query)
+}
+
+// countArguments returns the number of arguments of query, either the number of ? markers,
+// the greatest position of $ markers, or the number of names of named markers.
+// It returns -1 if query mixes named and positional markers.
 func countArguments(query string) int {
-	for id := 1; id > 0; id++ {
-		sep := fmt.Sprintf("$%d", id)
-		if strings.Count(query, sep) == 0 {
-			return id - 1
+	var odbc, postgres int
+	names := make(map[string]bool)
+
+	for _, m := range markers(query) {
+		switch {
+		case m.name != "":
+			names[m.name] = true
+		case m.index == 0:
+			odbc++
+		case m.index > postgres:
+			postgres = m.index
 		}
 	}
 
-	return -1
+	switch {
+	case len(names) > 0 && odbc+postgres > 0:
+		return -1
+	case len(names) > 0:
+		return len(names)
+	case odbc > 0:
+		return odbc
+	}
+	return postgres
 }
 
 func prepareStatement(c *Conn, query string) *Stmt {
 
 	// Parse number of arguments here
 	// Should handler either Postgres ($*) or ODBC (?) parameter markers
-	numInput := strings.Count(query, "?")
-	// if numInput == 0, maybe it's Postgres format
-	if numInput == 0 {
-		numInput = countArguments(query)
-	}
+	numInput := countArguments(query)
 
 	// Create statement
 	stmt := &Stmt{
@@ -45,7 +155,6 @@ func prepareStatement(c *Conn, query string) *Stmt {
 		numInput: numInput,
 	}
 
-	stmt.conn.mutex.Lock()
 	return stmt
 }
 
@@ -53,8 +162,12 @@ func prepareStatement(c *Conn, query string) *Stmt {
 //
 // As of Go 1.1, a Stmt will not be closed if it's in use
 // by any queries.
+//
+// Statement has no state on server, and connection is only
+// locked while executing it, so there is nothing to release.
 func (s *Stmt) Close() error {
-	return fmt.Errorf("Not implemented.")
+	s.closed = true
+	return nil
 }
 
 // NumInput returns the number of placeholder parameters.
@@ -70,128 +183,270 @@ func (s *Stmt) NumInput() int {
 	return s.numInput
 }
 
+// CheckNamedValue keeps float32 arguments as they are, so they are formatted
+// with their own precision. Other arguments are converted by default converter.
+func (s *Stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(float32); ok {
+		return nil
+	}
+
+	return driver.ErrSkip
+}
+
 // Exec executes a query that doesn't return rows, such
 // as an INSERT or UPDATE.
 func (s *Stmt) Exec(args []driver.Value) (r driver.Result, err error) {
+	return s.exec(context.Background(), namedValues(args))
+}
+
+// ExecContext executes a query that doesn't return rows, such
+// as an INSERT or UPDATE. Server stops executing it once ctx is done.
+func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.exec(ctx, args)
+}
+
+func (s *Stmt) exec(ctx context.Context, args []driver.NamedValue) (r driver.Result, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("fatalf error: %s", r)
 			return
 		}
 	}()
+	s.conn.mutex.Lock()
 	defer s.conn.mutex.Unlock()
 
+	if s.closed {
+		return nil, fmt.Errorf("statement is closed")
+	}
+
 	if s.query == "" {
 		return nil, fmt.Errorf("empty statement")
 	}
 
-	var finalQuery string
-
-	// replace $* by arguments in query string
-	finalQuery = replaceArguments(s.query, args)
+	// replace markers by arguments in query string
+	finalQuery, err := bindArguments(s.query, args)
+	if err != nil {
+		return nil, err
+	}
 	log.Info("Exec <%s>\n", finalQuery)
 
+	// A script is executed one statement after the other, up to the first failing one
+	statements := splitStatements(finalQuery)
+	if len(statements) == 0 {
+		statements = []string{finalQuery}
+	}
+
+	var lastInsertedID, rowsAffected int64
+	for _, stmt := range statements {
+		start := time.Now()
+		lastInsertedID, rowsAffected, err = s.execStatement(ctx, stmt)
+		logQuery(stmt, args, start, rowsAffected, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create a driver.Result, of last statement
+	return newResult(lastInsertedID, rowsAffected), nil
+}
+
+// execStatement sends a single statement not returning rows to server, and waits for its result
+func (s *Stmt) execStatement(ctx context.Context, stmt string) (int64, int64, error) {
 	// Send query to server
-	err = s.conn.conn.WriteExec(finalQuery)
+	err := s.conn.conn.WriteExecContext(ctx, stmt)
 	if err != nil {
 		log.Warning("Exec: Cannot send query to server: %s", err)
-		return nil, fmt.Errorf("Cannot send query to server: %s", err)
+		return 0, 0, fmt.Errorf("Cannot send query to server: %s", err)
 	}
 
-	// Get answer from server
+	// Get answer from server, which stops executing query once context is done
 	lastInsertedID, rowsAffected, err := s.conn.conn.ReadResult()
 	if err != nil {
-		return nil, err
+		if ctx.Err() != nil {
+			return 0, 0, ctx.Err()
+		}
+		return 0, 0, err
 	}
 
-	// Create a driver.Result
-	return newResult(lastInsertedID, rowsAffected), nil
+	return lastInsertedID, rowsAffected, nil
 }
 
 // Query executes a query that may return rows, such as a
 // SELECT.
 func (s *Stmt) Query(args []driver.Value) (r driver.Rows, err error) {
+	return s.queryRows(context.Background(), namedValues(args))
+}
+
+// QueryContext executes a query that may return rows, such as a
+// SELECT. Server stops executing it once ctx is done.
+func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.queryRows(ctx, args)
+}
+
+func (s *Stmt) queryRows(ctx context.Context, args []driver.NamedValue) (r driver.Rows, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("fatalf error: %s", r)
 			return
 		}
 	}()
+	s.conn.mutex.Lock()
 	defer s.conn.mutex.Unlock()
 
+	if s.closed {
+		return nil, fmt.Errorf("statement is closed")
+	}
+
 	if s.query == "" {
 		return nil, fmt.Errorf("empty statement")
 	}
 
-	finalQuery := replaceArguments(s.query, args)
+	finalQuery, err := bindArguments(s.query, args)
+	if err != nil {
+		return nil, err
+	}
 	log.Info("Query < %s >\n", finalQuery)
-	err = s.conn.conn.WriteQuery(finalQuery)
+	start := time.Now()
+	err = s.conn.conn.WriteQueryContext(ctx, finalQuery)
 	if err != nil {
+		logQuery(finalQuery, args, start, -1, err)
 		return nil, err
 	}
 
-	rowsChannel, err := s.conn.conn.ReadRows()
+	// Get answer from server, which stops executing query once context is done
+	columns, rowsChannel, err := s.conn.conn.ReadRows()
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	logQuery(finalQuery, args, start, -1, err)
 	if err != nil {
 		return nil, err
 	}
 
-	r = newRows(rowsChannel)
+	r = newRows(columns, rowsChannel, s.conn.conn.ColumnTypes())
 	return r, nil
 }
 
-// replace $* by arguments in query string
-func replaceArguments(query string, args []driver.Value) string {
+// namedValues returns arguments given by position
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
 
-	holder := regexp.MustCompile(`[^\$]\$[0-9]+`)
-	replacedQuery := ""
+	return named
+}
 
-	if strings.Count(query, "?") == len(args) {
-		return replaceArgumentsODBC(query, args)
+// replace $* and ? by arguments in query string
+func replaceArguments(query string, args []driver.Value) string {
+	replacedQuery, err := bindArguments(query, namedValues(args))
+	if err != nil {
+		log.Warning("Cannot replace arguments: %s\n", err)
+		return query
 	}
 
-	allloc := holder.FindAllIndex([]byte(query), -1)
-	queryB := []byte(query)
-	for i, loc := range allloc {
-		match := queryB[loc[0]+1 : loc[1]]
+	return replacedQuery
+}
 
-		index, err := strconv.Atoi(string(match[1:]))
-		if err != nil {
-			log.Warning("Matched %s as a placeholder but cannot get index: %s\n", match, err)
-			return query
-		}
+// bindArguments replaces markers in query string by arguments, given by position
+// for $* and ? markers, and by name for named markers
+func bindArguments(query string, args []driver.NamedValue) (string, error) {
+	var replacedQuery strings.Builder
 
-		var v string
-		if args[index-1] == nil {
-			v = "null"
+	ms := markers(query)
+	named, positional := false, false
+	for _, m := range ms {
+		if m.name != "" {
+			named = true
 		} else {
-			v = fmt.Sprintf("$$%v$$", args[index-1])
+			positional = true
 		}
-		if i == 0 {
-			replacedQuery = fmt.Sprintf("%s%s%s", replacedQuery, string(queryB[:loc[0]+1]), v)
-		} else {
-			replacedQuery = fmt.Sprintf("%s%s%s", replacedQuery, string(queryB[allloc[i-1][1]:loc[0]+1]), v)
+	}
+	if named && positional {
+		return "", errors.New("cannot mix named and positional parameters")
+	}
+
+	next, prev := 0, 0
+	for _, m := range ms {
+		var arg *driver.NamedValue
+
+		switch {
+		case m.name != "":
+			for i := range args {
+				if args[i].Name == m.name {
+					arg = &args[i]
+					break
+				}
+			}
+			if arg == nil {
+				return "", fmt.Errorf("no argument named %s given for parameter %s", m.name, query[m.start:m.end])
+			}
+		default:
+			i := m.index - 1
+			if m.index == 0 {
+				i = next
+				next++
+			}
+			if i >= len(args) {
+				return "", fmt.Errorf("no argument given for parameter %s", query[m.start:m.end])
+			}
+			arg = &args[i]
+			if arg.Name != "" {
+				return "", fmt.Errorf("named argument %s given for positional parameter %s", arg.Name, query[m.start:m.end])
+			}
 		}
+
+		replacedQuery.WriteString(query[prev:m.start])
+		replacedQuery.WriteString(formatArgument(arg.Value))
+		prev = m.end
 	}
 	// add remaining query
-	replacedQuery = fmt.Sprintf("%s%s", replacedQuery, string(queryB[allloc[len(allloc)-1][1]:]))
+	replacedQuery.WriteString(query[prev:])
 
-	return replacedQuery
+	return replacedQuery.String(), nil
 }
 
-func replaceArgumentsODBC(query string, args []driver.Value) string {
-	var finalQuery string
-
-	queryParts := strings.Split(query, "?")
-	finalQuery = queryParts[0]
-	for i := range args {
-		arg := fmt.Sprintf("%v", args[i])
-		_, ok := args[i].(string)
-		if ok && !strings.HasSuffix(query, "'") {
-			arg = "$$" + arg + "$$"
+// formatArgument returns the literal of an argument. Byte slices are given in hexadecimal,
+// times in engine timestamp format with their time zone, and booleans as TRUE or FALSE.
+// Floats are given with the shortest representation parsed back to the same value.
+// Other values are escaped with $$ for every kind of marker, so that negative numbers are
+// parsed as a whole value.
+func formatArgument(arg driver.Value) string {
+	switch arg := arg.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quoteString(arg)
+	case []byte:
+		return "X'" + hex.EncodeToString(arg) + "'"
+	case time.Time:
+		return quoteString(arg.Format(parser.DateLongFormat))
+	case bool:
+		if arg {
+			return "TRUE"
 		}
-		finalQuery += arg
-		finalQuery += queryParts[i+1]
+		return "FALSE"
+	}
+
+	var literal string
+	switch arg := arg.(type) {
+	case float64:
+		literal = strconv.FormatFloat(arg, 'g', -1, 64)
+	case float32:
+		literal = strconv.FormatFloat(float64(arg), 'g', -1, 32)
+	default:
+		literal = fmt.Sprintf("%v", arg)
+	}
+
+	return "$$" + literal + "$$"
+}
+
+// quoteString returns the literal of a string argument, escaped with $$ unless it would
+// end escaping too early. Then it is between single quotes, with its quotes doubled.
+func quoteString(s string) string {
+	if !strings.Contains(s, "$$") && !strings.HasSuffix(s, "$") {
+		return "$$" + s + "$$"
 	}
 
-	return finalQuery
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
 }
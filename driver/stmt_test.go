@@ -10,7 +10,7 @@ func TestNumInputQuestionMarker(t *testing.T) {
 	// Create a new stub Conn
 	c := &Conn{}
 
-	stmt := prepareStatement(c, "SELECT * FROM account WHERE email = '?'")
+	stmt := prepareStatement(c, "SELECT * FROM account WHERE email = ?")
 	if stmt == nil {
 		t.Fatal("prepareStatement should not return nil")
 	}
@@ -35,6 +35,92 @@ func TestNumInputPostgreMarker(t *testing.T) {
 	}
 }
 
+func TestNumInputIgnoresLiterals(t *testing.T) {
+	queries := map[string]int{
+		`SELECT * FROM account WHERE email = '?'`:                            0,
+		`SELECT '$1 is literal' FROM account WHERE x = $1`:                   1,
+		`SELECT 'it''s ?' FROM account WHERE x = ? AND y = ?`:                2,
+		`SELECT $$what? $3$$ FROM account WHERE x = $2`:                      2,
+		`SELECT "col?" FROM account WHERE x = ?`:                             1,
+		"SELECT * FROM account -- where x = ?\nWHERE y = $1":                 1,
+		`SELECT * FROM account /* x = $4 or ? */ WHERE y = $1 AND z = $2`:    2,
+		`SELECT * FROM account WHERE note = 'unterminated ? $1`:              0,
+		`SELECT * FROM account WHERE price = $1 AND label = 'cost: $5 or ?'`: 1,
+	}
+
+	for query, expected := range queries {
+		stmt := prepareStatement(&Conn{}, query)
+		if stmt.numInput != expected {
+			t.Fatalf("prepareStatement expected %d input for <%s>, got %d", expected, query, stmt.numInput)
+		}
+	}
+}
+
+func TestReplaceIgnoresLiterals(t *testing.T) {
+	query := `SELECT '$1 is literal', 'why?' FROM account /* $2 */ WHERE x = $1 -- or $2`
+	wantedQuery := `SELECT '$1 is literal', 'why?' FROM account /* $2 */ WHERE x = $$foo$$ -- or $2`
+	args := []driver.Value{
+		driver.Value("foo"),
+	}
+
+	testReplaceArguments(t, query, args, wantedQuery)
+
+	query = `SELECT * FROM account WHERE note = 'what?' AND x = ? AND y = ?`
+	wantedQuery = `SELECT * FROM account WHERE note = 'what?' AND x = $$1$$ AND y = $$bar$$`
+	args = []driver.Value{
+		driver.Value(1),
+		driver.Value("bar"),
+	}
+
+	testReplaceArguments(t, query, args, wantedQuery)
+}
+
+func TestNumInputNamed(t *testing.T) {
+	queries := map[string]int{
+		`SELECT * FROM account WHERE id = @id OR other = @id`:     1,
+		`SELECT * FROM account WHERE id = :id AND name = :name`:   2,
+		`SELECT * FROM account WHERE email = 'foo@bar.com'`:       0,
+		`SELECT id::text FROM account WHERE id = $1`:              1,
+		`SELECT * FROM account WHERE id = @id AND name = $1`:      -1,
+		`SELECT * FROM account WHERE id = @id_2 AND name = @name`: 2,
+	}
+
+	for query, expected := range queries {
+		stmt := prepareStatement(&Conn{}, query)
+		if stmt.numInput != expected {
+			t.Fatalf("prepareStatement expected %d input for <%s>, got %d", expected, query, stmt.numInput)
+		}
+	}
+}
+
+func TestBindNamedArguments(t *testing.T) {
+	query := `SELECT * FROM account WHERE id = @id OR other = @id AND name = :name`
+	wantedQuery := `SELECT * FROM account WHERE id = $$42$$ OR other = $$42$$ AND name = $$foo$$`
+	args := []driver.NamedValue{
+		{Name: "name", Ordinal: 1, Value: "foo"},
+		{Name: "id", Ordinal: 2, Value: 42},
+	}
+
+	finalQuery, err := bindArguments(query, args)
+	if err != nil {
+		t.Fatalf("Cannot bind arguments: %s", err)
+	}
+	if finalQuery != wantedQuery {
+		t.Fatalf("Expected <%s>, got <%s>", wantedQuery, finalQuery)
+	}
+
+	rejected := map[string][]driver.NamedValue{
+		`SELECT * FROM account WHERE id = @id AND name = $1`: {{Name: "id", Ordinal: 1, Value: 1}, {Ordinal: 2, Value: "foo"}},
+		`SELECT * FROM account WHERE id = @id`:               {{Name: "other", Ordinal: 1, Value: 1}},
+		`SELECT * FROM account WHERE id = $1`:                {{Name: "id", Ordinal: 1, Value: 1}},
+	}
+	for query, args := range rejected {
+		if _, err := bindArguments(query, args); err == nil {
+			t.Fatalf("Expected error binding arguments of <%s>", query)
+		}
+	}
+}
+
 func TestReplaceArgument(t *testing.T) {
 	query := `SELECT * FROM account WHERE email = $1`
 	wantedQuery := `SELECT * FROM account WHERE email = $$foo@bar.com$$`
@@ -114,6 +200,45 @@ func TestReplaceALot(t *testing.T) {
 	testReplaceArguments(t, query, args, wantedQuery)
 }
 
+func TestReplaceQuotes(t *testing.T) {
+	query := `INSERT INTO account (name, email, note) VALUES ($1, $2, $3)`
+	wantedQuery := `INSERT INTO account (name, email, note) VALUES ($$O'Brien$$, 'pay$$''s', 'costs 5$')`
+	args := []driver.Value{
+		driver.Value("O'Brien"),
+		driver.Value("pay$$'s"),
+		driver.Value("costs 5$"),
+	}
+
+	testReplaceArguments(t, query, args, wantedQuery)
+}
+
+func TestReplaceQuotesODBC(t *testing.T) {
+	query := `SELECT * FROM account WHERE name = ? AND email = ? AND note = ?`
+	wantedQuery := `SELECT * FROM account WHERE name = $$O'Brien$$ AND email = $$$2$$ AND note = null`
+	args := []driver.Value{
+		driver.Value("O'Brien"),
+		driver.Value("$2"),
+		nil,
+	}
+
+	testReplaceArguments(t, query, args, wantedQuery)
+}
+
+func TestReplaceFloats(t *testing.T) {
+	query := `SELECT * FROM account WHERE a = ? AND b = ? AND c = ? AND d = ? AND e = ?`
+	wantedQuery := `SELECT * FROM account WHERE a = $$0.30000000000000004$$ AND b = $$1e+21$$ AND c = $$1.5e-07$$ AND d = $$0.1$$ AND e = $$-2.5$$`
+	a := 0.1
+	args := []driver.Value{
+		driver.Value(a + 0.2),
+		driver.Value(1e21),
+		driver.Value(1.5e-7),
+		driver.Value(float32(0.1)),
+		driver.Value(-2.5),
+	}
+
+	testReplaceArguments(t, query, args, wantedQuery)
+}
+
 func testReplaceArguments(t *testing.T, query string, args []driver.Value, wantedQuery string) {
 	finalQuery := replaceArguments(query, args)
 	if finalQuery != wantedQuery {
@@ -0,0 +1,58 @@
+package ramsql
+
+import (
+	"database/sql/driver"
+	"sync"
+	"time"
+)
+
+// QueryEvent describes a statement executed by the driver
+type QueryEvent struct {
+	// Query is the statement sent to engine, with arguments bound
+	Query string
+	// Args are the arguments given with query, in order
+	Args []interface{}
+	// Duration is the time taken by engine to execute the statement
+	Duration time.Duration
+	// RowsAffected by a statement not returning rows, or -1 for a query
+	RowsAffected int64
+	// Err is the error returned by engine, if any
+	Err error
+}
+
+var queryLogger struct {
+	sync.RWMutex
+	f func(ev QueryEvent)
+}
+
+// SetLogger sets a function called with each statement executed on any database,
+// once its result is known. A nil function stops logging.
+// It is independent from the level of engine logs.
+func SetLogger(f func(ev QueryEvent)) {
+	queryLogger.Lock()
+	defer queryLogger.Unlock()
+
+	queryLogger.f = f
+}
+
+// logQuery calls logger set with SetLogger, if any, with the event of an executed statement
+func logQuery(query string, args []driver.NamedValue, start time.Time, rowsAffected int64, err error) {
+	queryLogger.RLock()
+	f := queryLogger.f
+	queryLogger.RUnlock()
+
+	if f == nil {
+		return
+	}
+
+	ev := QueryEvent{
+		Query:        query,
+		Duration:     time.Since(start),
+		RowsAffected: rowsAffected,
+		Err:          err,
+	}
+	for _, arg := range args {
+		ev.Args = append(ev.Args, arg.Value)
+	}
+	f(ev)
+}
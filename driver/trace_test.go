@@ -0,0 +1,85 @@
+package ramsql
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestSetLogger(t *testing.T) {
+	log.UseTestLogger(t)
+
+	var mu sync.Mutex
+	var events []QueryEvent
+	SetLogger(func(ev QueryEvent) {
+		if !strings.Contains(ev.Query, "traced") && ev.Query != "BEGIN" && ev.Query != "COMMIT" {
+			return
+		}
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	defer SetLogger(nil)
+
+	db, err := sql.Open("ramsql", "TestSetLogger")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(`CREATE TABLE traced (id INT, name TEXT)`); err != nil {
+		t.Fatalf("cannot create table: %s", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin: %s", err)
+	}
+	if _, err = tx.Exec(`INSERT INTO traced (id, name) VALUES ($1, $2), (2, 'bar')`, 1, "foo"); err != nil {
+		t.Fatalf("cannot insert: %s", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit: %s", err)
+	}
+	rows, err := db.Query(`SELECT name FROM traced WHERE id = ?`, 2)
+	if err != nil {
+		t.Fatalf("cannot select: %s", err)
+	}
+	rows.Close()
+	if _, err = db.Exec(`DELETE FROM traced_missing`); err == nil {
+		t.Fatalf("expected error deleting from unknown table")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []struct {
+		query        string
+		args         int
+		rowsAffected int64
+		failed       bool
+	}{
+		{`CREATE TABLE traced (id INT, name TEXT)`, 0, 1, false},
+		{`BEGIN`, 0, 0, false},
+		{`INSERT INTO traced (id, name) VALUES ($$1$$, $$foo$$), (2, 'bar')`, 2, 2, false},
+		{`COMMIT`, 0, 0, false},
+		{`SELECT name FROM traced WHERE id = $$2$$`, 1, -1, false},
+		{`DELETE FROM traced_missing`, 0, 0, true},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, e := range expected {
+		ev := events[i]
+		if ev.Query != e.query || len(ev.Args) != e.args || ev.RowsAffected != e.rowsAffected || (ev.Err != nil) != e.failed {
+			t.Fatalf("expected event %+v, got %+v", e, ev)
+		}
+		if ev.Duration <= 0 {
+			t.Fatalf("expected duration of %s", ev.Query)
+		}
+	}
+	if events[2].Args[0] != int64(1) || events[2].Args[1] != "foo" {
+		t.Fatalf("unexpected arguments %v", events[2].Args)
+	}
+}
@@ -1,9 +1,5 @@
 package ramsql
 
-import (
-	"fmt"
-)
-
 // Tx implements SQL transaction method
 type Tx struct {
 	conn *Conn
@@ -11,11 +7,10 @@ type Tx struct {
 
 // Commit the transaction on server
 func (t *Tx) Commit() error {
-	// TODO: Not implemented
-	return nil
+	return t.conn.exec("COMMIT")
 }
 
 // Rollback all changes
 func (t *Tx) Rollback() error {
-	return fmt.Errorf("Not implemented")
+	return t.conn.exec("ROLLBACK")
 }
@@ -1,8 +1,12 @@
 package ramsql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/proullon/ramsql/engine/log"
 )
@@ -56,6 +60,652 @@ func TestTransaction(t *testing.T) {
 	// Select count
 }
 
+func TestTransactionRollback(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTransactionRollback")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`,
+		`CREATE INDEX account_email_idx ON account (email)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com'), (2, 'bar@bar.com')`,
+	}
+	for _, q := range init {
+		_, err = db.Exec(q)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	count := func(q queryRower, query string) int {
+		var n int
+		if err := q.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("cannot count with '%s': %s", query, err)
+		}
+		return n
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	writes := []string{
+		`INSERT INTO account (id, email) VALUES (3, 'baz@bar.com')`,
+		`UPDATE account SET email = 'qux@bar.com' WHERE id = 1`,
+		`DELETE FROM account WHERE id = 2`,
+	}
+	for _, q := range writes {
+		if _, err = tx.Exec(q); err != nil {
+			t.Fatalf("cannot exec '%s' in tx: %s", q, err)
+		}
+	}
+
+	// Transaction sees its own writes, other connections do not
+	if n := count(tx, `SELECT COUNT(*) FROM account WHERE email = 'qux@bar.com'`); n != 1 {
+		t.Fatalf("expected updated row in tx, got %d", n)
+	}
+	if n := count(tx, `SELECT COUNT(*) FROM account`); n != 2 {
+		t.Fatalf("expected 2 rows in tx, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE email = 'foo@bar.com'`); n != 1 {
+		t.Fatalf("expected row not updated outside tx, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account`); n != 2 {
+		t.Fatalf("expected 2 rows outside tx, got %d", n)
+	}
+
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("cannot rollback tx: %s", err)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE id = 3`); n != 0 {
+		t.Fatalf("expected inserted row to be rolled back, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE email = 'foo@bar.com'`); n != 1 {
+		t.Fatalf("expected updated row to be rolled back, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE id = 2`); n != 1 {
+		t.Fatalf("expected deleted row to be rolled back, got %d", n)
+	}
+
+	// Committed writes are seen by all connections
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	for _, q := range writes {
+		if _, err = tx.Exec(q); err != nil {
+			t.Fatalf("cannot exec '%s' in tx: %s", q, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE email = 'qux@bar.com'`); n != 1 {
+		t.Fatalf("expected updated row to be committed, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account WHERE email = 'baz@bar.com'`); n != 1 {
+		t.Fatalf("expected inserted row to be committed and indexed, got %d", n)
+	}
+	if n := count(db, `SELECT COUNT(*) FROM account`); n != 2 {
+		t.Fatalf("expected 2 rows after commit, got %d", n)
+	}
+}
+
+func TestTransactionConflict(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTransactionConflict")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+	}
+	for _, q := range init {
+		_, err = db.Exec(q)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	if _, err = tx.Exec(`INSERT INTO account (id, email) VALUES (2, 'bar@bar.com')`); err != nil {
+		t.Fatalf("cannot insert in tx: %s", err)
+	}
+
+	// Table modified by another connection since transaction used it
+	if _, err = db.Exec(`INSERT INTO account (id, email) VALUES (2, 'baz@bar.com')`); err != nil {
+		t.Fatalf("cannot insert outside tx: %s", err)
+	}
+	if err = tx.Commit(); err == nil {
+		t.Fatalf("expected commit to fail on concurrent update")
+	}
+
+	var email string
+	if err = db.QueryRow(`SELECT email FROM account WHERE id = 2`).Scan(&email); err != nil {
+		t.Fatalf("cannot select row: %s", err)
+	}
+	if email != "baz@bar.com" {
+		t.Fatalf("expected row inserted outside tx, got %s", email)
+	}
+}
+
+func TestTransactionSnapshot(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTransactionSnapshot")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`CREATE TABLE champion (user_id INT, name TEXT)`,
+		`INSERT INTO champion (user_id, name) VALUES (1, 'zed')`,
+	}
+	for _, q := range init {
+		_, err = db.Exec(q)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	count := func(q *sql.Tx, table string) int {
+		var n int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatalf("cannot count rows of %s: %s", table, err)
+		}
+		return n
+	}
+	email := func(q *sql.Tx) string {
+		var email string
+		if err := q.QueryRow(`SELECT email FROM account WHERE id = 1`).Scan(&email); err != nil {
+			t.Fatalf("cannot select row: %s", err)
+		}
+		return email
+	}
+
+	// Writes committed since BEGIN are not seen, even in tables not used yet
+	writes := []string{
+		`INSERT INTO account (id, email) VALUES (2, 'bar@bar.com')`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@baz.com') ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email`,
+		`INSERT INTO champion (user_id, name) VALUES (2, 'lulu')`,
+	}
+	for _, q := range writes {
+		if _, err = db.Exec(q); err != nil {
+			t.Fatalf("cannot write outside tx: %s", err)
+		}
+	}
+	if n := count(tx, "account"); n != 1 {
+		t.Fatalf("expected 1 account in tx, got %d", n)
+	}
+	if e := email(tx); e != "foo@bar.com" {
+		t.Fatalf("expected email as of BEGIN, got %s", e)
+	}
+	if n := count(tx, "champion"); n != 1 {
+		t.Fatalf("expected 1 champion in tx, got %d", n)
+	}
+
+	// Reads are repeatable, including after commit of another transaction
+	other, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	if _, err = other.Exec(`DELETE FROM champion WHERE user_id = 1`); err != nil {
+		t.Fatalf("cannot delete in tx: %s", err)
+	}
+	if err = other.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+	if n := count(tx, "champion"); n != 1 {
+		t.Fatalf("expected 1 champion in tx, got %d", n)
+	}
+
+	// Transaction sees its own writes
+	if _, err = tx.Exec(`INSERT INTO account (id, email) VALUES (3, 'baz@bar.com')`); err != nil {
+		t.Fatalf("cannot insert in tx: %s", err)
+	}
+	if n := count(tx, "account"); n != 2 {
+		t.Fatalf("expected 2 accounts in tx, got %d", n)
+	}
+
+	// Writes to tables changed since BEGIN cannot be committed
+	if err = tx.Commit(); err == nil {
+		t.Fatalf("expected commit to fail on concurrent update")
+	}
+
+	// Once transaction is done, every write committed is seen
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	if n := count(tx, "account"); n != 2 {
+		t.Fatalf("expected 2 accounts, got %d", n)
+	}
+	if e := email(tx); e != "foo@baz.com" {
+		t.Fatalf("expected email updated, got %s", e)
+	}
+	if n := count(tx, "champion"); n != 1 {
+		t.Fatalf("expected 1 champion, got %d", n)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+}
+
+func TestTransactionDeadlock(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTransactionDeadlock")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`CREATE TABLE champion (user_id INT, name TEXT)`,
+		`INSERT INTO champion (user_id, name) VALUES (1, 'zed')`,
+	}
+	for _, q := range init {
+		_, err = db.Exec(q)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	begin := func() *sql.Tx {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Cannot create tx: %s", err)
+		}
+		return tx
+	}
+	hasCode := func(err error, code ErrorCode) bool {
+		var e *Error
+		return errors.As(err, &e) && e.Code == code
+	}
+
+	// Each transaction writes a table, then the one written by the other
+	txs := []*sql.Tx{begin(), begin()}
+	first := []string{
+		`UPDATE account SET email = 'foo@baz.com' WHERE id = 1`,
+		`UPDATE champion SET name = 'lulu' WHERE user_id = 1`,
+	}
+	for i, tx := range txs {
+		if _, err = tx.Exec(first[i]); err != nil {
+			t.Fatalf("cannot update in tx: %s", err)
+		}
+	}
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, tx := range txs {
+		wg.Add(1)
+		go func(i int, tx *sql.Tx) {
+			defer wg.Done()
+			_, errs[i] = tx.Exec(first[1-i])
+		}(i, tx)
+	}
+	wg.Wait()
+
+	// One of them is aborted, so that the other goes on
+	victim, winner := 0, 1
+	if errs[0] == nil {
+		victim, winner = 1, 0
+	}
+	var e *Error
+	if !errors.As(errs[victim], &e) || e.Code != DeadlockDetected || e.Code.Name() != "deadlock_detected" {
+		t.Fatalf("expected a deadlock to be detected, got %v and %v", errs[0], errs[1])
+	}
+	if errs[winner] != nil {
+		t.Fatalf("expected other transaction to go on, got %s", errs[winner])
+	}
+	if _, err = txs[victim].Exec(`SELECT email FROM account`); !hasCode(err, InFailedSQLTransaction) {
+		t.Fatalf("expected aborted transaction to fail, got %v", err)
+	}
+	if err = txs[victim].Commit(); !hasCode(err, InFailedSQLTransaction) {
+		t.Fatalf("expected commit of aborted transaction to fail, got %v", err)
+	}
+	if err = txs[winner].Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+
+	// Aborted transaction can be retried
+	tx := begin()
+	for _, q := range first {
+		if _, err = tx.Exec(q); err != nil {
+			t.Fatalf("cannot update in tx: %s", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+
+	// Statement waiting for a table locked by another transaction can be canceled
+	tx = begin()
+	if _, err = tx.Exec(first[0]); err != nil {
+		t.Fatalf("cannot update in tx: %s", err)
+	}
+	other := begin()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = other.ExecContext(ctx, first[0]); err == nil {
+		t.Fatalf("expected statement waiting for lock to be canceled")
+	}
+	other.Rollback()
+	tx.Rollback()
+
+	// Statements outside of transactions do not wait
+	tx = begin()
+	if _, err = tx.Exec(first[0]); err != nil {
+		t.Fatalf("cannot update in tx: %s", err)
+	}
+	if _, err = db.Exec(first[0]); err != nil {
+		t.Fatalf("cannot update outside tx: %s", err)
+	}
+	tx.Rollback()
+}
+
+func TestRollbackOnClose(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestRollbackOnClose")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	ctx := context.Background()
+	count := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil {
+			t.Fatalf("cannot count rows: %s", err)
+		}
+		return n
+	}
+
+	// Connection returned to the pool does not keep its transaction
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	for _, q := range []string{`BEGIN`, `INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`} {
+		if _, err = conn.ExecContext(ctx, q); err != nil {
+			t.Fatalf("cannot execute '%s': %s", q, err)
+		}
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatalf("cannot close connection: %s", err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("expected uncommitted row to be discarded, got %d rows", n)
+	}
+
+	// Connection reused goes on without transaction
+	if _, err = db.Exec(`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`); err != nil {
+		t.Fatalf("cannot insert: %s", err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("expected 1 row, got %d", n)
+	}
+
+	// Closed connection does not keep tables locked by its transaction
+	other, err := sql.Open("ramsql", "TestRollbackOnClose")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	conn, err = other.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	for _, q := range []string{`BEGIN`, `UPDATE account SET email = 'foo@baz.com' WHERE id = 1`} {
+		if _, err = conn.ExecContext(ctx, q); err != nil {
+			t.Fatalf("cannot execute '%s': %s", q, err)
+		}
+	}
+	conn.Close()
+	other.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err = tx.ExecContext(timeout, `UPDATE account SET email = 'bar@bar.com' WHERE id = 1`); err != nil {
+		t.Fatalf("cannot update in tx: %s", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+
+	var email string
+	if err = db.QueryRow(`SELECT email FROM account WHERE id = 1`).Scan(&email); err != nil {
+		t.Fatalf("cannot select row: %s", err)
+	}
+	if email != "bar@bar.com" {
+		t.Fatalf("expected email written by last transaction, got %s", email)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSavepoint")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+	if _, err = db.Exec(`CREATE TABLE audit (id INT)`); err != nil {
+		t.Fatalf("sql.Exec: Error: %s\n", err)
+	}
+
+	// Savepoints are only available in transactions
+	if _, err = db.Exec(`SAVEPOINT sp1`); err == nil {
+		t.Fatalf("expected error setting savepoint outside transaction")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Cannot create tx: %s", err)
+	}
+
+	queries := []string{
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`SAVEPOINT sp1`,
+		`INSERT INTO account (id, email) VALUES (2, 'bar@bar.com')`,
+		`SAVEPOINT sp2`,
+		`UPDATE account SET email = 'baz@bar.com' WHERE id = 1`,
+		`INSERT INTO audit (id) VALUES (1)`,
+		`ROLLBACK TO SAVEPOINT sp2`,
+		`INSERT INTO account (id, email) VALUES (3, 'qux@bar.com')`,
+		`ROLLBACK TO sp1`,
+		`INSERT INTO account (id, email) VALUES (4, 'quux@bar.com')`,
+		`SAVEPOINT sp3`,
+		`INSERT INTO account (id, email) VALUES (5, 'corge@bar.com')`,
+		`RELEASE SAVEPOINT sp3`,
+	}
+	for _, q := range queries {
+		if _, err = tx.Exec(q); err != nil {
+			t.Fatalf("cannot exec '%s' in tx: %s", q, err)
+		}
+	}
+
+	// sp2 was set after sp1, and sp3 was released
+	if _, err = tx.Exec(`ROLLBACK TO sp2`); err == nil {
+		t.Fatalf("expected error rolling back to savepoint discarded")
+	}
+	if _, err = tx.Exec(`RELEASE sp3`); err == nil {
+		t.Fatalf("expected error releasing savepoint twice")
+	}
+
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit tx: %s", err)
+	}
+
+	rows, err := db.Query(`SELECT id, email FROM account ORDER BY id`)
+	if err != nil {
+		t.Fatalf("cannot select accounts: %s", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int
+		var email string
+		if err = rows.Scan(&id, &email); err != nil {
+			t.Fatalf("cannot scan account: %s", err)
+		}
+		got = append(got, email)
+	}
+	if len(got) != 3 || got[0] != "foo@bar.com" || got[1] != "quux@bar.com" || got[2] != "corge@bar.com" {
+		t.Fatalf("expected accounts [foo@bar.com quux@bar.com corge@bar.com], got %v", got)
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM audit`).Scan(&n); err != nil {
+		t.Fatalf("cannot count audit rows: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected audit row to be rolled back, got %d", n)
+	}
+}
+
+// queryRower is either a database or a transaction
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func TestReadOnly(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestReadOnly")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE account (id INT, email TEXT)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`CREATE SEQUENCE counter`,
+	}
+	for _, q := range init {
+		if _, err = db.Exec(q); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	writes := []struct {
+		query     string
+		statement string
+	}{
+		{`INSERT INTO account (id, email) VALUES (2, 'bar@bar.com')`, "INSERT"},
+		{`UPDATE account SET email = 'baz@bar.com' WHERE id = 1`, "UPDATE"},
+		{`DELETE FROM account WHERE id = 1`, "DELETE"},
+		{`TRUNCATE account`, "TRUNCATE"},
+		{`CREATE TABLE other (id INT)`, "CREATE"},
+		{`ALTER TABLE account ADD COLUMN age INT`, "ALTER"},
+		{`DROP TABLE account`, "DROP"},
+		{`SELECT nextval('counter') FROM account`, "nextval()"},
+	}
+	rejected := func(exec func(context.Context, string, ...interface{}) (sql.Result, error)) {
+		for _, w := range writes {
+			_, err := exec(context.Background(), w.query)
+			var e *Error
+			if !errors.As(err, &e) || e.Code != ReadOnlySQLTransaction || e.Code.Name() != "read_only_sql_transaction" || e.Message != "cannot execute "+w.statement+" in a read-only transaction" {
+				t.Fatalf("expected '%s' to be rejected, got %v", w.query, err)
+			}
+		}
+	}
+	unchanged := func() {
+		var email string
+		if err := db.QueryRow(`SELECT email FROM account WHERE id = 1`).Scan(&email); err != nil || email != "foo@bar.com" {
+			t.Fatalf("expected account to be unchanged, got %s (%v)", email, err)
+		}
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("expected 1 account, got %d (%v)", n, err)
+		}
+	}
+
+	// Read only transaction can read, but not write
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	var n int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 account, got %d (%v)", n, err)
+	}
+	rejected(tx.ExecContext)
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("cannot commit: %s", err)
+	}
+	unchanged()
+
+	// Other transactions still write
+	tx, err = db.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	if _, err = tx.Exec(`UPDATE account SET email = 'foo@baz.com' WHERE id = 1`); err != nil {
+		t.Fatalf("cannot update in transaction: %s", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("cannot rollback: %s", err)
+	}
+
+	// Connections opened in ro mode share the database, and never write
+	ro, err := sql.Open("ramsql", "TestReadOnly?mode=ro")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer ro.Close()
+	conn, err := ro.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer conn.Close()
+	if err = conn.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM account`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected 1 account, got %d (%v)", n, err)
+	}
+	rejected(conn.ExecContext)
+	unchanged()
+	tx, err = ro.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	rejected(tx.ExecContext)
+	tx.Rollback()
+	unchanged()
+}
+
 func TestCheckAttributes(t *testing.T) {
 	log.UseTestLogger(t)
 
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+/*
+|-> alter
+	|-> table
+		|-> users
+	|-> add
+		|-> age
+			|-> int
+			|-> default
+				|-> 0
+*/
+// alterExecutor adds, drops or renames a column of a table, or renames the table
+func alterExecutor(e *Engine, alterDecl *parser.Decl, conn protocol.EngineConn) error {
+	if len(alterDecl.Decl) < 2 || len(alterDecl.Decl[0].Decl) < 1 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+
+	name := alterDecl.Decl[0].Decl[0].Lexeme
+	r := e.relation(name)
+	if r == nil {
+		return &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("table %s does not exist", name)}
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	actionDecl := alterDecl.Decl[1]
+	switch actionDecl.Token {
+	case parser.AddToken:
+		if err := addColumnExecutor(e, r, actionDecl.Decl[0]); err != nil {
+			return err
+		}
+	case parser.DropToken:
+		if err := dropColumnExecutor(e, r, actionDecl); err != nil {
+			return err
+		}
+	case parser.RenameToken:
+		if err := renameExecutor(e, r, actionDecl); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported ALTER TABLE action %s", actionDecl.Lexeme)
+	}
+
+	conn.WriteResult(0, 0)
+	return nil
+}
+
+/*
+|-> age
+	|-> int
+	|-> default
+		|-> 0
+*/
+// addColumnExecutor appends a column to relation, with existing rows set to its default value.
+// Relation must be write locked.
+func addColumnExecutor(e *Engine, r *Relation, columnDecl *parser.Decl) error {
+	t := r.table
+
+	attr, err := parseAttribute(columnDecl)
+	if err != nil {
+		return err
+	}
+	if t.attributeIndex(attr.name) != -1 {
+		return &Error{Code: DuplicateColumn, Table: t.name, Column: attr.name, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" already exists", attr.name, t.name)}
+	}
+
+	// Compute new rows before changing anything, so that a failure leaves relation untouched
+	now := time.Now()
+	rows := make([]*Tuple, len(r.rows))
+	for i, row := range r.rows {
+		var v interface{}
+		if attr.autoIncrement {
+			v = attr.sequence.next()
+		} else {
+			v, err = attr.defaultTupleValue(e, now)
+			if err != nil {
+				return err
+			}
+		}
+		if v == nil && attr.notNull {
+			return fmt.Errorf("column \"%s\" of relation \"%s\" contains null values", attr.name, t.name)
+		}
+		rows[i] = NewTuple(append(row.Values[:len(row.Values):len(row.Values)], v)...)
+	}
+
+	unique := t.unique
+	if attr.unique {
+		unique = append(unique[:len(unique):len(unique)], uniqueConstraint{name: t.name + "_" + attr.name + "_key", attributes: []string{attr.name}})
+	}
+
+	altered := &Table{name: t.name, attributes: append(t.attributes[:len(t.attributes):len(t.attributes)], attr), unique: unique}
+	if err := altered.checkUnique(rows); err != nil {
+		return err
+	}
+
+	// Column REFERENCES is a foreign key on it, which values of existing rows must satisfy
+	for _, d := range columnDecl.Decl {
+		if d.Token != parser.ReferencesToken {
+			continue
+		}
+		if err := foreignKeyExecutor(e, altered, []string{attr.name}, d); err != nil {
+			return err
+		}
+		locked := map[*Relation]bool{r: true}
+		err := e.checkForeignKeys(&Relation{table: altered, rows: rows}, rows, rows, locked)
+		for l := range locked {
+			if l != r {
+				l.RUnlock()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	altered.foreignKeys = append(t.foreignKeys[:len(t.foreignKeys):len(t.foreignKeys)], altered.foreignKeys...)
+
+	// Column CHECK must be satisfied by existing rows
+	altered.checks = t.checks[:len(t.checks):len(t.checks)]
+	for _, d := range columnDecl.Decl {
+		if d.Token != parser.CheckToken {
+			continue
+		}
+		if err := checkExecutor(e, altered, attr.name, d); err != nil {
+			return err
+		}
+	}
+	if len(altered.checks) > len(t.checks) {
+		locked := map[*Relation]bool{r: true}
+		checks, err := checksExecutor(e, altered, locked)
+		for _, row := range rows {
+			if err != nil {
+				break
+			}
+			err = checks.check(row)
+		}
+		for l := range locked {
+			if l != r {
+				l.RUnlock()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	r.table.attributes = altered.attributes
+	r.table.unique = altered.unique
+	r.table.foreignKeys = altered.foreignKeys
+	r.table.checks = altered.checks
+	r.rows = rows
+	r.altered++
+	r.rebuildIndexes()
+	return nil
+}
+
+/*
+|-> drop
+	|-> if
+		|-> exists
+	|-> age
+*/
+// dropColumnExecutor removes a column from relation, and its value from all rows.
+// Columns in a key cannot be dropped, indexes on other ones are dropped as well.
+// Relation must be write locked.
+func dropColumnExecutor(e *Engine, r *Relation, dropDecl *parser.Decl) error {
+	t := r.table
+
+	var ifExists bool
+	var name string
+	for _, d := range dropDecl.Decl {
+		if d.Token == parser.IfToken {
+			ifExists = true
+			continue
+		}
+		name = d.Lexeme
+	}
+
+	idx := t.attributeIndex(name)
+	if idx < 0 {
+		if ifExists {
+			return nil
+		}
+		return &Error{Code: UndefinedColumn, Table: t.name, Column: name, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" does not exist", name, t.name)}
+	}
+
+	if t.attributes[idx].primaryKey {
+		return fmt.Errorf("cannot drop column \"%s\" of relation \"%s\" because it is part of the primary key", name, t.name)
+	}
+	for _, c := range t.unique {
+		for _, a := range c.attributes {
+			if a == name {
+				return fmt.Errorf("cannot drop column \"%s\" of relation \"%s\" because unique constraint \"%s\" depends on it", name, t.name, c.name)
+			}
+		}
+	}
+	for _, fk := range t.foreignKeys {
+		for _, a := range fk.attributes {
+			if a == name {
+				return &Error{Code: DependentObjectsStillExist, Table: t.name, Column: name, Constraint: fk.name, Message: fmt.Sprintf("cannot drop column \"%s\" of relation \"%s\" because foreign key constraint \"%s\" depends on it", name, t.name, fk.name)}
+			}
+		}
+	}
+	if c := t.checkReferencing(name); c != "" {
+		return &Error{Code: DependentObjectsStillExist, Table: t.name, Column: name, Constraint: c, Message: fmt.Sprintf("cannot drop column \"%s\" of relation \"%s\" because check constraint \"%s\" depends on it", name, t.name, c)}
+	}
+
+	rows := make([]*Tuple, len(r.rows))
+	for i, row := range r.rows {
+		values := append(row.Values[:idx:idx], row.Values[idx+1:]...)
+		rows[i] = NewTuple(values...)
+	}
+
+	for _, i := range r.indexes[:len(r.indexes):len(r.indexes)] {
+		for _, a := range i.attributes {
+			if a == name {
+				e.Lock()
+				delete(e.indexes, i.name)
+				e.Unlock()
+				r.dropIndex(i.name)
+				break
+			}
+		}
+	}
+
+	t.attributes = append(t.attributes[:idx:idx], t.attributes[idx+1:]...)
+	r.rows = rows
+	r.altered++
+	r.rebuildIndexes()
+	return nil
+}
+
+/*
+|-> rename
+	|-> age
+	|-> to
+		|-> years
+*/
+// renameExecutor renames a column of relation, or relation itself if no column is given.
+// Relation must be write locked.
+func renameExecutor(e *Engine, r *Relation, renameDecl *parser.Decl) error {
+	t := r.table
+
+	var column, name string
+	for _, d := range renameDecl.Decl {
+		if d.Token == parser.ToToken {
+			name = d.Decl[0].Lexeme
+			continue
+		}
+		column = d.Lexeme
+	}
+
+	if column == "" {
+		e.Lock()
+		defer e.Unlock()
+		if _, ok := e.relations[name]; ok {
+			return &Error{Code: DuplicateTable, Table: name, Message: fmt.Sprintf("relation \"%s\" already exists", name)}
+		}
+		delete(e.relations, t.name)
+		for _, other := range e.relations {
+			other.table.renameReferences(t.name, "", name)
+		}
+		t.renameReferences(t.name, "", name)
+		t.name = name
+		e.relations[name] = r
+		return nil
+	}
+
+	idx := t.attributeIndex(column)
+	if idx < 0 {
+		return &Error{Code: UndefinedColumn, Table: t.name, Column: column, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" does not exist", column, t.name)}
+	}
+	if t.attributeIndex(name) >= 0 {
+		return &Error{Code: DuplicateColumn, Table: t.name, Column: name, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" already exists", name, t.name)}
+	}
+	if c := t.checkReferencing(column); c != "" {
+		return &Error{Code: DependentObjectsStillExist, Table: t.name, Column: column, Constraint: c, Message: fmt.Sprintf("cannot rename column \"%s\" of relation \"%s\" because check constraint \"%s\" depends on it", column, t.name, c)}
+	}
+
+	t.attributes[idx].name = name
+	for _, c := range t.unique {
+		for i := range c.attributes {
+			if c.attributes[i] == column {
+				c.attributes[i] = name
+			}
+		}
+	}
+	for _, ix := range r.indexes {
+		for i := range ix.attributes {
+			if ix.attributes[i] == column {
+				ix.attributes[i] = name
+			}
+		}
+	}
+	for _, fk := range t.foreignKeys {
+		for i := range fk.attributes {
+			if fk.attributes[i] == column {
+				fk.attributes[i] = name
+			}
+		}
+	}
+	e.Lock()
+	for _, other := range e.relations {
+		other.table.renameReferences(t.name, column, name)
+	}
+	e.Unlock()
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestAlterTableAddColumn(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestAlterTableAddColumn")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT)`,
+		`INSERT INTO users (name) VALUES ('alice')`,
+		`INSERT INTO users (name) VALUES ('bob')`,
+		`ALTER TABLE users ADD COLUMN age INT DEFAULT 0`,
+		`ALTER TABLE users ADD nickname TEXT`,
+		`INSERT INTO users (name, age, nickname) VALUES ('carol', 42, 'caro')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT * FROM users ORDER BY id`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("rows.Columns: %s", err)
+	}
+	if strings.Join(columns, ",") != "id,name,age,nickname" {
+		t.Fatalf("Expected new columns at the end, got %v", columns)
+	}
+
+	expected := []string{"alice 0 <nil>", "bob 0 <nil>", "carol 42 caro"}
+	var got []string
+	for rows.Next() {
+		var id int64
+		var name, age string
+		var nickname sql.NullString
+		if err := rows.Scan(&id, &name, &age, &nickname); err != nil {
+			t.Fatalf("rows.Scan: %s", err)
+		}
+		n := "<nil>"
+		if nickname.Valid {
+			n = nickname.String
+		}
+		got = append(got, name+" "+age+" "+n)
+	}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	// NOT NULL column cannot be added without default to a non empty table
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN email TEXT NOT NULL`)
+	if err == nil || !strings.Contains(err.Error(), `column "email" of relation "users" contains null values`) {
+		t.Fatalf("Expected null values error, got %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN age INT`)
+	if err == nil {
+		t.Fatalf("Expected error adding an existing column")
+	}
+
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT true`)
+	if err != nil {
+		t.Fatalf("Cannot add NOT NULL column with default: %s", err)
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM users WHERE active = true`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Cannot count active users: %s", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 active users, got %d", count)
+	}
+
+	// Empty table accepts NOT NULL column without default
+	_, err = db.Exec(`CREATE TABLE tags (id BIGSERIAL)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	_, err = db.Exec(`ALTER TABLE tags ADD COLUMN label TEXT NOT NULL`)
+	if err != nil {
+		t.Fatalf("Cannot add NOT NULL column to empty table: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO tags (id) VALUES (1)`)
+	if err == nil {
+		t.Fatalf("Expected NOT NULL violation on added column")
+	}
+}
+
+func TestAlterTableDropColumn(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestAlterTableDropColumn")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL PRIMARY KEY, name TEXT, age INT, email TEXT UNIQUE)`,
+		`CREATE TABLE members (user_id BIGINT, group_id BIGINT, role TEXT, PRIMARY KEY (user_id, group_id))`,
+		`INSERT INTO users (name, age, email) VALUES ('alice', 31, 'alice@example.com')`,
+		`INSERT INTO users (name, age, email) VALUES ('bob', 27, 'bob@example.com')`,
+		`ALTER TABLE users DROP COLUMN age`,
+		`ALTER TABLE users DROP COLUMN IF EXISTS age`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT * FROM users ORDER BY id`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("rows.Columns: %s", err)
+	}
+	if strings.Join(columns, ",") != "id,name,email" {
+		t.Fatalf("Expected age column to be dropped, got %v", columns)
+	}
+
+	var got []string
+	for rows.Next() {
+		var id int64
+		var name, email string
+		if err := rows.Scan(&id, &name, &email); err != nil {
+			t.Fatalf("rows.Scan: %s", err)
+		}
+		got = append(got, name+" "+email)
+	}
+	if strings.Join(got, ",") != "alice alice@example.com,bob bob@example.com" {
+		t.Fatalf("Unexpected rows after dropping column: %v", got)
+	}
+
+	_, err = db.Exec(`SELECT age FROM users`)
+	if err == nil {
+		t.Fatalf("Expected error selecting dropped column")
+	}
+
+	rejected := map[string]string{
+		`ALTER TABLE users DROP COLUMN id`:         "primary key",
+		`ALTER TABLE members DROP COLUMN group_id`: "primary key",
+		`ALTER TABLE users DROP COLUMN email`:      `unique constraint "users_email_key"`,
+		`ALTER TABLE users DROP COLUMN age`:        "does not exist",
+	}
+	for query, reason := range rejected {
+		_, err = db.Exec(query)
+		if err == nil || !strings.Contains(err.Error(), reason) {
+			t.Fatalf("Expected error mentioning %s with '%s', got %v", reason, query, err)
+		}
+	}
+}
+
+func TestAlterTableRename(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestAlterTableRename")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT, age INT, email TEXT UNIQUE)`,
+		`CREATE TABLE groups (id BIGSERIAL, label TEXT)`,
+		`INSERT INTO users (name, age, email) VALUES ('alice', 31, 'alice@example.com')`,
+		`ALTER TABLE users RENAME TO accounts`,
+		`ALTER TABLE accounts RENAME COLUMN age TO years`,
+		`ALTER TABLE accounts RENAME email TO mail`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	var name string
+	var years int
+	err = db.QueryRow(`SELECT name, years FROM accounts WHERE years > 30`).Scan(&name, &years)
+	if err != nil {
+		t.Fatalf("Cannot select renamed column: %s", err)
+	}
+	if name != "alice" || years != 31 {
+		t.Fatalf("Expected alice aged 31, got %s aged %d", name, years)
+	}
+
+	// Constraints follow renamed column
+	_, err = db.Exec(`INSERT INTO accounts (name, mail) VALUES ('bob', 'alice@example.com')`)
+	if err == nil {
+		t.Fatalf("Expected UNIQUE violation on renamed column")
+	}
+
+	// Old names do not resolve anymore
+	for _, query := range []string{`SELECT * FROM users`, `SELECT age FROM accounts`} {
+		rows, err := db.Query(query)
+		if err == nil {
+			rows.Close()
+			t.Fatalf("Expected error with old name in '%s'", query)
+		}
+	}
+
+	rejected := []string{
+		`ALTER TABLE accounts RENAME TO groups`,
+		`ALTER TABLE accounts RENAME COLUMN name TO mail`,
+		`ALTER TABLE accounts RENAME COLUMN age TO old_age`,
+	}
+	for _, query := range rejected {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Arrays are stored as their text, like PostgreSQL writes them: elements between braces,
+// separated by commas, double quoted if needed. {a,b,"c d",NULL}
+
+// arrayFunction returns the array of its arguments, as built by ARRAY['a', 'b']
+func arrayFunction(args []interface{}) (interface{}, error) {
+	return arrayText(args), nil
+}
+
+// arrayLengthFunction returns the number of elements of an array along given dimension.
+// Like PostgreSQL, it is NULL for an empty array, arrays having a single dimension.
+func arrayLengthFunction(args []interface{}) (interface{}, error) {
+	elements, err := arrayElements(args[0])
+	if err != nil {
+		return nil, err
+	}
+	dimension, err := integerValue(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if dimension != 1 || len(elements) == 0 {
+		return nil, nil
+	}
+	return int64(len(elements)), nil
+}
+
+// cardinalityFunction returns the number of elements of an array
+func cardinalityFunction(args []interface{}) (interface{}, error) {
+	elements, err := arrayElements(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return int64(len(elements)), nil
+}
+
+// convertArray returns v as stored in an array attribute, its elements being converted
+// like values of an attribute of their own type
+func (a Attribute) convertArray(v interface{}) (interface{}, error) {
+	elements, err := arrayElements(v)
+	if err != nil {
+		return nil, err
+	}
+
+	element := Attribute{name: a.name, typeName: strings.TrimSuffix(a.typeName, "[]")}
+	for i := range elements {
+		elements[i], err = element.convert(elements[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return arrayText(elements), nil
+}
+
+// arrayText returns the text of an array with given elements, NULL being nil
+func arrayText(elements []interface{}) string {
+	var b strings.Builder
+
+	b.WriteByte('{')
+	for i, e := range elements {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if e == nil {
+			b.WriteString("NULL")
+			continue
+		}
+		s := stringValue(e)
+		if s != "" && !strings.EqualFold(s, "null") && !strings.ContainsAny(s, "{},\"\\") && strings.IndexFunc(s, unicode.IsSpace) < 0 {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteByte('"')
+		for _, c := range s {
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// arrayElements returns elements of an array given as text, NULL being nil
+func arrayElements(v interface{}) ([]interface{}, error) {
+	s := strings.TrimSpace(stringValue(v))
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("malformed array literal: \"%v\"", v)
+	}
+	s = s[1 : len(s)-1]
+
+	elements := []interface{}{}
+	if strings.TrimSpace(s) == "" {
+		return elements, nil
+	}
+
+	for i := 0; ; {
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+
+		var element interface{}
+		switch {
+		case i < len(s) && s[i] == '"':
+			var b strings.Builder
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("malformed array literal: \"%v\"", v)
+			}
+			i++
+			element = b.String()
+		case i < len(s) && s[i] == '{':
+			return nil, fmt.Errorf("multidimensional arrays are not supported: \"%v\"", v)
+		default:
+			start := i
+			for i < len(s) && s[i] != ',' {
+				if s[i] == '"' || s[i] == '{' || s[i] == '}' {
+					return nil, fmt.Errorf("malformed array literal: \"%v\"", v)
+				}
+				i++
+			}
+			text := strings.TrimSpace(s[start:i])
+			if text == "" {
+				return nil, fmt.Errorf("malformed array literal: \"%v\"", v)
+			}
+			element = text
+			if strings.EqualFold(text, "null") {
+				element = nil
+			}
+		}
+		elements = append(elements, element)
+
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+		if i == len(s) {
+			return elements, nil
+		}
+		if s[i] != ',' {
+			return nil, fmt.Errorf("malformed array literal: \"%v\"", v)
+		}
+		i++
+	}
+}
+
+// arrayElementsExpression computes elements of an array, as compared with ANY or ALL
+type arrayElementsExpression struct {
+	array expression
+}
+
+func (a *arrayElementsExpression) eval(row virtualRow) (interface{}, error) {
+	v, err := a.array.eval(row)
+	if v == nil || err != nil {
+		return nil, err
+	}
+
+	return arrayElements(v)
+}
@@ -0,0 +1,100 @@
+package engine_test
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestArray(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestArray")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE posts (id INT, tags TEXT[], scores INT[])`,
+		`INSERT INTO posts (id, tags, scores) VALUES (1, ARRAY['go', 'sql'], '{1, 2,3}')`,
+		`INSERT INTO posts (id, tags, scores) VALUES (2, '{"hello world", "a,b", NULL}', ARRAY[4])`,
+		`INSERT INTO posts (id, tags, scores) VALUES (3, '{}', ARRAY[])`,
+		`INSERT INTO posts (id) VALUES (4)`,
+		`UPDATE posts SET tags = ARRAY['sql', 'say "hi"'] WHERE id = 4`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	if _, err := db.Exec(`INSERT INTO posts (id, tags) VALUES ($1, $2)`, 5, `{go}`); err != nil {
+		t.Fatalf("cannot insert array argument: %s", err)
+	}
+
+	queries := map[string][]string{
+		`SELECT tags, scores FROM posts ORDER BY id`:                                    {"{go,sql} {1,2,3}", `{"hello world","a,b",NULL} {4}`, "{} {}", `{sql,"say \"hi\""} NULL`, "{go} NULL"},
+		`SELECT id FROM posts WHERE 'sql' = ANY (tags) ORDER BY id`:                     {"1", "4"},
+		`SELECT id FROM posts WHERE 'a,b' = ANY (tags)`:                                 {"2"},
+		`SELECT id FROM posts WHERE NOT 'go' = ANY (tags) ORDER BY id`:                  {"3", "4"},
+		`SELECT id FROM posts WHERE 2 < ALL (scores) ORDER BY id`:                       {"2", "3"},
+		`SELECT id FROM posts WHERE 3 = ANY (ARRAY[1, 3]) AND id = 1`:                   {"1"},
+		`SELECT id, array_length(tags, 1), cardinality(tags) FROM posts ORDER BY id`:    {"1 2 2", "2 3 3", "3 NULL 0", "4 2 2", "5 1 1"},
+		`SELECT ARRAY[id, 10], cardinality(scores) FROM posts WHERE id < 3 ORDER BY id`: {"{1,10} 3", "{2,10} 1"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		columns, _ := rows.Columns()
+		var res []string
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			var line []string
+			for _, v := range values {
+				if !v.Valid {
+					line = append(line, "NULL")
+					continue
+				}
+				line = append(line, v.String)
+			}
+			res = append(res, strings.Join(line, " "))
+		}
+		rows.Close()
+
+		if len(res) != len(expected) || (len(res) > 0 && !reflect.DeepEqual(res, expected)) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+	}
+
+	// Arrays are scanned like PostgreSQL ones, as text
+	var raw []byte
+	if err := db.QueryRow(`SELECT tags FROM posts WHERE id = 1`).Scan(&raw); err != nil || string(raw) != "{go,sql}" {
+		t.Fatalf("expected {go,sql}, got %s (%v)", raw, err)
+	}
+
+	failing := []string{
+		`INSERT INTO posts (id, tags) VALUES (6, 'go')`,
+		`INSERT INTO posts (id, tags) VALUES (6, '{go, "sql}')`,
+		`INSERT INTO posts (id, tags) VALUES (6, '{{a}, {b}}')`,
+		`UPDATE posts SET tags = '{a,}' WHERE id = 1`,
+	}
+	for _, query := range failing {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+}
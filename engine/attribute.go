@@ -2,11 +2,13 @@ package engine
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/proullon/ramsql/engine/log"
 	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
 )
 
 // Domain is the set of allowable values for an Attribute.
@@ -21,9 +23,23 @@ type Attribute struct {
 	typeName      string
 	typeInstance  interface{}
 	defaultValue  interface{}
+	defaultText   string
+	defaultDecl   *parser.Decl
 	domain        Domain
 	autoIncrement bool
+	sequence      *sequence
 	unique        bool
+	notNull       bool
+	primaryKey    bool
+	// value of attribute set by ON UPDATE clause, like defaultValue, or nil if there is none
+	onUpdateValue interface{}
+	onUpdateDecl  *parser.Decl
+	// precision and scale of a decimal attribute, unconstrained if precision is 0
+	precision int
+	scale     int
+	// values allowed in an enum attribute, in definition order. Values are
+	// stored as text, so they are compared and sorted in lexical order.
+	enum []string
 }
 
 func parseAttribute(decl *parser.Decl) (Attribute, error) {
@@ -43,6 +59,16 @@ func parseAttribute(decl *parser.Decl) (Attribute, error) {
 		return attr, fmt.Errorf("engine: expected attribute type, got %v:%v", decl.Decl[0].Token, decl.Decl[0].Lexeme)
 	}
 	attr.typeName = decl.Decl[0].Lexeme
+	if isDecimal(attr.typeName) {
+		if err := attr.parsePrecision(decl.Decl[0]); err != nil {
+			return attr, err
+		}
+	}
+	if strings.EqualFold(attr.typeName, "enum") {
+		if err := attr.parseEnum(decl.Decl[0]); err != nil {
+			return attr, err
+		}
+	}
 
 	// Maybe domain and special thing like primary key
 	typeDecl := decl.Decl[1:]
@@ -53,14 +79,15 @@ func parseAttribute(decl *parser.Decl) (Attribute, error) {
 		}
 
 		if typeDecl[i].Token == parser.DefaultToken {
-			log.Debug("we get a default value for %s: %s!\n", attr.name, typeDecl[i].Decl[0].Lexeme)
-			switch typeDecl[i].Decl[0].Token {
-			case parser.LocalTimestampToken, parser.NowToken:
-				log.Debug("Setting default value to NOW() func !\n")
-				attr.defaultValue = func() interface{} { return time.Now().Format(parser.DateLongFormat) }
-			default:
-				log.Debug("Setting default value to '%v'\n", typeDecl[i].Decl[0].Lexeme)
-				attr.defaultValue = typeDecl[i].Decl[0].Lexeme
+			if err := attr.setDefault(typeDecl[i].Decl[0]); err != nil {
+				return attr, err
+			}
+		}
+
+		// ON UPDATE
+		if typeDecl[i].Token == parser.OnToken {
+			if err := attr.setOnUpdate(typeDecl[i].Decl[0].Decl[0]); err != nil {
+				return attr, err
 			}
 		}
 
@@ -69,15 +96,223 @@ func parseAttribute(decl *parser.Decl) (Attribute, error) {
 			attr.unique = true
 		}
 
+		// NOT NULL
+		if typeDecl[i].Token == parser.NotToken {
+			attr.notNull = true
+		}
+
+		if typeDecl[i].Token == parser.PrimaryToken {
+			attr.primaryKey = true
+		}
+
 	}
 
-	if strings.ToLower(attr.typeName) == "bigserial" {
+	// Serial types are integers with a backing sequence, which cannot be NULL
+	switch strings.ToLower(attr.typeName) {
+	case "smallserial", "serial", "bigserial":
 		attr.autoIncrement = true
+		attr.notNull = true
+	}
+
+	if attr.autoIncrement {
+		attr.sequence = &sequence{increment: 1}
 	}
 
 	return attr, nil
 }
 
+/*
+|-> numeric
+	|-> 10
+	|-> 2
+*/
+// parsePrecision sets precision and scale of a decimal attribute, scale being 0 by default
+func (a *Attribute) parsePrecision(typeDecl *parser.Decl) error {
+	var sizes []int
+	for _, d := range typeDecl.Decl {
+		if d.Token != parser.NumberToken {
+			continue
+		}
+		n, err := strconv.Atoi(d.Lexeme)
+		if err != nil {
+			return fmt.Errorf("invalid %s precision: %s", a.typeName, d.Lexeme)
+		}
+		sizes = append(sizes, n)
+	}
+	if len(sizes) == 0 {
+		return nil
+	}
+
+	a.precision = sizes[0]
+	if len(sizes) > 1 {
+		a.scale = sizes[1]
+	}
+	if a.precision < 1 || a.precision > 1000 {
+		return fmt.Errorf("NUMERIC precision %d must be between 1 and 1000", a.precision)
+	}
+	if a.scale < 0 || a.scale > a.precision {
+		return fmt.Errorf("NUMERIC scale %d must be between 0 and precision %d", a.scale, a.precision)
+	}
+
+	return nil
+}
+
+/*
+|-> enum
+	|-> open
+	|-> closed
+*/
+// parseEnum sets values allowed in an enum attribute
+func (a *Attribute) parseEnum(typeDecl *parser.Decl) error {
+	for _, d := range typeDecl.Decl {
+		for _, v := range a.enum {
+			if v == d.Lexeme {
+				return fmt.Errorf("enum value \"%s\" of %s is given more than once", d.Lexeme, a.name)
+			}
+		}
+		a.enum = append(a.enum, d.Lexeme)
+	}
+	if len(a.enum) == 0 {
+		return fmt.Errorf("enum %s has no value", a.name)
+	}
+
+	return nil
+}
+
+// setDefault sets the value of attribute when not given. Functions and expressions
+// are checked now, but computed for each inserted row.
+func (a *Attribute) setDefault(d *parser.Decl) error {
+	log.Debug("we get a default value for %s: %s!\n", a.name, d.Lexeme)
+	a.defaultDecl = d
+	if d.Token != parser.NullToken {
+		a.defaultText = declText(d)
+	}
+
+	v, err := a.valueExecutor(d)
+	if err != nil {
+		return fmt.Errorf("invalid default value for %s: %s", a.name, err)
+	}
+	a.defaultValue = v
+
+	return nil
+}
+
+// setOnUpdate sets the value of attribute when its row is updated without setting it,
+// computed for each updated row like a default value
+func (a *Attribute) setOnUpdate(d *parser.Decl) error {
+	v, err := a.valueExecutor(d)
+	if err != nil {
+		return fmt.Errorf("invalid on update value for %s: %s", a.name, err)
+	}
+	a.onUpdateDecl = d
+	a.onUpdateValue = v
+
+	return nil
+}
+
+// valueExecutor returns the value of attribute given in DEFAULT or ON UPDATE clause: either
+// a constant, or a function computing it with the current time of the statement
+func (a Attribute) valueExecutor(d *parser.Decl) (interface{}, error) {
+	switch {
+	case d.Token == parser.LocalTimestampToken, d.Token == parser.NowToken, d.Token == parser.CurrentTimestampToken:
+		log.Debug("Setting value to NOW() func !\n")
+		return func(e *Engine, now time.Time) (interface{}, error) { return now.Format(parser.DateLongFormat), nil }, nil
+	case d.Token == parser.NullToken:
+		return nil, nil
+	case isExpression(d):
+		if _, err := expressionExecutor(nil, d, nil, nil); err != nil {
+			return nil, err
+		}
+		log.Debug("Setting value to expression %s\n", d.Lexeme)
+		return func(e *Engine, now time.Time) (interface{}, error) {
+			expr, err := expressionExecutor(e, d, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			return expr.eval(virtualRow{})
+		}, nil
+	default:
+		log.Debug("Setting value to '%v'\n", d.Lexeme)
+		if _, err := a.convert(d.Lexeme); err != nil {
+			return nil, err
+		}
+		return d.Lexeme, nil
+	}
+}
+
+// defaultTupleValue returns the value of attribute when not given, computing
+// default functions and expressions with the current time of the statement
+func (a Attribute) defaultTupleValue(e *Engine, now time.Time) (interface{}, error) {
+	return computeValue(a.defaultValue, e, now)
+}
+
+// computeValue returns v, or the value computed by v if it is a function
+func computeValue(v interface{}, e *Engine, now time.Time) (interface{}, error) {
+	if f, ok := v.(func(*Engine, time.Time) (interface{}, error)); ok {
+		return f(e, now)
+	}
+
+	return v, nil
+}
+
+// convert returns v as stored in attribute. Booleans are stored as true or false,
+// whichever way they are written, decimals are rounded to their scale, JSON and
+// enum values must be valid, UUIDs and arrays are written like PostgreSQL does,
+// and other values are kept as they are.
+func (a Attribute) convert(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if strings.HasSuffix(a.typeName, "[]") {
+		return a.convertArray(v)
+	}
+
+	switch typeName := strings.ToLower(a.typeName); typeName {
+	case "bool", "boolean":
+		b, err := castFunction([]interface{}{v, "boolean"})
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%v", b), nil
+	case "decimal", "numeric":
+		return a.convertDecimal(v)
+	case "json", "jsonb", "uuid":
+		return castFunction([]interface{}{v, typeName})
+	case "enum":
+		s := stringValue(v)
+		for _, allowed := range a.enum {
+			if s == allowed {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid input value for enum %s: \"%s\", expected one of '%s'", a.name, s, strings.Join(a.enum, "', '"))
+	}
+
+	return v, nil
+}
+
+// literal returns a literal as it would be stored in attribute, so that they can be compared.
+// It is returned as it is if it cannot be stored.
+func (a Attribute) literal(lexeme string) string {
+	if strings.EqualFold(a.typeName, "uuid") {
+		if s, err := uuidText(lexeme); err == nil {
+			return s
+		}
+		return lexeme
+	}
+
+	return a.decimalLiteral(lexeme)
+}
+
+// columnType returns the type of attribute, as sent with selected rows
+func (a Attribute) columnType() protocol.ColumnType {
+	return protocol.ColumnType{
+		DatabaseTypeName: strings.ToUpper(a.typeName),
+		Nullable:         !a.notNull,
+		HasNullable:      true,
+	}
+}
+
 // NewAttribute initialize a new Attribute struct
 func NewAttribute(name string, typeName string, autoIncrement bool) Attribute {
 	a := Attribute{
@@ -86,5 +321,9 @@ func NewAttribute(name string, typeName string, autoIncrement bool) Attribute {
 		autoIncrement: autoIncrement,
 	}
 
+	if autoIncrement {
+		a.sequence = &sequence{increment: 1}
+	}
+
 	return a
 }
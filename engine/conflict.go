@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// onConflict is the action of INSERT when inserted row conflicts with an existing one
+// on a UNIQUE constraint, called an arbiter. With DO UPDATE, existing row is updated
+// with given values, or expressions of its attributes and of the EXCLUDED row.
+type onConflict struct {
+	arbiters []uniqueConstraint
+	nothing  bool
+	values   map[string]interface{}
+	exprs    map[string]expression
+	excluded *Table
+}
+
+/*
+|-> conflict
+	|-> k
+	|-> update
+		|-> set
+			|-> v
+				|-> =
+				|-> v
+					|-> excluded
+*/
+// conflictExecutor returns the ON CONFLICT action of INSERT into relation, or nil if there
+// is none. Arbiters are UNIQUE constraints on exactly the given attributes, or all UNIQUE
+// constraints of relation if none is given.
+func conflictExecutor(e *Engine, r *Relation, decls []*parser.Decl, now time.Time) (*onConflict, error) {
+	var conflictDecl *parser.Decl
+	for _, d := range decls {
+		if d.Token == parser.ConflictToken {
+			conflictDecl = d
+		}
+	}
+	if conflictDecl == nil {
+		return nil, nil
+	}
+
+	var err error
+	c := &onConflict{}
+	var target []string
+	var setDecl *parser.Decl
+	for _, d := range conflictDecl.Decl {
+		switch d.Token {
+		case parser.NothingToken:
+			c.nothing = true
+		case parser.UpdateToken:
+			setDecl = d.Decl[0]
+		default:
+			if r.table.attributeIndex(d.Lexeme) < 0 {
+				return nil, &Error{Code: UndefinedColumn, Column: d.Lexeme, Message: fmt.Sprintf("column \"%s\" does not exist", d.Lexeme)}
+			}
+			target = append(target, d.Lexeme)
+		}
+	}
+
+	switch {
+	case len(target) > 0:
+		for _, u := range r.table.unique {
+			if sameAttributes(u.attributes, target) {
+				c.arbiters = append(c.arbiters, u)
+			}
+		}
+		if len(c.arbiters) == 0 {
+			return nil, fmt.Errorf("there is no unique constraint matching the ON CONFLICT specification")
+		}
+	case c.nothing:
+		c.arbiters = r.table.unique
+	default:
+		return nil, fmt.Errorf("ON CONFLICT DO UPDATE requires inference specification")
+	}
+
+	if c.nothing {
+		return c, nil
+	}
+
+	// Literal values are set as with UPDATE, and other ones are computed for each conflict.
+	// Unqualified attributes are the ones of existing row.
+	c.values, err = setExecutor(setDecl, now)
+	if err != nil {
+		return nil, err
+	}
+	c.excluded = &Table{name: "excluded", attributes: r.table.attributes, correlated: true}
+	c.exprs, err = setExpressionsExecutor(e, r.table, setDecl, []*Table{r.table, c.excluded}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// sameAttributes returns true if both lists have the same attributes, in any order
+func sameAttributes(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// row returns the position of the row of relation conflicting with t on an arbiter, or -1
+func (c *onConflict) row(r *Relation, t *Tuple) int {
+	for _, u := range c.arbiters {
+		row := r.conflicting(u, t)
+		if row == nil {
+			continue
+		}
+		for i := range r.rows {
+			if r.rows[i] == row {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// update applies DO UPDATE action to conflicting row i of relation, t being the EXCLUDED row,
+// and returns updated row. now is the current time of the statement, updating the UPDATE
+// triggers of relation fired for the row, and checks its CHECK constraints.
+func (c *onConflict) update(e *Engine, r *Relation, i int, t *Tuple, now time.Time, updating *rowTriggers, checks *rowChecks) (*Tuple, error) {
+	vrow := make(virtualRow)
+	for j, a := range r.table.attributes {
+		vrow[r.table.name+"."+a.name] = Value{v: r.rows[i].Values[j], valid: true, lexeme: a.name, table: r.table.name}
+		vrow[c.excluded.name+"."+a.name] = Value{v: t.Values[j], valid: true, lexeme: a.name, table: c.excluded.name}
+	}
+
+	values, err := setValues(c.values, c.exprs, vrow)
+	if err != nil {
+		return nil, err
+	}
+	if values, err = onUpdateValues(e, r.table, values, now); err != nil {
+		return nil, err
+	}
+
+	row := updateValues(r, i, values)
+	if err := r.table.convertValues(row); err != nil {
+		return nil, err
+	}
+	if row, err = updating.fireBefore(r.rows[i], row); err != nil {
+		return nil, err
+	}
+	if err := r.table.checkNotNull(row); err != nil {
+		return nil, err
+	}
+	if err := checks.check(row); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkUniqueChanges([]*Tuple{r.rows[i]}, []*Tuple{row}); err != nil {
+		return nil, err
+	}
+
+	old := r.rows[i]
+	r.removeFromIndexes(old)
+	r.addToIndexes(row)
+	r.rows[i] = row
+
+	if err := updating.fireAfter(old, row); err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
@@ -0,0 +1,123 @@
+package engine_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestOnConflict(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestOnConflict")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE kv (k TEXT PRIMARY KEY, v TEXT, hits INT DEFAULT 1)`,
+		`CREATE TABLE pairs (a INT, b INT, label TEXT, UNIQUE (a, b))`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'first')`,
+		`INSERT INTO pairs (a, b, label) VALUES (1, 2, 'one-two')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	// Primary key is unique
+	_, err = db.Exec(`INSERT INTO kv (k, v) VALUES ('a', 'dup')`)
+	if err == nil {
+		t.Fatalf("Expected primary key violation")
+	}
+
+	value := func(k string) (string, int) {
+		var v string
+		var hits int
+		err := db.QueryRow(`SELECT v, hits FROM kv WHERE k = $1`, k).Scan(&v, &hits)
+		if err != nil {
+			t.Fatalf("Cannot select key %s: %s", k, err)
+		}
+		return v, hits
+	}
+
+	// DO UPDATE with EXCLUDED values
+	res, err := db.Exec(`INSERT INTO kv (k, v) VALUES ($1, $2) ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v, hits = kv.hits + 1`, "a", "second")
+	if err != nil {
+		t.Fatalf("Cannot upsert: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Expected 1 row affected, got %d", n)
+	}
+	if v, hits := value("a"); v != "second" || hits != 2 {
+		t.Fatalf("Expected updated row (second, 2), got (%s, %d)", v, hits)
+	}
+
+	// No conflict inserts row
+	_, err = db.Exec(`INSERT INTO kv (k, v) VALUES ('b', 'new') ON CONFLICT (k) DO UPDATE SET v = 'updated'`)
+	if err != nil {
+		t.Fatalf("Cannot upsert: %s", err)
+	}
+	if v, hits := value("b"); v != "new" || hits != 1 {
+		t.Fatalf("Expected inserted row (new, 1), got (%s, %d)", v, hits)
+	}
+	_, err = db.Exec(`INSERT INTO kv (k, v) VALUES ('b', 'new') ON CONFLICT (k) DO UPDATE SET v = 'updated'`)
+	if err != nil {
+		t.Fatalf("Cannot upsert: %s", err)
+	}
+	if v, _ := value("b"); v != "updated" {
+		t.Fatalf("Expected updated row, got %s", v)
+	}
+
+	// DO NOTHING, with or without conflict target
+	for _, query := range []string{
+		`INSERT INTO kv (k, v) VALUES ('a', 'ignored') ON CONFLICT DO NOTHING`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'ignored') ON CONFLICT (k) DO NOTHING`,
+		`INSERT INTO pairs (b, a, label) VALUES (2, 1, 'ignored') ON CONFLICT (b, a) DO NOTHING`,
+	} {
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("Cannot insert '%s': %s", query, err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Fatalf("Expected no row affected with '%s', got %d", query, n)
+		}
+	}
+	if v, _ := value("a"); v != "second" {
+		t.Fatalf("Expected row left as is, got %s", v)
+	}
+
+	// RETURNING written row
+	var v string
+	var hits int
+	err = db.QueryRow(`INSERT INTO kv (k, v) VALUES ('a', 'third') ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v, hits = kv.hits * 10 RETURNING v, hits`).Scan(&v, &hits)
+	if err != nil {
+		t.Fatalf("Cannot upsert with RETURNING: %s", err)
+	}
+	if v != "third" || hits != 20 {
+		t.Fatalf("Expected returned row (third, 20), got (%s, %d)", v, hits)
+	}
+	err = db.QueryRow(`INSERT INTO kv (k, v) VALUES ('a', 'ignored') ON CONFLICT DO NOTHING RETURNING k`).Scan(&v)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected no row returned with DO NOTHING, got %v", err)
+	}
+
+	// Conflict on another constraint than the target one is a violation
+	rejected := []string{
+		`INSERT INTO pairs (a, b, label) VALUES (1, 2, 'dup') ON CONFLICT (a) DO NOTHING`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'x') ON CONFLICT (v) DO NOTHING`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'x') ON CONFLICT DO UPDATE SET v = 'y'`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'x') ON CONFLICT (k) DO UPDATE SET unknown = 'y'`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'x') ON CONFLICT (k) DO UPDATE SET k = 'b'`,
+	}
+	for _, query := range rejected {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+}
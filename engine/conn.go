@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/log"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// bufferedConn holds selected rows until the end of the query,
+// so an error occuring while selecting is reported instead of a partial result.
+type bufferedConn struct {
+	realConn protocol.EngineConn
+	header   []string
+	types    []protocol.ColumnType
+	rows     [][]*string
+}
+
+func bufferConn(conn protocol.EngineConn) *bufferedConn {
+	return &bufferedConn{realConn: conn}
+}
+
+// Not needed
+func (c *bufferedConn) ReadStatement() (string, error) {
+	log.Debug("bufferedConn.ReadStatement: should not be used\n")
+	return "", nil
+}
+
+// Not needed
+func (c *bufferedConn) WriteResult(last int64, ra int64) error {
+	log.Debug("bufferedConn.WriteResult: should not be used\n")
+	return nil
+}
+
+func (c *bufferedConn) WriteError(err error) error {
+	return c.realConn.WriteError(err)
+}
+
+func (c *bufferedConn) WriteRowHeader(header []string) error {
+	c.header = header
+	return nil
+}
+
+func (c *bufferedConn) WriteTypedRowHeader(header []string, types []protocol.ColumnType) error {
+	c.header = header
+	c.types = types
+	return nil
+}
+
+func (c *bufferedConn) WriteRow(row []*string) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+// WriteRowEnd does nothing, rows being sent with flush
+func (c *bufferedConn) WriteRowEnd() error {
+	return nil
+}
+
+// flush sends buffered rows to real connection
+func (c *bufferedConn) flush() error {
+	if err := writeRowHeader(c.realConn, c.header, c.types); err != nil {
+		return err
+	}
+
+	for _, row := range c.rows {
+		if err := c.realConn.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return c.realConn.WriteRowEnd()
+}
+
+// typedConn sends the type of selected columns with rows header
+type typedConn struct {
+	protocol.EngineConn
+	types []protocol.ColumnType
+}
+
+func (c *typedConn) WriteRowHeader(header []string) error {
+	return writeRowHeader(c.EngineConn, header, c.types)
+}
+
+// rowValue returns v as sent in rows, NULL being nil
+func rowValue(v interface{}) *string {
+	if v == nil {
+		return nil
+	}
+
+	s := fmt.Sprintf("%v", v)
+	return &s
+}
+
+// writeRowHeader writes rows header with the type of columns,
+// if conn is able to send them
+func writeRowHeader(conn protocol.EngineConn, header []string, types []protocol.ColumnType) error {
+	if t, ok := conn.(protocol.TypedEngineConn); ok && types != nil {
+		return t.WriteTypedRowHeader(header, types)
+	}
+
+	return conn.WriteRowHeader(header)
+}
+
+// columnTypes returns the type of attributes in header,
+// unknown for computed values
+func columnTypes(header []string, tables []*Table) []protocol.ColumnType {
+	types := make([]protocol.ColumnType, len(header))
+	for i, key := range header {
+		for _, t := range tables {
+			if !strings.HasPrefix(key, t.name+".") {
+				continue
+			}
+			if j := t.attributeIndex(strings.TrimPrefix(key, t.name+".")); j >= 0 {
+				types[i] = t.attributes[j].columnType()
+				break
+			}
+		}
+	}
+
+	return types
+}
@@ -0,0 +1,759 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// checkNotNull returns an error if row has a NULL value for a NOT NULL attribute
+func (t *Table) checkNotNull(row *Tuple) error {
+	for i, a := range t.attributes {
+		if a.notNull && row.Values[i] == nil {
+			return &Error{Code: NotNullViolation, Table: t.name, Column: a.name, Message: fmt.Sprintf("NOT NULL constraint violation: null value in column \"%s\" of relation \"%s\" violates not-null constraint", a.name, t.name)}
+		}
+	}
+
+	return nil
+}
+
+// uniqueConstraint is a set of attributes whose values cannot be the same in two rows
+type uniqueConstraint struct {
+	name       string
+	attributes []string
+}
+
+/*
+|-> unique
+	|-> first_name
+	|-> last_name
+*/
+// uniqueExecutor adds a UNIQUE constraint on given attributes to table.
+// Like PostgreSQL, constraint is named after table and attributes.
+func uniqueExecutor(t *Table, uniqueDecl *parser.Decl) error {
+	c := uniqueConstraint{}
+
+	for _, d := range uniqueDecl.Decl {
+		if t.attributeIndex(d.Lexeme) < 0 {
+			return &Error{Code: UndefinedColumn, Table: t.name, Column: d.Lexeme, Message: fmt.Sprintf("column \"%s\" named in key does not exist", d.Lexeme)}
+		}
+		c.attributes = append(c.attributes, d.Lexeme)
+	}
+	if len(c.attributes) == 0 {
+		return fmt.Errorf("UNIQUE constraint without column")
+	}
+	c.name = t.name + "_" + strings.Join(c.attributes, "_") + "_key"
+
+	t.unique = append(t.unique, c)
+	return nil
+}
+
+/*
+|-> primary
+	|-> key
+	|-> id
+*/
+// primaryKeyExecutor marks given attributes as part of table primary key
+func primaryKeyExecutor(t *Table, primaryDecl *parser.Decl) error {
+	for _, d := range primaryDecl.Decl {
+		if d.Token == parser.KeyToken {
+			continue
+		}
+		i := t.attributeIndex(d.Lexeme)
+		if i < 0 {
+			return &Error{Code: UndefinedColumn, Table: t.name, Column: d.Lexeme, Message: fmt.Sprintf("column \"%s\" named in key does not exist", d.Lexeme)}
+		}
+		t.attributes[i].primaryKey = true
+	}
+
+	return nil
+}
+
+// addPrimaryKey adds the UNIQUE constraint of table primary key, if any.
+// Like PostgreSQL, constraint is named after table.
+func (t *Table) addPrimaryKey() {
+	var attributes []string
+	for _, a := range t.attributes {
+		if a.primaryKey {
+			attributes = append(attributes, a.name)
+		}
+	}
+	if len(attributes) == 0 {
+		return
+	}
+
+	t.unique = append(t.unique, uniqueConstraint{name: t.name + "_pkey", attributes: attributes})
+}
+
+// checkUnique returns an error if two of given rows have the same values
+// for attributes of a UNIQUE constraint. Rows with a NULL value never conflict.
+func (t *Table) checkUnique(rows []*Tuple) error {
+	for _, c := range t.unique {
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			key, ok := t.uniqueKey(c, row)
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				return &Error{Code: UniqueViolation, Table: t.name, Constraint: c.name, Message: fmt.Sprintf("UNIQUE constraint violation: duplicate key value violates unique constraint \"%s\"", c.name)}
+			}
+			seen[key] = true
+		}
+	}
+
+	return nil
+}
+
+// uniqueSet holds rows of a relation by their key for attributes of a UNIQUE constraint,
+// so that rows written are checked against it rather than against every row
+type uniqueSet struct {
+	constraint uniqueConstraint
+	rows       map[string]*Tuple
+}
+
+// uniqueSets returns the sets of UNIQUE constraints of relation, built again from its rows
+// if constraints changed since they were
+func (r *Relation) uniqueSets() []*uniqueSet {
+	if !r.uniqueBuilt() {
+		r.buildUniqueSets()
+	}
+
+	return r.unique
+}
+
+// uniqueBuilt returns true if sets of relation are the ones of its UNIQUE constraints
+func (r *Relation) uniqueBuilt() bool {
+	if len(r.unique) != len(r.table.unique) {
+		return false
+	}
+
+	for i, s := range r.unique {
+		c := r.table.unique[i]
+		if s.constraint.name != c.name || len(s.constraint.attributes) != len(c.attributes) {
+			return false
+		}
+		for j := range c.attributes {
+			if s.constraint.attributes[j] != c.attributes[j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// buildUniqueSets adds all rows of relation to sets of its UNIQUE constraints, dropping previous ones
+func (r *Relation) buildUniqueSets() {
+	r.unique = make([]*uniqueSet, 0, len(r.table.unique))
+	for _, c := range r.table.unique {
+		s := &uniqueSet{constraint: c, rows: make(map[string]*Tuple, len(r.rows))}
+		for _, row := range r.rows {
+			if k, ok := r.table.uniqueKey(c, row); ok {
+				s.rows[k] = row
+			}
+		}
+		r.unique = append(r.unique, s)
+	}
+}
+
+// addToUniqueSets adds a written row to sets of relation. Sets built again later are left as is.
+func (r *Relation) addToUniqueSets(row *Tuple) {
+	if !r.uniqueBuilt() {
+		return
+	}
+
+	for _, s := range r.unique {
+		if k, ok := r.table.uniqueKey(s.constraint, row); ok {
+			s.rows[k] = row
+		}
+	}
+}
+
+// removeFromUniqueSets removes a deleted or replaced row from sets of relation, unless another
+// row replaced it already
+func (r *Relation) removeFromUniqueSets(row *Tuple) {
+	if !r.uniqueBuilt() {
+		return
+	}
+
+	for _, s := range r.unique {
+		if k, ok := r.table.uniqueKey(s.constraint, row); ok && s.rows[k] == row {
+			delete(s.rows, k)
+		}
+	}
+}
+
+// checkUniqueChanges returns an error if, once removed rows are replaced with added ones,
+// two rows of relation have the same values for attributes of a UNIQUE constraint.
+// Only added rows are checked, other rows being unique already.
+func (r *Relation) checkUniqueChanges(removed []*Tuple, added []*Tuple) error {
+	for _, s := range r.uniqueSets() {
+		counts := make(map[string]int)
+		for _, row := range removed {
+			if k, ok := r.table.uniqueKey(s.constraint, row); ok {
+				counts[k]--
+			}
+		}
+		for _, row := range added {
+			k, ok := r.table.uniqueKey(s.constraint, row)
+			if !ok {
+				continue
+			}
+			counts[k]++
+			n := counts[k]
+			if _, ok := s.rows[k]; ok {
+				n++
+			}
+			if n > 1 {
+				return &Error{Code: UniqueViolation, Table: r.table.name, Constraint: s.constraint.name, Message: fmt.Sprintf("UNIQUE constraint violation: duplicate key value violates unique constraint \"%s\"", s.constraint.name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflicting returns the row of relation with the same values as row for attributes of
+// UNIQUE constraint c, if any
+func (r *Relation) conflicting(c uniqueConstraint, row *Tuple) *Tuple {
+	k, ok := r.table.uniqueKey(c, row)
+	if !ok {
+		return nil
+	}
+
+	for _, s := range r.uniqueSets() {
+		if s.constraint.name == c.name {
+			return s.rows[k]
+		}
+	}
+
+	return nil
+}
+
+// uniqueKey returns the key of row values for attributes of UNIQUE constraint,
+// or false if one of them is NULL, since NULL never conflicts
+func (t *Table) uniqueKey(c uniqueConstraint, row *Tuple) (string, bool) {
+	return t.key(c.attributes, row)
+}
+
+// key returns the key of row values for given attributes, or false if one of them is NULL
+func (t *Table) key(attributes []string, row *Tuple) (string, bool) {
+	var values []interface{}
+
+	for _, a := range attributes {
+		v := row.Values[t.attributeIndex(a)]
+		if v == nil {
+			return "", false
+		}
+		values = append(values, v)
+	}
+
+	return valuesKey(values), true
+}
+
+// checkConstraint is a CHECK constraint: its condition cannot be false for a row, but may be
+// unknown. Table is in scope with the name it had when constraint was created, which its
+// condition may qualify attributes with.
+type checkConstraint struct {
+	name      string
+	table     string
+	condition *parser.Decl
+}
+
+/*
+|-> check
+	|-> (
+		|-> age
+			|-> >=
+			|-> 0
+	|-> constraint
+		|-> positive_age
+*/
+// checkExecutor adds a CHECK constraint to table t, once its condition is checked. Unless it is
+// named, it is named after table and column like in PostgreSQL, column being empty for a table
+// constraint.
+func checkExecutor(e *Engine, t *Table, column string, checkDecl *parser.Decl) error {
+	if len(checkDecl.Decl) < 1 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+	c := checkConstraint{table: t.name, condition: checkDecl.Decl[0]}
+
+	if len(checkDecl.Decl) > 1 && len(checkDecl.Decl[1].Decl) == 1 {
+		c.name = checkDecl.Decl[1].Decl[0].Lexeme
+		if t.hasCheck(c.name) {
+			return &Error{Code: DuplicateObject, Table: t.name, Constraint: c.name, Message: fmt.Sprintf("constraint \"%s\" for relation \"%s\" already exists", c.name, t.name)}
+		}
+	} else {
+		base := t.name + "_check"
+		if column != "" {
+			base = t.name + "_" + column + "_check"
+		}
+		c.name = base
+		for n := 1; t.hasCheck(c.name); n++ {
+			c.name = fmt.Sprintf("%s%d", base, n)
+		}
+	}
+
+	locked := make(map[*Relation]bool)
+	_, _, err := c.compile(e, t, locked)
+	for l := range locked {
+		l.RUnlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	t.checks = append(t.checks, c)
+	return nil
+}
+
+// hasCheck returns true if table has a CHECK constraint with given name
+func (t *Table) hasCheck(name string) bool {
+	for _, c := range t.checks {
+		if c.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkReferencing returns the name of a CHECK constraint of table whose condition
+// may reference given column, or an empty string if there is none
+func (t *Table) checkReferencing(column string) string {
+	var references func(d *parser.Decl) bool
+	references = func(d *parser.Decl) bool {
+		if d.Token == parser.StringToken && d.Lexeme == column {
+			return true
+		}
+		for _, child := range d.Decl {
+			if references(child) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, c := range t.checks {
+		if references(c.condition) {
+			return c.name
+		}
+	}
+
+	return ""
+}
+
+// compile returns the condition of CHECK constraint of table t, ready to be evaluated
+// on its rows with the relation returned, which is its scope
+func (c checkConstraint) compile(e *Engine, t *Table, locked map[*Relation]bool) (PredicateLinker, *Relation, error) {
+	scope := &Relation{table: &Table{name: c.table, attributes: t.attributes}}
+
+	predicate, err := whereExecutor2(e, c.condition.Decl, []*Table{scope.table}, locked)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return predicate, scope, nil
+}
+
+// rowChecks are the CHECK constraints of a table, ready to be evaluated on its rows
+type rowChecks struct {
+	table      *Table
+	scopes     []*Relation
+	predicates []PredicateLinker
+}
+
+// checksExecutor returns CHECK constraints of table t ready to be evaluated. A nil
+// rowChecks, returned if there is none, rejects no row.
+func checksExecutor(e *Engine, t *Table, locked map[*Relation]bool) (*rowChecks, error) {
+	if len(t.checks) == 0 {
+		return nil, nil
+	}
+
+	rc := &rowChecks{table: t}
+	for _, c := range t.checks {
+		predicate, scope, err := c.compile(e, t, locked)
+		if err != nil {
+			return nil, err
+		}
+		rc.predicates = append(rc.predicates, predicate)
+		rc.scopes = append(rc.scopes, scope)
+	}
+
+	return rc, nil
+}
+
+// check returns an error if the condition of a CHECK constraint is false for row.
+// Like in SQL, an unknown condition, because of NULL values, does not reject it.
+func (rc *rowChecks) check(row *Tuple) error {
+	if rc == nil {
+		return nil
+	}
+
+	for i, predicate := range rc.predicates {
+		ok, known, err := evalTernary(predicate, virtualRow{}.with(rc.scopes[i], row))
+		if err != nil {
+			return err
+		}
+		if known && !ok {
+			return &Error{Code: CheckViolation, Table: rc.table.name, Constraint: rc.table.checks[i].name, Message: fmt.Sprintf("CHECK constraint violation: new row for relation \"%s\" violates check constraint \"%s\"", rc.table.name, rc.table.checks[i].name)}
+		}
+	}
+
+	return nil
+}
+
+// foreignKey is a FOREIGN KEY constraint: values of attributes of a row must be the ones of
+// referenced attributes in a row of referenced table, unless one of them is NULL
+type foreignKey struct {
+	name       string
+	attributes []string
+	table      string
+	referenced []string
+	// onDelete is the action on rows referencing a deleted one, either "cascade" or
+	// "set null", or empty if their deletion is restricted
+	onDelete string
+}
+
+/*
+|-> references
+	|-> users
+		|-> id
+	|-> on
+		|-> delete
+			|-> cascade
+*/
+// foreignKeyExecutor adds a FOREIGN KEY constraint on given attributes to table t, which may
+// reference itself. Referenced attributes, the primary key of referenced table by default,
+// must be the ones of one of its UNIQUE constraints. Like PostgreSQL, constraint is named
+// after table and attributes.
+func foreignKeyExecutor(e *Engine, t *Table, attributes []string, referencesDecl *parser.Decl) error {
+	if len(referencesDecl.Decl) < 1 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+	tableDecl := referencesDecl.Decl[0]
+
+	fk := foreignKey{table: tableDecl.Lexeme}
+	for _, d := range referencesDecl.Decl[1:] {
+		if d.Token != parser.OnToken || len(d.Decl) != 1 || len(d.Decl[0].Decl) != 1 {
+			return fmt.Errorf("parsing failed, malformed query")
+		}
+		switch d.Decl[0].Decl[0].Token {
+		case parser.CascadeToken:
+			fk.onDelete = "cascade"
+		case parser.SetToken:
+			fk.onDelete = "set null"
+		}
+	}
+	for _, a := range attributes {
+		if t.attributeIndex(a) < 0 {
+			return &Error{Code: UndefinedColumn, Table: fk.table, Column: a, Message: fmt.Sprintf("column \"%s\" referenced in foreign key constraint does not exist", a)}
+		}
+		fk.attributes = append(fk.attributes, a)
+	}
+	fk.name = t.name + "_" + strings.Join(fk.attributes, "_") + "_fkey"
+
+	// Referenced table schema is only changed while write locked
+	referenced := t
+	if fk.table != t.name {
+		r := e.relation(fk.table)
+		if r == nil {
+			return &Error{Code: UndefinedTable, Table: fk.table, Message: fmt.Sprintf("relation \"%s\" does not exist", fk.table)}
+		}
+		r.RLock()
+		defer r.RUnlock()
+		referenced = r.table
+	}
+
+	for _, d := range tableDecl.Decl {
+		if referenced.attributeIndex(d.Lexeme) < 0 {
+			return &Error{Code: UndefinedColumn, Table: fk.table, Column: d.Lexeme, Message: fmt.Sprintf("column \"%s\" referenced in foreign key constraint does not exist", d.Lexeme)}
+		}
+		fk.referenced = append(fk.referenced, d.Lexeme)
+	}
+	if len(fk.referenced) == 0 {
+		for _, a := range referenced.attributes {
+			if a.primaryKey {
+				fk.referenced = append(fk.referenced, a.name)
+			}
+		}
+		if len(fk.referenced) == 0 {
+			return fmt.Errorf("there is no primary key for referenced table \"%s\"", fk.table)
+		}
+	}
+	if len(fk.referenced) != len(fk.attributes) {
+		return fmt.Errorf("number of referencing and referenced columns for foreign key disagree")
+	}
+	if !referenced.isKey(fk.referenced) {
+		return fmt.Errorf("there is no unique constraint matching given keys for referenced table \"%s\"", fk.table)
+	}
+
+	t.foreignKeys = append(t.foreignKeys, fk)
+	return nil
+}
+
+// isKey returns true if given attributes are the ones of a UNIQUE constraint, in any order
+func (t *Table) isKey(attributes []string) bool {
+	for _, c := range t.unique {
+		if len(c.attributes) != len(attributes) {
+			continue
+		}
+		found := 0
+		for _, a := range attributes {
+			for _, ca := range c.attributes {
+				if a == ca {
+					found++
+					break
+				}
+			}
+		}
+		if found == len(attributes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkForeignKeys returns an error if one of given rows, written in locked relation r,
+// references a key which does not exist. Rows of r after the statement are given, in
+// case it references itself. Referenced relations are read locked, and added to locked.
+func (e *Engine) checkForeignKeys(r *Relation, rows []*Tuple, current []*Tuple, locked map[*Relation]bool) error {
+	if !e.foreignKeys {
+		return nil
+	}
+
+	for _, fk := range r.table.foreignKeys {
+		parent, parentRows := r, current
+		if fk.table != r.table.name {
+			parent = e.relation(fk.table)
+			if parent == nil {
+				return &Error{Code: UndefinedTable, Table: fk.table, Message: fmt.Sprintf("relation \"%s\" does not exist", fk.table)}
+			}
+			if !locked[parent] {
+				parent.RLock()
+				locked[parent] = true
+			}
+			parentRows = parent.rows
+		}
+
+		keys := make(map[string]bool)
+		for _, row := range parentRows {
+			if k, ok := parent.table.key(fk.referenced, row); ok {
+				keys[k] = true
+			}
+		}
+		for _, row := range rows {
+			k, ok := r.table.key(fk.attributes, row)
+			if !ok || keys[k] {
+				continue
+			}
+			return &Error{Code: ForeignKeyViolation, Table: r.table.name, Constraint: fk.name, Message: fmt.Sprintf("FOREIGN KEY constraint violation: insert or update on table \"%s\" violates foreign key constraint \"%s\": key (%s)=(%s) is not present in table \"%s\"",
+				r.table.name, fk.name, strings.Join(fk.attributes, ", "), keyValues(r.table, fk.attributes, row), fk.table)}
+		}
+	}
+
+	return nil
+}
+
+// checkReferences returns an error if a row of a table referencing locked relation r would
+// reference a key of it which does not exist anymore, rows being the ones of r after the
+// statement. Referencing relations are read locked, and added to locked.
+func (e *Engine) checkReferences(r *Relation, rows []*Tuple, locked map[*Relation]bool) error {
+	if !e.foreignKeys {
+		return nil
+	}
+
+	for _, name := range e.referencing(r.table.name) {
+		child, childRows := r, rows
+		if name != r.table.name {
+			if child = e.relation(name); child == nil {
+				continue
+			}
+			if !locked[child] {
+				child.RLock()
+				locked[child] = true
+			}
+			childRows = child.rows
+		}
+
+		for _, fk := range child.table.foreignKeys {
+			if fk.table != r.table.name {
+				continue
+			}
+			keys := make(map[string]bool)
+			for _, row := range rows {
+				if k, ok := r.table.key(fk.referenced, row); ok {
+					keys[k] = true
+				}
+			}
+			for _, row := range childRows {
+				k, ok := child.table.key(fk.attributes, row)
+				if !ok || keys[k] {
+					continue
+				}
+				return &Error{Code: ForeignKeyViolation, Table: child.table.name, Constraint: fk.name, Message: fmt.Sprintf("FOREIGN KEY constraint violation: update or delete on table \"%s\" violates foreign key constraint \"%s\" on table \"%s\": key (%s)=(%s) is still referenced from table \"%s\"",
+					r.table.name, fk.name, child.table.name, strings.Join(fk.referenced, ", "), keyValues(child.table, fk.attributes, row), child.table.name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// cascadedRelations write locks the relations whose rows may be deleted or changed by ON DELETE
+// actions of foreign keys when rows of locked relation r are deleted, transitively, and returns them
+func (e *Engine) cascadedRelations(r *Relation) []*Relation {
+	if !e.foreignKeys {
+		return nil
+	}
+
+	var cascaded []*Relation
+	seen := map[string]bool{r.table.name: true}
+	for queue := []string{r.table.name}; len(queue) > 0; queue = queue[1:] {
+		for _, name := range e.referencing(queue[0]) {
+			if seen[name] {
+				continue
+			}
+			child := e.relation(name)
+			if child == nil {
+				continue
+			}
+			for _, fk := range child.table.foreignKeys {
+				if fk.table == queue[0] && fk.onDelete != "" {
+					seen[name] = true
+					child.Lock()
+					cascaded = append(cascaded, child)
+					queue = append(queue, name)
+					break
+				}
+			}
+		}
+	}
+
+	return cascaded
+}
+
+// deleteReferences applies ON DELETE actions of foreign keys to the rows referencing the ones
+// deleted from locked relation r, which keeps given rows: they are deleted with CASCADE, or get
+// NULL values with SET NULL, transitively. It returns the rows of each relation changed, r
+// included, the other ones being among cascaded relations.
+func (e *Engine) deleteReferences(r *Relation, kept []*Tuple, cascaded []*Relation) (map[*Relation][]*Tuple, error) {
+	rows := map[*Relation][]*Tuple{r: kept}
+	children := append([]*Relation{r}, cascaded...)
+
+	// Each change deletes rows or NULL values, so that cycles end
+	for changed := []*Relation{r}; len(changed) > 0; changed = changed[1:] {
+		parent := changed[0]
+		for _, child := range children {
+			for _, fk := range child.table.foreignKeys {
+				if fk.table != parent.table.name || fk.onDelete == "" {
+					continue
+				}
+				keys := make(map[string]bool)
+				for _, row := range rows[parent] {
+					if k, ok := parent.table.key(fk.referenced, row); ok {
+						keys[k] = true
+					}
+				}
+
+				current, ok := rows[child]
+				if !ok {
+					current = child.rows
+				}
+				var next []*Tuple
+				modified := false
+				for _, row := range current {
+					k, ok := child.table.key(fk.attributes, row)
+					if !ok || keys[k] {
+						next = append(next, row)
+						continue
+					}
+					modified = true
+					if fk.onDelete == "cascade" {
+						continue
+					}
+					row = NewTuple(row.Values...)
+					for _, a := range fk.attributes {
+						row.Values[child.table.attributeIndex(a)] = nil
+					}
+					if err := child.table.checkNotNull(row); err != nil {
+						return nil, err
+					}
+					next = append(next, row)
+				}
+				if modified {
+					rows[child] = next
+					changed = append(changed, child)
+				}
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// referencing returns the names of tables with a foreign key referencing given one, itself included
+func (e *Engine) referencing(table string) []string {
+	e.Lock()
+	defer e.Unlock()
+
+	var names []string
+	for name, r := range e.relations {
+		for _, fk := range r.table.foreignKeys {
+			if fk.table == table {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+// referencedBy returns the name of another table with a foreign key referencing given one,
+// if foreign keys are enforced, or an empty string if there is none
+func (e *Engine) referencedBy(table string) string {
+	if !e.foreignKeys {
+		return ""
+	}
+
+	for _, name := range e.referencing(table) {
+		if name != table {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// renameReferences renames table referenced by foreign keys of t, or given column of it if any
+func (t *Table) renameReferences(table string, column string, name string) {
+	for i := range t.foreignKeys {
+		fk := &t.foreignKeys[i]
+		if fk.table != table {
+			continue
+		}
+		if column == "" {
+			fk.table = name
+			continue
+		}
+		for j := range fk.referenced {
+			if fk.referenced[j] == column {
+				fk.referenced[j] = name
+			}
+		}
+	}
+}
+
+// keyValues returns row values of given attributes, as shown in errors
+func keyValues(t *Table, attributes []string, row *Tuple) string {
+	var values []string
+	for _, a := range attributes {
+		values = append(values, fmt.Sprintf("%v", row.Values[t.attributeIndex(a)]))
+	}
+
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,620 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestUniqueConstraint(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUniqueConstraint")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, email TEXT UNIQUE, first_name TEXT, last_name TEXT, UNIQUE (first_name, last_name))`,
+		`INSERT INTO users (email, first_name, last_name) VALUES ('alice@example.com', 'Alice', 'Smith')`,
+		`INSERT INTO users (email, first_name, last_name) VALUES ('bob@example.com', 'Bob', 'Smith')`,
+		`INSERT INTO users (email, first_name, last_name) VALUES ('alice2@example.com', 'Alice', 'Jones')`,
+		// NULL values never conflict
+		`INSERT INTO users (first_name) VALUES ('Carol')`,
+		`INSERT INTO users (email, first_name) VALUES (NULL, 'Carol')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	violations := map[string]string{
+		`INSERT INTO users (email) VALUES ('bob@example.com')`:                                      "users_email_key",
+		`INSERT INTO users (first_name, last_name) VALUES ('Alice', 'Smith')`:                       "users_first_name_last_name_key",
+		`UPDATE users SET email = 'alice@example.com' WHERE id = 2`:                                 "users_email_key",
+		`UPDATE users SET last_name = 'Smith' WHERE first_name = 'Alice'`:                           "users_first_name_last_name_key",
+		`UPDATE users SET email = 'same@example.com' WHERE last_name = 'Smith'`:                     "users_email_key",
+		`INSERT INTO users (email, first_name, last_name) VALUES ('x@example.com', 'Bob', 'Smith')`: "users_first_name_last_name_key",
+	}
+	for query, constraint := range violations {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected UNIQUE violation with '%s'", query)
+		}
+		if !strings.HasPrefix(err.Error(), "UNIQUE constraint violation") || !strings.Contains(err.Error(), `"`+constraint+`"`) {
+			t.Fatalf("Expected violation of %s with '%s', got: %s", constraint, query, err)
+		}
+	}
+
+	// Failed statements did not change anything
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM users WHERE email = 'same@example.com' OR email = 'x@example.com'`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Cannot count users: %s", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected no row changed by failed statements, got %d", count)
+	}
+
+	// Updating a row with its own values is not a violation
+	_, err = db.Exec(`UPDATE users SET email = 'bob@example.com' WHERE id = 2`)
+	if err != nil {
+		t.Fatalf("Cannot update row with its own value: %s", err)
+	}
+
+	_, err = db.Exec(`UPDATE users SET email = NULL WHERE first_name = 'Alice'`)
+	if err != nil {
+		t.Fatalf("Cannot set several NULL values: %s", err)
+	}
+	err = db.QueryRow(`SELECT COUNT(*) FROM users WHERE email IS NULL`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Cannot count users: %s", err)
+	}
+	if count != 4 {
+		t.Fatalf("Expected 4 NULL emails, got %d", count)
+	}
+
+	_, err = db.Exec(`CREATE TABLE broken (id BIGSERIAL, UNIQUE (name))`)
+	if err == nil {
+		t.Fatalf("Expected error with UNIQUE constraint on unknown column")
+	}
+}
+
+func TestUniqueAfterWrites(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUniqueAfterWrites")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	// Keys freed or taken by each write are seen by the following ones
+	batch := []string{
+		`CREATE TABLE slot (id INT PRIMARY KEY, k INT UNIQUE, parent INT REFERENCES slot(id) ON DELETE CASCADE)`,
+		`INSERT INTO slot (id, k) VALUES (1, 1), (2, 0)`,
+		`CREATE TABLE shift (id INT, k INT)`,
+		`INSERT INTO shift (id, k) VALUES (1, 2), (2, 1)`,
+		`UPDATE slot SET k = shift.k FROM shift WHERE slot.id = shift.id`,
+		`DELETE FROM slot WHERE id = 2`,
+		`INSERT INTO slot (id, k) VALUES (2, 1)`,
+		`UPDATE slot SET k = 5 WHERE id = 1`,
+		`INSERT INTO slot (id, k) VALUES (3, 2) ON CONFLICT (id) DO UPDATE SET k = 2`,
+		`INSERT INTO slot (id, k) VALUES (4, 3) ON CONFLICT (k) DO UPDATE SET k = 7`,
+		`INSERT INTO slot (id, k, parent) VALUES (5, 8, 4), (6, 9, 5)`,
+		`DELETE FROM slot WHERE id = 4`,
+		`INSERT INTO slot (id, k) VALUES (5, 8), (6, 9)`,
+		`ALTER TABLE slot RENAME COLUMN k TO code`,
+		`TRUNCATE TABLE slot`,
+		`INSERT INTO slot (id, code) VALUES (1, 1)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO slot (id, code) VALUES (2, 2)`); err != nil {
+		t.Fatalf("cannot insert in transaction: %s", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO slot (id, code) VALUES (3, 2)`); err == nil {
+		t.Fatalf("expected UNIQUE violation in transaction")
+	}
+	tx.Rollback()
+
+	violations := []string{
+		`INSERT INTO slot (id, code) VALUES (1, 2)`,
+		`INSERT INTO slot (id, code) VALUES (2, 1)`,
+		`INSERT INTO slot (id, code) VALUES (2, 2), (3, 2)`,
+	}
+	for _, query := range violations {
+		_, err = db.Exec(query)
+		if err == nil || !strings.HasPrefix(err.Error(), "UNIQUE constraint violation") {
+			t.Fatalf("Expected UNIQUE violation with '%s', got %v", query, err)
+		}
+	}
+
+	if _, err := db.Exec(`INSERT INTO slot (id, code) VALUES (2, 2)`); err != nil {
+		t.Fatalf("cannot insert key of rolled back row: %s", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE dup (id INT, k INT)`); err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO dup (id, k) VALUES (1, 1), (2, 1)`); err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if _, err := db.Exec(`CREATE UNIQUE INDEX dup_k_idx ON dup (k)`); err == nil {
+		t.Fatalf("expected UNIQUE index on duplicate values to fail")
+	}
+	if _, err := db.Exec(`UPDATE dup SET k = 2 WHERE id = 2`); err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if _, err := db.Exec(`CREATE UNIQUE INDEX dup_k_idx ON dup (k)`); err != nil {
+		t.Fatalf("cannot create UNIQUE index: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO dup (id, k) VALUES (3, 2)`); err == nil {
+		t.Fatalf("expected UNIQUE violation of index created on existing rows")
+	}
+}
+
+func TestNotNullConstraint(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestNotNullConstraint")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE accounts (id BIGSERIAL, email TEXT NOT NULL, nickname TEXT, active BOOLEAN NOT NULL DEFAULT false)`,
+		`INSERT INTO accounts (email, nickname) VALUES ('alice@example.com', 'al')`,
+		`INSERT INTO accounts (email) VALUES ('bob@example.com')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	violations := map[string]string{
+		`INSERT INTO accounts (nickname) VALUES ('anonymous')`:           "email",
+		`INSERT INTO accounts (email, nickname) VALUES (NULL, 'nobody')`: "email",
+		`UPDATE accounts SET email = NULL WHERE id = 2`:                  "email",
+		`UPDATE accounts SET active = NULL WHERE nickname = 'al'`:        "active",
+	}
+	for query, column := range violations {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected NOT NULL violation with '%s'", query)
+		}
+		if !strings.Contains(err.Error(), `column "`+column+`" of relation "accounts"`) {
+			t.Fatalf("Expected violation on %s with '%s', got: %s", column, query, err)
+		}
+	}
+
+	// Placeholder set to nil is NULL as well
+	_, err = db.Exec(`INSERT INTO accounts (email) VALUES ($1)`, nil)
+	if err == nil {
+		t.Fatalf("Expected NOT NULL violation with nil argument")
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE email IS NOT NULL AND active IS NOT NULL`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Cannot count accounts: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 valid accounts, got %d", count)
+	}
+
+	_, err = db.Exec(`UPDATE accounts SET nickname = NULL WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("Cannot set nullable column to NULL: %s", err)
+	}
+}
+
+func TestDefaultValues(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDefaultValues")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE tickets (id INT, created TIMESTAMP DEFAULT CURRENT_TIMESTAMP, status TEXT DEFAULT 'new', priority BIGINT DEFAULT (2 * 5), code TEXT DEFAULT upper('tk'), note TEXT)`,
+		`INSERT INTO tickets (id) VALUES (1)`,
+		`INSERT INTO tickets (id, status, note) VALUES (2, 'closed', 'done')`,
+		`INSERT INTO tickets (id, status, priority) VALUES (3, DEFAULT, 1)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	expected := map[int][]string{
+		1: {"new", "10", "TK", "<nil>"},
+		2: {"closed", "10", "TK", "done"},
+		3: {"new", "1", "TK", "<nil>"},
+	}
+	for id, exp := range expected {
+		var status, priority, code string
+		var note sql.NullString
+		var created time.Time
+		err = db.QueryRow(`SELECT created, status, priority, code, note FROM tickets WHERE id = $1`, id).Scan(&created, &status, &priority, &code, &note)
+		if err != nil {
+			t.Fatalf("Cannot select ticket %d: %s", id, err)
+		}
+		if created.IsZero() {
+			t.Fatalf("Expected creation time to be set for ticket %d", id)
+		}
+		n := "<nil>"
+		if note.Valid {
+			n = note.String
+		}
+		got := []string{status, priority, code, n}
+		for i := range exp {
+			if got[i] != exp[i] {
+				t.Fatalf("Expected %v for ticket %d, got %v", exp, id, got)
+			}
+		}
+	}
+
+	// NOT NULL without default must be provided
+	_, err = db.Exec(`CREATE TABLE labels (id INT, name TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO labels (id) VALUES (1)`)
+	if err == nil {
+		t.Fatalf("Expected NOT NULL violation when column without default is omitted")
+	}
+
+	// Defaults cannot reference columns
+	_, err = db.Exec(`CREATE TABLE broken (id INT, twice BIGINT DEFAULT (id * 2))`)
+	if err == nil {
+		t.Fatalf("Expected error with default referencing a column")
+	}
+}
+
+func TestForeignKeyConstraint(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestForeignKeyConstraint")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL PRIMARY KEY, email TEXT UNIQUE)`,
+		`CREATE TABLE orders (id BIGSERIAL PRIMARY KEY, user_id BIGINT REFERENCES users (id), email TEXT, parent_id BIGINT REFERENCES orders, FOREIGN KEY (email) REFERENCES users (email))`,
+		`INSERT INTO users (email) VALUES ('alice@example.com')`,
+		`INSERT INTO users (email) VALUES ('bob@example.com')`,
+		`INSERT INTO orders (user_id, email) VALUES (1, 'alice@example.com')`,
+		`INSERT INTO orders (user_id, parent_id) VALUES (1, 1)`,
+		// NULL values reference nothing
+		`INSERT INTO orders (user_id) VALUES (NULL)`,
+		// Rows of a statement may reference each other
+		`INSERT INTO orders (id, parent_id) VALUES (10, 11), (11, 10)`,
+		// Keys which are not referenced may be changed or deleted
+		`UPDATE users SET email = 'robert@example.com' WHERE id = 2`,
+		`DELETE FROM users WHERE id = 2`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	violations := map[string]string{
+		`INSERT INTO orders (user_id) VALUES (42)`:                                                  `insert or update on table "orders" violates foreign key constraint "orders_user_id_fkey": key (user_id)=(42) is not present in table "users"`,
+		`INSERT INTO orders (email) VALUES ('bob@example.com')`:                                     `insert or update on table "orders" violates foreign key constraint "orders_email_fkey": key (email)=(bob@example.com) is not present in table "users"`,
+		`INSERT INTO orders (user_id, parent_id) VALUES (1, 99)`:                                    `insert or update on table "orders" violates foreign key constraint "orders_parent_id_fkey": key (parent_id)=(99) is not present in table "orders"`,
+		`UPDATE orders SET user_id = 3 WHERE id = 1`:                                                `insert or update on table "orders" violates foreign key constraint "orders_user_id_fkey": key (user_id)=(3) is not present in table "users"`,
+		`DELETE FROM users WHERE id = 1`:                                                            `update or delete on table "users" violates foreign key constraint "orders_user_id_fkey" on table "orders": key (id)=(1) is still referenced from table "orders"`,
+		`DELETE FROM users`:                                                                         `update or delete on table "users" violates foreign key constraint "orders_user_id_fkey" on table "orders": key (id)=(1) is still referenced from table "orders"`,
+		`UPDATE users SET email = 'alice@example.org' WHERE id = 1`:                                 `update or delete on table "users" violates foreign key constraint "orders_email_fkey" on table "orders": key (email)=(alice@example.com) is still referenced from table "orders"`,
+		`DELETE FROM orders WHERE id = 1`:                                                           `update or delete on table "orders" violates foreign key constraint "orders_parent_id_fkey" on table "orders": key (id)=(1) is still referenced from table "orders"`,
+		`INSERT INTO orders (user_id) VALUES (1), (2)`:                                              `insert or update on table "orders" violates foreign key constraint "orders_user_id_fkey": key (user_id)=(2) is not present in table "users"`,
+		`UPDATE orders SET parent_id = 5 WHERE id = 2`:                                              `insert or update on table "orders" violates foreign key constraint "orders_parent_id_fkey": key (parent_id)=(5) is not present in table "orders"`,
+		`INSERT INTO orders (id, user_id) VALUES (1, 1) ON CONFLICT (id) DO UPDATE SET user_id = 7`: `insert or update on table "orders" violates foreign key constraint "orders_user_id_fkey": key (user_id)=(7) is not present in table "users"`,
+	}
+	for query, expected := range violations {
+		_, err := db.Exec(query)
+		if err == nil || err.Error() != "FOREIGN KEY constraint violation: "+expected {
+			t.Fatalf("expected '%s' to fail with '%s', got %v", query, expected, err)
+		}
+	}
+
+	// Failed statements did not change anything
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&count); err != nil || count != 5 {
+		t.Fatalf("expected 5 orders, got %d (%v)", count, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil || count != 1 {
+		t.Fatalf("expected 1 user, got %d (%v)", count, err)
+	}
+
+	// Referenced tables cannot be truncated or dropped
+	for _, query := range []string{`TRUNCATE users`, `DROP TABLE users`, `ALTER TABLE orders DROP COLUMN user_id`} {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	// Referenced keys must exist, and be unique
+	invalid := []string{
+		`CREATE TABLE invoices (user_id BIGINT REFERENCES missing (id))`,
+		`CREATE TABLE invoices (user_id BIGINT REFERENCES users (missing))`,
+		`CREATE TABLE invoices (user_id BIGINT, FOREIGN KEY (missing) REFERENCES users (id))`,
+		`CREATE TABLE invoices (email TEXT REFERENCES orders (email))`,
+		`CREATE TABLE invoices (user_id BIGINT, email TEXT, FOREIGN KEY (user_id, email) REFERENCES users (id))`,
+		`CREATE TABLE invoices (id BIGINT, other_id BIGINT REFERENCES invoices)`,
+	}
+	for _, query := range invalid {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	// Renamed tables and columns are still referenced
+	rename := []string{
+		`ALTER TABLE users RENAME TO customers`,
+		`ALTER TABLE customers RENAME COLUMN id TO customer_id`,
+		`ALTER TABLE orders ADD COLUMN referrer_id BIGINT REFERENCES customers`,
+	}
+	for _, query := range rename {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", query, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO orders (user_id) VALUES (2)`); err == nil || !strings.Contains(err.Error(), `is not present in table "customers"`) {
+		t.Fatalf("expected missing customer, got %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO orders (referrer_id) VALUES (1)`); err != nil {
+		t.Fatalf("cannot insert order with referrer: %s", err)
+	}
+	if _, err := db.Exec(`DELETE FROM customers WHERE customer_id = 1`); err == nil || !strings.Contains(err.Error(), `key (customer_id)=(1) is still referenced`) {
+		t.Fatalf("expected referenced customer, got %v", err)
+	}
+
+	// Foreign keys may not be enforced
+	unchecked, err := sql.Open("ramsql", "TestForeignKeyConstraintUnchecked?foreign_keys=off")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer unchecked.Close()
+	batch = []string{
+		`CREATE TABLE users (id BIGSERIAL PRIMARY KEY)`,
+		`CREATE TABLE orders (user_id BIGINT REFERENCES users)`,
+		`INSERT INTO orders (user_id) VALUES (42)`,
+		`DROP TABLE users`,
+	}
+	for _, b := range batch {
+		if _, err := unchecked.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+}
+
+func TestForeignKeyOnDelete(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestForeignKeyOnDelete")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id INT PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES users ON DELETE CASCADE)`,
+		`CREATE TABLE items (id INT PRIMARY KEY, order_id INT NOT NULL, FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE)`,
+		`CREATE TABLE notes (id INT PRIMARY KEY, order_id INT REFERENCES orders ON DELETE SET NULL)`,
+		`CREATE TABLE employees (id INT PRIMARY KEY, manager_id INT REFERENCES employees ON DELETE CASCADE)`,
+		`INSERT INTO users (id, name) VALUES (1, 'alice'), (2, 'bob')`,
+		`INSERT INTO orders (id, user_id) VALUES (10, 1), (11, 1), (12, 2)`,
+		`INSERT INTO items (id, order_id) VALUES (100, 10), (101, 10), (102, 11), (103, 12)`,
+		`INSERT INTO notes (id, order_id) VALUES (1000, 10), (1001, 12)`,
+		// Employees 3 and 4 manage each other
+		`INSERT INTO employees (id, manager_id) VALUES (1, NULL), (2, 1), (3, 4), (4, 3), (5, 2)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("cannot count '%s': %s", query, err)
+		}
+		return n
+	}
+
+	// Only directly deleted rows are affected
+	res, err := db.Exec(`DELETE FROM users WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("cannot delete user: %s", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("expected 1 row affected, got %d (%v)", n, err)
+	}
+	expected := map[string]int{
+		`SELECT COUNT(*) FROM orders`:                                  1,
+		`SELECT COUNT(*) FROM items`:                                   1,
+		`SELECT COUNT(*) FROM notes`:                                   2,
+		`SELECT COUNT(*) FROM notes WHERE order_id IS NULL`:            1,
+		`SELECT COUNT(*) FROM notes WHERE id = 1001 AND order_id = 12`: 1,
+	}
+	for query, n := range expected {
+		if c := count(query); c != n {
+			t.Fatalf("%s: expected %d, got %d", query, n, c)
+		}
+	}
+
+	// Chains and cycles of references are deleted
+	if _, err := db.Exec(`DELETE FROM employees WHERE id = 1`); err != nil {
+		t.Fatalf("cannot delete employee: %s", err)
+	}
+	if c := count(`SELECT COUNT(*) FROM employees`); c != 2 {
+		t.Fatalf("expected 2 employees, got %d", c)
+	}
+	if _, err := db.Exec(`DELETE FROM employees WHERE id = 3`); err != nil {
+		t.Fatalf("cannot delete employee: %s", err)
+	}
+	if c := count(`SELECT COUNT(*) FROM employees`); c != 0 {
+		t.Fatalf("expected no employee, got %d", c)
+	}
+
+	// Deletion is restricted by a referencing row without action, even through a cascade
+	if _, err := db.Exec(`CREATE TABLE audit (item_id INT REFERENCES items)`); err != nil {
+		t.Fatalf("cannot create table: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO audit (item_id) VALUES (103)`); err != nil {
+		t.Fatalf("cannot insert audit: %s", err)
+	}
+	_, err = db.Exec(`DELETE FROM users WHERE id = 2`)
+	if err == nil || !strings.Contains(err.Error(), `violates foreign key constraint "audit_item_id_fkey" on table "audit"`) {
+		t.Fatalf("expected deletion to be restricted, got %v", err)
+	}
+	if c := count(`SELECT COUNT(*) FROM users`) + count(`SELECT COUNT(*) FROM orders`) + count(`SELECT COUNT(*) FROM items`); c != 3 {
+		t.Fatalf("expected rows to be unchanged, got %d", c)
+	}
+	if c := count(`SELECT COUNT(*) FROM notes WHERE order_id = 12`); c != 1 {
+		t.Fatalf("expected note to be unchanged, got %d", c)
+	}
+	if _, err := db.Exec(`DELETE FROM audit`); err != nil {
+		t.Fatalf("cannot delete audit: %s", err)
+	}
+
+	// Cascaded deletions are rolled back with their transaction
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM users`); err != nil {
+		t.Fatalf("cannot delete users: %s", err)
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("expected no item in transaction, got %d (%v)", n, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("cannot rollback: %s", err)
+	}
+	if c := count(`SELECT COUNT(*) FROM items`); c != 1 {
+		t.Fatalf("expected item to be back, got %d", c)
+	}
+
+	// SET NULL cannot break a NOT NULL constraint
+	batch = []string{
+		`CREATE TABLE shipments (id INT PRIMARY KEY, order_id INT NOT NULL REFERENCES orders ON DELETE SET NULL)`,
+		`INSERT INTO shipments (id, order_id) VALUES (1, 12)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM orders WHERE id = 12`); err == nil || !strings.HasPrefix(err.Error(), "NOT NULL constraint violation") {
+		t.Fatalf("expected NOT NULL violation, got %v", err)
+	}
+	if c := count(`SELECT COUNT(*) FROM orders`); c != 1 {
+		t.Fatalf("expected order to be kept, got %d", c)
+	}
+}
+
+func TestCheckConstraint(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCheckConstraint")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE product (id INT PRIMARY KEY, price FLOAT CHECK (price > 0), stock INT CONSTRAINT positive_stock CHECK (stock > 0))`,
+		`CREATE TABLE booking (id INT PRIMARY KEY, start INT, stop INT, CONSTRAINT valid_range CHECK (start < stop))`,
+		`INSERT INTO product (id, price, stock) VALUES (1, 10, 5)`,
+		`INSERT INTO product (id, price) VALUES (2, 3.5)`,
+		`INSERT INTO booking (id, start, stop) VALUES (1, 1, 2)`,
+		`INSERT INTO booking (id, start) VALUES (2, 10)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	violations := []struct {
+		query string
+		err   string
+	}{
+		{`INSERT INTO product (id, price, stock) VALUES (3, 0, 1)`, `CHECK constraint violation: new row for relation "product" violates check constraint "product_price_check"`},
+		{`INSERT INTO product (id, price, stock) VALUES (3, 1, 0)`, `CHECK constraint violation: new row for relation "product" violates check constraint "positive_stock"`},
+		{`INSERT INTO booking (id, start, stop) VALUES (3, 5, 5)`, `CHECK constraint violation: new row for relation "booking" violates check constraint "valid_range"`},
+		{`UPDATE product SET stock = 0 WHERE id > 0`, `CHECK constraint violation: new row for relation "product" violates check constraint "positive_stock"`},
+		{`INSERT INTO product (id, price, stock) VALUES (1, 0, 1) ON CONFLICT (id) DO UPDATE SET price = 0`, `CHECK constraint violation: new row for relation "product" violates check constraint "product_price_check"`},
+	}
+	for _, v := range violations {
+		if _, err := db.Exec(v.query); err == nil || err.Error() != v.err {
+			t.Fatalf("expected '%s' to fail with %s, got %v", v.query, v.err, err)
+		}
+	}
+
+	// Rejected statements change nothing
+	var stock int
+	var price float64
+	if err := db.QueryRow(`SELECT price, stock FROM product WHERE id = 1`).Scan(&price, &stock); err != nil || price != 10 || stock != 5 {
+		t.Fatalf("expected product to be unchanged, got %f and %d (%v)", price, stock, err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM product`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("expected 2 products, got %d (%v)", n, err)
+	}
+
+	// Valid updates still go through
+	if _, err := db.Exec(`UPDATE product SET stock = 1 WHERE id = 1`); err != nil {
+		t.Fatalf("cannot update product: %s", err)
+	}
+
+	// Checks must be valid on creation, and on added columns
+	failing := []string{
+		`CREATE TABLE invalid (id INT CHECK (missing > 0))`,
+		`CREATE TABLE invalid (id INT, CONSTRAINT c CHECK (id > 0), CONSTRAINT c CHECK (id < 10))`,
+		`ALTER TABLE product ADD COLUMN weight INT DEFAULT 0 CHECK (weight > 0)`,
+		`ALTER TABLE product DROP COLUMN stock`,
+		`ALTER TABLE booking RENAME COLUMN start TO begin`,
+	}
+	for _, query := range failing {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	if _, err := db.Exec(`ALTER TABLE product ADD COLUMN weight INT DEFAULT 1 CHECK (weight > 0)`); err != nil {
+		t.Fatalf("cannot add column: %s", err)
+	}
+	if _, err := db.Exec(`INSERT INTO product (id, price, stock, weight) VALUES (3, 1, 1, 0)`); err == nil || err.Error() != `CHECK constraint violation: new row for relation "product" violates check constraint "product_weight_check"` {
+		t.Fatalf("expected weight check violation, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// isDecimal returns true if type stores exact numbers, like NUMERIC(10, 2)
+func isDecimal(typeName string) bool {
+	switch strings.ToLower(typeName) {
+	case "decimal", "numeric":
+		return true
+	}
+
+	return false
+}
+
+// parseDecimal returns the exact value of a number
+func parseDecimal(v interface{}) (*big.Rat, error) {
+	s := strings.TrimSpace(fmt.Sprintf("%v", v))
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type numeric: \"%s\"", s)
+	}
+
+	return r, nil
+}
+
+// decimalScale returns the number of digits after decimal point of a number
+func decimalScale(v interface{}) int {
+	s := fmt.Sprintf("%v", v)
+
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		return 0
+	}
+	s = s[i+1:]
+	if e := strings.IndexAny(s, "eE"); e >= 0 {
+		s = s[:e]
+	}
+
+	return len(s)
+}
+
+// formatDecimal returns r with scale digits after decimal point, rounded half to even
+func formatDecimal(r *big.Rat, scale int) string {
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	num := new(big.Int).Mul(r.Num(), pow)
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+
+	// Remainder is compared with half of denominator, a tie rounding to even quotient
+	m.Abs(m).Lsh(m, 1)
+	if c := m.Cmp(r.Denom()); c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	digits := new(big.Int).Abs(q).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if q.Sign() < 0 {
+		digits = "-" + digits
+	}
+
+	return digits
+}
+
+// convertDecimal returns v as stored in a decimal attribute. With a precision, it is
+// rounded to attribute scale, and cannot have more than precision digits.
+func (a Attribute) convertDecimal(v interface{}) (interface{}, error) {
+	r, err := parseDecimal(v)
+	if err != nil {
+		return nil, err
+	}
+	if a.precision == 0 {
+		return v, nil
+	}
+
+	s := formatDecimal(r, a.scale)
+	integer := strings.TrimLeft(strings.SplitN(strings.TrimPrefix(s, "-"), ".", 2)[0], "0")
+	if len(integer) > a.precision-a.scale {
+		return nil, fmt.Errorf("numeric field overflow: value %v of column %s must round to an absolute value less than 10^%d", v, a.name, a.precision-a.scale)
+	}
+
+	return s, nil
+}
+
+// decimalLiteral returns a number as it would be stored in attribute, if it is a decimal
+// one and number has the same value once stored, so that they can be compared
+func (a Attribute) decimalLiteral(lexeme string) string {
+	if !isDecimal(a.typeName) || a.precision == 0 {
+		return lexeme
+	}
+
+	r, err := parseDecimal(lexeme)
+	if err != nil {
+		return lexeme
+	}
+	s := formatDecimal(r, a.scale)
+	if stored, _ := parseDecimal(s); stored.Cmp(r) != 0 {
+		return lexeme
+	}
+
+	return s
+}
@@ -0,0 +1,132 @@
+package engine_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestDecimal(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDecimal")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id INT, amount NUMERIC(10, 2), rate DECIMAL)`,
+		`INSERT INTO account (id, amount, rate) VALUES (1, 0.1, 0.1)`,
+		`INSERT INTO account (id, amount, rate) VALUES (2, 0.2, 0.2)`,
+		`INSERT INTO account (id, amount, rate) VALUES (3, 2.675, 0.005)`,
+		`INSERT INTO account (id, amount, rate) VALUES (4, 2.665, NULL)`,
+		`INSERT INTO account (id, amount, rate) VALUES (5, 1.005, 1)`,
+		`INSERT INTO account (id, amount, rate) VALUES (6, '-1.005', 10.5)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("sql.Query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var values []string
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("cannot scan: %s", err)
+			}
+			values = append(values, v.String)
+		}
+		return values
+	}
+
+	// Values are rounded half to even to the scale of attribute
+	if v, expected := values(`SELECT amount FROM account ORDER BY id`), []string{"0.10", "0.20", "2.68", "2.66", "1.00", "-1.00"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+	if v, expected := values(`SELECT rate FROM account ORDER BY id`), []string{"0.1", "0.2", "0.005", "", "1", "10.5"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+
+	// Sums and means are exact
+	if v, expected := values(`SELECT SUM(amount) FROM account WHERE id < 3`), []string{"0.30"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+	if v, expected := values(`SELECT SUM(rate) FROM account`), []string{"11.805"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+	if v, expected := values(`SELECT AVG(amount) FROM account WHERE id < 3`), []string{"0.1500000000000000"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+
+	// Literals are compared with stored values
+	if v, expected := values(`SELECT id FROM account WHERE amount = 2.68`), []string{"3"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+	if v, expected := values(`SELECT id FROM account WHERE amount = 0.1`), []string{"1"}; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %v, got %v", expected, v)
+	}
+	if v := values(`SELECT id FROM account WHERE amount = 2.675`); len(v) != 0 {
+		t.Fatalf("expected no row, got %v", v)
+	}
+
+	// Values are scanned as strings, without float rounding
+	var amount string
+	err = db.QueryRow(`SELECT amount FROM account WHERE id = $1`, 3).Scan(&amount)
+	if err != nil {
+		t.Fatalf("cannot scan amount: %s", err)
+	}
+	if amount != "2.68" {
+		t.Fatalf("expected 2.68, got %s", amount)
+	}
+	rows, err := db.Query(`SELECT amount FROM account`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	types, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		t.Fatalf("cannot get column types: %s", err)
+	}
+	if st := types[0].ScanType(); st != reflect.TypeOf("") {
+		t.Fatalf("expected NUMERIC to be scanned as string, got %v", st)
+	}
+
+	// Precision bounds integer digits
+	_, err = db.Exec(`CREATE TABLE small (amount NUMERIC(4, 2))`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO small (amount) VALUES (99.99)`)
+	if err != nil {
+		t.Fatalf("expected 99.99 to fit NUMERIC(4, 2): %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO small (amount) VALUES (123.45)`)
+	if err == nil {
+		t.Fatalf("expected numeric field overflow")
+	}
+	_, err = db.Exec(`INSERT INTO small (amount) VALUES (99.995)`)
+	if err == nil {
+		t.Fatalf("expected numeric field overflow once rounded")
+	}
+	_, err = db.Exec(`INSERT INTO small (amount) VALUES ('abc')`)
+	if err == nil {
+		t.Fatalf("expected invalid input syntax error")
+	}
+
+	_, err = db.Exec(`CREATE TABLE invalid (amount NUMERIC(2, 3))`)
+	if err == nil {
+		t.Fatalf("expected scale greater than precision to fail")
+	}
+}
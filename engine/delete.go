@@ -9,67 +9,172 @@ import (
 	"github.com/proullon/ramsql/engine/protocol"
 )
 
+// deleteExecutor deletes rows of a table validating WHERE clause, and rows of other tables
+// referencing them with ON DELETE CASCADE. Only rows of the table are counted as affected,
+// or returned with RETURNING clause.
 func deleteExecutor(e *Engine, deleteDecl *parser.Decl, conn protocol.EngineConn) error {
 	log.Debug("deleteExecutor")
 
 	// get tables to be deleted
 	tables := fromExecutor(deleteDecl.Decl[0])
 
-	// If len is 1, it means no predicates so truncate table
-	if len(deleteDecl.Decl) == 1 {
-		return truncateTable(e, tables[0], conn)
+	r := e.relation(tables[0].name)
+	if r == nil {
+		return &Error{Code: UndefinedTable, Table: tables[0].name, Message: fmt.Sprintf("Table %s not found", tables[0].name)}
+	}
+
+	var whereDecl *parser.Decl
+	for _, d := range deleteDecl.Decl[1:] {
+		if d.Token == parser.WhereToken {
+			whereDecl = d
+		}
+	}
+
+	// If there is no predicates, rows to return, triggers to fire nor references to check, truncate table
+	if whereDecl == nil && len(deleteDecl.Decl) == 1 && !r.fires(parser.DeleteToken) && e.referencedBy(r.table.name) == "" {
+		return truncateTable(e, tables[0], false, conn)
 	}
 
-	// get WHERE declaration
-	predicates, err := whereExecutor(deleteDecl.Decl[1], tables[0].name)
+	r.Lock()
+	defer r.Unlock()
+
+	// Deleted table is in scope with its alias
+	target := &Relation{table: r.table, rows: r.rows}
+	if alias := selectedAlias(deleteDecl.Decl[0].Decl[0]); alias != "" {
+		target.table = &Table{name: alias, attributes: r.table.attributes, aliased: r.table.name}
+	}
+
+	// Relations changed by ON DELETE actions of foreign keys are write locked as well
+	cascaded := e.cascadedRelations(r)
+	locked := map[*Relation]bool{r: true}
+	for _, c := range cascaded {
+		locked[c] = true
+	}
+	defer func() {
+		for l := range locked {
+			if l != r && !contains(cascaded, l) {
+				l.RUnlock()
+			}
+		}
+		for _, c := range cascaded {
+			c.Unlock()
+		}
+	}()
+
+	// get WHERE declaration, evaluated like SELECT one so subqueries may be used.
+	// Deleted relations are already locked if a subquery reads them.
+	predicate := PredicateLinker(&TruePredicate)
+	if whereDecl != nil {
+		var err error
+		predicate, err = whereExecutor2(e, whereDecl.Decl, []*Table{target.table}, locked)
+		if err != nil {
+			return err
+		}
+	}
+
+	// get triggers fired by deleted rows
+	deleting, err := triggersExecutor(e, r, parser.DeleteToken, locked)
+	if err != nil {
+		return err
+	}
+
+	// get RETURNING declaration
+	ret, err := returningExecutor(r.table, deleteDecl.Decl)
 	if err != nil {
 		return err
 	}
 
 	// and delete
-	return deleteRows(e, tables, conn, predicates)
+	deleted, err := deleteRows(e, r, target.table, predicate, deleting, cascaded, locked)
+	if err != nil {
+		return err
+	}
+
+	if ret != nil {
+		return ret.write(conn, deleted)
+	}
+	return conn.WriteResult(0, int64(len(deleted)))
 }
 
-func deleteRows(e *Engine, tables []*Table, conn protocol.EngineConn, predicates []Predicate) error {
-	var rowsDeleted int64
+// deleteRows removes rows of locked relation validating predicate, and returns them.
+// All rows are evaluated before any is removed, so subqueries see the relation as it was.
+// Predicate refers to the relation as scope, which is its table or an alias of it.
+// Rows are removed only once deleting triggers have been fired for all of them. Rows of cascaded
+// relations referencing them are deleted or changed according to ON DELETE actions, and no other
+// row may reference them. Relations read are added to locked.
+func deleteRows(e *Engine, r *Relation, scope *Table, predicate PredicateLinker, deleting *rowTriggers, cascaded []*Relation, locked map[*Relation]bool) ([]*Tuple, error) {
+	target := &Relation{table: scope, rows: r.rows}
 
-	r := e.relation(tables[0].name)
-	if r == nil {
-		return fmt.Errorf("Table %s not found", tables[0].name)
-	}
-	r.Lock()
-	defer r.Unlock()
+	var deleted []*Tuple
+	var kept []*Tuple
 
-	var ok, res bool
-	var err error
-	lenRows := len(r.rows)
-	for i := 0; i < lenRows; i++ {
-		ok = true
-		// If the row validate all predicates, write it
-		for _, predicate := range predicates {
-			if res, err = predicate.Evaluate(r.rows[i], r.table); err != nil {
-				return err
-			}
-			if res == false {
-				ok = false
-				continue
+	for _, t := range r.rows {
+		if err := e.canceled(); err != nil {
+			return nil, err
+		}
+		ok, err := predicate.Eval(virtualRow{}.with(target, t))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if _, err := deleting.fireBefore(t, nil); err != nil {
+				return nil, err
 			}
+			deleted = append(deleted, t)
+			continue
 		}
+		kept = append(kept, t)
+	}
 
-		if ok {
-			switch i {
-			case 0:
-				r.rows = r.rows[1:]
-			case lenRows - 1:
-				r.rows = r.rows[:lenRows-1]
-			default:
-				r.rows = append(r.rows[:i], r.rows[i+1:]...)
-				i--
+	for _, t := range deleted {
+		if err := deleting.fireAfter(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	changes, err := e.deleteReferences(r, kept, cascaded)
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[*Relation][]*Tuple)
+	for c, rows := range changes {
+		previous[c] = c.rows
+		c.rows = rows
+	}
+	for c, rows := range changes {
+		if err := e.checkReferences(c, rows, locked); err != nil {
+			for c, rows := range previous {
+				c.rows = rows
 			}
-			lenRows--
-			rowsDeleted++
+			return nil, err
+		}
+	}
+
+	// Rows of relation itself may be deleted or updated by its foreign keys as well
+	for c, rows := range changes {
+		if c != r || !sameRows(rows, kept) {
+			c.rebuildIndexes()
+		}
+	}
+	if sameRows(changes[r], kept) {
+		for _, t := range deleted {
+			r.removeFromIndexes(t)
+		}
+	}
+
+	return deleted, nil
+}
+
+// contains returns true if r is one of given relations
+func contains(relations []*Relation, r *Relation) bool {
+	for _, c := range relations {
+		if c == r {
+			return true
 		}
 	}
 
-	return conn.WriteResult(0, rowsDeleted)
+	return false
 }
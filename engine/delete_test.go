@@ -63,6 +63,78 @@ func TestTrunc(t *testing.T) {
 
 }
 
+func TestTruncateTable(t *testing.T) {
+	log.UseTestLogger(t)
+	db, err := sql.Open("ramsql", "TestTruncateTable")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id BIGSERIAL PRIMARY KEY, label TEXT)`,
+		`INSERT INTO events (label) VALUES ('start')`,
+		`INSERT INTO events (label) VALUES ('stop')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	res, err := db.Exec("TRUNCATE TABLE events")
+	if err != nil {
+		t.Fatalf("Cannot truncate table: %s", err)
+	}
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		t.Fatalf("Cannot fetch affected rows: %s", err)
+	}
+	if affectedRows != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", affectedRows)
+	}
+
+	// Sequence starts over
+	res, err = db.Exec("INSERT INTO events (label) VALUES ('restart')")
+	if err != nil {
+		t.Fatalf("Cannot insert into table events: %s", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("Cannot fetch last inserted id: %s", err)
+	}
+	if id != 1 {
+		t.Fatalf("Expected id 1 after truncate, got %d", id)
+	}
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatalf("Cannot count events: %s", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 event, got %d", count)
+	}
+
+	// DELETE without WHERE keeps sequence going
+	if _, err = db.Exec("DELETE FROM events"); err != nil {
+		t.Fatalf("Cannot delete events: %s", err)
+	}
+	res, err = db.Exec("INSERT INTO events (label) VALUES ('again')")
+	if err != nil {
+		t.Fatalf("Cannot insert into table events: %s", err)
+	}
+	if id, _ = res.LastInsertId(); id != 2 {
+		t.Fatalf("Expected id 2 after delete, got %d", id)
+	}
+
+	if _, err = db.Exec("TRUNCATE events CASCADE"); err != nil {
+		t.Fatalf("Cannot truncate table with CASCADE: %s", err)
+	}
+	if _, err = db.Exec("TRUNCATE TABLE unknown"); err == nil {
+		t.Fatalf("Expected error truncating unknown table")
+	}
+}
+
 func TestDelete(t *testing.T) {
 	log.UseTestLogger(t)
 
@@ -161,3 +233,65 @@ func TestDeleteAnd(t *testing.T) {
 		t.Fatalf("Expected 3 values, got %d", n)
 	}
 }
+
+func TestDeleteSubquery(t *testing.T) {
+	log.UseTestLogger(t)
+	db, err := sql.Open("ramsql", "TestDeleteSubquery")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE posts (id INT PRIMARY KEY, spam INT)`,
+		`CREATE TABLE comments (id INT PRIMARY KEY, post_id INT, body TEXT)`,
+		`CREATE INDEX comments_post_idx ON comments (post_id)`,
+		`INSERT INTO posts (id, spam) VALUES (1, 0), (2, 1), (3, 1)`,
+		`INSERT INTO comments (id, post_id, body) VALUES (1, 1, 'a'), (2, 2, 'b'), (3, 2, 'c'), (4, 3, 'd'), (5, 4, 'e')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("Cannot exec query '%s': %s", b, err)
+		}
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("Cannot count with '%s': %s", query, err)
+		}
+		return n
+	}
+
+	res, err := db.Exec(`DELETE FROM comments WHERE post_id IN (SELECT id FROM posts WHERE spam = 1) AND id < 4`)
+	if err != nil {
+		t.Fatalf("Cannot delete with IN subquery: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM comments WHERE post_id = 2`); n != 0 {
+		t.Fatalf("Expected no comment left on post 2 using index, got %d", n)
+	}
+
+	// Orphans, with a correlated subquery
+	res, err = db.Exec(`DELETE FROM comments WHERE NOT EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id)`)
+	if err != nil {
+		t.Fatalf("Cannot delete with NOT EXISTS subquery: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Expected 1 row affected, got %d", n)
+	}
+
+	// Subquery on deleted table sees all its rows
+	res, err = db.Exec(`DELETE FROM comments WHERE id IN (SELECT id FROM comments WHERE post_id > 0)`)
+	if err != nil {
+		t.Fatalf("Cannot delete with subquery on same table: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM comments`); n != 0 {
+		t.Fatalf("Expected no comment left, got %d", n)
+	}
+}
@@ -7,21 +7,64 @@ import (
 	"github.com/proullon/ramsql/engine/protocol"
 )
 
+/*
+|-> drop
+	|-> table
+		|-> if
+			|-> exists
+		|-> account
+*/
 func dropExecutor(e *Engine, dropDecl *parser.Decl, conn protocol.EngineConn) error {
 
-	// Should have table token
+	// Should have table, index, view, sequence or trigger token
 	if dropDecl.Decl == nil ||
 		len(dropDecl.Decl) != 1 ||
-		dropDecl.Decl[0].Token != parser.TableToken ||
-		len(dropDecl.Decl[0].Decl) != 1 {
+		(dropDecl.Decl[0].Token != parser.TableToken && dropDecl.Decl[0].Token != parser.IndexToken &&
+			dropDecl.Decl[0].Token != parser.ViewToken && dropDecl.Decl[0].Token != parser.SequenceToken &&
+			dropDecl.Decl[0].Token != parser.TriggerToken) ||
+		len(dropDecl.Decl[0].Decl) < 1 {
 		return fmt.Errorf("unexpected drop arguments")
 	}
 
-	table := dropDecl.Decl[0].Decl[0].Lexeme
+	// With IF EXISTS, dropping a missing table, index, view, sequence or trigger does nothing
+	args := dropDecl.Decl[0].Decl
+	nameDecl := args[0]
+	ifExists := nameDecl.Token == parser.IfToken
+	if ifExists {
+		if len(args) < 2 {
+			return fmt.Errorf("unexpected drop arguments")
+		}
+		nameDecl = args[1]
+		args = args[1:]
+	}
+
+	switch dropDecl.Decl[0].Token {
+	case parser.IndexToken:
+		return dropIndexExecutor(e, nameDecl.Lexeme, ifExists, conn)
+	case parser.ViewToken:
+		return dropViewExecutor(e, nameDecl.Lexeme, ifExists, conn)
+	case parser.SequenceToken:
+		return dropSequenceExecutor(e, nameDecl.Lexeme, ifExists, conn)
+	case parser.TriggerToken:
+		// Trigger may be followed by its table
+		var table string
+		if len(args) > 1 && len(args[1].Decl) > 0 {
+			table = args[1].Decl[0].Lexeme
+		}
+		return dropTriggerExecutor(e, nameDecl.Lexeme, table, ifExists, conn)
+	}
+
+	table := nameDecl.Lexeme
 
 	r := e.relation(table)
 	if r == nil {
-		return fmt.Errorf("relation '%s' not found", table)
+		if ifExists {
+			return conn.WriteResult(0, 0)
+		}
+		return &Error{Code: UndefinedTable, Table: table, Message: fmt.Sprintf("relation '%s' not found", table)}
+	}
+	if name := e.referencedBy(table); name != "" {
+		return &Error{Code: DependentObjectsStillExist, Table: name, Message: fmt.Sprintf("cannot drop table %s because foreign key constraint of table %s depends on it", table, name)}
 	}
 
 	e.drop(table)
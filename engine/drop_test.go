@@ -27,3 +27,91 @@ func TestDrop(t *testing.T) {
 		t.Fatalf("cannot drop table: %s", err)
 	}
 }
+
+func TestDropIfExists(t *testing.T) {
+	log.UseTestLogger(t)
+	db, err := sql.Open("ramsql", "TestDropIfExists")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DROP TABLE account")
+	if err == nil {
+		t.Fatalf("expected error dropping missing table")
+	}
+
+	batch := []string{
+		`DROP TABLE IF EXISTS account`,
+		`DROP INDEX IF EXISTS idx_account_email`,
+		`CREATE TABLE account (id INT, email TEXT)`,
+		`CREATE INDEX idx_account_email ON account (email)`,
+		`DROP INDEX IF EXISTS idx_account_email`,
+		`DROP TABLE IF EXISTS account`,
+		`DROP TABLE IF EXISTS account`,
+		`CREATE TABLE account (id INT, email TEXT)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+}
+
+func TestCreateTableIfNotExists(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCreateTableIfNotExists")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	// Same script runs twice
+	script := []string{
+		`CREATE TABLE IF NOT EXISTS account (id BIGSERIAL, email TEXT)`,
+		`INSERT INTO account (email) VALUES ('foo@bar.com')`,
+	}
+	for i := 0; i < 2; i++ {
+		for _, q := range script {
+			_, err = db.Exec(q)
+			if err != nil {
+				t.Fatalf("sql.Exec '%s': %s", q, err)
+			}
+		}
+	}
+
+	_, err = db.Exec(`CREATE TABLE account (id BIGSERIAL, email TEXT)`)
+	if err == nil {
+		t.Fatalf("expected error creating existing table")
+	}
+
+	// Existing table is left as is, even with another definition
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS account (id BIGSERIAL, name TEXT, age INT)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+
+	rows, err := db.Query(`SELECT * FROM account`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("rows.Columns: %s", err)
+	}
+	if len(columns) != 2 || columns[1] != "email" {
+		t.Fatalf("expected table to be left unchanged, got columns %v", columns)
+	}
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows, got %d", n)
+	}
+}
@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+func init() {
+	// Values computed by expressions may be stored as they are
+	gob.Register(time.Time{})
+}
+
+// snapshot is the content of a database, as written by Dump
+type snapshot struct {
+	Tables    []tableSnapshot
+	Views     []viewSnapshot
+	Sequences []sequenceSnapshot
+}
+
+type tableSnapshot struct {
+	Name        string
+	Attributes  []attributeSnapshot
+	Unique      []constraintSnapshot
+	ForeignKeys []foreignKeySnapshot
+	Checks      []checkSnapshot
+	Indexes     []constraintSnapshot
+	Triggers    []triggerSnapshot
+	Rows        [][]interface{}
+}
+
+type attributeSnapshot struct {
+	Name          string
+	TypeName      string
+	Precision     int
+	Scale         int
+	Enum          []string
+	Default       *parser.Decl
+	OnUpdate      *parser.Decl
+	AutoIncrement bool
+	Sequence      int64
+	Unique        bool
+	NotNull       bool
+	PrimaryKey    bool
+}
+
+type constraintSnapshot struct {
+	Name       string
+	Attributes []string
+}
+
+type foreignKeySnapshot struct {
+	Name       string
+	Attributes []string
+	Table      string
+	Referenced []string
+	OnDelete   string
+}
+
+type checkSnapshot struct {
+	Name      string
+	Table     string
+	Condition *parser.Decl
+}
+
+// triggerSnapshot is a trigger, as the CREATE TRIGGER statement re-creating it
+type triggerSnapshot struct {
+	Name      string
+	Statement string
+}
+
+type viewSnapshot struct {
+	Name  string
+	Query *parser.Decl
+}
+
+type sequenceSnapshot struct {
+	Name      string
+	Last      int64
+	Increment int64
+}
+
+// Dump writes schemas, triggers and rows of every table, views and sequences, to w, so that
+// they can be restored with Load. It can be called while statements are executed.
+func (e *Engine) Dump(w io.Writer) error {
+	if err := gob.NewEncoder(w).Encode(e.snapshot()); err != nil {
+		return fmt.Errorf("cannot dump database: %s", err)
+	}
+	return nil
+}
+
+// snapshot returns the content of the database, tables, views and sequences being sorted by name
+func (e *Engine) snapshot() snapshot {
+	e.statements.RLock()
+	defer e.statements.RUnlock()
+
+	e.Lock()
+	relations := make([]*Relation, 0, len(e.relations))
+	for _, r := range e.relations {
+		relations = append(relations, r)
+	}
+	s := snapshot{}
+	for name, v := range e.views {
+		s.Views = append(s.Views, viewSnapshot{Name: name, Query: v})
+	}
+	for name, seq := range e.sequences {
+		seq.Lock()
+		s.Sequences = append(s.Sequences, sequenceSnapshot{Name: name, Last: seq.last, Increment: seq.increment})
+		seq.Unlock()
+	}
+	e.Unlock()
+
+	sort.Slice(relations, func(i, j int) bool { return relations[i].table.name < relations[j].table.name })
+	sort.Slice(s.Views, func(i, j int) bool { return s.Views[i].Name < s.Views[j].Name })
+	sort.Slice(s.Sequences, func(i, j int) bool { return s.Sequences[i].Name < s.Sequences[j].Name })
+
+	for _, r := range relations {
+		r.RLock()
+		s.Tables = append(s.Tables, r.snapshot())
+		r.RUnlock()
+	}
+
+	return s
+}
+
+// Load replaces every table, view and sequence by the ones read from r, as written by Dump.
+// It waits for statements in flight to be done, and statements wait until it is.
+func (e *Engine) Load(r io.Reader) error {
+	s := snapshot{}
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("cannot load database: %s", err)
+	}
+
+	relations := make(map[string]*Relation)
+	for _, t := range s.Tables {
+		rel, err := t.relation()
+		if err != nil {
+			return err
+		}
+		relations[t.Name] = rel
+	}
+
+	// Relations are changed in place, since sessions share them
+	e.statements.Lock()
+	defer e.statements.Unlock()
+	e.Lock()
+	defer e.Unlock()
+	for name := range e.relations {
+		delete(e.relations, name)
+	}
+	for name := range e.indexes {
+		delete(e.indexes, name)
+	}
+	for name := range e.views {
+		delete(e.views, name)
+	}
+	for name := range e.sequences {
+		delete(e.sequences, name)
+	}
+	for name, rel := range relations {
+		e.relations[name] = rel
+		for _, i := range rel.indexes {
+			e.indexes[i.name] = rel
+		}
+	}
+	for _, v := range s.Views {
+		e.views[v.Name] = v.Query
+	}
+	for _, seq := range s.Sequences {
+		e.sequences[seq.Name] = &sequence{last: seq.Last, increment: seq.Increment}
+	}
+
+	return nil
+}
+
+// snapshot returns schema and rows of relation, which must be read locked
+func (r *Relation) snapshot() tableSnapshot {
+	t := tableSnapshot{Name: r.table.name}
+
+	for _, a := range r.table.attributes {
+		as := attributeSnapshot{
+			Name:          a.name,
+			TypeName:      a.typeName,
+			Precision:     a.precision,
+			Scale:         a.scale,
+			Enum:          a.enum,
+			Default:       a.defaultDecl,
+			OnUpdate:      a.onUpdateDecl,
+			AutoIncrement: a.autoIncrement,
+			Unique:        a.unique,
+			NotNull:       a.notNull,
+			PrimaryKey:    a.primaryKey,
+		}
+		if a.sequence != nil {
+			as.Sequence = a.sequence.last
+		}
+		t.Attributes = append(t.Attributes, as)
+	}
+
+	for _, u := range r.table.unique {
+		t.Unique = append(t.Unique, constraintSnapshot{Name: u.name, Attributes: u.attributes})
+	}
+	for _, fk := range r.table.foreignKeys {
+		t.ForeignKeys = append(t.ForeignKeys, foreignKeySnapshot{Name: fk.name, Attributes: fk.attributes, Table: fk.table, Referenced: fk.referenced, OnDelete: fk.onDelete})
+	}
+	for _, c := range r.table.checks {
+		t.Checks = append(t.Checks, checkSnapshot{Name: c.name, Table: c.table, Condition: c.condition})
+	}
+	for _, i := range r.indexes {
+		t.Indexes = append(t.Indexes, constraintSnapshot{Name: i.name, Attributes: i.attributes})
+	}
+	for _, tr := range r.table.triggers {
+		t.Triggers = append(t.Triggers, triggerSnapshot{Name: tr.name, Statement: tr.statement(r.table.name)})
+	}
+	for _, row := range r.rows {
+		t.Rows = append(t.Rows, row.Values)
+	}
+
+	return t
+}
+
+// relation returns a new relation with schema and rows of snapshot
+func (s tableSnapshot) relation() (*Relation, error) {
+	t := NewTable(s.Name)
+
+	for _, as := range s.Attributes {
+		a := NewAttribute(as.Name, as.TypeName, as.AutoIncrement)
+		a.enum = as.Enum
+		if as.Default != nil {
+			if err := a.setDefault(as.Default); err != nil {
+				return nil, err
+			}
+		}
+		if as.OnUpdate != nil {
+			if err := a.setOnUpdate(as.OnUpdate); err != nil {
+				return nil, err
+			}
+		}
+		if a.sequence != nil {
+			a.sequence.advance(as.Sequence)
+		}
+		a.precision = as.Precision
+		a.scale = as.Scale
+		a.unique = as.Unique
+		a.notNull = as.NotNull
+		a.primaryKey = as.PrimaryKey
+		t.attributes = append(t.attributes, a)
+	}
+
+	for _, u := range s.Unique {
+		t.unique = append(t.unique, uniqueConstraint{name: u.Name, attributes: u.Attributes})
+	}
+	for _, fk := range s.ForeignKeys {
+		t.foreignKeys = append(t.foreignKeys, foreignKey{name: fk.Name, attributes: fk.Attributes, table: fk.Table, referenced: fk.Referenced, onDelete: fk.OnDelete})
+	}
+	for _, c := range s.Checks {
+		t.checks = append(t.checks, checkConstraint{name: c.Name, table: c.Table, condition: c.Condition})
+	}
+	for _, ts := range s.Triggers {
+		tr, err := parseTrigger(ts.Statement)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load trigger %s of table %s: %s", ts.Name, s.Name, err)
+		}
+		t.triggers = append(t.triggers, tr)
+	}
+
+	r := NewRelation(t)
+	for _, values := range s.Rows {
+		if len(values) != len(t.attributes) {
+			return nil, fmt.Errorf("cannot load table %s: row has %d values for %d attributes", s.Name, len(values), len(t.attributes))
+		}
+		r.rows = append(r.rows, &Tuple{Values: values})
+	}
+
+	for _, is := range s.Indexes {
+		i := &index{name: is.Name, attributes: is.Attributes}
+		i.build(t, r.rows)
+		r.indexes = append(r.indexes, i)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// DumpSQL writes CREATE SEQUENCE, CREATE TABLE, INSERT, CREATE INDEX and CREATE TRIGGER statements
+// reproducing every sequence and table to w, so that they can be executed again by ramsql or PostgreSQL.
+// Sequences and tables are sorted by name, tables referenced by foreign keys coming first, and
+// rows are in insertion order. Triggers of a table come after its rows, so that they do not fire
+// when rows are inserted again, and are only understood by ramsql. Views and CHECK constraints are not written, since their query
+// and condition text is not kept.
+func (e *Engine) DumpSQL(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	s := e.snapshot()
+
+	for _, seq := range s.Sequences {
+		fmt.Fprintf(b, "CREATE SEQUENCE %s START WITH %d INCREMENT BY %d;\n", quoteIdentifier(seq.Name), seq.Last+seq.Increment, seq.Increment)
+	}
+	if len(s.Sequences) > 0 {
+		fmt.Fprintln(b)
+	}
+
+	for _, t := range referencedFirst(s.Tables) {
+		fmt.Fprintf(b, "CREATE TABLE %s (\n", quoteIdentifier(t.Name))
+		var definitions []string
+		var primaryKey []string
+		for _, a := range t.Attributes {
+			definitions = append(definitions, "\t"+a.definition())
+			if a.PrimaryKey {
+				primaryKey = append(primaryKey, quoteIdentifier(a.Name))
+			}
+		}
+		if len(primaryKey) > 0 {
+			definitions = append(definitions, "\tPRIMARY KEY ("+strings.Join(primaryKey, ", ")+")")
+		}
+		for _, u := range t.tableConstraints() {
+			definitions = append(definitions, "\tUNIQUE ("+quoteIdentifiers(u.Attributes)+")")
+		}
+		for _, fk := range t.ForeignKeys {
+			definition := fmt.Sprintf("\tFOREIGN KEY (%s) REFERENCES %s (%s)", quoteIdentifiers(fk.Attributes), quoteIdentifier(fk.Table), quoteIdentifiers(fk.Referenced))
+			if fk.OnDelete != "" {
+				definition += " ON DELETE " + strings.ToUpper(fk.OnDelete)
+			}
+			definitions = append(definitions, definition)
+		}
+		fmt.Fprintf(b, "%s\n);\n", strings.Join(definitions, ",\n"))
+
+		var names []string
+		for _, a := range t.Attributes {
+			names = append(names, a.Name)
+		}
+		for _, row := range t.Rows {
+			values := make([]string, len(row))
+			for i, v := range row {
+				values[i] = t.Attributes[i].literal(v)
+			}
+			fmt.Fprintf(b, "INSERT INTO %s (%s) VALUES (%s);\n", quoteIdentifier(t.Name), quoteIdentifiers(names), strings.Join(values, ", "))
+		}
+
+		for _, i := range t.Indexes {
+			create := "CREATE INDEX"
+			if t.isUnique(i.Name) {
+				create = "CREATE UNIQUE INDEX"
+			}
+			fmt.Fprintf(b, "%s %s ON %s (%s);\n", create, quoteIdentifier(i.Name), quoteIdentifier(t.Name), quoteIdentifiers(i.Attributes))
+		}
+		for _, tr := range t.Triggers {
+			fmt.Fprintf(b, "%s;\n", tr.Statement)
+		}
+		fmt.Fprintln(b)
+	}
+
+	return b.Flush()
+}
+
+// definition returns the attribute as declared in CREATE TABLE
+func (a attributeSnapshot) definition() string {
+	def := quoteIdentifier(a.Name) + " " + strings.ToUpper(a.TypeName)
+	if a.Precision > 0 {
+		def += fmt.Sprintf("(%d,%d)", a.Precision, a.Scale)
+	}
+	if len(a.Enum) > 0 {
+		var values []string
+		for _, v := range a.Enum {
+			values = append(values, a.literal(v))
+		}
+		def += "(" + strings.Join(values, ", ") + ")"
+	}
+
+	switch strings.ToLower(a.TypeName) {
+	case "smallserial", "serial", "bigserial":
+	default:
+		if a.AutoIncrement {
+			def += " AUTOINCREMENT"
+		}
+		if a.NotNull {
+			def += " NOT NULL"
+		}
+	}
+	if a.Unique {
+		def += " UNIQUE"
+	}
+	if a.Default != nil && a.Default.Token != parser.NullToken {
+		def += " DEFAULT " + declText(a.Default)
+	}
+	if a.OnUpdate != nil {
+		def += " ON UPDATE " + declText(a.OnUpdate)
+	}
+
+	return def
+}
+
+// literal returns the SQL literal of value v of attribute. Timestamps are written in
+// parser.DateLongFormat, as they are stored when inserted, binary strings in hexadecimal,
+// and strings are quoted.
+func (a attributeSnapshot) literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int64, int, float64:
+		return fmt.Sprintf("%v", v)
+	case bool:
+		return strings.ToUpper(fmt.Sprintf("%v", v))
+	case time.Time:
+		return "'" + v.Format(parser.DateLongFormat) + "'"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	}
+
+	s := fmt.Sprintf("%v", v)
+	switch strings.ToLower(a.TypeName) {
+	case "timestamp", "timestamptz", "datetime":
+		if t, err := parser.ParseDate(s); err == nil {
+			s = t.Format(parser.DateLongFormat)
+		}
+	case "bytea", "blob":
+		return "X'" + hex.EncodeToString([]byte(s)) + "'"
+	}
+
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// tableConstraints returns UNIQUE constraints of table which are not written
+// with an attribute, a primary key or a unique index
+func (s tableSnapshot) tableConstraints() []constraintSnapshot {
+	var constraints []constraintSnapshot
+
+	for _, u := range s.Unique {
+		if u.Name == s.Name+"_pkey" {
+			continue
+		}
+		indexed := false
+		for _, i := range s.Indexes {
+			if i.Name == u.Name {
+				indexed = true
+			}
+		}
+		if indexed {
+			continue
+		}
+		if len(u.Attributes) == 1 && u.Name == s.Name+"_"+u.Attributes[0]+"_key" && s.attribute(u.Attributes[0]).Unique {
+			continue
+		}
+		constraints = append(constraints, u)
+	}
+
+	return constraints
+}
+
+// referencedFirst returns tables sorted so that a table comes after the ones its foreign keys
+// reference, keeping their order otherwise. Tables referencing a missing one, which may have
+// been dropped while foreign keys were not enforced, come last.
+func referencedFirst(tables []tableSnapshot) []tableSnapshot {
+	var sorted []tableSnapshot
+	written := make(map[string]bool)
+
+	for len(sorted) < len(tables) {
+		next := -1
+		for i, t := range tables {
+			if written[t.Name] {
+				continue
+			}
+			if next < 0 {
+				next = i
+			}
+			ready := true
+			for _, fk := range t.ForeignKeys {
+				if fk.Table != t.Name && !written[fk.Table] {
+					ready = false
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		sorted = append(sorted, tables[next])
+		written[tables[next].Name] = true
+	}
+
+	return sorted
+}
+
+// isUnique returns true if named index is a unique one
+func (s tableSnapshot) isUnique(name string) bool {
+	for _, u := range s.Unique {
+		if u.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attribute returns named attribute of table
+func (s tableSnapshot) attribute(name string) attributeSnapshot {
+	for _, a := range s.Attributes {
+		if a.Name == name {
+			return a
+		}
+	}
+
+	return attributeSnapshot{}
+}
+
+// quoteIdentifier returns name quoted, so it may be a keyword
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteIdentifiers returns names quoted and separated with commas
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quoteIdentifier(n)
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -1,10 +1,12 @@
 package engine
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/proullon/ramsql/engine/log"
 	"github.com/proullon/ramsql/engine/parser"
@@ -17,40 +19,96 @@ type executor func(*Engine, *parser.Decl, protocol.EngineConn) error
 type Engine struct {
 	endpoint     protocol.EngineEndpoint
 	relations    map[string]*Relation
+	indexes      map[string]*Relation
+	views        map[string]*parser.Decl
+	sequences    map[string]*sequence
 	opsExecutors map[int]executor
 
+	// foreignKeys is set if FOREIGN KEY constraints are enforced
+	foreignKeys bool
+	// random generates values of RANDOM(), shared by sessions
+	random *randomSource
+	// statementTimeout aborts statements running longer, if not zero
+	statementTimeout time.Duration
+	// statements is locked by each statement executed, in the mode given by lockMode,
+	// and writeLocks by the statements writing a shared relation, for the tables they use
+	statements *sync.RWMutex
+	writeLocks *writeLocks
+	// tableLocks are the tables locked by transactions until they end
+	tableLocks *tableLocks
+
 	// Any value send to this channel (through Engine.stop)
 	// Will stop the listening loop
 	stop chan bool
 
-	sync.Mutex
+	// Transaction opened by the connection of a session, if any
+	tx *transaction
+	// defaultReadOnly is set if transactions of a session, and statements executed
+	// outside of one, cannot write
+	defaultReadOnly bool
+	// Context of the statement executed by a session
+	ctx context.Context
+	// Time at which the statement executed by a session times out, if any
+	deadline time.Time
+	// Working tables of the recursive queries evaluated by a session, by reference
+	workTables map[*parser.Decl]*resultConn
+	// Last values returned by nextval in a session, by sequence name
+	currvals map[string]int64
+
+	*sync.Mutex
 }
 
 // New initialize a new RamSQL server
 func New(endpoint protocol.EngineEndpoint) (e *Engine, err error) {
 
 	e = &Engine{
-		endpoint: endpoint,
+		endpoint:    endpoint,
+		foreignKeys: true,
+		random:      newRandomSource(time.Now().UnixNano()),
+		Mutex:       new(sync.Mutex),
+		statements:  new(sync.RWMutex),
+		writeLocks:  newWriteLocks(),
+		tableLocks:  newTableLocks(),
 	}
 
 	e.stop = make(chan bool)
 
 	e.opsExecutors = map[int]executor{
-		parser.CreateToken:   createExecutor,
-		parser.TableToken:    createTableExecutor,
-		parser.SelectToken:   selectExecutor,
-		parser.InsertToken:   insertIntoTableExecutor,
-		parser.DeleteToken:   deleteExecutor,
-		parser.UpdateToken:   updateExecutor,
-		parser.IfToken:       ifExecutor,
-		parser.NotToken:      notExecutor,
-		parser.ExistsToken:   existsExecutor,
-		parser.TruncateToken: truncateExecutor,
-		parser.DropToken:     dropExecutor,
-		parser.GrantToken:    grantExecutor,
+		parser.CreateToken:    createExecutor,
+		parser.TableToken:     createTableExecutor,
+		parser.SelectToken:    selectExecutor,
+		parser.UnionToken:     selectExecutor,
+		parser.IntersectToken: selectExecutor,
+		parser.ExceptToken:    selectExecutor,
+		parser.InsertToken:    insertIntoTableExecutor,
+		parser.DeleteToken:    deleteExecutor,
+		parser.UpdateToken:    updateExecutor,
+		parser.IfToken:        ifExecutor,
+		parser.NotToken:       notExecutor,
+		parser.ExistsToken:    existsExecutor,
+		parser.TruncateToken:  truncateExecutor,
+		parser.DropToken:      dropExecutor,
+		parser.GrantToken:     grantExecutor,
+		parser.AlterToken:     alterExecutor,
+		parser.IndexToken:     createIndexExecutor,
+		parser.ViewToken:      createViewExecutor,
+		parser.SequenceToken:  createSequenceExecutor,
+		parser.TriggerToken:   createTriggerExecutor,
+		parser.BeginToken:     beginExecutor,
+		parser.CommitToken:    commitExecutor,
+		parser.RollbackToken:  rollbackExecutor,
+		parser.SavepointToken: savepointExecutor,
+		parser.ReleaseToken:   releaseExecutor,
+		parser.SetToken:       setSessionExecutor,
+		parser.ShowToken:      showExecutor,
+		parser.ExplainToken:   explainExecutor,
 	}
 
 	e.relations = make(map[string]*Relation)
+	e.indexes = make(map[string]*Relation)
+	e.views = make(map[string]*parser.Decl)
+	e.sequences = make(map[string]*sequence)
+	e.currvals = make(map[string]int64)
 
 	err = e.start()
 	if err != nil {
@@ -78,17 +136,80 @@ func (e *Engine) Stop() {
 	}()
 }
 
+// session returns an engine sharing relations of e, executing statements of a single
+// connection so that its transaction is only seen by it
+func (e *Engine) session() *Engine {
+	return &Engine{
+		relations:    e.relations,
+		indexes:      e.indexes,
+		views:        e.views,
+		sequences:    e.sequences,
+		opsExecutors: e.opsExecutors,
+		foreignKeys:  e.foreignKeys,
+		random:       e.random,
+		currvals:     make(map[string]int64),
+		Mutex:        e.Mutex,
+		statements:   e.statements,
+		writeLocks:   e.writeLocks,
+		tableLocks:   e.tableLocks,
+
+		statementTimeout: e.statementTimeout,
+	}
+}
+
+// SetForeignKeys enables or disables checks of FOREIGN KEY constraints, which are
+// enforced by default. It must be called before any connection is opened.
+func (e *Engine) SetForeignKeys(enforced bool) {
+	e.foreignKeys = enforced
+}
+
+// SetRandomSeed seeds the generator of RANDOM() values, so that the same seed yields
+// the same sequence. It must be called before any connection is opened.
+func (e *Engine) SetRandomSeed(seed int64) {
+	e.random = newRandomSource(seed)
+}
+
+// SetStatementTimeout aborts any statement running longer than d, a zero duration meaning
+// no limit, which is the default. It must be called before any connection is opened.
+func (e *Engine) SetStatementTimeout(d time.Duration) {
+	e.statementTimeout = d
+}
+
+// canceled returns an error once client does not wait for the result of statement anymore,
+// or once it timed out, so executors stop scanning rows
+func (e *Engine) canceled() error {
+	if !e.deadline.IsZero() && time.Now().After(e.deadline) {
+		return errorf(QueryCanceled, "canceling statement due to statement timeout")
+	}
+	if e.ctx == nil {
+		return nil
+	}
+
+	return e.ctx.Err()
+}
+
 func (e *Engine) relation(name string) *Relation {
-	// Lock ?
+	e.Lock()
 	r := e.relations[name]
-	// Unlock ?
+	e.Unlock()
+
+	// Within a transaction, relation is its own copy
+	if r != nil && e.tx != nil {
+		return e.tx.relation(r)
+	}
 
 	return r
 }
 
 func (e *Engine) drop(name string) {
 	e.Lock()
+	r := e.relations[name]
 	delete(e.relations, name)
+	for i, ir := range e.indexes {
+		if ir == r {
+			delete(e.indexes, i)
+		}
+	}
 	e.Unlock()
 }
 
@@ -110,7 +231,7 @@ func (e *Engine) listen() {
 	for {
 		select {
 		case conn := <-newConnectionChannel:
-			go e.handleConnection(conn)
+			go e.session().handleConnection(conn)
 			break
 
 		case <-e.stop:
@@ -122,6 +243,8 @@ func (e *Engine) listen() {
 }
 
 func (e *Engine) handleConnection(conn protocol.EngineConn) {
+	// Transaction left open by connection is rolled back
+	defer e.rollback()
 
 	for {
 		stmt, err := conn.ReadStatement()
@@ -134,15 +257,24 @@ func (e *Engine) handleConnection(conn protocol.EngineConn) {
 			return
 		}
 
+		e.ctx = context.Background()
+		if c, ok := conn.(protocol.ContextEngineConn); ok {
+			e.ctx = c.Context()
+		}
+		e.deadline = time.Time{}
+		if e.statementTimeout > 0 {
+			e.deadline = time.Now().Add(e.statementTimeout)
+		}
+
 		instructions, err := parser.ParseInstruction(stmt)
 		if err != nil {
-			conn.WriteError(err)
+			conn.WriteError(syntaxError(err))
 			continue
 		}
 
 		err = e.executeQueries(instructions, conn)
 		if err != nil {
-			conn.WriteError(err)
+			conn.WriteError(asError(err))
 			continue
 		}
 	}
@@ -168,6 +300,36 @@ func (e *Engine) executeQueries(instructions []parser.Instruction, conn protocol
 
 func (e *Engine) executeQuery(i parser.Instruction, conn protocol.EngineConn) error {
 
+	if err := e.checkWritable(i.Decls[0]); err != nil {
+		return err
+	}
+
+	// Once aborted, transaction can only be ended
+	if e.tx != nil && e.tx.aborted && !isEnd(i.Decls[0]) {
+		return errorf(InFailedSQLTransaction, "current transaction is aborted, commands ignored until end of transaction block")
+	}
+
+	// Table written is locked until transaction ends, before statement is executed since
+	// it may wait for another transaction
+	if table := writtenTable(i.Decls[0]); table != "" && e.tx != nil {
+		if err := e.tableLocks.lock(e, table); err != nil {
+			if asError(err).Code == DeadlockDetected {
+				e.abort()
+			}
+			return err
+		}
+	}
+
+	unlock := e.lockStatement(i.Decls[0])
+	defer unlock()
+
+	// Schema changes are not transactional, they commit current transaction first
+	if e.tx != nil && isSchemaChange(i.Decls[0]) {
+		if err := e.commit(); err != nil {
+			return err
+		}
+	}
+
 	if e.opsExecutors[i.Decls[0].Token] != nil {
 		return e.opsExecutors[i.Decls[0].Token](e, i.Decls[0], conn)
 	}
@@ -1,8 +1,13 @@
 package engine
 
 import (
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/proullon/ramsql/engine/log"
+	"github.com/proullon/ramsql/engine/parser"
 	"github.com/proullon/ramsql/engine/protocol"
 )
 
@@ -25,7 +30,7 @@ func (conn *TestEngineConn) WriteRowHeader(header []string) error {
 	return nil
 }
 
-func (conn *TestEngineConn) WriteRow(row []string) error {
+func (conn *TestEngineConn) WriteRow(row []*string) error {
 	return nil
 }
 
@@ -47,3 +52,92 @@ func TestNewEngine(t *testing.T) {
 	e := testEngine(t)
 	e.Stop()
 }
+
+func TestWriteLocks(t *testing.T) {
+	log.UseTestLogger(t)
+
+	e := testEngine(t)
+	defer e.Stop()
+
+	exec := func(query string) error {
+		i, err := parser.ParseInstruction(query)
+		if err != nil {
+			return err
+		}
+		return e.session().executeQuery(i[0], &TestEngineConn{})
+	}
+
+	batch := []string{
+		`CREATE TABLE account (id INT PRIMARY KEY, email TEXT)`,
+		`CREATE TABLE address (id INT, account_id INT REFERENCES account (id), street TEXT)`,
+		`CREATE TABLE visit (id INT, email TEXT)`,
+		`CREATE VIEW emails AS SELECT email FROM account`,
+	}
+	for _, b := range batch {
+		if err := exec(b); err != nil {
+			t.Fatalf("Cannot execute query %s: %s", b, err)
+		}
+	}
+
+	// Tables read are the ones named, through views as well, and the ones checked by foreign keys
+	expected := map[string]string{
+		`INSERT INTO visit (id, email) VALUES (1, 'foo@bar.com')`:           "",
+		`INSERT INTO visit (id, email) SELECT id, email FROM account`:       "account",
+		`UPDATE visit SET id = 2 WHERE email IN (SELECT email FROM emails)`: "account emails",
+		`INSERT INTO address (id, account_id) VALUES (1, 1)`:                "account",
+		`DELETE FROM account WHERE id = 1`:                                  "address",
+		`UPDATE visit SET email = account.email FROM account WHERE id = 3`:  "account",
+	}
+	for query, tables := range expected {
+		i, err := parser.ParseInstruction(query)
+		if err != nil {
+			t.Fatalf("Cannot parse query %s: %s", query, err)
+		}
+		read := e.readTables(i[0].Decls[0])
+		sort.Strings(read)
+		if strings.Join(read, " ") != tables {
+			t.Fatalf("%s: expected tables %s to be read, got %v", query, tables, read)
+		}
+	}
+
+	// Statements writing a table wait for the ones writing or reading it, and only them
+	unlock := e.writeLocks.lock("account", nil)
+	done := make(chan error)
+	go func() {
+		done <- exec(`INSERT INTO visit (id, email) VALUES (1, 'foo@bar.com')`)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Cannot insert visit: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Writing a table waited for another table to be written")
+	}
+
+	for _, query := range []string{
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`INSERT INTO visit (id, email) SELECT id, email FROM account`,
+	} {
+		go func(query string) {
+			done <- exec(query)
+		}(query)
+	}
+	select {
+	case <-done:
+		t.Fatalf("Statement did not wait for written table")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	for n := 0; n < 2; n++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Cannot execute statement: %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Statement still waits for unlocked table")
+		}
+	}
+}
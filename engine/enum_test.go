@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestEnum(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestEnum")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE tickets (id BIGSERIAL, status ENUM('open', 'closed', 'archived') DEFAULT 'open', priority ENUM('low', 'high'))`,
+		`INSERT INTO tickets (status, priority) VALUES ('closed', 'low')`,
+		`INSERT INTO tickets (priority) VALUES ('high')`,
+		`INSERT INTO tickets (status) VALUES ('archived')`,
+		`INSERT INTO tickets (status, priority) VALUES ('open', NULL)`,
+		`UPDATE tickets SET priority = 'low' WHERE id = 3`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	// Values are compared and sorted like text
+	queries := map[string][]string{
+		`SELECT id, status FROM tickets ORDER BY status, id`:                                                    {"3 archived", "1 closed", "2 open", "4 open"},
+		`SELECT id FROM tickets WHERE status = 'open' ORDER BY id`:                                              {"2", "4"},
+		`SELECT id FROM tickets WHERE status IN ('closed', 'archived') ORDER BY id`:                             {"1", "3"},
+		`SELECT priority, COUNT(*) FROM tickets WHERE priority IS NOT NULL GROUP BY priority ORDER BY priority`: {"high 1", "low 2"},
+	}
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		columns, _ := rows.Columns()
+		var res []string
+		for rows.Next() {
+			values := make([]string, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			res = append(res, strings.Join(values, " "))
+		}
+		rows.Close()
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+	}
+
+	// Values out of the allowed set are rejected
+	_, err = db.Exec(`INSERT INTO tickets (status) VALUES ('pending')`)
+	if err == nil || !strings.Contains(err.Error(), `invalid input value for enum status: "pending"`) {
+		t.Fatalf("expected invalid enum value error, got %v", err)
+	}
+	failing := []string{
+		`INSERT INTO tickets (status) VALUES ('Open')`,
+		`UPDATE tickets SET priority = 'urgent' WHERE id = 1`,
+		`CREATE TABLE bad (status ENUM('a', 'b', 'a'))`,
+		`CREATE TABLE bad (status ENUM())`,
+		`CREATE TABLE bad (status ENUM('a') DEFAULT 'b')`,
+	}
+	for _, query := range failing {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	var priority string
+	if err := db.QueryRow(`SELECT priority FROM tickets WHERE id = 1`).Scan(&priority); err != nil || priority != "low" {
+		t.Fatalf("expected priority to be left unchanged, got %s (%v)", priority, err)
+	}
+}
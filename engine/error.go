@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// ErrorCode is the SQLSTATE code of an error, as defined by PostgreSQL
+type ErrorCode string
+
+// Codes of errors returned by the engine
+const (
+	InvalidTextRepresentation  ErrorCode = "22P02"
+	NotNullViolation           ErrorCode = "23502"
+	ForeignKeyViolation        ErrorCode = "23503"
+	UniqueViolation            ErrorCode = "23505"
+	CheckViolation             ErrorCode = "23514"
+	ReadOnlySQLTransaction     ErrorCode = "25006"
+	InFailedSQLTransaction     ErrorCode = "25P02"
+	DependentObjectsStillExist ErrorCode = "2BP01"
+	DeadlockDetected           ErrorCode = "40P01"
+	QueryCanceled              ErrorCode = "57014"
+	SyntaxError                ErrorCode = "42601"
+	DatatypeMismatch           ErrorCode = "42804"
+	DuplicateColumn            ErrorCode = "42701"
+	AmbiguousColumn            ErrorCode = "42702"
+	UndefinedColumn            ErrorCode = "42703"
+	UndefinedObject            ErrorCode = "42704"
+	DuplicateObject            ErrorCode = "42710"
+	UndefinedFunction          ErrorCode = "42883"
+	UndefinedTable             ErrorCode = "42P01"
+	DuplicateTable             ErrorCode = "42P07"
+	InternalError              ErrorCode = "XX000"
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	InvalidTextRepresentation:  "invalid_text_representation",
+	NotNullViolation:           "not_null_violation",
+	ForeignKeyViolation:        "foreign_key_violation",
+	UniqueViolation:            "unique_violation",
+	CheckViolation:             "check_violation",
+	ReadOnlySQLTransaction:     "read_only_sql_transaction",
+	InFailedSQLTransaction:     "in_failed_sql_transaction",
+	DependentObjectsStillExist: "dependent_objects_still_exist",
+	DeadlockDetected:           "deadlock_detected",
+	QueryCanceled:              "query_canceled",
+	SyntaxError:                "syntax_error",
+	DatatypeMismatch:           "datatype_mismatch",
+	DuplicateColumn:            "duplicate_column",
+	AmbiguousColumn:            "ambiguous_column",
+	UndefinedColumn:            "undefined_column",
+	UndefinedObject:            "undefined_object",
+	DuplicateObject:            "duplicate_object",
+	UndefinedFunction:          "undefined_function",
+	UndefinedTable:             "undefined_table",
+	DuplicateTable:             "duplicate_table",
+	InternalError:              "internal_error",
+}
+
+// Name returns the condition name of code, like unique_violation
+func (c ErrorCode) Name() string {
+	return errorCodeNames[c]
+}
+
+// Error is an error of a statement, like pq.Error. Its code classifies it, and it names
+// the table, column and constraint at fault when they are known. Errors not classified
+// more precisely have code InternalError.
+type Error struct {
+	Code       ErrorCode
+	Message    string
+	Table      string
+	Column     string
+	Constraint string
+	// Position of a syntax error in statement, in characters counting from 1,
+	// and its line and column in statement text, or 0 if unknown
+	Position       int
+	Line           int
+	ColumnPosition int
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// errorf returns an Error of given code, with a formatted message
+func errorf(code ErrorCode, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// syntaxError returns a parsing error as an Error, located in statement if it can be
+func syntaxError(err error) *Error {
+	e := &Error{Code: SyntaxError, Message: err.Error()}
+
+	var se *parser.SyntaxError
+	if errors.As(err, &se) {
+		e.Position, e.Line, e.ColumnPosition = se.Position, se.Line, se.Column
+	}
+
+	return e
+}
+
+// asError returns err if it is an Error, or err as an internal Error otherwise
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	return &Error{Code: InternalError, Message: err.Error()}
+}
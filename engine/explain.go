@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// plan is a stage of query execution, fed by its children
+type plan struct {
+	node     string
+	children []*plan
+}
+
+// lines returns the description of plan and its children, one stage per line
+func (p *plan) lines(depth int) []string {
+	line := p.node
+	if depth > 0 {
+		line = strings.Repeat("   ", depth-1) + "-> " + p.node
+	}
+
+	lines := []string{line}
+	for _, c := range p.children {
+		lines = append(lines, c.lines(depth+1)...)
+	}
+	return lines
+}
+
+// on returns a new stage fed by p
+func (p *plan) on(node string) *plan {
+	return &plan{node: node, children: []*plan{p}}
+}
+
+/*
+|-> explain
+	|-> select
+		|-> *
+		|-> from
+			|-> users
+*/
+// explainExecutor writes the plan of a query, one stage per row, instead of its rows.
+// Each table is scanned entirely, but the first one of FROM clause which may be scanned
+// with an index, and joined tables are combined with every row in nested loops.
+func explainExecutor(e *Engine, explainDecl *parser.Decl, conn protocol.EngineConn) error {
+	locked := make(map[*Relation]bool)
+	defer func() {
+		for r := range locked {
+			r.RUnlock()
+		}
+	}()
+
+	p, err := explainQuery(e, explainDecl.Decl[0], locked)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.WriteRowHeader([]string{"QUERY PLAN"}); err != nil {
+		return err
+	}
+	for _, l := range p.lines(0) {
+		l := l
+		if err := conn.WriteRow([]*string{&l}); err != nil {
+			return err
+		}
+	}
+
+	return conn.WriteRowEnd()
+}
+
+// explainQuery returns the plan of a SELECT statement, or of combined ones,
+// following the stages selectQueryExecutor goes through
+func explainQuery(e *Engine, decl *parser.Decl, locked map[*Relation]bool) (*plan, error) {
+	if decl.Token == parser.RecursiveToken {
+		return explainRecursive(e, decl, locked)
+	}
+	if decl.Token != parser.SelectToken {
+		return explainSetOperation(e, decl, locked)
+	}
+
+	var from *Relation
+	var fromDecl *parser.Decl
+	var tables []*Table
+	var joins []*plan
+	var predicates []PredicateLinker
+	var grouped, windowed, distinct, ordered, limited bool
+
+	for _, d := range decl.Decl {
+		switch d.Token {
+		case parser.FromToken:
+			for _, tableDecl := range d.Decl {
+				r, err := tableReferenceExecutor(e, tableDecl, tables, locked)
+				if err != nil {
+					return nil, err
+				}
+				tables = append(tables, r.table)
+				if from == nil {
+					from, fromDecl = r, tableDecl
+					continue
+				}
+				scan, err := explainScan(e, tableDecl, r, nil, locked)
+				if err != nil {
+					return nil, err
+				}
+				joins = append(joins, &plan{node: "Nested Loop", children: []*plan{scan}})
+			}
+		case parser.JoinToken:
+			r, err := tableReferenceExecutor(e, d.Decl[0], tables, locked)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, r.table)
+			scan, err := explainScan(e, d.Decl[0], r, nil, locked)
+			if err != nil {
+				return nil, err
+			}
+			node := "Nested Loop Inner Join"
+			for _, t := range d.Decl[2:] {
+				switch t.Token {
+				case parser.LeftToken:
+					node = "Nested Loop Left Join"
+				case parser.RightToken:
+					node = "Nested Loop Right Join"
+				case parser.FullToken:
+					node = "Nested Loop Full Join"
+				}
+			}
+			joins = append(joins, &plan{node: node, children: []*plan{scan}})
+		case parser.WhereToken:
+			pred, err := whereExecutor2(e, d.Decl, tables, locked)
+			if err != nil {
+				return nil, err
+			}
+			predicates = []PredicateLinker{pred}
+		case parser.GroupToken, parser.HavingToken,
+			parser.CountToken, parser.SumToken, parser.AvgToken, parser.MinToken, parser.MaxToken,
+			parser.GroupConcatToken, parser.StringAggToken:
+			grouped = true
+		case parser.DistinctToken:
+			distinct = true
+		case parser.OrderToken:
+			ordered = true
+		case parser.LimitToken, parser.OffsetToken:
+			limited = true
+		case parser.FunctionToken:
+			windowed = windowed || windowDecl(d) != nil
+		}
+	}
+
+	if from == nil {
+		return nil, fmt.Errorf("no table selected")
+	}
+
+	// Joined tables are combined in order with rows of the previous ones
+	p, err := explainScan(e, fromDecl, from, predicates, locked)
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range joins {
+		j.children = append([]*plan{p}, j.children...)
+		p = j
+	}
+
+	// Without WHERE clause, an always true predicate is given
+	if len(predicates) > 0 {
+		if pred, ok := predicates[0].(*Predicate); !ok || !pred.True {
+			p = p.on("Filter")
+		}
+	}
+	if grouped {
+		p = p.on("Aggregate")
+	}
+	if windowed {
+		p = p.on("WindowAgg")
+	}
+	if distinct {
+		p = p.on("Unique")
+	}
+	if ordered {
+		p = p.on("Sort")
+	}
+	if limited {
+		p = p.on("Limit")
+	}
+
+	return p, nil
+}
+
+// explainRecursive returns the plan of a recursive query, which recursive term scans
+// the working table filled by previous evaluation
+func explainRecursive(e *Engine, decl *parser.Decl, locked map[*Relation]bool) (*plan, error) {
+	name := decl.Decl[0].Lexeme
+	unionDecl := decl.Decl[1]
+	if unionDecl.Token != parser.UnionToken {
+		return nil, fmt.Errorf("recursive query \"%s\" does not have the form non-recursive-term UNION [ALL] recursive-term", name)
+	}
+
+	seed, err := explainQuery(e, unionDecl.Decl[0], locked)
+	if err != nil {
+		return nil, err
+	}
+
+	// Working table is empty, with columns of non recursive term
+	res, err := subqueryExecutor(e, unionDecl.Decl[0], locked)
+	if err != nil {
+		return nil, err
+	}
+	refs := workTableReferences(unionDecl.Decl[1], name)
+	e.setWorkTable(refs, &resultConn{header: res.header})
+	defer e.setWorkTable(refs, nil)
+
+	recursive, err := explainQuery(e, unionDecl.Decl[1], locked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan{node: "Recursive Union", children: []*plan{seed, recursive}}, nil
+}
+
+// explainSetOperation returns the plan of statements combined with UNION, INTERSECT or EXCEPT
+func explainSetOperation(e *Engine, decl *parser.Decl, locked map[*Relation]bool) (*plan, error) {
+	left, err := explainQuery(e, decl.Decl[0], locked)
+	if err != nil {
+		return nil, err
+	}
+	right, err := explainQuery(e, decl.Decl[1], locked)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan{node: "Union", children: []*plan{left, right}}
+	switch decl.Token {
+	case parser.IntersectToken:
+		p.node = "Intersect"
+	case parser.ExceptToken:
+		p.node = "Except"
+	}
+	var ordered, limited bool
+	for _, d := range decl.Decl[2:] {
+		switch d.Token {
+		case parser.AllToken:
+			p.node += " All"
+		case parser.OrderToken:
+			ordered = true
+		case parser.LimitToken, parser.OffsetToken:
+			limited = true
+		}
+	}
+
+	if ordered {
+		p = p.on("Sort")
+	}
+	if limited {
+		p = p.on("Limit")
+	}
+
+	return p, nil
+}
+
+// explainScan returns the plan of reading rows of table reference, with an index
+// if one can be used for predicates
+func explainScan(e *Engine, decl *parser.Decl, r *Relation, predicates []PredicateLinker, locked map[*Relation]bool) (*plan, error) {
+	name := decl.Lexeme
+	if r.table.name != name {
+		name += " " + r.table.name
+	}
+
+	switch {
+	case isQuery(decl):
+		sub, err := explainQuery(e, decl, locked)
+		if err != nil {
+			return nil, err
+		}
+		return &plan{node: "Subquery Scan on " + r.table.name, children: []*plan{sub}}, nil
+	case isWorkTable(decl):
+		return &plan{node: "WorkTable Scan on " + name}, nil
+	case isInformationSchema(decl):
+		return &plan{node: "Seq Scan on information_schema." + name}, nil
+	case e.view(decl.Lexeme) != nil:
+		sub, err := explainQuery(e, e.view(decl.Lexeme), locked)
+		if err != nil {
+			return nil, err
+		}
+		return &plan{node: "View Scan on " + name, children: []*plan{sub}}, nil
+	}
+
+	i, values := r.scanIndex(predicates)
+	if i == nil {
+		return &plan{node: "Seq Scan on " + name}, nil
+	}
+
+	var conditions []string
+	for n, a := range i.attributes {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", a, strings.Replace(values[n], "'", "''", -1)))
+	}
+	return &plan{node: fmt.Sprintf("Index Scan using %s on %s (%s)", i.name, name, strings.Join(conditions, " AND "))}, nil
+}
@@ -0,0 +1,86 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestExplain(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestExplain")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, email TEXT, country TEXT)`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id BIGINT, amount INT)`,
+		`CREATE INDEX idx_user_email ON users (email)`,
+		`INSERT INTO users (email, country) VALUES ('foo@bar.com', 'fr')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	explain := func(query string) string {
+		rows, err := db.Query(`EXPLAIN ` + query)
+		if err != nil {
+			t.Fatalf("sql.Query 'EXPLAIN %s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var lines []string
+		for rows.Next() {
+			var l string
+			if err := rows.Scan(&l); err != nil {
+				t.Fatalf("rows.Scan: %s", err)
+			}
+			lines = append(lines, l)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	expected := map[string]string{
+		`SELECT * FROM users`: "Seq Scan on users",
+		`SELECT * FROM users WHERE email = 'foo@bar.com'`: "Filter\n" +
+			"-> Index Scan using idx_user_email on users (email = 'foo@bar.com')",
+		`SELECT * FROM users WHERE email = 'foo@bar.com' OR country = 'fr'`: "Filter\n" +
+			"-> Seq Scan on users",
+		`SELECT u.country, COUNT(*) FROM users AS u JOIN orders ON orders.user_id = u.id GROUP BY u.country ORDER BY u.country LIMIT 2`: "Limit\n" +
+			"-> Sort\n" +
+			"   -> Aggregate\n" +
+			"      -> Nested Loop Inner Join\n" +
+			"         -> Seq Scan on users u\n" +
+			"         -> Seq Scan on orders",
+		`SELECT DISTINCT email FROM users LEFT JOIN orders ON orders.user_id = users.id WHERE email = 'foo@bar.com'`: "Unique\n" +
+			"-> Filter\n" +
+			"   -> Nested Loop Left Join\n" +
+			"      -> Index Scan using idx_user_email on users (email = 'foo@bar.com')\n" +
+			"      -> Seq Scan on orders",
+		`SELECT id FROM users UNION SELECT id FROM orders`: "Union\n" +
+			"-> Seq Scan on users\n" +
+			"-> Seq Scan on orders",
+	}
+	for query, want := range expected {
+		if got := explain(query); got != want {
+			t.Fatalf("expected plan of '%s' to be\n%s\ngot\n%s", query, want, got)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected explained queries to leave 1 user, got %d (%v)", n, err)
+	}
+
+	if _, err = db.Query(`EXPLAIN SELECT * FROM nope`); err == nil {
+		t.Fatalf("expected error explaining query on unknown table")
+	}
+}
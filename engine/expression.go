@@ -0,0 +1,452 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// expression is a value computed from a virtual row
+type expression interface {
+	eval(row virtualRow) (interface{}, error)
+}
+
+// selectedExpression is an expression in select list. Its value is added
+// to virtual rows under key, and its column is named name.
+type selectedExpression struct {
+	key  string
+	name string
+	expr expression
+}
+
+// constantExpression is a literal value, nil being NULL
+type constantExpression struct {
+	v interface{}
+}
+
+func (c *constantExpression) eval(row virtualRow) (interface{}, error) {
+	return c.v, nil
+}
+
+// attributeExpression is the value of an attribute in virtual row
+type attributeExpression struct {
+	key string
+}
+
+func (a *attributeExpression) eval(row virtualRow) (interface{}, error) {
+	val, ok := row[a.key]
+	if !ok {
+		return nil, fmt.Errorf("could not find attribute %s in virtual row", a.key)
+	}
+	return val.v, nil
+}
+
+// listExpression is a list of values, like BETWEEN bounds
+type listExpression struct {
+	items []expression
+}
+
+func (l *listExpression) eval(row virtualRow) (interface{}, error) {
+	var values []interface{}
+
+	for _, item := range l.items {
+		v, err := item.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// expressionExecutor returns the expression computing decl value from rows of given tables
+func expressionExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (expression, error) {
+	switch decl.Token {
+	case parser.CaseToken:
+		return caseExecutor(e, decl, tables, locked)
+	case parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken:
+		return arithmeticExecutor(e, decl, tables, locked)
+	case parser.FunctionToken:
+		return functionExecutor(e, decl, tables, locked)
+	case parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken:
+		// Current time is computed once, so it is the same for all rows of a statement
+		return &constantExpression{v: time.Now().Format(parser.DateLongFormat)}, nil
+	case parser.NullToken:
+		return &constantExpression{}, nil
+	case parser.NumberToken:
+		if i, err := strconv.ParseInt(decl.Lexeme, 10, 64); err == nil {
+			return &constantExpression{v: i}, nil
+		}
+		f, err := strconv.ParseFloat(decl.Lexeme, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", decl.Lexeme)
+		}
+		return &constantExpression{v: f}, nil
+	case parser.QuotedStringToken:
+		return &constantExpression{v: decl.Lexeme}, nil
+	case parser.TrueToken, parser.FalseToken:
+		return &constantExpression{v: decl.Token == parser.TrueToken}, nil
+	case parser.StringToken:
+		var tableName string
+		if len(decl.Decl) > 0 && decl.Decl[0].Token == parser.StringToken {
+			tableName = decl.Decl[0].Lexeme
+		}
+		t, err := resolveAttribute(decl.Lexeme, tableName, tables)
+		if err != nil {
+			return nil, err
+		}
+		return &attributeExpression{key: t.name + "." + decl.Lexeme}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %s in expression", decl.Lexeme)
+}
+
+// isExpression returns true if decl is a value computed from attributes,
+// not a mere attribute
+func isExpression(decl *parser.Decl) bool {
+	switch decl.Token {
+	case parser.CaseToken, parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken, parser.FunctionToken,
+		parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken:
+		return true
+	}
+
+	return false
+}
+
+// followingDecls returns decls added after expression ones, like
+// an operator and a value in a condition, or an ordering direction
+func followingDecls(decl *parser.Decl) []*parser.Decl {
+	switch decl.Token {
+	case parser.CaseToken, parser.FunctionToken:
+		for i, d := range decl.Decl {
+			if d.Token == parser.EndToken || d.Token == parser.BracketClosingToken {
+				return decl.Decl[i+1:]
+			}
+		}
+		return nil
+	case parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken:
+		return decl.Decl[2:]
+	}
+
+	return decl.Decl
+}
+
+/*
+|-> +
+	|-> *
+		|-> price
+		|-> quantity
+	|-> 10
+*/
+// arithmeticExecutor returns the operation computed with both operand expressions
+func arithmeticExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (*arithmeticExpression, error) {
+	if len(decl.Decl) < 2 {
+		return nil, fmt.Errorf("%s: operand not provided", decl.Lexeme)
+	}
+
+	left, err := expressionExecutor(e, decl.Decl[0], tables, locked)
+	if err != nil {
+		return nil, err
+	}
+	right, err := expressionExecutor(e, decl.Decl[1], tables, locked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &arithmeticExpression{operator: decl.Token, left: left, right: right}, nil
+}
+
+// arithmeticExpression computes an addition, a subtraction, a multiplication or a division.
+// Like in Go, result is an integer if both operands are, and a float otherwise.
+// NULL operand makes a NULL result.
+type arithmeticExpression struct {
+	operator int
+	left     expression
+	right    expression
+}
+
+func (a *arithmeticExpression) eval(row virtualRow) (interface{}, error) {
+	l, err := a.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	l, err = numericValue(l)
+	if err != nil {
+		return nil, err
+	}
+	r, err = numericValue(r)
+	if err != nil {
+		return nil, err
+	}
+
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		switch a.operator {
+		case parser.PlusToken:
+			return li + ri, nil
+		case parser.MinusToken:
+			return li - ri, nil
+		case parser.MultiplyToken:
+			return li * ri, nil
+		}
+		if ri == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return li / ri, nil
+	}
+
+	lf, _ := convToFloat(l)
+	rf, _ := convToFloat(r)
+	switch a.operator {
+	case parser.PlusToken:
+		return lf + rf, nil
+	case parser.MinusToken:
+		return lf - rf, nil
+	case parser.MultiplyToken:
+		return lf * rf, nil
+	}
+	if rf == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	return lf / rf, nil
+}
+
+// numericValue returns v as an int64 if it is an integer, or else as a float64
+func numericValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int64, float64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	}
+
+	s := fmt.Sprintf("%v", v)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("invalid number %s in arithmetic expression", s)
+}
+
+/*
+|-> case
+	|-> when
+		|-> score
+			|-> >=
+			|-> 90
+		|-> then
+			|-> A
+	|-> else
+		|-> C
+	|-> end
+*/
+// caseExecutor returns the CASE expression, either searched with a condition
+// in each WHEN clause, or simple with an operand compared to each WHEN value.
+// Decls following end decl are ignored.
+func caseExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (*caseExpression, error) {
+	c := &caseExpression{}
+	var results []expression
+
+	for i, d := range decl.Decl {
+		switch d.Token {
+		case parser.WhenToken:
+			if len(d.Decl) < 2 {
+				return nil, fmt.Errorf("malformed WHEN clause")
+			}
+			w := caseWhen{}
+			var err error
+			cond, then := d.Decl[:len(d.Decl)-1], d.Decl[len(d.Decl)-1]
+			if c.operand != nil {
+				w.value, err = expressionExecutor(e, cond[0], tables, locked)
+			} else {
+				w.cond, err = whereExecutor2(e, cond, tables, locked)
+			}
+			if err != nil {
+				return nil, err
+			}
+			w.result, err = expressionExecutor(e, then.Decl[0], tables, locked)
+			if err != nil {
+				return nil, err
+			}
+			c.whens = append(c.whens, w)
+			results = append(results, w.result)
+		case parser.ElseToken:
+			var err error
+			c.elseResult, err = expressionExecutor(e, d.Decl[0], tables, locked)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, c.elseResult)
+		case parser.EndToken:
+			reconcileResults(results)
+			return c, nil
+		default:
+			// Simple CASE operand comes first
+			if i > 0 {
+				return nil, fmt.Errorf("unexpected %s in CASE expression", d.Lexeme)
+			}
+			var err error
+			c.operand, err = expressionExecutor(e, d, tables, locked)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("CASE expression without END")
+}
+
+// reconcileResults converts constant results of a CASE expression to a common type:
+// integers become floats if mixed with floats, and numbers become strings if mixed
+// with strings. Attribute values are left as is.
+func reconcileResults(results []expression) {
+	var hasFloat, hasString bool
+
+	for _, r := range results {
+		c, ok := r.(*constantExpression)
+		if !ok {
+			continue
+		}
+		switch c.v.(type) {
+		case float64:
+			hasFloat = true
+		case string:
+			hasString = true
+		}
+	}
+
+	for _, r := range results {
+		c, ok := r.(*constantExpression)
+		if !ok || c.v == nil {
+			continue
+		}
+		switch v := c.v.(type) {
+		case int64:
+			if hasString {
+				c.v = strconv.FormatInt(v, 10)
+			} else if hasFloat {
+				c.v = float64(v)
+			}
+		case float64:
+			if hasString {
+				c.v = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+	}
+}
+
+// caseWhen is a WHEN clause, selected if its condition is true
+// or if its value equals CASE operand
+type caseWhen struct {
+	cond   PredicateLinker
+	value  expression
+	result expression
+}
+
+// caseExpression returns the result of the first WHEN clause selected,
+// else the ELSE result, or NULL if there is none
+type caseExpression struct {
+	operand    expression
+	whens      []caseWhen
+	elseResult expression
+}
+
+func (c *caseExpression) eval(row virtualRow) (interface{}, error) {
+	var operand interface{}
+	var err error
+
+	if c.operand != nil {
+		operand, err = c.operand.eval(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, w := range c.whens {
+		var selected bool
+
+		if c.operand != nil {
+			// NULL operand never equals any value
+			v, err := w.value.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			selected = operand != nil && v != nil && compareValues(operand, v) == 0
+		} else {
+			selected, err = w.cond.Eval(row)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if selected {
+			return w.result.eval(row)
+		}
+	}
+
+	if c.elseResult == nil {
+		return nil, nil
+	}
+	return c.elseResult.eval(row)
+}
+
+// expressionFunctor adds values of expressions in select list to each virtual row
+// before feeding next functors
+type expressionFunctor struct {
+	next        []selectFunctor
+	expressions []*selectedExpression
+}
+
+func (f *expressionFunctor) Init(e *Engine, conn protocol.EngineConn, attr []string, alias []string) error {
+	for i := range f.next {
+		if err := f.next[i].Init(e, conn, attr, alias); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *expressionFunctor) FeedVirtualRow(vrow virtualRow) error {
+	for _, s := range f.expressions {
+		v, err := s.expr.eval(vrow)
+		if err != nil {
+			return err
+		}
+		vrow[s.key] = Value{v: v, valid: true, lexeme: s.key}
+	}
+
+	for i := range f.next {
+		if err := f.next[i].FeedVirtualRow(vrow); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *expressionFunctor) Done() error {
+	for i := range f.next {
+		if err := f.next[i].Done(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,633 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestCase(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCase")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE results (id BIGSERIAL, name TEXT, country TEXT, score INT, active BOOLEAN)`,
+		`INSERT INTO results (name, country, score, active) VALUES ('alice', 'FR', 95, TRUE)`,
+		`INSERT INTO results (name, country, score, active) VALUES ('bob', 'US', 85, FALSE)`,
+		`INSERT INTO results (name, country, score, active) VALUES ('carol', 'DE', 70, TRUE)`,
+		`INSERT INTO results (name, country) VALUES ('dave', 'FR')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []sql.NullString {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []sql.NullString
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT CASE WHEN score >= 90 THEN 'A' WHEN score >= 80 THEN 'B' ELSE 'C' END AS grade FROM results ORDER BY id`: {"A", "B", "C", "C"},
+		`SELECT CASE country WHEN 'FR' THEN 'Europe' WHEN 'DE' THEN 'Europe' END FROM results ORDER BY id`:               {"Europe", "NULL", "Europe", "Europe"},
+		`SELECT CASE WHEN score IS NULL THEN name ELSE country END FROM results ORDER BY id`:                             {"FR", "US", "DE", "dave"},
+		`SELECT name FROM results WHERE CASE WHEN score >= 80 THEN 'pass' ELSE 'fail' END = 'pass' ORDER BY id`:          {"alice", "bob"},
+		`SELECT name FROM results WHERE country = CASE WHEN score > 90 THEN 'FR' ELSE 'US' END ORDER BY id`:              {"alice", "bob"},
+		`SELECT name FROM results ORDER BY CASE country WHEN 'US' THEN 1 WHEN 'DE' THEN 2 ELSE 3 END, id DESC`:           {"bob", "carol", "dave", "alice"},
+		`SELECT CASE WHEN score > 90 AND country = 'FR' THEN 1 ELSE 2.5 END FROM results ORDER BY id`:                    {"1", "2.5", "2.5", "2.5"},
+		`SELECT CASE WHEN active THEN 'y' ELSE 'n' END FROM results ORDER BY id`:                                         {"y", "n", "y", "n"},
+		`SELECT CASE WHEN NOT active THEN 'y' ELSE 'n' END FROM results ORDER BY id`:                                     {"n", "y", "n", "n"},
+		`SELECT CASE WHEN results.active AND score > 80 THEN 1 ELSE 0 END FROM results ORDER BY id`:                      {"1", "0", "0", "0"},
+		`SELECT name FROM results WHERE active ORDER BY id`:                                                              {"alice", "carol"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			v := "NULL"
+			if res[i].Valid {
+				v = res[i].String
+			}
+			if v != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// Column is named after alias, or else case
+	rows, err := db.Query(`SELECT name, CASE WHEN score >= 90 THEN 'A' END AS grade, CASE WHEN score < 90 THEN 'B' END FROM results`)
+	if err != nil {
+		t.Fatalf("Cannot query case: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if len(columns) != 3 || columns[1] != "grade" || columns[2] != "case" {
+		t.Fatalf("Expected columns [name grade case], got %v", columns)
+	}
+
+	_, err = db.Query(`SELECT CASE WHEN score > 90 THEN unknown END FROM results`)
+	if err == nil {
+		t.Fatalf("Expected error with unknown attribute in CASE expression")
+	}
+}
+
+func TestCaseKeywordsAsNames(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCaseKeywordsAsNames")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE ev (id INT, end INT, when TEXT)`,
+		`INSERT INTO ev (id, end, when) VALUES (1, 10, 'now')`,
+		`INSERT INTO ev (id, end, when) VALUES (2, 3, 'later')`,
+		`UPDATE ev SET end = end + 1 WHERE when = 'later'`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT end FROM ev ORDER BY end`:                                               {"4", "10"},
+		`SELECT ev.when FROM ev WHERE end > 5`:                                          {"now"},
+		`SELECT CASE WHEN end > 5 THEN when ELSE 'soon' END AS end FROM ev ORDER BY id`: {"now", "soon"},
+		`SELECT CASE end WHEN 4 THEN 'four' END FROM ev ORDER BY id`:                    {"", "four"},
+	}
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v.String)
+		}
+		rows.Close()
+		if strings.Join(res, ",") != strings.Join(expected, ",") {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestArithmetic")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE line_items (id BIGSERIAL, price FLOAT, quantity INT)`,
+		`INSERT INTO line_items (price, quantity) VALUES (10, 5)`,
+		`INSERT INTO line_items (price, quantity) VALUES (2.5, 4)`,
+		`INSERT INTO line_items (price, quantity) VALUES (30, 4)`,
+		`INSERT INTO line_items (quantity) VALUES (7)`,
+		`INSERT INTO line_items (price, quantity) VALUES (-5, 0 - 5)`,
+		`INSERT INTO line_items (price, quantity) VALUES (-(2 + 0.5) * 2, CASE 1 WHEN 1 THEN -1 END)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []sql.NullString {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []sql.NullString
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT price * quantity AS total FROM line_items WHERE price * quantity > 100 ORDER BY id`: {"120"},
+		`SELECT price * quantity FROM line_items ORDER BY id`:                                       {"50", "10", "120", "NULL", "25", "5"},
+		`SELECT quantity + 2 * 3 FROM line_items ORDER BY id`:                                       {"11", "10", "10", "13", "1", "5"},
+		`SELECT (quantity + 2) * 3 FROM line_items ORDER BY id`:                                     {"21", "18", "18", "27", "-9", "3"},
+		`SELECT quantity / 2 FROM line_items WHERE quantity > 0 ORDER BY id`:                        {"2", "2", "2", "3"},
+		`SELECT quantity / 2.0 FROM line_items WHERE quantity > 0 ORDER BY id`:                      {"2.5", "2", "2", "3.5"},
+		`SELECT -quantity - 1 FROM line_items ORDER BY id`:                                          {"-6", "-5", "-5", "-8", "4", "0"},
+		`SELECT id FROM line_items WHERE quantity - 5 > -1 ORDER BY id`:                             {"1", "4"},
+		`SELECT id FROM line_items WHERE price = quantity * 2 ORDER BY id`:                          {"1"},
+		`SELECT price FROM line_items WHERE id > 4 ORDER BY id`:                                     {"-5", "-5"},
+		`SELECT quantity FROM line_items WHERE id > 4 ORDER BY id`:                                  {"-5", "-1"},
+		`SELECT id FROM line_items ORDER BY price * quantity DESC, id`:                              {"4", "3", "1", "5", "2", "6"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			v := "NULL"
+			if res[i].Valid {
+				v = res[i].String
+			}
+			if v != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	_, err = db.Query(`SELECT quantity / (quantity - 4) FROM line_items`)
+	if err == nil {
+		t.Fatalf("Expected division by zero error")
+	}
+
+	_, err = db.Query(`SELECT id FROM line_items WHERE price / 0.0 > 1`)
+	if err == nil {
+		t.Fatalf("Expected division by zero error")
+	}
+}
+
+func TestStringFunctions(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestStringFunctions")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE products (id BIGSERIAL, name TEXT, code TEXT, sku TEXT)`,
+		`INSERT INTO products (name, code, sku) VALUES ('Chair', '  ch1 ', 'FUR-001')`,
+		`INSERT INTO products (name, code, sku) VALUES ('Crème brûlée', 'cb', 'FOO-002')`,
+		`INSERT INTO products (name, sku) VALUES ('table', 'FUR-003')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []sql.NullString {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []sql.NullString
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT UPPER(name) FROM products ORDER BY id`:                                               {"CHAIR", "CRÈME BRÛLÉE", "TABLE"},
+		`SELECT lower(name) FROM products ORDER BY id`:                                               {"chair", "crème brûlée", "table"},
+		`SELECT LENGTH(name) FROM products ORDER BY id`:                                              {"5", "12", "5"},
+		`SELECT TRIM(code) FROM products ORDER BY id`:                                                {"ch1", "cb", "NULL"},
+		`SELECT SUBSTR(sku, 1, 3) FROM products ORDER BY id`:                                         {"FUR", "FOO", "FUR"},
+		`SELECT SUBSTR(name, 7) FROM products ORDER BY id`:                                           {"", "brûlée", ""},
+		`SELECT SUBSTR(sku, 0, 3) FROM products ORDER BY id`:                                         {"FU", "FO", "FU"},
+		`SELECT CONCAT(name, '-', code) FROM products ORDER BY id`:                                   {"Chair-  ch1 ", "Crème brûlée-cb", "table-"},
+		`SELECT id FROM products WHERE UPPER(name) = 'TABLE'`:                                        {"3"},
+		`SELECT id FROM products WHERE SUBSTR(sku, 1, 3) = 'FUR' ORDER BY id`:                        {"1", "3"},
+		`SELECT id FROM products WHERE LENGTH(TRIM(code)) > 2`:                                       {"1"},
+		`SELECT name FROM products ORDER BY LOWER(name) DESC`:                                        {"table", "Crème brûlée", "Chair"},
+		`SELECT CONCAT(UPPER(SUBSTR(name, 1, 1)), LOWER(SUBSTR(name, 2))) FROM products ORDER BY id`: {"Chair", "Crème brûlée", "Table"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			v := "NULL"
+			if res[i].Valid {
+				v = res[i].String
+			}
+			if v != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// Column is named after function
+	rows, err := db.Query(`SELECT upper(name), lower(name) AS l FROM products`)
+	if err != nil {
+		t.Fatalf("Cannot query functions: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if len(columns) != 2 || columns[0] != "upper" || columns[1] != "l" {
+		t.Fatalf("Expected columns [upper l], got %v", columns)
+	}
+
+	_, err = db.Query(`SELECT unknown(name) FROM products`)
+	if err == nil {
+		t.Fatalf("Expected error with unknown function")
+	}
+
+	_, err = db.Query(`SELECT upper(name, code) FROM products`)
+	if err == nil {
+		t.Fatalf("Expected error with wrong number of arguments")
+	}
+}
+
+func TestDateFunctions(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDateFunctions")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id BIGSERIAL PRIMARY KEY, name TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`INSERT INTO events (name, created_at) VALUES ('launch', '2023-04-05T06:07:08.25Z')`,
+		`INSERT INTO events (name, created_at) VALUES ('review', '2024-12-31T23:59:00Z')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string]string{
+		`SELECT EXTRACT(YEAR FROM created_at) FROM events WHERE name = 'launch'`:   "2023",
+		`SELECT EXTRACT(month FROM created_at) FROM events WHERE name = 'launch'`:  "4",
+		`SELECT EXTRACT(DAY FROM created_at) FROM events WHERE name = 'launch'`:    "5",
+		`SELECT EXTRACT(HOUR FROM created_at) FROM events WHERE name = 'launch'`:   "6",
+		`SELECT EXTRACT(MINUTE FROM created_at) FROM events WHERE name = 'launch'`: "7",
+		`SELECT EXTRACT(SECOND FROM created_at) FROM events WHERE name = 'launch'`: "8.25",
+		`SELECT name FROM events WHERE EXTRACT(YEAR FROM created_at) = 2024`:       "review",
+	}
+	for query, expected := range queries {
+		var v string
+		if err := db.QueryRow(query).Scan(&v); err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		if v != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, v)
+		}
+	}
+
+	_, err = db.Query(`SELECT EXTRACT(WEEKDAY FROM created_at) FROM events`)
+	if err == nil {
+		t.Fatalf("Expected error with unknown timestamp unit")
+	}
+
+	// Default value and NOW() are timestamps
+	before := time.Now()
+	_, err = db.Exec(`INSERT INTO events (name) VALUES ('release')`)
+	if err != nil {
+		t.Fatalf("Cannot insert with default timestamp: %s", err)
+	}
+	var created time.Time
+	if err := db.QueryRow(`SELECT created_at FROM events WHERE name = 'release'`).Scan(&created); err != nil {
+		t.Fatalf("Cannot scan timestamp: %s", err)
+	}
+	if created.Before(before.Truncate(time.Second)) || created.After(time.Now()) {
+		t.Fatalf("Expected current timestamp, got %v", created)
+	}
+
+	var now time.Time
+	if err := db.QueryRow(`SELECT NOW() FROM events WHERE name = 'release'`).Scan(&now); err != nil {
+		t.Fatalf("Cannot scan NOW(): %s", err)
+	}
+	if now.Before(created) {
+		t.Fatalf("Expected NOW() after %v, got %v", created, now)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM events WHERE created_at <= CURRENT_TIMESTAMP`).Scan(&count); err != nil {
+		t.Fatalf("Cannot compare with CURRENT_TIMESTAMP: %s", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 past events, got %d", count)
+	}
+
+	// All rows updated by a statement share the same timestamp
+	_, err = db.Exec(`UPDATE events SET created_at = NOW() WHERE id > 0`)
+	if err != nil {
+		t.Fatalf("Cannot update with NOW(): %s", err)
+	}
+	rows, err := db.Query(`SELECT created_at FROM events`)
+	if err != nil {
+		t.Fatalf("Cannot select timestamps: %s", err)
+	}
+	var timestamps []time.Time
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			t.Fatalf("Cannot scan timestamp: %s", err)
+		}
+		timestamps = append(timestamps, ts)
+	}
+	rows.Close()
+	if len(timestamps) != 3 {
+		t.Fatalf("Expected 3 timestamps, got %d", len(timestamps))
+	}
+	for _, ts := range timestamps {
+		if !ts.Equal(timestamps[0]) {
+			t.Fatalf("Expected same timestamp for all rows, got %v", timestamps)
+		}
+	}
+}
+
+func TestCoalesceNullif(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCoalesceNullif")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT, nickname TEXT, score INT)`,
+		`INSERT INTO users (name, nickname, score) VALUES ('Alice', 'al', 0)`,
+		`INSERT INTO users (name, score) VALUES ('Bob', 12)`,
+		`INSERT INTO users (score) VALUES (7)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT COALESCE(nickname, name, 'anonymous') FROM users ORDER BY id`: {"al", "Bob", "anonymous"},
+		`SELECT COALESCE(nickname, name) FROM users ORDER BY id`:              {"al", "Bob", "NULL"},
+		`SELECT COALESCE(name, nickname, 1 / 0) FROM users WHERE id < 3`:      {"Alice", "Bob"},
+		`SELECT NULLIF(score, 0) FROM users ORDER BY id`:                      {"NULL", "12", "7"},
+		`SELECT NULLIF(name, nickname) FROM users ORDER BY id`:                {"Alice", "Bob", "NULL"},
+		`SELECT id FROM users WHERE COALESCE(nickname, name) = 'Bob'`:         {"2"},
+		`SELECT id FROM users WHERE NULLIF(score, 0) IS NULL`:                 {"1"},
+		`SELECT id FROM users WHERE 100 / COALESCE(NULLIF(score, 0), 1) > 10`: {"1", "3"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			if !v.Valid {
+				v.String = "NULL"
+			}
+			res = append(res, v.String)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	_, err = db.Query(`SELECT NULLIF(name) FROM users`)
+	if err == nil {
+		t.Fatalf("Expected error with wrong number of arguments")
+	}
+}
+
+func TestCast(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestCast")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE measures (id BIGSERIAL, label TEXT, amount TEXT, ratio TEXT, flag TEXT, taken_at TIMESTAMP)`,
+		`INSERT INTO measures (label, amount, ratio, flag, taken_at) VALUES ('a', '9', '0.5', 'yes', '2023-04-05T06:07:08Z')`,
+		`INSERT INTO measures (label, amount, ratio, flag, taken_at) VALUES ('b', '12', '2.75', 'false', '2024-01-02T03:04:05Z')`,
+		`INSERT INTO measures (label, amount, ratio, flag) VALUES ('c', '100', '1', 'on')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT label FROM measures WHERE CAST(amount AS INTEGER) > 10 ORDER BY id`:   {"b", "c"},
+		`SELECT label FROM measures WHERE amount::int > 10 ORDER BY amount::int DESC`: {"c", "b"},
+		`SELECT CAST(amount AS INTEGER) + 1 FROM measures ORDER BY id`:                {"10", "13", "101"},
+		`SELECT CAST(ratio AS DECIMAL) * 2 FROM measures ORDER BY id`:                 {"1", "5.5", "2"},
+		`SELECT CAST(ratio AS DOUBLE PRECISION) FROM measures WHERE id = 2`:           {"2.75"},
+		`SELECT CAST(2.5 AS INTEGER) FROM measures WHERE id = 1`:                      {"3"},
+		`SELECT CAST(id AS TEXT) FROM measures ORDER BY id`:                           {"1", "2", "3"},
+		`SELECT CAST(flag AS BOOLEAN) FROM measures ORDER BY id`:                      {"true", "false", "true"},
+		`SELECT '42'::varchar(10) FROM measures WHERE id = 1`:                         {"42"},
+		`SELECT CAST(taken_at AS DATE) FROM measures ORDER BY id`:                     {"2023-04-05T00:00:00Z", "2024-01-02T00:00:00Z", "NULL"},
+		`SELECT label FROM measures WHERE CAST(taken_at AS TIMESTAMP) > '2024-01-01'`: {"b"},
+		`SELECT label FROM measures WHERE -amount::int < -50`:                         {"c"},
+		`SELECT CAST(ratio AS INTEGER) FROM measures ORDER BY id`:                     {"1", "3", "1"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			if !v.Valid {
+				v.String = "NULL"
+			}
+			res = append(res, v.String)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// Cast attribute keeps its name
+	rows, err := db.Query(`SELECT CAST(amount AS INTEGER), label::text AS l FROM measures`)
+	if err != nil {
+		t.Fatalf("Cannot query casts: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if len(columns) != 2 || columns[0] != "amount" || columns[1] != "l" {
+		t.Fatalf("Expected columns [amount l], got %v", columns)
+	}
+
+	errorQueries := []string{
+		`SELECT CAST(label AS INTEGER) FROM measures`,
+		`SELECT CAST(label AS BOOLEAN) FROM measures`,
+		`SELECT CAST(label AS TIMESTAMP) FROM measures`,
+		`SELECT CAST(label AS blob) FROM measures`,
+	}
+	for _, query := range errorQueries {
+		rows, err := db.Query(query)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+		}
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+
+	// FLOAT values bound as text are rounded as well
+	if _, err := db.Exec(`CREATE TABLE readings (id BIGSERIAL, value FLOAT)`); err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	for _, v := range []string{"2.0", "1.5", "-0.4"} {
+		if _, err := db.Exec(`INSERT INTO readings (value) VALUES ($1)`, v); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+	rows, err = db.Query(`SELECT CAST(value AS INTEGER) FROM readings ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Cannot cast FLOAT column: %s", err)
+	}
+	var res []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("cannot scan row: %s", err)
+		}
+		res = append(res, v)
+	}
+	rows.Close()
+	if len(res) != 3 || res[0] != 2 || res[1] != 2 || res[2] != 0 {
+		t.Fatalf("Expected [2 2 0], got %v", res)
+	}
+}
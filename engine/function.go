@@ -0,0 +1,392 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// function is a builtin function called with values of its arguments.
+// A strict function returns NULL as soon as an argument is NULL,
+// without being called. maxArgs is -1 if arguments are not limited.
+// call is nil for functions evaluating their arguments lazily,
+// which have their own expression.
+type function struct {
+	minArgs int
+	maxArgs int
+	strict  bool
+	call    func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"upper":     {1, 1, true, upperFunction},
+	"lower":     {1, 1, true, lowerFunction},
+	"length":    {1, 1, true, lengthFunction},
+	"substr":    {2, 3, true, substrFunction},
+	"substring": {2, 3, true, substrFunction},
+	"trim":      {1, 1, true, trimFunction},
+	"concat":    {0, -1, false, concatFunction},
+	"extract":   {2, 2, true, extractFunction},
+	"coalesce":  {1, -1, false, nil},
+	"nullif":    {2, 2, false, nullifFunction},
+	"cast":      {2, 2, true, castFunction},
+	"random":    {0, 0, false, nil},
+	"nextval":   {1, 1, true, nil},
+	"currval":   {1, 1, true, nil},
+	"setval":    {2, 3, true, nil},
+
+	"gen_random_uuid": {0, 0, false, genRandomUUIDFunction},
+
+	"array":        {0, -1, false, arrayFunction},
+	"array_length": {2, 2, true, arrayLengthFunction},
+	"cardinality":  {1, 1, true, cardinalityFunction},
+
+	"json_extract_path":       {2, -1, true, jsonExtractPathFunction},
+	"json_extract_path_text":  {2, -1, true, jsonExtractPathTextFunction},
+	"jsonb_extract_path":      {2, -1, true, jsonExtractPathFunction},
+	"jsonb_extract_path_text": {2, -1, true, jsonExtractPathTextFunction},
+}
+
+/*
+|-> upper
+	|-> name
+	|-> )
+*/
+// functionExecutor returns the call of a builtin function with given arguments expressions
+func functionExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (expression, error) {
+	if windowDecl(decl) != nil {
+		return nil, fmt.Errorf("window function %s is only allowed in select list", decl.Lexeme)
+	}
+
+	f, ok := functions[decl.Lexeme]
+	if !ok {
+		return nil, errorf(UndefinedFunction, "function %s does not exist", decl.Lexeme)
+	}
+
+	c := &functionExpression{f: f}
+	for _, d := range decl.Decl {
+		if d.Token == parser.BracketClosingToken {
+			break
+		}
+		arg, err := expressionExecutor(e, d, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+	}
+
+	if len(c.args) < f.minArgs || (f.maxArgs >= 0 && len(c.args) > f.maxArgs) {
+		return nil, fmt.Errorf("function %s does not take %d arguments", decl.Lexeme, len(c.args))
+	}
+
+	if decl.Lexeme == "coalesce" {
+		return &coalesceExpression{args: c.args}, nil
+	}
+	if decl.Lexeme == "random" {
+		return &randomExpression{e: e}, nil
+	}
+	if decl.Lexeme == "nextval" || decl.Lexeme == "currval" || decl.Lexeme == "setval" {
+		return &sequenceExpression{e: e, function: decl.Lexeme, args: c.args}, nil
+	}
+
+	return c, nil
+}
+
+// coalesceExpression returns the first non NULL argument, or NULL if all are.
+// Arguments following it are not evaluated.
+type coalesceExpression struct {
+	args []expression
+}
+
+func (c *coalesceExpression) eval(row virtualRow) (interface{}, error) {
+	for _, arg := range c.args {
+		v, err := arg.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			return v, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// randomSource generates random values, from several sessions
+type randomSource struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+func newRandomSource(seed int64) *randomSource {
+	return &randomSource{r: rand.New(rand.NewSource(seed))}
+}
+
+// randomExpression returns a random float in [0, 1), different for each row
+type randomExpression struct {
+	e *Engine
+}
+
+func (r *randomExpression) eval(row virtualRow) (interface{}, error) {
+	source := r.e.random
+	source.Lock()
+	defer source.Unlock()
+
+	return source.r.Float64(), nil
+}
+
+// functionExpression is a call to a builtin function
+type functionExpression struct {
+	f    function
+	args []expression
+}
+
+func (c *functionExpression) eval(row virtualRow) (interface{}, error) {
+	var args []interface{}
+
+	for _, arg := range c.args {
+		v, err := arg.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil && c.f.strict {
+			return nil, nil
+		}
+		args = append(args, v)
+	}
+
+	return c.f.call(args)
+}
+
+// stringValue returns the text representation of v
+func stringValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// integerValue returns v as an integer, as function argument
+func integerValue(v interface{}) (int64, error) {
+	n, err := numericValue(v)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := n.(int64)
+	if !ok {
+		return 0, fmt.Errorf("invalid integer %v", v)
+	}
+	return i, nil
+}
+
+func upperFunction(args []interface{}) (interface{}, error) {
+	return strings.ToUpper(stringValue(args[0])), nil
+}
+
+func lowerFunction(args []interface{}) (interface{}, error) {
+	return strings.ToLower(stringValue(args[0])), nil
+}
+
+// lengthFunction counts characters, not bytes
+func lengthFunction(args []interface{}) (interface{}, error) {
+	return int64(utf8.RuneCountInString(stringValue(args[0]))), nil
+}
+
+// substrFunction extracts count characters, or up to the end, from 1-based start position.
+// Like PostgreSQL, positions before the first character are counted
+// but do not select anything.
+func substrFunction(args []interface{}) (interface{}, error) {
+	s := []rune(stringValue(args[0]))
+
+	start, err := integerValue(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	end := int64(len(s)) + 1
+	if len(args) > 2 {
+		count, err := integerValue(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("negative substring length not allowed")
+		}
+		if start+count < end {
+			end = start + count
+		}
+	}
+	if start < 1 {
+		start = 1
+	}
+	if end <= start {
+		return "", nil
+	}
+
+	return string(s[start-1 : end-1]), nil
+}
+
+// trimFunction removes leading and trailing spaces
+func trimFunction(args []interface{}) (interface{}, error) {
+	return strings.Trim(stringValue(args[0]), " "), nil
+}
+
+// concatFunction concatenates text representation of arguments.
+// Like PostgreSQL, NULL arguments are ignored, so the result is never NULL.
+func concatFunction(args []interface{}) (interface{}, error) {
+	var b strings.Builder
+
+	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
+		b.WriteString(stringValue(arg))
+	}
+
+	return b.String(), nil
+}
+
+// extractFunction returns a field of a timestamp: YEAR, MONTH, DAY, HOUR, MINUTE or SECOND.
+// Seconds include the fractional part.
+func extractFunction(args []interface{}) (interface{}, error) {
+	var t time.Time
+	switch v := args[1].(type) {
+	case time.Time:
+		t = v
+	default:
+		d, err := convToDate(stringValue(v))
+		if err != nil {
+			return nil, err
+		}
+		t = d
+	}
+
+	field := stringValue(args[0])
+	switch strings.ToLower(field) {
+	case "year":
+		return int64(t.Year()), nil
+	case "month":
+		return int64(t.Month()), nil
+	case "day":
+		return int64(t.Day()), nil
+	case "hour":
+		return int64(t.Hour()), nil
+	case "minute":
+		return int64(t.Minute()), nil
+	case "second":
+		if t.Nanosecond() == 0 {
+			return int64(t.Second()), nil
+		}
+		return float64(t.Second()) + float64(t.Nanosecond())/1e9, nil
+	}
+
+	return nil, fmt.Errorf("timestamp unit %s not recognized", field)
+}
+
+// nullifFunction returns NULL if both arguments are equal, and the first one otherwise
+func nullifFunction(args []interface{}) (interface{}, error) {
+	if args[0] != nil && args[1] != nil && compareValues(args[0], args[1]) == 0 {
+		return nil, nil
+	}
+
+	return args[0], nil
+}
+
+// castFunction converts a value to given type. Integer, decimal, text, boolean
+// and timestamp types are supported. A value that cannot be represented
+// in target type is an error.
+func castFunction(args []interface{}) (interface{}, error) {
+	v, typeName := args[0], stringValue(args[1])
+
+	switch typeName {
+	case "int", "integer", "int2", "int4", "int8", "smallint", "bigint", "serial", "bigserial":
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(math.Round(v)), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+		s := strings.TrimSpace(stringValue(v))
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+		// FLOAT and DECIMAL values may be stored as text, such as 2.0 or 1.5
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %v", typeName, v)
+		}
+		return int64(math.Round(f)), nil
+	case "decimal", "numeric", "real", "float", "float4", "float8", "double precision":
+		switch v := v.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(stringValue(v)), 64)
+		if err != nil {
+			return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %v", typeName, v)
+		}
+		return f, nil
+	case "text", "varchar", "char", "character", "character varying":
+		switch v := v.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case time.Time:
+			return v.Format(parser.DateLongFormat), nil
+		}
+		return stringValue(v), nil
+	case "bool", "boolean":
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(stringValue(v))) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+		return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %v", typeName, v)
+	case "timestamp", "timestamptz", "date":
+		t, ok := v.(time.Time)
+		if !ok {
+			var err error
+			t, err = convToDate(stringValue(v))
+			if err != nil {
+				return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %v", typeName, v)
+			}
+		}
+		if typeName == "date" {
+			return t.Format(parser.DateNumberFormat), nil
+		}
+		return t.Format(parser.DateLongFormat), nil
+	case "uuid":
+		return uuidText(v)
+	case "json", "jsonb":
+		// JSON text is kept verbatim, as long as it is valid
+		s := stringValue(v)
+		if !json.Valid([]byte(s)) {
+			return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %v", typeName, v)
+		}
+		return s, nil
+	}
+
+	return nil, errorf(UndefinedObject, "type %s does not exist", typeName)
+}
@@ -0,0 +1,558 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+/*
+|-> group
+	|-> country
+	|-> city
+		|-> address
+*/
+// groupbyExecutor returns virtual row keys of grouping attributes. Like PostgreSQL, an
+// unqualified name which is not an attribute of tables may be the one of a selected attribute.
+func groupbyExecutor(groupDecl *parser.Decl, tables []*Table, header []string, alias []string) ([]string, error) {
+	var keys []string
+
+	for _, attr := range groupDecl.Decl {
+		var tableName string
+		if len(attr.Decl) > 0 {
+			tableName = attr.Decl[0].Lexeme
+		}
+		t, err := resolveAttribute(attr.Lexeme, tableName, tables)
+		if err == nil {
+			keys = append(keys, t.name+"."+attr.Lexeme)
+			continue
+		}
+		if tableName != "" {
+			return nil, err
+		}
+
+		// Only attributes are known before grouping, not computed values
+		key, kerr := selectedColumn(attr.Lexeme, header, alias)
+		if kerr != nil {
+			return nil, kerr
+		}
+		if key == "" || !isAttributeKey(key, tables) {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// isAttributeKey returns true if virtual row key is the one of an attribute of tables
+func isAttributeKey(key string, tables []*Table) bool {
+	for _, t := range tables {
+		for _, a := range t.attributes {
+			if t.name+"."+a.name == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+|-> having
+	|-> count
+		|-> *
+		|-> >
+		|-> 5
+*/
+// havingExecutor sets HAVING predicates on groups. Aggregates used in
+// HAVING clause are computed even if not selected.
+func havingExecutor(e *Engine, havingDecl *parser.Decl, tables []*Table, g *groupbyFunctor, locked map[*Relation]bool) error {
+	for _, cond := range havingDecl.Decl {
+		switch cond.Token {
+		case parser.CountToken, parser.SumToken, parser.AvgToken, parser.MinToken, parser.MaxToken,
+			parser.GroupConcatToken, parser.StringAggToken:
+			f, err := aggregateExecutor(e, cond, tables, locked)
+			if err != nil {
+				return err
+			}
+			g.addAggregate(f)
+		}
+	}
+
+	pred, err := whereExecutor2(e, havingDecl.Decl, tables, locked)
+	if err != nil {
+		return err
+	}
+	g.having = []PredicateLinker{pred}
+
+	return nil
+}
+
+/*
+|-> sum
+	|-> amount
+		|-> orders
+
+|-> string_agg
+	|-> upper
+		|-> name
+		|-> )
+	|-> ,
+*/
+// aggregateExecutor returns the aggregate of an attribute, or of an expression for GROUP_CONCAT
+// and STRING_AGG, computed for each row
+func aggregateExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (*aggregateFunction, error) {
+	if len(decl.Decl) < 1 {
+		return nil, fmt.Errorf("%s: attribute not provided", decl.Lexeme)
+	}
+
+	f := &aggregateFunction{
+		token: decl.Token,
+		name:  strings.ToLower(decl.Lexeme),
+	}
+
+	attr := decl.Decl[0]
+	if attr.Token == parser.StarToken {
+		if f.token != parser.CountToken {
+			return nil, fmt.Errorf("%s(*) is not supported", f.name)
+		}
+		f.key = f.name + "(*)"
+		return f, nil
+	}
+
+	concat := f.token == parser.GroupConcatToken || f.token == parser.StringAggToken
+	if concat && attr.Token != parser.StringToken {
+		expr, err := expressionExecutor(e, attr, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		f.expr = expr
+		f.attribute = declText(attr)
+	} else {
+		var tableName string
+		if len(attr.Decl) > 0 {
+			tableName = attr.Decl[0].Lexeme
+		}
+		t, err := resolveAttribute(attr.Lexeme, tableName, tables)
+		if err != nil {
+			return nil, err
+		}
+		f.attribute = t.name + "." + attr.Lexeme
+		if i := t.attributeIndex(attr.Lexeme); i >= 0 {
+			f.decimal = isDecimal(t.attributes[i].typeName)
+		}
+	}
+	f.key = f.name + "(" + f.attribute + ")"
+	if concat {
+		f.separator = defaultSeparator
+		if len(decl.Decl) > 1 && decl.Decl[1].Token == parser.StringToken {
+			f.separator = decl.Decl[1].Lexeme
+			f.key = f.name + "(" + f.attribute + ", '" + f.separator + "')"
+		}
+	}
+
+	return f, nil
+}
+
+// aggregateFunction is an aggregate found in query, like SUM(amount).
+// Its value for each group is stored in group virtual row under key.
+type aggregateFunction struct {
+	token     int
+	name      string
+	attribute string
+	key       string
+	// decimal is set if attribute is a decimal one, computed exactly
+	decimal bool
+	// separator is put between concatenated values
+	separator string
+	// expr computes concatenated values, instead of attribute
+	expr expression
+}
+
+// defaultSeparator is the separator of GROUP_CONCAT and STRING_AGG if none is given
+const defaultSeparator = ","
+
+func (f *aggregateFunction) new() aggregate {
+	switch f.token {
+	case parser.SumToken:
+		return &sumAggregate{attribute: f.attribute, decimal: f.decimal}
+	case parser.AvgToken:
+		return &avgAggregate{attribute: f.attribute, decimal: f.decimal}
+	case parser.MinToken:
+		return &minmaxAggregate{attribute: f.attribute, sign: -1}
+	case parser.MaxToken:
+		return &minmaxAggregate{attribute: f.attribute, sign: 1}
+	case parser.GroupConcatToken, parser.StringAggToken:
+		return &concatAggregate{attribute: f.attribute, expr: f.expr, separator: f.separator}
+	default:
+		return &countAggregate{attribute: f.attribute}
+	}
+}
+
+// aggregate computes a value over all virtual rows of a group
+type aggregate interface {
+	Feed(row virtualRow) error
+	Value() interface{}
+}
+
+// aggregatedValue returns the value of attribute in row, nil being NULL
+func aggregatedValue(row virtualRow, attribute string) (interface{}, error) {
+	val, ok := row[attribute]
+	if !ok {
+		return nil, fmt.Errorf("could not find aggregated attribute %s in virtual row", attribute)
+	}
+	return val.v, nil
+}
+
+// countAggregate counts rows, or non NULL values if attribute is set
+type countAggregate struct {
+	attribute string
+	count     int64
+}
+
+func (a *countAggregate) Feed(row virtualRow) error {
+	if a.attribute == "" {
+		a.count++
+		return nil
+	}
+
+	v, err := aggregatedValue(row, a.attribute)
+	if err != nil {
+		return err
+	}
+	if v != nil {
+		a.count++
+	}
+	return nil
+}
+
+func (a *countAggregate) Value() interface{} {
+	return a.count
+}
+
+// sumAggregate sums non NULL values, as an integer as long as all values are.
+// Decimal values are summed exactly, with the greatest scale of them.
+// Sum of no value is NULL.
+type sumAggregate struct {
+	attribute string
+	isFloat   bool
+	valid     bool
+	i         int64
+	f         float64
+	decimal   bool
+	r         big.Rat
+	scale     int
+}
+
+func (a *sumAggregate) Feed(row virtualRow) error {
+	v, err := aggregatedValue(row, a.attribute)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	a.valid = true
+
+	if a.decimal {
+		r, err := parseDecimal(v)
+		if err != nil {
+			return fmt.Errorf("cannot sum value %v: %s", v, err)
+		}
+		a.r.Add(&a.r, r)
+		if s := decimalScale(v); s > a.scale {
+			a.scale = s
+		}
+		return nil
+	}
+
+	if !a.isFloat {
+		if i, err := strconv.ParseInt(fmt.Sprintf("%v", v), 10, 64); err == nil {
+			a.i += i
+			return nil
+		}
+		a.isFloat = true
+		a.f = float64(a.i)
+	}
+
+	f, err := convToFloat(v)
+	if err != nil {
+		return fmt.Errorf("cannot sum value %v: %s", v, err)
+	}
+	a.f += f
+	return nil
+}
+
+func (a *sumAggregate) Value() interface{} {
+	if !a.valid {
+		return nil
+	}
+	if a.decimal {
+		return formatDecimal(&a.r, a.scale)
+	}
+	if a.isFloat {
+		return a.f
+	}
+	return a.i
+}
+
+// avgScale is the minimum number of digits after decimal point of the mean of decimal values
+const avgScale = 16
+
+// avgAggregate computes the mean of non NULL values. Mean of decimal values
+// is exact, with at least avgScale digits after decimal point like PostgreSQL.
+type avgAggregate struct {
+	attribute string
+	count     int64
+	sum       float64
+	decimal   bool
+	r         big.Rat
+	scale     int
+}
+
+func (a *avgAggregate) Feed(row virtualRow) error {
+	v, err := aggregatedValue(row, a.attribute)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+
+	if a.decimal {
+		r, err := parseDecimal(v)
+		if err != nil {
+			return fmt.Errorf("cannot average value %v: %s", v, err)
+		}
+		a.r.Add(&a.r, r)
+		if s := decimalScale(v); s > a.scale {
+			a.scale = s
+		}
+		a.count++
+		return nil
+	}
+
+	f, err := convToFloat(v)
+	if err != nil {
+		return fmt.Errorf("cannot average value %v: %s", v, err)
+	}
+	a.sum += f
+	a.count++
+	return nil
+}
+
+func (a *avgAggregate) Value() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	if a.decimal {
+		mean := new(big.Rat).Quo(&a.r, new(big.Rat).SetInt64(a.count))
+		if a.scale < avgScale {
+			return formatDecimal(mean, avgScale)
+		}
+		return formatDecimal(mean, a.scale)
+	}
+	return a.sum / float64(a.count)
+}
+
+// minmaxAggregate keeps the smallest (sign -1) or greatest (sign 1) non NULL value
+type minmaxAggregate struct {
+	attribute string
+	sign      int
+	value     interface{}
+}
+
+func (a *minmaxAggregate) Feed(row virtualRow) error {
+	v, err := aggregatedValue(row, a.attribute)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+
+	if a.value == nil || compareValues(v, a.value)*a.sign > 0 {
+		a.value = v
+	}
+	return nil
+}
+
+func (a *minmaxAggregate) Value() interface{} {
+	return a.value
+}
+
+// concatAggregate joins non NULL values with separator, in the order rows are fed
+type concatAggregate struct {
+	attribute string
+	expr      expression
+	separator string
+	values    []string
+}
+
+func (a *concatAggregate) Feed(row virtualRow) error {
+	var v interface{}
+	var err error
+	if a.expr != nil {
+		v, err = a.expr.eval(row)
+	} else {
+		v, err = aggregatedValue(row, a.attribute)
+	}
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+
+	a.values = append(a.values, fmt.Sprintf("%v", v))
+	return nil
+}
+
+func (a *concatAggregate) Value() interface{} {
+	if len(a.values) == 0 {
+		return nil
+	}
+	return strings.Join(a.values, a.separator)
+}
+
+// compareValues returns -1, 0 or 1 if a is lesser, equal or greater than b.
+// Values are compared as numbers if possible, then as dates, then as strings.
+func compareValues(a, b interface{}) int {
+	if fa, err := convToFloat(a); err == nil {
+		if fb, err := convToFloat(b); err == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if da, err := convToDate(a); err == nil {
+		if db, err := convToDate(b); err == nil {
+			switch {
+			case da.Before(db):
+				return -1
+			case da.After(db):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// groupbyFunctor buckets virtual rows by grouping attributes. Once all rows are fed,
+// next functors are fed with one virtual row per group, holding grouping attributes
+// and aggregates values.
+// Without grouping attribute, all rows are in a single group.
+type groupbyFunctor struct {
+	next       []selectFunctor
+	keys       []string
+	aggregates []*aggregateFunction
+	having     []PredicateLinker
+	groups     map[string]*group
+	order      []*group
+}
+
+// addAggregate computes given aggregate if not already done
+func (f *groupbyFunctor) addAggregate(a *aggregateFunction) {
+	for _, agg := range f.aggregates {
+		if agg.key == a.key {
+			return
+		}
+	}
+
+	f.aggregates = append(f.aggregates, a)
+}
+
+type group struct {
+	row        virtualRow
+	aggregates []aggregate
+}
+
+func (f *groupbyFunctor) Init(e *Engine, conn protocol.EngineConn, attr []string, alias []string) error {
+	f.groups = make(map[string]*group)
+
+	for i := range f.next {
+		if err := f.next[i].Init(e, conn, attr, alias); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *groupbyFunctor) FeedVirtualRow(vrow virtualRow) error {
+	key, err := vrow.key(f.keys)
+	if err != nil {
+		return err
+	}
+
+	g, ok := f.groups[key]
+	if !ok {
+		g = f.newGroup(vrow)
+		f.groups[key] = g
+		f.order = append(f.order, g)
+	}
+
+	for _, a := range g.aggregates {
+		if err := a.Feed(vrow); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *groupbyFunctor) newGroup(vrow virtualRow) *group {
+	g := &group{row: make(virtualRow)}
+
+	for _, k := range f.keys {
+		g.row[k] = vrow[k]
+	}
+	for _, a := range f.aggregates {
+		g.aggregates = append(g.aggregates, a.new())
+	}
+
+	return g
+}
+
+func (f *groupbyFunctor) Done() error {
+
+	// Aggregates without GROUP BY always return a row
+	if len(f.order) == 0 && len(f.keys) == 0 {
+		f.order = append(f.order, f.newGroup(nil))
+	}
+
+	for _, g := range f.order {
+		for i, a := range f.aggregates {
+			g.row[a.key] = Value{
+				v:      g.aggregates[i].Value(),
+				valid:  true,
+				lexeme: a.key,
+			}
+		}
+
+		// Filter groups with HAVING clause
+		if err := selectRows(g.row, f.having, f.next); err != nil {
+			return err
+		}
+	}
+
+	for i := range f.next {
+		if err := f.next[i].Done(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,406 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestGroupBy(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestGroupBy")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE orders (id BIGSERIAL, country TEXT, amount INT)`,
+		`INSERT INTO orders (country, amount) VALUES ('FR', 10)`,
+		`INSERT INTO orders (country, amount) VALUES ('FR', 30)`,
+		`INSERT INTO orders (country, amount) VALUES ('US', 5)`,
+		`INSERT INTO orders (country, amount) VALUES ('FR', 20)`,
+		`INSERT INTO orders (country) VALUES ('US')`,
+		`INSERT INTO orders (country, amount) VALUES ('DE', 7)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT country, COUNT(*), COUNT(amount), SUM(amount), AVG(amount), MIN(amount), MAX(amount) FROM orders GROUP BY country ORDER BY country ASC`)
+	if err != nil {
+		t.Fatalf("Cannot group by country: %s", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	expectedColumns := []string{"country", "count", "count", "sum", "avg", "min", "max"}
+	if len(columns) != len(expectedColumns) {
+		t.Fatalf("Expected columns %v, got %v", expectedColumns, columns)
+	}
+	for i := range columns {
+		if columns[i] != expectedColumns[i] {
+			t.Fatalf("Expected columns %v, got %v", expectedColumns, columns)
+		}
+	}
+
+	type result struct {
+		country    string
+		count      int64
+		countValue int64
+		sum        int64
+		avg        float64
+		min        int64
+		max        int64
+	}
+	expected := []result{
+		{"DE", 1, 1, 7, 7, 7, 7},
+		{"FR", 3, 3, 60, 20, 10, 30},
+		{"US", 2, 1, 5, 5, 5, 5},
+	}
+
+	var results []result
+	for rows.Next() {
+		var r result
+		if err := rows.Scan(&r.country, &r.count, &r.countValue, &r.sum, &r.avg, &r.min, &r.max); err != nil {
+			t.Fatalf("Cannot scan row: %s", err)
+		}
+		results = append(results, r)
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d", len(expected), len(results))
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected[i], results[i])
+		}
+	}
+
+	// Not grouped attribute
+	_, err = db.Query(`SELECT country, amount FROM orders GROUP BY country`)
+	if err == nil {
+		t.Fatalf("Expected error selecting attribute not in GROUP BY clause")
+	}
+
+	// Aggregate without GROUP BY
+	var sum int64
+	err = db.QueryRow(`SELECT SUM(amount) FROM orders WHERE country = 'FR'`).Scan(&sum)
+	if err != nil {
+		t.Fatalf("Cannot select sum: %s", err)
+	}
+	if sum != 60 {
+		t.Fatalf("Expected sum of 60, got %d", sum)
+	}
+}
+
+func TestHaving(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestHaving")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE orders (id BIGSERIAL, country TEXT, amount INT)`,
+		`INSERT INTO orders (country, amount) VALUES ('FR', 10)`,
+		`INSERT INTO orders (country, amount) VALUES ('FR', 100)`,
+		`INSERT INTO orders (country, amount) VALUES ('US', 5)`,
+		`INSERT INTO orders (country, amount) VALUES ('US', 6)`,
+		`INSERT INTO orders (country, amount) VALUES ('US', 7)`,
+		`INSERT INTO orders (country, amount) VALUES ('DE', 700)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	countries := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var country string
+			if err := rows.Scan(&country); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, country)
+		}
+		return res
+	}
+
+	res := countries(`SELECT country FROM orders GROUP BY country HAVING COUNT(*) > 2`)
+	if len(res) != 1 || res[0] != "US" {
+		t.Fatalf("Expected [US], got %v", res)
+	}
+
+	// SUM(amount) is not selected
+	res = countries(`SELECT country FROM orders GROUP BY country HAVING SUM(amount) > 100 ORDER BY country ASC`)
+	if len(res) != 2 || res[0] != "DE" || res[1] != "FR" {
+		t.Fatalf("Expected [DE FR], got %v", res)
+	}
+
+	res = countries(`SELECT country FROM orders GROUP BY country HAVING COUNT(*) > 1 AND MAX(amount) < 50`)
+	if len(res) != 1 || res[0] != "US" {
+		t.Fatalf("Expected [US], got %v", res)
+	}
+
+	// No group at all
+	res = countries(`SELECT country FROM orders WHERE amount > 1000 GROUP BY country HAVING COUNT(*) > 0`)
+	if len(res) != 0 {
+		t.Fatalf("Expected no rows, got %v", res)
+	}
+}
+
+func TestAggregateEmpty(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestAggregateEmpty")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t (id INT, x INT)`,
+		`CREATE TABLE empty (id INT, x INT)`,
+		`INSERT INTO t (id, x) VALUES (1, 10)`,
+		`INSERT INTO t (id, x) VALUES (2, 20)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	// Without GROUP BY, aggregates always return exactly one row
+	queries := []string{
+		`SELECT COUNT(*), SUM(x), AVG(x), MAX(x), MIN(x) FROM t WHERE false`,
+		`SELECT COUNT(*), SUM(x), AVG(x), MAX(x), MIN(x) FROM t WHERE x > 100`,
+		`SELECT COUNT(*), SUM(x), AVG(x), MAX(x), MIN(x) FROM empty`,
+		`SELECT COUNT(*), SUM(e.x), AVG(e.x), MAX(e.x), MIN(t.x) FROM t JOIN empty e ON t.id = e.id`,
+	}
+	for _, q := range queries {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatalf("%s: %s", q, err)
+		}
+		n := 0
+		for rows.Next() {
+			var count int64
+			var sum, avg, max, min sql.NullString
+			if err := rows.Scan(&count, &sum, &avg, &max, &min); err != nil {
+				t.Fatalf("%s: cannot scan: %s", q, err)
+			}
+			if count != 0 {
+				t.Fatalf("%s: expected COUNT(*) to be 0, got %d", q, count)
+			}
+			if sum.Valid || avg.Valid || max.Valid || min.Valid {
+				t.Fatalf("%s: expected NULL aggregates, got %v %v %v %v", q, sum, avg, max, min)
+			}
+			n++
+		}
+		rows.Close()
+		if n != 1 {
+			t.Fatalf("%s: expected 1 row, got %d", q, n)
+		}
+	}
+
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM t WHERE true`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("expected 2 rows, got %d (%v)", count, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM t WHERE NOT false AND x > 10`).Scan(&count); err != nil || count != 1 {
+		t.Fatalf("expected 1 row, got %d (%v)", count, err)
+	}
+
+	// With GROUP BY, there is no group at all
+	rows, err := db.Query(`SELECT id, COUNT(*) FROM t WHERE false GROUP BY id`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		t.Fatalf("expected no rows")
+	}
+}
+
+func TestAggregateNull(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestAggregateNull")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t (id INT, g TEXT, x INT)`,
+		`CREATE TABLE child (id INT, parent_id INT)`,
+		`INSERT INTO t (id, g, x) VALUES (1, 'a', 10)`,
+		`INSERT INTO t (id, g, x) VALUES (2, 'a', NULL)`,
+		`INSERT INTO t (id, g, x) VALUES (3, 'b', NULL)`,
+		`INSERT INTO t (id, g, x) VALUES (4, 'b', 30)`,
+		`INSERT INTO t (id, g, x) VALUES (5, 'b', 2)`,
+		`INSERT INTO t (id, g, x) VALUES (6, 'c', NULL)`,
+		`INSERT INTO child (id, parent_id) VALUES (1, 1)`,
+		`INSERT INTO child (id, parent_id) VALUES (2, 1)`,
+		`INSERT INTO child (id, parent_id) VALUES (3, 4)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	// COUNT(*) counts every row, other aggregates ignore NULL values
+	var all, count int64
+	var sum, avg, min, max string
+	err = db.QueryRow(`SELECT COUNT(*), COUNT(x), SUM(x), AVG(x), MIN(x), MAX(x) FROM t`).Scan(&all, &count, &sum, &avg, &min, &max)
+	if err != nil {
+		t.Fatalf("sql.QueryRow: %s", err)
+	}
+	if all != 6 || count != 3 || sum != "42" || avg != "14" || min != "2" || max != "30" {
+		t.Fatalf("unexpected aggregates %d %d %s %s %s %s", all, count, sum, avg, min, max)
+	}
+
+	rows, err := db.Query(`SELECT g, COUNT(*), COUNT(x), SUM(x) FROM t GROUP BY g ORDER BY g`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+	expected := []struct {
+		g          string
+		all, count int64
+		sum        sql.NullString
+	}{
+		{"a", 2, 1, sql.NullString{String: "10", Valid: true}},
+		{"b", 3, 2, sql.NullString{String: "32", Valid: true}},
+		{"c", 1, 0, sql.NullString{}},
+	}
+	i := 0
+	for rows.Next() {
+		var g string
+		var s sql.NullString
+		if err := rows.Scan(&g, &all, &count, &s); err != nil {
+			t.Fatalf("cannot scan: %s", err)
+		}
+		if i >= len(expected) || g != expected[i].g || all != expected[i].all || count != expected[i].count || s != expected[i].sum {
+			t.Fatalf("unexpected group %d: %s %d %d %v", i, g, all, count, s)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d groups, got %d", len(expected), i)
+	}
+
+	// COUNT of a column filled with NULL by an outer join
+	err = db.QueryRow(`SELECT COUNT(*), COUNT(c.id) FROM t LEFT JOIN child c ON t.id = c.parent_id`).Scan(&all, &count)
+	if err != nil {
+		t.Fatalf("sql.QueryRow: %s", err)
+	}
+	if all != 7 || count != 3 {
+		t.Fatalf("expected 7 rows and 3 children, got %d and %d", all, count)
+	}
+}
+
+func TestGroupConcat(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestGroupConcat")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t (id INT, g TEXT, name TEXT)`,
+		`INSERT INTO t (id, g, name) VALUES (1, 'a', 'x')`,
+		`INSERT INTO t (id, g, name) VALUES (2, 'a', NULL)`,
+		`INSERT INTO t (id, g, name) VALUES (3, 'a', 'y')`,
+		`INSERT INTO t (id, g, name) VALUES (4, 'b', 'z')`,
+		`INSERT INTO t (id, g, name) VALUES (5, 'c', NULL)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var v sql.NullString
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			if !v.Valid {
+				v.String = "NULL"
+			}
+			res = append(res, v.String)
+		}
+		return res
+	}
+
+	// Values are concatenated in arrival order, NULL being skipped
+	queries := map[string]string{
+		`SELECT GROUP_CONCAT(name) FROM t`:                                                    "x,y,z",
+		`SELECT group_concat(t.name SEPARATOR ' / ') FROM t WHERE g = 'a'`:                    "x / y",
+		`SELECT string_agg(name, ', ') FROM t`:                                                "x, y, z",
+		`SELECT string_agg(id, '') FROM t`:                                                    "12345",
+		`SELECT string_agg(name, ', ') FROM (SELECT name FROM t ORDER BY id DESC) s`:          "z, y, x",
+		`SELECT string_agg(name, ', ') FROM t WHERE false`:                                    "NULL",
+		`SELECT string_agg(name, ',') AS names FROM t GROUP BY g ORDER BY g`:                  "x,y;z;NULL",
+		`SELECT g FROM t GROUP BY g HAVING string_agg(name, '-') = 'x-y'`:                     "a",
+		`SELECT g FROM t GROUP BY g HAVING GROUP_CONCAT(name SEPARATOR '') = 'xy' ORDER BY g`: "a",
+		// Values may be computed
+		`SELECT string_agg(UPPER(name), ',') FROM t`:                             "X,Y,Z",
+		`SELECT string_agg(CAST(id AS TEXT), '+') FROM t WHERE id < 3`:           "1+2",
+		`SELECT GROUP_CONCAT(id * 10 SEPARATOR ',') FROM t WHERE g = 'a'`:        "10,20,30",
+		`SELECT string_agg(t.id::text, ',') AS ids FROM t GROUP BY g ORDER BY g`: "1,2,3;4;5",
+		`SELECT g FROM t GROUP BY g HAVING string_agg(UPPER(name), '') = 'XY'`:   "a",
+	}
+	for query, expected := range queries {
+		if res := strings.Join(values(query), ";"); res != expected {
+			t.Fatalf("%s: expected '%s', got '%s'", query, expected, res)
+		}
+	}
+
+	rows, err := db.Query(`SELECT string_agg(name, ', ') AS names FROM t`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil || len(columns) != 1 || columns[0] != "names" {
+		t.Fatalf("expected names column, got %v (%v)", columns, err)
+	}
+
+	_, err = db.Query(`SELECT string_agg(name, ', ') FROM t GROUP BY g, name SEPARATOR`)
+	if err == nil {
+		t.Fatalf("expected syntax error")
+	}
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// index is a hash index on attributes of a relation, mapping their values to the rows
+// having them. Like equality operator, values are compared with their text representation.
+// Rows with a NULL value are not indexed, since NULL is never equal to anything.
+type index struct {
+	name       string
+	attributes []string
+	rows       map[string][]*Tuple
+}
+
+// indexKey returns the key of given values in index
+func indexKey(values []string) string {
+	return strings.Join(values, "\x01")
+}
+
+// key returns the key of row in index, or false if one of its values is NULL
+func (i *index) key(t *Table, row *Tuple) (string, bool) {
+	var values []string
+
+	for _, a := range i.attributes {
+		v := row.Values[t.attributeIndex(a)]
+		if v == nil {
+			return "", false
+		}
+		values = append(values, fmt.Sprintf("%v", v))
+	}
+
+	return indexKey(values), true
+}
+
+func (i *index) add(t *Table, row *Tuple) {
+	k, ok := i.key(t, row)
+	if !ok {
+		return
+	}
+	i.rows[k] = append(i.rows[k], row)
+}
+
+func (i *index) remove(t *Table, row *Tuple) {
+	k, ok := i.key(t, row)
+	if !ok {
+		return
+	}
+
+	rows := i.rows[k]
+	for j := range rows {
+		if rows[j] == row {
+			rows = append(rows[:j:j], rows[j+1:]...)
+			break
+		}
+	}
+	if len(rows) == 0 {
+		delete(i.rows, k)
+		return
+	}
+	i.rows[k] = rows
+}
+
+// build indexes all given rows, dropping previous content
+func (i *index) build(t *Table, rows []*Tuple) {
+	i.rows = make(map[string][]*Tuple)
+	for _, row := range rows {
+		i.add(t, row)
+	}
+}
+
+// addToIndexes indexes a new row of relation
+func (r *Relation) addToIndexes(row *Tuple) {
+	for _, i := range r.indexes {
+		i.add(r.table, row)
+	}
+	r.addToUniqueSets(row)
+}
+
+// removeFromIndexes removes a deleted row of relation from indexes
+func (r *Relation) removeFromIndexes(row *Tuple) {
+	for _, i := range r.indexes {
+		i.remove(r.table, row)
+	}
+	r.removeFromUniqueSets(row)
+}
+
+// rebuildIndexes indexes all rows again, once they are all replaced
+func (r *Relation) rebuildIndexes() {
+	for _, i := range r.indexes {
+		i.build(r.table, r.rows)
+	}
+	r.buildUniqueSets()
+}
+
+// indexScan returns the rows of relation that may validate predicates, found with an index,
+// or false if no index can be used. Returned rows still have to be checked against all predicates.
+func (r *Relation) indexScan(predicates []PredicateLinker) ([]*Tuple, bool) {
+	i, values := r.scanIndex(predicates)
+	if i == nil {
+		return nil, false
+	}
+
+	return i.rows[indexKey(values)], true
+}
+
+// scanIndex returns the index usable to find rows validating predicates, with the values
+// its attributes are compared with, or nil. An index is used if each of its attributes is
+// compared with a constant by an equality predicate which must be true, that is not under an OR.
+func (r *Relation) scanIndex(predicates []PredicateLinker) (*index, []string) {
+	if len(r.indexes) == 0 {
+		return nil, nil
+	}
+
+	equalities := make(map[string]string)
+	var collect func(p PredicateLinker)
+	collect = func(p PredicateLinker) {
+		switch p := p.(type) {
+		case *andOperator:
+			for _, sub := range p.pred {
+				collect(sub)
+			}
+		case *Predicate:
+			if p.equality && p.LeftValue.table == r.table.name && p.LeftValue.expr == nil &&
+				p.RightValue.expr == nil && p.RightValue.table == "" {
+				equalities[p.LeftValue.lexeme] = p.RightValue.lexeme
+			}
+		}
+	}
+	for _, p := range predicates {
+		collect(p)
+	}
+
+indexes:
+	for _, i := range r.indexes {
+		var values []string
+		for _, a := range i.attributes {
+			v, ok := equalities[a]
+			if !ok {
+				continue indexes
+			}
+			values = append(values, v)
+		}
+		return i, values
+	}
+
+	return nil, nil
+}
+
+/*
+|-> index
+	|-> unique
+	|-> idx_user_email
+	|-> on
+		|-> users
+			|-> email
+*/
+// createIndexExecutor creates a hash index on given attributes of a relation, and indexes
+// existing rows. A unique index is a UNIQUE constraint named after it as well.
+// Without name, index is named after table and attributes, like PostgreSQL.
+func createIndexExecutor(e *Engine, indexDecl *parser.Decl, conn protocol.EngineConn) error {
+	var unique bool
+	var name string
+	var tableDecl *parser.Decl
+
+	for _, d := range indexDecl.Decl {
+		switch d.Token {
+		case parser.UniqueToken:
+			unique = true
+		case parser.OnToken:
+			tableDecl = d.Decl[0]
+		default:
+			name = d.Lexeme
+		}
+	}
+	if tableDecl == nil {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+
+	r := e.relation(tableDecl.Lexeme)
+	if r == nil {
+		return &Error{Code: UndefinedTable, Table: tableDecl.Lexeme, Message: fmt.Sprintf("relation \"%s\" does not exist", tableDecl.Lexeme)}
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	i := &index{name: name}
+	for _, d := range tableDecl.Decl {
+		if r.table.attributeIndex(d.Lexeme) < 0 {
+			return &Error{Code: UndefinedColumn, Table: tableDecl.Lexeme, Column: d.Lexeme, Message: fmt.Sprintf("column \"%s\" does not exist", d.Lexeme)}
+		}
+		i.attributes = append(i.attributes, d.Lexeme)
+	}
+	if i.name == "" {
+		i.name = r.table.name + "_" + strings.Join(i.attributes, "_") + "_idx"
+	}
+
+	// Existing rows must validate UNIQUE constraint
+	c := uniqueConstraint{name: i.name, attributes: i.attributes}
+	if unique {
+		t := &Table{name: r.table.name, attributes: r.table.attributes, unique: []uniqueConstraint{c}}
+		if err := t.checkUnique(r.rows); err != nil {
+			return fmt.Errorf("could not create unique index \"%s\": %s", i.name, err)
+		}
+	}
+
+	e.Lock()
+	if _, ok := e.indexes[i.name]; ok {
+		e.Unlock()
+		return &Error{Code: DuplicateTable, Table: i.name, Message: fmt.Sprintf("relation \"%s\" already exists", i.name)}
+	}
+	e.indexes[i.name] = r
+	e.Unlock()
+
+	i.build(r.table, r.rows)
+	r.indexes = append(r.indexes, i)
+	if unique {
+		r.table.unique = append(r.table.unique, c)
+	}
+
+	return conn.WriteResult(0, 1)
+}
+
+// dropIndexExecutor removes an index, and the UNIQUE constraint of a unique one.
+// With ifExists, dropping a missing index does nothing.
+func dropIndexExecutor(e *Engine, name string, ifExists bool, conn protocol.EngineConn) error {
+	e.Lock()
+	r, ok := e.indexes[name]
+	delete(e.indexes, name)
+	e.Unlock()
+	if !ok {
+		if ifExists {
+			return conn.WriteResult(0, 0)
+		}
+		return errorf(UndefinedObject, "index \"%s\" does not exist", name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.dropIndex(name)
+
+	return conn.WriteResult(0, 1)
+}
+
+// dropIndex removes named index from relation, with its UNIQUE constraint if any.
+// Index must already be removed from engine.
+func (r *Relation) dropIndex(name string) {
+	for j, i := range r.indexes {
+		if i.name == name {
+			r.indexes = append(r.indexes[:j:j], r.indexes[j+1:]...)
+			break
+		}
+	}
+
+	for j, c := range r.table.unique {
+		if c.name == name {
+			r.table.unique = append(r.table.unique[:j:j], r.table.unique[j+1:]...)
+			break
+		}
+	}
+}
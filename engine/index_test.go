@@ -0,0 +1,166 @@
+package engine_test
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestIndex(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestIndex")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id BIGSERIAL, email TEXT, country TEXT, city TEXT)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO users (email, country, city) VALUES ($1, $2, $3)`,
+			fmt.Sprintf("user%d@example.com", i), fmt.Sprintf("country%d", i%10), fmt.Sprintf("city%d", i%3))
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	batch := []string{
+		`CREATE INDEX idx_user_email ON users(email)`,
+		`CREATE INDEX idx_user_location ON users (country, city)`,
+		`INSERT INTO users (email, country, city) VALUES ('late@example.com', 'country1', 'city2')`,
+		`UPDATE users SET email = 'renamed@example.com' WHERE email = 'user42@example.com'`,
+		`DELETE FROM users WHERE email = 'user7@example.com'`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	count := func(query string, args ...interface{}) int {
+		var n int
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("Cannot count with '%s': %s", query, err)
+		}
+		return n
+	}
+
+	expected := map[string]int{
+		`SELECT COUNT(*) FROM users WHERE email = 'user3@example.com'`:                         1,
+		`SELECT COUNT(*) FROM users WHERE email = 'late@example.com'`:                          1,
+		`SELECT COUNT(*) FROM users WHERE email = 'renamed@example.com'`:                       1,
+		`SELECT COUNT(*) FROM users WHERE email = 'user42@example.com'`:                        0,
+		`SELECT COUNT(*) FROM users WHERE email = 'user7@example.com'`:                         0,
+		`SELECT COUNT(*) FROM users WHERE email = 'user3@example.com' AND id = 4`:              1,
+		`SELECT COUNT(*) FROM users WHERE email = 'user3@example.com' AND id = 5`:              0,
+		`SELECT COUNT(*) FROM users WHERE email = 'user3@example.com' OR id = 5`:               2,
+		`SELECT COUNT(*) FROM users WHERE country = 'country1' AND city = 'city2'`:             4,
+		`SELECT COUNT(*) FROM users WHERE users.country = 'country1' AND users.city = 'city2'`: 4,
+		`SELECT COUNT(*) FROM users WHERE country = 'country1'`:                                11,
+		`SELECT COUNT(*) FROM users u WHERE u.email = 'user3@example.com'`:                     1,
+	}
+	for query, n := range expected {
+		if got := count(query); got != n {
+			t.Fatalf("Expected %d rows with '%s', got %d", n, query, got)
+		}
+	}
+	if got := count(`SELECT COUNT(*) FROM users WHERE email = $1`, "user5@example.com"); got != 1 {
+		t.Fatalf("Expected 1 row with placeholder, got %d", got)
+	}
+
+	// Indexes follow schema changes
+	batch = []string{
+		`ALTER TABLE users ADD COLUMN age INT DEFAULT 0`,
+		`ALTER TABLE users RENAME COLUMN email TO mail`,
+		`ALTER TABLE users DROP COLUMN city`,
+		`TRUNCATE users`,
+		`INSERT INTO users (mail, country) VALUES ('again@example.com', 'country1')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+	if got := count(`SELECT COUNT(*) FROM users WHERE mail = 'again@example.com'`); got != 1 {
+		t.Fatalf("Expected 1 row after schema changes, got %d", got)
+	}
+
+	_, err = db.Exec(`CREATE INDEX idx_user_email ON users (country)`)
+	if err == nil {
+		t.Fatalf("Expected error creating an index with an existing name")
+	}
+	_, err = db.Exec(`CREATE INDEX idx_user_age ON users (unknown)`)
+	if err == nil {
+		t.Fatalf("Expected error creating an index on an unknown column")
+	}
+
+	_, err = db.Exec(`DROP INDEX idx_user_email`)
+	if err != nil {
+		t.Fatalf("Cannot drop index: %s", err)
+	}
+	_, err = db.Exec(`DROP INDEX idx_user_email`)
+	if err == nil {
+		t.Fatalf("Expected error dropping a dropped index")
+	}
+	// Dropping a column dropped its index
+	_, err = db.Exec(`DROP INDEX idx_user_location`)
+	if err == nil {
+		t.Fatalf("Expected index on dropped column to be dropped")
+	}
+	if got := count(`SELECT COUNT(*) FROM users WHERE mail = 'again@example.com'`); got != 1 {
+		t.Fatalf("Expected 1 row without index, got %d", got)
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUniqueIndex")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE accounts (id BIGSERIAL, login TEXT, team TEXT)`,
+		`INSERT INTO accounts (login, team) VALUES ('alice', 'red')`,
+		`INSERT INTO accounts (login, team) VALUES ('bob', 'red')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	_, err = db.Exec(`CREATE UNIQUE INDEX idx_account_team ON accounts (team)`)
+	if err == nil {
+		t.Fatalf("Expected error creating unique index on duplicate values")
+	}
+
+	_, err = db.Exec(`CREATE UNIQUE INDEX idx_account_login ON accounts USING hash (login)`)
+	if err != nil {
+		t.Fatalf("Cannot create unique index: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO accounts (login, team) VALUES ('alice', 'blue')`)
+	if err == nil || !strings.Contains(err.Error(), `"idx_account_login"`) {
+		t.Fatalf("Expected violation of unique index, got %v", err)
+	}
+
+	_, err = db.Exec(`DROP INDEX idx_account_login`)
+	if err != nil {
+		t.Fatalf("Cannot drop index: %s", err)
+	}
+	_, err = db.Exec(`INSERT INTO accounts (login, team) VALUES ('alice', 'blue')`)
+	if err != nil {
+		t.Fatalf("Cannot insert duplicate once unique index is dropped: %s", err)
+	}
+}
@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -19,45 +18,146 @@ import (
             |-> first_name
             |-> email
     |-> VALUES
-        |-> Roullon
-        |-> Pierre
-        |-> pierre.roullon@gmail.com
+        |-> (
+            |-> Roullon
+            |-> Pierre
+            |-> pierre.roullon@gmail.com
 */
+// insertIntoTableExecutor inserts rows given with VALUES clause, or selected by a SELECT
+// statement. Without attributes list, values are given for all attributes of table.
 func insertIntoTableExecutor(e *Engine, insertDecl *parser.Decl, conn protocol.EngineConn) error {
 
-	// Get table and concerned attributes and write lock it
+	// Get table and concerned attributes
 	r, attributes, err := getRelation(e, insertDecl.Decl[0])
 	if err != nil {
 		return err
 	}
+
+	// Get values of rows, selected before write locking table since it may be read
+	values, err := insertedValues(e, insertDecl.Decl[1], len(attributes))
+	if err != nil {
+		return err
+	}
 	r.Lock()
 	defer r.Unlock()
 
 	// Check for RETURNING clause
-	var returnedID string
-	if len(insertDecl.Decl) > 2 {
-		for i := range insertDecl.Decl {
-			if insertDecl.Decl[i].Token == parser.ReturningToken {
-				returnedID = insertDecl.Decl[i].Lexeme
-				break
+	ret, err := returningExecutor(r.table, insertDecl.Decl)
+	if err != nil {
+		return err
+	}
+
+	// Check for ON CONFLICT clause
+	now := time.Now()
+	conflict, err := conflictExecutor(e, r, insertDecl.Decl, now)
+	if err != nil {
+		return err
+	}
+
+	// Get triggers fired by inserted rows, and by updated ones on conflict
+	locked := map[*Relation]bool{r: true}
+	defer func() {
+		for l := range locked {
+			if l != r {
+				l.RUnlock()
 			}
 		}
+	}()
+	inserting, err := triggersExecutor(e, r, parser.InsertToken, locked)
+	if err != nil {
+		return err
 	}
-
-	// Create a new tuple with values
-	id, err := insert(r, attributes, insertDecl.Decl[1].Decl, returnedID)
+	var updating *rowTriggers
+	if conflict != nil && !conflict.nothing {
+		if updating, err = triggersExecutor(e, r, parser.UpdateToken, locked); err != nil {
+			return err
+		}
+	}
+	checks, err := checksExecutor(e, r.table, locked)
 	if err != nil {
 		return err
 	}
 
-	// if RETURNING decl is not present
-	if returnedID != "" {
-		conn.WriteRowHeader([]string{returnedID})
-		conn.WriteRow([]string{fmt.Sprintf("%v", id)})
-		conn.WriteRowEnd()
-	} else {
-		conn.WriteResult(id, 1)
+	// Rows are all written, or none if one of them violates a constraint.
+	// Inserted rows are appended, and previous version of updated ones is kept.
+	var written []*Tuple
+	var id int64
+	n := len(r.rows)
+	previous := make(map[int]*Tuple)
+	rollback := func(err error) error {
+		if len(written) == 0 && len(previous) == 0 {
+			return err
+		}
+		for i, t := range previous {
+			r.rows[i] = t
+		}
+		r.rows = r.rows[:n]
+		r.rebuildIndexes()
+		return err
 	}
+
+	for _, v := range values {
+		// Create a new tuple with values
+		t, tupleID, err := newTuple(e, r, attributes, v, now)
+		if err != nil {
+			return rollback(err)
+		}
+		if t, err = inserting.fireBefore(nil, t); err != nil {
+			return rollback(err)
+		}
+		if err := r.table.checkNotNull(t); err != nil {
+			return rollback(err)
+		}
+		if err := checks.check(t); err != nil {
+			return rollback(err)
+		}
+
+		// Insert it, unless it conflicts with an existing row
+		i := -1
+		if conflict != nil {
+			i = conflict.row(r, t)
+		}
+		if i < 0 {
+			if err := insertTuple(r, t); err != nil {
+				return rollback(err)
+			}
+			written = append(written, t)
+			id = tupleID
+			if err := inserting.fireAfter(nil, t); err != nil {
+				return rollback(err)
+			}
+			continue
+		}
+
+		// Conflicting row is updated instead, or left as is with DO NOTHING
+		if conflict.nothing {
+			continue
+		}
+		if _, ok := previous[i]; ok || i >= n {
+			return rollback(fmt.Errorf("ON CONFLICT DO UPDATE command cannot affect row a second time"))
+		}
+		previous[i] = r.rows[i]
+		if t, err = conflict.update(e, r, i, t, now, updating, checks); err != nil {
+			return rollback(err)
+		}
+		written = append(written, t)
+	}
+
+	// Foreign keys are checked once all rows are written, since they may reference each other
+	if err := e.checkForeignKeys(r, written, r.rows, locked); err != nil {
+		return rollback(err)
+	}
+	if len(previous) > 0 {
+		if err := e.checkReferences(r, r.rows, locked); err != nil {
+			return rollback(err)
+		}
+	}
+
+	// if RETURNING decl is present, send written rows
+	if ret != nil {
+		return ret.write(conn, written)
+	}
+	conn.WriteResult(id, int64(len(written)))
 	return nil
 }
 
@@ -73,7 +173,7 @@ func getRelation(e *Engine, intoDecl *parser.Decl) (*Relation, []*parser.Decl, e
 	// Decl[0] is the table name
 	r := e.relation(intoDecl.Decl[0].Lexeme)
 	if r == nil {
-		return nil, nil, errors.New("table " + intoDecl.Decl[0].Lexeme + " does not exists")
+		return nil, nil, &Error{Code: UndefinedTable, Table: intoDecl.Decl[0].Lexeme, Message: "table " + intoDecl.Decl[0].Lexeme + " does not exists"}
 	}
 
 	for i := range intoDecl.Decl[0].Decl {
@@ -83,81 +183,164 @@ func getRelation(e *Engine, intoDecl *parser.Decl) (*Relation, []*parser.Decl, e
 		}
 	}
 
-	return r, intoDecl.Decl[0].Decl, nil
+	// Without attributes, values are given for all attributes of table, in order
+	attributes := intoDecl.Decl[0].Decl
+	if len(attributes) == 0 {
+		for _, a := range r.table.attributes {
+			attributes = append(attributes, parser.NewDecl(parser.Token{Token: parser.StringToken, Lexeme: a.name}))
+		}
+	}
+
+	return r, attributes, nil
+}
+
+/*
+|-> SELECT
+	|-> *
+	|-> FROM
+		|-> events
+*/
+// insertedValues returns values of rows to insert, given with VALUES clause or selected.
+// Selected values are given as literals, which attributes types apply to like VALUES ones.
+func insertedValues(e *Engine, decl *parser.Decl, attributes int) ([][]*parser.Decl, error) {
+	var rows [][]*parser.Decl
+
+	if !isQuery(decl) {
+		for _, rowDecl := range decl.Decl {
+			rows = append(rows, rowDecl.Decl)
+		}
+		return rows, nil
+	}
+
+	locked := make(map[*Relation]bool)
+	res, err := subqueryExecutor(e, decl, locked)
+	for l := range locked {
+		l.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res.header) > attributes {
+		return nil, fmt.Errorf("INSERT has more expressions than target columns")
+	}
+	if len(res.header) < attributes {
+		return nil, fmt.Errorf("INSERT has more target columns than expressions")
+	}
+
+	for _, row := range res.rows {
+		values := make([]*parser.Decl, len(row))
+		for i, v := range row {
+			if v == nil {
+				values[i] = parser.NewDecl(parser.Token{Token: parser.NullToken, Lexeme: "null"})
+				continue
+			}
+			values[i] = parser.NewDecl(parser.Token{Token: parser.StringToken, Lexeme: fmt.Sprintf("%v", v)})
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
 }
 
 type f func() interface{}
 
-func insert(r *Relation, attributes []*parser.Decl, values []*parser.Decl, returnedID string) (int64, error) {
+// newTuple creates a tuple with given values, defaults and sequence values, whose NOT NULL
+// constraints are checked once BEFORE triggers have run. It returns it with the value
+// allocated to its auto increment attribute, if any.
+// now is the current time of the statement, so that all values and defaults using it are the same.
+func newTuple(e *Engine, r *Relation, attributes []*parser.Decl, values []*parser.Decl, now time.Time) (*Tuple, int64, error) {
+	if len(values) > len(attributes) {
+		return nil, 0, fmt.Errorf("INSERT has more expressions than target columns")
+	}
+	if len(values) < len(attributes) {
+		return nil, 0, fmt.Errorf("INSERT has more target columns than expressions")
+	}
+
 	var assigned = false
 	var id int64
-	var valuesindex int
 
 	// Create tuple
 	t := NewTuple()
-	for attrindex, attr := range r.table.attributes {
+	for _, attr := range r.table.attributes {
 		assigned = false
 
 		for x, decl := range attributes {
 
-			if attr.name == decl.Lexeme && attr.autoIncrement == false {
-				// Before adding value in tuple, check it's not a builtin func or arithmetic operation
-				switch values[x].Token {
-				case parser.NowToken:
-					t.Append(time.Now().Format(parser.DateLongFormat))
-				default:
-					t.Append(values[x].Lexeme)
+			// DEFAULT keyword leaves attribute to its default value
+			if attr.name != decl.Lexeme || values[x].Token == parser.DefaultToken {
+				continue
+			}
 
+			// Explicit value of an auto increment attribute advances its sequence past it
+			if attr.autoIncrement && values[x].Token != parser.NullToken {
+				v, err := strconv.ParseInt(values[x].Lexeme, 10, 64)
+				if err != nil {
+					return nil, 0, errorf(InvalidTextRepresentation, "invalid input syntax for type %s: %s", attr.typeName, values[x].Lexeme)
 				}
-				valuesindex = x
+				attr.sequence.advance(v)
+				id = v
+				t.Append(v)
 				assigned = true
-				if returnedID == attr.name {
-					var err error
-					id, err = strconv.ParseInt(values[x].Lexeme, 10, 64)
-					if err != nil {
-						return 0, err
-					}
+				continue
+			}
+
+			// Before adding value in tuple, check it's not a builtin func or arithmetic operation
+			switch values[x].Token {
+			case parser.NullToken:
+				t.Append(nil)
+			case parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken:
+				t.Append(now.Format(parser.DateLongFormat))
+			case parser.FunctionToken, parser.CaseToken, parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken:
+				// Function call or arithmetic operation, whose operands are constants
+				expr, err := expressionExecutor(e, values[x], nil, nil)
+				if err != nil {
+					return nil, 0, err
 				}
+				v, err := expr.eval(virtualRow{})
+				if err != nil {
+					return nil, 0, err
+				}
+				t.Append(v)
+			default:
+				t.Append(values[x].Lexeme)
+
 			}
+			assigned = true
 		}
 
-		// If attribute is AUTO INCREMENT, compute it and assign it
-		if attr.autoIncrement {
+		// If attribute is AUTO INCREMENT, allocate next value of its sequence
+		if attr.autoIncrement && !assigned {
 			assigned = true
-			id = int64(len(r.rows) + 1)
+			id = attr.sequence.next()
 			t.Append(id)
 		}
 
-		// Do we have a UNIQUE attribute ? if so
-		if attr.unique {
-			for i := range r.rows { // check all value already in relation (yup, no index tree)
-				if r.rows[i].Values[attrindex].(string) == string(values[valuesindex].Lexeme) {
-					return 0, fmt.Errorf("UNIQUE constraint violation")
-				}
-			}
-		}
-
 		// If values was not explictly given, set default value
 		if assigned == false {
-			switch val := attr.defaultValue.(type) {
-			case func() interface{}:
-				v := (func() interface{})(val)()
-				log.Debug("Setting func value '%v' to %s\n", v, attr.name)
-				t.Append(v)
-			default:
-				log.Debug("Setting default value '%v' to %s\n", val, attr.name)
-				t.Append(attr.defaultValue)
+			v, err := attr.defaultTupleValue(e, now)
+			if err != nil {
+				return nil, 0, err
 			}
+			log.Debug("Setting default value '%v' to %s\n", v, attr.name)
+			t.Append(v)
 		}
 	}
 
 	log.Info("New tuple : %v", t)
 
-	// Insert tuple
-	err := r.Insert(t)
-	if err != nil {
-		return 0, err
+	if err := r.table.convertValues(t); err != nil {
+		return nil, 0, err
 	}
 
-	return id, nil
+	return t, id, nil
+}
+
+// insertTuple inserts tuple in relation, checking UNIQUE constraints
+func insertTuple(r *Relation, t *Tuple) error {
+	if err := r.checkUniqueChanges(nil, []*Tuple{t}); err != nil {
+		return err
+	}
+
+	// Insert tuple
+	return r.Insert(t)
 }
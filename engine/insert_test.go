@@ -0,0 +1,171 @@
+package engine_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestInsertMultipleRows(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInsertMultipleRows")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE t (id BIGSERIAL PRIMARY KEY, a INT UNIQUE, b TEXT)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+
+	res, err := db.Exec(`INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, 'z')`)
+	if err != nil {
+		t.Fatalf("Cannot insert multiple rows: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Fatalf("Expected 3 rows affected, got %d", n)
+	}
+	if id, _ := res.LastInsertId(); id != 3 {
+		t.Fatalf("Expected last insert id 3, got %d", id)
+	}
+
+	count := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM t`).Scan(&n); err != nil {
+			t.Fatalf("Cannot count rows: %s", err)
+		}
+		return n
+	}
+
+	// A constraint violation inserts no row
+	rejected := []string{
+		`INSERT INTO t (a, b) VALUES (4, 'w'), (1, 'dup')`,
+		`INSERT INTO t (a, b) VALUES (4, 'w'), (4, 'dup')`,
+		`INSERT INTO t (a, b) VALUES (4, 'w'), (5)`,
+		`INSERT INTO t (a, b) VALUES (4, 'w'), (5, 'v', 'u')`,
+		`INSERT INTO t (a, b) VALUES (4, 'w'), (1, 'x') ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, id = 2`,
+		`INSERT INTO t (a, b) VALUES (1, 'w'), (1, 'x') ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b`,
+	}
+	for _, query := range rejected {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+		if n := count(); n != 3 {
+			t.Fatalf("Expected 3 rows after '%s', got %d", query, n)
+		}
+	}
+	var b string
+	if err := db.QueryRow(`SELECT b FROM t WHERE a = 1`).Scan(&b); err != nil || b != "x" {
+		t.Fatalf("Expected row left as is, got '%s' (%v)", b, err)
+	}
+	var a int
+	if err := db.QueryRow(`SELECT a FROM t WHERE id = 2`).Scan(&a); err != nil || a != 2 {
+		t.Fatalf("Expected row 2 left as is, got %d (%v)", a, err)
+	}
+
+	// Rows are written in order, with RETURNING
+	rows, err := db.Query(`INSERT INTO t (a, b) VALUES (1, 'updated'), (6, 'new'), (2, 'ignored') ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b RETURNING a, b`)
+	if err != nil {
+		t.Fatalf("Cannot upsert multiple rows: %s", err)
+	}
+	var got []string
+	for rows.Next() {
+		if err := rows.Scan(&a, &b); err != nil {
+			t.Fatalf("rows.Scan: %s", err)
+		}
+		got = append(got, b)
+	}
+	rows.Close()
+	if len(got) != 3 || got[0] != "updated" || got[1] != "new" || got[2] != "ignored" {
+		t.Fatalf("Unexpected returned rows: %v", got)
+	}
+	if n := count(); n != 4 {
+		t.Fatalf("Expected 4 rows, got %d", n)
+	}
+}
+
+func TestInsertSelect(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInsertSelect")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id BIGSERIAL PRIMARY KEY, ts INT, name TEXT)`,
+		`CREATE TABLE archive (id BIGINT PRIMARY KEY, ts INT, name TEXT)`,
+		`CREATE TABLE names (name TEXT, created_at TIMESTAMP DEFAULT now())`,
+		`INSERT INTO events VALUES (DEFAULT, 10, 'a'), (DEFAULT, 20, 'b'), (DEFAULT, 30, NULL)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	res, err := db.Exec(`INSERT INTO archive SELECT * FROM events WHERE ts < $1`, 25)
+	if err != nil {
+		t.Fatalf("Cannot insert selected rows: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", n)
+	}
+
+	// Selected values follow attributes list, and defaults apply to other attributes
+	_, err = db.Exec(`INSERT INTO names (name) SELECT name FROM events ORDER BY id DESC`)
+	if err != nil {
+		t.Fatalf("Cannot insert selected rows: %s", err)
+	}
+	var nulls int
+	err = db.QueryRow(`SELECT COUNT(*) FROM names WHERE name IS NULL AND created_at IS NOT NULL`).Scan(&nulls)
+	if err != nil || nulls != 1 {
+		t.Fatalf("Expected NULL name to be copied, got %d (%v)", nulls, err)
+	}
+
+	// Table may be read while inserting into it
+	rows, err := db.Query(`INSERT INTO events (ts, name) SELECT ts + 100, name FROM events WHERE name IS NOT NULL RETURNING id, ts`)
+	if err != nil {
+		t.Fatalf("Cannot insert rows selected from same table: %s", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id, ts int64
+		if err := rows.Scan(&id, &ts); err != nil {
+			t.Fatalf("rows.Scan: %s", err)
+		}
+		if ts <= 100 {
+			t.Fatalf("Expected computed ts, got %d", ts)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Fatalf("Expected ids 4 and 5, got %v", ids)
+	}
+
+	rejected := []string{
+		`INSERT INTO archive (name) SELECT name, ts FROM events`,
+		`INSERT INTO archive (id, name) SELECT id FROM events`,
+		`INSERT INTO archive SELECT id, ts FROM events`,
+		`INSERT INTO archive SELECT * FROM events WHERE ts = 10`,
+		`INSERT INTO archive SELECT * FROM unknown`,
+	}
+	for _, query := range rejected {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM archive`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("Expected 2 archived rows, got %d (%v)", count, err)
+	}
+}
@@ -24,236 +24,240 @@ func (v virtualRow) String() string {
 	return l1 + l2
 }
 
-// 3 types of predicates
+// key returns a string identifying values of given attributes,
+// so rows can be compared. NULL values are considered equal.
+func (v virtualRow) key(attributes []string) (string, error) {
+	var values []string
+
+	for _, attr := range attributes {
+		val, ok := v[attr]
+		if !ok {
+			return "", fmt.Errorf("could not find attribute %s in virtual row", attr)
+		}
+		if val.v == nil {
+			values = append(values, "\x00")
+			continue
+		}
+		values = append(values, fmt.Sprintf("%v", val.v))
+	}
+
+	return strings.Join(values, "\x01"), nil
+}
+
+// with returns a copy of the virtual row extended with the values of given tuple
+func (v virtualRow) with(r *Relation, t *Tuple) virtualRow {
+	row := make(virtualRow, len(v)+len(t.Values))
+	for key, val := range v {
+		row[key] = val
+	}
+
+	for index := range t.Values {
+		val := Value{
+			v:      t.Values[index],
+			valid:  true,
+			lexeme: r.table.attributes[index].name,
+			table:  r.table.name,
+		}
+		row[val.table+"."+val.lexeme] = val
+	}
+
+	return row
+}
+
+// 4 types of join
 // INNER, LEFT, RIGHT, FULL
 // with NATURAL option
 type joiner interface {
-	Evaluate(virtualRow, *Relation, int) (bool, error)
-	On() string
+	Join(e *Engine, rows []virtualRow) ([]virtualRow, error)
 }
 
-// default joiner implementation
+// default joiner implementation, combining every virtual row with every
+// row of the relation validating the ON predicate.
+// Tables listed in FROM clause are joined with an always true predicate.
 type inner struct {
-	table   string
-	t1Value Value
-	t2Value Value
+	relation  *Relation
+	predicate PredicateLinker
 }
 
-func (i *inner) On() string {
-	return i.table
-}
-
-func (i *inner) Evaluate(row virtualRow, r *Relation, index int) (bool, error) {
-	var t1, t2 Value
+func (i *inner) Join(e *Engine, rows []virtualRow) ([]virtualRow, error) {
+	var res []virtualRow
 
-	// I want t1 to be the attribute already in the virtual row
-	// So if t1 table is the current one...swap !
-	if i.t1Value.table == r.table.name {
-		t1 = i.t2Value
-		i.t2Value = i.t1Value
-		i.t1Value = t1
-	}
-
-	// let's find t1Value
-	val, ok := row[i.t1Value.table+"."+i.t1Value.lexeme]
-	if !ok {
-		return false, fmt.Errorf("JOIN: joining on %s, not found", i.t1Value.table+"."+i.t1Value.lexeme)
-	}
-	t1 = val
-
-	if r.table.name != i.t2Value.table {
-		return false, fmt.Errorf("JOIN: joining on table %s, got %s", i.t2Value.table, r.table.name)
-	}
-	for attrIndex, attr := range r.table.attributes {
-		if attr.name == i.t2Value.lexeme {
-			t2 = Value{
-				v:      r.rows[index].Values[attrIndex],
-				lexeme: attr.name,
-				table:  r.table.name,
-				valid:  true,
+	for _, row := range rows {
+		if err := e.canceled(); err != nil {
+			return nil, err
+		}
+		for _, t := range i.relation.rows {
+			joined := row.with(i.relation, t)
+			ok, err := i.predicate.Eval(joined)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				res = append(res, joined)
 			}
-			break
 		}
 	}
-	if t2.valid == false {
-		return false, fmt.Errorf("JOIN: joining on table %s, attribute %s not found", i.t2Value.table, i.t2Value.lexeme)
-	}
 
-	// let's say for now the only operator is '='
-	if fmt.Sprintf("%v", t1.v) == fmt.Sprintf("%v", t2.v) {
-		return true, nil
-	}
+	return res, nil
+}
 
-	return false, nil
+// outer joiner keeps virtual rows (LEFT JOIN), relation rows (RIGHT JOIN)
+// or both (FULL JOIN) even if they have no match, the missing side being NULL
+type outer struct {
+	relation  *Relation
+	predicate PredicateLinker
+	left      bool
+	right     bool
+	// tables already in virtual rows, to pad unmatched relation rows
+	tables []*Table
 }
 
-// The optional WHERE, GROUP BY, and HAVING clauses in the table expression specify a pipeline of successive transformations performed on the table derived in the FROM clause.
-// All these transformations produce a virtual table that provides the rows that are passed to the select list to compute the output rows of the query.
-func generateVirtualRows(e *Engine, attr []Attribute, conn protocol.EngineConn, t1Name string, joinPredicates []joiner, selectPredicates []PredicateLinker, functors []selectFunctor) error {
+func (o *outer) Join(e *Engine, rows []virtualRow) ([]virtualRow, error) {
+	var res []virtualRow
 
-	// get t1 and lock it
-	t1 := e.relation(t1Name)
-	if t1 == nil {
-		return fmt.Errorf("table %s not found", t1Name)
-	}
-	t1.RLock()
-	defer t1.RUnlock()
-
-	// all joined tables in a map of relation
-	relations := make(map[string]*Relation)
-	for _, j := range joinPredicates {
-		r := e.relation(j.On())
-		if r == nil {
-			return fmt.Errorf("table %s not found", j.On())
+	matched := make([]bool, len(o.relation.rows))
+	for _, row := range rows {
+		if err := e.canceled(); err != nil {
+			return nil, err
 		}
-		r.RLock()
-		defer r.RUnlock()
-		relations[j.On()] = r
-	}
-
-	// Write header
-	var header []string
-	var alias []string
-	for _, a := range attr {
-		alias = append(alias, a.name)
-		if strings.Contains(a.name, ".") == false {
-			a.name = t1Name + "." + a.name
+		rowMatched := false
+		for i, t := range o.relation.rows {
+			joined := row.with(o.relation, t)
+			ok, err := o.predicate.Eval(joined)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				res = append(res, joined)
+				rowMatched = true
+				matched[i] = true
+			}
 		}
-		header = append(header, a.name)
-	}
 
-	// Initialize functors here
-	for i := range functors {
-		if err := functors[i].Init(e, conn, header, alias); err != nil {
-			return err
+		if o.left && !rowMatched {
+			res = append(res, row.with(o.relation, nullTuple(o.relation.table)))
 		}
 	}
 
-	// for each row in t1
-	for i := range t1.rows {
-		// create virtualrow
-		row := make(virtualRow)
-		for index := range t1.rows[i].Values {
-			v := Value{
-				v:      t1.rows[i].Values[index],
-				valid:  true,
-				lexeme: t1.table.attributes[index].name,
-				table:  t1Name,
+	if o.right {
+		null := make(virtualRow)
+		for _, t := range o.tables {
+			for _, attr := range t.attributes {
+				null[t.name+"."+attr.name] = Value{valid: true, lexeme: attr.name, table: t.name}
 			}
-			row[v.table+"."+v.lexeme] = v
 		}
 
-		// for first join predicates
-		err := join(row, relations, joinPredicates, 0, selectPredicates, functors)
-		if err != nil {
-			return err
+		for i, t := range o.relation.rows {
+			if !matched[i] {
+				res = append(res, null.with(o.relation, t))
+			}
 		}
-
 	}
 
+	return res, nil
+}
+
+// nullTuple returns a tuple of given table with every value set to NULL
+func nullTuple(t *Table) *Tuple {
+	return &Tuple{Values: make([]interface{}, len(t.attributes))}
+}
+
+// The optional WHERE, GROUP BY, and HAVING clauses in the table expression specify a pipeline of successive transformations performed on the table derived in the FROM clause.
+// All these transformations produce a virtual table that provides the rows that are passed to the select list to compute the output rows of the query.
+func generateVirtualRows(e *Engine, conn protocol.EngineConn, header []string, alias []string, from *Relation, joiners []joiner, selectPredicates []PredicateLinker, functors []selectFunctor) error {
+	var err error
+
+	// Initialize functors here
 	for i := range functors {
-		err := functors[i].Done()
-		if err != nil {
+		if err := functors[i].Init(e, conn, header, alias); err != nil {
 			return err
 		}
 	}
-	return nil
-}
-
-// Recursive virtual row creation
-func join(row virtualRow, relations map[string]*Relation, predicates []joiner, predicateIndex int, selectPredicates []PredicateLinker, functors []selectFunctor) error {
 
-	// Skip directly to selectRows if there is no joiner to run
-	if len(predicates) == 0 {
-		return selectRows(row, selectPredicates, functors)
+	// create a virtualrow for each row in first table, found with an index if possible
+	tuples := from.rows
+	if indexed, ok := from.indexScan(selectPredicates); ok {
+		tuples = indexed
 	}
-
-	// get current predicates
-	predicate := predicates[predicateIndex]
-
-	// last := is it last join ?
-	last := false
-	if predicateIndex >= len(predicates)-1 {
-		last = true
+	rows := make([]virtualRow, 0, len(tuples))
+	for _, t := range tuples {
+		rows = append(rows, virtualRow{}.with(from, t))
 	}
 
-	// for each row in relations[pred.Table()]
-	r := relations[predicate.On()]
-	for i := range r.rows {
-		ok, err := predicate.Evaluate(row, r, i)
+	// then run each join in order
+	for _, j := range joiners {
+		rows, err = j.Join(e, rows)
 		if err != nil {
 			return err
 		}
-		// if predicate not ok
-		if !ok {
-			continue
-		}
-
-		// combine columns to existing virtual row
-		for index := range r.rows[i].Values {
-			v := Value{
-				v:      r.rows[i].Values[index],
-				valid:  true,
-				lexeme: r.table.attributes[index].name,
-				table:  r.table.name,
-			}
-			row[v.table+"."+v.lexeme] = v
-		}
+	}
 
-		// if last predicate
-		if last {
-			err = selectRows(row, selectPredicates, functors)
-		} else {
-			err = join(row, relations, predicates, predicateIndex+1, selectPredicates, functors)
+	for _, row := range rows {
+		if err = e.canceled(); err != nil {
+			return err
 		}
+		err = selectRows(row, selectPredicates, functors)
 		if err != nil {
 			return err
 		}
 	}
 
+	for i := range functors {
+		err := functors[i].Done()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 /*
 -> join
        |-> user_project
+           |-> as
+               |-> up
        |-> on
            |-> project_id
                |-> user_project
-           |-> =
-           |-> id
-               |-> project
+               |-> =
+               |-> id
+                   |-> project
 
 */
-func joinExecutor(decl *parser.Decl) (joiner, error) {
+// joinExecutor returns the joiner of r with tables already selected. In a correlated
+// subquery, ON condition may use columns of outer query as well.
+func joinExecutor(e *Engine, decl *parser.Decl, r *Relation, tables []*Table, locked map[*Relation]bool, correlated *correlation) (joiner, error) {
 	decl.Stringy(0)
 
-	j := &inner{}
-
-	// Table name
-	if decl.Decl[0].Token != parser.StringToken {
-		return nil, fmt.Errorf("join: expected table name, got %v", decl.Decl[0])
-	}
-	j.table = decl.Decl[0].Lexeme
-
 	// Predicate should be ON
-	on := decl.Decl[1]
-	if on.Token != parser.OnToken {
-		return nil, fmt.Errorf("join: expected ON, got %v", on)
+	if len(decl.Decl) < 2 || decl.Decl[1].Token != parser.OnToken {
+		return nil, fmt.Errorf("join: expected ON clause")
 	}
+	on := decl.Decl[1]
 
-	// Set first value
-	j.t1Value.valid = true
-	j.t1Value.lexeme = on.Decl[0].Lexeme
-	j.t1Value.table = on.Decl[0].Decl[0].Lexeme
-
-	// TODO: Skip operator here, expect '='
+	pred, err := whereExecutor2(e, on.Decl, correlated.scope(tables), locked)
+	if err != nil {
+		return nil, err
+	}
 
-	// Set second value
-	j.t2Value.valid = true
-	j.t2Value.lexeme = on.Decl[2].Lexeme
-	j.t2Value.table = on.Decl[2].Decl[0].Lexeme
+	// Join type may follow ON clause, default is INNER
+	for _, d := range decl.Decl[2:] {
+		o := &outer{relation: r, predicate: pred, tables: tables[:len(tables)-1]}
+		switch d.Token {
+		case parser.LeftToken:
+			o.left = true
+		case parser.RightToken:
+			o.right = true
+		case parser.FullToken:
+			o.left = true
+			o.right = true
+		default:
+			continue
+		}
+		log.Debug("%s JOIN %s ON %v", d.Lexeme, r.table.name, pred)
+		return o, nil
+	}
 
-	log.Debug("JOIN %s ON %s = %s !", j.table, j.t1Value.table+"."+j.t1Value.lexeme, j.t2Value.table+"."+j.t2Value.lexeme)
-	return j, nil
+	log.Debug("JOIN %s ON %v", r.table.name, pred)
+	return &inner{relation: r, predicate: pred}, nil
 }
@@ -2,6 +2,7 @@ package engine_test
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -158,3 +159,439 @@ func TestJoinGroup(t *testing.T) {
 	}
 
 }
+
+func TestInnerJoinAlias(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInnerJoinAlias")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT)`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id INT, total INT)`,
+		`INSERT INTO users (name) VALUES ('riri')`,
+		`INSERT INTO users (name) VALUES ('fifi')`,
+		`INSERT INTO orders (user_id, total) VALUES (1, 5)`,
+		`INSERT INTO orders (user_id, total) VALUES (1, 20)`,
+		`INSERT INTO orders (user_id, total) VALUES (2, 30)`,
+	}
+	for _, q := range init {
+		_, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("Cannot initialize test: %s", err)
+		}
+	}
+
+	query := `SELECT u.name, o.id, u.id FROM users AS u
+			INNER JOIN orders o ON u.id = o.user_id AND o.total > 10
+			WHERE o.total < 25`
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("Cannot select with inner join: %s", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	if len(columns) != 3 || columns[0] != "u.name" || columns[1] != "o.id" || columns[2] != "u.id" {
+		t.Fatalf("Unexpected columns %v", columns)
+	}
+
+	n := 0
+	for rows.Next() {
+		var name string
+		var orderID, userID int64
+		if err := rows.Scan(&name, &orderID, &userID); err != nil {
+			t.Fatalf("Cannot scan row: %s", err)
+		}
+		if name != "riri" || orderID != 2 || userID != 1 {
+			t.Fatalf("Unexpected row (%s, %d, %d)", name, orderID, userID)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("Expected 1 row, got %d", n)
+	}
+
+	// Implicit join in FROM clause
+	var total int64
+	err = db.QueryRow(`SELECT o.total FROM users u, orders o WHERE u.id = o.user_id AND u.name = 'fifi'`).Scan(&total)
+	if err != nil {
+		t.Fatalf("Cannot select from 2 tables: %s", err)
+	}
+	if total != 30 {
+		t.Fatalf("Expected total 30, got %d", total)
+	}
+
+	// id exists in both tables
+	_, err = db.Query(`SELECT id FROM users u JOIN orders o ON u.id = o.user_id`)
+	if err == nil {
+		t.Fatalf("Expected ambiguous attribute error")
+	}
+}
+
+func TestTableAlias(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTableAlias")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE users (id INT, name TEXT)`,
+		`CREATE TABLE orders (id INT, user_id INT, total INT)`,
+		`INSERT INTO users (id, name) VALUES (1, 'riri')`,
+		`INSERT INTO users (id, name) VALUES (2, 'fifi')`,
+		`INSERT INTO users (id, name) VALUES (3, 'loulou')`,
+		`INSERT INTO orders (id, user_id, total) VALUES (1, 2, 30)`,
+	}
+	for _, q := range init {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("Cannot initialize test: %s", err)
+		}
+	}
+
+	var name string
+	err = db.QueryRow(`SELECT u.name FROM users u WHERE u.id = $1`, 2).Scan(&name)
+	if err != nil {
+		t.Fatalf("Cannot select with table alias: %s", err)
+	}
+	if name != "fifi" {
+		t.Fatalf("Expected fifi, got %s", name)
+	}
+
+	// Once aliased, table cannot be referenced by its name
+	queries := map[string]string{
+		`SELECT users.name FROM users u WHERE u.id = 1`:                                   `invalid reference to FROM-clause entry for table "users"`,
+		`SELECT u.name FROM users u WHERE users.id = 1`:                                   `invalid reference to FROM-clause entry for table "users"`,
+		`SELECT u.name FROM users AS u ORDER BY users.name`:                               `invalid reference to FROM-clause entry for table "users"`,
+		`SELECT o.total FROM users u JOIN orders o ON users.id = o.user_id`:               `invalid reference to FROM-clause entry for table "users"`,
+		`SELECT x.name FROM users u`:                                                      `table "x" does not exist`,
+		`SELECT name FROM users u JOIN orders o ON u.id = o.user_id WHERE id = 1`:         `u.id or o.id`,
+		`SELECT u.name, total FROM users u JOIN orders o ON u.id = o.user_id ORDER BY id`: `u.id or o.id`,
+	}
+	for q, expected := range queries {
+		_, err := db.Query(q)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing '%s', got %v", q, expected, err)
+		}
+	}
+
+	// UPDATE and DELETE scope their table with its alias as well
+	_, err = db.Exec(`UPDATE users u SET name = 'donald' WHERE u.id = 3`)
+	if err != nil {
+		t.Fatalf("Cannot update with table alias: %s", err)
+	}
+	_, err = db.Exec(`UPDATE users u SET name = 'donald' WHERE users.id = 3`)
+	if err == nil {
+		t.Fatalf("Expected update referencing aliased table to fail")
+	}
+	res, err := db.Exec(`DELETE FROM users AS u WHERE u.name = 'donald'`)
+	if err != nil {
+		t.Fatalf("Cannot delete with table alias: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Expected 1 deleted row, got %d", n)
+	}
+	_, err = db.Exec(`DELETE FROM users u WHERE users.id = 1`)
+	if err == nil {
+		t.Fatalf("Expected delete referencing aliased table to fail")
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Cannot count users: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 users, got %d", count)
+	}
+}
+
+func TestSelfJoin(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSelfJoin")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE nodes (id INT, parent_id INT, name TEXT)`,
+		`INSERT INTO nodes (id, parent_id, name) VALUES (1, NULL, 'root')`,
+		`INSERT INTO nodes (id, parent_id, name) VALUES (2, 1, 'a')`,
+		`INSERT INTO nodes (id, parent_id, name) VALUES (3, 1, 'b')`,
+		`INSERT INTO nodes (id, parent_id, name) VALUES (4, 2, 'c')`,
+	}
+	for _, q := range init {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("Cannot initialize test: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT c.name, p.name FROM nodes c JOIN nodes p ON c.parent_id = p.id ORDER BY c.id`:                                  {"a root", "b root", "c a"},
+		`SELECT c.name, p.name FROM nodes c LEFT JOIN nodes p ON c.parent_id = p.id ORDER BY c.id`:                             {"root ", "a root", "b root", "c a"},
+		`SELECT c.name FROM nodes c LEFT JOIN nodes p ON c.parent_id = p.id WHERE p.id IS NULL`:                                {"root"},
+		`SELECT c.name, g.name FROM nodes c JOIN nodes p ON c.parent_id = p.id JOIN nodes g ON p.parent_id = g.id`:             {"c root"},
+		`SELECT c.name, p.name FROM nodes c, nodes p WHERE c.parent_id = p.id AND p.name = 'a'`:                                {"c a"},
+		`SELECT p.name, COUNT(c.id) FROM nodes p JOIN nodes c ON c.parent_id = p.id GROUP BY p.name ORDER BY p.name`:           {"a 1", "root 2"},
+		`SELECT c.name FROM nodes c WHERE EXISTS (SELECT 1 FROM nodes p WHERE p.id = c.parent_id AND p.parent_id IS NOT NULL)`: {"c"},
+	}
+
+	run := func() {
+		for query, expected := range queries {
+			rows, err := db.Query(query)
+			if err != nil {
+				t.Fatalf("%s: %s", query, err)
+			}
+			var res []string
+			for rows.Next() {
+				var a, b sql.NullString
+				var dest = []interface{}{&a, &b}
+				if columns, _ := rows.Columns(); len(columns) == 1 {
+					dest = dest[:1]
+				}
+				if err := rows.Scan(dest...); err != nil {
+					t.Fatalf("%s: cannot scan: %s", query, err)
+				}
+				if len(dest) == 1 {
+					res = append(res, a.String)
+					continue
+				}
+				res = append(res, a.String+" "+b.String)
+			}
+			rows.Close()
+			if strings.Join(res, ",") != strings.Join(expected, ",") {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// Each alias has its own scope over the same relation, scanned or not through an index
+	run()
+	if _, err := db.Exec(`CREATE INDEX nodes_id_idx ON nodes (id)`); err != nil {
+		t.Fatalf("Cannot create index: %s", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX nodes_parent_idx ON nodes (parent_id)`); err != nil {
+		t.Fatalf("Cannot create index: %s", err)
+	}
+	run()
+}
+
+func TestLeftJoin(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestLeftJoin")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT, team_id INT)`,
+		`CREATE TABLE teams (id BIGSERIAL, name TEXT, owner_id INT)`,
+		`INSERT INTO users (name, team_id) VALUES ('riri', 1)`,
+		`INSERT INTO users (name, team_id) VALUES ('fifi', 2)`,
+		`INSERT INTO users (name) VALUES ('loulou')`,
+		`INSERT INTO teams (name) VALUES ('cowboys')`,
+	}
+	for _, q := range init {
+		_, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("Cannot initialize test: %s", err)
+		}
+	}
+
+	query := `SELECT u.name, t.id, t.name FROM users u
+			LEFT JOIN teams t ON t.id = u.team_id
+			ORDER BY u.id ASC`
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("Cannot select with left join: %s", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	var teams []sql.NullString
+	for rows.Next() {
+		var name string
+		var teamID sql.NullInt64
+		var team sql.NullString
+		if err := rows.Scan(&name, &teamID, &team); err != nil {
+			t.Fatalf("Cannot scan row: %s", err)
+		}
+		if teamID.Valid != team.Valid {
+			t.Fatalf("Expected team id and name to be both NULL or not, got %v and %v", teamID, team)
+		}
+		names = append(names, name)
+		teams = append(teams, team)
+	}
+
+	if len(names) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(names))
+	}
+	if !teams[0].Valid || teams[0].String != "cowboys" {
+		t.Fatalf("Expected riri to be in cowboys, got %v", teams[0])
+	}
+	if teams[1].Valid || teams[2].Valid {
+		t.Fatalf("Expected fifi and loulou without team, got %v and %v", teams[1], teams[2])
+	}
+
+	// NULL on both sides of ON predicate must not match
+	var team sql.NullString
+	err = db.QueryRow(`SELECT t.name FROM users u LEFT OUTER JOIN teams t ON t.owner_id = u.team_id WHERE u.name = 'loulou'`).Scan(&team)
+	if err != nil {
+		t.Fatalf("Cannot select with left outer join: %s", err)
+	}
+	if team.Valid {
+		t.Fatalf("Expected NULL team, got %v", team)
+	}
+}
+
+func TestRightAndFullJoin(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestRightAndFullJoin")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT, team_id INT)`,
+		`CREATE TABLE teams (id BIGSERIAL, name TEXT)`,
+		`CREATE TABLE empty_a (id INT)`,
+		`CREATE TABLE empty_b (id INT)`,
+		`INSERT INTO users (name, team_id) VALUES ('riri', 1)`,
+		`INSERT INTO users (name, team_id) VALUES ('fifi', 1)`,
+		`INSERT INTO users (name, team_id) VALUES ('loulou', 3)`,
+		`INSERT INTO teams (name) VALUES ('cowboys')`,
+		`INSERT INTO teams (name) VALUES ('troopers')`,
+	}
+	for _, q := range init {
+		_, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("Cannot initialize test: %s", err)
+		}
+	}
+
+	count := func(query string) (int, int, int) {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var n, nullUsers, nullTeams int
+		for rows.Next() {
+			var user, team sql.NullString
+			if err := rows.Scan(&user, &team); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			n++
+			if !user.Valid {
+				nullUsers++
+			}
+			if !team.Valid {
+				nullTeams++
+			}
+		}
+		return n, nullUsers, nullTeams
+	}
+
+	// riri and fifi in cowboys, nobody in troopers
+	n, nullUsers, nullTeams := count(`SELECT u.name, t.name FROM users u RIGHT JOIN teams t ON t.id = u.team_id`)
+	if n != 3 || nullUsers != 1 || nullTeams != 0 {
+		t.Fatalf("RIGHT JOIN: expected 3 rows with 1 NULL user, got %d rows, %d NULL users, %d NULL teams", n, nullUsers, nullTeams)
+	}
+
+	n, nullUsers, nullTeams = count(`SELECT u.name, t.name FROM users u RIGHT OUTER JOIN teams t ON t.id = u.team_id`)
+	if n != 3 || nullUsers != 1 || nullTeams != 0 {
+		t.Fatalf("RIGHT OUTER JOIN: expected 3 rows with 1 NULL user, got %d rows, %d NULL users, %d NULL teams", n, nullUsers, nullTeams)
+	}
+
+	// loulou team does not exist
+	n, nullUsers, nullTeams = count(`SELECT u.name, t.name FROM users u FULL OUTER JOIN teams t ON t.id = u.team_id`)
+	if n != 4 || nullUsers != 1 || nullTeams != 1 {
+		t.Fatalf("FULL OUTER JOIN: expected 4 rows with 1 NULL user and 1 NULL team, got %d rows, %d NULL users, %d NULL teams", n, nullUsers, nullTeams)
+	}
+
+	n, _, _ = count(`SELECT u.name, t.name FROM users u FULL JOIN teams t ON t.id = u.team_id`)
+	if n != 4 {
+		t.Fatalf("FULL JOIN: expected 4 rows, got %d", n)
+	}
+
+	// Duplicate keys are multiplied
+	n, _, _ = count(`SELECT u1.name, u2.name FROM users u1 FULL JOIN users u2 ON u1.team_id = u2.team_id`)
+	if n != 5 {
+		t.Fatalf("FULL JOIN on duplicate keys: expected 5 rows, got %d", n)
+	}
+
+	rows, err := db.Query(`SELECT a.id, b.id FROM empty_a a FULL OUTER JOIN empty_b b ON a.id = b.id`)
+	if err != nil {
+		t.Fatalf("Cannot full join empty tables: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t.Fatalf("Expected no rows joining empty tables")
+	}
+}
+
+func TestJoinKeywordsAsNames(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestJoinKeywordsAsNames")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	init := []string{
+		`CREATE TABLE lr (id INT, left INT, right INT)`,
+		`CREATE TABLE full (id INT, outer TEXT)`,
+		`INSERT INTO lr (id, left, right) VALUES (1, 10, 20)`,
+		`INSERT INTO lr (id, left, right) VALUES (2, 30, 40)`,
+		`INSERT INTO full (id, outer) VALUES (1, 'one')`,
+		`UPDATE lr SET right = right + 1 WHERE left = 30`,
+	}
+	for _, q := range init {
+		_, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("Cannot initialize test with '%s': %s", q, err)
+		}
+	}
+
+	queries := map[string]string{
+		`SELECT left, right FROM lr ORDER BY left`:                                          "10 20,30 41",
+		`SELECT lr.left, f.outer FROM lr LEFT JOIN full f ON f.id = lr.id ORDER BY lr.left`: "10 one,30 ",
+		`SELECT l.right, full.outer FROM lr l RIGHT OUTER JOIN full ON full.id = l.id`:      "20 one",
+		`SELECT right, left FROM lr WHERE left > 10 AND right > 40`:                         "41 30",
+	}
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var a, b sql.NullString
+			if err := rows.Scan(&a, &b); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, a.String+" "+b.String)
+		}
+		rows.Close()
+		if strings.Join(res, ",") != expected {
+			t.Fatalf("%s: expected %s, got %v", query, expected, res)
+		}
+	}
+}
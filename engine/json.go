@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// jsonExtractPathFunction returns the field of a JSON object, or the element of a JSON array,
+// found by following path, as JSON text. Arrays are indexed from 0, or from their end with
+// a negative index. If path leads nowhere, result is NULL.
+func jsonExtractPathFunction(args []interface{}) (interface{}, error) {
+	v, err := jsonExtractPath(args)
+	if v == nil || err != nil {
+		return nil, err
+	}
+
+	return string(v), nil
+}
+
+// jsonExtractPathTextFunction returns the value found by following path like jsonExtractPathFunction,
+// but as text, strings being unquoted. JSON null is then NULL as well.
+func jsonExtractPathTextFunction(args []interface{}) (interface{}, error) {
+	v, err := jsonExtractPath(args)
+	if v == nil || err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(v, []byte("null")):
+		return nil, nil
+	case v[0] == '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	return string(v), nil
+}
+
+// jsonExtractPath returns the JSON text found in first argument by following path given by other arguments
+func jsonExtractPath(args []interface{}) (json.RawMessage, error) {
+	doc := json.RawMessage(stringValue(args[0]))
+	if !json.Valid(doc) {
+		return nil, errorf(InvalidTextRepresentation, "invalid input syntax for type json: %v", args[0])
+	}
+
+	for _, step := range args[1:] {
+		key := stringValue(step)
+
+		switch bytes.TrimSpace(doc)[0] {
+		case '{':
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(doc, &object); err != nil {
+				return nil, err
+			}
+			v, ok := object[key]
+			if !ok {
+				return nil, nil
+			}
+			doc = v
+		case '[':
+			var array []json.RawMessage
+			if err := json.Unmarshal(doc, &array); err != nil {
+				return nil, err
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, nil
+			}
+			if i < 0 {
+				i += len(array)
+			}
+			if i < 0 || i >= len(array) {
+				return nil, nil
+			}
+			doc = array[i]
+		default:
+			// Scalars have neither fields nor elements
+			return nil, nil
+		}
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,174 @@
+package engine_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestJSON(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestJSON")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id INT, data JSON, meta JSONB)`,
+		`INSERT INTO events (id, data, meta) VALUES (1, '{"user": "alice",  "tags": [1, 2]}', '[]')`,
+		`INSERT INTO events (id, data, meta) VALUES (2, '"text"', 'null')`,
+		`INSERT INTO events (id, data) VALUES (3, 42)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	if _, err := db.Exec(`INSERT INTO events (id, data) VALUES ($1, $2)`, 4, `{"nested": {"ok": true}}`); err != nil {
+		t.Fatalf("cannot insert JSON argument: %s", err)
+	}
+
+	// JSON text is returned verbatim
+	expected := map[int]string{
+		1: `{"user": "alice",  "tags": [1, 2]}`,
+		2: `"text"`,
+		3: `42`,
+		4: `{"nested": {"ok": true}}`,
+	}
+	for id, text := range expected {
+		var s string
+		if err := db.QueryRow(`SELECT data FROM events WHERE id = $1`, id).Scan(&s); err != nil {
+			t.Fatalf("cannot scan JSON into string: %s", err)
+		}
+		if s != text {
+			t.Fatalf("expected %s, got %s", text, s)
+		}
+
+		var raw json.RawMessage
+		if err := db.QueryRow(`SELECT data FROM events WHERE id = $1`, id).Scan(&raw); err != nil {
+			t.Fatalf("cannot scan JSON into json.RawMessage: %s", err)
+		}
+		if string(raw) != text {
+			t.Fatalf("expected %s, got %s", text, raw)
+		}
+	}
+
+	// JSON null is a value, unlike SQL NULL
+	var meta []sql.NullString
+	rows, err := db.Query(`SELECT meta FROM events ORDER BY id`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	for rows.Next() {
+		var v sql.NullString
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("cannot scan: %s", err)
+		}
+		meta = append(meta, v)
+	}
+	rows.Close()
+	if len(meta) != 4 || meta[0].String != "[]" || meta[1].String != "null" || !meta[1].Valid || meta[2].Valid {
+		t.Fatalf("unexpected meta values %v", meta)
+	}
+
+	failing := []string{
+		`INSERT INTO events (id, data) VALUES (5, '{"user": ')`,
+		`INSERT INTO events (id, meta) VALUES (5, 'alice')`,
+		`UPDATE events SET data = '[1, 2' WHERE id = 1`,
+	}
+	for _, query := range failing {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected invalid JSON in '%s' to fail", query)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n); err != nil || n != 4 {
+		t.Fatalf("expected 4 rows, got %d (%v)", n, err)
+	}
+	var s string
+	if err := db.QueryRow(`SELECT data FROM events WHERE id = 1`).Scan(&s); err != nil || s != expected[1] {
+		t.Fatalf("expected JSON to be left unchanged by failed update, got %s (%v)", s, err)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestJSONPath")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id INT, data JSONB)`,
+		`INSERT INTO events (id, data) VALUES (1, '{"user": {"name": "alice", "age": 31}, "tags": ["a", "b"], "score": 1.5}')`,
+		`INSERT INTO events (id, data) VALUES (2, '{"user": {"name": "bob", "age": null}, "tags": []}')`,
+		`INSERT INTO events (id, data) VALUES (3, '[{"name": "carol"}, 7]')`,
+		`INSERT INTO events (id) VALUES (4)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT data -> 'user' ->> 'name' FROM events ORDER BY id`:                                           {"alice", "bob", "NULL", "NULL"},
+		`SELECT data->'user'->'name' FROM events WHERE id = 1`:                                               {`"alice"`},
+		`SELECT data -> 'user' FROM events WHERE id = 2`:                                                     {`{"name": "bob", "age": null}`},
+		`SELECT data -> 'user' -> 'age' FROM events WHERE id = 2`:                                            {"null"},
+		`SELECT data -> 'user' ->> 'age' FROM events ORDER BY id`:                                            {"31", "NULL", "NULL", "NULL"},
+		`SELECT data -> 'tags' -> 1 FROM events ORDER BY id`:                                                 {`"b"`, "NULL", "NULL", "NULL"},
+		`SELECT data -> 'tags' ->> -1 FROM events WHERE id = 1`:                                              {"b"},
+		`SELECT data -> 0 ->> 'name', data ->> 1 FROM events WHERE id = 3`:                                   {"carol 7"},
+		`SELECT data ->> 'score' FROM events WHERE id = 1`:                                                   {"1.5"},
+		`SELECT data -> 'missing' -> 'deeper' FROM events WHERE id = 1`:                                      {"NULL"},
+		`SELECT id FROM events WHERE data -> 'user' ->> 'name' = 'bob'`:                                      {"2"},
+		`SELECT id FROM events WHERE data -> 'user' ->> 'age' > 30`:                                          {"1"},
+		`SELECT id FROM events WHERE data ->> 'tags' IS NULL ORDER BY id`:                                    {"3", "4"},
+		`SELECT id FROM events WHERE (data -> 'tags' ->> 0) IN ('a', 'z')`:                                   {"1"},
+		`SELECT jsonb_extract_path_text(data, 'user', 'name') FROM events ORDER BY id DESC LIMIT 1 OFFSET 2`: {"bob"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		columns, _ := rows.Columns()
+		var res []string
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			var line []string
+			for _, v := range values {
+				if !v.Valid {
+					line = append(line, "NULL")
+					continue
+				}
+				line = append(line, v.String)
+			}
+			res = append(res, strings.Join(line, " "))
+		}
+		rows.Close()
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+	}
+}
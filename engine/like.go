@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/proullon/ramsql/engine/log"
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+/*
+|-> not
+	|-> ilike
+		|-> escape
+			|-> \
+*/
+// likeExecutor returns the operator matching left value with LIKE or ILIKE pattern,
+// possibly negated. A constant pattern is checked right away.
+func likeExecutor(likeDecl *parser.Decl, patternDecl *parser.Decl) (Operator, error) {
+	negated := false
+	if likeDecl.Token == parser.NotToken && len(likeDecl.Decl) > 0 {
+		negated = true
+		likeDecl = likeDecl.Decl[0]
+	}
+	if likeDecl.Token != parser.LikeToken && likeDecl.Token != parser.ILikeToken {
+		return nil, errorf(UndefinedFunction, "Operator '%s' does not exist", likeDecl.Lexeme)
+	}
+
+	// Like PostgreSQL, default escape character is backslash
+	escape := '\\'
+	if len(likeDecl.Decl) > 0 && len(likeDecl.Decl[0].Decl) > 0 {
+		e := likeDecl.Decl[0].Decl[0].Lexeme
+		switch utf8.RuneCountInString(e) {
+		case 0:
+			escape = 0
+		case 1:
+			escape, _ = utf8.DecodeRuneInString(e)
+		default:
+			return nil, fmt.Errorf("invalid escape string %s: must be empty or one character", e)
+		}
+	}
+
+	op := &likeOperator{
+		caseInsensitive: likeDecl.Token == parser.ILikeToken,
+		negated:         negated,
+		escape:          escape,
+	}
+
+	if !isExpression(patternDecl) && len(patternDecl.Decl) == 0 {
+		if _, err := op.regexp(patternDecl.Lexeme); err != nil {
+			return nil, err
+		}
+	}
+
+	return op.match, nil
+}
+
+// likeOperator matches values with a LIKE pattern, translated to a regular expression.
+// Last translated pattern is kept, since it is usually the same for all rows.
+type likeOperator struct {
+	caseInsensitive bool
+	negated         bool
+	escape          rune
+	pattern         string
+	re              *regexp.Regexp
+}
+
+func (o *likeOperator) match(leftValue Value, rightValue Value) bool {
+	// NULL never matches, nor does not match
+	if leftValue.v == nil {
+		return false
+	}
+
+	pattern := rightValue.lexeme
+	if rightValue.v != nil {
+		pattern = fmt.Sprintf("%v", rightValue.v)
+	}
+
+	re, err := o.regexp(pattern)
+	if err != nil {
+		log.Debug("LikeOperator> %s\n", err)
+		return false
+	}
+
+	return re.MatchString(fmt.Sprintf("%v", leftValue.v)) != o.negated
+}
+
+// regexp translates pattern into an anchored regular expression. % matches any sequence
+// of characters and _ any single character, unless preceded by escape character.
+// Other characters, regular expression metacharacters included, match themselves.
+func (o *likeOperator) regexp(pattern string) (*regexp.Regexp, error) {
+	if o.re != nil && o.pattern == pattern {
+		return o.re, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if o.caseInsensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case o.escape != 0 && c == o.escape:
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("LIKE pattern must not end with escape character")
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	o.pattern, o.re = pattern, re
+	return re, nil
+}
@@ -0,0 +1,87 @@
+package engine_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestLike(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestLike")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE files (id BIGSERIAL, name TEXT, pattern TEXT)`,
+		`INSERT INTO files (name, pattern) VALUES ('a.b', 'a_b')`,
+		`INSERT INTO files (name, pattern) VALUES ('axb', 'a.b')`,
+		`INSERT INTO files (name, pattern) VALUES ('a_b', 'a\_b')`,
+		`INSERT INTO files (name, pattern) VALUES ('Report (final).PDF', '%(%)%')`,
+		`INSERT INTO files (name, pattern) VALUES ('100%', '%!%')`,
+		`INSERT INTO files (pattern) VALUES ('%')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT id FROM files WHERE name LIKE 'a_b' ORDER BY id`:                 {"1", "2", "3"},
+		`SELECT id FROM files WHERE name LIKE 'a.b'`:                             {"1"},
+		`SELECT id FROM files WHERE name LIKE 'a\_b'`:                            {"3"},
+		`SELECT id FROM files WHERE name LIKE 'a#_b' ESCAPE '#'`:                 {"3"},
+		`SELECT id FROM files WHERE name LIKE '%!%' ESCAPE '!'`:                  {"5"},
+		`SELECT id FROM files WHERE name LIKE 'report%'`:                         {},
+		`SELECT id FROM files WHERE name ILIKE 'report (%).pdf'`:                 {"4"},
+		`SELECT id FROM files WHERE name LIKE '%'  ORDER BY id`:                  {"1", "2", "3", "4", "5"},
+		`SELECT id FROM files WHERE name NOT LIKE 'a%' ORDER BY id`:              {"4", "5"},
+		`SELECT id FROM files WHERE name NOT ILIKE 'A_B' AND id < 5 ORDER BY id`: {"4"},
+		`SELECT id FROM files WHERE name LIKE files.pattern ORDER BY id`:         {"1", "3", "4"},
+		`SELECT id FROM files WHERE UPPER(name) LIKE 'A%B' ORDER BY id`:          {"1", "2", "3"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			res = append(res, v)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	res, err := db.Exec(`DELETE FROM files WHERE name LIKE 'a_b'`)
+	if err != nil {
+		t.Fatalf("Cannot delete with LIKE: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Fatalf("Expected 3 deleted rows, got %d", n)
+	}
+
+	_, err = db.Query(`SELECT id FROM files WHERE name LIKE 'a\'`)
+	if err == nil {
+		t.Fatalf("Expected error with pattern ending with escape character")
+	}
+}
@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// Modes in which a statement locks the engine
+const (
+	// sharedLock statements only read shared relations, or write copies of them
+	sharedLock = iota
+	// writeLock statements write lock a shared relation, and may read lock others afterward
+	writeLock
+	// exclusiveLock statements write lock several shared relations, or change the schema
+	exclusiveLock
+)
+
+// lockStatement locks the engine for the execution of a statement, and returns the function
+// unlocking it.
+//
+// Each relation is locked by executors when they first use it, rows being read or written
+// while it is, and a statement reading relations locks them one after the other, so readers
+// of a relation do not block each other. Statements writing a shared relation lock it with
+// writeLocks beforehand, along with the tables they may read, so that a table is written by
+// one statement at a time and none of them waits for a relation another one holds. The ones
+// writing several relations or changing the schema are executed alone. Transactions write
+// copies of relations, and lock the tables they write with tableLocks instead, until they end.
+func (e *Engine) lockStatement(decl *parser.Decl) func() {
+	e.statements.RLock()
+
+	switch e.lockMode(decl) {
+	case writeLock:
+		unlock := e.writeLocks.lock(writtenTable(decl), e.readTables(decl))
+		return func() {
+			unlock()
+			e.statements.RUnlock()
+		}
+	case exclusiveLock:
+		e.statements.RUnlock()
+		e.statements.Lock()
+		return e.statements.Unlock
+	}
+
+	return e.statements.RUnlock
+}
+
+// lockMode returns the mode in which statement locks the engine.
+// Schema cannot change while it is called.
+func (e *Engine) lockMode(decl *parser.Decl) int {
+	switch decl.Token {
+	case parser.SelectToken, parser.UnionToken, parser.IntersectToken, parser.ExceptToken,
+		parser.ShowToken, parser.ExplainToken, parser.GrantToken, parser.SetToken,
+		parser.BeginToken, parser.RollbackToken, parser.SavepointToken, parser.ReleaseToken:
+		return sharedLock
+	case parser.InsertToken, parser.UpdateToken, parser.DeleteToken, parser.TruncateToken:
+	default:
+		return exclusiveLock
+	}
+
+	// Within a transaction, relations written are copies
+	if e.tx != nil {
+		return sharedLock
+	}
+
+	// Rows of tables referencing deleted ones may be deleted or updated as well
+	if decl.Token == parser.DeleteToken && len(decl.Decl) > 0 {
+		tables := fromExecutor(decl.Decl[0])
+		if len(tables) > 0 && e.referencedBy(tables[0].name) != "" {
+			return exclusiveLock
+		}
+	}
+
+	// Triggers fired by rows written may write other tables
+	if r := e.relation(writtenTable(decl)); r != nil && len(r.table.triggers) > 0 {
+		return exclusiveLock
+	}
+
+	return writeLock
+}
+
+// readTables returns the names of the tables statement may read besides the one it writes:
+// the ones it names, directly or through views, and the ones referencing or referenced by
+// written table, whose rows are checked by foreign keys
+func (e *Engine) readTables(decl *parser.Decl) []string {
+	written := writtenTable(decl)
+
+	names := make(map[string]bool)
+	e.namedTables(decl, names)
+	for _, name := range e.referencing(written) {
+		names[name] = true
+	}
+	if r := e.relation(written); r != nil {
+		for _, fk := range r.table.foreignKeys {
+			names[fk.table] = true
+		}
+	}
+	delete(names, written)
+
+	tables := make([]string, 0, len(names))
+	for name := range names {
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// namedTables adds to names the tables named in decl, and the views along with the tables
+// they read. Other names, like the ones of columns, are ignored unless they name a table too.
+func (e *Engine) namedTables(decl *parser.Decl, names map[string]bool) {
+	for _, d := range decl.Decl {
+		if !names[d.Lexeme] {
+			if e.relation(d.Lexeme) != nil {
+				names[d.Lexeme] = true
+			} else if v := e.view(d.Lexeme); v != nil {
+				names[d.Lexeme] = true
+				e.namedTables(v, names)
+			}
+		}
+		e.namedTables(d, names)
+	}
+}
+
+// writeLocks are the tables locked by statements writing a shared relation: the one written
+// exclusively, and the ones read shared. A statement locks all of them before it is executed,
+// in the order of table names like commit locks relations, so statements writing different
+// tables are executed concurrently, unless one reads the table the other writes.
+type writeLocks struct {
+	sync.Mutex
+	tables map[string]*sync.RWMutex
+}
+
+func newWriteLocks() *writeLocks {
+	return &writeLocks{tables: make(map[string]*sync.RWMutex)}
+}
+
+// table returns the lock of named table
+func (l *writeLocks) table(name string) *sync.RWMutex {
+	l.Lock()
+	defer l.Unlock()
+
+	m, ok := l.tables[name]
+	if !ok {
+		m = new(sync.RWMutex)
+		l.tables[name] = m
+	}
+	return m
+}
+
+// lock locks written table exclusively and read ones shared, and returns the function unlocking them
+func (l *writeLocks) lock(written string, read []string) func() {
+	names := append([]string{written}, read...)
+	sort.Strings(names)
+
+	unlocks := make([]func(), 0, len(names))
+	for _, name := range names {
+		m := l.table(name)
+		if name == written {
+			m.Lock()
+			unlocks = append(unlocks, m.Unlock)
+		} else {
+			m.RLock()
+			unlocks = append(unlocks, m.RUnlock)
+		}
+	}
+
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// tableLocks are the tables write locked by transactions, each one until the end of the
+// transaction. A transaction writing a table locked by another one waits for it to end,
+// unless both would wait for each other, directly or through other transactions.
+// Statements executed outside of a transaction do not lock tables.
+type tableLocks struct {
+	sync.Mutex
+	holders map[string]*transaction
+	// waiting holds the transaction each waiting transaction waits for
+	waiting map[*transaction]*transaction
+	// released is closed once locks are released, and replaced
+	released chan struct{}
+}
+
+func newTableLocks() *tableLocks {
+	return &tableLocks{
+		holders:  make(map[string]*transaction),
+		waiting:  make(map[*transaction]*transaction),
+		released: make(chan struct{}),
+	}
+}
+
+// lock write locks table for transaction of session e, waiting for the transaction holding
+// it to end. If it waits for the transaction of session already, directly or not, it returns
+// a DeadlockDetected error rather than waiting forever. Statement may be canceled while waiting.
+func (l *tableLocks) lock(e *Engine, table string) error {
+	tx := e.tx
+	for {
+		l.Lock()
+		holder, ok := l.holders[table]
+		if !ok || holder == tx {
+			l.holders[table] = tx
+			delete(l.waiting, tx)
+			l.Unlock()
+			return nil
+		}
+
+		for t := holder; t != nil; t = l.waiting[t] {
+			if t == tx {
+				delete(l.waiting, tx)
+				l.Unlock()
+				return &Error{Code: DeadlockDetected, Table: table, Message: fmt.Sprintf("deadlock detected: table \"%s\" is locked by a transaction waiting for this one", table)}
+			}
+		}
+		l.waiting[tx] = holder
+		released := l.released
+		l.Unlock()
+
+		if err := e.wait(released); err != nil {
+			l.Lock()
+			delete(l.waiting, tx)
+			l.Unlock()
+			return err
+		}
+	}
+}
+
+// release unlocks tables locked by transaction tx, once it ended
+func (l *tableLocks) release(tx *transaction) {
+	l.Lock()
+	defer l.Unlock()
+
+	for table, holder := range l.holders {
+		if holder == tx {
+			delete(l.holders, table)
+		}
+	}
+	delete(l.waiting, tx)
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// wait waits until c is closed, and returns an error if statement is canceled before
+func (e *Engine) wait(c <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if !e.deadline.IsZero() {
+		t := time.NewTimer(time.Until(e.deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+	var done <-chan struct{}
+	if e.ctx != nil {
+		done = e.ctx.Done()
+	}
+
+	select {
+	case <-c:
+		return nil
+	case <-timeout:
+	case <-done:
+	}
+
+	return e.canceled()
+}
+
+/*
+|-> insert
+	|-> into
+		|-> account
+*/
+// writtenTable returns the name of the table written by statement, if any
+func writtenTable(decl *parser.Decl) string {
+	if len(decl.Decl) == 0 {
+		return ""
+	}
+
+	switch decl.Token {
+	case parser.InsertToken, parser.DeleteToken:
+		if len(decl.Decl[0].Decl) > 0 {
+			return decl.Decl[0].Decl[0].Lexeme
+		}
+	case parser.UpdateToken, parser.TruncateToken:
+		return decl.Decl[0].Lexeme
+	}
+
+	return ""
+}
@@ -0,0 +1,108 @@
+package engine_test
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestConcurrentStatements(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestConcurrentStatements")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id BIGSERIAL PRIMARY KEY, email TEXT UNIQUE, visits INT DEFAULT 0)`,
+		`CREATE TABLE address (id BIGSERIAL PRIMARY KEY, account_id BIGINT REFERENCES account(id) ON DELETE CASCADE, street TEXT)`,
+		`CREATE INDEX address_street_idx ON address (street)`,
+		`CREATE VIEW emails AS SELECT email FROM account`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	// Connections read and write the same tables, in different orders
+	workers, iterations := 8, 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				email := fmt.Sprintf("%d.%d@foo.bar", w, i)
+				queries := []string{
+					fmt.Sprintf(`INSERT INTO account (email) VALUES ('%s')`, email),
+					fmt.Sprintf(`INSERT INTO address (account_id, street) SELECT id, 'main' FROM account WHERE email = '%s'`, email),
+					`SELECT a.email FROM address d JOIN account a ON d.account_id = a.id WHERE d.street = 'main'`,
+					fmt.Sprintf(`UPDATE account SET visits = visits + 1 WHERE email = '%s'`, email),
+					`SELECT email FROM emails WHERE email IN (SELECT email FROM account)`,
+					fmt.Sprintf(`CREATE TABLE tmp_%d (id INT)`, w),
+					fmt.Sprintf(`DROP TABLE tmp_%d`, w),
+					fmt.Sprintf(`DELETE FROM account WHERE email = '%s.old'`, email),
+				}
+				for _, q := range queries {
+					if !strings.HasPrefix(q, "SELECT") {
+						if _, err := db.Exec(q); err != nil {
+							t.Errorf("cannot execute '%s': %s", q, err)
+							return
+						}
+						continue
+					}
+					rows, err := db.Query(q)
+					if err != nil {
+						t.Errorf("cannot query '%s': %s", q, err)
+						return
+					}
+					for rows.Next() {
+					}
+					rows.Close()
+				}
+
+				tx, err := db.Begin()
+				if err != nil {
+					t.Errorf("cannot begin transaction: %s", err)
+					return
+				}
+				if _, err := tx.Exec(fmt.Sprintf(`UPDATE account SET email = '%s.old' WHERE email = '%s'`, email, email)); err != nil {
+					t.Errorf("cannot update in transaction: %s", err)
+					return
+				}
+				// Concurrent commits of other tables rows may fail, but never block
+				tx.Commit()
+			}
+		}(w)
+	}
+
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("statements did not complete, they may wait for each other")
+	}
+
+	var accounts, addresses int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM account`).Scan(&accounts); err != nil {
+		t.Fatalf("cannot count accounts: %s", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM address`).Scan(&addresses); err != nil {
+		t.Fatalf("cannot count addresses: %s", err)
+	}
+	if accounts != addresses || accounts == 0 || accounts > workers*iterations {
+		t.Fatalf("expected as many addresses as accounts, got %d addresses and %d accounts", addresses, accounts)
+	}
+}
@@ -26,7 +26,7 @@ func NewOperator(token int, lexeme string) (Operator, error) {
 		return greaterOrEqualOperator, nil
 	}
 
-	return nil, fmt.Errorf("Operator '%s' does not exist", lexeme)
+	return nil, errorf(UndefinedFunction, "Operator '%s' does not exist", lexeme)
 }
 
 func convToDate(t interface{}) (time.Time, error) {
@@ -64,11 +64,21 @@ func convToFloat(t interface{}) (float64, error) {
 
 }
 
+// nullOperand returns true if one of compared values is NULL. Right value is
+// not valid if NULL, constants having a lexeme but no value.
+func nullOperand(leftValue Value, rightValue Value) bool {
+	return leftValue.v == nil || !rightValue.valid
+}
+
 func greaterThanOperator(leftValue Value, rightValue Value) bool {
 	log.Debug("GreaterThanOperator")
 	var left, right float64
 	var err error
 
+	if nullOperand(leftValue, rightValue) {
+		return false
+	}
+
 	var rvalue interface{}
 	if rightValue.v != nil {
 		rvalue = rightValue.v
@@ -121,6 +131,10 @@ func lessThanOperator(leftValue Value, rightValue Value) bool {
 	var left, right float64
 	var err error
 
+	if nullOperand(leftValue, rightValue) {
+		return false
+	}
+
 	var rvalue interface{}
 	if rightValue.v != nil {
 		rvalue = rightValue.v
@@ -163,6 +177,11 @@ func lessThanOperator(leftValue Value, rightValue Value) bool {
 // EqualityOperator checks if given value are equal
 func equalityOperator(leftValue Value, rightValue Value) bool {
 
+	// NULL is never equal to anything
+	if nullOperand(leftValue, rightValue) {
+		return false
+	}
+
 	if fmt.Sprintf("%v", leftValue.v) == rightValue.lexeme {
 		return true
 	}
@@ -175,17 +194,95 @@ func TrueOperator(leftValue Value, rightValue Value) bool {
 	return true
 }
 
+// nullOperator is any comparison with NULL, whose result is unknown,
+// so the row is never selected
+func nullOperator(leftValue Value, rightValue Value) bool {
+	return false
+}
+
+// neverKnown is the result of nullOperator
+func neverKnown(leftValue Value, rightValue Value) bool {
+	return false
+}
+
+// alwaysKnown is the result of IS NULL and IS NOT NULL, which are never unknown
+func alwaysKnown(leftValue Value, rightValue Value) bool {
+	return true
+}
+
+// listKnown returns false if IN or NOT IN result is unknown, that is if left
+// value is NULL, or if it is not found among right values and one of them is NULL.
+// A tuple is NULL as a whole only if it has no mismatching non NULL value.
+func listKnown(leftValue Value, rightValue Value) bool {
+	values, ok := rightValue.v.([]interface{})
+	if !ok || len(values) == 0 {
+		return true
+	}
+	if leftValue.v == nil {
+		return false
+	}
+
+	known := true
+	for i := range values {
+		equal, k := listEqual(leftValue, values[i])
+		if equal {
+			return true
+		}
+		known = known && k
+	}
+
+	return known
+}
+
+// listEqual compares left value with an element of IN list, both being tuples if left value is one.
+// Tuples are equal if all their values are, and unequal as soon as two non NULL values differ.
+// Otherwise the result is unknown, like any comparison with NULL.
+func listEqual(leftValue Value, v interface{}) (equal bool, known bool) {
+	left, ok := leftValue.v.([]interface{})
+	if !ok {
+		if leftValue.v == nil || v == nil {
+			return false, false
+		}
+		return equalityOperator(leftValue, listValue(v)), true
+	}
+
+	right, ok := v.([]interface{})
+	if !ok || len(right) != len(left) {
+		return false, true
+	}
+
+	known = true
+	for i := range left {
+		if left[i] == nil || right[i] == nil {
+			known = false
+			continue
+		}
+		if !equalityOperator(listValue(left[i]), listValue(right[i])) {
+			return false, true
+		}
+	}
+
+	return known, known
+}
+
+// inOperator checks if left value equals one of right values, as with equality operator.
+// If not found among non NULL values, result is unknown and the row is not selected.
 func inOperator(leftValue Value, rightValue Value) bool {
-	// Right value should be a slice of string
-	values, ok := rightValue.v.([]string)
+	// Right value should be a slice of values
+	values, ok := rightValue.v.([]interface{})
 	if !ok {
-		log.Debug("InOperator: rightValue.v is not a []string !")
+		log.Debug("InOperator: rightValue.v is not a []interface{} !")
+		return false
+	}
+
+	// NULL is never found
+	if leftValue.v == nil {
 		return false
 	}
 
 	for i := range values {
-		log.Debug("InOperator: Testing %v against %s", leftValue.v, values[i])
-		if fmt.Sprintf("%v", leftValue.v) == values[i] {
+		log.Debug("InOperator: Testing %v against %v", leftValue.v, values[i])
+		if equal, _ := listEqual(leftValue, values[i]); equal {
 			return true
 		}
 	}
@@ -193,6 +290,123 @@ func inOperator(leftValue Value, rightValue Value) bool {
 	return false
 }
 
+// notInOperator checks if left value is known to be absent from right values.
+// A NULL left value, or a NULL among right values, makes the result unknown.
+func notInOperator(leftValue Value, rightValue Value) bool {
+	values, ok := rightValue.v.([]interface{})
+	if !ok {
+		log.Debug("NotInOperator: rightValue.v is not a []interface{} !")
+		return false
+	}
+
+	// Nothing is in an empty set, not even NULL
+	if len(values) == 0 {
+		return true
+	}
+
+	if leftValue.v == nil {
+		return false
+	}
+
+	unknown := false
+	for i := range values {
+		equal, known := listEqual(leftValue, values[i])
+		if equal {
+			return false
+		}
+		unknown = unknown || !known
+	}
+
+	return !unknown
+}
+
+// quantified compares left value with each of right values. With ALL, result is false as soon as
+// a comparison is, and true if all of them are, or if there is none. With ANY, result is true as
+// soon as a comparison is, and false if none is. Otherwise, a comparison with NULL makes it unknown,
+// as well as a NULL array.
+func quantified(op Operator, all bool, leftValue Value, rightValue Value) (result bool, known bool) {
+	values, ok := rightValue.v.([]interface{})
+	if !ok {
+		return false, false
+	}
+
+	known = true
+	for _, v := range values {
+		if leftValue.v == nil || v == nil {
+			known = false
+			continue
+		}
+		if op(leftValue, listValue(v)) != all {
+			return !all, true
+		}
+	}
+
+	return all, known
+}
+
+// betweenClause returns BETWEEN decl of a condition, possibly negated
+func betweenClause(decl *parser.Decl) (*parser.Decl, bool) {
+	if decl.Token == parser.NotToken && len(decl.Decl) > 0 && decl.Decl[0].Token == parser.BetweenToken {
+		return decl.Decl[0], true
+	}
+	if decl.Token == parser.BetweenToken {
+		return decl, false
+	}
+	return nil, false
+}
+
+// between compares left value with both bounds, inclusive, in right values.
+// Like AND of two comparisons, result is unknown if a NULL makes a comparison
+// unknown while the other one is not false.
+func between(leftValue Value, rightValue Value) (result bool, known bool) {
+	bounds, ok := rightValue.v.([]interface{})
+	if !ok || len(bounds) != 2 {
+		log.Debug("between: rightValue.v is not a pair of bounds !")
+		return false, false
+	}
+
+	// NULL is never in a range
+	if leftValue.v == nil {
+		return false, false
+	}
+
+	low, high := bounds[0], bounds[1]
+	if low != nil && compareValues(leftValue.v, low) < 0 {
+		return false, true
+	}
+	if high != nil && compareValues(leftValue.v, high) > 0 {
+		return false, true
+	}
+	if low == nil || high == nil {
+		return false, false
+	}
+
+	return true, true
+}
+
+// betweenOperator checks if left value is known to be within bounds
+func betweenOperator(leftValue Value, rightValue Value) bool {
+	result, known := between(leftValue, rightValue)
+	return known && result
+}
+
+// notBetweenOperator checks if left value is known to be out of bounds
+func notBetweenOperator(leftValue Value, rightValue Value) bool {
+	result, known := between(leftValue, rightValue)
+	return known && !result
+}
+
+// betweenKnown returns false if BETWEEN or NOT BETWEEN result is unknown
+func betweenKnown(leftValue Value, rightValue Value) bool {
+	_, known := between(leftValue, rightValue)
+	return known
+}
+
+// listValue returns an element of IN list as a right value
+func listValue(v interface{}) Value {
+	return Value{v: v, valid: v != nil, lexeme: fmt.Sprintf("%v", v)}
+}
+
 func isNullOperator(leftValue Value, rightValue Value) bool {
 	return leftValue.v == nil
 }
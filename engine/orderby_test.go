@@ -231,3 +231,65 @@ func TestOrderByIntEmpty(t *testing.T) {
 	defer rows.Close()
 
 }
+
+func TestOrderByMultipleColumns(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestOrderByMultipleColumns")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE item (id BIGSERIAL, name TEXT, price FLOAT, stock INT)`,
+		`INSERT INTO item (name, price, stock) VALUES ('b', 2.5, 10)`,
+		`INSERT INTO item (name, price, stock) VALUES ('a', 12.25, 10)`,
+		`INSERT INTO item (name, price, stock) VALUES ('c', 2.5, 3)`,
+		`INSERT INTO item (name, price) VALUES ('d', 1.75)`,
+		`INSERT INTO item (name, stock) VALUES ('e', 3)`,
+		`INSERT INTO item (name, price, stock) VALUES ('f', 12.25, 10)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s", err)
+		}
+	}
+
+	names := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("Cannot scan: %s", err)
+			}
+			res += name
+		}
+		return res
+	}
+
+	tests := map[string]string{
+		// NULL last when ascending, first when descending
+		`SELECT name FROM item ORDER BY stock DESC, name ASC`:            "dabfce",
+		`SELECT name FROM item ORDER BY stock ASC, price DESC`:           "ecafbd",
+		`SELECT name FROM item ORDER BY price ASC, stock DESC`:           "dbcafe",
+		`SELECT name FROM item ORDER BY price DESC, name DESC`:           "efacbd",
+		`SELECT name FROM item ORDER BY price ASC NULLS FIRST, name ASC`: "edbcaf",
+		`SELECT name FROM item ORDER BY stock, price DESC NULLS LAST`:    "ceafbd",
+		// stable on equal keys
+		`SELECT name FROM item ORDER BY price`: "dbcafe",
+	}
+
+	for query, expected := range tests {
+		if got := names(query); got != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+/*
+|-> alter
+	|-> table
+		|-> users
+	|-> add
+		|-> age
+			|-> int
+			|-> default
+				|-> 0
+*/
+// parseAlter parses a table alteration
+// ALTER TABLE users ADD COLUMN age INT DEFAULT 0
+// ALTER TABLE users DROP COLUMN IF EXISTS age
+// ALTER TABLE users RENAME COLUMN age TO years
+// ALTER TABLE users RENAME TO accounts
+func (p *parser) parseAlter() (*Instruction, error) {
+	i := &Instruction{}
+
+	alterDecl, err := p.consumeToken(AlterToken)
+	if err != nil {
+		return nil, err
+	}
+	i.Decls = append(i.Decls, alterDecl)
+
+	tableDecl, err := p.consumeToken(TableToken)
+	if err != nil {
+		return nil, err
+	}
+	alterDecl.Add(tableDecl)
+
+	nameDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	tableDecl.Add(nameDecl)
+	p.table = nameDecl.Lexeme
+
+	switch {
+	case p.is(AddToken):
+		addDecl, err := p.consumeToken(AddToken)
+		if err != nil {
+			return nil, err
+		}
+		alterDecl.Add(addDecl)
+
+		// COLUMN keyword is optional
+		if p.is(ColumnToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		columnDecl, err := p.parseColumn()
+		if err != nil {
+			return nil, err
+		}
+		addDecl.Add(columnDecl)
+	case p.is(DropToken):
+		dropDecl, err := p.consumeToken(DropToken)
+		if err != nil {
+			return nil, err
+		}
+		alterDecl.Add(dropDecl)
+
+		// COLUMN keyword is optional
+		if p.is(ColumnToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.is(IfToken) {
+			ifDecl, err := p.consumeToken(IfToken)
+			if err != nil {
+				return nil, err
+			}
+			existsDecl, err := p.consumeToken(ExistsToken)
+			if err != nil {
+				return nil, err
+			}
+			ifDecl.Add(existsDecl)
+			dropDecl.Add(ifDecl)
+		}
+
+		columnDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		dropDecl.Add(columnDecl)
+	case p.is(RenameToken):
+		renameDecl, err := p.consumeToken(RenameToken)
+		if err != nil {
+			return nil, err
+		}
+		alterDecl.Add(renameDecl)
+
+		// Renamed column comes first, COLUMN keyword being optional
+		if p.is(ColumnToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.is(ToToken) {
+			columnDecl, err := p.parseQuotedToken()
+			if err != nil {
+				return nil, err
+			}
+			renameDecl.Add(columnDecl)
+		}
+
+		toDecl, err := p.consumeToken(ToToken)
+		if err != nil {
+			return nil, err
+		}
+		renameDecl.Add(toDecl)
+
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		toDecl.Add(nameDecl)
+	default:
+		return nil, p.syntaxError()
+	}
+
+	return i, nil
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strings"
+)
+
+// parseConflict parses ON CONFLICT clause of INSERT, with an optional list
+// of attributes of the conflicting UNIQUE constraint, and the action to take
+// ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v
+// ON CONFLICT DO NOTHING
+func (p *parser) parseConflict(insertDecl *Decl) error {
+	if _, err := p.consumeToken(OnToken); err != nil {
+		return err
+	}
+	conflictDecl, err := p.consumeToken(ConflictToken)
+	if err != nil {
+		return err
+	}
+	insertDecl.Add(conflictDecl)
+
+	// Conflict target
+	if p.is(BracketOpeningToken) {
+		if err := p.next(); err != nil {
+			return err
+		}
+		for {
+			attrDecl, err := p.parseQuotedToken()
+			if err != nil {
+				return err
+			}
+			conflictDecl.Add(attrDecl)
+
+			if p.is(BracketClosingToken) {
+				break
+			}
+			if _, err := p.consumeToken(CommaToken); err != nil {
+				return err
+			}
+		}
+		if _, err := p.consumeToken(BracketClosingToken); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.consumeToken(DoToken); err != nil {
+		return err
+	}
+
+	if p.is(NothingToken) {
+		nothingDecl, err := p.consumeToken(NothingToken)
+		if err != nil {
+			return err
+		}
+		conflictDecl.Add(nothingDecl)
+		return nil
+	}
+
+	updateDecl, err := p.consumeToken(UpdateToken)
+	if err != nil {
+		return err
+	}
+	conflictDecl.Add(updateDecl)
+
+	setDecl, err := p.consumeToken(SetToken)
+	if err != nil {
+		return err
+	}
+	updateDecl.Add(setDecl)
+
+	for {
+		attributeDecl, err := p.parseCondition()
+		if err != nil {
+			return err
+		}
+		excludedReferences(attributeDecl)
+		setDecl.Add(attributeDecl)
+
+		if !p.is(CommaToken) {
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// excludedReferences names EXCLUDED pseudo-table in lowercase in attributes
+// qualified with it, whichever case it was written with
+func excludedReferences(decl *Decl) {
+	pseudoTableReferences(decl, "excluded")
+}
+
+// pseudoTableReferences names given pseudo-table in lowercase in attributes
+// qualified with it, whichever case it was written with
+func pseudoTableReferences(decl *Decl, name string) {
+	if decl.Token == StringToken && len(decl.Decl) > 0 && decl.Decl[0].Token == StringToken &&
+		strings.EqualFold(decl.Decl[0].Lexeme, name) {
+		decl.Decl[0].Lexeme = name
+	}
+
+	for _, d := range decl.Decl {
+		pseudoTableReferences(d, name)
+	}
+}
@@ -15,9 +15,13 @@ func (p *parser) parseCreate(tokens []Token) (*Instruction, error) {
 	// After create token, should be either
 	// TABLE
 	// INDEX
+	// UNIQUE INDEX
+	// VIEW
+	// SEQUENCE
+	// TRIGGER
 	// ...
 	if !p.hasNext() {
-		return nil, fmt.Errorf("CREATE token must be followed by TABLE, INDEX")
+		return nil, fmt.Errorf("CREATE token must be followed by TABLE, INDEX, VIEW, SEQUENCE, TRIGGER")
 	}
 	p.index++
 
@@ -29,8 +33,38 @@ func (p *parser) parseCreate(tokens []Token) (*Instruction, error) {
 		}
 		createDecl.Add(d)
 		break
+	case ViewToken:
+		d, err := p.parseView()
+		if err != nil {
+			return nil, err
+		}
+		createDecl.Add(d)
+		break
+	case IndexToken, UniqueToken:
+		d, err := p.parseIndex()
+		if err != nil {
+			return nil, err
+		}
+		createDecl.Add(d)
+		break
+	case StringToken:
+		var d *Decl
+		var err error
+		switch {
+		case p.isWord("sequence"):
+			d, err = p.parseSequence()
+		case p.isWord("trigger"):
+			d, err = p.parseTrigger()
+		default:
+			return nil, p.syntaxError()
+		}
+		if err != nil {
+			return nil, err
+		}
+		createDecl.Add(d)
+		break
 	default:
-		return nil, fmt.Errorf("Parsing error near <%s>", tokens[p.index].Lexeme)
+		return nil, p.syntaxError()
 	}
 
 	return i, nil
@@ -72,6 +106,7 @@ func (p *parser) parseTable(tokens []Token) (*Decl, error) {
 		return nil, p.syntaxError()
 	}
 	tableDecl.Add(nameTable)
+	p.table = nameTable.Lexeme
 
 	// Now we should found brackets
 	if !p.hasNext() || tokens[p.index].Token != BracketOpeningToken {
@@ -83,11 +118,39 @@ func (p *parser) parseTable(tokens []Token) (*Decl, error) {
 
 		switch p.cur().Token {
 		case PrimaryToken:
-			_, err := p.parsePrimaryKey()
+			primaryDecl, err := p.parsePrimaryKey()
+			if err != nil {
+				return nil, err
+			}
+			tableDecl.Add(primaryDecl)
+			continue
+		case UniqueToken:
+			uniqueDecl, err := p.parseUniqueConstraint()
 			if err != nil {
 				return nil, err
 			}
+			tableDecl.Add(uniqueDecl)
 			continue
+		case StringToken:
+			if _, err := p.isNext(KeyToken); err == nil && p.isWord("foreign") {
+				foreignDecl, err := p.parseForeignKey()
+				if err != nil {
+					return nil, err
+				}
+				tableDecl.Add(foreignDecl)
+				continue
+			}
+			if p.isCheck() {
+				checkDecl, err := p.parseCheck()
+				if err != nil {
+					return nil, err
+				}
+				tableDecl.Add(checkDecl)
+				if p.is(CommaToken) {
+					p.consumeToken(CommaToken)
+				}
+				continue
+			}
 		default:
 		}
 
@@ -97,114 +160,180 @@ func (p *parser) parseTable(tokens []Token) (*Decl, error) {
 			break
 		}
 
-		// New attribute name
-		newAttribute, err := p.parseQuotedToken()
+		// New attribute
+		newAttribute, err := p.parseColumn()
 		if err != nil {
 			return nil, err
 		}
 		tableDecl.Add(newAttribute)
 
-		newAttributeType, err := p.parseType()
-		if err != nil {
-			return nil, err
+		// The current token is either closing bracked or comma.
+
+		// Closing bracket means table parsing stops.
+		if tokens[p.index].Token == BracketClosingToken {
+			p.index++
+			break
 		}
-		newAttribute.Add(newAttributeType)
 
-		// All the following tokens until bracket or comma are column constraints.
-		// Column constraints can be listed in any order.
-		for p.isNot(BracketClosingToken, CommaToken) {
-			switch p.cur().Token {
-			case UniqueToken: // UNIQUE
-				uniqueDecl, err := p.consumeToken(UniqueToken)
+		// Comma means continue on next table column.
+		p.index++
+	}
+
+	return tableDecl, nil
+}
+
+/*
+|-> age
+	|-> int
+	|-> default
+		|-> 0
+*/
+// parseColumn parses a column definition: its name, type and constraints
+// age INT NOT NULL DEFAULT 0
+// updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+// user_id INT REFERENCES users (id)
+// age INT CHECK (age >= 0)
+func (p *parser) parseColumn() (*Decl, error) {
+	// New attribute name
+	newAttribute, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+
+	newAttributeType, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	newAttribute.Add(newAttributeType)
+
+	// All the following tokens until bracket, comma or end of statement are column constraints.
+	// Column constraints can be listed in any order.
+	for p.isNot(BracketClosingToken, CommaToken, SemicolonToken) {
+		switch p.cur().Token {
+		case UniqueToken: // UNIQUE
+			uniqueDecl, err := p.consumeToken(UniqueToken)
+			if err != nil {
+				return nil, err
+			}
+			newAttribute.Add(uniqueDecl)
+		case NotToken: // NOT NULL
+			if _, err = p.isNext(NullToken); err == nil {
+				notDecl, err := p.consumeToken(NotToken)
 				if err != nil {
 					return nil, err
 				}
-				newAttribute.Add(uniqueDecl)
-			case NotToken: // NOT NULL
-				if _, err = p.isNext(NullToken); err == nil {
-					notDecl, err := p.consumeToken(NotToken)
-					if err != nil {
-						return nil, err
-					}
-					newAttribute.Add(notDecl)
-					nullDecl, err := p.consumeToken(NullToken)
-					if err != nil {
-						return nil, err
-					}
-					notDecl.Add(nullDecl)
+				newAttribute.Add(notDecl)
+				nullDecl, err := p.consumeToken(NullToken)
+				if err != nil {
+					return nil, err
 				}
-			case PrimaryToken: // PRIMARY KEY
-				if _, err = p.isNext(KeyToken); err == nil {
-					newPrimary := NewDecl(tokens[p.index])
-					newAttribute.Add(newPrimary)
+				notDecl.Add(nullDecl)
+			}
+		case PrimaryToken: // PRIMARY KEY
+			if _, err = p.isNext(KeyToken); err == nil {
+				newPrimary := NewDecl(p.cur())
+				newAttribute.Add(newPrimary)
 
-					if err = p.next(); err != nil {
-						return nil, fmt.Errorf("Unexpected end")
-					}
+				if err = p.next(); err != nil {
+					return nil, fmt.Errorf("Unexpected end")
+				}
 
-					newKey := NewDecl(tokens[p.index])
-					newPrimary.Add(newKey)
+				newKey := NewDecl(p.cur())
+				newPrimary.Add(newKey)
 
-					if err = p.next(); err != nil {
-						return nil, fmt.Errorf("Unexpected end")
-					}
+				if err = p.next(); err != nil {
+					return nil, fmt.Errorf("Unexpected end")
 				}
-			case AutoincrementToken:
-				autoincDecl, err := p.consumeToken(AutoincrementToken)
+			}
+		case AutoincrementToken:
+			autoincDecl, err := p.consumeToken(AutoincrementToken)
+			if err != nil {
+				return nil, err
+			}
+			newAttribute.Add(autoincDecl)
+		case WithToken: // WITH TIME ZONE
+			if strings.ToLower(newAttributeType.Lexeme) == "timestamp" {
+				withDecl, err := p.consumeToken(WithToken)
 				if err != nil {
 					return nil, err
 				}
-				newAttribute.Add(autoincDecl)
-			case WithToken: // WITH TIME ZONE
-				if strings.ToLower(newAttributeType.Lexeme) == "timestamp" {
-					withDecl, err := p.consumeToken(WithToken)
-					if err != nil {
-						return nil, err
-					}
-					timeDecl, err := p.consumeToken(TimeToken)
-					if err != nil {
-						return nil, err
-					}
-					zoneDecl, err := p.consumeToken(ZoneToken)
-					if err != nil {
-						return nil, err
-					}
-					newAttributeType.Add(withDecl)
-					withDecl.Add(timeDecl)
-					timeDecl.Add(zoneDecl)
-				}
-			case DefaultToken: // DEFAULT
-				dDecl, err := p.consumeToken(DefaultToken)
+				timeDecl, err := p.consumeToken(TimeToken)
 				if err != nil {
 					return nil, err
 				}
-				newAttribute.Add(dDecl)
-				vDecl, err := p.consumeToken(FalseToken, StringToken, NumberToken, LocalTimestampToken)
+				zoneDecl, err := p.consumeToken(ZoneToken)
 				if err != nil {
 					return nil, err
 				}
-				dDecl.Add(vDecl)
+				newAttributeType.Add(withDecl)
+				withDecl.Add(timeDecl)
+				timeDecl.Add(zoneDecl)
+			}
+		case DefaultToken: // DEFAULT
+			dDecl, err := p.consumeToken(DefaultToken)
+			if err != nil {
+				return nil, err
+			}
+			newAttribute.Add(dDecl)
+			// Default value is either a literal or an expression evaluated at insert time
+			var vDecl *Decl
+			if p.is(FalseToken) {
+				vDecl, err = p.consumeToken(FalseToken)
+			} else {
+				vDecl, err = p.parseExpression()
+			}
+			if err != nil {
+				return nil, err
+			}
+			dDecl.Add(vDecl)
+		case StringToken: // REFERENCES or CHECK
+			var d *Decl
+			switch {
+			case p.isWord("references"):
+				d, err = p.parseReferences()
+			case p.isCheck():
+				d, err = p.parseCheck()
 			default:
-				// Unknown column constraint
 				return nil, p.syntaxError()
 			}
+			if err != nil {
+				return nil, err
+			}
+			newAttribute.Add(d)
+		case OnToken: // ON UPDATE
+			onDecl, err := p.consumeToken(OnToken)
+			if err != nil {
+				return nil, err
+			}
+			newAttribute.Add(onDecl)
+			updateDecl, err := p.consumeToken(UpdateToken)
+			if err != nil {
+				return nil, err
+			}
+			onDecl.Add(updateDecl)
+			// Value is computed for each updated row, like a default one
+			vDecl, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			updateDecl.Add(vDecl)
+		default:
+			// Unknown column constraint
+			return nil, p.syntaxError()
 		}
-
-		// The current token is either closing bracked or comma.
-
-		// Closing bracket means table parsing stops.
-		if tokens[p.index].Token == BracketClosingToken {
-			p.index++
-			break
-		}
-
-		// Comma means continue on next table column.
-		p.index++
 	}
 
-	return tableDecl, nil
+	return newAttribute, nil
 }
 
+/*
+|-> primary
+	|-> key
+	|-> id
+*/
+// parsePrimaryKey parses a table PRIMARY KEY constraint on a list of columns,
+// and the comma following it if any
+// PRIMARY KEY (id)
 func (p *parser) parsePrimaryKey() (*Decl, error) {
 	primaryDecl, err := p.consumeToken(PrimaryToken)
 	if err != nil {
@@ -227,6 +356,7 @@ func (p *parser) parsePrimaryKey() (*Decl, error) {
 		if err != nil {
 			return nil, err
 		}
+		primaryDecl.Add(d)
 
 		d, err = p.consumeToken(CommaToken, BracketClosingToken)
 		if err != nil {
@@ -237,5 +367,270 @@ func (p *parser) parsePrimaryKey() (*Decl, error) {
 		}
 	}
 
+	if p.is(CommaToken) {
+		p.consumeToken(CommaToken)
+	}
+
 	return primaryDecl, nil
 }
+
+/*
+|-> unique
+	|-> first_name
+	|-> last_name
+*/
+// parseUniqueConstraint parses a table UNIQUE constraint on a list of columns,
+// and the comma following it if any
+// UNIQUE (first_name, last_name)
+func (p *parser) parseUniqueConstraint() (*Decl, error) {
+	uniqueDecl, err := p.consumeToken(UniqueToken)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consumeToken(BracketOpeningToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		d, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		uniqueDecl.Add(d)
+
+		d, err = p.consumeToken(CommaToken, BracketClosingToken)
+		if err != nil {
+			return nil, err
+		}
+		if d.Token == BracketClosingToken {
+			break
+		}
+	}
+
+	if p.is(CommaToken) {
+		p.consumeToken(CommaToken)
+	}
+
+	return uniqueDecl, nil
+}
+
+/*
+|-> foreign
+	|-> user_id
+	|-> references
+		|-> users
+			|-> id
+*/
+// parseForeignKey parses a table FOREIGN KEY constraint on a list of columns,
+// and the comma following it if any
+// FOREIGN KEY (user_id) REFERENCES users (id)
+func (p *parser) parseForeignKey() (*Decl, error) {
+	foreignDecl := &Decl{Token: ForeignToken, Lexeme: "foreign"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if _, err := p.consumeToken(KeyToken); err != nil {
+		return nil, err
+	}
+
+	_, err := p.consumeToken(BracketOpeningToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		d, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		foreignDecl.Add(d)
+
+		d, err = p.consumeToken(CommaToken, BracketClosingToken)
+		if err != nil {
+			return nil, err
+		}
+		if d.Token == BracketClosingToken {
+			break
+		}
+	}
+
+	if !p.isWord("references") {
+		return nil, p.syntaxError()
+	}
+	referencesDecl, err := p.parseReferences()
+	if err != nil {
+		return nil, err
+	}
+	foreignDecl.Add(referencesDecl)
+
+	if p.is(CommaToken) {
+		p.consumeToken(CommaToken)
+	}
+
+	return foreignDecl, nil
+}
+
+/*
+|-> references
+	|-> users
+		|-> id
+	|-> on
+		|-> delete
+			|-> cascade
+*/
+// parseReferences parses the table referenced by a foreign key, its columns if given, and the
+// action on delete of a referenced row, which is either CASCADE, SET NULL, RESTRICT or NO ACTION
+// REFERENCES users (id) ON DELETE CASCADE
+func (p *parser) parseReferences() (*Decl, error) {
+	referencesDecl := &Decl{Token: ReferencesToken, Lexeme: "references"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	tableDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	referencesDecl.Add(tableDecl)
+
+	if p.is(BracketOpeningToken) {
+		if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+			return nil, err
+		}
+		for {
+			d, err := p.parseQuotedToken()
+			if err != nil {
+				return nil, err
+			}
+			tableDecl.Add(d)
+
+			d, err = p.consumeToken(CommaToken, BracketClosingToken)
+			if err != nil {
+				return nil, err
+			}
+			if d.Token == BracketClosingToken {
+				break
+			}
+		}
+	}
+
+	// ON UPDATE following a column foreign key is the value of the column on update
+	if _, err := p.isNext(DeleteToken); err != nil || !p.is(OnToken) {
+		return referencesDecl, nil
+	}
+	onDecl, err := p.consumeToken(OnToken)
+	if err != nil {
+		return nil, err
+	}
+	referencesDecl.Add(onDecl)
+	deleteDecl, err := p.consumeToken(DeleteToken)
+	if err != nil {
+		return nil, err
+	}
+	onDecl.Add(deleteDecl)
+
+	switch {
+	case p.is(CascadeToken):
+		cascadeDecl, err := p.consumeToken(CascadeToken)
+		if err != nil {
+			return nil, err
+		}
+		deleteDecl.Add(cascadeDecl)
+	case p.is(SetToken):
+		setDecl, err := p.consumeToken(SetToken)
+		if err != nil {
+			return nil, err
+		}
+		nullDecl, err := p.consumeToken(NullToken)
+		if err != nil {
+			return nil, err
+		}
+		setDecl.Add(nullDecl)
+		deleteDecl.Add(setDecl)
+	case p.isWord("restrict"):
+		deleteDecl.Add(&Decl{Token: StringToken, Lexeme: "restrict"})
+		p.next()
+	case p.isWord("no"):
+		p.next()
+		if !p.isWord("action") {
+			return nil, p.syntaxError()
+		}
+		deleteDecl.Add(&Decl{Token: StringToken, Lexeme: "no action"})
+		p.next()
+	default:
+		return nil, p.syntaxError()
+	}
+
+	return referencesDecl, nil
+}
+
+// isCheck returns true if current token starts a CHECK constraint, which may be named
+// CHECK (age >= 0)
+// CONSTRAINT positive_age CHECK (age >= 0)
+func (p *parser) isCheck() bool {
+	if p.isWord("check") {
+		_, err := p.isNext(BracketOpeningToken)
+		return err == nil
+	}
+
+	return p.isWord("constraint") && p.index+2 < len(p.tokens) &&
+		p.tokens[p.index+2].Token == StringToken && strings.EqualFold(p.tokens[p.index+2].Lexeme, "check")
+}
+
+/*
+|-> check
+	|-> (
+		|-> age
+			|-> >=
+			|-> 0
+	|-> constraint
+		|-> positive_age
+*/
+// parseCheck parses a CHECK constraint, with its name if given. Unquoted names compared
+// in its condition are attributes of the table, not values.
+// CONSTRAINT positive_age CHECK (age >= 0)
+func (p *parser) parseCheck() (*Decl, error) {
+	checkDecl := &Decl{Token: CheckToken, Lexeme: "check"}
+
+	var constraintDecl *Decl
+	if p.isWord("constraint") {
+		constraintDecl = &Decl{Token: ConstraintToken, Lexeme: "constraint"}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		constraintDecl.Add(nameDecl)
+	}
+
+	if !p.isWord("check") {
+		return nil, p.syntaxError()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	conditionDecl, err := p.consumeToken(BracketOpeningToken)
+	if err != nil {
+		return nil, err
+	}
+	p.checking = true
+	err = p.parseConditions(conditionDecl)
+	p.checking = false
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+	checkDecl.Add(conditionDecl)
+
+	if constraintDecl != nil {
+		checkDecl.Add(constraintDecl)
+	}
+
+	return checkDecl, nil
+}
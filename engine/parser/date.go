@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,10 @@ const DateShortFormat = "2006-Jan-02"
 
 const DateNumberFormat = "2006-01-02"
 
+// dateOffsetFormat is DateLongFormat without zone name, which is not parsed when it
+// is not an abbreviation, as for fixed zones
+const dateOffsetFormat = "2006-01-02 15:04:05.999999999 -0700"
+
 // ParseDate intends to parse all SQL date format
 func ParseDate(data string) (*time.Time, error) {
 	t, err := time.Parse(DateLongFormat, data)
@@ -20,6 +25,13 @@ func ParseDate(data string) (*time.Time, error) {
 		return &t, nil
 	}
 
+	if i := strings.LastIndexByte(data, ' '); i > 0 {
+		t, err = time.Parse(dateOffsetFormat, data[:i])
+		if err == nil {
+			return &t, nil
+		}
+	}
+
 	t, err = time.Parse(time.RFC3339, data)
 	if err == nil {
 		return &t, nil
@@ -19,12 +19,15 @@ func (p *parser) parseDelete() (*Instruction, error) {
 	}
 	deleteDecl.Add(fromDecl)
 
-	// Should be a table name
+	// Should be a table name, with an optional alias
 	nameDecl, err := p.parseQuotedToken()
 	if err != nil {
 		return nil, err
 	}
 	fromDecl.Add(nameDecl)
+	if err := p.parseAlias(nameDecl); err != nil {
+		return nil, err
+	}
 
 	// MAY be WHERE  here
 	debug("WHERE ? %v", p.tokens[p.index])
@@ -32,9 +35,17 @@ func (p *parser) parseDelete() (*Instruction, error) {
 		return i, nil
 	}
 
-	err = p.parseWhere(deleteDecl)
-	if err != nil {
-		return nil, err
+	if !p.is(ReturningToken) {
+		err = p.parseWhere(deleteDecl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.is(ReturningToken) {
+		if err := p.parseReturning(deleteDecl); err != nil {
+			return nil, err
+		}
 	}
 
 	return i, nil
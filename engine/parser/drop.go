@@ -14,14 +14,40 @@ func (p *parser) parseDrop() (*Instruction, error) {
 	}
 	i.Decls = append(i.Decls, trDecl)
 
-	tableDecl, err := p.consumeToken(TableToken)
-	if err != nil {
-		log.Debug("Consume table !\n")
-		return nil, err
+	// Either DROP TABLE, DROP INDEX, DROP VIEW, DROP SEQUENCE or DROP TRIGGER
+	var tableDecl *Decl
+	if p.isWord("sequence") || p.isWord("trigger") {
+		tableDecl = &Decl{Token: SequenceToken, Lexeme: "sequence"}
+		if p.isWord("trigger") {
+			tableDecl = &Decl{Token: TriggerToken, Lexeme: "trigger"}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	} else {
+		tableDecl, err = p.consumeToken(TableToken, IndexToken, ViewToken)
+		if err != nil {
+			log.Debug("Consume table !\n")
+			return nil, err
+		}
 	}
 	trDecl.Add(tableDecl)
 
-	// Should be a table name
+	// Maybe have "IF EXISTS" here
+	if p.is(IfToken) {
+		ifDecl, err := p.consumeToken(IfToken)
+		if err != nil {
+			return nil, err
+		}
+		existsDecl, err := p.consumeToken(ExistsToken)
+		if err != nil {
+			return nil, err
+		}
+		ifDecl.Add(existsDecl)
+		tableDecl.Add(ifDecl)
+	}
+
+	// Should be a table, index, view, sequence or trigger name
 	nameDecl, err := p.parseQuotedToken()
 	if err != nil {
 		log.Debug("UH ?\n")
@@ -29,5 +55,19 @@ func (p *parser) parseDrop() (*Instruction, error) {
 	}
 	tableDecl.Add(nameDecl)
 
+	// Trigger may be followed by its table
+	if tableDecl.Token == TriggerToken && p.is(OnToken) {
+		onDecl, err := p.consumeToken(OnToken)
+		if err != nil {
+			return nil, err
+		}
+		onTableDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		onDecl.Add(onTableDecl)
+		tableDecl.Add(onDecl)
+	}
+
 	return i, nil
 }
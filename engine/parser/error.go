@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetWidth is the number of characters quoted on each side of an error position
+const snippetWidth = 20
+
+// SyntaxError is an error in the syntax of an instruction, near a token. Once located,
+// it has the position of the token, and a snippet of the text around it.
+type SyntaxError struct {
+	Message string
+	// Token is the lexeme of the offending token, if any
+	Token string
+	// Position of the error in instruction, in characters counting from 1,
+	// and its Line and Column, or 0 until located
+	Position int
+	Line     int
+	Column   int
+	// Snippet is the text of the line around the error
+	Snippet string
+	// pos is the offset of the error in instruction, in bytes
+	pos int
+}
+
+func (err *SyntaxError) Error() string {
+	if err.Line == 0 {
+		return err.Message
+	}
+
+	return fmt.Sprintf("%s at line %d, column %d: %s", err.Message, err.Line, err.Column, err.Snippet)
+}
+
+// locate sets the line, column and snippet of a syntax error in given instruction
+func locate(err error, instruction string) error {
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		return err
+	}
+
+	pos := se.pos
+	if pos > len(instruction) {
+		pos = len(instruction)
+	}
+	start := strings.LastIndexByte(instruction[:pos], '\n') + 1
+	end := strings.IndexByte(instruction[pos:], '\n')
+	if end < 0 {
+		end = len(instruction)
+	} else {
+		end += pos
+	}
+
+	se.Position = utf8.RuneCountInString(instruction[:pos]) + 1
+	se.Line = strings.Count(instruction[:start], "\n") + 1
+	se.Column = utf8.RuneCountInString(instruction[start:pos]) + 1
+
+	// Quote a few characters on each side, on the same line
+	before := []rune(instruction[start:pos])
+	after := []rune(instruction[pos:end])
+	prefix, suffix := "", ""
+	if len(before) > snippetWidth {
+		before, prefix = before[len(before)-snippetWidth:], "..."
+	}
+	if len(after) > snippetWidth {
+		after, suffix = after[:snippetWidth], "..."
+	}
+	se.Snippet = prefix + strings.TrimSpace(string(before)+string(after)) + suffix
+
+	return se
+}
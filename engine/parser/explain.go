@@ -0,0 +1,29 @@
+package parser
+
+/*
+|-> explain
+	|-> select
+		|-> *
+		|-> from
+			|-> users
+*/
+// parseExplain parses a query which plan is described instead of being executed
+// EXPLAIN SELECT * FROM users WHERE email = 'foo@bar.com'
+func (p *parser) parseExplain(tokens []Token) (*Instruction, error) {
+	explainDecl, err := p.consumeToken(ExplainToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.is(SelectToken, WithToken) {
+		return nil, p.syntaxError()
+	}
+	i, err := p.parseSelect(tokens)
+	if err != nil {
+		return nil, err
+	}
+	explainDecl.Add(i.Decls[0])
+	i.Decls = []*Decl{explainDecl}
+
+	return i, nil
+}
@@ -0,0 +1,521 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> +
+	|-> *
+		|-> price
+		|-> quantity
+	|-> 10
+*/
+// parseExpression parses a value computed for each row. Multiplication and division
+// bind more tightly than addition and subtraction.
+// price * quantity + 10
+func (p *parser) parseExpression() (*Decl, error) {
+	decl, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(PlusToken, MinusToken) {
+		opDecl, err := p.consumeToken(PlusToken, MinusToken)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		opDecl.Add(decl)
+		opDecl.Add(right)
+		decl = opDecl
+	}
+
+	return decl, nil
+}
+
+// parseTerm parses factors multiplied or divided together
+func (p *parser) parseTerm() (*Decl, error) {
+	decl, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(StarToken, SlashToken) {
+		opDecl, err := p.consumeToken(StarToken, SlashToken)
+		if err != nil {
+			return nil, err
+		}
+		if opDecl.Token == StarToken {
+			opDecl.Token = MultiplyToken
+		}
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		opDecl.Add(decl)
+		opDecl.Add(right)
+		decl = opDecl
+	}
+
+	return decl, nil
+}
+
+/*
+|-> cast
+	|-> price
+	|-> integer
+	|-> )
+*/
+// parseFactor parses a primary value, cast to given type with PostgreSQL shorthand,
+// or a JSON value from which a field or an element is extracted. A cast is a call
+// to CAST function, and an extraction a call to JSON_EXTRACT_PATH, or to
+// JSON_EXTRACT_PATH_TEXT to get it as text.
+// price::integer
+// data -> 'user' ->> 'name'
+// tags -> 0
+func (p *parser) parseFactor() (*Decl, error) {
+	decl, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(DoubleColonToken, ArrowToken, DoubleArrowToken) {
+		if p.is(ArrowToken, DoubleArrowToken) {
+			extractDecl := &Decl{Token: FunctionToken, Lexeme: "json_extract_path"}
+			if p.is(DoubleArrowToken) {
+				extractDecl.Lexeme = "json_extract_path_text"
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			pathDecl, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			extractDecl.Add(decl)
+			extractDecl.Add(pathDecl)
+			extractDecl.Add(&Decl{Token: BracketClosingToken, Lexeme: ")"})
+			decl = extractDecl
+			continue
+		}
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		typeDecl, err := p.parseCastType()
+		if err != nil {
+			return nil, err
+		}
+		castDecl := &Decl{Token: FunctionToken, Lexeme: "cast"}
+		castDecl.Add(decl)
+		castDecl.Add(typeDecl)
+		castDecl.Add(&Decl{Token: BracketClosingToken, Lexeme: ")"})
+		decl = castDecl
+	}
+
+	return decl, nil
+}
+
+// parsePrimary parses a negated factor, an expression between brackets, a CASE expression,
+// a function call, current time, NULL, a number, a quoted string or an attribute
+func (p *parser) parsePrimary() (*Decl, error) {
+	switch {
+	case p.is(MinusToken):
+		minusDecl, err := p.consumeToken(MinusToken)
+		if err != nil {
+			return nil, err
+		}
+		// Negative number
+		if p.is(NumberToken) {
+			numDecl, err := p.consumeToken(NumberToken)
+			if err != nil {
+				return nil, err
+			}
+			numDecl.Lexeme = "-" + numDecl.Lexeme
+			return numDecl, nil
+		}
+		// Negated value is subtracted from zero
+		operandDecl, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		minusDecl.Add(&Decl{Token: NumberToken, Lexeme: "0"})
+		minusDecl.Add(operandDecl)
+		return minusDecl, nil
+	case p.is(BracketOpeningToken):
+		if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+			return nil, err
+		}
+		decl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeToken(BracketClosingToken); err != nil {
+			return nil, err
+		}
+		return decl, nil
+	case p.is(CaseToken):
+		return p.parseCase()
+	case p.isArrayConstructor():
+		return p.parseArray()
+	case p.isFunctionCall():
+		return p.parseFunctionCall()
+	case p.is(NowToken, CurrentTimestampToken, LocalTimestampToken):
+		return p.consumeToken(NowToken, CurrentTimestampToken, LocalTimestampToken)
+	case p.is(NullToken, NumberToken, TrueToken, FalseToken):
+		return p.consumeToken(NullToken, NumberToken, TrueToken, FalseToken)
+	case p.is(SimpleQuoteToken):
+		valueDecl, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		valueDecl.Token = QuotedStringToken
+		return valueDecl, nil
+	}
+
+	return p.parseAttribute()
+}
+
+// isFunctionCall returns true if current tokens are a name followed by a bracket
+func (p *parser) isFunctionCall() bool {
+	return p.is(StringToken) && p.index+1 < p.tokenLen && p.tokens[p.index+1].Token == BracketOpeningToken
+}
+
+// isArrayConstructor returns true if current tokens are ARRAY followed by a square bracket
+func (p *parser) isArrayConstructor() bool {
+	return p.is(StringToken) && strings.EqualFold(p.cur().Lexeme, "array") &&
+		p.index+1 < p.tokenLen && p.tokens[p.index+1].Token == SquareBracketOpeningToken
+}
+
+/*
+|-> array
+	|-> a
+	|-> b
+	|-> )
+*/
+// parseArray parses an array constructor, which is a call to ARRAY function with its elements
+// ARRAY['a', 'b']
+func (p *parser) parseArray() (*Decl, error) {
+	if _, err := p.consumeToken(StringToken); err != nil {
+		return nil, err
+	}
+	arrayDecl := &Decl{Token: FunctionToken, Lexeme: "array"}
+
+	if _, err := p.consumeToken(SquareBracketOpeningToken); err != nil {
+		return nil, err
+	}
+	for !p.is(SquareBracketClosingToken) {
+		elementDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		arrayDecl.Add(elementDecl)
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.consumeToken(SquareBracketClosingToken); err != nil {
+		return nil, err
+	}
+	arrayDecl.Add(&Decl{Token: BracketClosingToken, Lexeme: ")"})
+
+	return arrayDecl, nil
+}
+
+/*
+|-> substr
+	|-> sku
+	|-> 1
+	|-> 3
+	|-> )
+*/
+// parseFunctionCall parses a function name and its arguments. A closing bracket decl
+// ends the arguments, following tokens like an alias being added after it.
+// SUBSTR(sku, 1, 3)
+// EXTRACT(YEAR FROM created_at)
+// CAST(price AS INTEGER)
+func (p *parser) parseFunctionCall() (*Decl, error) {
+	nameDecl, err := p.consumeToken(StringToken)
+	if err != nil {
+		return nil, err
+	}
+	funcDecl := &Decl{Token: FunctionToken, Lexeme: strings.ToLower(nameDecl.Lexeme)}
+
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+
+	// Extracted field is given as first argument
+	if funcDecl.Lexeme == "extract" && p.is(StringToken) {
+		fieldDecl, err := p.consumeToken(StringToken)
+		if err != nil {
+			return nil, err
+		}
+		fieldDecl.Token = QuotedStringToken
+		funcDecl.Add(fieldDecl)
+
+		if _, err := p.consumeToken(FromToken); err != nil {
+			return nil, err
+		}
+		argDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		funcDecl.Add(argDecl)
+	}
+
+	// Target type is given as second argument
+	if funcDecl.Lexeme == "cast" {
+		argDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		funcDecl.Add(argDecl)
+
+		if _, err := p.consumeToken(AsToken); err != nil {
+			return nil, err
+		}
+		typeDecl, err := p.parseCastType()
+		if err != nil {
+			return nil, err
+		}
+		funcDecl.Add(typeDecl)
+	}
+
+	for !p.is(BracketClosingToken) {
+		argDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		funcDecl.Add(argDecl)
+
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	closingDecl, err := p.consumeToken(BracketClosingToken)
+	if err != nil {
+		return nil, err
+	}
+	funcDecl.Add(closingDecl)
+
+	// Window function
+	if p.is(OverToken) {
+		overDecl, err := p.parseOver()
+		if err != nil {
+			return nil, err
+		}
+		funcDecl.Add(overDecl)
+	}
+
+	return funcDecl, nil
+}
+
+/*
+|-> over
+	|-> partition
+		|-> user_id
+	|-> order
+		|-> created_at
+			|-> desc
+*/
+// parseOver parses the window a window function is computed over
+// OVER (PARTITION BY user_id ORDER BY created_at DESC)
+// OVER (ORDER BY score DESC)
+// OVER ()
+func (p *parser) parseOver() (*Decl, error) {
+	overDecl, err := p.consumeToken(OverToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+
+	if p.is(PartitionToken) {
+		partitionDecl, err := p.consumeToken(PartitionToken)
+		if err != nil {
+			return nil, err
+		}
+		overDecl.Add(partitionDecl)
+
+		if _, err := p.consumeToken(ByToken); err != nil {
+			return nil, err
+		}
+		for {
+			attrDecl, err := p.parseAttribute()
+			if err != nil {
+				return nil, err
+			}
+			partitionDecl.Add(attrDecl)
+
+			if !p.is(CommaToken) {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.is(OrderToken) {
+		if err := p.parseOrderBy(overDecl); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+
+	return overDecl, nil
+}
+
+// parseCastType parses the type a value is cast to, as a lowercase quoted string.
+// Type modifiers and time zone are ignored.
+// DOUBLE PRECISION
+// VARCHAR(32)
+// TIMESTAMP WITH TIME ZONE
+func (p *parser) parseCastType() (*Decl, error) {
+	typeDecl, err := p.consumeToken(StringToken)
+	if err != nil {
+		return nil, err
+	}
+	typeDecl.Token = QuotedStringToken
+	typeDecl.Lexeme = strings.ToLower(typeDecl.Lexeme)
+
+	// Two words types
+	if p.is(StringToken) {
+		switch next := strings.ToLower(p.tokens[p.index].Lexeme); {
+		case typeDecl.Lexeme == "double" && next == "precision",
+			typeDecl.Lexeme == "character" && next == "varying":
+			typeDecl.Lexeme += " " + next
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Length or precision
+	if p.is(BracketOpeningToken) {
+		for !p.is(BracketClosingToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.consumeToken(BracketClosingToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.is(WithToken) {
+		for _, t := range []int{WithToken, TimeToken, ZoneToken} {
+			if _, err := p.consumeToken(t); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return typeDecl, nil
+}
+
+/*
+|-> case
+	|-> when
+		|-> score
+			|-> >=
+			|-> 90
+		|-> then
+			|-> A
+	|-> else
+		|-> C
+	|-> end
+*/
+// parseCase parses a searched CASE expression, or a simple one comparing
+// an operand with each WHEN value. Following tokens, like an alias,
+// are added after the end decl.
+// CASE WHEN score >= 90 THEN 'A' WHEN score >= 80 THEN 'B' ELSE 'C' END
+// CASE country WHEN 'FR' THEN 'Europe' END
+func (p *parser) parseCase() (*Decl, error) {
+	caseDecl, err := p.consumeToken(CaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	simple := !p.is(WhenToken)
+	if simple {
+		operandDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		caseDecl.Add(operandDecl)
+	}
+
+	for p.is(WhenToken) {
+		whenDecl, err := p.consumeToken(WhenToken)
+		if err != nil {
+			return nil, err
+		}
+		caseDecl.Add(whenDecl)
+
+		if simple {
+			valueDecl, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			whenDecl.Add(valueDecl)
+		} else if err := p.parseConditions(whenDecl); err != nil {
+			return nil, err
+		}
+
+		thenDecl, err := p.consumeToken(ThenToken)
+		if err != nil {
+			return nil, err
+		}
+		whenDecl.Add(thenDecl)
+
+		resultDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		thenDecl.Add(resultDecl)
+	}
+
+	if len(caseDecl.Decl) == 0 || caseDecl.Decl[len(caseDecl.Decl)-1].Token != WhenToken {
+		return nil, p.syntaxError()
+	}
+
+	if p.is(ElseToken) {
+		elseDecl, err := p.consumeToken(ElseToken)
+		if err != nil {
+			return nil, err
+		}
+		caseDecl.Add(elseDecl)
+
+		resultDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		elseDecl.Add(resultDecl)
+	}
+
+	endDecl, err := p.consumeToken(EndToken)
+	if err != nil {
+		return nil, err
+	}
+	caseDecl.Add(endDecl)
+
+	return caseDecl, nil
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> index
+	|-> unique
+	|-> idx_user_email
+	|-> on
+		|-> users
+			|-> email
+*/
+// parseIndex parses an index definition, following CREATE token. Index name
+// may be omitted, and access method is ignored since all indexes are hash ones.
+// UNIQUE INDEX idx_user_email ON users USING hash (email)
+func (p *parser) parseIndex() (*Decl, error) {
+	var uniqueDecl *Decl
+	var err error
+
+	if p.is(UniqueToken) {
+		uniqueDecl, err = p.consumeToken(UniqueToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	indexDecl, err := p.consumeToken(IndexToken)
+	if err != nil {
+		return nil, err
+	}
+	if uniqueDecl != nil {
+		indexDecl.Add(uniqueDecl)
+	}
+
+	if !p.is(OnToken) {
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		indexDecl.Add(nameDecl)
+	}
+
+	onDecl, err := p.consumeToken(OnToken)
+	if err != nil {
+		return nil, err
+	}
+	indexDecl.Add(onDecl)
+
+	tableDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	onDecl.Add(tableDecl)
+
+	if p.is(StringToken) && strings.ToLower(p.cur().Lexeme) == "using" {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeToken(StringToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+	for {
+		d, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		tableDecl.Add(d)
+
+		d, err = p.consumeToken(CommaToken, BracketClosingToken)
+		if err != nil {
+			return nil, err
+		}
+		if d.Token == BracketClosingToken {
+			break
+		}
+	}
+
+	return indexDecl, nil
+}
@@ -10,13 +10,13 @@ func ParseInstruction(instruction string) ([]Instruction, error) {
 	l := lexer{}
 	tokens, err := l.lex([]byte(instruction))
 	if err != nil {
-		return nil, err
+		return nil, locate(err, instruction)
 	}
 
-	p := parser{}
+	p := parser{source: []byte(instruction)}
 	instructions, err := p.parse(tokens)
 	if err != nil {
-		return nil, err
+		return nil, locate(err, instruction)
 	}
 
 	if len(instructions) == 0 {
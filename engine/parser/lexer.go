@@ -1,7 +1,8 @@
 package parser
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/hex"
 	"unicode"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -16,6 +17,8 @@ const (
 	CommaToken
 	BracketOpeningToken
 	BracketClosingToken
+	SquareBracketOpeningToken
+	SquareBracketClosingToken
 	LeftDipleToken
 	RightDipleToken
 	LessOrEqualToken
@@ -29,6 +32,12 @@ const (
 	StarToken
 	EqualityToken
 	PeriodToken
+	PlusToken
+	MinusToken
+	SlashToken
+	DoubleColonToken
+	ArrowToken
+	DoubleArrowToken
 
 	// First order Token
 
@@ -41,6 +50,7 @@ const (
 	TruncateToken
 	DropToken
 	GrantToken
+	AlterToken
 
 	// Second order Token
 
@@ -75,9 +85,75 @@ const (
 	DefaultToken
 	LocalTimestampToken
 	FalseToken
+	TrueToken
 	UniqueToken
 	NowToken
 	OffsetToken
+	InnerToken
+	AsToken
+	LeftToken
+	OuterToken
+	RightToken
+	FullToken
+	GroupToken
+	SumToken
+	AvgToken
+	MinToken
+	MaxToken
+	GroupConcatToken
+	StringAggToken
+	OverToken
+	PartitionToken
+	// RecursiveToken is not lexed, so recursive can still be a name, but set by parser
+	RecursiveToken
+	// TupleToken is not lexed either, but set by parser on a list of values between brackets
+	TupleToken
+	// SequenceToken is not lexed, so sequence can still be a name, but set by parser
+	SequenceToken
+	// TriggerToken and RaiseToken are not lexed either
+	TriggerToken
+	RaiseToken
+	// ForeignToken and ReferencesToken are not lexed either
+	ForeignToken
+	ReferencesToken
+	// CheckToken and ConstraintToken are not lexed either
+	CheckToken
+	ConstraintToken
+	HavingToken
+	DistinctToken
+	NullsToken
+	UnionToken
+	AllToken
+	AnyToken
+	IntersectToken
+	ExceptToken
+	CaseToken
+	WhenToken
+	ThenToken
+	ElseToken
+	EndToken
+	CurrentTimestampToken
+	LikeToken
+	ILikeToken
+	EscapeToken
+	BetweenToken
+	AddToken
+	ColumnToken
+	RenameToken
+	ToToken
+	IndexToken
+	CascadeToken
+	ViewToken
+	ConflictToken
+	DoToken
+	NothingToken
+	BeginToken
+	CommitToken
+	RollbackToken
+	TransactionToken
+	SavepointToken
+	ReleaseToken
+	ShowToken
 
 	// Type Token
 
@@ -88,12 +164,22 @@ const (
 	StringToken
 	NumberToken
 	DateToken
+
+	// QuotedStringToken is a string literal in an expression, as opposed
+	// to an attribute name
+	QuotedStringToken
+	// MultiplyToken is a star used as multiplication operator
+	MultiplyToken
+	// FunctionToken is a call to a builtin function, named after its lexeme
+	FunctionToken
 )
 
 // Token struct holds token id and it's lexeme
 type Token struct {
 	Token  int
 	Lexeme string
+	// Pos is the offset of token in instruction, in bytes
+	Pos int
 }
 
 type lexer struct {
@@ -120,6 +206,8 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 	matchers = append(matchers, l.MatchCommaToken)
 	matchers = append(matchers, l.MatchBracketOpeningToken)
 	matchers = append(matchers, l.MatchBracketClosingToken)
+	matchers = append(matchers, l.MatchSquareBracketOpeningToken)
+	matchers = append(matchers, l.MatchSquareBracketClosingToken)
 	matchers = append(matchers, l.MatchStarToken)
 	matchers = append(matchers, l.MatchSimpleQuoteToken)
 	matchers = append(matchers, l.MatchEqualityToken)
@@ -130,6 +218,11 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 	matchers = append(matchers, l.MatchLeftDipleToken)
 	matchers = append(matchers, l.MatchRightDipleToken)
 	matchers = append(matchers, l.MatchBacktickToken)
+	matchers = append(matchers, l.MatchPlusToken)
+	matchers = append(matchers, l.MatchArrowToken)
+	matchers = append(matchers, l.MatchMinusToken)
+	matchers = append(matchers, l.MatchSlashToken)
+	matchers = append(matchers, l.MatchDoubleColonToken)
 	// First order Matcher
 	matchers = append(matchers, l.MatchCreateToken)
 	matchers = append(matchers, l.MatchSelectToken)
@@ -139,6 +232,7 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 	matchers = append(matchers, l.MatchTruncateToken)
 	matchers = append(matchers, l.MatchDropToken)
 	matchers = append(matchers, l.MatchGrantToken)
+	matchers = append(matchers, l.MatchAlterToken)
 	// Second order Matcher
 	matchers = append(matchers, l.MatchTableToken)
 	matchers = append(matchers, l.MatchFromToken)
@@ -171,13 +265,66 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 	matchers = append(matchers, l.MatchDefaultToken)
 	matchers = append(matchers, l.MatchLocalTimestampToken)
 	matchers = append(matchers, l.MatchFalseToken)
+	matchers = append(matchers, l.MatchTrueToken)
 	matchers = append(matchers, l.MatchUniqueToken)
 	matchers = append(matchers, l.MatchNowToken)
 	matchers = append(matchers, l.MatchOffsetToken)
+	matchers = append(matchers, l.MatchInnerToken)
+	matchers = append(matchers, l.MatchAsToken)
+	matchers = append(matchers, l.MatchLeftToken)
+	matchers = append(matchers, l.MatchOuterToken)
+	matchers = append(matchers, l.MatchRightToken)
+	matchers = append(matchers, l.MatchFullToken)
+	matchers = append(matchers, l.MatchGroupToken)
+	matchers = append(matchers, l.MatchSumToken)
+	matchers = append(matchers, l.MatchAvgToken)
+	matchers = append(matchers, l.MatchMinToken)
+	matchers = append(matchers, l.MatchMaxToken)
+	matchers = append(matchers, l.MatchGroupConcatToken)
+	matchers = append(matchers, l.MatchStringAggToken)
+	matchers = append(matchers, l.MatchOverToken)
+	matchers = append(matchers, l.MatchPartitionToken)
+	matchers = append(matchers, l.MatchHavingToken)
+	matchers = append(matchers, l.MatchDistinctToken)
+	matchers = append(matchers, l.MatchNullsToken)
+	matchers = append(matchers, l.MatchUnionToken)
+	matchers = append(matchers, l.MatchAllToken)
+	matchers = append(matchers, l.MatchAnyToken)
+	matchers = append(matchers, l.MatchIntersectToken)
+	matchers = append(matchers, l.MatchExceptToken)
+	matchers = append(matchers, l.MatchCaseToken)
+	matchers = append(matchers, l.MatchWhenToken)
+	matchers = append(matchers, l.MatchThenToken)
+	matchers = append(matchers, l.MatchElseToken)
+	matchers = append(matchers, l.MatchEndToken)
+	matchers = append(matchers, l.MatchCurrentTimestampToken)
+	matchers = append(matchers, l.MatchLikeToken)
+	matchers = append(matchers, l.MatchILikeToken)
+	matchers = append(matchers, l.MatchEscapeToken)
+	matchers = append(matchers, l.MatchBetweenToken)
+	matchers = append(matchers, l.MatchAddToken)
+	matchers = append(matchers, l.MatchColumnToken)
+	matchers = append(matchers, l.MatchRenameToken)
+	matchers = append(matchers, l.MatchToToken)
+	matchers = append(matchers, l.MatchIndexToken)
+	matchers = append(matchers, l.MatchCascadeToken)
+	matchers = append(matchers, l.MatchViewToken)
+	matchers = append(matchers, l.MatchConflictToken)
+	matchers = append(matchers, l.MatchDoToken)
+	matchers = append(matchers, l.MatchNothingToken)
+	matchers = append(matchers, l.MatchBeginToken)
+	matchers = append(matchers, l.MatchCommitToken)
+	matchers = append(matchers, l.MatchRollbackToken)
+	matchers = append(matchers, l.MatchTransactionToken)
+	matchers = append(matchers, l.MatchSavepointToken)
+	matchers = append(matchers, l.MatchReleaseToken)
+	matchers = append(matchers, l.MatchShowToken)
+	matchers = append(matchers, l.MatchExplainToken)
 	// Type Matcher
 	matchers = append(matchers, l.MatchPrimaryToken)
 	matchers = append(matchers, l.MatchKeyToken)
 	matchers = append(matchers, l.MatchEscapedStringToken)
+	matchers = append(matchers, l.MatchHexStringToken)
 	matchers = append(matchers, l.MatchDateToken)
 	matchers = append(matchers, l.MatchNumberToken)
 	matchers = append(matchers, l.MatchStringToken)
@@ -187,6 +334,7 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 		// fmt.Printf("Tokens : %v\n\n", l.tokens)
 
 		r = false
+		start, n := l.pos, len(l.tokens)
 		for _, m := range matchers {
 			if r = m(); r == true {
 				securityPos = l.pos
@@ -195,12 +343,15 @@ func (l *lexer) lex(instruction []byte) ([]Token, error) {
 		}
 
 		if r {
+			for i := n; i < len(l.tokens); i++ {
+				l.tokens[i].Pos = start
+			}
 			continue
 		}
 
 		if l.pos == securityPos {
 			log.Warning("Cannot lex <%s>, stuck at pos %d -> [%c]", l.instruction, l.pos, l.instruction[l.pos])
-			return nil, fmt.Errorf("Cannot lex instruction. Syntax error near %s", instruction[l.pos:])
+			return nil, &SyntaxError{Message: "Cannot lex instruction. Syntax error near " + string(instruction[l.pos:]), pos: l.pos}
 		}
 		securityPos = l.pos
 	}
@@ -243,6 +394,10 @@ func (l *lexer) MatchFalseToken() bool {
 	return l.Match([]byte("false"), FalseToken)
 }
 
+func (l *lexer) MatchTrueToken() bool {
+	return l.Match([]byte("true"), TrueToken)
+}
+
 func (l *lexer) MatchAscToken() bool {
 	return l.Match([]byte("desc"), DescToken)
 }
@@ -399,6 +554,219 @@ func (l *lexer) MatchOffsetToken() bool {
 	return l.Match([]byte("offset"), OffsetToken)
 }
 
+func (l *lexer) MatchInnerToken() bool {
+	return l.Match([]byte("inner"), InnerToken)
+}
+
+func (l *lexer) MatchAsToken() bool {
+	return l.Match([]byte("as"), AsToken)
+}
+
+func (l *lexer) MatchLeftToken() bool {
+	return l.Match([]byte("left"), LeftToken)
+}
+
+func (l *lexer) MatchOuterToken() bool {
+	return l.Match([]byte("outer"), OuterToken)
+}
+
+func (l *lexer) MatchRightToken() bool {
+	return l.Match([]byte("right"), RightToken)
+}
+
+func (l *lexer) MatchFullToken() bool {
+	return l.Match([]byte("full"), FullToken)
+}
+
+// MatchGroupToken only matches GROUP BY, so group can still be a table name
+func (l *lexer) MatchGroupToken() bool {
+	return l.MatchFollowedBy([]byte("group"), GroupToken, []byte("by"))
+}
+
+func (l *lexer) MatchSumToken() bool {
+	return l.MatchFollowedBy([]byte("sum"), SumToken, []byte("("))
+}
+
+func (l *lexer) MatchAvgToken() bool {
+	return l.MatchFollowedBy([]byte("avg"), AvgToken, []byte("("))
+}
+
+func (l *lexer) MatchMinToken() bool {
+	return l.MatchFollowedBy([]byte("min"), MinToken, []byte("("))
+}
+
+func (l *lexer) MatchMaxToken() bool {
+	return l.MatchFollowedBy([]byte("max"), MaxToken, []byte("("))
+}
+
+func (l *lexer) MatchGroupConcatToken() bool {
+	return l.MatchFollowedBy([]byte("group_concat"), GroupConcatToken, []byte("("))
+}
+
+func (l *lexer) MatchStringAggToken() bool {
+	return l.MatchFollowedBy([]byte("string_agg"), StringAggToken, []byte("("))
+}
+
+// MatchOverToken only matches OVER followed by a window definition, so over can still be an attribute name
+func (l *lexer) MatchOverToken() bool {
+	return l.MatchFollowedBy([]byte("over"), OverToken, []byte("("))
+}
+
+// MatchPartitionToken only matches PARTITION BY, so partition can still be an attribute name
+func (l *lexer) MatchPartitionToken() bool {
+	return l.MatchFollowedBy([]byte("partition"), PartitionToken, []byte("by"))
+}
+
+func (l *lexer) MatchHavingToken() bool {
+	return l.Match([]byte("having"), HavingToken)
+}
+
+func (l *lexer) MatchDistinctToken() bool {
+	return l.Match([]byte("distinct"), DistinctToken)
+}
+
+func (l *lexer) MatchNullsToken() bool {
+	return l.Match([]byte("nulls"), NullsToken)
+}
+
+func (l *lexer) MatchUnionToken() bool {
+	return l.Match([]byte("union"), UnionToken)
+}
+
+func (l *lexer) MatchAllToken() bool {
+	return l.Match([]byte("all"), AllToken)
+}
+
+// MatchAnyToken matches ANY, or its synonym SOME, only when followed by a subquery,
+// so any and some can still be attribute names
+func (l *lexer) MatchAnyToken() bool {
+	return l.MatchFollowedBy([]byte("any"), AnyToken, []byte("(")) || l.MatchFollowedBy([]byte("some"), AnyToken, []byte("("))
+}
+
+func (l *lexer) MatchIntersectToken() bool {
+	return l.Match([]byte("intersect"), IntersectToken)
+}
+
+func (l *lexer) MatchExceptToken() bool {
+	return l.Match([]byte("except"), ExceptToken)
+}
+
+func (l *lexer) MatchCaseToken() bool {
+	return l.Match([]byte("case"), CaseToken)
+}
+
+func (l *lexer) MatchWhenToken() bool {
+	return l.Match([]byte("when"), WhenToken)
+}
+
+func (l *lexer) MatchThenToken() bool {
+	return l.Match([]byte("then"), ThenToken)
+}
+
+func (l *lexer) MatchElseToken() bool {
+	return l.Match([]byte("else"), ElseToken)
+}
+
+func (l *lexer) MatchEndToken() bool {
+	return l.Match([]byte("end"), EndToken)
+}
+
+func (l *lexer) MatchCurrentTimestampToken() bool {
+	return l.Match([]byte("current_timestamp"), CurrentTimestampToken)
+}
+
+func (l *lexer) MatchLikeToken() bool {
+	return l.Match([]byte("like"), LikeToken)
+}
+
+func (l *lexer) MatchILikeToken() bool {
+	return l.Match([]byte("ilike"), ILikeToken)
+}
+
+func (l *lexer) MatchEscapeToken() bool {
+	return l.MatchFollowedBy([]byte("escape"), EscapeToken, []byte("'"))
+}
+
+func (l *lexer) MatchBetweenToken() bool {
+	return l.Match([]byte("between"), BetweenToken)
+}
+
+func (l *lexer) MatchAlterToken() bool {
+	return l.Match([]byte("alter"), AlterToken)
+}
+
+func (l *lexer) MatchAddToken() bool {
+	return l.Match([]byte("add"), AddToken)
+}
+
+func (l *lexer) MatchColumnToken() bool {
+	return l.Match([]byte("column"), ColumnToken)
+}
+
+func (l *lexer) MatchRenameToken() bool {
+	return l.Match([]byte("rename"), RenameToken)
+}
+
+func (l *lexer) MatchToToken() bool {
+	return l.Match([]byte("to"), ToToken)
+}
+
+func (l *lexer) MatchIndexToken() bool {
+	return l.Match([]byte("index"), IndexToken)
+}
+
+func (l *lexer) MatchCascadeToken() bool {
+	return l.Match([]byte("cascade"), CascadeToken)
+}
+
+func (l *lexer) MatchViewToken() bool {
+	return l.Match([]byte("view"), ViewToken)
+}
+
+func (l *lexer) MatchConflictToken() bool {
+	return l.Match([]byte("conflict"), ConflictToken)
+}
+
+func (l *lexer) MatchDoToken() bool {
+	return l.Match([]byte("do"), DoToken)
+}
+
+func (l *lexer) MatchNothingToken() bool {
+	return l.Match([]byte("nothing"), NothingToken)
+}
+
+func (l *lexer) MatchBeginToken() bool {
+	return l.Match([]byte("begin"), BeginToken)
+}
+
+func (l *lexer) MatchCommitToken() bool {
+	return l.Match([]byte("commit"), CommitToken)
+}
+
+func (l *lexer) MatchRollbackToken() bool {
+	return l.Match([]byte("rollback"), RollbackToken)
+}
+
+func (l *lexer) MatchTransactionToken() bool {
+	return l.Match([]byte("transaction"), TransactionToken)
+}
+
+func (l *lexer) MatchSavepointToken() bool {
+	return l.Match([]byte("savepoint"), SavepointToken)
+}
+
+func (l *lexer) MatchReleaseToken() bool {
+	return l.Match([]byte("release"), ReleaseToken)
+}
+
+func (l *lexer) MatchShowToken() bool {
+	return l.Match([]byte("show"), ShowToken)
+}
+
+func (l *lexer) MatchExplainToken() bool {
+	return l.Match([]byte("explain"), ExplainToken)
+}
+
 func (l *lexer) MatchStringToken() bool {
 
 	i := l.pos
@@ -430,6 +798,28 @@ func (l *lexer) MatchNumberToken() bool {
 		i++
 	}
 
+	// Decimal part
+	if i != l.pos && i+1 < l.instructionLen && l.instruction[i] == '.' && unicode.IsDigit(rune(l.instruction[i+1])) {
+		i++
+		for i < l.instructionLen && unicode.IsDigit(rune(l.instruction[i])) {
+			i++
+		}
+	}
+
+	// Exponent, as in 1.5e-07
+	if i != l.pos && i+1 < l.instructionLen && (l.instruction[i] == 'e' || l.instruction[i] == 'E') {
+		j := i + 1
+		if l.instruction[j] == '+' || l.instruction[j] == '-' {
+			j++
+		}
+		if j < l.instructionLen && unicode.IsDigit(rune(l.instruction[j])) {
+			i = j
+			for i < l.instructionLen && unicode.IsDigit(rune(l.instruction[i])) {
+				i++
+			}
+		}
+	}
+
 	if i != l.pos {
 		t := Token{
 			Token:  NumberToken,
@@ -459,6 +849,14 @@ func (l *lexer) MatchBracketClosingToken() bool {
 	return l.MatchSingle(')', BracketClosingToken)
 }
 
+func (l *lexer) MatchSquareBracketOpeningToken() bool {
+	return l.MatchSingle('[', SquareBracketOpeningToken)
+}
+
+func (l *lexer) MatchSquareBracketClosingToken() bool {
+	return l.MatchSingle(']', SquareBracketClosingToken)
+}
+
 func (l *lexer) MatchCommaToken() bool {
 	return l.MatchSingle(',', CommaToken)
 }
@@ -491,6 +889,45 @@ func (l *lexer) MatchBacktickToken() bool {
 	return l.MatchSingle('`', BacktickToken)
 }
 
+func (l *lexer) MatchPlusToken() bool {
+	return l.MatchSingle('+', PlusToken)
+}
+
+func (l *lexer) MatchMinusToken() bool {
+	return l.MatchSingle('-', MinusToken)
+}
+
+func (l *lexer) MatchSlashToken() bool {
+	return l.MatchSingle('/', SlashToken)
+}
+
+func (l *lexer) MatchDoubleColonToken() bool {
+	if l.pos+1 >= l.instructionLen || l.instruction[l.pos] != ':' || l.instruction[l.pos+1] != ':' {
+		return false
+	}
+
+	l.tokens = append(l.tokens, Token{Token: DoubleColonToken, Lexeme: "::"})
+	l.pos += 2
+	return true
+}
+
+// MatchArrowToken matches JSON operators -> and ->>
+func (l *lexer) MatchArrowToken() bool {
+	if l.pos+1 >= l.instructionLen || l.instruction[l.pos] != '-' || l.instruction[l.pos+1] != '>' {
+		return false
+	}
+
+	if l.pos+2 < l.instructionLen && l.instruction[l.pos+2] == '>' {
+		l.tokens = append(l.tokens, Token{Token: DoubleArrowToken, Lexeme: "->>"})
+		l.pos += 3
+		return true
+	}
+
+	l.tokens = append(l.tokens, Token{Token: ArrowToken, Lexeme: "->"})
+	l.pos += 2
+	return true
+}
+
 // 2015-09-10 14:03:09.444695269 +0200 CEST);
 func (l *lexer) MatchDateToken() bool {
 
@@ -585,6 +1022,37 @@ func (l *lexer) MatchEscapedStringToken() bool {
 	return true
 }
 
+// MatchHexStringToken matches a binary string given in hexadecimal, as X'68690a',
+// and returns its bytes as they are
+func (l *lexer) MatchHexStringToken() bool {
+	i := l.pos
+	if i+1 >= l.instructionLen || (l.instruction[i] != 'x' && l.instruction[i] != 'X') || l.instruction[i+1] != '\'' {
+		return false
+	}
+	i += 2
+
+	for i < l.instructionLen && l.instruction[i] != '\'' {
+		i++
+	}
+	if i == l.instructionLen {
+		return false
+	}
+
+	b, err := hex.DecodeString(string(l.instruction[l.pos+2 : i]))
+	if err != nil {
+		return false
+	}
+
+	t := Token{
+		Token:  StringToken,
+		Lexeme: string(b),
+	}
+	l.tokens = append(l.tokens, t)
+	l.pos = i + 1
+
+	return true
+}
+
 func (l *lexer) MatchDoubleQuotedStringToken() bool {
 	i := l.pos
 	for i < l.instructionLen && l.instruction[i] != '"' {
@@ -628,15 +1096,27 @@ func (l *lexer) MatchSimpleQuoteToken() bool {
 	return false
 }
 
+// MatchSingleQuotedStringToken matches a string up to its closing quote,
+// a doubled quote being a quote in string
 func (l *lexer) MatchSingleQuotedStringToken() bool {
+	var str []byte
 	i := l.pos
-	for i < l.instructionLen && l.instruction[i] != '\'' {
+	for i < l.instructionLen {
+		if l.instruction[i] == '\'' {
+			if i+1 < l.instructionLen && l.instruction[i+1] == '\'' {
+				str = append(str, '\'')
+				i += 2
+				continue
+			}
+			break
+		}
+		str = append(str, l.instruction[i])
 		i++
 	}
 
 	t := Token{
 		Token:  StringToken,
-		Lexeme: string(l.instruction[l.pos:i]),
+		Lexeme: string(str),
 	}
 	l.tokens = append(l.tokens, t)
 	l.pos = i
@@ -666,7 +1146,7 @@ func (l *lexer) MatchSingle(char byte, token int) bool {
 
 func (l *lexer) Match(str []byte, token int) bool {
 
-	if l.pos+len(str)-1 > l.instructionLen {
+	if l.pos+len(str) > l.instructionLen {
 		return false
 	}
 
@@ -678,9 +1158,10 @@ func (l *lexer) Match(str []byte, token int) bool {
 	}
 
 	// if next character is still a string, it means it doesn't match
-	// ie: COUNT shoulnd match COUNTRY
+	// ie: COUNT shoulnd match COUNTRY, AS shouldn't match AS1
 	if l.instructionLen > l.pos+len(str) {
 		if unicode.IsLetter(rune(l.instruction[l.pos+len(str)])) ||
+			unicode.IsDigit(rune(l.instruction[l.pos+len(str)])) ||
 			l.instruction[l.pos+len(str)] == '_' {
 			return false
 		}
@@ -695,3 +1176,25 @@ func (l *lexer) Match(str []byte, token int) bool {
 	l.pos += len(t.Lexeme)
 	return true
 }
+
+// MatchFollowedBy matches str only if next non space characters are next,
+// so keywords like aggregate function names can still be used as identifiers
+func (l *lexer) MatchFollowedBy(str []byte, token int, next []byte) bool {
+	if !l.Match(str, token) {
+		return false
+	}
+
+	i := l.pos
+	for i < l.instructionLen && unicode.IsSpace(rune(l.instruction[i])) {
+		i++
+	}
+
+	if i+len(next) <= l.instructionLen && bytes.EqualFold(l.instruction[i:i+len(next)], next) {
+		return true
+	}
+
+	// Not followed by expected string, revert
+	l.pos -= len(str)
+	l.tokens = l.tokens[:len(l.tokens)-1]
+	return false
+}
@@ -27,6 +27,18 @@ func TestParseDate(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Cannot parse %s: %s", data, err)
 	}
+
+	// Zones without abbreviation are named after their offset
+	expected := time.Date(2015, 9, 10, 14, 3, 9, 0, time.FixedZone("", 2*3600))
+	for _, data := range []string{expected.Format(DateLongFormat), data[:19] + " +0200 CEST", "2015-09-10T14:03:09+02:00"} {
+		d, err := ParseDate(data)
+		if err != nil {
+			t.Fatalf("Cannot parse %s: %s", data, err)
+		}
+		if !d.Equal(expected) {
+			t.Fatalf("Expected %s parsing %s, got %s", expected, data, d)
+		}
+	}
 }
 
 func TestLexerWithGTOEandLTOEOperator(t *testing.T) {
@@ -42,3 +54,70 @@ func TestLexerWithGTOEandLTOEOperator(t *testing.T) {
 		t.Fatalf("Lexing failed, expected 21 tokens, got %d", len(decls))
 	}
 }
+
+func TestLexerEscapedQuote(t *testing.T) {
+	queries := map[string]string{
+		`SELECT * FROM foo WHERE name = 'O''Brien'`: `O'Brien`,
+		`SELECT * FROM foo WHERE name = ''''`:       `'`,
+		`SELECT * FROM foo WHERE name = ''`:         ``,
+	}
+
+	for query, expected := range queries {
+		lexer := lexer{}
+		tokens, err := lexer.lex([]byte(query))
+		if err != nil {
+			t.Fatalf("Cannot lex <%s> string", query)
+		}
+
+		last := tokens[len(tokens)-2]
+		if last.Token != StringToken || last.Lexeme != expected {
+			t.Fatalf("Expected string <%s> in <%s>, got <%s>", expected, query, last.Lexeme)
+		}
+		if tokens[len(tokens)-1].Token != SimpleQuoteToken {
+			t.Fatalf("Expected closing quote in <%s>", query)
+		}
+	}
+}
+
+func TestLexerScientificNotation(t *testing.T) {
+	queries := map[string]string{
+		`SELECT * FROM foo WHERE price = 1e+21`:      `1e+21`,
+		`SELECT * FROM foo WHERE price = 1.5E-07`:    `1.5E-07`,
+		`SELECT * FROM foo WHERE price = 2e3`:        `2e3`,
+		`SELECT * FROM foo WHERE price = 0.30000004`: `0.30000004`,
+	}
+
+	for query, expected := range queries {
+		lexer := lexer{}
+		tokens, err := lexer.lex([]byte(query))
+		if err != nil {
+			t.Fatalf("Cannot lex <%s> string", query)
+		}
+
+		last := tokens[len(tokens)-1]
+		if last.Token != NumberToken || last.Lexeme != expected {
+			t.Fatalf("Expected number <%s> in <%s>, got <%s>", expected, query, last.Lexeme)
+		}
+	}
+}
+
+func TestLexerHexString(t *testing.T) {
+	queries := map[string]string{
+		`SELECT * FROM foo WHERE data = X'68690a'`: "hi\n",
+		`SELECT * FROM foo WHERE data = x'00ff27'`: "\x00\xff'",
+		`SELECT * FROM foo WHERE data = X''`:       "",
+	}
+
+	for query, expected := range queries {
+		lexer := lexer{}
+		tokens, err := lexer.lex([]byte(query))
+		if err != nil {
+			t.Fatalf("Cannot lex <%s> string", query)
+		}
+
+		last := tokens[len(tokens)-1]
+		if last.Token != StringToken || last.Lexeme != expected {
+			t.Fatalf("Expected bytes %q in <%s>, got %q", expected, query, last.Lexeme)
+		}
+	}
+}
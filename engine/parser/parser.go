@@ -5,6 +5,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/proullon/ramsql/engine/log"
 )
@@ -15,6 +16,14 @@ type parser struct {
 	index    int
 	tokenLen int
 	tokens   []Token
+	// ctes are the common table expressions in scope, by name
+	ctes map[string]*Decl
+	// table is the table whose definition is parsed, qualifying names of its CHECK
+	// conditions while checking is set
+	table    string
+	checking bool
+	// source is the text of instructions parsed, if known
+	source []byte
 }
 
 // Decl structure is the node to statement declaration tree
@@ -64,6 +73,18 @@ func (d *Decl) Add(subDecl *Decl) {
 
 func (p *parser) parse(tokens []Token) ([]Instruction, error) {
 	tokens = stripSpaces(tokens)
+	// Always end with a semicolon, so the last token of a statement
+	// can be consumed like any other
+	if len(tokens) == 0 || tokens[len(tokens)-1].Token != SemicolonToken {
+		end := 0
+		if len(tokens) > 0 {
+			end = tokens[len(tokens)-1].Pos + len(tokens[len(tokens)-1].Lexeme)
+		}
+		if p.source != nil {
+			end = len(p.source)
+		}
+		tokens = append(tokens, Token{Token: SemicolonToken, Lexeme: ";", Pos: end})
+	}
 	p.tokens = tokens
 	log.Debug("parser.parse: %v\n", p.tokens)
 
@@ -87,7 +108,7 @@ func (p *parser) parse(tokens []Token) ([]Instruction, error) {
 		// Now,
 		// Create a logical tree of all tokens
 		// We start with first order query
-		// CREATE, SELECT, INSERT, UPDATE, DELETE, TRUNCATE, DROP, EXPLAIN
+		// CREATE, SELECT, INSERT, UPDATE, DELETE, TRUNCATE, DROP, ALTER, EXPLAIN
 		switch tokens[p.index].Token {
 		case CreateToken:
 			i, err := p.parseCreate(tokens)
@@ -96,7 +117,7 @@ func (p *parser) parse(tokens []Token) ([]Instruction, error) {
 			}
 			p.i = append(p.i, *i)
 			break
-		case SelectToken:
+		case SelectToken, WithToken:
 			i, err := p.parseSelect(tokens)
 			if err != nil {
 				return nil, err
@@ -139,7 +160,40 @@ func (p *parser) parse(tokens []Token) ([]Instruction, error) {
 			}
 			p.i = append(p.i, *i)
 			break
+		case AlterToken:
+			i, err := p.parseAlter()
+			if err != nil {
+				return nil, err
+			}
+			p.i = append(p.i, *i)
+			break
+		case BeginToken, CommitToken, RollbackToken, SavepointToken, ReleaseToken:
+			i, err := p.parseTransaction()
+			if err != nil {
+				return nil, err
+			}
+			p.i = append(p.i, *i)
+			break
+		case SetToken:
+			i, err := p.parseSet()
+			if err != nil {
+				return nil, err
+			}
+			p.i = append(p.i, *i)
+			break
+		case ShowToken:
+			i, err := p.parseShow()
+			if err != nil {
+				return nil, err
+			}
+			p.i = append(p.i, *i)
+			break
 		case ExplainToken:
+			i, err := p.parseExplain(tokens)
+			if err != nil {
+				return nil, err
+			}
+			p.i = append(p.i, *i)
 			break
 		case GrantToken:
 			i := &Instruction{}
@@ -147,7 +201,7 @@ func (p *parser) parse(tokens []Token) ([]Instruction, error) {
 			p.i = append(p.i, *i)
 			return p.i, nil
 		default:
-			return nil, fmt.Errorf("Parsing error near <%s>", tokens[p.index].Lexeme)
+			return nil, p.syntaxError()
 		}
 	}
 
@@ -164,12 +218,15 @@ func (p *parser) parseUpdate() (*Instruction, error) {
 	}
 	i.Decls = append(i.Decls, updateDecl)
 
-	// should be table name
+	// should be table name, maybe aliased
 	nameDecl, err := p.parseQuotedToken()
 	if err != nil {
 		return nil, err
 	}
 	updateDecl.Add(nameDecl)
+	if err := p.parseAlias(nameDecl); err != nil {
+		return nil, err
+	}
 
 	// should be SET
 	setDecl, err := p.consumeToken(SetToken)
@@ -180,7 +237,7 @@ func (p *parser) parseUpdate() (*Instruction, error) {
 
 	// should be a list of equality
 	gotClause := false
-	for p.tokens[p.index].Token != WhereToken {
+	for !p.is(WhereToken, FromToken, ReturningToken) {
 
 		if !p.hasNext() && gotClause {
 			break
@@ -197,11 +254,41 @@ func (p *parser) parseUpdate() (*Instruction, error) {
 		gotClause = true
 	}
 
+	// may be FROM, a list of tables joined with updated one
+	if p.is(FromToken) {
+		fromDecl, err := p.consumeToken(FromToken)
+		if err != nil {
+			return nil, err
+		}
+		updateDecl.Add(fromDecl)
+
+		for {
+			tableDecl, err := p.parseTableReference()
+			if err != nil {
+				return nil, err
+			}
+			fromDecl.Add(tableDecl)
+
+			if !p.is(CommaToken) {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	err = p.parseWhere(updateDecl)
 	if err != nil {
 		return nil, err
 	}
 
+	if p.is(ReturningToken) {
+		if err := p.parseReturning(updateDecl); err != nil {
+			return nil, err
+		}
+	}
+
 	return i, nil
 }
 
@@ -229,75 +316,110 @@ func (p *parser) parseInsert() (*Instruction, error) {
 	}
 	intoDecl.Add(tableDecl)
 
-	_, err = p.consumeToken(BracketOpeningToken)
-	if err != nil {
-		return nil, err
-	}
-
-	// concerned attribute
-	for {
-		decl, err := p.parseListElement()
-		if err != nil {
+	// concerned attributes, all of them if not specified
+	if p.is(BracketOpeningToken) {
+		if err := p.next(); err != nil {
 			return nil, err
 		}
-		tableDecl.Add(decl)
 
-		if p.is(BracketClosingToken) {
-			if _, err = p.consumeToken(BracketClosingToken); err != nil {
+		for {
+			decl, err := p.parseListElement()
+			if err != nil {
 				return nil, err
 			}
+			tableDecl.Add(decl)
 
-			break
+			if p.is(BracketClosingToken) {
+				if _, err = p.consumeToken(BracketClosingToken); err != nil {
+					return nil, err
+				}
+
+				break
+			}
+
+			_, err = p.consumeToken(CommaToken)
+			if err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		_, err = p.consumeToken(CommaToken)
+	// should be VALUES, or a SELECT
+	if p.is(SelectToken) {
+		selectInstruction, err := p.parseSelect(p.tokens)
 		if err != nil {
 			return nil, err
 		}
+		insertDecl.Add(selectInstruction.Decls[0])
+	} else if err := p.parseValues(insertDecl); err != nil {
+		return nil, err
 	}
 
-	// should be VALUES
-	valuesDecl, err := p.consumeToken(ValuesToken)
-	if err != nil {
-		return nil, err
+	// we may have `on conflict` here
+	if p.is(OnToken) {
+		if err := p.parseConflict(insertDecl); err != nil {
+			return nil, err
+		}
 	}
-	insertDecl.Add(valuesDecl)
 
-	_, err = p.consumeToken(BracketOpeningToken)
+	// we may have `returning "something"` here
+	if p.is(ReturningToken) {
+		if err := p.parseReturning(insertDecl); err != nil {
+			return nil, err
+		}
+	}
+
+	return i, nil
+}
+
+// parseValues parses VALUES clause of INSERT, a list of rows
+// VALUES (1, 'x'), (2, 'y')
+func (p *parser) parseValues(insertDecl *Decl) error {
+	valuesDecl, err := p.consumeToken(ValuesToken)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	insertDecl.Add(valuesDecl)
 
-	// should be a list of values for specified attributes
+	// should be a list of rows, each a list of values for specified attributes
 	for {
-		decl, err := p.parseListElement()
+		rowDecl, err := p.consumeToken(BracketOpeningToken)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		valuesDecl.Add(decl)
+		valuesDecl.Add(rowDecl)
+
+		for {
+			// Values are expressions of constants, or DEFAULT
+			var decl *Decl
+			if p.is(DefaultToken, DateToken) {
+				decl, err = p.consumeToken(DefaultToken, DateToken)
+			} else {
+				decl, err = p.parseExpression()
+			}
+			if err != nil {
+				return err
+			}
+			rowDecl.Add(decl)
 
-		if p.is(BracketClosingToken) {
-			p.consumeToken(BracketClosingToken)
-			break
-		}
+			if p.is(BracketClosingToken) {
+				p.consumeToken(BracketClosingToken)
+				break
+			}
 
-		_, err = p.consumeToken(CommaToken)
-		if err != nil {
-			return nil, err
+			_, err = p.consumeToken(CommaToken)
+			if err != nil {
+				return err
+			}
 		}
-	}
 
-	// we may have `returning "something"` here
-	if retDecl, err := p.consumeToken(ReturningToken); err == nil {
-		insertDecl.Add(retDecl)
-		attrDecl, err := p.parseAttribute()
-		if err != nil {
-			return nil, err
+		if !p.is(CommaToken) {
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
 		}
-		retDecl.Add(attrDecl)
 	}
-
-	return i, nil
 }
 
 func (p *parser) parseType() (*Decl, error) {
@@ -306,6 +428,14 @@ func (p *parser) parseType() (*Decl, error) {
 		return nil, err
 	}
 
+	// Enumerated type, with its allowed values
+	if strings.EqualFold(typeDecl.Lexeme, "enum") {
+		if err := p.parseEnumValues(typeDecl); err != nil {
+			return nil, err
+		}
+		return typeDecl, nil
+	}
+
 	// Maybe a complex type
 	if p.is(BracketOpeningToken) {
 		_, err = p.consumeToken(BracketOpeningToken)
@@ -317,15 +447,82 @@ func (p *parser) parseType() (*Decl, error) {
 			return nil, err
 		}
 		typeDecl.Add(sizeDecl)
+		// Precision may be followed by scale, like NUMERIC(10, 2)
+		if p.is(CommaToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			scaleDecl, err := p.consumeToken(NumberToken)
+			if err != nil {
+				return nil, err
+			}
+			typeDecl.Add(scaleDecl)
+		}
 		_, err = p.consumeToken(BracketClosingToken)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// Maybe an array of it, like TEXT[]
+	if p.is(SquareBracketOpeningToken) {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeToken(SquareBracketClosingToken); err != nil {
+			return nil, err
+		}
+		typeDecl.Lexeme += "[]"
+	}
+
 	return typeDecl, nil
 }
 
+/*
+|-> enum
+	|-> open
+	|-> closed
+*/
+// parseEnumValues parses the list of values allowed by an ENUM type
+// ENUM('open', 'closed', 'archived')
+func (p *parser) parseEnumValues(typeDecl *Decl) error {
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return err
+	}
+
+	for {
+		if !p.is(SimpleQuoteToken) {
+			return p.syntaxError()
+		}
+		valueDecl, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		valueDecl.Token = QuotedStringToken
+		typeDecl.Add(valueDecl)
+
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	_, err := p.consumeToken(BracketClosingToken)
+	return err
+}
+
+/*
+|-> order
+	|-> created_at
+		|-> desc
+	|-> name
+		|-> user
+		|-> asc
+		|-> nulls
+			|-> first
+*/
 func (p *parser) parseOrderBy(selectDecl *Decl) error {
 	orderDecl, err := p.consumeToken(OrderToken)
 	if err != nil {
@@ -338,36 +535,46 @@ func (p *parser) parseOrderBy(selectDecl *Decl) error {
 		return err
 	}
 
-	// parse attribute now
-	attrDecl, err := p.parseAttribute()
-	if err != nil {
-		return err
-	}
-	orderDecl.Add(attrDecl)
-
 	// Parse multiple ordering
-	for p.cur().Token == CommaToken {
-		_, err := p.consumeToken(CommaToken)
-		if err != nil {
-			return nil
-		}
-
-		// parse attribute now
-		attrDecl, err := p.parseAttribute()
+	for {
+		// parse attribute, or expression, now
+		attrDecl, err := p.parseExpression()
 		if err != nil {
 			return err
 		}
 		orderDecl.Add(attrDecl)
-	}
 
-	// ASC ? DESC ? nothing ?
-	t := p.cur().Token
-	if t == AscToken || t == DescToken {
-		decl, err := p.consumeToken(AscToken, DescToken)
-		if err != nil {
+		// ASC ? DESC ? nothing ?
+		if p.is(AscToken, DescToken) {
+			decl, err := p.consumeToken(AscToken, DescToken)
+			if err != nil {
+				return err
+			}
+			attrDecl.Add(decl)
+		}
+
+		// NULLS FIRST ? NULLS LAST ?
+		if p.is(NullsToken) {
+			nullsDecl, err := p.consumeToken(NullsToken)
+			if err != nil {
+				return err
+			}
+			if !p.is(StringToken) || (strings.ToLower(p.cur().Lexeme) != "first" && strings.ToLower(p.cur().Lexeme) != "last") {
+				return p.syntaxError()
+			}
+			nullsDecl.Add(&Decl{Token: StringToken, Lexeme: strings.ToLower(p.cur().Lexeme)})
+			attrDecl.Add(nullsDecl)
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
 			return err
 		}
-		orderDecl.Add(decl)
 	}
 
 	return nil
@@ -383,29 +590,88 @@ func (p *parser) parseWhere(selectDecl *Decl) error {
 	}
 	selectDecl.Add(whereDecl)
 
+	return p.parseConditions(whereDecl)
+}
+
+// parseHaving parses HAVING clause, which conditions may apply to aggregates
+// HAVING COUNT(*) > 5 AND country = 'FR'
+func (p *parser) parseHaving(selectDecl *Decl) error {
+	havingDecl, err := p.consumeToken(HavingToken)
+	if err != nil {
+		return err
+	}
+	selectDecl.Add(havingDecl)
+
+	return p.parseConditions(havingDecl)
+}
+
+// parseReturning parses RETURNING clause of INSERT, UPDATE and DELETE,
+// a list of attributes of written rows which may be aliased
+// RETURNING id, created_at AS creation
+// RETURNING *
+func (p *parser) parseReturning(decl *Decl) error {
+	retDecl, err := p.consumeToken(ReturningToken)
+	if err != nil {
+		return err
+	}
+	decl.Add(retDecl)
+
+	for {
+		attrDecl, err := p.parseAttribute()
+		if err != nil {
+			return err
+		}
+		if attrDecl.Token != StarToken {
+			if err := p.parseAlias(attrDecl); err != nil {
+				return err
+			}
+		}
+		retDecl.Add(attrDecl)
+
+		if !p.is(CommaToken) {
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseConditions parses a list of conditions linked with AND or OR
+// until the end of the clause. AND binds tighter than OR, which
+// executor handles since conditions are kept in order.
+func (p *parser) parseConditions(clauseDecl *Decl) error {
+
 	// Now should be a list of: Attribute and Operator and Value
 	gotClause := false
+	// linked is true after AND or OR, which must be followed by a condition
+	linked := false
 	for {
-		if !p.hasNext() && gotClause {
+		if !p.hasNext() && gotClause && !linked {
 			break
 		}
 
-		if p.is(OrderToken, LimitToken, ForToken) {
+		if p.is(OrderToken, LimitToken, OffsetToken, ForToken, GroupToken, HavingToken, UnionToken, IntersectToken, ExceptToken, ThenToken, ReturningToken, OnToken, SemicolonToken, BracketClosingToken) {
+			// Clause may not be empty
+			if !gotClause || linked {
+				return p.syntaxError()
+			}
 			break
 		}
 
-		attributeDecl, err := p.parseCondition()
+		attributeDecl, err := p.parseOperand()
 		if err != nil {
 			return err
 		}
-		whereDecl.Add(attributeDecl)
+		clauseDecl.Add(attributeDecl)
 
-		if p.is(AndToken, OrToken) {
+		linked = p.is(AndToken, OrToken)
+		if linked {
 			linkDecl, err := p.consumeToken(p.cur().Token)
 			if err != nil {
 				return err
 			}
-			whereDecl.Add(linkDecl)
+			clauseDecl.Add(linkDecl)
 		}
 
 		// Got at least one clause
@@ -415,14 +681,14 @@ func (p *parser) parseWhere(selectDecl *Decl) error {
 	return nil
 }
 
-// parseBuiltinFunc looks for COUNT,MAX,MIN
+// parseBuiltinFunc looks for COUNT,SUM,AVG,MIN,MAX,GROUP_CONCAT,STRING_AGG
 func (p *parser) parseBuiltinFunc() (*Decl, error) {
 	var d *Decl
 	var err error
 
 	// COUNT(attribute)
-	if p.is(CountToken) {
-		d, err = p.consumeToken(CountToken)
+	if p.is(CountToken, SumToken, AvgToken, MinToken, MaxToken, GroupConcatToken, StringAggToken) {
+		d, err = p.consumeToken(CountToken, SumToken, AvgToken, MinToken, MaxToken, GroupConcatToken, StringAggToken)
 		if err != nil {
 			return nil, err
 		}
@@ -431,12 +697,32 @@ func (p *parser) parseBuiltinFunc() (*Decl, error) {
 		if err != nil {
 			return nil, err
 		}
-		// Attribute
-		attr, err := p.parseAttribute()
+		// Attribute, or expression for GROUP_CONCAT and STRING_AGG
+		var attr *Decl
+		if d.Token == GroupConcatToken || d.Token == StringAggToken {
+			attr, err = p.parseExpression()
+		} else {
+			attr, err = p.parseAttribute()
+		}
 		if err != nil {
 			return nil, err
 		}
 		d.Add(attr)
+		// Separator, GROUP_CONCAT(name SEPARATOR ', ') or STRING_AGG(name, ', ')
+		if (d.Token == GroupConcatToken && p.is(StringToken) && strings.ToLower(p.cur().Lexeme) == "separator") ||
+			(d.Token == StringAggToken && p.is(CommaToken)) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if !p.is(SimpleQuoteToken) {
+				return nil, p.syntaxError()
+			}
+			sep, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			d.Add(sep)
+		}
 		// Bracket
 		_, err = p.consumeToken(BracketClosingToken)
 		if err != nil {
@@ -467,10 +753,10 @@ func (p *parser) parseAttribute() (*Decl, error) {
 	// shoud be a StringToken here
 	// If there is a point after, it's a table name,
 	// if not, it's the attribute
-	if !p.is(StringToken, StarToken) {
+	if !p.isName() && !p.is(StarToken) {
 		return nil, p.syntaxError()
 	}
-	decl := NewDecl(p.cur())
+	decl := p.nameDecl()
 
 	if quoted {
 		// Check there is a closing quote
@@ -491,10 +777,11 @@ func (p *parser) parseAttribute() (*Decl, error) {
 			return nil, err
 		}
 		// if so, next must be the attribute name or a star
-		attributeDecl, err := p.consumeToken(StringToken, StarToken)
-		if err != nil {
-			return nil, err
+		if !p.isName() && !p.is(StarToken) {
+			return nil, p.syntaxError()
 		}
+		attributeDecl := p.nameDecl()
+		p.next()
 		attributeDecl.Add(decl)
 		return attributeDecl, nil
 	}
@@ -503,6 +790,25 @@ func (p *parser) parseAttribute() (*Decl, error) {
 	return decl, nil
 }
 
+// nameKeywords are keywords only in some clauses, which can be names elsewhere,
+// like END in CREATE TABLE ev (id INT, end INT) or LEFT in SELECT left FROM lr
+var nameKeywords = []int{CaseToken, WhenToken, ThenToken, ElseToken, EndToken, LeftToken, RightToken, FullToken, OuterToken}
+
+// isName returns true if current token is a name, or a keyword which can be one
+func (p *parser) isName() bool {
+	return p.is(StringToken) || p.is(nameKeywords...)
+}
+
+// nameDecl returns current token as a decl, keywords being names
+func (p *parser) nameDecl() *Decl {
+	decl := NewDecl(p.cur())
+	if p.is(nameKeywords...) {
+		decl.Token = StringToken
+	}
+
+	return decl
+}
+
 // parseQuotedToken parse a token of the form
 // table
 // "table"
@@ -519,10 +825,10 @@ func (p *parser) parseQuotedToken() (*Decl, error) {
 	}
 
 	// shoud be a StringToken here
-	if !p.is(StringToken) {
+	if !p.isName() {
 		return nil, p.syntaxError()
 	}
-	decl := NewDecl(p.cur())
+	decl := p.nameDecl()
 
 	if quoted {
 
@@ -536,6 +842,64 @@ func (p *parser) parseQuotedToken() (*Decl, error) {
 	return decl, nil
 }
 
+// parseOperand parses a condition linked with AND or OR, which may be
+// negated or be a list of conditions between brackets
+// NOT a = 1
+// (a = 1 OR b = 2)
+// NOT (a = 1 AND b = 2)
+func (p *parser) parseOperand() (*Decl, error) {
+	// NOT EXISTS is a condition by itself
+	if _, err := p.isNext(ExistsToken); p.is(NotToken) && err != nil {
+		notDecl, err := p.consumeToken(NotToken)
+		if err != nil {
+			return nil, err
+		}
+		d, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		notDecl.Add(d)
+		return notDecl, nil
+	}
+
+	if p.is(BracketOpeningToken) {
+		if d := p.parseConditionsGroup(); d != nil {
+			return d, nil
+		}
+	}
+
+	return p.parseCondition()
+}
+
+// parseConditionsGroup parses a list of conditions between brackets, or returns
+// nil without consuming any token if brackets are part of a condition, like
+// (a + 1) * 2 > 3
+// (SELECT COUNT(*) FROM foo) > 3
+func (p *parser) parseConditionsGroup() *Decl {
+	start := p.index
+
+	groupDecl, err := p.consumeToken(BracketOpeningToken)
+	if err != nil || p.is(SelectToken) {
+		p.index = start
+		return nil
+	}
+	if err := p.parseConditions(groupDecl); err != nil || !p.is(BracketClosingToken) {
+		p.index = start
+		return nil
+	}
+	p.next()
+
+	// Closing bracket may be followed by an operator of a condition
+	if p.is(EqualityToken, LeftDipleToken, RightDipleToken, LessOrEqualToken, GreaterOrEqualToken,
+		PlusToken, MinusToken, StarToken, SlashToken, DoubleColonToken, ArrowToken, DoubleArrowToken,
+		InToken, BetweenToken, LikeToken, ILikeToken, IsToken, NotToken) {
+		p.index = start
+		return nil
+	}
+
+	return groupDecl
+}
+
 func (p *parser) parseCondition() (*Decl, error) {
 
 	// We may have the WHERE 1 condition
@@ -553,12 +917,41 @@ func (p *parser) parseCondition() (*Decl, error) {
 		return attributeDecl, nil
 	}
 
-	// Attribute
-	attributeDecl, err := p.parseAttribute()
+	// Boolean constant, like WHERE false
+	if p.is(TrueToken, FalseToken) {
+		return p.consumeToken(TrueToken, FalseToken)
+	}
+
+	// EXISTS subquery, possibly negated
+	if _, err := p.isNext(ExistsToken); p.is(ExistsToken) || (p.is(NotToken) && err == nil) {
+		return p.parseExists()
+	}
+
+	// Row of values compared with IN, like (a, b) IN ((1, 'x'), (2, 'y'))
+	if p.is(BracketOpeningToken) {
+		start := p.index
+		tupleDecl, err := p.parseTuple()
+		if err == nil {
+			return p.parseTupleIn(tupleDecl)
+		}
+		p.index = start
+	}
+
+	// Attribute, expression, or aggregate in HAVING clause
+	var attributeDecl *Decl
+	var err error
+	if p.is(CountToken, SumToken, AvgToken, MinToken, MaxToken, GroupConcatToken, StringAggToken) {
+		attributeDecl, err = p.parseBuiltinFunc()
+	} else {
+		attributeDecl, err = p.parseExpression()
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// LIKE operator may be followed by an ESCAPE clause
+	var likeDecl *Decl
+
 	switch p.cur().Token {
 	case EqualityToken, LeftDipleToken, RightDipleToken, LessOrEqualToken, GreaterOrEqualToken:
 		decl, err := p.consumeToken(p.cur().Token)
@@ -574,6 +967,49 @@ func (p *parser) parseCondition() (*Decl, error) {
 		}
 		attributeDecl.Add(inDecl)
 		return attributeDecl, nil
+	case BetweenToken:
+		betweenDecl, err := p.parseBetween()
+		if err != nil {
+			return nil, err
+		}
+		attributeDecl.Add(betweenDecl)
+		return attributeDecl, nil
+	case LikeToken, ILikeToken:
+		likeDecl, err = p.consumeToken(LikeToken, ILikeToken)
+		if err != nil {
+			return nil, err
+		}
+		attributeDecl.Add(likeDecl)
+	case NotToken:
+		notDecl, err := p.consumeToken(NotToken)
+		if err != nil {
+			return nil, err
+		}
+		attributeDecl.Add(notDecl)
+		switch {
+		case p.is(InToken):
+			inDecl, err := p.parseIn()
+			if err != nil {
+				return nil, err
+			}
+			notDecl.Add(inDecl)
+			return attributeDecl, nil
+		case p.is(BetweenToken):
+			betweenDecl, err := p.parseBetween()
+			if err != nil {
+				return nil, err
+			}
+			notDecl.Add(betweenDecl)
+			return attributeDecl, nil
+		case p.is(LikeToken, ILikeToken):
+			likeDecl, err = p.consumeToken(LikeToken, ILikeToken)
+			if err != nil {
+				return nil, err
+			}
+			notDecl.Add(likeDecl)
+		default:
+			return nil, p.syntaxError()
+		}
 	case IsToken:
 		log.Debug("parseCondition: IsToken\n")
 		decl, err := p.consumeToken(IsToken)
@@ -598,17 +1034,167 @@ func (p *parser) parseCondition() (*Decl, error) {
 			decl.Add(nullDecl)
 		}
 		return attributeDecl, nil
+	default:
+		// Boolean value by itself, like WHERE active, is compared with TRUE
+		if !p.is(AnyToken, AllToken) {
+			attributeDecl.Add(NewDecl(Token{Token: EqualityToken, Lexeme: "="}))
+			attributeDecl.Add(NewDecl(Token{Token: TrueToken, Lexeme: "true"}))
+			return attributeDecl, nil
+		}
 	}
 
-	// Value
+	// Comparison with rows of a subquery, like price > ALL (SELECT price FROM competitors)
+	if likeDecl == nil && p.is(AnyToken, AllToken) {
+		quantifierDecl, err := p.parseQuantifier()
+		if err != nil {
+			return nil, err
+		}
+		attributeDecl.Add(quantifierDecl)
+		return attributeDecl, nil
+	}
+
+	// Value, or expression in which unqualified names are attributes
+	start := p.index
 	valueDecl, err := p.parseValue()
+	if err != nil || p.is(PlusToken, MinusToken, StarToken, SlashToken, DoubleColonToken, ArrowToken, DoubleArrowToken, SquareBracketOpeningToken) {
+		p.index = start
+		valueDecl, err = p.parseExpression()
+	}
 	if err != nil {
 		return nil, err
 	}
 	attributeDecl.Add(valueDecl)
+
+	// name LIKE 'a\_b%' ESCAPE '\'
+	if likeDecl != nil && p.is(EscapeToken) {
+		escapeDecl, err := p.consumeToken(EscapeToken)
+		if err != nil {
+			return nil, err
+		}
+		charDecl, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		escapeDecl.Add(charDecl)
+		likeDecl.Add(escapeDecl)
+	}
+
 	return attributeDecl, nil
 }
 
+/*
+|-> all
+	|-> SELECT
+		|-> price
+		|-> FROM
+			|-> competitors
+*/
+// parseQuantifier parses ANY, SOME or ALL followed by a subquery, or by an array
+// tag = ANY (tags)
+func (p *parser) parseQuantifier() (*Decl, error) {
+	quantifierDecl, err := p.consumeToken(AnyToken, AllToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+
+	// Subquery, or array whose elements are compared
+	if p.is(SelectToken) {
+		i, err := p.parseSelect(p.tokens)
+		if err != nil {
+			return nil, err
+		}
+		quantifierDecl.Add(i.Decls[0])
+	} else {
+		arrayDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		quantifierDecl.Add(arrayDecl)
+	}
+
+	if _, err = p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+
+	return quantifierDecl, nil
+}
+
+// parseExists parses EXISTS condition, possibly negated
+// EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id)
+// NOT EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id)
+func (p *parser) parseExists() (*Decl, error) {
+	var notDecl *Decl
+	if p.is(NotToken) {
+		notDecl, _ = p.consumeToken(NotToken)
+	}
+
+	existsDecl, err := p.consumeToken(ExistsToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+	if !p.is(SelectToken) {
+		return nil, p.syntaxError()
+	}
+	i, err := p.parseSelect(p.tokens)
+	if err != nil {
+		return nil, err
+	}
+	existsDecl.Add(i.Decls[0])
+	if _, err = p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+
+	if notDecl != nil {
+		notDecl.Add(existsDecl)
+		return notDecl, nil
+	}
+	return existsDecl, nil
+}
+
+/*
+|-> age
+	|-> between
+		|-> 18
+		|-> 65
+*/
+// parseBetween parses BETWEEN operator and both its bounds
+// age BETWEEN 18 AND 65
+func (p *parser) parseBetween() (*Decl, error) {
+	betweenDecl, err := p.consumeToken(BetweenToken)
+	if err != nil {
+		return nil, err
+	}
+
+	lowDecl, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	betweenDecl.Add(lowDecl)
+
+	if _, err := p.consumeToken(AndToken); err != nil {
+		return nil, err
+	}
+
+	highDecl, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	betweenDecl.Add(highDecl)
+
+	return betweenDecl, nil
+}
+
+// parseIn parses IN clause, with either a list of values or a subquery
+// id IN (1, 2, 3)
+// id IN (SELECT user_id FROM admins)
 func (p *parser) parseIn() (*Decl, error) {
 	inDecl, err := p.consumeToken(InToken)
 	if err != nil {
@@ -621,10 +1207,32 @@ func (p *parser) parseIn() (*Decl, error) {
 		return nil, err
 	}
 
-	// list of value
+	// subquery
+	if p.is(SelectToken) {
+		i, err := p.parseSelect(p.tokens)
+		if err != nil {
+			return nil, err
+		}
+		inDecl.Add(i.Decls[0])
+		if _, err = p.consumeToken(BracketClosingToken); err != nil {
+			return nil, err
+		}
+		return inDecl, nil
+	}
+
+	// list of value, of constant expressions like negative numbers, or of tuples
 	gotList := false
 	for {
-		v, err := p.parseValue()
+		start := p.index
+		v, err := p.parseTuple()
+		if err != nil {
+			p.index = start
+			v, err = p.parseValue()
+		}
+		if err != nil || !p.is(CommaToken, BracketClosingToken) {
+			p.index = start
+			v, err = p.parseExpression()
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -648,11 +1256,99 @@ func (p *parser) parseIn() (*Decl, error) {
 	return inDecl, nil
 }
 
+/*
+|-> tuple
+	|-> a
+	|-> b
+*/
+// parseTuple parses a list of at least two expressions between brackets, like (a, b) or (1, 'x').
+// A single expression between brackets is not a tuple.
+func (p *parser) parseTuple() (*Decl, error) {
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+	tupleDecl := NewDecl(Token{Token: TupleToken, Lexeme: "tuple"})
+
+	for {
+		d, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		tupleDecl.Add(d)
+
+		if !p.is(CommaToken) {
+			break
+		}
+		p.next()
+	}
+
+	if _, err := p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+	if len(tupleDecl.Decl) < 2 {
+		return nil, p.syntaxError()
+	}
+
+	return tupleDecl, nil
+}
+
+/*
+|-> tuple
+	|-> a
+	|-> b
+	|-> in
+		|-> tuple
+			|-> 1
+			|-> x
+*/
+// parseTupleIn parses IN or NOT IN following a tuple, the only comparison supported for tuples
+func (p *parser) parseTupleIn(tupleDecl *Decl) (*Decl, error) {
+	decl := tupleDecl
+	if p.is(NotToken) {
+		notDecl, err := p.consumeToken(NotToken)
+		if err != nil {
+			return nil, err
+		}
+		tupleDecl.Add(notDecl)
+		decl = notDecl
+	}
+
+	if !p.is(InToken) {
+		return nil, p.syntaxError()
+	}
+	inDecl, err := p.parseIn()
+	if err != nil {
+		return nil, err
+	}
+	decl.Add(inDecl)
+
+	return tupleDecl, nil
+}
+
 func (p *parser) parseValue() (*Decl, error) {
 	debug("parseValue")
 	defer debug("~parseValue")
 	quoted := false
 
+	// Value may be another attribute, as long as it is qualified
+	if p.isQualifiedAttribute() {
+		return p.parseAttribute()
+	}
+
+	// In CHECK conditions, unquoted names are attributes of the table
+	if p.checking && p.is(StringToken) {
+		attributeDecl, err := p.consumeToken(StringToken)
+		if err != nil {
+			return nil, err
+		}
+		attributeDecl.Add(&Decl{Token: StringToken, Lexeme: p.table})
+		return attributeDecl, nil
+	}
+
+	if p.is(CaseToken) {
+		return p.parseCase()
+	}
+
 	if p.is(SimpleQuoteToken) || p.is(DoubleQuoteToken) {
 		quoted = true
 		debug("value %v is quoted!", p.tokens[p.index])
@@ -662,7 +1358,7 @@ func (p *parser) parseValue() (*Decl, error) {
 		}
 	}
 
-	valueDecl, err := p.consumeToken(StringToken, NumberToken, DateToken, NowToken)
+	valueDecl, err := p.consumeToken(StringToken, NumberToken, DateToken, NowToken, CurrentTimestampToken, LocalTimestampToken, TrueToken, FalseToken)
 	if err != nil {
 		debug("parseValue: Wasn't expecting %v\n", p.tokens[p.index])
 		return nil, err
@@ -683,14 +1379,38 @@ func (p *parser) parseValue() (*Decl, error) {
 
 // parseJoin parses the JOIN keywords and all its condition
 // JOIN user_addresses ON address.id=user_addresses.address_id
+// INNER JOIN orders o ON u.id = o.user_id AND o.total > 10
+// LEFT OUTER JOIN orders o ON u.id = o.user_id
+// FULL JOIN orders o ON u.id = o.user_id
 func (p *parser) parseJoin() (*Decl, error) {
+	var typeDecl *Decl
+	var err error
+
+	// INNER is the default join type
+	switch {
+	case p.is(InnerToken):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	case p.is(LeftToken, RightToken, FullToken):
+		typeDecl, err = p.consumeToken(LeftToken, RightToken, FullToken)
+		if err != nil {
+			return nil, err
+		}
+		if p.is(OuterToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	joinDecl, err := p.consumeToken(JoinToken)
 	if err != nil {
 		return nil, err
 	}
 
 	// TABLE NAME
-	tableDecl, err := p.parseAttribute()
+	tableDecl, err := p.parseTableReference()
 	if err != nil {
 		return nil, err
 	}
@@ -701,31 +1421,114 @@ func (p *parser) parseJoin() (*Decl, error) {
 	if err != nil {
 		return nil, err
 	}
-	// onDecl := NewDecl(t)
 	joinDecl.Add(onDecl)
 
-	// ATTRIBUTE
-	leftAttributeDecl, err := p.parseAttribute()
+	// List of conditions, same as WHERE clause
+	for {
+		condDecl, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		onDecl.Add(condDecl)
+
+		if !p.is(AndToken, OrToken) {
+			break
+		}
+		linkDecl, err := p.consumeToken(AndToken, OrToken)
+		if err != nil {
+			return nil, err
+		}
+		onDecl.Add(linkDecl)
+	}
+
+	if typeDecl != nil {
+		joinDecl.Add(typeDecl)
+	}
+
+	return joinDecl, nil
+}
+
+// parseTableReference parses a table name, or a derived table, with an optional alias
+// account
+// "account" a
+// account AS a
+// (SELECT a AS x FROM foo) t
+func (p *parser) parseTableReference() (*Decl, error) {
+	if _, err := p.isNext(SelectToken); p.is(BracketOpeningToken) && err == nil {
+		return p.parseDerivedTable()
+	}
+	if _, err := p.isNext(WithToken); p.is(BracketOpeningToken) && err == nil {
+		return p.parseDerivedTable()
+	}
+
+	tableDecl, err := p.parseAttribute()
 	if err != nil {
 		return nil, err
 	}
-	onDecl.Add(leftAttributeDecl)
 
-	// EQUAL
-	equalAttr, err := p.consumeToken(EqualityToken)
-	if err != nil {
+	if err := p.parseAlias(tableDecl); err != nil {
 		return nil, err
 	}
-	onDecl.Add(equalAttr)
 
-	//ATTRIBUTE
-	rightAttributeDecl, err := p.parseAttribute()
+	if ref := p.cteReference(tableDecl); ref != nil {
+		return ref, nil
+	}
+
+	return tableDecl, nil
+}
+
+// parseDerivedTable parses a subquery used as a table, which must be aliased
+// (SELECT a AS x FROM foo WHERE a > 0) AS t
+func (p *parser) parseDerivedTable() (*Decl, error) {
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+
+	i, err := p.parseSelect(p.tokens)
 	if err != nil {
 		return nil, err
 	}
-	onDecl.Add(rightAttributeDecl)
+	subqueryDecl := i.Decls[0]
 
-	return joinDecl, nil
+	if _, err := p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+
+	if !p.is(AsToken, StringToken, DoubleQuoteToken, BacktickToken) {
+		return nil, fmt.Errorf("subquery in FROM must have an alias")
+	}
+	if err := p.parseAlias(subqueryDecl); err != nil {
+		return nil, err
+	}
+
+	return subqueryDecl, nil
+}
+
+// parseAlias parses an optional alias of a table or a selected value,
+// and adds it to decl
+// AS a
+// a
+// "a"
+func (p *parser) parseAlias(decl *Decl) error {
+	if !p.is(AsToken, StringToken, DoubleQuoteToken, BacktickToken) {
+		return nil
+	}
+
+	asDecl := NewDecl(Token{Token: AsToken, Lexeme: "as"})
+	if p.is(AsToken) {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	aliasDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return err
+	}
+	asDecl.Add(aliasDecl)
+	decl.Add(asDecl)
+
+	return nil
 }
 
 func (p *parser) parseListElement() (*Decl, error) {
@@ -740,16 +1543,23 @@ func (p *parser) parseListElement() (*Decl, error) {
 		return v, nil
 	}
 
+	if p.isArrayConstructor() {
+		return p.parseArray()
+	}
+	if p.isFunctionCall() {
+		return p.parseFunctionCall()
+	}
+
 	if p.is(SimpleQuoteToken) || p.is(DoubleQuoteToken) {
 		quoted = true
 		p.next()
 	}
 
-	var valueDecl *Decl
-	valueDecl, err := p.consumeToken(StringToken, NumberToken, NullToken, DateToken, NowToken)
-	if err != nil {
-		return nil, err
+	if !p.isName() && !p.is(NumberToken, NullToken, DateToken, NowToken, CurrentTimestampToken, LocalTimestampToken, TrueToken, FalseToken) {
+		return nil, p.syntaxError()
 	}
+	valueDecl := p.nameDecl()
+	p.next()
 
 	if quoted {
 		if _, err := p.consumeToken(SimpleQuoteToken, DoubleQuoteToken); err != nil {
@@ -786,6 +1596,22 @@ func (p *parser) is(tokenTypes ...int) bool {
 	return false
 }
 
+// isQualifiedAttribute returns true if current tokens are
+// of the form table.foo or "table".foo
+func (p *parser) isQualifiedAttribute() bool {
+	i := p.index
+	if p.is(DoubleQuoteToken, BacktickToken) {
+		i += 2
+		if i >= p.tokenLen || p.tokens[i].Token != p.cur().Token {
+			return false
+		}
+	} else if !p.is(StringToken) {
+		return false
+	}
+
+	return i+1 < p.tokenLen && p.tokens[i+1].Token == PeriodToken
+}
+
 func (p *parser) isNot(tokenTypes ...int) bool {
 	return !p.is(tokenTypes...)
 }
@@ -846,13 +1672,17 @@ func (p *parser) consumeToken(tokenTypes ...int) (*Decl, error) {
 	return decl, nil
 }
 
+// syntaxError returns an error at current token, quoting the tokens around it
 func (p *parser) syntaxError() error {
+	err := &SyntaxError{Token: p.tokens[p.index].Lexeme, pos: p.tokens[p.index].Pos}
 	if p.index == 0 {
-		return fmt.Errorf("Syntax error near %v %v", p.tokens[p.index].Lexeme, p.tokens[p.index+1].Lexeme)
+		err.Message = fmt.Sprintf("Syntax error near %v %v", p.tokens[p.index].Lexeme, p.tokens[p.index+1].Lexeme)
 	} else if !p.hasNext() {
-		return fmt.Errorf("Syntax error near %v %v", p.tokens[p.index-1].Lexeme, p.tokens[p.index].Lexeme)
+		err.Message = fmt.Sprintf("Syntax error near %v %v", p.tokens[p.index-1].Lexeme, p.tokens[p.index].Lexeme)
+	} else {
+		err.Message = fmt.Sprintf("Syntax error near %v %v %v", p.tokens[p.index-1].Lexeme, p.tokens[p.index].Lexeme, p.tokens[p.index+1].Lexeme)
 	}
-	return fmt.Errorf("Syntax error near %v %v %v", p.tokens[p.index-1].Lexeme, p.tokens[p.index].Lexeme, p.tokens[p.index+1].Lexeme)
+	return err
 }
 
 func stripSpaces(t []Token) (ret []Token) {
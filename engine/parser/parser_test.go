@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -128,6 +130,638 @@ func TestSelectJoin(t *testing.T) {
 	parse(query, 1, t)
 }
 
+func TestSelectInnerJoinAlias(t *testing.T) {
+	query := `SELECT u.name, o.total FROM users AS u
+	INNER JOIN orders o ON u.id = o.user_id AND o.total > 10`
+	parse(query, 1, t)
+
+	query = `SELECT * FROM users u, orders o WHERE u.id = o.user_id`
+	parse(query, 1, t)
+}
+
+func TestSelectLeftJoin(t *testing.T) {
+	query := `SELECT u.name, t.name FROM users u LEFT JOIN teams t ON t.id = u.team_id`
+	parse(query, 1, t)
+
+	query = `SELECT u.name, t.name FROM users u LEFT OUTER JOIN teams t ON t.id = u.team_id WHERE u.id = 1`
+	parse(query, 1, t)
+}
+
+func TestSelectRightAndFullJoin(t *testing.T) {
+	queries := []string{
+		`SELECT u.name, t.name FROM users u RIGHT JOIN teams t ON t.id = u.team_id`,
+		`SELECT u.name, t.name FROM users u RIGHT OUTER JOIN teams t ON t.id = u.team_id`,
+		`SELECT u.name, t.name FROM users u FULL JOIN teams t ON t.id = u.team_id`,
+		`SELECT u.name, t.name FROM users u FULL OUTER JOIN teams t ON t.id = u.team_id`,
+	}
+
+	for _, query := range queries {
+		parse(query, 1, t)
+	}
+}
+
+func TestSelectGroupBy(t *testing.T) {
+	query := `SELECT country, COUNT(*), SUM(amount) FROM orders GROUP BY country`
+	parse(query, 1, t)
+
+	query = `SELECT o.country, o.city, AVG(o.amount), MIN(amount), MAX(amount) FROM orders o WHERE o.amount > 10 GROUP BY o.country, city ORDER BY country`
+	parse(query, 1, t)
+
+	query = `SELECT country FROM orders GROUP BY country HAVING SUM(amount) > 100 AND country = 'FR' ORDER BY country`
+	parse(query, 1, t)
+
+	// group is not a keyword by itself
+	query = `SELECT group.name FROM group`
+	parse(query, 1, t)
+}
+
+func TestSelectDistinct(t *testing.T) {
+	query := `SELECT DISTINCT status FROM tasks`
+	parse(query, 1, t)
+
+	query = `SELECT DISTINCT owner, status FROM tasks WHERE status = 'done' ORDER BY owner`
+	parse(query, 1, t)
+}
+
+func TestSelectOrderByDirections(t *testing.T) {
+	query := `SELECT * FROM item ORDER BY created_at DESC, name ASC`
+	parse(query, 1, t)
+
+	query = `SELECT * FROM item ORDER BY item.price DESC NULLS LAST, name NULLS FIRST LIMIT 10`
+	parse(query, 1, t)
+}
+
+func TestSelectSubquery(t *testing.T) {
+	queries := []string{
+		`SELECT * FROM users WHERE id IN (SELECT user_id FROM admins)`,
+		`SELECT * FROM users WHERE id NOT IN (SELECT user_id FROM admins WHERE level > 1) AND name = 'bob'`,
+		`SELECT * FROM users WHERE id NOT IN (1, 2, 3)`,
+		`SELECT * FROM orders o WHERE EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id)`,
+		`SELECT * FROM orders o WHERE o.total > 10 AND NOT EXISTS (SELECT * FROM refunds r WHERE r.order_id = o.id)`,
+		`SELECT name, (SELECT COUNT(*) FROM orders WHERE user_id = u.id) AS order_count FROM users u`,
+		`SELECT (SELECT MAX(amount) FROM orders) max_amount, name FROM users`,
+		`SELECT t.x FROM (SELECT a AS x FROM foo WHERE a > 0) t`,
+		`SELECT t.x, bar.y FROM bar JOIN (SELECT a AS x, COUNT(*) AS n FROM foo GROUP BY a) AS t ON t.x = bar.x`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+
+	// Derived table must have an alias
+	_, err := ParseInstruction(`SELECT x FROM (SELECT a AS x FROM foo)`)
+	if err == nil {
+		t.Fatalf("Expected error with derived table without alias")
+	}
+}
+
+func TestSelectUnion(t *testing.T) {
+	queries := []string{
+		`SELECT a FROM t1 UNION SELECT a FROM t2`,
+		`SELECT a FROM t1 WHERE b = 1 UNION ALL SELECT a FROM t2 ORDER BY a DESC LIMIT 2`,
+		`SELECT a FROM t1 UNION SELECT a FROM t2 UNION ALL SELECT a FROM t3`,
+		`SELECT * FROM t WHERE a IN (SELECT a FROM t1 UNION SELECT a FROM t2)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectIntersectExcept(t *testing.T) {
+	queries := []string{
+		`SELECT a FROM t1 INTERSECT SELECT a FROM t2`,
+		`SELECT a FROM t1 EXCEPT ALL SELECT a FROM t2 ORDER BY a LIMIT 2`,
+		`SELECT a FROM t1 UNION SELECT a FROM t2 INTERSECT SELECT a FROM t3`,
+		`SELECT * FROM t WHERE EXISTS (SELECT a FROM t1 EXCEPT SELECT a FROM t2)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectCase(t *testing.T) {
+	queries := []string{
+		`SELECT CASE WHEN score >= 90 THEN 'A' WHEN score >= 80 THEN 'B' ELSE 'C' END AS grade FROM results`,
+		`SELECT CASE country WHEN 'FR' THEN 1 END FROM results`,
+		`SELECT name FROM results WHERE CASE WHEN score > 10 AND r.country = 'FR' THEN score END = 20`,
+		`SELECT name FROM results ORDER BY CASE WHEN score IS NULL THEN 0 ELSE 1 END DESC, name`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectArithmetic(t *testing.T) {
+	queries := []string{
+		`SELECT price * quantity AS total FROM line_items WHERE price * quantity > 100`,
+		`SELECT (price + 1) * -quantity / 2 FROM line_items ORDER BY price * quantity DESC`,
+		`SELECT * FROM line_items WHERE quantity - 1 > -5 AND price = quantity * 2.5`,
+		`SELECT l.*, l.price - 1 FROM line_items l`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectFunction(t *testing.T) {
+	queries := []string{
+		`SELECT UPPER(name), SUBSTR(sku, 1, 3) AS prefix FROM products`,
+		`SELECT CONCAT(UPPER(SUBSTR(name, 1, 1)), '-', LENGTH(name) + 1) FROM products`,
+		`SELECT * FROM products WHERE TRIM(code) = 'x' ORDER BY LOWER(name) DESC`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectDateFunctions(t *testing.T) {
+	queries := []string{
+		`SELECT NOW(), CURRENT_TIMESTAMP, EXTRACT(YEAR FROM created_at) AS year FROM events`,
+		`SELECT * FROM events WHERE created_at <= NOW() AND EXTRACT(MONTH FROM created_at) = 4`,
+		`INSERT INTO events (name, created_at) VALUES ('launch', CURRENT_TIMESTAMP)`,
+		`CREATE TABLE events (id BIGSERIAL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectCast(t *testing.T) {
+	queries := []string{
+		`SELECT CAST(amount AS INTEGER), CAST(ratio AS DOUBLE PRECISION) AS r FROM measures`,
+		`SELECT * FROM measures WHERE amount::int > 10 ORDER BY ratio::decimal(10, 2) DESC`,
+		`SELECT CAST(taken_at AS TIMESTAMP WITH TIME ZONE), label::varchar(32) FROM measures`,
+		`SELECT * FROM measures WHERE 10 < -amount::int + 1`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectLike(t *testing.T) {
+	queries := []string{
+		`SELECT * FROM files WHERE name LIKE 'a_b%'`,
+		`SELECT * FROM files WHERE name NOT ILIKE '%.pdf' AND id > 1`,
+		`SELECT * FROM files WHERE name LIKE 'a#_b' ESCAPE '#' OR name LIKE '100\%'`,
+		`DELETE FROM files WHERE name LIKE 'tmp%'`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectBetween(t *testing.T) {
+	queries := []string{
+		`SELECT * FROM people WHERE age BETWEEN 18 AND 65`,
+		`SELECT * FROM people WHERE age NOT BETWEEN 18 AND 65 AND name = 'bob'`,
+		`SELECT * FROM people WHERE born BETWEEN '1970-01-01' AND '2005-12-31' OR age BETWEEN 10 + 8 AND 60`,
+		`DELETE FROM people WHERE age BETWEEN 18 AND 65`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectInList(t *testing.T) {
+	queries := []string{
+		`SELECT * FROM tickets WHERE status IN ('open', 'pending', 'closed')`,
+		`SELECT * FROM tickets WHERE priority NOT IN (-1, 1 + 1, NULL)`,
+		`SELECT * FROM tickets WHERE id IN (1, 2) AND status IN ('open')`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestAlterTableAddColumn(t *testing.T) {
+	queries := []string{
+		`ALTER TABLE users ADD COLUMN age INT DEFAULT 0`,
+		`ALTER TABLE users ADD age INT`,
+		`ALTER TABLE "users" ADD COLUMN email TEXT NOT NULL UNIQUE`,
+		`ALTER TABLE users ADD COLUMN created TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestAlterTableDropColumn(t *testing.T) {
+	queries := []string{
+		`ALTER TABLE users DROP COLUMN age`,
+		`ALTER TABLE users DROP age`,
+		`ALTER TABLE "users" DROP COLUMN IF EXISTS "age"`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestAlterTableRename(t *testing.T) {
+	queries := []string{
+		`ALTER TABLE users RENAME TO accounts`,
+		`ALTER TABLE "users" RENAME COLUMN age TO years`,
+		`ALTER TABLE users RENAME age TO "years"`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestCreateTablePrimaryKeyConstraint(t *testing.T) {
+	queries := []string{
+		`CREATE TABLE users (id BIGINT, name TEXT, PRIMARY KEY (id))`,
+		`CREATE TABLE members (user_id BIGINT, group_id BIGINT, PRIMARY KEY (user_id, group_id), role TEXT)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestCreateIndex(t *testing.T) {
+	queries := []string{
+		`CREATE INDEX idx_user_email ON users(email)`,
+		`CREATE INDEX idx_user_name ON "users" ("first_name", last_name)`,
+		`CREATE UNIQUE INDEX idx_user_login ON users USING hash (login)`,
+		`CREATE INDEX ON users (email)`,
+		`DROP INDEX idx_user_email`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestDropIfExists(t *testing.T) {
+	queries := []string{
+		`DROP TABLE account`,
+		`DROP TABLE IF EXISTS account`,
+		`DROP TABLE IF EXISTS "account"`,
+		`DROP INDEX IF EXISTS idx_account_email`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	queries := []string{
+		`TRUNCATE events`,
+		`TRUNCATE TABLE events`,
+		`TRUNCATE TABLE "events" CASCADE`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestCreateView(t *testing.T) {
+	queries := []string{
+		`CREATE VIEW active_users AS SELECT * FROM users WHERE active = true`,
+		`CREATE VIEW "big_orders" AS SELECT user_id, amount FROM orders WHERE amount > 100 ORDER BY amount DESC`,
+		`DROP VIEW active_users`,
+		`DROP VIEW IF EXISTS active_users`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestReturning(t *testing.T) {
+	queries := []string{
+		`INSERT INTO users (name) VALUES ($$alice$$) RETURNING id`,
+		`INSERT INTO users (name) VALUES ('alice') RETURNING id, created_at AS creation`,
+		`INSERT INTO users (name) VALUES ('alice') RETURNING *`,
+		`UPDATE users SET score = 50, name = 'bob' WHERE id = 1 RETURNING users.id, score`,
+		`DELETE FROM users WHERE id = 2 RETURNING name`,
+		`DELETE FROM users RETURNING *`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	queries := []string{
+		`INSERT INTO kv (k, v) VALUES ($$a$$, $$b$$) ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'b') ON CONFLICT (k) DO UPDATE SET v = excluded.v, hits = kv.hits + 1 RETURNING *`,
+		`INSERT INTO pairs (a, b) VALUES (1, 2) ON CONFLICT (a, b) DO NOTHING`,
+		`INSERT INTO kv (k, v) VALUES ('a', 'b') ON CONFLICT DO NOTHING`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestInsertMultipleRows(t *testing.T) {
+	queries := []string{
+		`INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, 'z')`,
+		`INSERT INTO t (a, b) VALUES (1, 'x'),(2, DEFAULT) RETURNING id`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestInsertExpressions(t *testing.T) {
+	queries := []string{
+		`INSERT INTO t (a, b) VALUES (-5, 1)`,
+		`INSERT INTO t (a, b) VALUES (0 - 5, 1)`,
+		`INSERT INTO t (a, b) VALUES (-(1 + 2) * 3, CASE 1 WHEN 2 THEN 'x' ELSE 'y' END), (UPPER('x'), '2'::int)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestInsertSelect(t *testing.T) {
+	queries := []string{
+		`INSERT INTO archive SELECT * FROM events WHERE ts < $$10$$`,
+		`INSERT INTO archive (id, name) SELECT id, name FROM events WHERE ts < 10 ORDER BY id RETURNING id`,
+		`INSERT INTO archive (id) SELECT id FROM events UNION SELECT id FROM logs ON CONFLICT DO NOTHING`,
+		`INSERT INTO archive VALUES (1, 'a')`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestUpdateFrom(t *testing.T) {
+	queries := []string{
+		`UPDATE orders o SET region = c.region FROM customers c WHERE o.customer_id = c.id`,
+		`UPDATE orders AS o SET region = c.region, city = x.city FROM customers AS c, offices x WHERE o.customer_id = c.id AND x.region = c.region`,
+		`UPDATE orders SET region = customers.region FROM customers WHERE orders.customer_id = customers.id RETURNING orders.id`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	queries := []string{
+		`BEGIN`,
+		`BEGIN TRANSACTION`,
+		`COMMIT`,
+		`commit transaction`,
+		`ROLLBACK`,
+		`SAVEPOINT sp1`,
+		`ROLLBACK TO SAVEPOINT sp1`,
+		`ROLLBACK TRANSACTION TO sp1`,
+		`RELEASE SAVEPOINT sp1`,
+		`RELEASE "sp1"`,
+		`BEGIN READ ONLY`,
+		`BEGIN TRANSACTION READ WRITE`,
+		`SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY`,
+		`set session characteristics as transaction read write`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestBooleanLiterals(t *testing.T) {
+	queries := []string{
+		`INSERT INTO flag (id, active) VALUES (1, TRUE), (2, false)`,
+		`SELECT id FROM flag WHERE active = true`,
+		`UPDATE flag SET active = FALSE WHERE id = 1`,
+		`SELECT id FROM flag WHERE active IN (true, false)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestShow(t *testing.T) {
+	queries := []string{
+		`SHOW TABLES`,
+		`show columns from users`,
+		`SHOW COLUMNS IN "users"`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	queries := []string{
+		`EXPLAIN SELECT * FROM users WHERE email = 'foo@bar.com'`,
+		`explain SELECT id FROM users UNION SELECT id FROM admins ORDER BY id`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestCreateTableNumeric(t *testing.T) {
+	queries := []string{
+		`CREATE TABLE account (id INT, amount NUMERIC(10, 2))`,
+		`CREATE TABLE account (id INT, amount DECIMAL(10))`,
+		`CREATE TABLE account (id INT, amount NUMERIC)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestDeleteAlias(t *testing.T) {
+	queries := []string{
+		`DELETE FROM users u WHERE u.id = 1`,
+		`DELETE FROM users AS u WHERE u.id = 1 RETURNING id`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestConditionsGroup(t *testing.T) {
+	queries := []string{
+		`SELECT * FROM t WHERE (a = 1 OR b = 2) AND c = 3`,
+		`SELECT * FROM t WHERE NOT (a = 1 AND b = 2) OR NOT c = 3`,
+		`SELECT * FROM t WHERE ((a = 1))`,
+		`SELECT * FROM t WHERE (a + 1) * 2 = 4 AND (b = 2 OR c = 0)`,
+		`SELECT * FROM t JOIN u ON t.id = u.id AND (u.a = 1 OR u.b = 2)`,
+		`UPDATE t SET a = 1 WHERE (b = 2 OR c = 3) AND NOT d = 4`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
+func TestSelectBooleanPredicate(t *testing.T) {
+	parse(`SELECT COUNT(*) FROM account WHERE false`, 1, t)
+	parse(`SELECT * FROM account WHERE true AND id = 1`, 1, t)
+	parse(`SELECT * FROM account WHERE NOT false`, 1, t)
+}
+
+func TestSelectGroupConcat(t *testing.T) {
+	parse(`SELECT GROUP_CONCAT(name) FROM account`, 1, t)
+	parse(`SELECT group_concat(account.name SEPARATOR ', ') AS names FROM account GROUP BY country`, 1, t)
+	parse(`SELECT string_agg(name, ', ') FROM account HAVING string_agg(name, '') = 'ab'`, 1, t)
+	parse(`SELECT string_agg(name) FROM account`, 1, t)
+	parse(`SELECT string_agg(UPPER(name), ','), group_concat(CAST(id AS TEXT) SEPARATOR '-') FROM account`, 1, t)
+}
+
+func TestSelectWindow(t *testing.T) {
+	parse(`SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS rn FROM event`, 1, t)
+	parse(`SELECT id, row_number() OVER (PARTITION BY event.user_id, kind) FROM event`, 1, t)
+	parse(`SELECT id, row_number() OVER (ORDER BY created_at) FROM event`, 1, t)
+	parse(`SELECT id, row_number() OVER () FROM event`, 1, t)
+	parse(`SELECT over, partition FROM event`, 1, t)
+}
+
+func TestSelectWith(t *testing.T) {
+	parse(`WITH recent AS (SELECT * FROM event WHERE ts > 10) SELECT * FROM recent WHERE type = 'click'`, 1, t)
+	parse(`WITH a AS (SELECT id FROM event), b AS (SELECT id FROM a) SELECT a.id FROM a JOIN b ON a.id = b.id`, 1, t)
+	parse(`EXPLAIN WITH a AS (SELECT id FROM event) SELECT id FROM a`, 1, t)
+	parse(`SELECT id FROM (WITH a AS (SELECT id FROM event) SELECT id FROM a) t`, 1, t)
+}
+
+func TestSelectWithRecursive(t *testing.T) {
+	parse(`WITH RECURSIVE tree AS (SELECT id FROM category WHERE parent_id IS NULL UNION ALL SELECT c.id FROM category c JOIN tree ON c.parent_id = tree.id) SELECT * FROM tree`, 1, t)
+	parse(`WITH RECURSIVE up AS (SELECT id FROM category WHERE id = 5 UNION SELECT c.id FROM category c, up u WHERE c.id = u.parent_id) SELECT id FROM up`, 1, t)
+	parse(`WITH recursive AS (SELECT id FROM category) SELECT id FROM recursive`, 1, t)
+	parse(`WITH RECURSIVE n(v) AS (SELECT id FROM category WHERE id = 1 UNION ALL SELECT c.id FROM category c JOIN n ON c.parent_id = n.v) SELECT v FROM n`, 1, t)
+	parse(`WITH RECURSIVE n("v", depth) AS (SELECT id, 0 FROM category UNION SELECT id, depth FROM n) SELECT v, depth FROM n`, 1, t)
+}
+
+func TestSelectInTuple(t *testing.T) {
+	parse(`SELECT id FROM stock WHERE (warehouse, sku) IN ((1, 'x'), (2, 'y'))`, 1, t)
+	parse(`SELECT id FROM stock WHERE (warehouse, sku) NOT IN (SELECT warehouse, sku FROM recall)`, 1, t)
+	parse(`SELECT id FROM stock WHERE id > 1 AND ((warehouse, sku) IN ((1, 'x')) OR sku IS NULL)`, 1, t)
+	parse(`SELECT id FROM stock WHERE (warehouse + 1) * 2 IN (4, 6)`, 1, t)
+}
+
+func TestSelectQuantifiedSubquery(t *testing.T) {
+	parse(`SELECT name FROM products WHERE price > ALL (SELECT price FROM competitors)`, 1, t)
+	parse(`SELECT name FROM products WHERE price = ANY (SELECT price FROM competitors WHERE store = 'a')`, 1, t)
+	parse(`SELECT name FROM products WHERE NOT price <= SOME (SELECT price FROM competitors) AND id > 1`, 1, t)
+	parse(`SELECT any, some FROM products WHERE any = 1`, 1, t)
+}
+
+func TestSelectJSONPath(t *testing.T) {
+	parse(`SELECT data -> 'user' ->> 'name' FROM events WHERE data->'tags'->>0 = 'a'`, 1, t)
+	parse(`SELECT data -> 0, data ->> -1 AS last FROM events ORDER BY data ->> 'created_at'`, 1, t)
+	parse(`SELECT id FROM events WHERE (data -> 'user' ->> 'age')::integer > 30`, 1, t)
+}
+
+func TestArray(t *testing.T) {
+	parse(`CREATE TABLE posts (id INT, tags TEXT[], codes VARCHAR(3)[])`, 1, t)
+	parse(`INSERT INTO posts (id, tags) VALUES (1, ARRAY['go', 'sql'])`, 1, t)
+	parse(`UPDATE posts SET tags = ARRAY[] WHERE id = 1`, 1, t)
+	parse(`SELECT id, cardinality(tags) FROM posts WHERE 'go' = ANY (tags) AND 2 > ALL (ARRAY[id, 1])`, 1, t)
+}
+
+func TestCreateTableEnum(t *testing.T) {
+	parse(`CREATE TABLE tickets (id INT, status ENUM('open', 'closed', 'archived') NOT NULL DEFAULT 'open')`, 1, t)
+	parse(`CREATE TABLE tickets (status enum('open'))`, 1, t)
+}
+
+func TestCreateTableUUID(t *testing.T) {
+	parse(`CREATE TABLE account (id UUID DEFAULT gen_random_uuid() PRIMARY KEY, name TEXT)`, 1, t)
+	parse(`SELECT gen_random_uuid() FROM account`, 1, t)
+}
+
+func TestSequence(t *testing.T) {
+	parse(`CREATE SEQUENCE order_id`, 1, t)
+	parse(`CREATE SEQUENCE order_id START WITH 100 INCREMENT BY -1`, 1, t)
+	parse(`CREATE SEQUENCE "order_id" INCREMENT 5 START 1`, 1, t)
+	parse(`INSERT INTO orders (id) VALUES (nextval('order_id'))`, 1, t)
+	parse(`DROP SEQUENCE IF EXISTS order_id`, 1, t)
+}
+
+func TestCreateTableOnUpdate(t *testing.T) {
+	parse(`CREATE TABLE account (id INT, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL)`, 1, t)
+	parse(`CREATE TABLE account (id INT, version INT ON UPDATE 1 DEFAULT 0)`, 1, t)
+}
+
+func TestCreateTrigger(t *testing.T) {
+	parse(`CREATE TRIGGER set_total BEFORE INSERT ON orders FOR EACH ROW SET NEW.total = NEW.price * NEW.quantity`, 1, t)
+	parse(`CREATE TRIGGER no_decrease BEFORE UPDATE ON orders FOR EACH ROW WHEN (NEW.total < OLD.total) RAISE 'total cannot decrease'`, 1, t)
+	parse(`CREATE TRIGGER no_delete AFTER DELETE ON orders FOR EACH ROW WHEN (old.paid = true) RAISE EXCEPTION 'paid order'`, 1, t)
+	parse(`DROP TRIGGER IF EXISTS set_total ON orders`, 1, t)
+	parse(`DROP TRIGGER set_total`, 1, t)
+}
+
+func TestCreateTableForeignKey(t *testing.T) {
+	parse(`CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES users (id))`, 1, t)
+	parse(`CREATE TABLE orders (id INT PRIMARY KEY, user_id INT NOT NULL REFERENCES users, parent_id INT REFERENCES orders)`, 1, t)
+	parse(`CREATE TABLE orders (id INT, user_id INT, region TEXT, FOREIGN KEY (user_id, region) REFERENCES users (id, region), PRIMARY KEY (id))`, 1, t)
+	parse(`CREATE TABLE orders (id INT, user_id INT REFERENCES users (id) ON DELETE CASCADE, parent_id INT REFERENCES orders ON DELETE SET NULL)`, 1, t)
+	parse(`CREATE TABLE orders (id INT, user_id INT REFERENCES users ON DELETE RESTRICT ON UPDATE 0, FOREIGN KEY (id) REFERENCES items ON DELETE NO ACTION)`, 1, t)
+}
+
+func TestCreateTableCheck(t *testing.T) {
+	parse(`CREATE TABLE product (id INT, price FLOAT CHECK (price > 0), discount FLOAT CHECK (discount >= 0 AND discount < price))`, 1, t)
+	parse(`CREATE TABLE product (id INT, price FLOAT NOT NULL CONSTRAINT positive_price CHECK (price > 0) DEFAULT 1)`, 1, t)
+	parse(`CREATE TABLE booking (id INT, start INT, stop INT, CHECK (start < stop), CONSTRAINT valid_id CHECK (id > 0), PRIMARY KEY (id))`, 1, t)
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	log.UseTestLogger(t)
+
+	expected := []struct {
+		query    string
+		token    string
+		position int
+		line     int
+		column   int
+		snippet  string
+	}{
+		{`SELECT * FORM account`, "FORM", 10, 1, 10, "SELECT * FORM account"},
+		{"SELECT id,\n  email\nFROM account\nWHERE id = = 1", "=", 44, 4, 12, "WHERE id = = 1"},
+		{`SELECT id FROM account WHERE email = 'a very long email address@example.com' AND AND id = 2`, "and", 82, 1, 82, "...ss@example.com' AND AND id = 2"},
+		{`SELECT * FROM account WHERE id =`, ";", 33, 1, 33, "...M account WHERE id ="},
+		{`SELEC * FROM account`, "SELEC", 1, 1, 1, "SELEC * FROM account"},
+		{`SELECT * FROM account a b`, "b", 25, 1, 25, "...CT * FROM account a b"},
+		{`SELECT * FROM account WHERE`, ";", 28, 1, 28, "...* FROM account WHERE"},
+		{`SELECT * FROM account WHERE id = 1 AND`, ";", 39, 1, 39, "...unt WHERE id = 1 AND"},
+		{`CREATE SEQUENZ account_id`, "SEQUENZ", 8, 1, 8, "CREATE SEQUENZ account_id"},
+		{`CREATE FOO account`, "FOO", 8, 1, 8, "CREATE FOO account"},
+	}
+	for _, e := range expected {
+		_, err := ParseInstruction(e.query)
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected syntax error for '%s', got %v", e.query, err)
+		}
+		if se.Token != e.token || se.Position != e.position || se.Line != e.line || se.Column != e.column || se.Snippet != e.snippet {
+			t.Fatalf("unexpected syntax error for '%s': %+v", e.query, se)
+		}
+		if !strings.HasSuffix(se.Error(), fmt.Sprintf(" at line %d, column %d: %s", e.line, e.column, e.snippet)) {
+			t.Fatalf("expected error to be located, got %s", se)
+		}
+	}
+}
+
 func TestInsertMinimal(t *testing.T) {
 	query := `INSERT INTO account ('email', 'password', 'age') VALUES ('foo@bar.com', 'tititoto', '4')`
 	parse(query, 1, t)
@@ -211,6 +845,19 @@ func TestCreateDefaultNumerical(t *testing.T) {
 	parse(query, 1, t)
 }
 
+func TestCreateDefaultExpression(t *testing.T) {
+	queries := []string{
+		`CREATE TABLE t (id INT, created TIMESTAMP DEFAULT CURRENT_TIMESTAMP, status TEXT DEFAULT 'new')`,
+		`CREATE TABLE t (id INT, balance BIGINT DEFAULT -1 NOT NULL, note TEXT DEFAULT NULL)`,
+		`CREATE TABLE t (id INT, code TEXT DEFAULT upper('abc') UNIQUE, total BIGINT DEFAULT (2 * 50))`,
+		`CREATE TABLE t (id INT, created TIMESTAMP DEFAULT now(), status TEXT DEFAULT 'new', flag BOOLEAN DEFAULT true)`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
 func TestCreateWithTimestamp(t *testing.T) {
 	query := `CREATE TABLE IF NOT EXISTS "pokemon" (id BIGSERIAL PRIMARY KEY, name TEXT, type TEXT, seen TIMESTAMP WITH TIME ZONE)`
 
@@ -229,10 +876,31 @@ func TestCreateNumberInNames(t *testing.T) {
 	parse(query, 1, t)
 }
 
+func TestKeywordsAsNames(t *testing.T) {
+	queries := []string{
+		`CREATE TABLE ev (id INT, end INT, else TEXT)`,
+		`INSERT INTO ev (id, end) VALUES (1, 2)`,
+		`SELECT ev.end, CASE WHEN end > 1 THEN else END FROM ev WHERE end = 2 ORDER BY end`,
+		`UPDATE ev SET end = 3 WHERE when = 2`,
+		`CREATE TABLE lr (id INT, left INT, right INT, full TEXT)`,
+		`SELECT left, lr.right FROM lr LEFT OUTER JOIN ev ON lr.left = ev.id WHERE right > 1 ORDER BY full`,
+	}
+
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
+}
+
 func TestOffset(t *testing.T) {
-	query := `SELECT * FROM mytable LIMIT 1 OFFSET 0`
+	queries := []string{
+		`SELECT * FROM mytable LIMIT 1 OFFSET 0`,
+		`SELECT * FROM mytable OFFSET 2 LIMIT 1`,
+		`SELECT * FROM mytable LIMIT 2, 1`,
+	}
 
-	parse(query, 1, t)
+	for _, q := range queries {
+		parse(q, 1, t)
+	}
 }
 
 func TestUnique(t *testing.T) {
@@ -240,6 +908,8 @@ func TestUnique(t *testing.T) {
 		`CREATE TABLE pokemon (id BIGSERIAL, name TEXT UNIQUE NOT NULL)`,
 		`CREATE TABLE pokemon (id BIGSERIAL, name TEXT NOT NULL UNIQUE)`,
 		`CREATE TABLE pokemon_name (id BIGINT, name VARCHAR(255) PRIMARY KEY NOT NULL UNIQUE)`,
+		`CREATE TABLE users (id BIGSERIAL, first_name TEXT, last_name TEXT, UNIQUE (first_name, last_name))`,
+		`CREATE TABLE users (id BIGSERIAL, email TEXT, UNIQUE (email), name TEXT)`,
 	}
 
 	for _, q := range queries {
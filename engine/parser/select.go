@@ -2,10 +2,289 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 )
 
+// parseSelect parses a SELECT statement, possibly combined with following ones.
+// INTERSECT binds more tightly than UNION and EXCEPT.
+// SELECT a FROM t1 UNION SELECT a FROM t2 ORDER BY a
+// WITH recent AS (SELECT * FROM event WHERE ts > 10) SELECT * FROM recent
 func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 	i := &Instruction{}
+
+	// Common table expressions are only visible from the statement they introduce
+	if p.is(WithToken) {
+		ctes := p.ctes
+		defer func() { p.ctes = ctes }()
+		if err := p.parseWith(); err != nil {
+			return nil, err
+		}
+	}
+
+	decl, err := p.parseIntersect()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(UnionToken, ExceptToken) {
+		decl, err = p.parseSetOperation(decl, p.parseIntersect)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.Decls = append(i.Decls, decl)
+
+	if decl.Token == SelectToken {
+		return i, nil
+	}
+
+	// ORDER BY, LIMIT and OFFSET after last SELECT apply to combined result
+	last := decl
+	for last.Token != SelectToken {
+		last = last.Decl[1]
+	}
+	var kept []*Decl
+	for _, d := range last.Decl {
+		switch d.Token {
+		case OrderToken, LimitToken, OffsetToken:
+			decl.Add(d)
+		default:
+			kept = append(kept, d)
+		}
+	}
+	last.Decl = kept
+
+	return i, nil
+}
+
+/*
+|-> recursive
+	|-> tree
+		|-> id
+	|-> union
+		|-> SELECT
+			|-> id
+			|-> FROM
+				|-> category
+			|-> WHERE
+				|-> parent_id
+					|-> IS
+					|-> NULL
+		|-> SELECT
+			|-> c
+				|-> id
+			|-> FROM
+				|-> category
+					|-> as
+						|-> c
+			|-> JOIN
+				|-> tree
+					|-> recursive
+				|-> ...
+		|-> all
+*/
+// parseWith parses common table expressions, each one being visible from the following ones.
+// With RECURSIVE, an expression of the form non recursive term UNION [ALL] recursive term
+// is visible from its recursive term, where it is the working table of the recursion.
+// Its columns may be named after it, instead of after the non recursive term.
+// WITH recent AS (SELECT * FROM event WHERE ts > 10), clicks AS (SELECT * FROM recent WHERE type = 'click')
+// WITH RECURSIVE tree AS (SELECT id FROM category WHERE parent_id IS NULL UNION ALL SELECT c.id FROM category c JOIN tree ON c.parent_id = tree.id)
+// WITH RECURSIVE tree(id) AS (SELECT id FROM category WHERE parent_id IS NULL UNION ALL SELECT c.id FROM category c JOIN tree ON c.parent_id = tree.id)
+func (p *parser) parseWith() error {
+	if _, err := p.consumeToken(WithToken); err != nil {
+		return err
+	}
+
+	recursive := false
+	if _, err := p.isNext(AsToken); p.is(StringToken) && strings.ToLower(p.cur().Lexeme) == "recursive" && err != nil {
+		recursive = true
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	ctes := make(map[string]*Decl, len(p.ctes))
+	for name, decl := range p.ctes {
+		ctes[name] = decl
+	}
+	defined := make(map[string]bool)
+
+	for {
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return err
+		}
+		// Recursive query may name its columns
+		if recursive && p.is(BracketOpeningToken) {
+			if err := p.next(); err != nil {
+				return err
+			}
+			for {
+				columnDecl, err := p.parseQuotedToken()
+				if err != nil {
+					return err
+				}
+				nameDecl.Add(columnDecl)
+
+				if p.is(BracketClosingToken) {
+					break
+				}
+				if _, err := p.consumeToken(CommaToken); err != nil {
+					return err
+				}
+			}
+			if _, err := p.consumeToken(BracketClosingToken); err != nil {
+				return err
+			}
+		}
+		if _, err := p.consumeToken(AsToken); err != nil {
+			return err
+		}
+		if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+			return err
+		}
+		if !p.is(SelectToken, WithToken) {
+			return p.syntaxError()
+		}
+		if recursive {
+			ctes[nameDecl.Lexeme] = NewDecl(Token{Token: RecursiveToken, Lexeme: "recursive"})
+			p.ctes = ctes
+		}
+		i, err := p.parseSelect(p.tokens)
+		if err != nil {
+			return err
+		}
+		if _, err := p.consumeToken(BracketClosingToken); err != nil {
+			return err
+		}
+		if recursive && i.Decls[0].Token == UnionToken {
+			recursiveDecl := NewDecl(Token{Token: RecursiveToken, Lexeme: "recursive"})
+			recursiveDecl.Add(nameDecl)
+			recursiveDecl.Add(i.Decls[0])
+			i.Decls[0] = recursiveDecl
+		} else if len(nameDecl.Decl) > 0 {
+			return fmt.Errorf("columns of WITH query \"%s\" can only be named if it has the form non-recursive-term UNION [ALL] recursive-term", nameDecl.Lexeme)
+		}
+
+		if defined[nameDecl.Lexeme] {
+			return fmt.Errorf("WITH query name \"%s\" specified more than once", nameDecl.Lexeme)
+		}
+		defined[nameDecl.Lexeme] = true
+		ctes[nameDecl.Lexeme] = i.Decls[0]
+		p.ctes = ctes
+
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	if !p.is(SelectToken) {
+		return p.syntaxError()
+	}
+
+	return nil
+}
+
+// cteReference returns the query of the common table expression referenced by tableDecl,
+// named after its alias or else the expression name, or nil if tableDecl is a table.
+func (p *parser) cteReference(tableDecl *Decl) *Decl {
+	cte, ok := p.ctes[tableDecl.Lexeme]
+	if !ok || len(tableDecl.Decl) > 0 && tableDecl.Decl[0].Token != AsToken {
+		return nil
+	}
+
+	// Working table of a recursive query, referenced from the query itself
+	if cte.Token == RecursiveToken && len(cte.Decl) == 0 {
+		tableDecl.Add(NewDecl(Token{Token: RecursiveToken, Lexeme: "recursive"}))
+		return tableDecl
+	}
+
+	// Query is shared by every reference, and aliased in each one
+	ref := &Decl{Token: cte.Token, Lexeme: cte.Lexeme}
+	ref.Decl = append(ref.Decl, cte.Decl...)
+	if len(tableDecl.Decl) > 0 {
+		ref.Add(tableDecl.Decl[0])
+	} else {
+		asDecl := NewDecl(Token{Token: AsToken, Lexeme: "as"})
+		asDecl.Add(NewDecl(Token{Token: StringToken, Lexeme: tableDecl.Lexeme}))
+		ref.Add(asDecl)
+	}
+
+	return ref
+}
+
+// parseIntersect parses a SELECT statement, possibly intersected with following ones
+func (p *parser) parseIntersect() (*Decl, error) {
+	simpleSelect := func() (*Decl, error) {
+		if !p.is(SelectToken) {
+			return nil, p.syntaxError()
+		}
+		i, err := p.parseSimpleSelect(p.tokens)
+		if err != nil {
+			return nil, err
+		}
+		return i.Decls[0], nil
+	}
+
+	decl, err := simpleSelect()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(IntersectToken) {
+		decl, err = p.parseSetOperation(decl, simpleSelect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decl, nil
+}
+
+/*
+|-> union
+	|-> SELECT
+		|-> a
+		|-> FROM
+			|-> t1
+	|-> SELECT
+		|-> a
+		|-> FROM
+			|-> t2
+	|-> all
+*/
+// parseSetOperation parses UNION, INTERSECT or EXCEPT keyword, and the right operand
+// combined with left one
+func (p *parser) parseSetOperation(left *Decl, parseRight func() (*Decl, error)) (*Decl, error) {
+	setDecl, err := p.consumeToken(UnionToken, IntersectToken, ExceptToken)
+	if err != nil {
+		return nil, err
+	}
+	setDecl.Add(left)
+
+	var allDecl *Decl
+	if p.is(AllToken) {
+		allDecl, _ = p.consumeToken(AllToken)
+	}
+
+	right, err := parseRight()
+	if err != nil {
+		return nil, err
+	}
+	setDecl.Add(right)
+
+	if allDecl != nil {
+		setDecl.Add(allDecl)
+	}
+
+	return setDecl, nil
+}
+
+func (p *parser) parseSimpleSelect(tokens []Token) (*Instruction, error) {
+	i := &Instruction{}
 	var err error
 
 	// Create select decl
@@ -20,18 +299,42 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 		return nil, fmt.Errorf("SELECT token must be followed by attributes to select")
 	}
 
+	// DISTINCT
+	if p.is(DistinctToken) {
+		distinctDecl, err := p.consumeToken(DistinctToken)
+		if err != nil {
+			return nil, err
+		}
+		selectDecl.Add(distinctDecl)
+	}
+
 	for {
-		if p.is(CountToken) {
+		if p.is(CountToken, SumToken, AvgToken, MinToken, MaxToken, GroupConcatToken, StringAggToken) {
 			attrDecl, err := p.parseBuiltinFunc()
 			if err != nil {
 				return nil, err
 			}
+			if err := p.parseAlias(attrDecl); err != nil {
+				return nil, err
+			}
 			selectDecl.Add(attrDecl)
+		} else if _, err := p.isNext(SelectToken); p.is(BracketOpeningToken) && err == nil {
+			subqueryDecl, err := p.parseScalarSubquery()
+			if err != nil {
+				return nil, err
+			}
+			selectDecl.Add(subqueryDecl)
 		} else {
-			attrDecl, err := p.parseAttribute()
+			// Attribute, star or expression
+			attrDecl, err := p.parseExpression()
 			if err != nil {
 				return nil, err
 			}
+			if attrDecl.Token != StarToken {
+				if err := p.parseAlias(attrDecl); err != nil {
+					return nil, err
+				}
+			}
 			selectDecl.Add(attrDecl)
 		}
 
@@ -47,7 +350,7 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 
 	// Must be from now
 	if tokens[p.index].Token != FromToken {
-		return nil, fmt.Errorf("Syntax error near %v\n", tokens[p.index])
+		return nil, p.syntaxError()
 	}
 	fromDecl := NewDecl(tokens[p.index])
 	selectDecl.Add(fromDecl)
@@ -56,9 +359,9 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 	for {
 		// string
 		if err = p.next(); err != nil {
-			return nil, fmt.Errorf("Unexpected end. Syntax error near %v\n", tokens[p.index])
+			return nil, p.syntaxError()
 		}
-		tableNameDecl, err := p.parseAttribute()
+		tableNameDecl, err := p.parseTableReference()
 		if err != nil {
 			return nil, err
 		}
@@ -76,7 +379,7 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 	}
 
 	// JOIN OR ...?
-	for p.is(JoinToken) {
+	for p.is(JoinToken, InnerToken, LeftToken, RightToken, FullToken) {
 		joinDecl, err := p.parseJoin()
 		if err != nil {
 			return nil, err
@@ -93,10 +396,26 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 				return nil, err
 			}
 			hazWhereClause = true
+		case GroupToken:
+			if hazWhereClause == false {
+				// WHERE clause is implicit
+				addImplicitWhereAll(selectDecl)
+				hazWhereClause = true
+			}
+			err := p.parseGroupBy(selectDecl)
+			if err != nil {
+				return nil, err
+			}
+		case HavingToken:
+			err := p.parseHaving(selectDecl)
+			if err != nil {
+				return nil, err
+			}
 		case OrderToken:
 			if hazWhereClause == false {
 				// WHERE clause is implicit
 				addImplicitWhereAll(selectDecl)
+				hazWhereClause = true
 			}
 			err := p.parseOrderBy(selectDecl)
 			if err != nil {
@@ -112,6 +431,20 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 			if err != nil {
 				return nil, err
 			}
+			// MySQL form: LIMIT offset, count
+			if p.is(CommaToken) {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				countDecl, err := p.consumeToken(NumberToken)
+				if err != nil {
+					return nil, err
+				}
+				offsetDecl := NewDecl(Token{Token: OffsetToken, Lexeme: "offset"})
+				offsetDecl.Add(numDecl)
+				selectDecl.Add(offsetDecl)
+				numDecl = countDecl
+			}
 			limitDecl.Add(numDecl)
 		case OffsetToken:
 			offsetDecl, err := p.consumeToken(OffsetToken)
@@ -135,6 +468,81 @@ func (p *parser) parseSelect(tokens []Token) (*Instruction, error) {
 	}
 }
 
+/*
+|-> SELECT
+	|-> COUNT
+		|-> *
+	|-> FROM
+		|-> orders
+	|-> WHERE
+		|-> user_id
+			|-> =
+			|-> id
+				|-> u
+	|-> as
+		|-> order_count
+*/
+// parseScalarSubquery parses a subquery in select list, with its optional alias
+// (SELECT COUNT(*) FROM orders WHERE user_id = u.id) AS order_count
+func (p *parser) parseScalarSubquery() (*Decl, error) {
+	if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+		return nil, err
+	}
+
+	i, err := p.parseSelect(p.tokens)
+	if err != nil {
+		return nil, err
+	}
+	subqueryDecl := i.Decls[0]
+
+	if _, err := p.consumeToken(BracketClosingToken); err != nil {
+		return nil, err
+	}
+
+	if err := p.parseAlias(subqueryDecl); err != nil {
+		return nil, err
+	}
+
+	return subqueryDecl, nil
+}
+
+/*
+|-> group
+	|-> country
+	|-> city
+		|-> address
+*/
+func (p *parser) parseGroupBy(selectDecl *Decl) error {
+	groupDecl, err := p.consumeToken(GroupToken)
+	if err != nil {
+		return err
+	}
+	selectDecl.Add(groupDecl)
+
+	_, err = p.consumeToken(ByToken)
+	if err != nil {
+		return err
+	}
+
+	// list of attributes
+	for {
+		attrDecl, err := p.parseAttribute()
+		if err != nil {
+			return err
+		}
+		groupDecl.Add(attrDecl)
+
+		if !p.is(CommaToken) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func addImplicitWhereAll(decl *Decl) {
 
 	whereDecl := &Decl{
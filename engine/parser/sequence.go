@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> sequence
+	|-> order_id
+	|-> start
+		|-> 100
+	|-> increment
+		|-> -1
+*/
+// parseSequence parses a sequence definition, following CREATE token. START and
+// INCREMENT may be given in any order, each with its optional WITH or BY.
+// SEQUENCE order_id START WITH 100 INCREMENT BY -1
+func (p *parser) parseSequence() (*Decl, error) {
+	if !p.isWord("sequence") {
+		return nil, p.syntaxError()
+	}
+	sequenceDecl := &Decl{Token: SequenceToken, Lexeme: "sequence"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	nameDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	sequenceDecl.Add(nameDecl)
+
+	for p.is(StringToken) {
+		option := strings.ToLower(p.cur().Lexeme)
+		var keyword int
+		switch option {
+		case "start":
+			keyword = WithToken
+		case "increment":
+			keyword = ByToken
+		default:
+			return nil, p.syntaxError()
+		}
+		optionDecl := &Decl{Token: StringToken, Lexeme: option}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.is(keyword) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		sign := ""
+		if p.is(MinusToken) {
+			sign = "-"
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		valueDecl, err := p.consumeToken(NumberToken)
+		if err != nil {
+			return nil, err
+		}
+		valueDecl.Lexeme = sign + valueDecl.Lexeme
+		optionDecl.Add(valueDecl)
+		sequenceDecl.Add(optionDecl)
+	}
+
+	return sequenceDecl, nil
+}
+
+// isWord returns true if current token is given word, which is not a keyword
+// so it can still be a name, like SEQUENCE or TRIGGER
+func (p *parser) isWord(word string) bool {
+	return p.is(StringToken) && strings.EqualFold(p.cur().Lexeme, word)
+}
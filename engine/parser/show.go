@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> show
+	|-> columns
+		|-> users
+*/
+// parseShow parses a listing of tables, or of the columns of a table
+// SHOW TABLES
+// SHOW COLUMNS FROM users
+func (p *parser) parseShow() (*Instruction, error) {
+	i := &Instruction{}
+
+	showDecl, err := p.consumeToken(ShowToken)
+	if err != nil {
+		return nil, err
+	}
+	i.Decls = append(i.Decls, showDecl)
+
+	// TABLES and COLUMNS are not keywords, so they can still name attributes
+	if !p.is(StringToken) {
+		return nil, p.syntaxError()
+	}
+	whatDecl, err := p.consumeToken(StringToken)
+	if err != nil {
+		return nil, err
+	}
+	whatDecl.Lexeme = strings.ToLower(whatDecl.Lexeme)
+	showDecl.Add(whatDecl)
+
+	switch whatDecl.Lexeme {
+	case "tables":
+		return i, nil
+	case "columns":
+		if _, err := p.consumeToken(FromToken, InToken); err != nil {
+			return nil, err
+		}
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		whatDecl.Add(nameDecl)
+		return i, nil
+	}
+
+	return nil, p.syntaxError()
+}
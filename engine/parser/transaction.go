@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> rollback
+	|-> sp1
+*/
+// parseTransaction parses a transaction control statement, TRANSACTION and SAVEPOINT
+// keywords being optional where the standard allows it
+// BEGIN TRANSACTION
+// BEGIN READ ONLY
+// COMMIT
+// ROLLBACK
+// SAVEPOINT sp1
+// ROLLBACK TO SAVEPOINT sp1
+// RELEASE SAVEPOINT sp1
+func (p *parser) parseTransaction() (*Instruction, error) {
+	i := &Instruction{}
+
+	txDecl, err := p.consumeToken(BeginToken, CommitToken, RollbackToken, SavepointToken, ReleaseToken)
+	if err != nil {
+		return nil, err
+	}
+	i.Decls = append(i.Decls, txDecl)
+
+	switch txDecl.Token {
+	case BeginToken, CommitToken:
+		if p.is(TransactionToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if txDecl.Token == BeginToken && p.isWord("read") {
+			modeDecl, err := p.parseAccessMode()
+			if err != nil {
+				return nil, err
+			}
+			txDecl.Add(modeDecl)
+		}
+	case RollbackToken:
+		if p.is(TransactionToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.is(ToToken) {
+			return i, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		fallthrough
+	case ReleaseToken:
+		if p.is(SavepointToken) {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		fallthrough
+	case SavepointToken:
+		nameDecl, err := p.parseQuotedToken()
+		if err != nil {
+			return nil, err
+		}
+		txDecl.Add(nameDecl)
+	}
+
+	return i, nil
+}
+
+/*
+|-> read
+	|-> only
+*/
+// parseAccessMode parses the access mode of a transaction
+// READ ONLY
+// READ WRITE
+func (p *parser) parseAccessMode() (*Decl, error) {
+	if !p.isWord("read") {
+		return nil, p.syntaxError()
+	}
+	modeDecl := &Decl{Token: StringToken, Lexeme: "read"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if !p.isWord("only") && !p.isWord("write") {
+		return nil, p.syntaxError()
+	}
+	modeDecl.Add(&Decl{Token: StringToken, Lexeme: strings.ToLower(p.cur().Lexeme)})
+	p.next()
+
+	return modeDecl, nil
+}
+
+/*
+|-> set
+	|-> session
+	|-> read
+		|-> only
+*/
+// parseSet parses the default access mode of transactions of a session
+// SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY
+func (p *parser) parseSet() (*Instruction, error) {
+	i := &Instruction{}
+
+	setDecl, err := p.consumeToken(SetToken)
+	if err != nil {
+		return nil, err
+	}
+	i.Decls = append(i.Decls, setDecl)
+
+	if !p.isWord("session") {
+		return nil, p.syntaxError()
+	}
+	setDecl.Add(&Decl{Token: StringToken, Lexeme: "session"})
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if !p.isWord("characteristics") {
+		return nil, p.syntaxError()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if _, err := p.consumeToken(AsToken); err != nil {
+		return nil, err
+	}
+	if _, err := p.consumeToken(TransactionToken); err != nil {
+		return nil, err
+	}
+
+	modeDecl, err := p.parseAccessMode()
+	if err != nil {
+		return nil, err
+	}
+	setDecl.Add(modeDecl)
+
+	return i, nil
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"strings"
+)
+
+/*
+|-> trigger
+	|-> check_total
+	|-> before
+	|-> insert
+	|-> on
+		|-> orders
+	|-> when
+		|-> total
+			|-> new
+			|-> <
+			|-> 0
+	|-> set
+		|-> =
+			|-> total
+				|-> new
+			|-> 0
+	|-> WHEN (NEW.total < 0) SET NEW.total = 0
+*/
+// parseTrigger parses a row trigger definition, following CREATE token. Its action is either
+// SET assignments of NEW row values, or RAISE of an error, optionally only WHEN a condition holds.
+// The text of condition and action follows them, so that the trigger can be written back.
+// TRIGGER check_total BEFORE INSERT ON orders FOR EACH ROW WHEN (NEW.total < 0) SET NEW.total = 0
+// TRIGGER check_total BEFORE UPDATE ON orders FOR EACH ROW WHEN (NEW.total < OLD.total) RAISE 'total cannot decrease'
+func (p *parser) parseTrigger() (*Decl, error) {
+	if !p.isWord("trigger") {
+		return nil, p.syntaxError()
+	}
+	triggerDecl := &Decl{Token: TriggerToken, Lexeme: "trigger"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	nameDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	triggerDecl.Add(nameDecl)
+
+	if !p.isWord("before") && !p.isWord("after") {
+		return nil, p.syntaxError()
+	}
+	triggerDecl.Add(&Decl{Token: StringToken, Lexeme: strings.ToLower(p.cur().Lexeme)})
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	eventDecl, err := p.consumeToken(InsertToken, UpdateToken, DeleteToken)
+	if err != nil {
+		return nil, err
+	}
+	triggerDecl.Add(eventDecl)
+
+	onDecl, err := p.consumeToken(OnToken)
+	if err != nil {
+		return nil, err
+	}
+	triggerDecl.Add(onDecl)
+	tableDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	onDecl.Add(tableDecl)
+
+	// FOR EACH ROW, which is the only kind of trigger
+	if _, err := p.consumeToken(ForToken); err != nil {
+		return nil, err
+	}
+	for _, word := range []string{"each", "row"} {
+		if !p.isWord(word) {
+			return nil, p.syntaxError()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	start := p.cur().Pos
+
+	if p.is(WhenToken) {
+		whenDecl, err := p.consumeToken(WhenToken)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeToken(BracketOpeningToken); err != nil {
+			return nil, err
+		}
+		if err := p.parseConditions(whenDecl); err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeToken(BracketClosingToken); err != nil {
+			return nil, err
+		}
+		rowReferences(whenDecl)
+		triggerDecl.Add(whenDecl)
+	}
+
+	switch {
+	case p.is(SetToken):
+		setDecl, err := p.parseTriggerSet()
+		if err != nil {
+			return nil, err
+		}
+		triggerDecl.Add(setDecl)
+	case p.isWord("raise"):
+		raiseDecl := &Decl{Token: RaiseToken, Lexeme: "raise"}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.isWord("exception") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.is(SimpleQuoteToken) {
+			return nil, p.syntaxError()
+		}
+		messageDecl, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		messageDecl.Token = QuotedStringToken
+		raiseDecl.Add(messageDecl)
+		triggerDecl.Add(raiseDecl)
+	default:
+		return nil, p.syntaxError()
+	}
+
+	end := p.cur().Pos
+	if end > len(p.source) {
+		end = len(p.source)
+	}
+	if start < end {
+		triggerDecl.Add(&Decl{Token: QuotedStringToken, Lexeme: strings.TrimSpace(string(p.source[start:end]))})
+	}
+
+	return triggerDecl, nil
+}
+
+// parseTriggerSet parses assignments of a trigger action
+// SET NEW.total = NEW.price * NEW.quantity, NEW.updated = true
+func (p *parser) parseTriggerSet() (*Decl, error) {
+	setDecl, err := p.consumeToken(SetToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		attributeDecl, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		assignDecl, err := p.consumeToken(EqualityToken)
+		if err != nil {
+			return nil, err
+		}
+		valueDecl, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		assignDecl.Add(attributeDecl)
+		assignDecl.Add(valueDecl)
+		rowReferences(assignDecl)
+		setDecl.Add(assignDecl)
+
+		if !p.is(CommaToken) {
+			return setDecl, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rowReferences names NEW and OLD pseudo-tables in lowercase in attributes
+// qualified with them, whichever case they were written with
+func rowReferences(decl *Decl) {
+	pseudoTableReferences(decl, "new")
+	pseudoTableReferences(decl, "old")
+}
@@ -1,5 +1,12 @@
 package parser
 
+/*
+|-> truncate
+	|-> events
+	|-> cascade
+*/
+// parseTruncate parses a table truncation, TABLE keyword being optional
+// TRUNCATE TABLE events CASCADE
 func (p *parser) parseTruncate() (*Instruction, error) {
 	i := &Instruction{}
 
@@ -10,6 +17,12 @@ func (p *parser) parseTruncate() (*Instruction, error) {
 	}
 	i.Decls = append(i.Decls, trDecl)
 
+	if p.is(TableToken) {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Should be a table name
 	nameDecl, err := p.parseQuotedToken()
 	if err != nil {
@@ -17,5 +30,13 @@ func (p *parser) parseTruncate() (*Instruction, error) {
 	}
 	trDecl.Add(nameDecl)
 
+	if p.is(CascadeToken) {
+		cascadeDecl, err := p.consumeToken(CascadeToken)
+		if err != nil {
+			return nil, err
+		}
+		trDecl.Add(cascadeDecl)
+	}
+
 	return i, nil
 }
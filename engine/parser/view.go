@@ -0,0 +1,43 @@
+package parser
+
+/*
+|-> view
+	|-> active_users
+	|-> SELECT
+		|-> *
+		|-> FROM
+			|-> users
+		|-> WHERE
+			|-> active
+				|-> =
+				|-> true
+*/
+// parseView parses a view definition, following CREATE token
+// VIEW active_users AS SELECT * FROM users WHERE active = true
+func (p *parser) parseView() (*Decl, error) {
+	viewDecl, err := p.consumeToken(ViewToken)
+	if err != nil {
+		return nil, err
+	}
+
+	nameDecl, err := p.parseQuotedToken()
+	if err != nil {
+		return nil, err
+	}
+	viewDecl.Add(nameDecl)
+
+	if _, err := p.consumeToken(AsToken); err != nil {
+		return nil, err
+	}
+	if !p.is(SelectToken, WithToken) {
+		return nil, p.syntaxError()
+	}
+
+	i, err := p.parseSelect(p.tokens)
+	if err != nil {
+		return nil, err
+	}
+	viewDecl.Add(i.Decls[0])
+
+	return viewDecl, nil
+}
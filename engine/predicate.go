@@ -5,10 +5,29 @@ import (
 )
 
 // PredicateLinker referes to AND and OR operators.
+// Eval returns true if predicate is true for given row,
+// that is neither false nor unknown.
 type PredicateLinker interface {
 	Eval(v virtualRow) (bool, error)
 }
 
+// ternaryPredicate is a predicate whose result may be unknown, like a comparison
+// with NULL. An unknown result is not true, and remains unknown once negated.
+type ternaryPredicate interface {
+	ternary(v virtualRow) (result bool, known bool, err error)
+}
+
+// evalTernary evaluates predicate with SQL three-valued logic.
+// Predicates whose result cannot be unknown, like EXISTS, are evaluated as usual.
+func evalTernary(p PredicateLinker, v virtualRow) (bool, bool, error) {
+	if t, ok := p.(ternaryPredicate); ok {
+		return t.ternary(v)
+	}
+
+	ok, err := p.Eval(v)
+	return ok, true, err
+}
+
 type andOperator struct {
 	pred []PredicateLinker
 }
@@ -32,6 +51,27 @@ func (o *andOperator) Eval(v virtualRow) (bool, error) {
 	return true, nil
 }
 
+// ternary returns false if a predicate is false, or unknown if none is false but one is unknown
+func (o *andOperator) ternary(v virtualRow) (bool, bool, error) {
+	known := true
+
+	for i := range o.pred {
+		ok, k, err := evalTernary(o.pred[i], v)
+		if err != nil {
+			return false, false, err
+		}
+		if !k {
+			known = false
+			continue
+		}
+		if !ok {
+			return false, true, nil
+		}
+	}
+
+	return known, known, nil
+}
+
 type orOperator struct {
 	pred []PredicateLinker
 }
@@ -55,6 +95,46 @@ func (o *orOperator) Eval(v virtualRow) (bool, error) {
 	return false, nil
 }
 
+// ternary returns true if a predicate is true, or unknown if none is true but one is unknown
+func (o *orOperator) ternary(v virtualRow) (bool, bool, error) {
+	known := true
+
+	for i := range o.pred {
+		ok, k, err := evalTernary(o.pred[i], v)
+		if err != nil {
+			return false, false, err
+		}
+		if !k {
+			known = false
+			continue
+		}
+		if ok {
+			return true, true, nil
+		}
+	}
+
+	return false, known, nil
+}
+
+// notOperator negates a predicate, an unknown result staying unknown
+type notOperator struct {
+	pred PredicateLinker
+}
+
+func (o *notOperator) Eval(v virtualRow) (bool, error) {
+	ok, known, err := o.ternary(v)
+	return ok && known, err
+}
+
+func (o *notOperator) ternary(v virtualRow) (bool, bool, error) {
+	ok, known, err := evalTernary(o.pred, v)
+	if err != nil {
+		return false, false, err
+	}
+
+	return !ok, known, nil
+}
+
 // TruePredicate is a predicate wich return always true
 var TruePredicate = Predicate{
 	True: true,
@@ -67,6 +147,7 @@ type Value struct {
 	lexeme   string
 	constant bool
 	table    string
+	expr     expression
 }
 
 // Predicate evaluate if a condition is valid with 2 values and an operator on this 2 values
@@ -75,6 +156,11 @@ type Predicate struct {
 	Operator   Operator
 	RightValue Value
 	True       bool
+	// equality is set if Operator is equality, so that an index can be used
+	equality bool
+	// known returns false if result of Operator is unknown. If not set,
+	// result is unknown if it is false because an operand is NULL.
+	known func(leftValue Value, rightValue Value) bool
 }
 
 func (p Predicate) String() string {
@@ -100,42 +186,70 @@ func (p Predicate) String() string {
 
 // Eval fetches operand from virtual row and run operator
 func (p *Predicate) Eval(row virtualRow) (bool, error) {
-
-	if p.True {
-		return true, nil
-	}
-
-	// Find left attribute
-	left := p.LeftValue.table + "." + p.LeftValue.lexeme
-	val, ok := row[left]
-	if !ok {
-		return false, fmt.Errorf("Attribute [%s] not found in row", left)
-	}
-	p.LeftValue.v = val.v
-
-	return p.Operator(p.LeftValue, p.RightValue), nil
+	ok, known, err := p.ternary(row)
+	return ok && known, err
 }
 
-// Evaluate is deprecated (see Eval). It calls operators and use tuple as operand
-// TODO: Delete that
-func (p *Predicate) Evaluate(t *Tuple, table *Table) (bool, error) {
+// ternary fetches operand from virtual row and run operator, whose result is
+// unknown if compared values are NULL
+func (p *Predicate) ternary(row virtualRow) (bool, bool, error) {
 
 	if p.True {
-		return true, nil
+		return true, true, nil
 	}
 
-	// Find left
-	var i = 0
-	lenTable := len(table.attributes)
-	for i = 0; i < lenTable; i++ {
-		if table.attributes[i].name == p.LeftValue.lexeme {
-			break
+	// Left value is either computed, or an attribute, aggregates having no table
+	if p.LeftValue.expr != nil {
+		v, err := p.LeftValue.expr.eval(row)
+		if err != nil {
+			return false, false, err
+		}
+		p.LeftValue.v = v
+	} else {
+		left := p.LeftValue.lexeme
+		if p.LeftValue.table != "" {
+			left = p.LeftValue.table + "." + p.LeftValue.lexeme
+		}
+		val, ok := row[left]
+		if !ok {
+			return false, false, fmt.Errorf("Attribute [%s] not found in row", left)
 		}
+		p.LeftValue.v = val.v
 	}
-	if i == lenTable {
-		return false, fmt.Errorf("Attribute [%s] not found in table [%s]", p.LeftValue.lexeme, table.name)
+
+	// Right value may be computed, or an attribute as well
+	right := p.RightValue
+	rightNull := false
+	if right.expr != nil {
+		v, err := right.expr.eval(row)
+		if err != nil {
+			return false, false, err
+		}
+		rightNull = v == nil
+		right = Value{
+			v:      v,
+			valid:  !rightNull,
+			lexeme: fmt.Sprintf("%v", v),
+		}
+	} else if right.table != "" {
+		key := right.table + "." + right.lexeme
+		val, ok := row[key]
+		if !ok {
+			return false, false, fmt.Errorf("Attribute [%s] not found in row", key)
+		}
+		rightNull = val.v == nil
+		right = Value{
+			v:      val.v,
+			valid:  !rightNull,
+			lexeme: fmt.Sprintf("%v", val.v),
+			table:  val.table,
+		}
+	}
+
+	result := p.Operator(p.LeftValue, right)
+	if p.known != nil {
+		return result, p.known(p.LeftValue, right), nil
 	}
 
-	p.LeftValue.v = t.Values[i]
-	return p.Operator(p.LeftValue, p.RightValue), nil
+	return result, result || (p.LeftValue.v != nil && !rightNull), nil
 }
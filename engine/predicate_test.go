@@ -0,0 +1,242 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestPredicatePrecedence(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestPredicatePrecedence")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t (id INT, a INT, b INT, c INT)`,
+		`INSERT INTO t (id, a, b, c) VALUES (1, 1, 0, 0)`,
+		`INSERT INTO t (id, a, b, c) VALUES (2, 0, 2, 0)`,
+		`INSERT INTO t (id, a, b, c) VALUES (3, 0, 2, 3)`,
+		`INSERT INTO t (id, a, b, c) VALUES (4, 1, 2, 3)`,
+		`INSERT INTO t (id, a, b, c) VALUES (5, 0, 0, 3)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	ids := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, id)
+		}
+		return strings.Join(res, ",")
+	}
+
+	// AND binds tighter than OR, and NOT tighter than AND
+	queries := map[string]string{
+		`SELECT id FROM t WHERE a = 1 OR b = 2 AND c = 3 ORDER BY id`:                                    "1,3,4",
+		`SELECT id FROM t WHERE b = 2 AND c = 3 OR a = 1 ORDER BY id`:                                    "1,3,4",
+		`SELECT id FROM t WHERE (a = 1 OR b = 2) AND c = 3 ORDER BY id`:                                  "3,4",
+		`SELECT id FROM t WHERE c = 3 AND (a = 1 OR b = 2) ORDER BY id`:                                  "3,4",
+		`SELECT id FROM t WHERE a = 0 AND b = 0 OR a = 1 AND b = 2 OR id = 2 ORDER BY id`:                "2,4,5",
+		`SELECT id FROM t WHERE NOT a = 1 AND b = 2 ORDER BY id`:                                         "2,3",
+		`SELECT id FROM t WHERE NOT (a = 1 OR b = 2) ORDER BY id`:                                        "5",
+		`SELECT id FROM t WHERE NOT (a = 1 AND b = 2) AND c = 3 ORDER BY id`:                             "3,5",
+		`SELECT id FROM t WHERE NOT NOT a = 1 ORDER BY id`:                                               "1,4",
+		`SELECT id FROM t WHERE ((a = 1)) ORDER BY id`:                                                   "1,4",
+		`SELECT id FROM t WHERE (a = 1 OR (b = 2 AND c = 0)) AND id > 1 ORDER BY id`:                     "2,4",
+		`SELECT id FROM t WHERE (a + 1) * 2 = 4 AND (b = 2 OR c = 0) ORDER BY id`:                        "1,4",
+		`SELECT id FROM t WHERE id NOT IN (1, 2) AND NOT (c = 3 AND a = 0) ORDER BY id`:                  "4",
+		`SELECT x.id FROM t x JOIN t y ON x.id = y.id AND (y.a = 1 OR y.b = 2) WHERE x.c = 3`:            "3,4",
+		`SELECT id FROM t WHERE NOT EXISTS (SELECT 1 FROM t x WHERE x.id = t.id + 1) OR a = 1 AND b = 0`: "1,5",
+	}
+	for query, expected := range queries {
+		if res := ids(query); res != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, res)
+		}
+	}
+
+	// UPDATE and DELETE use the same precedence
+	res, err := db.Exec(`UPDATE t SET c = 9 WHERE a = 1 OR b = 2 AND c = 3`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Fatalf("expected 3 updated rows, got %d", n)
+	}
+	res, err = db.Exec(`DELETE FROM t WHERE NOT (a = 1 OR c = 9)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("expected 2 deleted rows, got %d", n)
+	}
+	if res := ids(`SELECT id FROM t ORDER BY id`); res != "1,3,4" {
+		t.Fatalf("expected rows 1,3,4 left, got %s", res)
+	}
+
+	_, err = db.Query(`SELECT id FROM t WHERE (a = 1 OR b = 2`)
+	if err == nil {
+		t.Fatalf("expected unbalanced brackets to fail")
+	}
+}
+
+func TestThreeValuedLogic(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestThreeValuedLogic")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t (id INT, a INT, b INT)`,
+		`INSERT INTO t (id, a, b) VALUES (1, 1, 1)`,
+		`INSERT INTO t (id, a, b) VALUES (2, 1, NULL)`,
+		`INSERT INTO t (id, a, b) VALUES (3, NULL, 1)`,
+		`INSERT INTO t (id, a, b) VALUES (4, NULL, NULL)`,
+		`INSERT INTO t (id, a, b) VALUES (5, 2, 3)`,
+		`INSERT INTO t (id, a, b) VALUES (6, 2, NULL)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	ids := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, id)
+		}
+		return strings.Join(res, ",")
+	}
+
+	// Comparisons with NULL are unknown, and unknown is neither true nor false
+	queries := map[string]string{
+		`SELECT id FROM t WHERE a = NULL`:                                 "",
+		`SELECT id FROM t WHERE NOT (a = NULL)`:                           "",
+		`SELECT id FROM t WHERE a > NULL OR NOT a > NULL`:                 "",
+		`SELECT id FROM t WHERE NOT (a = 1) ORDER BY id`:                  "5,6",
+		`SELECT id FROM t WHERE NOT (a = t.b) ORDER BY id`:                "5",
+		`SELECT id FROM t WHERE NOT (a < t.b) ORDER BY id`:                "1",
+		`SELECT id FROM t WHERE a = 1 OR b = 1 ORDER BY id`:               "1,2,3",
+		`SELECT id FROM t WHERE NOT (a = 1 OR b = 1) ORDER BY id`:         "5",
+		`SELECT id FROM t WHERE NOT (a = 1 AND b = 1) ORDER BY id`:        "5,6",
+		`SELECT id FROM t WHERE NOT (a IN (1, NULL)) ORDER BY id`:         "",
+		`SELECT id FROM t WHERE NOT (a IN (1)) ORDER BY id`:               "5,6",
+		`SELECT id FROM t WHERE NOT (a NOT IN (1)) ORDER BY id`:           "1,2",
+		`SELECT id FROM t WHERE NOT (a BETWEEN 0 AND 1) ORDER BY id`:      "5,6",
+		`SELECT id FROM t WHERE NOT (a IS NULL) ORDER BY id`:              "1,2,5,6",
+		`SELECT id FROM t WHERE NOT (b IS NOT NULL) ORDER BY id`:          "2,4,6",
+		`SELECT id FROM t WHERE NOT (a = 1 OR b IS NULL) ORDER BY id`:     "5",
+		`SELECT id FROM t WHERE NOT NOT (a = 2 OR b = 1) ORDER BY id`:     "1,3,5,6",
+		`SELECT id FROM t WHERE a IS NULL OR NOT (a + 1 = 3) ORDER BY id`: "1,2,3,4",
+	}
+	for query, expected := range queries {
+		if res := ids(query); res != expected {
+			t.Fatalf("%s: expected '%s', got '%s'", query, expected, res)
+		}
+	}
+
+	// Rows for which WHERE clause is unknown are neither updated nor deleted
+	res, err := db.Exec(`UPDATE t SET a = 0 WHERE NOT (b = 1)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("expected 1 updated row, got %d", n)
+	}
+	res, err = db.Exec(`DELETE FROM t WHERE NOT (a = 0 OR b = 1)`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 0 {
+		t.Fatalf("expected no deleted row, got %d", n)
+	}
+}
+
+func TestNullRightOperand(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestNullRightOperand")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	// Values looking like a formatted NULL must not be equal to NULL
+	batch := []string{
+		`CREATE TABLE t (id INT, s TEXT, n INT, at TIMESTAMP)`,
+		`INSERT INTO t (id, s, n, at) VALUES (1, '<nil>', 1, '2020-02-29 13:37:00')`,
+		`INSERT INTO t (id, s, n, at) VALUES (2, '', 2, '2020-03-01 00:00:00')`,
+		`INSERT INTO t (id) VALUES (3)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	ids := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, id)
+		}
+		return strings.Join(res, ",")
+	}
+
+	queries := map[string]string{
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND x.s = y.s`:                     "",
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND (x.n < y.n OR x.n >= y.n)`:     "",
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND (x.n > y.n OR x.n <= y.n)`:     "",
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND (x.at < y.at OR x.at >= y.at)`: "",
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND NOT (x.s = y.s)`:               "",
+		`SELECT x.id FROM t x JOIN t y ON x.s = y.s ORDER BY x.id`:                   "1,2",
+		`SELECT x.id FROM t x LEFT JOIN t y ON x.s = y.s WHERE y.id IS NULL`:         "3",
+		`SELECT x.id FROM t x, t y WHERE y.id = 3 AND x.n = y.n + 1`:                 "",
+	}
+	for query, expected := range queries {
+		if res := ids(query); res != expected {
+			t.Fatalf("%s: expected '%s', got '%s'", query, expected, res)
+		}
+	}
+}
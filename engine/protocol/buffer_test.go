@@ -12,38 +12,36 @@ func TestBufferChannel(t *testing.T) {
 	NumberRows := 10
 
 	engineChannel := make(chan message)
-	m := message{
-		Type:  rowHeaderMessage,
-		Value: []string{"foo", "bar"},
-	}
-
-	driverChannel := UnlimitedRowsChannel(engineChannel, m)
+	driverChannel := UnlimitedRowsChannel(engineChannel)
 
 	// We should be able to push 100 rows
 	for i := 0; i < NumberRows; i++ {
+		v := fmt.Sprintf("%d", i)
 		row := message{
-			Type:  rowValueMessage,
-			Value: []string{"row", fmt.Sprintf("%d", i)},
+			Type: rowValueMessage,
+			Row:  []*string{&v, nil},
 		}
 		engineChannel <- row
 	}
 	// send rowEnd
-	m = message{
+	m := message{
 		Type: rowEndMessage,
 	}
 	engineChannel <- m
 
-	// We should be able to read NumberRows+1 rows
+	// We should be able to read NumberRows rows
 	var count int
 	for {
-		m, ok := <-driverChannel
+		row, ok := <-driverChannel
 		if !ok {
-			if count != NumberRows+1 {
-				t.Fatalf("Expected %d messages, got %d\n", NumberRows+1, count)
+			if count != NumberRows {
+				t.Fatalf("Expected %d messages, got %d\n", NumberRows, count)
 			}
 			break
 		}
-		_ = m
+		if len(row) != 2 || *row[0] != fmt.Sprintf("%d", count) || row[1] != nil {
+			t.Fatalf("Unexpected row %d: %v", count, row)
+		}
 		count++
 	}
 }
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -19,11 +20,29 @@ const (
 type message struct {
 	Type  string
 	Value []string
+	// Values of a row, NULL ones being nil
+	Row []*string
+	// Types of columns of a rows header
+	Types []ColumnType
+	// Context of a statement
+	ctx context.Context
+	// Error answered to a statement, as returned by the engine
+	err error
+}
+
+// error returns the error of an error message
+func (m message) error() error {
+	if m.err != nil {
+		return m.err
+	}
+
+	return errors.New(m.Value[0])
 }
 
 // ChannelDriverConn implements DriverConn for channel backend
 type ChannelDriverConn struct {
-	conn chan message
+	conn  chan message
+	types []ColumnType
 }
 
 // ChannelDriverEndpoint implements DriverEndpoint for channel backend
@@ -93,6 +112,7 @@ func (cee *ChannelEngineEndpoint) Close() {
 // ChannelEngineConn implements EngineConn for channel backend
 type ChannelEngineConn struct {
 	conn chan message
+	ctx  context.Context
 }
 
 // NewChannelEngineConn initializes a new EngineConn with channel backend
@@ -112,9 +132,19 @@ func (cec *ChannelEngineConn) ReadStatement() (string, error) {
 		return "", io.EOF
 	}
 
+	cec.ctx = message.ctx
 	return message.Value[0], nil
 }
 
+// Context returns the context of the last statement read
+func (cec *ChannelEngineConn) Context() context.Context {
+	if cec.ctx == nil {
+		return context.Background()
+	}
+
+	return cec.ctx
+}
+
 // WriteResult is used to answer to statements other than SELECT
 func (cec *ChannelEngineConn) WriteResult(lastInsertedID int64, rowsAffected int64) error {
 	m := message{
@@ -131,6 +161,7 @@ func (cec *ChannelEngineConn) WriteError(err error) error {
 	m := message{
 		Type:  errMessage,
 		Value: []string{err.Error()},
+		err:   err,
 	}
 
 	cec.conn <- m
@@ -140,21 +171,27 @@ func (cec *ChannelEngineConn) WriteError(err error) error {
 
 // WriteRowHeader indicates that rows are coming next
 func (cec *ChannelEngineConn) WriteRowHeader(header []string) error {
+	return cec.WriteTypedRowHeader(header, nil)
+}
+
+// WriteTypedRowHeader indicates that rows are coming next,
+// with the type of their columns
+func (cec *ChannelEngineConn) WriteTypedRowHeader(header []string, types []ColumnType) error {
 	m := message{
 		Type:  rowHeaderMessage,
 		Value: header,
+		Types: types,
 	}
 
 	cec.conn <- m
 	return nil
-
 }
 
 // WriteRow must be called after WriteRowHeader and before WriteRowEnd
-func (cec *ChannelEngineConn) WriteRow(row []string) error {
+func (cec *ChannelEngineConn) WriteRow(row []*string) error {
 	m := message{
-		Type:  rowValueMessage,
-		Value: row,
+		Type: rowValueMessage,
+		Row:  row,
 	}
 
 	cec.conn <- m
@@ -173,6 +210,17 @@ func (cec *ChannelEngineConn) WriteRowEnd() error {
 
 // WriteQuery allows client to query the RamSQL server
 func (cdc *ChannelDriverConn) WriteQuery(query string) error {
+	return cdc.WriteQueryContext(context.Background(), query)
+}
+
+// WriteExec allows client to manipulate the RamSQL server
+func (cdc *ChannelDriverConn) WriteExec(statement string) error {
+	return cdc.WriteExecContext(context.Background(), statement)
+}
+
+// WriteQueryContext allows client to query the RamSQL server,
+// which stops executing query once ctx is done
+func (cdc *ChannelDriverConn) WriteQueryContext(ctx context.Context, query string) error {
 	if cdc.conn == nil {
 		return fmt.Errorf("connection closed")
 	}
@@ -180,14 +228,16 @@ func (cdc *ChannelDriverConn) WriteQuery(query string) error {
 	m := message{
 		Type:  queryMessage,
 		Value: []string{query},
+		ctx:   ctx,
 	}
 
 	cdc.conn <- m
 	return nil
 }
 
-// WriteExec allows client to manipulate the RamSQL server
-func (cdc *ChannelDriverConn) WriteExec(statement string) error {
+// WriteExecContext allows client to manipulate the RamSQL server,
+// which stops executing statement once ctx is done
+func (cdc *ChannelDriverConn) WriteExecContext(ctx context.Context, statement string) error {
 	if cdc.conn == nil {
 		return fmt.Errorf("connection closed")
 	}
@@ -195,6 +245,7 @@ func (cdc *ChannelDriverConn) WriteExec(statement string) error {
 	m := message{
 		Type:  execMessage,
 		Value: []string{statement},
+		ctx:   ctx,
 	}
 
 	cdc.conn <- m
@@ -208,9 +259,21 @@ func (cdc *ChannelDriverConn) ReadResult() (lastInsertedID int64, rowsAffected i
 	}
 
 	m := <-cdc.conn
+
+	// A statement with RETURNING clause answers rows, which are discarded
+	if m.Type == rowHeaderMessage {
+		for m = range cdc.conn {
+			if m.Type == rowEndMessage {
+				break
+			}
+			rowsAffected++
+		}
+		return 0, rowsAffected, nil
+	}
+
 	if m.Type != resultMessage {
 		if m.Type == errMessage {
-			return 0, 0, errors.New(m.Value[0])
+			return 0, 0, m.error()
 		}
 		return 0, 0, fmt.Errorf("Protocal error: ReadResult received %v", m)
 	}
@@ -219,20 +282,27 @@ func (cdc *ChannelDriverConn) ReadResult() (lastInsertedID int64, rowsAffected i
 	return lastInsertedID, rowsAffected, err
 }
 
-// ReadRows when Query has been used
-func (cdc *ChannelDriverConn) ReadRows() (chan []string, error) {
+// ReadRows when Query has been used, returning the name of columns
+// and a channel of rows
+func (cdc *ChannelDriverConn) ReadRows() ([]string, chan []*string, error) {
 	if cdc.conn == nil {
-		return nil, fmt.Errorf("connection closed")
+		return nil, nil, fmt.Errorf("connection closed")
 	}
 
 	m := <-cdc.conn
 	if m.Type == errMessage {
-		return nil, errors.New(m.Value[0])
+		return nil, nil, m.error()
 	}
 
 	if m.Type != rowHeaderMessage {
-		return nil, errors.New("not a rows header")
+		return nil, nil, errors.New("not a rows header")
 	}
 
-	return UnlimitedRowsChannel(cdc.conn, m), nil
+	cdc.types = m.Types
+	return m.Value, UnlimitedRowsChannel(cdc.conn), nil
+}
+
+// ColumnTypes returns the type of the columns of the last rows read
+func (cdc *ChannelDriverConn) ColumnTypes() []ColumnType {
+	return cdc.types
 }
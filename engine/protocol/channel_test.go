@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"context"
 	"errors"
 	"testing"
 )
@@ -26,7 +27,8 @@ func TestQuery(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = engineConn.WriteRow([]string{"hello", "world"})
+			hello, world := "hello", "world"
+			err = engineConn.WriteRow([]*string{&hello, &world, nil})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -48,16 +50,11 @@ func TestQuery(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	channel, err := driverConn.ReadRows()
+	header, channel, err := driverConn.ReadRows()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	header, ok := <-channel
-	if !ok {
-		t.Fatal("Cannot read rows")
-	}
-
 	if len(header) != 2 {
 		t.Fatalf("Expected 2 columns, got %d", len(header))
 	}
@@ -75,16 +72,20 @@ func TestQuery(t *testing.T) {
 		t.Fatal("Cannot read rows")
 	}
 
-	if len(rows) != 2 {
-		t.Fatalf("Expected 2 columns, got %d", len(rows))
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 columns, got %d", len(rows))
+	}
+
+	if *rows[0] != "hello" {
+		t.Fatalf("Expected first column value to be <hello>, got <%s>", *rows[0])
 	}
 
-	if rows[0] != "hello" {
-		t.Fatalf("Expected first column value to be <hello>, got <%s>", rows[0])
+	if *rows[1] != "world" {
+		t.Fatalf("Expected first columns value to be <world>, got <%s>", *rows[1])
 	}
 
-	if rows[1] != "world" {
-		t.Fatalf("Expected first columns value to be <world>, got <%s>", rows[1])
+	if rows[2] != nil {
+		t.Fatalf("Expected third column value to be NULL, got <%s>", *rows[2])
 	}
 
 }
@@ -167,3 +168,55 @@ func TestError(t *testing.T) {
 		t.Fatalf("Expected error <%s>, got <%s>", errMessage, err)
 	}
 }
+
+func TestExecContext(t *testing.T) {
+
+	driverE, engineE := NewChannelEndpoints()
+
+	go func() {
+		engineConn, err := engineE.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for {
+			_, err := engineConn.ReadStatement()
+			if err != nil {
+				return
+			}
+
+			// Answer with the state of statement context
+			ctx := engineConn.(ContextEngineConn).Context()
+			if ctx.Err() != nil {
+				engineConn.WriteError(ctx.Err())
+				continue
+			}
+			engineConn.WriteResult(0, 1)
+		}
+	}()
+
+	driverConn, err := driverE.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driverConn.Close()
+
+	err = driverConn.WriteExec("toto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = driverConn.ReadResult(); err != nil {
+		t.Fatalf("Expected no error without context, got %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = driverConn.WriteExecContext(ctx, "toto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = driverConn.ReadResult(); err == nil || err.Error() != context.Canceled.Error() {
+		t.Fatalf("Expected statement context to be canceled, got %v", err)
+	}
+}
@@ -1,12 +1,19 @@
 package protocol
 
+import (
+	"context"
+)
+
 // DriverConn is a networking helper hiding implementation
 // either with channels or network sockets.
 type DriverConn interface {
 	WriteQuery(query string) error
 	WriteExec(stmt string) error
+	WriteQueryContext(ctx context.Context, query string) error
+	WriteExecContext(ctx context.Context, stmt string) error
 	ReadResult() (lastInsertedID int64, rowsAffected int64, err error)
-	ReadRows() (chan []string, error)
+	ReadRows() (columns []string, rows chan []*string, err error)
+	ColumnTypes() []ColumnType
 	Close()
 }
 
@@ -17,10 +24,33 @@ type EngineConn interface {
 	WriteResult(lastInsertedID int64, rowsAffected int64) error
 	WriteError(err error) error
 	WriteRowHeader(header []string) error
-	WriteRow(row []string) error
+	WriteRow(row []*string) error
 	WriteRowEnd() error
 }
 
+// ContextEngineConn is an EngineConn giving the context of the last statement read,
+// done once client does not wait for its result anymore
+type ContextEngineConn interface {
+	EngineConn
+	Context() context.Context
+}
+
+// ColumnType describes a column of rows, as declared in table.
+// Type of computed values is unknown.
+type ColumnType struct {
+	// DatabaseTypeName is the declared type, like INT, or empty if unknown
+	DatabaseTypeName string
+	// Nullable is true if column may be NULL, and only known if HasNullable is true
+	Nullable    bool
+	HasNullable bool
+}
+
+// TypedEngineConn is an EngineConn able to send the type of columns with rows header
+type TypedEngineConn interface {
+	EngineConn
+	WriteTypedRowHeader(header []string, types []ColumnType) error
+}
+
 // EngineEndpoint is the query entrypoint of RamSQL engine.
 type EngineEndpoint interface {
 	Accept() (EngineConn, error)
@@ -0,0 +1,94 @@
+package engine_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestRandom(t *testing.T) {
+	log.UseTestLogger(t)
+
+	// shuffle returns ids and random values of a new database seeded with seed
+	shuffle := func(name string, seed string) ([]int, []float64) {
+		db, err := sql.Open("ramsql", "ramsql://"+name+"?seed="+seed)
+		if err != nil {
+			t.Fatalf("sql.Open: %s", err)
+		}
+		defer db.Close()
+
+		if _, err := db.Exec(`CREATE TABLE item (id INT)`); err != nil {
+			t.Fatalf("cannot create table: %s", err)
+		}
+		for i := 1; i <= 20; i++ {
+			if _, err := db.Exec(`INSERT INTO item (id) VALUES ($1)`, i); err != nil {
+				t.Fatalf("cannot insert: %s", err)
+			}
+		}
+
+		rows, err := db.Query(`SELECT id FROM item ORDER BY RANDOM()`)
+		if err != nil {
+			t.Fatalf("cannot shuffle rows: %s", err)
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("cannot scan row: %s", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		rows, err = db.Query(`SELECT random(), id + RANDOM() FROM item WHERE id <= 5`)
+		if err != nil {
+			t.Fatalf("cannot select random values: %s", err)
+		}
+		var values []float64
+		for rows.Next() {
+			var v, w float64
+			if err := rows.Scan(&v, &w); err != nil {
+				t.Fatalf("cannot scan row: %s", err)
+			}
+			values = append(values, v, w)
+		}
+		rows.Close()
+
+		return ids, values
+	}
+
+	ids, values := shuffle("TestRandom1", "42")
+	if len(ids) != 20 || len(values) != 10 {
+		t.Fatalf("expected 20 shuffled rows and 10 values, got %v and %v", ids, values)
+	}
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		seen[id] = true
+	}
+	if len(seen) != 20 {
+		t.Fatalf("expected each row once, got %v", ids)
+	}
+	distinct := make(map[float64]bool)
+	for i, v := range values {
+		if i%2 == 0 && (v < 0 || v >= 1) {
+			t.Fatalf("expected random value in [0, 1), got %v", v)
+		}
+		distinct[v] = true
+	}
+	if len(distinct) != len(values) {
+		t.Fatalf("expected a random value for each row, got %v", values)
+	}
+
+	// Same seed yields the same sequence
+	sameIDs, sameValues := shuffle("TestRandom2", "42")
+	if !reflect.DeepEqual(ids, sameIDs) || !reflect.DeepEqual(values, sameValues) {
+		t.Fatalf("expected same sequence with same seed, got %v %v and %v %v", ids, values, sameIDs, sameValues)
+	}
+
+	otherIDs, otherValues := shuffle("TestRandom3", "7")
+	if reflect.DeepEqual(ids, otherIDs) && reflect.DeepEqual(values, otherValues) {
+		t.Fatalf("expected different sequence with different seed, got %v", otherIDs)
+	}
+}
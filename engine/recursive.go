@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// recursionLimit is the maximum number of times the recursive term of a query is evaluated,
+// so that a recursion which never ends fails instead
+const recursionLimit = 10000
+
+/*
+|-> recursive
+	|-> tree
+		|-> id
+	|-> union
+		|-> SELECT
+			|-> ...
+		|-> SELECT
+			|-> ...
+			|-> FROM
+				|-> tree
+					|-> recursive
+		|-> all
+*/
+// recursiveQueryExecutor writes rows of a recursive common table expression to conn. Rows of
+// non recursive term are selected first, then recursive term is evaluated with rows found by
+// previous evaluation as working table, until no new row is found. Without ALL, rows already
+// found are discarded. Column names are those given after query name, or else those of the
+// non recursive term.
+func recursiveQueryExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn, locked map[*Relation]bool) error {
+	name := decl.Decl[0].Lexeme
+	unionDecl := decl.Decl[1]
+	if unionDecl.Token != parser.UnionToken {
+		return fmt.Errorf("recursive query \"%s\" does not have the form non-recursive-term UNION [ALL] recursive-term", name)
+	}
+
+	all := false
+	for _, d := range unionDecl.Decl[2:] {
+		if d.Token == parser.AllToken {
+			all = true
+		}
+	}
+
+	seed, err := subqueryExecutor(e, unionDecl.Decl[0], locked)
+	if err != nil {
+		return err
+	}
+
+	// Columns may be named after query, the first ones at least
+	columns := append([]string(nil), seed.header...)
+	if len(decl.Decl[0].Decl) > len(columns) {
+		return fmt.Errorf("recursive query \"%s\" has %d columns available but %d columns specified", name, len(columns), len(decl.Decl[0].Decl))
+	}
+	for i, d := range decl.Decl[0].Decl {
+		columns[i] = d.Lexeme
+	}
+
+	found := &resultConn{header: columns}
+	seen := make(map[string]bool)
+	add := func(rows [][]interface{}) [][]interface{} {
+		var added [][]interface{}
+		for _, row := range rows {
+			if !all {
+				k := valuesKey(row)
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+			}
+			added = append(added, row)
+		}
+		found.rows = append(found.rows, added...)
+		return added
+	}
+
+	refs := workTableReferences(unionDecl.Decl[1], name)
+	defer e.setWorkTable(refs, nil)
+
+	work := &resultConn{header: columns, rows: add(seed.rows)}
+	for i := 0; len(work.rows) > 0; i++ {
+		if i == recursionLimit {
+			return fmt.Errorf("recursive query \"%s\" did not end after %d iterations", name, recursionLimit)
+		}
+		if err := e.canceled(); err != nil {
+			return err
+		}
+
+		e.setWorkTable(refs, work)
+		res, err := subqueryExecutor(e, unionDecl.Decl[1], locked)
+		if err != nil {
+			return err
+		}
+		if len(res.header) != len(seed.header) {
+			return fmt.Errorf("each UNION query must have the same number of columns")
+		}
+
+		work = &resultConn{header: columns, rows: add(res.rows)}
+	}
+
+	r, err := found.relation(name)
+	if err != nil {
+		return err
+	}
+	var header []string
+	for _, a := range r.table.attributes {
+		header = append(header, r.table.name+"."+a.name)
+	}
+
+	return generateVirtualRows(e, conn, header, columns, r, nil, nil, []selectFunctor{&defaultSelectFunction{}})
+}
+
+// workTableReferences returns references to the working table of recursive query name in decl.
+// References in a nested recursive query with the same name are the ones of this query.
+func workTableReferences(decl *parser.Decl, name string) []*parser.Decl {
+	if isWorkTable(decl) && decl.Lexeme == name {
+		return []*parser.Decl{decl}
+	}
+	if decl.Token == parser.RecursiveToken && len(decl.Decl) > 0 && decl.Decl[0].Lexeme == name {
+		return nil
+	}
+
+	var refs []*parser.Decl
+	for _, d := range decl.Decl {
+		refs = append(refs, workTableReferences(d, name)...)
+	}
+
+	return refs
+}
+
+// setWorkTable sets rows of the working table seen by given references, or removes it if work is nil
+func (e *Engine) setWorkTable(refs []*parser.Decl, work *resultConn) {
+	if e.workTables == nil {
+		e.workTables = make(map[*parser.Decl]*resultConn)
+	}
+
+	for _, ref := range refs {
+		if work == nil {
+			delete(e.workTables, ref)
+			continue
+		}
+		e.workTables[ref] = work
+	}
+}
+
+// isWorkTable returns true if decl references the working table of a recursive query
+func isWorkTable(decl *parser.Decl) bool {
+	if decl.Token != parser.StringToken {
+		return false
+	}
+
+	for _, d := range decl.Decl {
+		if d.Token == parser.RecursiveToken {
+			return true
+		}
+	}
+
+	return false
+}
+
+// workTableExecutor returns rows of working table referenced by decl, with given name
+func workTableExecutor(e *Engine, decl *parser.Decl, name string) (*Relation, error) {
+	work, ok := e.workTables[decl]
+	if !ok {
+		return nil, fmt.Errorf("recursive reference to query \"%s\" must not appear within its non-recursive term", decl.Lexeme)
+	}
+
+	return work.relation(name)
+}
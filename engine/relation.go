@@ -8,8 +8,13 @@ import (
 // AKA File
 type Relation struct {
 	sync.RWMutex
-	table *Table
-	rows  []*Tuple
+	table   *Table
+	rows    []*Tuple
+	indexes []*index
+	// unique holds rows by their key for each UNIQUE constraint of table
+	unique []*uniqueSet
+	// altered counts changes of columns, rows copied before one not fitting them anymore
+	altered int
 }
 
 // NewRelation initializes a new Relation struct
@@ -24,7 +29,7 @@ func NewRelation(t *Table) *Relation {
 // Insert a tuple in relation
 func (r *Relation) Insert(t *Tuple) error {
 	// Maybe do somthing like lock read/write here
-	// Maybe index
 	r.rows = append(r.rows, t)
+	r.addToIndexes(t)
 	return nil
 }
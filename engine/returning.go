@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// returning is the projection of written rows asked by a RETURNING clause
+type returning struct {
+	header  []string
+	types   []protocol.ColumnType
+	indexes []int
+}
+
+/*
+|-> returning
+	|-> id
+	|-> created_at
+		|-> as
+			|-> creation
+*/
+// returningExecutor resolves attributes of RETURNING clause on table. It must be called
+// before writing rows, so an unknown attribute is reported without side effect.
+// A nil returning is returned if statement has no RETURNING clause.
+func returningExecutor(t *Table, decls []*parser.Decl) (*returning, error) {
+	var returningDecl *parser.Decl
+	for _, d := range decls {
+		if d.Token == parser.ReturningToken {
+			returningDecl = d
+		}
+	}
+	if returningDecl == nil {
+		return nil, nil
+	}
+
+	ret := &returning{}
+	for _, attr := range returningDecl.Decl {
+		if len(attr.Decl) > 0 && attr.Decl[0].Token == parser.StringToken && attr.Decl[0].Lexeme != t.name {
+			return nil, fmt.Errorf("missing FROM-clause entry for table \"%s\"", attr.Decl[0].Lexeme)
+		}
+
+		if attr.Token == parser.StarToken {
+			for i, a := range t.attributes {
+				ret.header = append(ret.header, a.name)
+				ret.types = append(ret.types, a.columnType())
+				ret.indexes = append(ret.indexes, i)
+			}
+			continue
+		}
+
+		i := t.attributeIndex(attr.Lexeme)
+		if i < 0 {
+			return nil, &Error{Code: UndefinedColumn, Column: attr.Lexeme, Message: fmt.Sprintf("column \"%s\" does not exist", attr.Lexeme)}
+		}
+		name := attr.Lexeme
+		if a := selectedAlias(attr); a != "" {
+			name = a
+		}
+		ret.header = append(ret.header, name)
+		ret.types = append(ret.types, t.attributes[i].columnType())
+		ret.indexes = append(ret.indexes, i)
+	}
+
+	return ret, nil
+}
+
+// write sends given rows, as they are once written, projected on returned attributes
+func (ret *returning) write(conn protocol.EngineConn, rows []*Tuple) error {
+	if err := writeRowHeader(conn, ret.header, ret.types); err != nil {
+		return err
+	}
+
+	for _, t := range rows {
+		var row []*string
+		for _, i := range ret.indexes {
+			row = append(row, rowValue(t.Values[i]))
+		}
+		if err := conn.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return conn.WriteRowEnd()
+}
@@ -0,0 +1,135 @@
+package engine_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestReturning(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestReturning")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id BIGSERIAL PRIMARY KEY, name TEXT, score INT DEFAULT 10, created_at TIMESTAMP DEFAULT now())`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+
+	// INSERT returns generated id and applied defaults
+	var id int64
+	var score int
+	var createdAt string
+	err = db.QueryRow(`INSERT INTO users (name) VALUES ($1) RETURNING id, score, created_at`, "alice").Scan(&id, &score, &createdAt)
+	if err != nil {
+		t.Fatalf("Cannot insert with RETURNING: %s", err)
+	}
+	if id != 1 || score != 10 || createdAt == "" || createdAt == "<nil>" {
+		t.Fatalf("Expected id 1, score 10 and a creation date, got %d, %d and '%s'", id, score, createdAt)
+	}
+
+	err = db.QueryRow(`INSERT INTO users (name, score) VALUES ('bob', 20) RETURNING id AS user_id`).Scan(&id)
+	if err != nil {
+		t.Fatalf("Cannot insert with aliased RETURNING: %s", err)
+	}
+	if id != 2 {
+		t.Fatalf("Expected id 2, got %d", id)
+	}
+
+	rows, err := db.Query(`INSERT INTO users (name) VALUES ('carol') RETURNING *`)
+	if err != nil {
+		t.Fatalf("Cannot insert with RETURNING *: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("rows.Columns: %s", err)
+	}
+	rows.Close()
+	if len(columns) != 4 || columns[0] != "id" || columns[3] != "created_at" {
+		t.Fatalf("Unexpected columns with RETURNING *: %v", columns)
+	}
+
+	// UPDATE returns updated rows with their new values
+	rows, err = db.Query(`UPDATE users SET score = 50 WHERE score = 10 RETURNING id, users.score`)
+	if err != nil {
+		t.Fatalf("Cannot update with RETURNING: %s", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		if err := rows.Scan(&id, &score); err != nil {
+			t.Fatalf("rows.Scan: %s", err)
+		}
+		if score != 50 {
+			t.Fatalf("Expected updated score 50, got %d", score)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Fatalf("Expected rows 1 and 3 to be updated, got %v", ids)
+	}
+
+	// DELETE returns deleted rows
+	var name string
+	err = db.QueryRow(`DELETE FROM users WHERE id = 2 RETURNING name`).Scan(&name)
+	if err != nil {
+		t.Fatalf("Cannot delete with RETURNING: %s", err)
+	}
+	if name != "bob" {
+		t.Fatalf("Expected bob to be deleted, got %s", name)
+	}
+
+	// An unknown attribute is an error, and nothing is written
+	rejected := []string{
+		`INSERT INTO users (name) VALUES ('dave') RETURNING unknown`,
+		`UPDATE users SET score = 0 WHERE id = 1 RETURNING unknown`,
+		`DELETE FROM users WHERE id = 1 RETURNING other.id`,
+	}
+	for _, query := range rejected {
+		rows, err := db.Query(query)
+		if err == nil {
+			rows.Close()
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM users WHERE score = 50`).Scan(&count)
+	if err != nil {
+		t.Fatalf("sql.QueryRow: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 unchanged rows, got %d", count)
+	}
+
+	// Exec discards returned rows
+	res, err := db.Exec(`UPDATE users SET score = 60 WHERE score = 50 RETURNING id`)
+	if err != nil {
+		t.Fatalf("Cannot exec statement with RETURNING: %s", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatalf("RowsAffected: %s", err)
+	}
+	if affected != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", affected)
+	}
+
+	// DELETE without WHERE returns all rows
+	rows, err = db.Query(`DELETE FROM users RETURNING id`)
+	if err != nil {
+		t.Fatalf("Cannot delete all rows with RETURNING: %s", err)
+	}
+	count = 0
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 2 {
+		t.Fatalf("Expected 2 deleted rows, got %d", count)
+	}
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+)
+
+// informationSchemaExecutor returns a read-only relation with given name, describing
+// tables and views, or their columns, like the views of information_schema
+// SELECT column_name, data_type FROM information_schema.columns WHERE table_name = 'account'
+func informationSchemaExecutor(e *Engine, view string, name string) (*Relation, error) {
+	var columns []string
+	view = strings.ToLower(view)
+	switch view {
+	case "tables":
+		columns = []string{"table_schema", "table_name", "table_type"}
+	case "columns":
+		columns = []string{"table_schema", "table_name", "column_name", "ordinal_position", "data_type", "is_nullable", "column_default"}
+	default:
+		return nil, &Error{Code: UndefinedTable, Table: "information_schema." + view, Message: fmt.Sprintf("table \"information_schema.%s\" does not exist", view)}
+	}
+
+	t := NewTable(name)
+	for _, c := range columns {
+		t.AddAttribute(NewAttribute(c, "text", false))
+	}
+	r := NewRelation(t)
+
+	e.Lock()
+	defer e.Unlock()
+
+	var names []string
+	for n := range e.relations {
+		names = append(names, n)
+	}
+	for n := range e.views {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		rel, ok := e.relations[n]
+		if view == "tables" {
+			tableType := "VIEW"
+			if ok {
+				tableType = "BASE TABLE"
+			}
+			r.rows = append(r.rows, NewTuple("public", n, tableType))
+			continue
+		}
+
+		// Columns of views are not known before they are selected
+		if !ok {
+			continue
+		}
+		for i, a := range rel.table.attributes {
+			nullable := "YES"
+			if a.notNull {
+				nullable = "NO"
+			}
+			var def interface{}
+			if a.defaultText != "" {
+				def = a.defaultText
+			}
+			r.rows = append(r.rows, NewTuple("public", n, a.name, int64(i+1), strings.ToLower(a.typeName), nullable, def))
+		}
+	}
+
+	return r, nil
+}
+
+// isInformationSchema returns true if table reference is qualified with information_schema
+func isInformationSchema(decl *parser.Decl) bool {
+	return len(decl.Decl) > 0 && decl.Decl[0].Token == parser.StringToken && strings.ToLower(decl.Decl[0].Lexeme) == "information_schema"
+}
+
+// declText returns the SQL text of a value or an expression, as a default value
+func declText(d *parser.Decl) string {
+	switch d.Token {
+	case parser.QuotedStringToken:
+		return "'" + strings.Replace(d.Lexeme, "'", "''", -1) + "'"
+	case parser.FunctionToken:
+		var args []string
+		for _, arg := range d.Decl {
+			if arg.Token == parser.BracketClosingToken {
+				break
+			}
+			args = append(args, declText(arg))
+		}
+		return d.Lexeme + "(" + strings.Join(args, ", ") + ")"
+	case parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken:
+		if len(d.Decl) >= 2 {
+			return declText(d.Decl[0]) + " " + d.Lexeme + " " + declText(d.Decl[1])
+		}
+	}
+
+	return d.Lexeme
+}
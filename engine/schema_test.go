@@ -0,0 +1,78 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestInformationSchema(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInformationSchema")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT NOT NULL, active BOOLEAN DEFAULT false, created_at TIMESTAMP DEFAULT NOW())`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id BIGINT, amount INT DEFAULT 2 * 5)`,
+		`CREATE VIEW active_users AS SELECT * FROM users WHERE active = true`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("sql.Query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		columns, _ := rows.Columns()
+		var got []string
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("rows.Scan: %s", err)
+			}
+			var row []string
+			for _, v := range values {
+				if !v.Valid {
+					row = append(row, "NULL")
+					continue
+				}
+				row = append(row, v.String)
+			}
+			got = append(got, strings.Join(row, "|"))
+		}
+		return strings.Join(got, ",")
+	}
+
+	expected := map[string]string{
+		`SELECT table_name, table_type FROM information_schema.tables`:                                                                                             "active_users|VIEW,orders|BASE TABLE,users|BASE TABLE",
+		`SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns WHERE table_name = 'users'`:                                    "id|bigserial|NO|NULL,name|text|NO|NULL,active|boolean|YES|false,created_at|timestamp|YES|now()",
+		`SELECT c.column_name, c.column_default FROM INFORMATION_SCHEMA.COLUMNS AS c WHERE c.table_name = 'orders' AND c.ordinal_position = 3`:                     "amount|2 * 5",
+		`SELECT COUNT(*) FROM information_schema.columns JOIN information_schema.tables ON tables.table_name = columns.table_name WHERE table_type = 'BASE TABLE'`: "7",
+	}
+	for query, want := range expected {
+		if got := rows(query); got != want {
+			t.Fatalf("expected '%s' for '%s', got '%s'", want, query, got)
+		}
+	}
+
+	if _, err = db.Exec(`INSERT INTO information_schema.tables (table_name) VALUES ('foo')`); err == nil {
+		t.Fatalf("expected error writing in information_schema")
+	}
+}
@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -15,7 +14,7 @@ func attributeExistsInTable(e *Engine, attr string, table string) error {
 
 	r := e.relation(table)
 	if r == nil {
-		return fmt.Errorf("table \"%s\" does not exist", table)
+		return &Error{Code: UndefinedTable, Table: table, Message: fmt.Sprintf("table \"%s\" does not exist", table)}
 	}
 
 	found := false
@@ -28,44 +27,77 @@ func attributeExistsInTable(e *Engine, attr string, table string) error {
 	}
 
 	if !found {
-		return fmt.Errorf("attribute %s does not exist in table %s", attr, table)
+		return &Error{Code: UndefinedColumn, Table: table, Column: attr, Message: fmt.Sprintf("attribute %s does not exist in table %s", attr, table)}
 	}
 
 	return nil
 }
 
-func attributesExistInTables(e *Engine, attributes []Attribute, tables []string) error {
-
-	for _, attr := range attributes {
-		if attr.name == "COUNT" {
-			continue
+// resolveAttribute returns the table of given attribute among tables in scope.
+// If table is not specified, attribute must exist in exactly one table,
+// subquery own tables taking precedence over correlated ones.
+func resolveAttribute(attr string, table string, tables []*Table) (*Table, error) {
+	if table != "" {
+		t, err := scopeTable(table, tables)
+		if err != nil {
+			return nil, err
+		}
+		for _, tAttr := range t.attributes {
+			if tAttr.name == attr {
+				return t, nil
+			}
 		}
+		return nil, &Error{Code: UndefinedColumn, Table: table, Column: attr, Message: fmt.Sprintf("attribute %s does not exist in table %s", attr, table)}
+	}
 
-		if strings.Contains(attr.name, ".") {
-			t := strings.Split(attr.name, ".")
-			if err := attributeExistsInTable(e, t[1], t[0]); err != nil {
-				return err
+	var found *Table
+	for _, correlated := range []bool{false, true} {
+		for _, t := range tables {
+			if t.correlated != correlated {
+				continue
 			}
-			continue
+			for _, tAttr := range t.attributes {
+				if tAttr.name != attr {
+					continue
+				}
+				if found != nil {
+					return nil, &Error{Code: AmbiguousColumn, Column: attr, Message: fmt.Sprintf("ambiguous attribute %s, column reference may be %s.%s or %s.%s", attr, found.name, attr, t.name, attr)}
+				}
+				found = t
+			}
+		}
+		if found != nil {
+			return found, nil
 		}
+	}
 
-		found := 0
+	if found == nil {
+		var names []string
 		for _, t := range tables {
+			names = append(names, t.name)
+		}
+		return nil, &Error{Code: UndefinedColumn, Column: attr, Message: fmt.Sprintf("attribute %s does not exist in tables %v", attr, names)}
+	}
 
-			if err := attributeExistsInTable(e, attr.name, t); err == nil {
-				found++
-			}
+	return found, nil
+}
 
-			if found == 0 {
-				return fmt.Errorf("attribute %s does not exist in tables %v", attr.name, tables)
-			}
-			if found > 1 {
-				return fmt.Errorf("ambiguous attribute %s", attr.name)
-			}
+// scopeTable returns the table referenced with given name in FROM or JOIN clause
+func scopeTable(name string, tables []*Table) (*Table, error) {
+	for _, t := range tables {
+		if t.name == name {
+			return t, nil
 		}
 	}
 
-	return nil
+	// Like PostgreSQL, an aliased relation can only be referenced by its alias
+	for _, t := range tables {
+		if t.aliased == name {
+			return nil, fmt.Errorf("invalid reference to FROM-clause entry for table \"%s\", use its alias \"%s\"", name, t.name)
+		}
+	}
+
+	return nil, &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("table \"%s\" does not exist", name)}
 }
 
 /*
@@ -79,78 +111,254 @@ func attributesExistInTables(e *Engine, attributes []Attribute, tables []string)
 			|-> foo@bar.com
 */
 func selectExecutor(e *Engine, selectDecl *parser.Decl, conn protocol.EngineConn) error {
-	var attributes []Attribute
+	// Relations stay read locked until every row is selected
+	locked := make(map[*Relation]bool)
+	defer func() {
+		for r := range locked {
+			r.RUnlock()
+		}
+	}()
+
+	selectDecl.Stringy(0)
+
+	// Rows are sent once all are selected, an error may occur on any of them
+	buffer := bufferConn(conn)
+	if err := selectQueryExecutor(e, selectDecl, buffer, locked, nil); err != nil {
+		return err
+	}
+
+	return buffer.flush()
+}
+
+// selectQueryExecutor writes rows selected by selectDecl to conn. Relations read locked
+// by the query, or by the outer one for a subquery, are kept in locked.
+// A correlated subquery sees the current row of outer query.
+func selectQueryExecutor(e *Engine, selectDecl *parser.Decl, conn protocol.EngineConn, locked map[*Relation]bool, outer *correlation) error {
+	if selectDecl.Token == parser.RecursiveToken {
+		return recursiveQueryExecutor(e, selectDecl, conn, locked)
+	}
+	if selectDecl.Token != parser.SelectToken {
+		return setOperationExecutor(e, selectDecl, conn, locked)
+	}
+
+	var header []string
+	var alias []string
+	var from *Relation
+	var fromTables []*Table
 	var tables []*Table
 	var predicates []PredicateLinker
 	var functors []selectFunctor
 	var joiners []joiner
+	var groupDecl *parser.Decl
+	var havingDecl *parser.Decl
+	var orderDecl *parser.Decl
+	var distinct bool
+	limit, offset := -1, 0
+	var aggregates []*aggregateFunction
+	var expressions []*selectedExpression
+	var windows []*windowFunction
 	var err error
 
-	selectDecl.Stringy(0)
 	for i := range selectDecl.Decl {
 		switch selectDecl.Decl[i].Token {
 		case parser.FromToken:
-			// get selected tables
-			tables = fromExecutor(selectDecl.Decl[i])
+			// get selected tables, several tables being a cartesian product
+			for _, tableDecl := range selectDecl.Decl[i].Decl {
+				r, err := tableReferenceExecutor(e, tableDecl, tables, locked)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, r.table)
+				fromTables = append(fromTables, r.table)
+				if from == nil {
+					from = r
+					continue
+				}
+				joiners = append(joiners, &inner{relation: r, predicate: &TruePredicate})
+			}
 		case parser.WhereToken:
 			// get WHERE declaration
-			pred, err := whereExecutor2(e, selectDecl.Decl[i].Decl, tables[0].name)
+			pred, err := whereExecutor2(e, selectDecl.Decl[i].Decl, outer.scope(tables), locked)
 			if err != nil {
 				return err
 			}
 			predicates = []PredicateLinker{pred}
 		case parser.JoinToken:
-			j, err := joinExecutor(selectDecl.Decl[i])
+			r, err := tableReferenceExecutor(e, selectDecl.Decl[i].Decl[0], tables, locked)
 			if err != nil {
 				return err
 			}
-			joiners = append(joiners, j)
-		case parser.OrderToken:
-			orderFunctor, err := orderbyExecutor(selectDecl.Decl[i], tables)
+			tables = append(tables, r.table)
+			j, err := joinExecutor(e, selectDecl.Decl[i], r, tables, locked, outer)
 			if err != nil {
 				return err
 			}
-			functors = append(functors, orderFunctor)
+			joiners = append(joiners, j)
+		case parser.DistinctToken:
+			distinct = true
+		case parser.GroupToken:
+			groupDecl = selectDecl.Decl[i]
+		case parser.HavingToken:
+			havingDecl = selectDecl.Decl[i]
+		case parser.OrderToken:
+			orderDecl = selectDecl.Decl[i]
 		case parser.LimitToken:
-			limit, err := strconv.Atoi(selectDecl.Decl[i].Decl[0].Lexeme)
+			limit, err = strconv.Atoi(selectDecl.Decl[i].Decl[0].Lexeme)
 			if err != nil {
 				return fmt.Errorf("wrong limit value: %s", err)
 			}
-			conn = limitedConn(conn, limit)
 		case parser.OffsetToken:
-			offset, err := strconv.Atoi(selectDecl.Decl[i].Decl[0].Lexeme)
+			offset, err = strconv.Atoi(selectDecl.Decl[i].Decl[0].Lexeme)
 			if err != nil {
 				return fmt.Errorf("wrong offset value: %s", err)
 			}
-			conn = offsetedConn(conn, offset)
 		}
 	}
 
+	if from == nil {
+		return fmt.Errorf("no table selected")
+	}
+
+	// Outer row values are added before tables are joined, so that ON conditions see them,
+	// and after each join, rows padded by RIGHT and FULL joins having none
+	if outer != nil {
+		correlated := []joiner{outer}
+		for _, j := range joiners {
+			correlated = append(correlated, j, outer)
+		}
+		joiners = correlated
+	}
+
 	for i := range selectDecl.Decl {
-		if selectDecl.Decl[i].Token != parser.StringToken &&
-			selectDecl.Decl[i].Token != parser.StarToken &&
-			selectDecl.Decl[i].Token != parser.CountToken {
+		if windowDecl(selectDecl.Decl[i]) != nil {
+			w, err := windowExecutor(e, selectDecl.Decl[i], tables, locked, fmt.Sprintf("(window %d)", len(windows)))
+			if err != nil {
+				return err
+			}
+			windows = append(windows, w)
+			header = append(header, w.key)
+			if a := selectedAlias(selectDecl.Decl[i]); a != "" {
+				alias = append(alias, a)
+			} else {
+				alias = append(alias, w.name)
+			}
 			continue
 		}
 
-		// get attribute to selected
-		attr, err := getSelectedAttribute(e, selectDecl.Decl[i], tables)
+		switch selectDecl.Decl[i].Token {
+		case parser.StringToken, parser.StarToken:
+			// get attribute to selected
+			h, a, err := getSelectedAttribute(selectDecl.Decl[i], tables, fromTables)
+			if err != nil {
+				return err
+			}
+			header = append(header, h...)
+			alias = append(alias, a...)
+		case parser.SelectToken, parser.UnionToken, parser.IntersectToken, parser.ExceptToken:
+			s := scalarSubqueryExecutor(e, selectDecl.Decl[i], outer.scope(tables), locked, len(expressions))
+			expressions = append(expressions, s)
+			header = append(header, s.key)
+			alias = append(alias, s.name)
+		case parser.CaseToken, parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken,
+			parser.FunctionToken, parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken,
+			parser.NumberToken, parser.QuotedStringToken, parser.NullToken, parser.TrueToken, parser.FalseToken:
+			expr, err := expressionExecutor(e, selectDecl.Decl[i], outer.scope(tables), locked)
+			if err != nil {
+				return err
+			}
+			s := &selectedExpression{key: fmt.Sprintf("(expression %d)", len(expressions)), name: "?column?", expr: expr}
+			switch selectDecl.Decl[i].Token {
+			case parser.CaseToken:
+				s.name = "case"
+			case parser.FunctionToken:
+				s.name = selectDecl.Decl[i].Lexeme
+				// Like PostgreSQL, a cast attribute keeps its name
+				if s.name == "cast" && selectDecl.Decl[i].Decl[0].Token == parser.StringToken {
+					s.name = selectDecl.Decl[i].Decl[0].Lexeme
+				}
+			case parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken:
+				s.name = strings.TrimSuffix(selectDecl.Decl[i].Lexeme, "()")
+			}
+			if a := selectedAlias(selectDecl.Decl[i]); a != "" {
+				s.name = a
+			}
+			expressions = append(expressions, s)
+			header = append(header, s.key)
+			alias = append(alias, s.name)
+		case parser.CountToken, parser.SumToken, parser.AvgToken, parser.MinToken, parser.MaxToken,
+			parser.GroupConcatToken, parser.StringAggToken:
+			f, err := aggregateExecutor(e, selectDecl.Decl[i], tables, locked)
+			if err != nil {
+				return err
+			}
+			aggregates = append(aggregates, f)
+			header = append(header, f.key)
+			if a := selectedAlias(selectDecl.Decl[i]); a != "" {
+				alias = append(alias, a)
+			} else {
+				alias = append(alias, f.name)
+			}
+		}
+	}
+
+	// Rows may be ordered by name of selected values
+	if orderDecl != nil {
+		orderFunctor, err := orderbyExecutor(e, orderDecl, tables, locked, header, alias)
 		if err != nil {
 			return err
 		}
-		attributes = append(attributes, attr...)
+		functors = append(functors, orderFunctor)
+	}
 
+	// Rows are skipped before being counted, whatever the clauses order
+	conn = &typedConn{EngineConn: conn, types: columnTypes(header, tables)}
+	if limit >= 0 {
+		conn = limitedConn(conn, limit)
+	}
+	if offset > 0 {
+		conn = offsetedConn(conn, offset)
 	}
 
 	if len(functors) == 0 {
-		// Instanciate a new select functor
-		functors, err = getSelectFunctors(selectDecl)
-		if err != nil {
+		functors = append(functors, &defaultSelectFunction{})
+	}
+
+	// Remove duplicates before ordering
+	if distinct {
+		functors = []selectFunctor{&distinctFunctor{next: functors}}
+	}
+
+	// Expressions and scalar subqueries are computed for each row, or group, to select
+	if len(expressions) > 0 {
+		functors = []selectFunctor{&expressionFunctor{next: functors, expressions: expressions}}
+	}
+
+	// Window functions are computed once every row, or group, is known
+	if len(windows) > 0 {
+		functors = []selectFunctor{&windowFunctor{next: functors, windows: windows}}
+	}
+
+	// Aggregates or GROUP BY clause need rows to be grouped first
+	if len(aggregates) > 0 || groupDecl != nil || havingDecl != nil {
+		g := &groupbyFunctor{next: functors, aggregates: aggregates}
+		if groupDecl != nil {
+			g.keys, err = groupbyExecutor(groupDecl, tables, header, alias)
+			if err != nil {
+				return err
+			}
+		}
+		if err = checkGroupedAttributes(header, alias, g, expressions, windows); err != nil {
 			return err
 		}
+		if havingDecl != nil {
+			if err = havingExecutor(e, havingDecl, tables, g, locked); err != nil {
+				return err
+			}
+		}
+		functors = []selectFunctor{g}
 	}
 
-	err = generateVirtualRows(e, attributes, conn, tables[0].name, joiners, predicates, functors)
+	err = generateVirtualRows(e, conn, header, alias, from, joiners, predicates, functors)
 	if err != nil {
 		return err
 	}
@@ -164,30 +372,54 @@ type selectFunctor interface {
 	Done() error
 }
 
-// getSelectFunctors instanciate new functors for COUNT, MAX, MIN, AVG, ... and default select functor that return rows to client
-// If a functor is specified, no attribute can be selected ?
-func getSelectFunctors(attr *parser.Decl) ([]selectFunctor, error) {
-	var functors []selectFunctor
+// distinctFunctor only feeds next functors with virtual rows
+// which selected attributes were not seen yet. NULL values are equal.
+type distinctFunctor struct {
+	next       []selectFunctor
+	attributes []string
+	seen       map[string]bool
+}
 
-	for i := range attr.Decl {
+func (f *distinctFunctor) Init(e *Engine, conn protocol.EngineConn, attr []string, alias []string) error {
+	f.attributes = attr
+	f.seen = make(map[string]bool)
 
-		if attr.Decl[i].Token == parser.FromToken {
-			break
+	for i := range f.next {
+		if err := f.next[i].Init(e, conn, attr, alias); err != nil {
+			return err
 		}
+	}
 
-		if attr.Decl[i].Token == parser.CountToken {
-			f := &countSelectFunction{}
-			functors = append(functors, f)
-		}
+	return nil
+}
+
+func (f *distinctFunctor) FeedVirtualRow(vrow virtualRow) error {
+	key, err := vrow.key(f.attributes)
+	if err != nil {
+		return err
+	}
+	if f.seen[key] {
+		return nil
 	}
+	f.seen[key] = true
 
-	if len(functors) == 0 {
-		f := &defaultSelectFunction{}
-		functors = append(functors, f)
+	for i := range f.next {
+		if err := f.next[i].FeedVirtualRow(vrow); err != nil {
+			return err
+		}
 	}
 
-	return functors, nil
+	return nil
+}
+
+func (f *distinctFunctor) Done() error {
+	for i := range f.next {
+		if err := f.next[i].Done(); err != nil {
+			return err
+		}
+	}
 
+	return nil
 }
 
 type defaultSelectFunction struct {
@@ -207,14 +439,14 @@ func (f *defaultSelectFunction) Init(e *Engine, conn protocol.EngineConn, attr [
 }
 
 func (f *defaultSelectFunction) FeedVirtualRow(vrow virtualRow) error {
-	var row []string
+	var row []*string
 
 	for _, attr := range f.attributes {
 		val, ok := vrow[attr]
 		if !ok {
 			return fmt.Errorf("could not select attribute %s", attr)
 		}
-		row = append(row, fmt.Sprintf("%v", val.v))
+		row = append(row, rowValue(val.v))
 	}
 
 	return f.conn.WriteRow(row)
@@ -224,53 +456,93 @@ func (f *defaultSelectFunction) Done() error {
 	return f.conn.WriteRowEnd()
 }
 
-type countSelectFunction struct {
-	e          *Engine
-	conn       protocol.EngineConn
-	attributes []string
-	alias      []string
-	Count      int64
-}
+func inExecutor(e *Engine, inDecl *parser.Decl, p *Predicate, width int) error {
+	inDecl.Stringy(0)
 
-func (f *countSelectFunction) Init(e *Engine, conn protocol.EngineConn, attr []string, alias []string) error {
-	f.e = e
-	f.conn = conn
-	f.attributes = attr
-	f.alias = alias
-	return nil
-}
+	p.Operator = inOperator
+	p.known = listKnown
+
+	// Put everything in a []interface{}, NULL being nil, and tuples being []interface{} as well
+	var values []interface{}
+	for i := range inDecl.Decl {
+		log.Debug("inExecutor: Appending [%s]", inDecl.Decl[i].Lexeme)
+		d := inDecl.Decl[i]
+		if n := tupleWidth(d); n != width {
+			return fmt.Errorf("IN list element has %d values, expected %d", n, width)
+		}
+		switch {
+		case d.Token == parser.TupleToken:
+			tuple := &listExpression{}
+			for _, item := range d.Decl {
+				expr, err := expressionExecutor(e, item, nil, nil)
+				if err != nil {
+					return err
+				}
+				tuple.items = append(tuple.items, expr)
+			}
+			v, err := tuple.eval(virtualRow{})
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		case d.Token == parser.NullToken:
+			values = append(values, nil)
+		case isExpression(d):
+			// Constant expression, computed once
+			expr, err := expressionExecutor(e, d, nil, nil)
+			if err != nil {
+				return err
+			}
+			v, err := expr.eval(virtualRow{})
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		default:
+			values = append(values, d.Lexeme)
+		}
+	}
+	p.RightValue.v = values
 
-func (f *countSelectFunction) FeedVirtualRow(row virtualRow) error {
-	f.Count++
 	return nil
 }
 
-func (f *countSelectFunction) Done() error {
-	err := f.conn.WriteRowHeader(f.alias)
-	if err != nil {
-		return err
-	}
-
-	err = f.conn.WriteRow([]string{fmt.Sprintf("%d", f.Count)})
-	if err != nil {
-		return err
+// tupleWidth returns the number of values of an IN list element, which is 1 unless it is a tuple
+func tupleWidth(decl *parser.Decl) int {
+	if decl.Token == parser.TupleToken {
+		return len(decl.Decl)
 	}
-
-	return f.conn.WriteRowEnd()
+	return 1
 }
 
-func inExecutor(inDecl *parser.Decl, p *Predicate) error {
-	inDecl.Stringy(0)
+/*
+|-> between
+	|-> 18
+	|-> 65
+*/
+// betweenExecutor sets BETWEEN, or NOT BETWEEN if negated, operator and its bounds
+func betweenExecutor(e *Engine, betweenDecl *parser.Decl, negated bool, p *Predicate, tables []*Table, locked map[*Relation]bool) error {
+	if len(betweenDecl.Decl) != 2 {
+		return fmt.Errorf("BETWEEN: bounds not provided")
+	}
 
-	p.Operator = inOperator
+	bounds := &listExpression{}
+	for _, d := range betweenDecl.Decl {
+		expr, err := expressionExecutor(e, d, tables, locked)
+		if err != nil {
+			return err
+		}
+		bounds.items = append(bounds.items, expr)
+	}
 
-	// Put everything in a []string
-	var values []string
-	for i := range inDecl.Decl {
-		log.Debug("inExecutor: Appending [%s]", inDecl.Decl[i].Lexeme)
-		values = append(values, inDecl.Decl[i].Lexeme)
+	p.Operator = betweenOperator
+	if negated {
+		p.Operator = notBetweenOperator
 	}
-	p.RightValue.v = values
+	p.known = betweenKnown
+	p.RightValue.lexeme = betweenDecl.Lexeme
+	p.RightValue.valid = true
+	p.RightValue.expr = bounds
 
 	return nil
 }
@@ -283,15 +555,16 @@ func isExecutor(isDecl *parser.Decl, p *Predicate) error {
 	} else {
 		p.Operator = isNotNullOperator
 	}
+	p.known = alwaysKnown
 
 	return nil
 }
 
-func or(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string) (PredicateLinker, error) {
+func or(e *Engine, left []*parser.Decl, right []*parser.Decl, tables []*Table, locked map[*Relation]bool) (PredicateLinker, error) {
 	p := &orOperator{}
 
 	if len(left) > 0 {
-		lPred, err := whereExecutor2(e, left, tableName)
+		lPred, err := whereExecutor2(e, left, tables, locked)
 		if err != nil {
 			return nil, err
 		}
@@ -299,7 +572,7 @@ func or(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string)
 	}
 
 	if len(right) > 0 {
-		rPred, err := whereExecutor2(e, right, tableName)
+		rPred, err := whereExecutor2(e, right, tables, locked)
 		if err != nil {
 			return nil, err
 		}
@@ -309,11 +582,11 @@ func or(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string)
 	return p, nil
 }
 
-func and(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string) (PredicateLinker, error) {
+func and(e *Engine, left []*parser.Decl, right []*parser.Decl, tables []*Table, locked map[*Relation]bool) (PredicateLinker, error) {
 	p := &andOperator{}
 
 	if len(left) > 0 {
-		lPred, err := whereExecutor2(e, left, tableName)
+		lPred, err := whereExecutor2(e, left, tables, locked)
 		if err != nil {
 			return nil, err
 		}
@@ -321,7 +594,7 @@ func and(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string)
 	}
 
 	if len(right) > 0 {
-		rPred, err := whereExecutor2(e, right, tableName)
+		rPred, err := whereExecutor2(e, right, tables, locked)
 		if err != nil {
 			return nil, err
 		}
@@ -331,30 +604,47 @@ func and(e *Engine, left []*parser.Decl, right []*parser.Decl, tableName string)
 	return p, nil
 }
 
-func whereExecutor2(e *Engine, decl []*parser.Decl, fromTableName string) (PredicateLinker, error) {
-
-	for i, cond := range decl {
+/*
+|-> a
+	|-> =
+	|-> 1
+|-> or
+|-> not
+	|-> (
+		|-> b
+			|-> =
+			|-> 2
+		|-> and
+		|-> c
+			|-> =
+			|-> 3
+*/
+// whereExecutor2 returns the predicate of a list of conditions linked with AND or OR.
+// Like in SQL, OR has the lowest precedence, then AND, then NOT. Conditions between
+// brackets are a single one.
+func whereExecutor2(e *Engine, decl []*parser.Decl, tables []*Table, locked map[*Relation]bool) (PredicateLinker, error) {
+	if len(decl) == 0 {
+		return nil, fmt.Errorf("query error: no predicate given")
+	}
 
-		if cond.Token == parser.AndToken {
-			if i+1 == len(decl) {
-				return nil, fmt.Errorf("query error: AND not followed by any predicate")
+	for _, link := range []int{parser.OrToken, parser.AndToken} {
+		for i, cond := range decl {
+			if cond.Token != link {
+				continue
 			}
-
-			p, err := and(e, decl[:i], decl[i+1:], fromTableName)
-			return p, err
-		}
-
-		if cond.Token == parser.OrToken {
 			if i+1 == len(decl) {
-				return nil, fmt.Errorf("query error: OR not followd by any predicate")
+				return nil, fmt.Errorf("query error: %s not followed by any predicate", strings.ToUpper(cond.Lexeme))
+			}
+			if link == parser.OrToken {
+				return or(e, decl[:i], decl[i+1:], tables, locked)
 			}
-			p, err := or(e, decl[:i], decl[i+1:], fromTableName)
-			return p, err
+			return and(e, decl[:i], decl[i+1:], tables, locked)
 		}
 	}
 
 	p := &Predicate{}
 	var err error
+	var left *Attribute
 	cond := decl[0]
 
 	// 1 PREDICATE
@@ -362,208 +652,380 @@ func whereExecutor2(e *Engine, decl []*parser.Decl, fromTableName string) (Predi
 		return &TruePredicate, nil
 	}
 
-	switch cond.Decl[0].Token {
-	case parser.IsToken, parser.InToken, parser.EqualityToken, parser.LeftDipleToken, parser.RightDipleToken, parser.LessOrEqualToken, parser.GreaterOrEqualToken:
-		break
-	default:
-		fromTableName = cond.Decl[0].Lexeme
-		cond.Decl = cond.Decl[1:]
-		break
+	// Boolean constants
+	switch cond.Token {
+	case parser.TrueToken:
+		return &TruePredicate, nil
+	case parser.FalseToken:
+		return &notOperator{pred: &TruePredicate}, nil
 	}
 
-	p.LeftValue.lexeme = cond.Lexeme
+	// Conditions between brackets
+	if cond.Token == parser.BracketOpeningToken {
+		return whereExecutor2(e, cond.Decl, tables, locked)
+	}
 
-	if err := attributeExistsInTable(e, p.LeftValue.lexeme, fromTableName); err != nil {
-		return nil, err
+	// EXISTS and NOT EXISTS subqueries, or negated condition
+	if cond.Token == parser.NotToken && len(cond.Decl) > 0 && cond.Decl[0].Token != parser.ExistsToken {
+		pred, err := whereExecutor2(e, cond.Decl, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		return &notOperator{pred: pred}, nil
+	}
+	if cond.Token == parser.ExistsToken || cond.Token == parser.NotToken {
+		return existsSubqueryExecutor(e, cond, tables, locked)
+	}
+
+	conds := cond.Decl
+	width := 1
+	switch cond.Token {
+	case parser.TupleToken:
+		// Values of the tuple are followed by IN or NOT IN
+		tuple := &listExpression{}
+		for len(conds) > 0 && conds[0].Token != parser.InToken && conds[0].Token != parser.NotToken {
+			expr, err := expressionExecutor(e, conds[0], tables, locked)
+			if err != nil {
+				return nil, err
+			}
+			tuple.items = append(tuple.items, expr)
+			conds = conds[1:]
+		}
+		p.LeftValue.expr = tuple
+		p.LeftValue.lexeme = cond.Lexeme
+		width = len(tuple.items)
+	case parser.CountToken, parser.SumToken, parser.AvgToken, parser.MinToken, parser.MaxToken,
+		parser.GroupConcatToken, parser.StringAggToken:
+		// Aggregate value, in HAVING clause
+		f, err := aggregateExecutor(e, cond, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		p.LeftValue.lexeme = f.key
+		conds = conds[1:]
+		if len(conds) > 0 && conds[0].Token == parser.StringToken {
+			// Separator of GROUP_CONCAT or STRING_AGG
+			conds = conds[1:]
+		}
+	case parser.CaseToken, parser.PlusToken, parser.MinusToken, parser.MultiplyToken, parser.SlashToken, parser.FunctionToken,
+		parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken, parser.NumberToken, parser.QuotedStringToken:
+		// Operator and value follow expression decls, or constants like 'go' = ANY (tags)
+		p.LeftValue.expr, err = expressionExecutor(e, cond, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		p.LeftValue.lexeme = cond.Lexeme
+		conds = followingDecls(cond)
+	default:
+		// Attribute may be prefixed with its table
+		var tableName string
+		if len(conds) > 0 && conds[0].Token == parser.StringToken {
+			tableName = conds[0].Lexeme
+			conds = conds[1:]
+		}
+
+		t, err := resolveAttribute(cond.Lexeme, tableName, tables)
+		if err != nil {
+			return nil, err
+		}
+		p.LeftValue.lexeme = cond.Lexeme
+		p.LeftValue.table = t.name
+		if i := t.attributeIndex(cond.Lexeme); i >= 0 {
+			left = &t.attributes[i]
+		}
 	}
 
-	// Handle IN keyword
-	if cond.Decl[0].Token == parser.InToken {
-		err := inExecutor(cond.Decl[0], p)
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("Malformed predicate \"%s\"", cond.Lexeme)
+	}
+
+	// Handle IN and NOT IN keywords
+	if conds[0].Token == parser.InToken || (conds[0].Token == parser.NotToken && len(conds[0].Decl) > 0 && conds[0].Decl[0].Token == parser.InToken) {
+		inDecl := conds[0]
+		if inDecl.Token == parser.NotToken {
+			inDecl = inDecl.Decl[0]
+		}
+		if len(inDecl.Decl) > 0 && isQuery(inDecl.Decl[0]) {
+			err = inSubqueryExecutor(e, inDecl.Decl[0], p, width, locked)
+		} else {
+			err = inExecutor(e, inDecl, p, width)
+		}
 		if err != nil {
 			return nil, err
 		}
-		p.LeftValue.table = fromTableName
+		if values, ok := p.RightValue.v.([]interface{}); ok && left != nil {
+			// Literals are compared with values as they are stored, like with equality
+			for i := range values {
+				if s, ok := values[i].(string); ok {
+					values[i] = left.literal(s)
+				}
+			}
+		}
+		if conds[0].Token == parser.NotToken {
+			p.Operator = notInOperator
+		}
 		return p, nil
 	}
 
+	if cond.Token == parser.TupleToken {
+		return nil, fmt.Errorf("tuple can only be compared with IN or NOT IN")
+	}
+
 	// Handle IS NULL and IS NOT NULL
-	if cond.Decl[0].Token == parser.IsToken {
-		err := isExecutor(cond.Decl[0], p)
+	if conds[0].Token == parser.IsToken {
+		err := isExecutor(conds[0], p)
 		if err != nil {
 			return nil, err
 		}
-		p.LeftValue.table = fromTableName
 		return p, nil
 	}
 
-	if len(cond.Decl) < 2 {
+	// Handle BETWEEN and NOT BETWEEN
+	if betweenDecl, negated := betweenClause(conds[0]); betweenDecl != nil {
+		err := betweenExecutor(e, betweenDecl, negated, p, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	if len(conds) < 2 {
 		return nil, fmt.Errorf("Malformed predicate \"%s\"", cond.Lexeme)
 	}
 
 	// The first element of the list is then the relation of the attribute
-	op := cond.Decl[0]
-	val := cond.Decl[1]
+	op := conds[0]
+	val := conds[1]
+
+	// Comparison with ANY or ALL rows of a subquery
+	if val.Token == parser.AnyToken || val.Token == parser.AllToken {
+		err := quantifiedSubqueryExecutor(e, op, val, p, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
 
-	p.Operator, err = NewOperator(op.Token, op.Lexeme)
+	switch op.Token {
+	case parser.LikeToken, parser.ILikeToken, parser.NotToken:
+		p.Operator, err = likeExecutor(op, val)
+	default:
+		p.Operator, err = NewOperator(op.Token, op.Lexeme)
+	}
 	if err != nil {
 		return nil, err
 	}
 	p.RightValue.lexeme = val.Lexeme
 	p.RightValue.valid = true
+	p.equality = op.Token == parser.EqualityToken
+	if val.Token == parser.NullToken {
+		p.Operator = nullOperator
+		p.known = neverKnown
+		p.equality = false
+	}
+
+	// Right value may be an expression, or a qualified attribute as well
+	if isExpression(val) {
+		p.RightValue.expr, err = expressionExecutor(e, val, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(val.Decl) > 0 {
+		t, err := resolveAttribute(val.Lexeme, val.Decl[0].Lexeme, tables)
+		if err != nil {
+			return nil, err
+		}
+		p.RightValue.table = t.name
+	} else if left != nil && (val.Token == parser.NumberToken || val.Token == parser.StringToken) {
+		// Literal is compared with decimal or UUID values as they are stored
+		p.RightValue.lexeme = left.literal(val.Lexeme)
+	}
 
-	p.LeftValue.table = fromTableName
 	return p, nil
 }
 
 /*
-   |-> WHERE
-	   |-> email
-		   |-> =
-		   |-> foo@bar.com
+|-> FROM
+	|-> account
 */
-func whereExecutor(whereDecl *parser.Decl, fromTableName string) ([]Predicate, error) {
-	var predicates []Predicate
-	var err error
-	whereDecl.Stringy(0)
+func fromExecutor(fromDecl *parser.Decl) []*Table {
+	var tables []*Table
+	for _, t := range fromDecl.Decl {
+		tables = append(tables, NewTable(t.Lexeme))
+	}
 
-	for i := range whereDecl.Decl {
-		var p Predicate
-		tableName := fromTableName
-		cond := whereDecl.Decl[i]
+	return tables
+}
 
-		// 1 PREDICATE
-		if cond.Lexeme == "1" {
-			predicates = append(predicates, TruePredicate)
-			continue
-		}
+/*
+|-> account
+	|-> as
+		|-> a
+*/
+// tableReferenceExecutor returns the relation referenced in FROM or JOIN clause,
+// named after its alias if any. The actual relation is read locked and
+// its rows are shared with the returned one, so that a relation joined with
+// itself is locked once and scanned in each alias scope. A view is expanded
+// to the rows it selects.
+func tableReferenceExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (*Relation, error) {
+	name := decl.Lexeme
+	if alias := selectedAlias(decl); alias != "" {
+		name = alias
+	}
 
-		if len(cond.Decl) == 0 {
-			log.Debug("whereExecutor: HUm hum you must be AND or OR: %v", cond)
-			continue
+	for _, t := range tables {
+		if t.name == name {
+			return nil, fmt.Errorf("table name \"%s\" specified more than once", name)
 		}
+	}
 
-		switch cond.Decl[0].Token {
-		case parser.EqualityToken, parser.LeftDipleToken, parser.RightDipleToken, parser.LessOrEqualToken, parser.GreaterOrEqualToken:
-			log.Debug("whereExecutor: it's = < > <= >=\n")
-			break
-		case parser.InToken:
-			log.Debug("whereExecutor: it's IN\n")
-			break
-		case parser.IsToken:
-			log.Debug("whereExecutor: it's IS token\n")
-			log.Debug("whereExecutor: %+v\n", cond.Decl[0])
-			break
-		default:
-			log.Debug("it's the table name ! -> %s", cond.Decl[0].Lexeme)
-			tableName = cond.Decl[0].Lexeme
-			cond.Decl = cond.Decl[1:]
-			break
-		}
+	if isQuery(decl) {
+		return derivedTableExecutor(e, decl, name, locked)
+	}
+
+	if isWorkTable(decl) {
+		return workTableExecutor(e, decl, name)
+	}
 
-		p.LeftValue.lexeme = whereDecl.Decl[i].Lexeme
+	if isInformationSchema(decl) {
+		return informationSchemaExecutor(e, decl.Lexeme, name)
+	}
+
+	if v := e.view(decl.Lexeme); v != nil {
+		return viewRelationExecutor(e, v, name, locked)
+	}
+
+	r := e.relation(decl.Lexeme)
+	if r == nil {
+		return nil, &Error{Code: UndefinedTable, Table: decl.Lexeme, Message: fmt.Sprintf("table \"%s\" does not exist", decl.Lexeme)}
+	}
+	if !locked[r] {
+		r.RLock()
+		locked[r] = true
+	}
+
+	if name == r.table.name {
+		return r, nil
+	}
+
+	aliased := &Relation{
+		table:   &Table{name: name, attributes: r.table.attributes, aliased: r.table.name},
+		rows:    r.rows,
+		indexes: r.indexes,
+	}
+	return aliased, nil
+}
+
+// getSelectedAttribute returns virtual row keys of selected attribute, and their column name.
+// Column name is qualified if attribute was, or if selected with * and present in several tables.
+// A naked * selects attributes of tables listed in FROM clause, not joined ones.
+func getSelectedAttribute(attr *parser.Decl, tables []*Table, fromTables []*Table) ([]string, []string, error) {
+	var header []string
+	var alias []string
 
-		// Handle IN keyword
-		if cond.Decl[0].Token == parser.InToken {
-			err := inExecutor(cond.Decl[0], &p)
+	switch attr.Token {
+	case parser.StarToken:
+		selected := fromTables
+		if len(attr.Decl) > 0 {
+			t, err := scopeTable(attr.Decl[0].Lexeme, tables)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			p.LeftValue.table = tableName
-			predicates = append(predicates, p)
-			continue
+			selected = []*Table{t}
 		}
-
-		// Handle IS NULL and IS NOT NULL
-		if cond.Decl[0].Token == parser.IsToken {
-			err := isExecutor(cond.Decl[0], &p)
-			if err != nil {
-				return nil, err
+		for _, t := range selected {
+			for _, a := range t.attributes {
+				header = append(header, t.name+"."+a.name)
+				if _, err := resolveAttribute(a.name, "", selected); err != nil {
+					alias = append(alias, t.name+"."+a.name)
+				} else {
+					alias = append(alias, a.name)
+				}
 			}
-			p.LeftValue.table = tableName
-			predicates = append(predicates, p)
-			continue
 		}
-
-		if len(cond.Decl) < 2 {
-			return nil, fmt.Errorf("Malformed predicate \"%s\"", cond.Lexeme)
+	case parser.StringToken:
+		var tableName string
+		name := attr.Lexeme
+		if len(attr.Decl) > 0 && attr.Decl[0].Token == parser.StringToken {
+			tableName = attr.Decl[0].Lexeme
+			name = tableName + "." + attr.Lexeme
 		}
-
-		// The first element of the list is then the relation of the attribute
-		op := cond.Decl[0]
-		val := cond.Decl[1]
-
-		p.Operator, err = NewOperator(op.Token, op.Lexeme)
+		if a := selectedAlias(attr); a != "" {
+			name = a
+		}
+		t, err := resolveAttribute(attr.Lexeme, tableName, tables)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		p.RightValue.lexeme = val.Lexeme
-		p.RightValue.valid = true
-
-		p.LeftValue.table = tableName
-
-		predicates = append(predicates, p)
+		header = append(header, t.name+"."+attr.Lexeme)
+		alias = append(alias, name)
 	}
 
-	if len(predicates) == 0 {
-		return nil, fmt.Errorf("No predicates provided")
+	return header, alias, nil
+}
+
+// isQuery returns true if decl is a SELECT statement, a combination of them, or a recursive query
+func isQuery(decl *parser.Decl) bool {
+	switch decl.Token {
+	case parser.SelectToken, parser.UnionToken, parser.IntersectToken, parser.ExceptToken, parser.RecursiveToken:
+		return true
 	}
 
-	return predicates, nil
+	return false
 }
 
-/*
-|-> FROM
-	|-> account
-*/
-func fromExecutor(fromDecl *parser.Decl) []*Table {
-	var tables []*Table
-	for _, t := range fromDecl.Decl {
-		tables = append(tables, NewTable(t.Lexeme))
+// selectedAlias returns the name given with AS to a table or a selected value, if any
+func selectedAlias(decl *parser.Decl) string {
+	for _, d := range decl.Decl {
+		if d.Token == parser.AsToken && len(d.Decl) > 0 {
+			return d.Decl[0].Lexeme
+		}
 	}
 
-	return tables
+	return ""
 }
 
-func getSelectedAttribute(e *Engine, attr *parser.Decl, tables []*Table) ([]Attribute, error) {
-	var attributes []Attribute
-	var t []string
+// selectedColumn returns the virtual row key of the selected value named name,
+// or an empty string if there is none
+func selectedColumn(name string, header []string, alias []string) (string, error) {
+	var key string
 
-	for i := range tables {
-		t = append(t, tables[i].name)
+	for i, a := range alias {
+		if a != name || header[i] == key {
+			continue
+		}
+		if key != "" {
+			return "", &Error{Code: AmbiguousColumn, Column: name, Message: fmt.Sprintf("column reference \"%s\" is ambiguous", name)}
+		}
+		key = header[i]
 	}
 
-	switch attr.Token {
-	case parser.StarToken:
-		for _, table := range tables {
-			r := e.relation(table.name)
-			if r == nil {
-				return nil, errors.New("Relation " + table.name + " not found")
-			}
-			attributes = append(attributes, r.table.attributes...)
+	return key, nil
+}
+
+// checkGroupedAttributes ensures every selected attribute is either
+// a grouping attribute, an aggregate, an expression or a window function
+func checkGroupedAttributes(header []string, alias []string, g *groupbyFunctor, expressions []*selectedExpression, windows []*windowFunction) error {
+	for i, h := range header {
+		found := false
+		for _, k := range g.keys {
+			found = found || k == h
 		}
-	case parser.CountToken:
-		err := attributesExistInTables(e, []Attribute{NewAttribute(attr.Decl[0].Lexeme, "", false)}, t)
-		if err != nil && attr.Decl[0].Lexeme != "*" {
-			return nil, err
+		for _, a := range g.aggregates {
+			found = found || a.key == h
 		}
-		attributes = append(attributes, NewAttribute("COUNT", "int", false))
-	case parser.StringToken:
-		attribute := attr.Lexeme
-		if len(attr.Decl) > 0 {
-			if err := attributeExistsInTable(e, attr.Lexeme, attr.Decl[0].Lexeme); err != nil {
-				return nil, err
-			}
-			attribute = attr.Decl[0].Lexeme + "." + attribute
+		for _, s := range expressions {
+			found = found || s.key == h
 		}
-		newAttr := NewAttribute(attribute, "text", false)
-		if err := attributesExistInTables(e, []Attribute{newAttr}, t); err != nil {
-			return nil, err
+		for _, w := range windows {
+			found = found || w.key == h
+		}
+		if !found {
+			return fmt.Errorf("attribute %s must appear in the GROUP BY clause or be used in an aggregate function", alias[i])
 		}
-		attributes = append(attributes, newAttr)
 	}
 
-	return attributes, nil
+	return nil
 }
 
 // Perform actual check of predicates present in virtualrow.
@@ -2,6 +2,7 @@ package engine_test
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -151,3 +152,364 @@ func TestCount(t *testing.T) {
 	}
 
 }
+
+func TestSelectDistinct(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSelectDistinct")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE tasks (id BIGSERIAL, status TEXT, owner TEXT)`,
+		`INSERT INTO tasks (status, owner) VALUES ('done', 'riri')`,
+		`INSERT INTO tasks (status, owner) VALUES ('todo', 'riri')`,
+		`INSERT INTO tasks (status, owner) VALUES ('done', 'fifi')`,
+		`INSERT INTO tasks (status, owner) VALUES ('done', 'riri')`,
+		`INSERT INTO tasks (owner) VALUES ('loulou')`,
+		`INSERT INTO tasks (owner) VALUES ('loulou')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: Error: %s\n", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT status FROM tasks`)
+	if err != nil {
+		t.Fatalf("Cannot select distinct: %s", err)
+	}
+	var statuses []sql.NullString
+	for rows.Next() {
+		var s sql.NullString
+		if err := rows.Scan(&s); err != nil {
+			t.Fatalf("Cannot scan: %s", err)
+		}
+		statuses = append(statuses, s)
+	}
+	rows.Close()
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 distinct status (including NULL), got %v", statuses)
+	}
+
+	rows, err = db.Query(`SELECT DISTINCT owner, status FROM tasks WHERE status = 'done' ORDER BY owner ASC`)
+	if err != nil {
+		t.Fatalf("Cannot select distinct with order by: %s", err)
+	}
+	defer rows.Close()
+
+	var owners []string
+	for rows.Next() {
+		var owner, status string
+		if err := rows.Scan(&owner, &status); err != nil {
+			t.Fatalf("Cannot scan: %s", err)
+		}
+		owners = append(owners, owner)
+	}
+	if len(owners) != 2 || owners[0] != "fifi" || owners[1] != "riri" {
+		t.Fatalf("Expected [fifi riri], got %v", owners)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestBetween")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE people (id BIGSERIAL, name TEXT, age INT, born TIMESTAMP)`,
+		`INSERT INTO people (name, age, born) VALUES ('alice', 17, '2006-03-01T00:00:00Z')`,
+		`INSERT INTO people (name, age, born) VALUES ('bob', 18, '2005-07-14T00:00:00Z')`,
+		`INSERT INTO people (name, age, born) VALUES ('carol', 42, '1981-11-30T00:00:00Z')`,
+		`INSERT INTO people (name, age, born) VALUES ('dave', 65, '1958-01-01T00:00:00Z')`,
+		`INSERT INTO people (name, age) VALUES ('erin', 70)`,
+		`INSERT INTO people (name) VALUES ('frank')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT name FROM people WHERE age BETWEEN 18 AND 65 ORDER BY id`:                               {"bob", "carol", "dave"},
+		`SELECT name FROM people WHERE age NOT BETWEEN 18 AND 65 ORDER BY id`:                           {"alice", "erin"},
+		`SELECT name FROM people WHERE age BETWEEN 18 AND 65 AND age > 20 ORDER BY id`:                  {"carol", "dave"},
+		`SELECT name FROM people WHERE name BETWEEN 'b' AND 'd' ORDER BY id`:                            {"bob", "carol"},
+		`SELECT name FROM people WHERE born BETWEEN '1970-01-01' AND '2005-12-31' ORDER BY id`:          {"bob", "carol"},
+		`SELECT name FROM people WHERE age BETWEEN 10 + 8 AND 60 OR name = 'frank' ORDER BY id`:         {"bob", "carol", "frank"},
+		`SELECT name FROM people WHERE age BETWEEN NULL AND 65 ORDER BY id`:                             {},
+		`SELECT name FROM people WHERE age NOT BETWEEN NULL AND 40 ORDER BY id`:                         {"carol", "dave", "erin"},
+		`SELECT name FROM people WHERE age NOT BETWEEN 18 AND NULL ORDER BY id`:                         {"alice"},
+		`SELECT CASE WHEN age BETWEEN 18 AND 64 THEN 'adult' ELSE 'other' END FROM people WHERE id < 5`: {"other", "adult", "adult", "other"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			res = append(res, v)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	res, err := db.Exec(`UPDATE people SET name = 'teen' WHERE age BETWEEN 13 AND 19`)
+	if err != nil {
+		t.Fatalf("Cannot update with BETWEEN: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 updated rows, got %d", n)
+	}
+
+	res, err = db.Exec(`DELETE FROM people WHERE age NOT BETWEEN 18 AND 65`)
+	if err != nil {
+		t.Fatalf("Cannot delete with NOT BETWEEN: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 deleted rows, got %d", n)
+	}
+}
+
+func TestInList(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInList")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE tickets (id BIGSERIAL, status TEXT, priority INT)`,
+		`INSERT INTO tickets (status, priority) VALUES ('open', 1)`,
+		`INSERT INTO tickets (status, priority) VALUES ('pending', '-1')`,
+		`INSERT INTO tickets (status, priority) VALUES ('closed', 3)`,
+		`INSERT INTO tickets (status, priority) VALUES ('archived', 2)`,
+		`INSERT INTO tickets (priority) VALUES (5)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT id FROM tickets WHERE status IN ('open', 'pending', 'closed') ORDER BY id`: {"1", "2", "3"},
+		`SELECT id FROM tickets WHERE status IN ('archived')`:                              {"4"},
+		`SELECT id FROM tickets WHERE priority IN (1, 3) ORDER BY id`:                      {"1", "3"},
+		`SELECT id FROM tickets WHERE priority IN (-1, 1 + 1) ORDER BY id`:                 {"2", "4"},
+		`SELECT id FROM tickets WHERE id IN (2, 4) ORDER BY id`:                            {"2", "4"},
+		`SELECT id FROM tickets WHERE status IN ('open', NULL) ORDER BY id`:                {"1"},
+		`SELECT id FROM tickets WHERE status NOT IN ('open', 'pending') ORDER BY id`:       {"3", "4"},
+		`SELECT id FROM tickets WHERE status NOT IN ('open', NULL)`:                        {},
+		`SELECT id FROM tickets WHERE priority NOT IN (1, 2, 3) AND status IN ('pending')`: {"2"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			res = append(res, v)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	res, err := db.Exec(`DELETE FROM tickets WHERE status IN ('closed', 'archived')`)
+	if err != nil {
+		t.Fatalf("Cannot delete with IN: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 deleted rows, got %d", n)
+	}
+}
+
+func TestInTuple(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInTuple")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE stock (id BIGSERIAL, warehouse INT, sku TEXT)`,
+		`CREATE TABLE recall (warehouse INT, sku TEXT)`,
+		`INSERT INTO stock (warehouse, sku) VALUES (1, 'x')`,
+		`INSERT INTO stock (warehouse, sku) VALUES (1, 'y')`,
+		`INSERT INTO stock (warehouse, sku) VALUES (2, 'y')`,
+		`INSERT INTO stock (warehouse) VALUES (2)`,
+		`INSERT INTO recall (warehouse, sku) VALUES (1, 'y')`,
+		`INSERT INTO recall (warehouse, sku) VALUES (2, 'y')`,
+		`INSERT INTO recall (warehouse, sku) VALUES (3, 'z')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT id FROM stock WHERE (warehouse, sku) IN ((1, 'x'), (2, 'y')) ORDER BY id`:                    {"1", "3"},
+		`SELECT id FROM stock WHERE (warehouse, sku) IN ((2, 'x'))`:                                          {},
+		`SELECT id FROM stock WHERE (warehouse, sku) NOT IN ((1, 'x'), (2, 'y')) ORDER BY id`:                {"2"},
+		`SELECT id FROM stock WHERE (warehouse, sku) IN (SELECT warehouse, sku FROM recall) ORDER BY id`:     {"2", "3"},
+		`SELECT id FROM stock WHERE (warehouse, sku) NOT IN (SELECT warehouse, sku FROM recall) ORDER BY id`: {"1"},
+		`SELECT id FROM stock WHERE (warehouse, sku) NOT IN ((1, NULL)) ORDER BY id`:                         {"3", "4"},
+		`SELECT id FROM stock WHERE (warehouse, sku) IN ((2, NULL))`:                                         {},
+		`SELECT id FROM stock WHERE (warehouse + 1, sku) IN ((2, 'x'), (3, 'y')) AND id > 1 ORDER BY id`:     {"3"},
+		`SELECT id FROM stock WHERE ((stock.warehouse, sku) IN ((1, 'y')) OR sku IS NULL) ORDER BY id`:       {"2", "4"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("%s: cannot scan row: %s", query, err)
+			}
+			res = append(res, v)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	failing := []string{
+		`SELECT id FROM stock WHERE (warehouse, sku) IN ((1, 'x', 2))`,
+		`SELECT id FROM stock WHERE (warehouse, sku) IN (1, 2)`,
+		`SELECT id FROM stock WHERE (warehouse, sku) IN (SELECT warehouse FROM recall)`,
+		`SELECT id FROM stock WHERE (warehouse, sku) = (1, 'x')`,
+	}
+	for _, query := range failing {
+		if _, err := db.Query(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+}
+
+func TestSelectAlias(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSelectAlias")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE product (id INT, name TEXT, price INT)`,
+		`INSERT INTO product (id, name, price) VALUES (1, 'pen', 3)`,
+		`INSERT INTO product (id, name, price) VALUES (2, 'book', 12)`,
+		`INSERT INTO product (id, name, price) VALUES (3, 'bag', 12)`,
+		`INSERT INTO product (id, name, price) VALUES (4, 'ink', 7)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string]struct {
+		columns []string
+		rows    []string
+	}{
+		`SELECT count(*) AS n, price amount FROM product GROUP BY price ORDER BY amount`: {[]string{"n", "amount"}, []string{"1 3", "1 7", "2 12"}},
+		`SELECT name, price * 2 AS double FROM product ORDER BY double DESC, name`:       {[]string{"name", "double"}, []string{"bag 24", "book 24", "ink 14", "pen 6"}},
+		`SELECT price AS p, count(*) AS n FROM product GROUP BY p ORDER BY n DESC, p`:    {[]string{"p", "n"}, []string{"12 2", "3 1", "7 1"}},
+		`SELECT name AS price, price AS name FROM product WHERE id < 3 ORDER BY price`:   {[]string{"price", "name"}, []string{"book 12", "pen 3"}},
+		`SELECT id, price + 1, upper(name) FROM product WHERE id = 1`:                    {[]string{"id", "?column?", "upper"}, []string{"1 4 PEN"}},
+		`SELECT max(price), min(price) m FROM product`:                                   {[]string{"max", "m"}, []string{"12 3"}},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("%s: cannot get columns: %s", query, err)
+		}
+		if strings.Join(columns, ",") != strings.Join(expected.columns, ",") {
+			t.Fatalf("%s: expected columns %v, got %v", query, expected.columns, columns)
+		}
+		var res []string
+		for rows.Next() {
+			values := make([]string, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, strings.Join(values, " "))
+		}
+		rows.Close()
+		if strings.Join(res, ",") != strings.Join(expected.rows, ",") {
+			t.Fatalf("%s: expected %v, got %v", query, expected.rows, res)
+		}
+	}
+
+	_, err = db.Query(`SELECT name AS x, price AS x FROM product ORDER BY x`)
+	if err == nil {
+		t.Fatalf("expected ambiguous ORDER BY reference to fail")
+	}
+	_, err = db.Query(`SELECT price * 2 AS double, count(*) FROM product GROUP BY double`)
+	if err == nil {
+		t.Fatalf("expected GROUP BY a computed value to fail")
+	}
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// sequence allocates values of an auto increment attribute, or of a sequence created with
+// CREATE SEQUENCE. An attribute one is shared by all copies of attribute, and only used while
+// its relation is write locked, so concurrent inserts never get the same value. Sequence
+// objects are shared by all connections. Like PostgreSQL ones, their values are not
+// transactional: they are never given back, even if transaction is rolled back.
+type sequence struct {
+	sync.Mutex
+	last      int64
+	increment int64
+}
+
+// next allocates the value following the last one
+func (s *sequence) next() int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	s.last += s.increment
+	return s.last
+}
+
+// restart makes sequence allocate its first value again
+func (s *sequence) restart() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.last = 0
+}
+
+// advance makes sure values allocated afterward are greater than v
+func (s *sequence) advance(v int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if v > s.last {
+		s.last = v
+	}
+}
+
+// set makes v the last allocated value, or the next one if called is not set
+func (s *sequence) set(v int64, called bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.last = v
+	if !called {
+		s.last -= s.increment
+	}
+}
+
+/*
+|-> sequence
+	|-> order_id
+	|-> start
+		|-> 100
+	|-> increment
+		|-> 1
+*/
+// createSequenceExecutor creates a sequence, counting from 1 by default,
+// or from -1 if it is decreasing
+func createSequenceExecutor(e *Engine, sequenceDecl *parser.Decl, conn protocol.EngineConn) error {
+	if len(sequenceDecl.Decl) < 1 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+	name := sequenceDecl.Decl[0].Lexeme
+
+	s := &sequence{increment: 1}
+	var start *int64
+	for _, d := range sequenceDecl.Decl[1:] {
+		if len(d.Decl) != 1 {
+			return fmt.Errorf("parsing failed, malformed query")
+		}
+		v, err := strconv.ParseInt(d.Decl[0].Lexeme, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %s for sequence \"%s\"", d.Lexeme, d.Decl[0].Lexeme, name)
+		}
+		switch d.Lexeme {
+		case "start":
+			start = &v
+		case "increment":
+			if v == 0 {
+				return fmt.Errorf("INCREMENT must not be zero")
+			}
+			s.increment = v
+		}
+	}
+
+	switch {
+	case start != nil:
+		s.last = *start - s.increment
+	case s.increment < 0:
+		s.last = -1 - s.increment
+	}
+
+	if e.relation(name) != nil || e.view(name) != nil {
+		return &Error{Code: DuplicateTable, Table: name, Message: fmt.Sprintf("relation \"%s\" already exists", name)}
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.sequences[name]; ok {
+		return &Error{Code: DuplicateTable, Table: name, Message: fmt.Sprintf("relation \"%s\" already exists", name)}
+	}
+	e.sequences[name] = s
+
+	return conn.WriteResult(0, 1)
+}
+
+// dropSequenceExecutor removes a sequence. With ifExists, dropping a missing sequence does nothing.
+func dropSequenceExecutor(e *Engine, name string, ifExists bool, conn protocol.EngineConn) error {
+	e.Lock()
+	_, ok := e.sequences[name]
+	delete(e.sequences, name)
+	e.Unlock()
+
+	if !ok {
+		if ifExists {
+			return conn.WriteResult(0, 0)
+		}
+		return &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("sequence \"%s\" does not exist", name)}
+	}
+
+	return conn.WriteResult(0, 1)
+}
+
+// sequence returns named sequence, or nil if there is none
+func (e *Engine) sequence(name string) *sequence {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.sequences[name]
+}
+
+// sequenceExpression is a call to nextval, currval or setval,
+// whose first argument is the name of the sequence
+type sequenceExpression struct {
+	e        *Engine
+	function string
+	args     []expression
+}
+
+func (s *sequenceExpression) eval(row virtualRow) (interface{}, error) {
+	var args []interface{}
+	for _, arg := range s.args {
+		v, err := arg.eval(row)
+		if v == nil || err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	name := stringValue(args[0])
+	seq := s.e.sequence(name)
+	if seq == nil {
+		return nil, &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("relation \"%s\" does not exist", name)}
+	}
+
+	// Sequences are written by nextval and setval
+	if s.function != "currval" && s.e.readOnly() {
+		return nil, errorf(ReadOnlySQLTransaction, "cannot execute %s() in a read-only transaction", s.function)
+	}
+
+	switch s.function {
+	case "nextval":
+		v := seq.next()
+		s.e.currvals[name] = v
+		return v, nil
+	case "currval":
+		v, ok := s.e.currvals[name]
+		if !ok {
+			return nil, fmt.Errorf("currval of sequence \"%s\" is not yet defined in this session", name)
+		}
+		return v, nil
+	}
+
+	// setval
+	v, err := integerValue(args[1])
+	if err != nil {
+		return nil, err
+	}
+	called := true
+	if len(args) > 2 {
+		b, err := castFunction([]interface{}{args[2], "boolean"})
+		if err != nil {
+			return nil, err
+		}
+		called = b.(bool)
+	}
+	seq.set(v, called)
+
+	return v, nil
+}
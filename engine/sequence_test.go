@@ -0,0 +1,170 @@
+package engine_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestSequence(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestSequence")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer conn.Close()
+	other, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("cannot get connection: %s", err)
+	}
+	defer other.Close()
+
+	batch := []string{
+		`CREATE TABLE one (id INT)`,
+		`INSERT INTO one (id) VALUES (1)`,
+		`CREATE SEQUENCE order_id START 100`,
+		`CREATE SEQUENCE countdown START WITH 10 INCREMENT BY -2`,
+		`CREATE SEQUENCE counter`,
+		`CREATE TABLE orders (id BIGINT DEFAULT nextval('order_id'), start TEXT)`,
+		`INSERT INTO orders (start) VALUES ('a')`,
+		`INSERT INTO orders (id, start) VALUES (nextval('order_id'), 'b')`,
+		`INSERT INTO orders (start) VALUES ('c')`,
+	}
+	for _, b := range batch {
+		if _, err := conn.ExecContext(ctx, b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT id FROM orders ORDER BY start`)
+	if err != nil {
+		t.Fatalf("cannot select orders: %s", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("cannot scan row: %s", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 3 || ids[0] != 100 || ids[1] != 101 || ids[2] != 102 {
+		t.Fatalf("expected ids 100, 101 and 102, got %v", ids)
+	}
+
+	value := func(query string) int64 {
+		var v int64
+		if err := conn.QueryRowContext(ctx, query).Scan(&v); err != nil {
+			t.Fatalf("cannot query '%s': %s", query, err)
+		}
+		return v
+	}
+	expected := []struct {
+		query string
+		value int64
+	}{
+		{`SELECT currval('order_id') FROM one`, 102},
+		{`SELECT nextval('counter') FROM one`, 1},
+		{`SELECT nextval('counter') + 10 FROM one`, 12},
+		{`SELECT nextval('countdown') FROM one`, 10},
+		{`SELECT nextval('countdown') FROM one`, 8},
+		{`SELECT setval('counter', 200) FROM one`, 200},
+		{`SELECT currval('counter') FROM one`, 2},
+		{`SELECT nextval('counter') FROM one`, 201},
+		{`SELECT setval('counter', 300, false) FROM one`, 300},
+		{`SELECT nextval('counter') FROM one`, 300},
+	}
+	for _, e := range expected {
+		if v := value(e.query); v != e.value {
+			t.Fatalf("%s: expected %d, got %d", e.query, e.value, v)
+		}
+	}
+
+	// Values are not given back on rollback
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO orders (start) VALUES ('d')`); err != nil {
+		t.Fatalf("cannot insert: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("cannot rollback: %s", err)
+	}
+	if v := value(`SELECT nextval('order_id') FROM one`); v != 104 {
+		t.Fatalf("expected 104, got %d", v)
+	}
+
+	// currval is only defined once nextval has been called in the same session
+	var v int64
+	err = other.QueryRowContext(ctx, `SELECT currval('order_id') FROM one`).Scan(&v)
+	if err == nil || err.Error() != `currval of sequence "order_id" is not yet defined in this session` {
+		t.Fatalf("expected currval to be undefined, got %d (%v)", v, err)
+	}
+	if err := other.QueryRowContext(ctx, `SELECT nextval('order_id') FROM one`).Scan(&v); err != nil || v != 105 {
+		t.Fatalf("expected 105, got %d (%v)", v, err)
+	}
+	if v := value(`SELECT currval('order_id') FROM one`); v != 104 {
+		t.Fatalf("expected currval of session to be 104, got %d", v)
+	}
+
+	// Concurrent connections never get the same value
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				var v int64
+				if err := db.QueryRow(`SELECT nextval('counter') FROM one`).Scan(&v); err != nil {
+					t.Errorf("cannot get next value: %s", err)
+					return
+				}
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 100 {
+		t.Fatalf("expected 100 distinct values, got %d", len(seen))
+	}
+
+	failing := []string{
+		`CREATE SEQUENCE counter`,
+		`CREATE SEQUENCE orders`,
+		`CREATE SEQUENCE bad INCREMENT 0`,
+		`SELECT nextval('missing') FROM one`,
+		`DROP SEQUENCE missing`,
+	}
+	for _, query := range failing {
+		if _, err := conn.ExecContext(ctx, query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `DROP SEQUENCE counter`); err != nil {
+		t.Fatalf("cannot drop sequence: %s", err)
+	}
+	if _, err := conn.ExecContext(ctx, `DROP SEQUENCE IF EXISTS counter`); err != nil {
+		t.Fatalf("cannot drop missing sequence: %s", err)
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT nextval('counter') FROM one`).Scan(&v); err == nil {
+		t.Fatalf("expected dropped sequence to be missing")
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+/*
+|-> show
+	|-> columns
+		|-> users
+*/
+// showExecutor lists tables sorted by name, or the columns of a table in order,
+// as information_schema describes them
+func showExecutor(e *Engine, showDecl *parser.Decl, conn protocol.EngineConn) error {
+	whatDecl := showDecl.Decl[0]
+
+	var header []string
+	var rows [][]*string
+	switch whatDecl.Lexeme {
+	case "tables":
+		tables, err := informationSchemaExecutor(e, "tables", "tables")
+		if err != nil {
+			return err
+		}
+		header = []string{"table_name"}
+		for _, t := range tables.rows {
+			if t.Values[2] == "BASE TABLE" {
+				rows = append(rows, []*string{rowValue(t.Values[1])})
+			}
+		}
+	case "columns":
+		name := whatDecl.Decl[0].Lexeme
+		if e.relation(name) == nil {
+			return &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("table \"%s\" does not exist", name)}
+		}
+		columns, err := informationSchemaExecutor(e, "columns", "columns")
+		if err != nil {
+			return err
+		}
+		header = []string{"column_name", "data_type", "is_nullable", "column_default"}
+		for _, t := range columns.rows {
+			if t.Values[1] == name {
+				rows = append(rows, []*string{
+					rowValue(t.Values[2]),
+					rowValue(t.Values[4]),
+					rowValue(t.Values[5]),
+					rowValue(t.Values[6]),
+				})
+			}
+		}
+	}
+
+	if err := conn.WriteRowHeader(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := conn.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return conn.WriteRowEnd()
+}
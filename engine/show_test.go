@@ -0,0 +1,78 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestShow(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestShow")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT NOT NULL, active BOOLEAN DEFAULT false)`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id BIGINT)`,
+		`CREATE TABLE accounts (id INT)`,
+		`CREATE VIEW active_users AS SELECT * FROM users WHERE active = true`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("sql.Query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		columns, _ := rows.Columns()
+		var got []string
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatalf("rows.Scan: %s", err)
+			}
+			var row []string
+			for _, v := range values {
+				if !v.Valid {
+					row = append(row, "NULL")
+					continue
+				}
+				row = append(row, v.String)
+			}
+			got = append(got, strings.Join(row, "|"))
+		}
+		return strings.Join(got, ",")
+	}
+
+	expected := map[string]string{
+		`SHOW TABLES`:              "accounts,orders,users",
+		`show columns from users`:  "id|bigserial|NO|NULL,name|text|NO|NULL,active|boolean|YES|false",
+		`SHOW COLUMNS FROM orders`: "id|bigserial|NO|NULL,user_id|bigint|YES|NULL",
+	}
+	for query, want := range expected {
+		if got := rows(query); got != want {
+			t.Fatalf("expected '%s' for '%s', got '%s'", want, query, got)
+		}
+	}
+
+	if _, err = db.Query(`SHOW COLUMNS FROM nope`); err == nil {
+		t.Fatalf("expected error showing columns of unknown table")
+	}
+}
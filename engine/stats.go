@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"time"
+)
+
+// Stats are runtime statistics of a database
+type Stats struct {
+	// Tables is the number of tables, without views
+	Tables int
+	// Views is the number of views
+	Views int
+	// Indexes is the number of indexes
+	Indexes int
+	// Rows is the number of rows of each table, by name
+	Rows map[string]int
+	// Memory is a rough estimate, in bytes, of memory used by rows and indexes
+	Memory int64
+}
+
+// Sizes used to estimate memory, of a pointer, an interface and a slice header
+const (
+	pointerSize   = 8
+	interfaceSize = 16
+	sliceSize     = 24
+)
+
+// Stats returns statistics of the database. Rows written by uncommitted transactions
+// are not counted.
+func (e *Engine) Stats() Stats {
+	e.Lock()
+	relations := make([]*Relation, 0, len(e.relations))
+	for _, r := range e.relations {
+		relations = append(relations, r)
+	}
+	s := Stats{
+		Tables:  len(e.relations),
+		Views:   len(e.views),
+		Indexes: len(e.indexes),
+		Rows:    make(map[string]int, len(e.relations)),
+	}
+	e.Unlock()
+
+	for _, r := range relations {
+		r.RLock()
+		s.Rows[r.table.name] = len(r.rows)
+		s.Memory += r.memory()
+		r.RUnlock()
+	}
+
+	return s
+}
+
+// memory returns an estimate of memory used by rows and indexes of relation, which must be read locked
+func (r *Relation) memory() int64 {
+	m := int64(sliceSize + pointerSize*len(r.rows))
+
+	for _, row := range r.rows {
+		m += sliceSize + int64(interfaceSize*len(row.Values))
+		for _, v := range row.Values {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				m += int64(len(v))
+			case []byte:
+				m += sliceSize + int64(len(v))
+			case time.Time:
+				m += 24
+			default:
+				m += pointerSize
+			}
+		}
+	}
+
+	for _, i := range r.indexes {
+		for k, rows := range i.rows {
+			m += int64(len(k)) + sliceSize + int64(pointerSize*len(rows))
+		}
+	}
+
+	return m
+}
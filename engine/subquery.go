@@ -0,0 +1,410 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/log"
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// resultConn is an in memory EngineConn keeping rows selected by a subquery,
+// NULL values being nil.
+type resultConn struct {
+	header []string
+	// types are the types of selected columns, unknown if nil
+	types []protocol.ColumnType
+	rows  [][]interface{}
+}
+
+// Not needed
+func (c *resultConn) ReadStatement() (string, error) {
+	log.Debug("resultConn.ReadStatement: should not be used\n")
+	return "", nil
+}
+
+// Not needed
+func (c *resultConn) WriteResult(last int64, ra int64) error {
+	log.Debug("resultConn.WriteResult: should not be used\n")
+	return nil
+}
+
+func (c *resultConn) WriteError(err error) error {
+	return err
+}
+
+func (c *resultConn) WriteRowHeader(header []string) error {
+	c.header = header
+	return nil
+}
+
+func (c *resultConn) WriteTypedRowHeader(header []string, types []protocol.ColumnType) error {
+	c.header = header
+	c.types = types
+	return nil
+}
+
+func (c *resultConn) WriteRow(row []*string) error {
+	values := make([]interface{}, len(row))
+	for i := range row {
+		if row[i] != nil {
+			values[i] = *row[i]
+		}
+	}
+	c.rows = append(c.rows, values)
+	return nil
+}
+
+func (c *resultConn) WriteRowEnd() error {
+	return nil
+}
+
+// relation returns selected rows as an ephemeral relation with given name,
+// which attributes are the selected columns
+func (c *resultConn) relation(name string) (*Relation, error) {
+	t := NewTable(name)
+	for _, column := range c.header {
+		// Qualified column names are not kept, like u.name in SELECT u.name FROM user u,
+		// unless needed to tell columns apart
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			if _, err := resolveAttribute(column[i+1:], "", []*Table{t}); err != nil {
+				column = column[i+1:]
+			}
+		}
+		if err := t.AddAttribute(NewAttribute(column, "text", false)); err != nil {
+			return nil, err
+		}
+	}
+
+	r := NewRelation(t)
+	for _, row := range c.rows {
+		r.rows = append(r.rows, &Tuple{Values: row})
+	}
+
+	return r, nil
+}
+
+// subqueryExecutor runs given SELECT statement and returns all selected rows.
+// Relations already read locked by outer query are not locked again.
+func subqueryExecutor(e *Engine, selectDecl *parser.Decl, locked map[*Relation]bool) (*resultConn, error) {
+	res := &resultConn{}
+
+	if err := selectQueryExecutor(e, selectDecl, res, locked, nil); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+/*
+|-> in
+	|-> SELECT
+		|-> user_id
+		|-> FROM
+			|-> admins
+*/
+// inSubqueryExecutor materializes the subquery result once, as values to test membership with
+func inSubqueryExecutor(e *Engine, selectDecl *parser.Decl, p *Predicate, width int, locked map[*Relation]bool) error {
+	res, err := subqueryExecutor(e, selectDecl, locked)
+	if err != nil {
+		return err
+	}
+
+	if len(res.header) > width {
+		return fmt.Errorf("subquery has too many columns")
+	}
+	if len(res.header) < width {
+		return fmt.Errorf("subquery has too few columns")
+	}
+
+	// Rows are compared with a tuple as a whole
+	values := make([]interface{}, len(res.rows))
+	for i := range res.rows {
+		values[i] = res.rows[i][0]
+		if width > 1 {
+			values[i] = res.rows[i]
+		}
+	}
+
+	p.Operator = inOperator
+	p.known = listKnown
+	p.RightValue.v = values
+	return nil
+}
+
+/*
+|-> >
+|-> all
+	|-> SELECT
+		|-> price
+		|-> FROM
+			|-> competitors
+*/
+// quantifiedSubqueryExecutor materializes the subquery result once, as values compared with left
+// value by the operator, ANY requiring one comparison to hold, and ALL every comparison.
+// Values may also be elements of an array, computed for each row.
+func quantifiedSubqueryExecutor(e *Engine, opDecl *parser.Decl, quantifierDecl *parser.Decl, p *Predicate, tables []*Table, locked map[*Relation]bool) error {
+	op, err := NewOperator(opDecl.Token, opDecl.Lexeme)
+	if err != nil {
+		return err
+	}
+
+	if !isQuery(quantifierDecl.Decl[0]) {
+		array, err := expressionExecutor(e, quantifierDecl.Decl[0], tables, locked)
+		if err != nil {
+			return err
+		}
+		p.RightValue.expr = &arrayElementsExpression{array: array}
+	} else {
+		res, err := subqueryExecutor(e, quantifierDecl.Decl[0], locked)
+		if err != nil {
+			return err
+		}
+
+		if len(res.header) > 1 {
+			return fmt.Errorf("subquery has too many columns")
+		}
+
+		values := make([]interface{}, len(res.rows))
+		for i := range res.rows {
+			values[i] = res.rows[i][0]
+		}
+		p.RightValue.v = values
+	}
+
+	all := quantifierDecl.Token == parser.AllToken
+	p.Operator = func(leftValue Value, rightValue Value) bool {
+		result, known := quantified(op, all, leftValue, rightValue)
+		return result && known
+	}
+	p.known = func(leftValue Value, rightValue Value) bool {
+		_, known := quantified(op, all, leftValue, rightValue)
+		return known
+	}
+	p.RightValue.lexeme = quantifierDecl.Lexeme
+	p.RightValue.valid = true
+	return nil
+}
+
+// correlation makes columns of outer query visible inside a subquery
+type correlation struct {
+	tables []*Table
+	row    virtualRow
+}
+
+// scope returns tables visible in subquery WHERE and ON clauses, its own first
+func (c *correlation) scope(tables []*Table) []*Table {
+	if c == nil {
+		return tables
+	}
+
+	s := make([]*Table, 0, len(tables)+len(c.tables))
+	s = append(s, tables...)
+	return append(s, c.tables...)
+}
+
+// Join adds outer row values to every virtual row of the subquery,
+// unless shadowed by a subquery table with the same name
+func (c *correlation) Join(e *Engine, rows []virtualRow) ([]virtualRow, error) {
+	for _, row := range rows {
+		for key, val := range c.row {
+			if _, ok := row[key]; !ok {
+				row[key] = val
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// errRowFound stops a subquery as soon as one row is selected
+var errRowFound = errors.New("row found")
+
+// existsConn is an EngineConn stopping subquery at first selected row
+type existsConn struct {
+	resultConn
+}
+
+func (c *existsConn) WriteRow(row []*string) error {
+	return errRowFound
+}
+
+/*
+|-> not
+	|-> exists
+		|-> SELECT
+			|-> 1
+			|-> FROM
+				|-> refunds
+			|-> WHERE
+				|-> order_id
+					|-> r
+					|-> =
+					|-> id
+						|-> o
+*/
+// existsSubqueryExecutor returns a predicate running the subquery for each outer row.
+// Selected attributes do not matter, only the existence of a row does.
+func existsSubqueryExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool) (PredicateLinker, error) {
+	p := &existsPredicate{e: e, locked: locked}
+
+	if decl.Token == parser.NotToken {
+		p.not = true
+		decl = decl.Decl[0]
+	}
+	if len(decl.Decl) < 1 {
+		return nil, fmt.Errorf("EXISTS: subquery not provided")
+	}
+	selectDecl := decl.Decl[0]
+
+	switch selectDecl.Token {
+	case parser.SelectToken:
+		p.decl = &parser.Decl{Token: selectDecl.Token, Lexeme: selectDecl.Lexeme}
+		for _, d := range selectDecl.Decl {
+			switch d.Token {
+			case parser.StringToken, parser.StarToken, parser.NumberToken:
+				continue
+			}
+			p.decl.Add(d)
+		}
+	case parser.UnionToken, parser.IntersectToken, parser.ExceptToken:
+		p.decl = selectDecl
+	default:
+		return nil, fmt.Errorf("EXISTS: subquery not provided")
+	}
+
+	for _, t := range tables {
+		p.tables = append(p.tables, &Table{name: t.name, attributes: t.attributes, aliased: t.aliased, correlated: true})
+	}
+
+	return p, nil
+}
+
+// existsPredicate is true if subquery selects at least one row
+// with outer row values, or false if negated
+type existsPredicate struct {
+	e      *Engine
+	decl   *parser.Decl
+	tables []*Table
+	locked map[*Relation]bool
+	not    bool
+}
+
+func (p *existsPredicate) Eval(row virtualRow) (bool, error) {
+	outer := &correlation{tables: p.tables, row: row}
+
+	err := selectQueryExecutor(p.e, p.decl, &existsConn{}, p.locked, outer)
+	if err == errRowFound {
+		return !p.not, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return p.not, nil
+}
+
+/*
+|-> SELECT
+	|-> COUNT
+		|-> *
+	|-> FROM
+		|-> orders
+	|-> as
+		|-> order_count
+*/
+// scalarSubqueryExecutor returns the subquery in select list, named after its alias
+// or else its selected attribute
+func scalarSubqueryExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool, index int) *selectedExpression {
+	s := &scalarSubquery{
+		e:      e,
+		decl:   decl,
+		locked: locked,
+	}
+	selected := &selectedExpression{
+		key:  fmt.Sprintf("(subquery %d)", index),
+		name: "?column?",
+		expr: s,
+	}
+
+	for _, t := range tables {
+		s.tables = append(s.tables, &Table{name: t.name, attributes: t.attributes, aliased: t.aliased, correlated: true})
+	}
+
+	if alias := selectedAlias(decl); alias != "" {
+		selected.name = alias
+		return selected
+	}
+
+	for _, d := range decl.Decl {
+		switch d.Token {
+		case parser.StringToken:
+			selected.name = d.Lexeme
+		case parser.CountToken, parser.SumToken, parser.AvgToken, parser.MinToken, parser.MaxToken,
+			parser.GroupConcatToken, parser.StringAggToken:
+			selected.name = strings.ToLower(d.Lexeme)
+		}
+		if a := selectedAlias(d); a != "" {
+			selected.name = a
+		}
+	}
+
+	return selected
+}
+
+// scalarSubquery is a subquery used as a value, evaluated with each outer row
+type scalarSubquery struct {
+	e      *Engine
+	decl   *parser.Decl
+	tables []*Table
+	locked map[*Relation]bool
+}
+
+// eval returns the only value selected by subquery, or NULL if no row is selected
+func (s *scalarSubquery) eval(row virtualRow) (interface{}, error) {
+	res := &resultConn{}
+	outer := &correlation{tables: s.tables, row: row}
+
+	if err := selectQueryExecutor(s.e, s.decl, res, s.locked, outer); err != nil {
+		return nil, err
+	}
+
+	if len(res.header) > 1 {
+		return nil, fmt.Errorf("subquery must return only one column")
+	}
+	if len(res.rows) > 1 {
+		return nil, fmt.Errorf("more than one row returned by a subquery used as an expression")
+	}
+	if len(res.rows) == 0 {
+		return nil, nil
+	}
+
+	return res.rows[0][0], nil
+}
+
+/*
+|-> SELECT
+	|-> a
+		|-> as
+			|-> x
+	|-> FROM
+		|-> foo
+	|-> as
+		|-> t
+*/
+// derivedTableExecutor runs a subquery used in FROM clause, and returns its result
+// as an ephemeral relation named after its alias. Its attributes are the selected columns.
+func derivedTableExecutor(e *Engine, decl *parser.Decl, name string, locked map[*Relation]bool) (*Relation, error) {
+	if name == decl.Lexeme {
+		return nil, fmt.Errorf("subquery in FROM must have an alias")
+	}
+
+	res, err := subqueryExecutor(e, decl, locked)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.relation(name)
+}
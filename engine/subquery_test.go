@@ -0,0 +1,602 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestInSubquery(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestInSubquery")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT)`,
+		`CREATE TABLE admins (id BIGSERIAL, user_id INT, level INT)`,
+		`CREATE TABLE bans (id BIGSERIAL, user_id INT)`,
+		`INSERT INTO users (name) VALUES ('alice')`,
+		`INSERT INTO users (name) VALUES ('bob')`,
+		`INSERT INTO users (name) VALUES ('carol')`,
+		`INSERT INTO users (name) VALUES ('dave')`,
+		`INSERT INTO admins (user_id, level) VALUES (1, 1)`,
+		`INSERT INTO admins (user_id, level) VALUES (3, 2)`,
+		`INSERT INTO bans (user_id) VALUES (2)`,
+		`INSERT INTO bans (id) VALUES (42)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	names := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, name)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT name FROM users WHERE id IN (SELECT user_id FROM admins) ORDER BY name`:               {"alice", "carol"},
+		`SELECT name FROM users WHERE id IN (SELECT user_id FROM admins WHERE level > 1)`:             {"carol"},
+		`SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM admins) ORDER BY name`:           {"bob", "dave"},
+		`SELECT name FROM users WHERE id IN (SELECT user_id FROM bans)`:                               {"bob"},
+		`SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM bans)`:                           nil,
+		`SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM admins WHERE level > 5)`:         {"alice", "bob", "carol", "dave"},
+		`SELECT name FROM users WHERE id IN (SELECT user_id FROM admins) AND name = 'carol'`:          {"carol"},
+		`SELECT name FROM users WHERE id IN (SELECT user_id FROM admins ORDER BY level DESC LIMIT 1)`: {"carol"},
+		`SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM bans WHERE user_id IS NOT NULL)`: {"alice", "carol", "dave"},
+	}
+
+	for query, expected := range queries {
+		res := names(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	_, err = db.Query(`SELECT name FROM users WHERE id IN (SELECT user_id, level FROM admins)`)
+	if err == nil {
+		t.Fatalf("Expected error with subquery selecting several columns")
+	}
+}
+
+func TestQuantifiedSubquery(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestQuantifiedSubquery")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE products (id BIGSERIAL, name TEXT, price INT)`,
+		`CREATE TABLE competitors (id BIGSERIAL, store TEXT, price INT)`,
+		`INSERT INTO products (name, price) VALUES ('pen', 3)`,
+		`INSERT INTO products (name, price) VALUES ('book', 12)`,
+		`INSERT INTO products (name, price) VALUES ('bag', 20)`,
+		`INSERT INTO products (name) VALUES ('ink')`,
+		`INSERT INTO competitors (store, price) VALUES ('a', 5)`,
+		`INSERT INTO competitors (store, price) VALUES ('a', 12)`,
+		`INSERT INTO competitors (store, price) VALUES ('b', 15)`,
+		`INSERT INTO competitors (store) VALUES ('b')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	queries := map[string][]string{
+		`SELECT name FROM products WHERE price > ALL (SELECT price FROM competitors WHERE store = 'a')`:                                {"bag"},
+		`SELECT name FROM products WHERE price >= ALL (SELECT price FROM competitors WHERE store = 'a') ORDER BY name`:                 {"bag", "book"},
+		`SELECT name FROM products WHERE price = ANY (SELECT price FROM competitors)`:                                                  {"book"},
+		`SELECT name FROM products WHERE price = SOME (SELECT price FROM competitors WHERE store = 'a')`:                               {"book"},
+		`SELECT name FROM products WHERE price > ALL (SELECT price FROM competitors WHERE store = 'c') ORDER BY name`:                  {"bag", "book", "ink", "pen"},
+		`SELECT name FROM products WHERE price > ANY (SELECT price FROM competitors WHERE store = 'c')`:                                nil,
+		`SELECT name FROM products WHERE price > ALL (SELECT price FROM competitors WHERE store = 'b')`:                                nil,
+		`SELECT name FROM products WHERE NOT price > ALL (SELECT price FROM competitors WHERE store = 'b') ORDER BY name`:              {"book", "pen"},
+		`SELECT name FROM products WHERE price < ANY (SELECT price FROM competitors WHERE store = 'b') ORDER BY name`:                  {"book", "pen"},
+		`SELECT name FROM products WHERE NOT price < ANY (SELECT price FROM competitors WHERE store = 'b')`:                            nil,
+		`SELECT name FROM products WHERE price <= ALL (SELECT price FROM competitors WHERE price IS NOT NULL) OR id = 3 ORDER BY name`: {"bag", "pen"},
+	}
+
+	for query, expected := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		var res []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, name)
+		}
+		rows.Close()
+
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	_, err = db.Query(`SELECT name FROM products WHERE price > ALL (SELECT store, price FROM competitors)`)
+	if err == nil {
+		t.Fatalf("Expected error with subquery selecting several columns")
+	}
+}
+
+func TestExistsSubquery(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestExistsSubquery")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE orders (id BIGSERIAL, ref TEXT)`,
+		`CREATE TABLE refunds (id BIGSERIAL, order_id INT, amount INT)`,
+		`INSERT INTO orders (ref) VALUES ('A')`,
+		`INSERT INTO orders (ref) VALUES ('B')`,
+		`INSERT INTO orders (ref) VALUES ('C')`,
+		`INSERT INTO refunds (order_id, amount) VALUES (1, 10)`,
+		`INSERT INTO refunds (order_id, amount) VALUES (1, 20)`,
+		`INSERT INTO refunds (order_id, amount) VALUES (3, 500)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	refs := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var ref string
+			if err := rows.Scan(&ref); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, ref)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id) ORDER BY ref`:                                        {"A", "C"},
+		`SELECT ref FROM orders o WHERE NOT EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id)`:                                                 {"B"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT * FROM refunds r WHERE r.order_id = o.id AND r.amount > 100)`:                                  {"C"},
+		`SELECT ref FROM orders WHERE EXISTS (SELECT 1 FROM refunds WHERE order_id = orders.id AND amount < 15)`:                                      {"A"},
+		`SELECT ref FROM orders WHERE ref = 'B' AND NOT EXISTS (SELECT 1 FROM refunds WHERE refunds.order_id = orders.id)`:                            {"B"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM refunds) ORDER BY ref`:                                                                  {"A", "B", "C"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM refunds r WHERE r.order_id = o.id AND r.id = 0)`:                                        nil,
+		`SELECT ref FROM orders WHERE EXISTS (SELECT 1 FROM orders WHERE id = 2)`:                                                                     {"A", "B", "C"},
+		`SELECT ref FROM orders o WHERE NOT EXISTS (SELECT order_id FROM refunds r WHERE r.order_id = o.id ORDER BY amount) ORDER BY ref DESC`:        {"B"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM refunds r JOIN orders x ON x.id = r.order_id AND x.id = o.id) ORDER BY ref`:             {"A", "C"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM orders x LEFT JOIN refunds r ON r.order_id = o.id WHERE r.amount > 15)`:                 {"A", "C"},
+		`SELECT ref FROM orders o WHERE EXISTS (SELECT 1 FROM refunds r RIGHT JOIN orders x ON x.id = r.order_id WHERE x.id = o.id AND r.id IS NULL)`: {"B"},
+	}
+
+	for query, expected := range queries {
+		res := refs(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+}
+
+func TestScalarSubquery(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestScalarSubquery")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT)`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id INT, amount INT)`,
+		`INSERT INTO users (name) VALUES ('alice')`,
+		`INSERT INTO users (name) VALUES ('bob')`,
+		`INSERT INTO users (name) VALUES ('carol')`,
+		`INSERT INTO orders (user_id, amount) VALUES (1, 10)`,
+		`INSERT INTO orders (user_id, amount) VALUES (1, 20)`,
+		`INSERT INTO orders (user_id, amount) VALUES (3, 5)`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT name, (SELECT COUNT(*) FROM orders WHERE user_id = u.id) AS order_count, (SELECT MAX(amount) FROM orders o WHERE o.user_id = u.id) FROM users u ORDER BY name`)
+	if err != nil {
+		t.Fatalf("Cannot select scalar subquery: %s", err)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Cannot get columns: %s", err)
+	}
+	if len(columns) != 3 || columns[0] != "name" || columns[1] != "order_count" || columns[2] != "max" {
+		t.Fatalf("Expected columns [name order_count max], got %v", columns)
+	}
+
+	type result struct {
+		name  string
+		count int64
+		max   sql.NullInt64
+	}
+	expected := []result{
+		{"alice", 2, sql.NullInt64{Int64: 20, Valid: true}},
+		{"bob", 0, sql.NullInt64{}},
+		{"carol", 1, sql.NullInt64{Int64: 5, Valid: true}},
+	}
+
+	var results []result
+	for rows.Next() {
+		var r result
+		if err := rows.Scan(&r.name, &r.count, &r.max); err != nil {
+			t.Fatalf("Cannot scan row: %s", err)
+		}
+		results = append(results, r)
+	}
+	rows.Close()
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, results)
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected[i], results[i])
+		}
+	}
+
+	// No row selected is NULL
+	var amount sql.NullInt64
+	err = db.QueryRow(`SELECT (SELECT amount FROM orders WHERE orders.user_id = users.id) FROM users WHERE name = 'bob'`).Scan(&amount)
+	if err != nil {
+		t.Fatalf("Cannot select scalar subquery: %s", err)
+	}
+	if amount.Valid {
+		t.Fatalf("Expected NULL, got %d", amount.Int64)
+	}
+
+	// More than one row
+	_, err = db.Query(`SELECT name, (SELECT amount FROM orders WHERE orders.user_id = users.id) FROM users`)
+	if err == nil {
+		t.Fatalf("Expected error with subquery returning more than one row")
+	}
+
+	// More than one column
+	_, err = db.Query(`SELECT name, (SELECT id, amount FROM orders WHERE orders.user_id = users.id) FROM users`)
+	if err == nil {
+		t.Fatalf("Expected error with subquery returning more than one column")
+	}
+}
+
+func TestDerivedTable(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestDerivedTable")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE foo (id BIGSERIAL, a INT, label TEXT)`,
+		`CREATE TABLE bar (id BIGSERIAL, foo_id INT, name TEXT)`,
+		`INSERT INTO foo (a, label) VALUES (0, 'zero')`,
+		`INSERT INTO foo (a, label) VALUES (2, 'two')`,
+		`INSERT INTO foo (a, label) VALUES (3, 'three')`,
+		`INSERT INTO bar (foo_id, name) VALUES (2, 'second')`,
+		`INSERT INTO bar (foo_id, name) VALUES (3, 'third')`,
+		`INSERT INTO bar (foo_id, name) VALUES (1, 'first')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT t.x FROM (SELECT a AS x FROM foo WHERE a > 0) t ORDER BY t.x`:                                        {"2", "3"},
+		`SELECT x FROM (SELECT a x FROM foo WHERE a > 2) AS t`:                                                       {"3"},
+		`SELECT t.label FROM (SELECT f.id, f.label FROM foo f) t WHERE t.id = 1`:                                     {"zero"},
+		`SELECT bar.name FROM bar JOIN (SELECT id, a FROM foo WHERE a > 0) t ON bar.foo_id = t.id ORDER BY bar.name`: {"second", "third"},
+		`SELECT c.n FROM (SELECT COUNT(*) AS n FROM bar) c`:                                                          {"3"},
+		`SELECT s.name FROM (SELECT name FROM (SELECT name FROM bar WHERE foo_id > 1) i) s ORDER BY s.name DESC`:     {"third", "second"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	rows, err := db.Query(`SELECT t.x FROM (SELECT a AS x FROM foo) t`)
+	if err != nil {
+		t.Fatalf("Cannot query derived table: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil || len(columns) != 1 || columns[0] != "t.x" {
+		t.Fatalf("Expected columns [t.x], got %v (%v)", columns, err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+
+	_, err = db.Query(`SELECT x FROM (SELECT a AS x FROM foo)`)
+	if err == nil {
+		t.Fatalf("Expected error with derived table without alias")
+	}
+}
+
+func TestWith(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestWith")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE events (id INT, ts INT, type TEXT)`,
+		`INSERT INTO events (id, ts, type) VALUES (1, 5, 'click')`,
+		`INSERT INTO events (id, ts, type) VALUES (2, 15, 'view')`,
+		`INSERT INTO events (id, ts, type) VALUES (3, 20, 'click')`,
+		`INSERT INTO events (id, ts, type) VALUES (4, 30, 'click')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	ids := func(query string, args ...interface{}) []string {
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, id)
+		}
+		return res
+	}
+
+	res := ids(`WITH recent AS (SELECT * FROM events WHERE ts > $1) SELECT id FROM recent WHERE type = 'click' ORDER BY id`, 10)
+	if len(res) != 2 || res[0] != "3" || res[1] != "4" {
+		t.Fatalf("expected [3 4], got %v", res)
+	}
+
+	// Later expressions, and subqueries, see previous ones
+	res = ids(`WITH recent AS (SELECT id, ts FROM events WHERE ts > 10),
+		first AS (SELECT MIN(ts) AS ts FROM recent)
+		SELECT r.id FROM recent r JOIN first f ON r.ts > f.ts ORDER BY r.id`)
+	if len(res) != 2 || res[0] != "3" || res[1] != "4" {
+		t.Fatalf("expected [3 4], got %v", res)
+	}
+
+	res = ids(`WITH clicks AS (SELECT id FROM events WHERE type = 'click')
+		SELECT id FROM events WHERE id NOT IN (SELECT id FROM clicks)`)
+	if len(res) != 1 || res[0] != "2" {
+		t.Fatalf("expected [2], got %v", res)
+	}
+
+	// Each reference is aliased on its own
+	res = ids(`WITH clicks AS (SELECT id, ts FROM events WHERE type = 'click')
+		SELECT a.id FROM clicks a JOIN clicks b ON b.ts < a.ts WHERE b.id = 3`)
+	if len(res) != 1 || res[0] != "4" {
+		t.Fatalf("expected [4], got %v", res)
+	}
+
+	// Expression hides the table with the same name, but not in its own query
+	res = ids(`WITH events AS (SELECT * FROM events WHERE id = 1) SELECT id FROM events`)
+	if len(res) != 1 || res[0] != "1" {
+		t.Fatalf("expected [1], got %v", res)
+	}
+
+	res = ids(`SELECT id FROM (WITH x AS (SELECT id FROM events WHERE id > 2) SELECT id FROM x) y ORDER BY id`)
+	if len(res) != 2 || res[0] != "3" || res[1] != "4" {
+		t.Fatalf("expected [3 4], got %v", res)
+	}
+
+	// Expressions are only visible from their statement
+	if _, err := db.Query(`SELECT id FROM recent`); err == nil {
+		t.Fatalf("expected recent not to exist")
+	}
+	if _, err := db.Query(`WITH a AS (SELECT id FROM events), a AS (SELECT id FROM events) SELECT id FROM a`); err == nil {
+		t.Fatalf("expected duplicate name to fail")
+	}
+}
+
+func TestWithRecursive(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestWithRecursive")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE category (id INT, parent_id INT, name TEXT)`,
+		`INSERT INTO category (id, parent_id, name) VALUES (1, NULL, 'root')`,
+		`INSERT INTO category (id, parent_id, name) VALUES (2, 1, 'a')`,
+		`INSERT INTO category (id, parent_id, name) VALUES (3, 1, 'b')`,
+		`INSERT INTO category (id, parent_id, name) VALUES (4, 2, 'aa')`,
+		`INSERT INTO category (id, parent_id, name) VALUES (5, 4, 'aaa')`,
+		`INSERT INTO category (id, parent_id, name) VALUES (6, NULL, 'other')`,
+		`CREATE TABLE edge (src INT, dst INT)`,
+		`INSERT INTO edge (src, dst) VALUES (1, 2)`,
+		`INSERT INTO edge (src, dst) VALUES (2, 3)`,
+		`INSERT INTO edge (src, dst) VALUES (3, 1)`,
+		`INSERT INTO edge (src, dst) VALUES (3, 4)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var a, b string
+			if err := rows.Scan(&a, &b); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, a+":"+b)
+		}
+		return strings.Join(res, ",")
+	}
+
+	queries := map[string]string{
+		// Descendants of root, with their depth
+		`WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM category WHERE id = 1
+			UNION ALL
+			SELECT c.id, t.depth + 1 FROM category c JOIN tree t ON c.parent_id = t.id
+		) SELECT id, depth FROM tree ORDER BY id`: "1:0,2:1,3:1,4:2,5:3",
+		// Ancestors of a category
+		`WITH RECURSIVE up AS (
+			SELECT id, parent_id FROM category WHERE id = 5
+			UNION ALL
+			SELECT c.id, c.parent_id FROM category c, up WHERE c.id = up.parent_id
+		) SELECT up.id, category.name FROM up JOIN category ON category.id = up.id ORDER BY up.id`: "1:root,2:a,4:aa,5:aaa",
+		// UNION ends the recursion once no new row is found, despite the cycle
+		`WITH RECURSIVE reach AS (
+			SELECT src, dst FROM edge WHERE src = 1
+			UNION
+			SELECT r.src, e.dst FROM edge e JOIN reach r ON e.src = r.dst
+		) SELECT src, dst FROM reach ORDER BY dst`: "1:1,1:2,1:3,1:4",
+		// Columns named after query
+		`WITH RECURSIVE n(v, depth) AS (
+			SELECT id, 0 FROM category WHERE id = 1
+			UNION ALL
+			SELECT c.id, n.depth + 1 FROM category c JOIN n ON c.parent_id = n.v
+		) SELECT v, depth FROM n ORDER BY v`: "1:0,2:1,3:1,4:2,5:3",
+		// Only the first ones
+		`WITH RECURSIVE up(child) AS (
+			SELECT id, parent_id FROM category WHERE id = 5
+			UNION ALL
+			SELECT c.id, c.parent_id FROM category c, up WHERE c.id = up.parent_id
+		) SELECT child, parent_id FROM up WHERE parent_id IS NOT NULL ORDER BY child`: "2:1,4:2,5:4",
+	}
+	for query, expected := range queries {
+		if res := rows(query); res != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, res)
+		}
+	}
+
+	// UNION ALL keeps every row, so the cycle never ends
+	_, err = db.Query(`WITH RECURSIVE reach AS (
+		SELECT dst FROM edge WHERE src = 1
+		UNION ALL
+		SELECT e.dst FROM edge e JOIN reach r ON e.src = r.dst
+	) SELECT dst FROM reach`)
+	if err == nil || !strings.Contains(err.Error(), "did not end") {
+		t.Fatalf("expected recursion limit to be reached, got %v", err)
+	}
+
+	_, err = db.Query(`WITH RECURSIVE x AS (SELECT id FROM x UNION SELECT id FROM category) SELECT id FROM x`)
+	if err == nil {
+		t.Fatalf("expected recursive reference in non recursive term to fail")
+	}
+
+	_, err = db.Query(`WITH RECURSIVE n(a, b) AS (SELECT id FROM category UNION SELECT id FROM n) SELECT a FROM n`)
+	if err == nil || !strings.Contains(err.Error(), "1 columns available but 2 columns specified") {
+		t.Fatalf("expected too many column names to fail, got %v", err)
+	}
+
+	_, err = db.Query(`WITH RECURSIVE n(a) AS (SELECT id FROM category) SELECT a FROM n`)
+	if err == nil {
+		t.Fatalf("expected column names of non recursive query to fail")
+	}
+}
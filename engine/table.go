@@ -12,6 +12,17 @@ import (
 type Table struct {
 	name       string
 	attributes []Attribute
+	unique     []uniqueConstraint
+	// foreignKeys constraints of table, referencing it or other tables
+	foreignKeys []foreignKey
+	// checks constraints of table, in order of creation
+	checks []checkConstraint
+	// correlated is set on outer query tables visible in a subquery
+	correlated bool
+	// aliased is the name of the relation referenced with an alias, which hides it
+	aliased string
+	// triggers fired by changes of rows, in order of creation
+	triggers []*trigger
 }
 
 // NewTable initializes a new Table
@@ -30,6 +41,30 @@ func (t *Table) AddAttribute(attr Attribute) error {
 	return nil
 }
 
+// attributeIndex returns the position of named attribute in tuples, or -1 if not found
+func (t *Table) attributeIndex(name string) int {
+	for i := range t.attributes {
+		if t.attributes[i].name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// convertValues converts values of row to the type of their attribute, in place
+func (t *Table) convertValues(row *Tuple) error {
+	for i, a := range t.attributes {
+		v, err := a.convert(row.Values[i])
+		if err != nil {
+			return err
+		}
+		row.Values[i] = v
+	}
+
+	return nil
+}
+
 // String returns a printable string with table name and attributes
 func (t Table) String() string {
 	stringy := t.name + " ("
@@ -50,10 +85,15 @@ func createTableExecutor(e *Engine, tableDecl *parser.Decl, conn protocol.Engine
 		return fmt.Errorf("parsing failed, malformed query")
 	}
 
-	// Fetch constrainit (i.e: "IF EXISTS")
+	// Fetch constrainit (i.e: "IF NOT EXISTS")
+	var ifNotExists bool
 	i = 0
 	for i < len(tableDecl.Decl) {
 
+		if d := tableDecl.Decl[i]; d.Token == parser.IfToken && len(d.Decl) > 0 && d.Decl[0].Token == parser.NotToken {
+			ifNotExists = true
+		}
+
 		if e.opsExecutors[tableDecl.Decl[i].Token] != nil {
 			if err := e.opsExecutors[tableDecl.Decl[i].Token](e, tableDecl.Decl[i], conn); err != nil {
 				return err
@@ -65,18 +105,41 @@ func createTableExecutor(e *Engine, tableDecl *parser.Decl, conn protocol.Engine
 		i++
 	}
 
-	// Check if table does not exists
+	// Check if table does not exists. With IF NOT EXISTS, existing table is left as is
+	// even if its definition differs.
 	r := e.relation(tableDecl.Decl[i].Lexeme)
-	if r != nil {
-		return fmt.Errorf("table %s already exists", tableDecl.Decl[i].Lexeme)
+	if r != nil || e.view(tableDecl.Decl[i].Lexeme) != nil {
+		if ifNotExists {
+			return conn.WriteResult(0, 0)
+		}
+		return &Error{Code: DuplicateTable, Table: tableDecl.Decl[i].Lexeme, Message: fmt.Sprintf("table %s already exists", tableDecl.Decl[i].Lexeme)}
 	}
 
 	// Fetch table name
 	t := NewTable(tableDecl.Decl[i].Lexeme)
 
-	// Fetch attributes
+	// Fetch attributes, and table constraints once all are known
+	var constraints []*parser.Decl
+	var foreignKeys []*parser.Decl
+	var checks, checkColumns []*parser.Decl
 	i++
 	for i < len(tableDecl.Decl) {
+		if tableDecl.Decl[i].Token == parser.UniqueToken || tableDecl.Decl[i].Token == parser.PrimaryToken {
+			constraints = append(constraints, tableDecl.Decl[i])
+			i++
+			continue
+		}
+		if tableDecl.Decl[i].Token == parser.ForeignToken {
+			foreignKeys = append(foreignKeys, tableDecl.Decl[i])
+			i++
+			continue
+		}
+		if tableDecl.Decl[i].Token == parser.CheckToken {
+			checks = append(checks, tableDecl.Decl[i])
+			checkColumns = append(checkColumns, nil)
+			i++
+			continue
+		}
 		attr, err := parseAttribute(tableDecl.Decl[i])
 		if err != nil {
 			return err
@@ -85,10 +148,77 @@ func createTableExecutor(e *Engine, tableDecl *parser.Decl, conn protocol.Engine
 		if err != nil {
 			return err
 		}
+		if attr.unique {
+			t.unique = append(t.unique, uniqueConstraint{name: t.name + "_" + attr.name + "_key", attributes: []string{attr.name}})
+		}
+		// Column REFERENCES is a foreign key on it, and CHECK is named after it
+		for _, d := range tableDecl.Decl[i].Decl {
+			switch d.Token {
+			case parser.ReferencesToken:
+				foreignKeys = append(foreignKeys, &parser.Decl{Token: parser.ForeignToken, Decl: []*parser.Decl{tableDecl.Decl[i], d}})
+			case parser.CheckToken:
+				checks = append(checks, d)
+				checkColumns = append(checkColumns, tableDecl.Decl[i])
+			}
+		}
 		i++
 	}
 
+	for _, c := range constraints {
+		var err error
+		switch c.Token {
+		case parser.PrimaryToken:
+			err = primaryKeyExecutor(t, c)
+		default:
+			err = uniqueExecutor(t, c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.addPrimaryKey()
+
+	for j, c := range checks {
+		var column string
+		if checkColumns[j] != nil {
+			column = checkColumns[j].Lexeme
+		}
+		if err := checkExecutor(e, t, column, c); err != nil {
+			return err
+		}
+	}
+
+	// Foreign keys may reference the keys of table itself
+	for _, fk := range foreignKeys {
+		var attributes []string
+		var referencesDecl *parser.Decl
+		for _, d := range fk.Decl {
+			if d.Token == parser.ReferencesToken {
+				referencesDecl = d
+				continue
+			}
+			attributes = append(attributes, d.Lexeme)
+		}
+		if referencesDecl == nil {
+			return fmt.Errorf("parsing failed, malformed query")
+		}
+		if err := foreignKeyExecutor(e, t, attributes, referencesDecl); err != nil {
+			return err
+		}
+	}
+
+	// Table may have been created concurrently
+	e.Lock()
+	if _, ok := e.relations[t.name]; ok {
+		e.Unlock()
+		if ifNotExists {
+			return conn.WriteResult(0, 0)
+		}
+		return &Error{Code: DuplicateTable, Table: t.name, Message: fmt.Sprintf("table %s already exists", t.name)}
+	}
 	e.relations[t.name] = NewRelation(t)
+	e.Unlock()
+
 	conn.WriteResult(0, 1)
 	return nil
 }
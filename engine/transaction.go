@@ -0,0 +1,371 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// transaction holds a copy of each relation used since BEGIN, so writes are only
+// seen by the connection until COMMIT, and discarded on ROLLBACK. Relations are copied
+// with the rows they had at BEGIN, so that reads are repeatable, and writes committed
+// by other connections since then are not seen.
+// Schema and sequences are shared with other connections, and are not transactional.
+type transaction struct {
+	copies     map[*Relation]*relationCopy
+	snapshot   map[*Relation]snapshotRows
+	savepoints []*savepoint
+	// readOnly is set if statements of transaction cannot write
+	readOnly bool
+	// aborted is set once transaction is rolled back because of an error, until it ends
+	aborted bool
+}
+
+// savepoint holds rows of relations copied by transaction when it was set
+type savepoint struct {
+	name string
+	rows map[*Relation][]*Tuple
+}
+
+// relationCopy is a copy of a relation, with the rows it had when copied
+type relationCopy struct {
+	relation *Relation
+	rows     []*Tuple
+}
+
+// snapshotRows are the rows of a relation at BEGIN, and the count of its columns changes then
+type snapshotRows struct {
+	rows    []*Tuple
+	altered int
+}
+
+// newTransaction begins a transaction, taking a snapshot of rows of every relation of e
+func newTransaction(e *Engine) *transaction {
+	tx := &transaction{
+		copies:   make(map[*Relation]*relationCopy),
+		snapshot: make(map[*Relation]snapshotRows),
+	}
+
+	e.Lock()
+	relations := make([]*Relation, 0, len(e.relations))
+	for _, r := range e.relations {
+		relations = append(relations, r)
+	}
+	e.Unlock()
+
+	// Rows are copied, since ON CONFLICT updates replace them in place
+	for _, r := range relations {
+		r.RLock()
+		rows := make([]*Tuple, len(r.rows))
+		copy(rows, r.rows)
+		tx.snapshot[r] = snapshotRows{rows: rows, altered: r.altered}
+		r.RUnlock()
+	}
+
+	return tx
+}
+
+// relation returns the copy of r used in transaction, made on first use with the rows
+// r had at BEGIN. If r was created since then, or its columns changed, it is copied
+// with its current rows.
+func (tx *transaction) relation(r *Relation) *Relation {
+	if c, ok := tx.copies[r]; ok {
+		return c.relation
+	}
+
+	r.RLock()
+	s, ok := tx.snapshot[r]
+	if !ok || s.altered != r.altered {
+		s.rows = make([]*Tuple, len(r.rows))
+		copy(s.rows, r.rows)
+	}
+	c := &relationCopy{rows: s.rows}
+	c.relation = &Relation{table: r.table, rows: make([]*Tuple, len(c.rows))}
+	copy(c.relation.rows, c.rows)
+	for _, i := range r.indexes {
+		c.relation.indexes = append(c.relation.indexes, &index{name: i.name, attributes: i.attributes})
+	}
+	r.RUnlock()
+
+	c.relation.rebuildIndexes()
+	tx.copies[r] = c
+	delete(tx.snapshot, r)
+	return c.relation
+}
+
+// savepoint sets a new savepoint, hiding previous ones with the same name
+func (tx *transaction) savepoint(name string) {
+	sp := &savepoint{name: name, rows: make(map[*Relation][]*Tuple)}
+	for r, c := range tx.copies {
+		sp.rows[r] = append([]*Tuple(nil), c.relation.rows...)
+	}
+
+	tx.savepoints = append(tx.savepoints, sp)
+}
+
+// lookup returns the position of the last savepoint with given name
+func (tx *transaction) lookup(name string) (int, error) {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("savepoint \"%s\" does not exist", name)
+}
+
+// rollbackTo discards writes done since savepoint was set, and savepoints set after it.
+// Relations copied after it get their rows back as copied.
+func (tx *transaction) rollbackTo(name string) error {
+	i, err := tx.lookup(name)
+	if err != nil {
+		return err
+	}
+	sp := tx.savepoints[i]
+	tx.savepoints = tx.savepoints[:i+1]
+
+	for r, c := range tx.copies {
+		rows, ok := sp.rows[r]
+		if !ok {
+			rows = c.rows
+		}
+		c.relation.rows = append([]*Tuple(nil), rows...)
+		c.relation.rebuildIndexes()
+	}
+
+	return nil
+}
+
+// release removes savepoint, and savepoints set after it
+func (tx *transaction) release(name string) error {
+	i, err := tx.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+// sameRows returns true if both lists hold the same rows, in the same order
+func sameRows(a []*Tuple, b []*Tuple) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// commit writes rows of relations modified in transaction, and closes it.
+// If one of them was modified by another connection since it was copied,
+// nothing is written.
+func (e *Engine) commit() error {
+	tx := e.tx
+	e.tx = nil
+	defer e.tableLocks.release(tx)
+
+	// Lock modified relations always in the same order
+	var modified []*Relation
+	for r, c := range tx.copies {
+		if !sameRows(c.relation.rows, c.rows) {
+			modified = append(modified, r)
+		}
+	}
+	sort.Slice(modified, func(i, j int) bool {
+		return modified[i].table.name < modified[j].table.name
+	})
+	for _, r := range modified {
+		r.Lock()
+		defer r.Unlock()
+	}
+
+	for _, r := range modified {
+		if !sameRows(r.rows, tx.copies[r].rows) {
+			return fmt.Errorf("could not serialize access due to concurrent update of %s", r.table.name)
+		}
+	}
+
+	for _, r := range modified {
+		r.rows = tx.copies[r].relation.rows
+		r.rebuildIndexes()
+	}
+
+	return nil
+}
+
+// rollback discards writes of transaction, if any, and closes it
+func (e *Engine) rollback() {
+	if e.tx == nil {
+		return
+	}
+
+	e.tableLocks.release(e.tx)
+	e.tx = nil
+}
+
+// abort rolls back transaction because of an error, its statements failing until it ends
+func (e *Engine) abort() {
+	e.tableLocks.release(e.tx)
+	e.tx.copies = make(map[*Relation]*relationCopy)
+	e.tx.snapshot = nil
+	e.tx.savepoints = nil
+	e.tx.aborted = true
+}
+
+// isEnd returns true if statement ends transaction, with COMMIT or ROLLBACK
+func isEnd(decl *parser.Decl) bool {
+	return decl.Token == parser.CommitToken || (decl.Token == parser.RollbackToken && len(decl.Decl) == 0)
+}
+
+// isSchemaChange returns true if statement changes tables, indexes or views
+func isSchemaChange(decl *parser.Decl) bool {
+	switch decl.Token {
+	case parser.CreateToken, parser.DropToken, parser.AlterToken:
+		return true
+	}
+
+	return false
+}
+
+/*
+|-> begin
+	|-> read
+		|-> only
+*/
+// beginExecutor starts a transaction, read only if given or by default in session
+func beginExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if e.tx != nil {
+		return fmt.Errorf("there is already a transaction in progress")
+	}
+
+	e.tx = newTransaction(e)
+	e.tx.readOnly = e.defaultReadOnly
+	if len(decl.Decl) > 0 {
+		e.tx.readOnly = isReadOnly(decl.Decl[0])
+	}
+	return conn.WriteResult(0, 0)
+}
+
+/*
+|-> set
+	|-> session
+	|-> read
+		|-> only
+*/
+// setSessionExecutor sets the access mode of transactions of the session, and of statements
+// executed outside of a transaction
+func setSessionExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if len(decl.Decl) != 2 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+
+	e.defaultReadOnly = isReadOnly(decl.Decl[1])
+	return conn.WriteResult(0, 0)
+}
+
+// isReadOnly returns true if access mode is READ ONLY rather than READ WRITE
+func isReadOnly(modeDecl *parser.Decl) bool {
+	return len(modeDecl.Decl) == 1 && modeDecl.Decl[0].Lexeme == "only"
+}
+
+// readOnly returns true if statements of session cannot write,
+// because its transaction is read only, or its transactions are by default
+func (e *Engine) readOnly() bool {
+	if e.tx != nil {
+		return e.tx.readOnly
+	}
+
+	return e.defaultReadOnly
+}
+
+// checkWritable returns an error if statement writes, and session cannot.
+// It is checked before executing statement, so that it changes nothing.
+func (e *Engine) checkWritable(decl *parser.Decl) error {
+	switch decl.Token {
+	case parser.CreateToken, parser.DropToken, parser.AlterToken, parser.InsertToken, parser.UpdateToken, parser.DeleteToken, parser.TruncateToken:
+	default:
+		return nil
+	}
+	if !e.readOnly() {
+		return nil
+	}
+
+	return errorf(ReadOnlySQLTransaction, "cannot execute %s in a read-only transaction", strings.ToUpper(decl.Lexeme))
+}
+
+/*
+|-> commit
+*/
+// commitExecutor makes writes of transaction seen by all connections. An aborted
+// transaction is rolled back instead. Without transaction in progress, there is nothing to do.
+func commitExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if e.tx != nil && e.tx.aborted {
+		e.rollback()
+		return errorf(InFailedSQLTransaction, "current transaction is aborted, it was rolled back")
+	}
+	if e.tx != nil {
+		if err := e.commit(); err != nil {
+			return err
+		}
+	}
+
+	return conn.WriteResult(0, 0)
+}
+
+/*
+|-> rollback
+	|-> sp1
+*/
+// rollbackExecutor discards writes of transaction, or only the ones done since given savepoint.
+// Without transaction in progress, there is nothing to do.
+func rollbackExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if len(decl.Decl) == 0 {
+		e.rollback()
+		return conn.WriteResult(0, 0)
+	}
+
+	if e.tx == nil {
+		return fmt.Errorf("ROLLBACK TO SAVEPOINT can only be used in transaction blocks")
+	}
+	if err := e.tx.rollbackTo(decl.Decl[0].Lexeme); err != nil {
+		return err
+	}
+	return conn.WriteResult(0, 0)
+}
+
+/*
+|-> savepoint
+	|-> sp1
+*/
+func savepointExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if e.tx == nil {
+		return fmt.Errorf("SAVEPOINT can only be used in transaction blocks")
+	}
+
+	e.tx.savepoint(decl.Decl[0].Lexeme)
+	return conn.WriteResult(0, 0)
+}
+
+/*
+|-> release
+	|-> sp1
+*/
+func releaseExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn) error {
+	if e.tx == nil {
+		return fmt.Errorf("RELEASE SAVEPOINT can only be used in transaction blocks")
+	}
+
+	if err := e.tx.release(decl.Decl[0].Lexeme); err != nil {
+		return err
+	}
+	return conn.WriteResult(0, 0)
+}
@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// trigger is a row trigger of a table, fired by INSERT, UPDATE or DELETE of each row,
+// before or after it is written. If its WHEN condition holds, its action either sets
+// values of NEW row, or raises an error aborting the statement. Since it runs within the
+// triggering statement, it is part of the same transaction.
+type trigger struct {
+	name   string
+	before bool
+	event  int
+	when   *parser.Decl
+	set    *parser.Decl
+	raise  string
+	// action is the text of condition and action, as written in CREATE TRIGGER
+	action string
+}
+
+/*
+|-> trigger
+	|-> check_total
+	|-> before
+	|-> insert
+	|-> on
+		|-> orders
+	|-> when
+		|-> total
+			|-> new
+			|-> <
+			|-> 0
+	|-> raise
+		|-> negative total
+	|-> WHEN (NEW.total < 0) RAISE 'negative total'
+*/
+// createTriggerExecutor adds a trigger to a table, once checked. Trigger names are unique
+// among all tables.
+func createTriggerExecutor(e *Engine, triggerDecl *parser.Decl, conn protocol.EngineConn) error {
+	t, table, err := newTrigger(triggerDecl)
+	if err != nil {
+		return err
+	}
+
+	r := e.relation(table)
+	if r == nil {
+		return &Error{Code: UndefinedTable, Table: table, Message: fmt.Sprintf("relation \"%s\" does not exist", table)}
+	}
+	if _, tr := e.trigger(t.name); tr != nil {
+		return errorf(DuplicateObject, "trigger \"%s\" already exists", t.name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	// Referenced columns and tables must exist
+	locked := map[*Relation]bool{r: true}
+	_, err = t.compile(e, r.table, locked)
+	for l := range locked {
+		if l != r {
+			l.RUnlock()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	r.table.triggers = append(r.table.triggers, t)
+	return conn.WriteResult(0, 1)
+}
+
+// newTrigger returns the trigger declared, and the name of its table
+func newTrigger(triggerDecl *parser.Decl) (*trigger, string, error) {
+	if len(triggerDecl.Decl) < 5 || len(triggerDecl.Decl[3].Decl) != 1 {
+		return nil, "", fmt.Errorf("parsing failed, malformed query")
+	}
+
+	t := &trigger{
+		name:   triggerDecl.Decl[0].Lexeme,
+		before: triggerDecl.Decl[1].Lexeme == "before",
+		event:  triggerDecl.Decl[2].Token,
+	}
+	table := triggerDecl.Decl[3].Decl[0].Lexeme
+
+	for _, d := range triggerDecl.Decl[4:] {
+		switch d.Token {
+		case parser.WhenToken:
+			t.when = d
+		case parser.SetToken:
+			t.set = d
+		case parser.RaiseToken:
+			if len(d.Decl) != 1 {
+				return nil, "", fmt.Errorf("parsing failed, malformed query")
+			}
+			t.raise = d.Decl[0].Lexeme
+		case parser.QuotedStringToken:
+			t.action = d.Lexeme
+		}
+	}
+
+	if t.set != nil && (!t.before || t.event == parser.DeleteToken) {
+		return nil, "", fmt.Errorf("trigger %s cannot set NEW values, which only BEFORE INSERT and BEFORE UPDATE triggers can", t.name)
+	}
+
+	return t, table, nil
+}
+
+// statement returns the CREATE TRIGGER statement of trigger on table
+func (t *trigger) statement(table string) string {
+	when := "AFTER"
+	if t.before {
+		when = "BEFORE"
+	}
+	event := "INSERT"
+	switch t.event {
+	case parser.UpdateToken:
+		event = "UPDATE"
+	case parser.DeleteToken:
+		event = "DELETE"
+	}
+
+	return fmt.Sprintf("CREATE TRIGGER %s %s %s ON %s FOR EACH ROW %s", quoteIdentifier(t.name), when, event, quoteIdentifier(table), t.action)
+}
+
+// parseTrigger returns the trigger created by statement, as returned by trigger.statement
+func parseTrigger(statement string) (*trigger, error) {
+	instructions, err := parser.ParseInstruction(statement)
+	if err != nil {
+		return nil, err
+	}
+	createDecl := instructions[0].Decls[0]
+	if createDecl.Token != parser.CreateToken || len(createDecl.Decl) != 1 || createDecl.Decl[0].Token != parser.TriggerToken {
+		return nil, fmt.Errorf("not a CREATE TRIGGER statement: %s", statement)
+	}
+
+	t, _, err := newTrigger(createDecl.Decl[0])
+	return t, err
+}
+
+// dropTriggerExecutor removes a trigger, of given table if any.
+// With ifExists, dropping a missing trigger does nothing.
+func dropTriggerExecutor(e *Engine, name string, table string, ifExists bool, conn protocol.EngineConn) error {
+	r, t := e.trigger(name)
+	if t == nil || (table != "" && r.table.name != table) {
+		if ifExists {
+			return conn.WriteResult(0, 0)
+		}
+		return errorf(UndefinedObject, "trigger \"%s\" does not exist", name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	for i := range r.table.triggers {
+		if r.table.triggers[i] == t {
+			r.table.triggers = append(r.table.triggers[:i:i], r.table.triggers[i+1:]...)
+			break
+		}
+	}
+
+	return conn.WriteResult(0, 1)
+}
+
+// trigger returns named trigger and its relation, or nil if there is none
+func (e *Engine) trigger(name string) (*Relation, *trigger) {
+	e.Lock()
+	relations := make([]*Relation, 0, len(e.relations))
+	for _, r := range e.relations {
+		relations = append(relations, r)
+	}
+	e.Unlock()
+
+	for _, r := range relations {
+		r.RLock()
+		for _, t := range r.table.triggers {
+			if t.name == name {
+				r.RUnlock()
+				return r, t
+			}
+		}
+		r.RUnlock()
+	}
+
+	return nil, nil
+}
+
+// fires returns true if relation has a trigger fired by given event
+func (r *Relation) fires(event int) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, t := range r.table.triggers {
+		if t.event == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compiledTrigger is a trigger ready to be fired, its condition and values being
+// evaluated on NEW and OLD rows
+type compiledTrigger struct {
+	when PredicateLinker
+	set  map[int]expression
+	err  error
+}
+
+// compile returns trigger of table ready to be fired. NEW row is in scope
+// for INSERT and UPDATE triggers, and OLD row for UPDATE and DELETE ones.
+func (t *trigger) compile(e *Engine, table *Table, locked map[*Relation]bool) (*compiledTrigger, error) {
+	var tables []*Table
+	if t.event != parser.DeleteToken {
+		tables = append(tables, &Table{name: "new", attributes: table.attributes, correlated: true})
+	}
+	if t.event != parser.InsertToken {
+		tables = append(tables, &Table{name: "old", attributes: table.attributes, correlated: true})
+	}
+
+	c := &compiledTrigger{}
+	if t.when != nil {
+		when, err := whereExecutor2(e, t.when.Decl, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		c.when = when
+	}
+
+	if t.set == nil {
+		c.err = errors.New(t.raise)
+		return c, nil
+	}
+	c.set = make(map[int]expression)
+	for _, assignDecl := range t.set.Decl {
+		if len(assignDecl.Decl) != 2 {
+			return nil, fmt.Errorf("parsing failed, malformed query")
+		}
+		attr := assignDecl.Decl[0]
+		if len(attr.Decl) == 0 || attr.Decl[0].Lexeme != "new" {
+			return nil, fmt.Errorf("trigger %s can only set NEW values, not %s", t.name, attr.Lexeme)
+		}
+		i := table.attributeIndex(attr.Lexeme)
+		if i < 0 {
+			return nil, &Error{Code: UndefinedColumn, Table: table.name, Column: attr.Lexeme, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" does not exist", attr.Lexeme, table.name)}
+		}
+		expr, err := expressionExecutor(e, assignDecl.Decl[1], tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		c.set[i] = expr
+	}
+
+	return c, nil
+}
+
+// rowTriggers are the triggers of a table fired by a statement, in order of creation
+type rowTriggers struct {
+	table  *Table
+	new    *Relation
+	old    *Relation
+	before []*compiledTrigger
+	after  []*compiledTrigger
+}
+
+// triggersExecutor returns triggers of locked relation fired by given event, which is
+// INSERT, UPDATE or DELETE token. A nil rowTriggers, returned if there is none, fires nothing.
+func triggersExecutor(e *Engine, r *Relation, event int, locked map[*Relation]bool) (*rowTriggers, error) {
+	var rt *rowTriggers
+
+	for _, t := range r.table.triggers {
+		if t.event != event {
+			continue
+		}
+		c, err := t.compile(e, r.table, locked)
+		if err != nil {
+			return nil, err
+		}
+		if rt == nil {
+			rt = &rowTriggers{
+				table: r.table,
+				new:   &Relation{table: &Table{name: "new", attributes: r.table.attributes}},
+				old:   &Relation{table: &Table{name: "old", attributes: r.table.attributes}},
+			}
+		}
+		if t.before {
+			rt.before = append(rt.before, c)
+		} else {
+			rt.after = append(rt.after, c)
+		}
+	}
+
+	return rt, nil
+}
+
+// fireBefore runs BEFORE triggers for a row, old or new being nil if there is none,
+// and returns the new row as set by them
+func (rt *rowTriggers) fireBefore(old *Tuple, new *Tuple) (*Tuple, error) {
+	if rt == nil {
+		return new, nil
+	}
+
+	return rt.fire(rt.before, old, new)
+}
+
+// fireAfter runs AFTER triggers for a written row, old or new being nil if there is none
+func (rt *rowTriggers) fireAfter(old *Tuple, new *Tuple) error {
+	if rt == nil {
+		return nil
+	}
+
+	_, err := rt.fire(rt.after, old, new)
+	return err
+}
+
+func (rt *rowTriggers) fire(triggers []*compiledTrigger, old *Tuple, new *Tuple) (*Tuple, error) {
+	for _, c := range triggers {
+		row := virtualRow{}
+		if new != nil {
+			row = row.with(rt.new, new)
+		}
+		if old != nil {
+			row = row.with(rt.old, old)
+		}
+
+		if c.when != nil {
+			ok, err := c.when.Eval(row)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+
+		values := make([]interface{}, len(new.Values))
+		copy(values, new.Values)
+		for i, expr := range c.set {
+			v, err := expr.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		new = NewTuple(values...)
+		if err := rt.table.convertValues(new); err != nil {
+			return nil, err
+		}
+	}
+
+	return new, nil
+}
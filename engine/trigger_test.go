@@ -0,0 +1,129 @@
+package engine_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestTrigger(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestTrigger")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE orders (id INT, price INT, quantity INT, total INT, status TEXT)`,
+		`CREATE TABLE audit (order_id INT)`,
+		`CREATE TRIGGER set_total BEFORE INSERT ON orders FOR EACH ROW SET NEW.total = NEW.price * NEW.quantity`,
+		`CREATE TRIGGER set_status BEFORE INSERT ON orders FOR EACH ROW WHEN (NEW.total > 100) SET NEW.status = 'large'`,
+		`CREATE TRIGGER no_decrease BEFORE UPDATE ON orders FOR EACH ROW WHEN (NEW.total < OLD.total) RAISE 'total cannot decrease'`,
+		`CREATE TRIGGER no_cancel AFTER UPDATE ON orders FOR EACH ROW WHEN (NEW.status = 'canceled') RAISE 'cannot cancel'`,
+		`CREATE TRIGGER no_delete BEFORE DELETE ON orders FOR EACH ROW WHEN (OLD.status = 'large') RAISE EXCEPTION 'cannot delete large order'`,
+		`INSERT INTO orders (id, price, quantity) VALUES (1, 10, 2)`,
+		`INSERT INTO orders (id, price, quantity, status) VALUES (2, 50, 3, 'small')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	// BEFORE INSERT triggers set values of NEW row, in order of creation
+	order := func(id int) (int64, string) {
+		var total int64
+		var status sql.NullString
+		if err := db.QueryRow(`SELECT total, status FROM orders WHERE id = $1`, id).Scan(&total, &status); err != nil {
+			t.Fatalf("cannot select order %d: %s", id, err)
+		}
+		return total, status.String
+	}
+	if total, status := order(1); total != 20 || status != "" {
+		t.Fatalf("expected order 1 total to be 20, got %d (%s)", total, status)
+	}
+	if total, status := order(2); total != 150 || status != "large" {
+		t.Fatalf("expected order 2 total to be 150 and large, got %d (%s)", total, status)
+	}
+
+	// Raised errors abort statement, leaving all rows unchanged
+	failing := []struct {
+		query string
+		err   string
+	}{
+		{`UPDATE orders SET total = 10 WHERE id > 0`, `total cannot decrease`},
+		{`UPDATE orders SET status = 'canceled' WHERE id = 1`, `cannot cancel`},
+		{`DELETE FROM orders`, `cannot delete large order`},
+	}
+	for _, f := range failing {
+		_, err := db.Exec(f.query)
+		if err == nil || err.Error() != f.err {
+			t.Fatalf("expected '%s' to fail with '%s', got %v", f.query, f.err, err)
+		}
+	}
+	if total, status := order(1); total != 20 || status != "" {
+		t.Fatalf("expected order 1 to be unchanged, got %d (%s)", total, status)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("expected 2 orders, got %d (%v)", count, err)
+	}
+
+	if _, err := db.Exec(`UPDATE orders SET total = 30 WHERE id = 1`); err != nil {
+		t.Fatalf("cannot update order: %s", err)
+	}
+	if total, _ := order(1); total != 30 {
+		t.Fatalf("expected order 1 total to be 30, got %d", total)
+	}
+
+	// Triggers fire within the transaction of their statement
+	if _, err := db.Exec(`CREATE TRIGGER fix_quantity BEFORE INSERT ON orders FOR EACH ROW WHEN (NEW.quantity IS NULL) SET NEW.quantity = 1, NEW.total = NEW.price`); err != nil {
+		t.Fatalf("cannot create trigger: %s", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %s", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO orders (id, price) VALUES (3, 5)`); err != nil {
+		t.Fatalf("cannot insert order: %s", err)
+	}
+	var quantity, total int64
+	if err := tx.QueryRow(`SELECT quantity, total FROM orders WHERE id = 3`).Scan(&quantity, &total); err != nil || quantity != 1 || total != 5 {
+		t.Fatalf("expected quantity 1 and total 5, got %d and %d (%v)", quantity, total, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("cannot rollback: %s", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("expected 2 orders, got %d (%v)", count, err)
+	}
+
+	invalid := []string{
+		`CREATE TRIGGER set_total BEFORE INSERT ON audit FOR EACH ROW SET NEW.order_id = 0`,
+		`CREATE TRIGGER late AFTER INSERT ON orders FOR EACH ROW SET NEW.total = 0`,
+		`CREATE TRIGGER removed BEFORE DELETE ON orders FOR EACH ROW SET NEW.total = 0`,
+		`CREATE TRIGGER unknown BEFORE INSERT ON orders FOR EACH ROW SET NEW.missing = 0`,
+		`CREATE TRIGGER previous BEFORE INSERT ON orders FOR EACH ROW WHEN (OLD.total > 0) RAISE 'error'`,
+		`CREATE TRIGGER nowhere BEFORE INSERT ON missing FOR EACH ROW RAISE 'error'`,
+		`DROP TRIGGER missing`,
+		`DROP TRIGGER set_total ON audit`,
+	}
+	for _, query := range invalid {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+
+	// Dropped triggers do not fire anymore
+	for _, query := range []string{`DROP TRIGGER no_delete ON orders`, `DROP TRIGGER IF EXISTS no_delete`} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("cannot drop trigger '%s': %s", query, err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM orders WHERE id = 2`); err != nil {
+		t.Fatalf("cannot delete order: %s", err)
+	}
+}
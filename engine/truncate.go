@@ -8,22 +8,35 @@ import (
 	"github.com/proullon/ramsql/engine/protocol"
 )
 
+/*
+|-> truncate
+	|-> events
+	|-> cascade
+*/
+// truncateExecutor removes all rows of a table, and restarts its sequences
 func truncateExecutor(e *Engine, trDecl *parser.Decl, conn protocol.EngineConn) error {
 	log.Debug("truncateExecutor")
 
 	// get tables to be deleted
 	table := NewTable(trDecl.Decl[0].Lexeme)
 
-	return truncateTable(e, table, conn)
+	// Rows of other tables may reference the ones of table
+	if name := e.referencedBy(table.name); name != "" {
+		return fmt.Errorf("cannot truncate a table referenced in a foreign key constraint: table \"%s\" references \"%s\"", name, table.name)
+	}
+
+	return truncateTable(e, table, true, conn)
 }
 
-func truncateTable(e *Engine, table *Table, conn protocol.EngineConn) error {
+// truncateTable discards all rows of a table at once. If restart is set,
+// sequences of auto increment attributes start over.
+func truncateTable(e *Engine, table *Table, restart bool, conn protocol.EngineConn) error {
 	var rowsDeleted int64
 
 	// get relations and write lock them
 	r := e.relation(table.name)
 	if r == nil {
-		return fmt.Errorf("Table %v not found", table.name)
+		return &Error{Code: UndefinedTable, Table: table.name, Message: fmt.Sprintf("Table %v not found", table.name)}
 	}
 	r.Lock()
 	defer r.Unlock()
@@ -32,6 +45,15 @@ func truncateTable(e *Engine, table *Table, conn protocol.EngineConn) error {
 		rowsDeleted = int64(len(r.rows))
 	}
 	r.rows = make([]*Tuple, 0)
+	r.rebuildIndexes()
+
+	if restart {
+		for _, a := range r.table.attributes {
+			if a.sequence != nil {
+				a.sequence.restart()
+			}
+		}
+	}
 
 	return conn.WriteResult(0, rowsDeleted)
 }
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+/*
+|-> union
+	|-> SELECT
+		|-> a
+		|-> FROM
+			|-> t1
+	|-> SELECT
+		|-> a
+		|-> FROM
+			|-> t2
+	|-> all
+	|-> order
+		|-> a
+	|-> limit
+		|-> 10
+*/
+// setOperationExecutor writes rows combined with UNION, INTERSECT or EXCEPT to conn, ordered
+// and limited if requested after the last statement. Column names are those of the first statement.
+func setOperationExecutor(e *Engine, decl *parser.Decl, conn protocol.EngineConn, locked map[*Relation]bool) error {
+	var functors []selectFunctor
+	var header []string
+	var err error
+	limit, offset := -1, 0
+
+	left, err := subqueryExecutor(e, decl.Decl[0], locked)
+	if err != nil {
+		return err
+	}
+	right, err := subqueryExecutor(e, decl.Decl[1], locked)
+	if err != nil {
+		return err
+	}
+	if len(left.header) != len(right.header) {
+		return fmt.Errorf("each %s query must have the same number of columns", strings.ToUpper(decl.Lexeme))
+	}
+	types, err := combinedTypes(strings.ToUpper(decl.Lexeme), left.types, right.types)
+	if err != nil {
+		return err
+	}
+
+	all := false
+	for _, d := range decl.Decl[2:] {
+		switch d.Token {
+		case parser.AllToken:
+			all = true
+		case parser.LimitToken:
+			limit, err = strconv.Atoi(d.Decl[0].Lexeme)
+			if err != nil {
+				return fmt.Errorf("wrong limit value: %s", err)
+			}
+		case parser.OffsetToken:
+			offset, err = strconv.Atoi(d.Decl[0].Lexeme)
+			if err != nil {
+				return fmt.Errorf("wrong offset value: %s", err)
+			}
+		}
+	}
+
+	r, err := left.relation(strings.ToLower(decl.Lexeme))
+	if err != nil {
+		return err
+	}
+	r.rows = combineRows(decl.Token, all, r.rows, right.rows)
+
+	for _, d := range decl.Decl[2:] {
+		if d.Token == parser.OrderToken {
+			f, err := orderbyExecutor(e, d, []*Table{r.table}, locked, nil, nil)
+			if err != nil {
+				return err
+			}
+			functors = append(functors, f)
+		}
+	}
+
+	conn = &typedConn{EngineConn: conn, types: types}
+	if limit >= 0 {
+		conn = limitedConn(conn, limit)
+	}
+	if offset > 0 {
+		conn = offsetedConn(conn, offset)
+	}
+
+	if len(functors) == 0 {
+		functors = append(functors, &defaultSelectFunction{})
+	}
+
+	// Duplicate rows are removed, unless ALL is specified
+	if !all {
+		functors = []selectFunctor{&distinctFunctor{next: functors}}
+	}
+
+	for _, a := range r.table.attributes {
+		header = append(header, r.table.name+"."+a.name)
+	}
+
+	return generateVirtualRows(e, conn, header, left.header, r, nil, nil, functors)
+}
+
+// combinedTypes returns the types of columns combined by operation, those of left side unless
+// unknown. When known on both sides, types must be of the same category, like integer and decimal.
+func combinedTypes(operation string, left []protocol.ColumnType, right []protocol.ColumnType) ([]protocol.ColumnType, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	types := make([]protocol.ColumnType, len(left))
+	for i := range left {
+		types[i] = left[i]
+		l, r := left[i].DatabaseTypeName, right[i].DatabaseTypeName
+		if l == "" {
+			types[i] = right[i]
+			continue
+		}
+		if r != "" && typeCategory(l) != typeCategory(r) {
+			return nil, errorf(DatatypeMismatch, "%s types %s and %s cannot be matched", operation, strings.ToLower(l), strings.ToLower(r))
+		}
+	}
+
+	return types, nil
+}
+
+// typeCategory returns the category of a column type, values of types of the same category
+// being comparable. Types are their own category, except numeric, string and date ones.
+func typeCategory(typeName string) string {
+	switch strings.ToUpper(typeName) {
+	case "INT", "INTEGER", "INT2", "INT4", "INT8", "SMALLINT", "BIGINT", "SMALLSERIAL", "SERIAL", "BIGSERIAL",
+		"REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DECIMAL", "NUMERIC":
+		return "numeric"
+	case "TEXT", "VARCHAR", "CHAR", "CHARACTER":
+		return "string"
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE", "DATETIME":
+		return "datetime"
+	case "BOOL", "BOOLEAN":
+		return "boolean"
+	}
+
+	return strings.ToUpper(typeName)
+}
+
+// combineRows returns rows of both sides with UNION, rows of left side found in right one
+// with INTERSECT, or rows of left side not found in right one with EXCEPT.
+// With ALL, each right row matches at most one left row so multiplicity is kept.
+// NULL values are equal.
+func combineRows(operation int, all bool, left []*Tuple, right [][]interface{}) []*Tuple {
+	if operation == parser.UnionToken {
+		for _, row := range right {
+			left = append(left, &Tuple{Values: row})
+		}
+		return left
+	}
+
+	count := make(map[string]int)
+	for _, row := range right {
+		count[valuesKey(row)]++
+	}
+
+	var res []*Tuple
+	for _, t := range left {
+		k := valuesKey(t.Values)
+		found := count[k] > 0
+		if found && all {
+			count[k]--
+		}
+		if found == (operation == parser.IntersectToken) {
+			res = append(res, t)
+		}
+	}
+
+	return res
+}
+
+// valuesKey returns a string identifying given values, NULL values being equal
+func valuesKey(values []interface{}) string {
+	var s []string
+
+	for _, v := range values {
+		if v == nil {
+			s = append(s, "\x00")
+			continue
+		}
+		s = append(s, fmt.Sprintf("%v", v))
+	}
+
+	return strings.Join(s, "\x01")
+}
@@ -0,0 +1,203 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestUnion(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUnion")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t1 (id BIGSERIAL, a TEXT, b INT)`,
+		`CREATE TABLE t2 (id BIGSERIAL, c TEXT)`,
+		`INSERT INTO t1 (a, b) VALUES ('x', 1)`,
+		`INSERT INTO t1 (a, b) VALUES ('y', 2)`,
+		`INSERT INTO t1 (a, b) VALUES ('y', 3)`,
+		`INSERT INTO t2 (c) VALUES ('z')`,
+		`INSERT INTO t2 (c) VALUES ('x')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT a FROM t1 UNION SELECT c FROM t2 ORDER BY a`:                                                         {"x", "y", "z"},
+		`SELECT a FROM t1 UNION ALL SELECT c FROM t2 ORDER BY a`:                                                     {"x", "x", "y", "y", "z"},
+		`SELECT a FROM t1 UNION ALL SELECT c FROM t2 ORDER BY a DESC LIMIT 2`:                                        {"z", "y"},
+		`SELECT a FROM t1 UNION SELECT c FROM t2 ORDER BY a LIMIT 1 OFFSET 1`:                                        {"y"},
+		`SELECT a FROM t1 WHERE b > 1 UNION SELECT c FROM t2 WHERE c = 'z' ORDER BY a`:                               {"y", "z"},
+		`SELECT a FROM t1 UNION SELECT c FROM t2 UNION ALL SELECT c FROM t2 ORDER BY a`:                              {"x", "x", "y", "z", "z"},
+		`SELECT u.a FROM (SELECT a FROM t1 UNION SELECT c FROM t2) u WHERE u.a = 'z'`:                                {"z"},
+		`SELECT c FROM t2 WHERE c IN (SELECT a FROM t1 WHERE b = 1 UNION SELECT c FROM t2 WHERE c = 'z') ORDER BY c`: {"x", "z"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// Column names come from first statement
+	rows, err := db.Query(`SELECT a, b FROM t1 UNION SELECT c, id FROM t2`)
+	if err != nil {
+		t.Fatalf("Cannot query union: %s", err)
+	}
+	columns, err := rows.Columns()
+	if err != nil || len(columns) != 2 || columns[0] != "a" || columns[1] != "b" {
+		t.Fatalf("Expected columns [a b], got %v (%v)", columns, err)
+	}
+	var count int
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 5 {
+		t.Fatalf("Expected 5 rows, got %d", count)
+	}
+
+	_, err = db.Query(`SELECT a, b FROM t1 UNION SELECT c FROM t2`)
+	if err == nil {
+		t.Fatalf("Expected error with different number of columns")
+	}
+
+	// Columns of both statements must have comparable types
+	for _, query := range []string{
+		`SELECT a FROM t1 UNION SELECT id FROM t2`,
+		`SELECT a, b FROM t1 UNION ALL SELECT c, c FROM t2`,
+		`SELECT b FROM t1 UNION SELECT id FROM t2 UNION SELECT c FROM t2`,
+	} {
+		_, err = db.Query(query)
+		if err == nil || !strings.Contains(err.Error(), "cannot be matched") {
+			t.Fatalf("%s: expected error with mismatching column types, got %v", query, err)
+		}
+	}
+	if values(`SELECT b FROM t1 WHERE b = 1 UNION SELECT id FROM t2 ORDER BY b`) == nil {
+		t.Fatalf("Expected integer columns to be combined")
+	}
+}
+
+func TestIntersectExcept(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestIntersectExcept")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE t1 (id BIGSERIAL, a TEXT, b INT)`,
+		`CREATE TABLE t2 (id BIGSERIAL, c TEXT, d INT)`,
+		`INSERT INTO t1 (a, b) VALUES ('x', 1)`,
+		`INSERT INTO t1 (a, b) VALUES ('x', 1)`,
+		`INSERT INTO t1 (a, b) VALUES ('x', 1)`,
+		`INSERT INTO t1 (a, b) VALUES ('y', 2)`,
+		`INSERT INTO t1 (a) VALUES ('n')`,
+		`INSERT INTO t2 (c, d) VALUES ('x', 1)`,
+		`INSERT INTO t2 (c, d) VALUES ('z', 3)`,
+		`INSERT INTO t2 (c) VALUES ('n')`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	values := func(query string) []string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("Cannot query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+
+	queries := map[string][]string{
+		`SELECT a FROM t1 INTERSECT SELECT c FROM t2 ORDER BY a`:                                      {"n", "x"},
+		`SELECT a FROM t1 INTERSECT ALL SELECT c FROM t2 ORDER BY a`:                                  {"n", "x"},
+		`SELECT a FROM t1 EXCEPT SELECT c FROM t2 ORDER BY a`:                                         {"y"},
+		`SELECT a FROM t1 EXCEPT ALL SELECT c FROM t2 ORDER BY a`:                                     {"x", "x", "y"},
+		`SELECT c FROM t2 EXCEPT SELECT a FROM t1`:                                                    {"z"},
+		`SELECT a FROM t1 EXCEPT SELECT c FROM t2 WHERE c = 'n' ORDER BY a DESC LIMIT 1`:              {"y"},
+		`SELECT c FROM t2 WHERE c = 'z' UNION SELECT a FROM t1 INTERSECT SELECT c FROM t2 ORDER BY c`: {"n", "x", "z"},
+		`SELECT c FROM t2 WHERE EXISTS (SELECT a FROM t1 EXCEPT SELECT c FROM t2) ORDER BY c`:         {"n", "x", "z"},
+	}
+
+	for query, expected := range queries {
+		res := values(query)
+		if len(res) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", query, expected, res)
+		}
+		for i := range res {
+			if res[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", query, expected, res)
+			}
+		}
+	}
+
+	// NULL values are equal
+	var count int
+	rows, err := db.Query(`SELECT a, b FROM t1 INTERSECT SELECT c, d FROM t2`)
+	if err != nil {
+		t.Fatalf("Cannot query intersect: %s", err)
+	}
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 2 {
+		t.Fatalf("Expected 2 rows, got %d", count)
+	}
+
+	_, err = db.Query(`SELECT a, b FROM t1 EXCEPT SELECT c FROM t2`)
+	if err == nil {
+		t.Fatalf("Expected error with different number of columns")
+	}
+}
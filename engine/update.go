@@ -2,7 +2,6 @@ package engine
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/proullon/ramsql/engine/log"
@@ -27,49 +26,128 @@ func updateExecutor(e *Engine, updateDecl *parser.Decl, conn protocol.EngineConn
 
 	updateDecl.Stringy(0)
 
+	// Aliased table, joined with other tables, or whose values are computed, is in scope like in SELECT
+	for _, d := range updateDecl.Decl {
+		if d.Token == parser.FromToken || selectedAlias(d) != "" || (d.Token == parser.SetToken && computedSet(d)) {
+			return updateFromExecutor(e, updateDecl, conn)
+		}
+	}
+
 	// Fetch table from name and write lock it
 	r := e.relation(updateDecl.Decl[0].Lexeme)
 	if r == nil {
-		return fmt.Errorf("Table %s does not exists", updateDecl.Decl[0].Lexeme)
+		return &Error{Code: UndefinedTable, Table: updateDecl.Decl[0].Lexeme, Message: fmt.Sprintf("Table %s does not exists", updateDecl.Decl[0].Lexeme)}
 	}
 	r.Lock()
-	r.Unlock()
+	defer r.Unlock()
+
+	// Current time is the same for all updated rows
+	now := time.Now()
 
 	// Set decl
-	values, err := setExecutor(updateDecl.Decl[1])
+	values, err := setExecutor(updateDecl.Decl[1], now)
 	if err != nil {
 		return err
 	}
 
-	// Where decl
-	predicates, err := whereExecutor(updateDecl.Decl[2], r.table.name)
+	// Where decl, evaluated like SELECT one so subqueries may be used.
+	// Updated relation is already locked if a subquery reads it.
+	locked := map[*Relation]bool{r: true}
+	defer func() {
+		for l := range locked {
+			if l != r {
+				l.RUnlock()
+			}
+		}
+	}()
+	predicate := PredicateLinker(&TruePredicate)
+	for _, d := range updateDecl.Decl[2:] {
+		if d.Token != parser.WhereToken {
+			continue
+		}
+		predicate, err = whereExecutor2(e, d.Decl, []*Table{r.table}, locked)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Triggers fired by updated rows, and constraints they must satisfy
+	updating, err := triggersExecutor(e, r, parser.UpdateToken, locked)
 	if err != nil {
 		return err
 	}
+	checks, err := checksExecutor(e, r.table, locked)
+	if err != nil {
+		return err
+	}
+
+	// Returning decl
+	ret, err := returningExecutor(r.table, updateDecl.Decl)
+	if err != nil {
+		return err
+	}
+
+	// Updated rows replace current ones only once all constraints are checked
+	rows := make([]*Tuple, len(r.rows))
+	copy(rows, r.rows)
+	var updated []*Tuple
 
-	var ok, res bool
 	for i := range r.rows {
-		ok = true
-		// If the row validate all predicates, write it
-		for _, predicate := range predicates {
-			if res, err = predicate.Evaluate(r.rows[i], r.table); err != nil {
-				return err
-			}
-			if res == false {
-				ok = false
-				continue
-			}
+		if err := e.canceled(); err != nil {
+			return err
 		}
 
+		// If the row validates predicate, write it
+		ok, err := predicate.Eval(virtualRow{}.with(r, r.rows[i]))
+		if err != nil {
+			return err
+		}
 		if ok {
 			num++
-			err = updateValues(r, i, values)
+			rowValues, err := onUpdateValues(e, r.table, values, now)
 			if err != nil {
 				return err
 			}
+			rows[i] = updateValues(r, i, rowValues)
+			if err := r.table.convertValues(rows[i]); err != nil {
+				return err
+			}
+			if rows[i], err = updating.fireBefore(r.rows[i], rows[i]); err != nil {
+				return err
+			}
+			if err := r.table.checkNotNull(rows[i]); err != nil {
+				return err
+			}
+			if err := checks.check(rows[i]); err != nil {
+				return err
+			}
+			updated = append(updated, rows[i])
+		}
+	}
+
+	if err := r.checkUniqueChanges(r.replaced(rows)); err != nil {
+		return err
+	}
+	if err := e.checkForeignKeys(r, updated, rows, locked); err != nil {
+		return err
+	}
+	if err := e.checkReferences(r, rows, locked); err != nil {
+		return err
+	}
+	if err := fireUpdated(updating, r.rows, rows); err != nil {
+		return err
+	}
+	for i := range rows {
+		if rows[i] != r.rows[i] {
+			r.removeFromIndexes(r.rows[i])
+			r.addToIndexes(rows[i])
 		}
 	}
+	r.rows = rows
 
+	if ret != nil {
+		return ret.write(conn, updated)
+	}
 	return conn.WriteResult(0, num)
 }
 
@@ -79,37 +157,329 @@ func updateExecutor(e *Engine, updateDecl *parser.Decl, conn protocol.EngineConn
 					|-> =
 					|-> roger@gmail.com
 */
-func setExecutor(setDecl *parser.Decl) (map[string]interface{}, error) {
+func setExecutor(setDecl *parser.Decl, now time.Time) (map[string]interface{}, error) {
 
 	values := make(map[string]interface{})
 
 	for _, attr := range setDecl.Decl {
-		values[attr.Lexeme] = attr.Decl[1].Lexeme
+		switch attr.Decl[1].Token {
+		case parser.NullToken:
+			values[attr.Lexeme] = nil
+		case parser.NowToken, parser.CurrentTimestampToken, parser.LocalTimestampToken:
+			values[attr.Lexeme] = now
+		default:
+			values[attr.Lexeme] = attr.Decl[1].Lexeme
+		}
 	}
 
 	return values, nil
 }
 
-func updateValues(r *Relation, row int, values map[string]interface{}) error {
+/*
+|-> update
+	|-> orders
+		|-> as
+			|-> o
+	|-> set
+		|-> region
+			|-> =
+			|-> region
+				|-> c
+	|-> from
+		|-> customers
+			|-> as
+				|-> c
+	|-> where
+		|-> customer_id
+			|-> o
+			|-> =
+			|-> id
+				|-> c
+*/
+// updateFromExecutor updates rows of a table joined with tables of FROM clause, if any,
+// which validate WHERE clause. SET values may be computed from attributes of all tables.
+// A row joined with several rows of FROM tables is updated only once, with the first
+// of them in order of FROM tables rows.
+func updateFromExecutor(e *Engine, updateDecl *parser.Decl, conn protocol.EngineConn) error {
+	nameDecl := updateDecl.Decl[0]
+	r := e.relation(nameDecl.Lexeme)
+	if r == nil {
+		return &Error{Code: UndefinedTable, Table: nameDecl.Lexeme, Message: fmt.Sprintf("Table %s does not exists", nameDecl.Lexeme)}
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	// Updated table is in scope with its alias, and is not read locked if joined with itself
+	target := &Relation{table: &Table{name: nameDecl.Lexeme, attributes: r.table.attributes}, rows: r.rows}
+	if alias := selectedAlias(nameDecl); alias != "" {
+		target.table.name = alias
+		target.table.aliased = nameDecl.Lexeme
+	}
+	tables := []*Table{target.table}
+	locked := map[*Relation]bool{r: true}
+	defer func() {
+		for l := range locked {
+			if l != r {
+				l.RUnlock()
+			}
+		}
+	}()
+
+	var setDecl, whereDecl *parser.Decl
+	var joiners []joiner
+	for _, d := range updateDecl.Decl[1:] {
+		switch d.Token {
+		case parser.SetToken:
+			setDecl = d
+		case parser.WhereToken:
+			whereDecl = d
+		case parser.FromToken:
+			for _, tableDecl := range d.Decl {
+				from, err := tableReferenceExecutor(e, tableDecl, tables, locked)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, from.table)
+				joiners = append(joiners, &inner{relation: from, predicate: &TruePredicate})
+			}
+		}
+	}
+	if setDecl == nil || whereDecl == nil {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+
+	predicate, err := whereExecutor2(e, whereDecl.Decl, tables, locked)
+	if err != nil {
+		return err
+	}
+
+	// Current time is the same for all updated rows
+	now := time.Now()
+	values, err := setExecutor(setDecl, now)
+	if err != nil {
+		return err
+	}
+	exprs, err := setExpressionsExecutor(e, r.table, setDecl, tables, locked)
+	if err != nil {
+		return err
+	}
+	updating, err := triggersExecutor(e, r, parser.UpdateToken, locked)
+	if err != nil {
+		return err
+	}
+	checks, err := checksExecutor(e, r.table, locked)
+	if err != nil {
+		return err
+	}
+
+	ret, err := returningExecutor(target.table, updateDecl.Decl)
+	if err != nil {
+		return err
+	}
+
+	// Updated rows replace current ones only once all constraints are checked
+	rows := make([]*Tuple, len(r.rows))
+	copy(rows, r.rows)
+	var updated []*Tuple
+
+	for i, t := range r.rows {
+		if err := e.canceled(); err != nil {
+			return err
+		}
+		joined := []virtualRow{virtualRow{}.with(target, t)}
+		for _, j := range joiners {
+			if joined, err = j.Join(e, joined); err != nil {
+				return err
+			}
+		}
+
+		var match virtualRow
+		for _, row := range joined {
+			ok, err := predicate.Eval(row)
+			if err != nil {
+				return err
+			}
+			if ok {
+				match = row
+				break
+			}
+		}
+		if match == nil {
+			continue
+		}
+
+		rowValues, err := setValues(values, exprs, match)
+		if err != nil {
+			return err
+		}
+		if rowValues, err = onUpdateValues(e, r.table, rowValues, now); err != nil {
+			return err
+		}
+		rows[i] = updateValues(r, i, rowValues)
+		if err := r.table.convertValues(rows[i]); err != nil {
+			return err
+		}
+		if rows[i], err = updating.fireBefore(t, rows[i]); err != nil {
+			return err
+		}
+		if err := r.table.checkNotNull(rows[i]); err != nil {
+			return err
+		}
+		if err := checks.check(rows[i]); err != nil {
+			return err
+		}
+		updated = append(updated, rows[i])
+	}
+
+	if err := r.checkUniqueChanges(r.replaced(rows)); err != nil {
+		return err
+	}
+	if err := e.checkForeignKeys(r, updated, rows, locked); err != nil {
+		return err
+	}
+	if err := e.checkReferences(r, rows, locked); err != nil {
+		return err
+	}
+	if err := fireUpdated(updating, r.rows, rows); err != nil {
+		return err
+	}
+	for i := range rows {
+		if rows[i] != r.rows[i] {
+			r.removeFromIndexes(r.rows[i])
+			r.addToIndexes(rows[i])
+		}
+	}
+	r.rows = rows
+
+	if ret != nil {
+		return ret.write(conn, updated)
+	}
+	return conn.WriteResult(0, int64(len(updated)))
+}
+
+// setExpressionsExecutor returns expressions computing values of SET clause which are not
+// literals, like operations or qualified attributes, from attributes of given tables.
+// Attributes set must exist in table t.
+func setExpressionsExecutor(e *Engine, t *Table, setDecl *parser.Decl, tables []*Table, locked map[*Relation]bool) (map[string]expression, error) {
+	exprs := make(map[string]expression)
+
+	for _, attr := range setDecl.Decl {
+		if t.attributeIndex(attr.Lexeme) < 0 {
+			return nil, &Error{Code: UndefinedColumn, Table: t.name, Column: attr.Lexeme, Message: fmt.Sprintf("column \"%s\" of relation \"%s\" does not exist", attr.Lexeme, t.name)}
+		}
+		v := attr.Decl[1]
+		if !isExpression(v) && (v.Token != parser.StringToken || len(v.Decl) == 0) {
+			continue
+		}
+		expr, err := expressionExecutor(e, v, tables, locked)
+		if err != nil {
+			return nil, err
+		}
+		exprs[attr.Lexeme] = expr
+	}
+
+	return exprs, nil
+}
+
+// computedSet returns true if a value of SET clause is an expression
+func computedSet(setDecl *parser.Decl) bool {
+	for _, attr := range setDecl.Decl {
+		if len(attr.Decl) > 1 && isExpression(attr.Decl[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setValues returns values of SET clause for a virtual row, literal ones being
+// given by setExecutor, and other ones computed with their expression
+func setValues(values map[string]interface{}, exprs map[string]expression, row virtualRow) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(values))
+
+	for name, v := range values {
+		res[name] = v
+	}
+	for name, expr := range exprs {
+		v, err := expr.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = v
+	}
+
+	return res, nil
+}
+
+// onUpdateValues returns values of SET clause for an updated row, with the ones
+// of attributes having an ON UPDATE clause which are not set explicitly
+func onUpdateValues(e *Engine, t *Table, values map[string]interface{}, now time.Time) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(values))
+
+	for name, v := range values {
+		res[name] = v
+	}
+	for _, a := range t.attributes {
+		if _, ok := values[a.name]; ok || a.onUpdateDecl == nil {
+			continue
+		}
+		v, err := computeValue(a.onUpdateValue, e, now)
+		if err != nil {
+			return nil, err
+		}
+		res[a.name] = v
+	}
+
+	return res, nil
+}
+
+// replaced returns the rows of relation which differ from given ones, at the same positions,
+// and the rows replacing them
+func (r *Relation) replaced(rows []*Tuple) (removed []*Tuple, added []*Tuple) {
+	for i := range rows {
+		if rows[i] != r.rows[i] {
+			removed = append(removed, r.rows[i])
+			added = append(added, rows[i])
+		}
+	}
+
+	return removed, added
+}
+
+// fireUpdated runs AFTER UPDATE triggers for rows which differ from current ones
+func fireUpdated(updating *rowTriggers, current []*Tuple, rows []*Tuple) error {
+	for i := range rows {
+		if rows[i] == current[i] {
+			continue
+		}
+		if err := updating.fireAfter(current[i], rows[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateValues returns a copy of given row with new values
+func updateValues(r *Relation, row int, values map[string]interface{}) *Tuple {
+	t := NewTuple(r.rows[row].Values...)
+
 	for i := range r.table.attributes {
 		val, ok := values[r.table.attributes[i].name]
 		if !ok {
 			continue
 		}
 		log.Debug("Type of '%s' is '%s'\n", r.table.attributes[i].name, r.table.attributes[i].typeName)
-		switch strings.ToLower(r.table.attributes[i].typeName) {
-		case "timestamp", "localtimestamp":
-			s, ok := val.(string)
-			if ok && (s == "current_timestamp" || s == "now()") {
-				val = time.Now()
-			}
+		switch val := val.(type) {
+		case nil:
+			t.Values[i] = nil
+		case time.Time:
 			// format time.Time into parsable string
-			if t, ok := val.(time.Time); ok {
-				val = t.Format(parser.DateLongFormat)
-			}
+			t.Values[i] = val.Format(parser.DateLongFormat)
+		default:
+			t.Values[i] = fmt.Sprintf("%v", val)
 		}
-		r.rows[row].Values[i] = fmt.Sprintf("%v", val)
 	}
 
-	return nil
+	return t
 }
@@ -2,6 +2,7 @@ package engine_test
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -161,3 +162,176 @@ func TestUpdateNotNull(t *testing.T) {
 	}
 
 }
+
+func TestUpdateFrom(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUpdateFrom")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE customers (id INT PRIMARY KEY, region TEXT)`,
+		`CREATE TABLE orders (id INT PRIMARY KEY, customer_id INT, region TEXT)`,
+		`CREATE TABLE offices (region TEXT, city TEXT)`,
+		`INSERT INTO customers (id, region) VALUES (1, 'emea'), (2, 'apac')`,
+		`INSERT INTO orders (id, customer_id, region) VALUES (1, 1, 'none'), (2, 2, 'none'), (3, 3, 'none')`,
+		`INSERT INTO offices (region, city) VALUES ('emea', 'Paris'), ('emea', 'Berlin')`,
+	}
+	for _, b := range batch {
+		if _, err = db.Exec(b); err != nil {
+			t.Fatalf("Cannot exec query '%s': %s", b, err)
+		}
+	}
+
+	region := func(id int) string {
+		var r string
+		if err := db.QueryRow(`SELECT region FROM orders WHERE id = $1`, id).Scan(&r); err != nil {
+			t.Fatalf("Cannot select order %d: %s", id, err)
+		}
+		return r
+	}
+
+	res, err := db.Exec(`UPDATE orders o SET region = c.region FROM customers c WHERE o.customer_id = c.id`)
+	if err != nil {
+		t.Fatalf("Cannot update from customers: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("Expected 2 rows affected, got %d", n)
+	}
+	if r1, r2, r3 := region(1), region(2), region(3); r1 != "emea" || r2 != "apac" || r3 != "none" {
+		t.Fatalf("Expected regions (emea, apac, none), got (%s, %s, %s)", r1, r2, r3)
+	}
+
+	// Row matching several rows of FROM tables is updated once, with the first of them
+	res, err = db.Exec(`UPDATE orders SET region = offices.city FROM offices WHERE orders.region = offices.region`)
+	if err != nil {
+		t.Fatalf("Cannot update from offices: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Expected 1 row affected, got %d", n)
+	}
+	if r := region(1); r != "Paris" {
+		t.Fatalf("Expected region Paris, got %s", r)
+	}
+
+	// Updated table joined with itself, with RETURNING
+	var id int
+	var r string
+	err = db.QueryRow(`UPDATE orders o SET region = other.region FROM orders other WHERE o.id = 3 AND other.id = 2 RETURNING o.id, region`).Scan(&id, &r)
+	if err != nil {
+		t.Fatalf("Cannot update from orders: %s", err)
+	}
+	if id != 3 || r != "apac" {
+		t.Fatalf("Expected returned row (3, apac), got (%d, %s)", id, r)
+	}
+
+	rejected := []string{
+		`UPDATE orders o SET region = c.region FROM unknown c WHERE o.customer_id = c.id`,
+		`UPDATE orders o SET region = c.unknown FROM customers c WHERE o.customer_id = c.id`,
+		`UPDATE orders o SET unknown = c.region FROM customers c WHERE o.customer_id = c.id`,
+		`UPDATE orders o SET id = c.id FROM customers c WHERE o.id > 0`,
+	}
+	for _, query := range rejected {
+		if _, err = db.Exec(query); err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+	if r := region(1); r != "Paris" {
+		t.Fatalf("Expected region left as Paris, got %s", r)
+	}
+}
+
+func TestUpdateOnUpdate(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUpdateOnUpdate")
+	if err != nil {
+		t.Fatalf("sql.Open : Error : %s\n", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id INT UNIQUE, email TEXT, version INT DEFAULT 0 ON UPDATE 1, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP)`,
+		`INSERT INTO account (id, email) VALUES (1, 'foo@bar.com')`,
+		`INSERT INTO account (id, email) VALUES (2, 'bar@bar.com')`,
+		`INSERT INTO account (id, email) VALUES (3, 'baz@bar.com')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	updatedAt := func() map[int]string {
+		rows, err := db.Query(`SELECT id, updated_at FROM account`)
+		if err != nil {
+			t.Fatalf("Cannot select updated_at: %s", err)
+		}
+		defer rows.Close()
+		res := make(map[int]string)
+		for rows.Next() {
+			var id int
+			var at string
+			if err := rows.Scan(&id, &at); err != nil {
+				t.Fatalf("Cannot scan row: %s", err)
+			}
+			res[id] = at
+		}
+		return res
+	}
+
+	inserted := updatedAt()
+	if inserted[1] == "" || inserted[2] == "" || inserted[3] == "" {
+		t.Fatalf("Expected updated_at to be set on insert, got %v", inserted)
+	}
+
+	// Updated rows are refreshed with the same time, other ones are left untouched
+	time.Sleep(10 * time.Millisecond)
+	if _, err := db.Exec(`UPDATE account SET email = 'new@bar.com' WHERE id <= 2`); err != nil {
+		t.Fatalf("Cannot update: %s", err)
+	}
+	updated := updatedAt()
+	if updated[1] == inserted[1] || updated[1] != updated[2] || updated[3] != inserted[3] {
+		t.Fatalf("Expected rows 1 and 2 to be refreshed, got %v then %v", inserted, updated)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT version FROM account WHERE id = 3`).Scan(&version); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %d (%v)", version, err)
+	}
+	if err := db.QueryRow(`SELECT version FROM account WHERE id = 1`).Scan(&version); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %d (%v)", version, err)
+	}
+
+	// Computed SET and ON CONFLICT DO UPDATE refresh rows as well
+	time.Sleep(10 * time.Millisecond)
+	if _, err := db.Exec(`UPDATE account a SET email = a.email WHERE a.id = 3`); err != nil {
+		t.Fatalf("Cannot update: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := db.Exec(`INSERT INTO account (id, email) VALUES (1, 'qux@bar.com') ON CONFLICT (id) DO UPDATE SET email = excluded.email`); err != nil {
+		t.Fatalf("Cannot upsert: %s", err)
+	}
+	refreshed := updatedAt()
+	if refreshed[3] == updated[3] || refreshed[1] == updated[1] || refreshed[2] != updated[2] {
+		t.Fatalf("Expected rows 1 and 3 to be refreshed, got %v then %v", updated, refreshed)
+	}
+
+	// Explicitly set value is kept
+	if _, err := db.Exec(`UPDATE account SET updated_at = '2020-01-01 00:00:00', version = 5 WHERE id = 2`); err != nil {
+		t.Fatalf("Cannot update: %s", err)
+	}
+	var at string
+	if err := db.QueryRow(`SELECT updated_at, version FROM account WHERE id = 2`).Scan(&at, &version); err != nil {
+		t.Fatalf("Cannot select: %s", err)
+	}
+	if !strings.HasPrefix(at, "2020-01-01") || version != 5 {
+		t.Fatalf("Expected explicit values to be kept, got %s and %d", at, version)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE bad (id UUID ON UPDATE 'a')`); err == nil {
+		t.Fatalf("Expected invalid ON UPDATE value to be rejected")
+	}
+}
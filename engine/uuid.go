@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// uuidText returns v as a UUID in canonical form, lower case hexadecimal digits in groups
+// of 8-4-4-4-12 separated by hyphens. Upper case digits are accepted.
+func uuidText(v interface{}) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(stringValue(v)))
+	if len(s) != 36 {
+		return "", errorf(InvalidTextRepresentation, "invalid input syntax for type uuid: \"%v\"", v)
+	}
+
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", errorf(InvalidTextRepresentation, "invalid input syntax for type uuid: \"%v\"", v)
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdef", c) {
+				return "", errorf(InvalidTextRepresentation, "invalid input syntax for type uuid: \"%v\"", v)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// genRandomUUIDFunction returns a random version 4 UUID
+func genRandomUUIDFunction(args []interface{}) (interface{}, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,78 @@
+package engine_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestUUID(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestUUID")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE account (id UUID DEFAULT gen_random_uuid() UNIQUE, name TEXT)`,
+		`INSERT INTO account (name) VALUES ('alice')`,
+		`INSERT INTO account (name) VALUES ('bob')`,
+		`INSERT INTO account (id, name) VALUES ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', 'carol')`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec '%s': %s", b, err)
+		}
+	}
+
+	// Generated UUIDs are random version 4 ones
+	canonical := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	var alice, bob string
+	if err := db.QueryRow(`SELECT id FROM account WHERE name = 'alice'`).Scan(&alice); err != nil {
+		t.Fatalf("cannot select id: %s", err)
+	}
+	if err := db.QueryRow(`SELECT id FROM account WHERE name = 'bob'`).Scan(&bob); err != nil {
+		t.Fatalf("cannot select id: %s", err)
+	}
+	if !canonical.MatchString(alice) || !canonical.MatchString(bob) || alice == bob {
+		t.Fatalf("expected two random UUIDs, got %s and %s", alice, bob)
+	}
+
+	var generated string
+	if err := db.QueryRow(`SELECT gen_random_uuid() FROM account WHERE name = 'alice'`).Scan(&generated); err != nil || !canonical.MatchString(generated) {
+		t.Fatalf("expected random UUID, got %s (%v)", generated, err)
+	}
+
+	// UUIDs are stored, and compared, in canonical form
+	var id string
+	if err := db.QueryRow(`SELECT id FROM account WHERE name = 'carol'`).Scan(&id); err != nil || id != "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11" {
+		t.Fatalf("expected canonical UUID, got %s (%v)", id, err)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT name FROM account WHERE id = 'A0EEBC99-9C0B-4EF8-BB6D-6bb9bd380a11'`).Scan(&name); err != nil || name != "carol" {
+		t.Fatalf("expected carol, got %s (%v)", name, err)
+	}
+	if err := db.QueryRow(`SELECT name FROM account WHERE id IN ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11')`).Scan(&name); err != nil || name != "carol" {
+		t.Fatalf("expected carol, got %s (%v)", name, err)
+	}
+	if err := db.QueryRow(`SELECT name FROM account WHERE id = $1`, "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11").Scan(&name); err != nil || name != "carol" {
+		t.Fatalf("expected carol, got %s (%v)", name, err)
+	}
+
+	failing := []string{
+		`INSERT INTO account (id, name) VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'dave')`,
+		`INSERT INTO account (id, name) VALUES ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', 'dave')`,
+		`INSERT INTO account (id, name) VALUES ('a0eebc999c0b4ef8bb6d6bb9bd380a11', 'dave')`,
+		`INSERT INTO account (id, name) VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a1g', 'dave')`,
+		`UPDATE account SET id = 'not a uuid' WHERE name = 'bob'`,
+	}
+	for _, query := range failing {
+		if _, err := db.Exec(query); err == nil {
+			t.Fatalf("expected '%s' to fail", query)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+/*
+|-> view
+	|-> active_users
+	|-> SELECT
+		|-> *
+		|-> FROM
+			|-> users
+*/
+// createViewExecutor stores the SELECT statement of a view, once checked by running it
+func createViewExecutor(e *Engine, viewDecl *parser.Decl, conn protocol.EngineConn) error {
+	if len(viewDecl.Decl) != 2 {
+		return fmt.Errorf("parsing failed, malformed query")
+	}
+	name, selectDecl := viewDecl.Decl[0].Lexeme, viewDecl.Decl[1]
+
+	if e.relation(name) != nil || e.view(name) != nil {
+		return &Error{Code: DuplicateTable, Table: name, Message: fmt.Sprintf("relation \"%s\" already exists", name)}
+	}
+
+	// Referenced tables and columns must exist
+	locked := make(map[*Relation]bool)
+	_, err := subqueryExecutor(e, selectDecl, locked)
+	for r := range locked {
+		r.RUnlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.views[name]; ok {
+		return &Error{Code: DuplicateTable, Table: name, Message: fmt.Sprintf("relation \"%s\" already exists", name)}
+	}
+	e.views[name] = selectDecl
+
+	return conn.WriteResult(0, 1)
+}
+
+// dropViewExecutor removes a view. With ifExists, dropping a missing view does nothing.
+func dropViewExecutor(e *Engine, name string, ifExists bool, conn protocol.EngineConn) error {
+	e.Lock()
+	_, ok := e.views[name]
+	delete(e.views, name)
+	e.Unlock()
+
+	if !ok {
+		if ifExists {
+			return conn.WriteResult(0, 0)
+		}
+		return &Error{Code: UndefinedTable, Table: name, Message: fmt.Sprintf("view \"%s\" does not exist", name)}
+	}
+
+	return conn.WriteResult(0, 1)
+}
+
+// view returns the SELECT statement of named view, or nil if there is none
+func (e *Engine) view(name string) *parser.Decl {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.views[name]
+}
+
+// viewRelationExecutor runs the SELECT statement of a view referenced in FROM or JOIN
+// clause, and returns selected rows as a relation with given name
+func viewRelationExecutor(e *Engine, selectDecl *parser.Decl, name string, locked map[*Relation]bool) (*Relation, error) {
+	res, err := subqueryExecutor(e, selectDecl, locked)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.relation(name)
+}
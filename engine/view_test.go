@@ -0,0 +1,108 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestView(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestView")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE users (id BIGSERIAL, name TEXT, active BOOLEAN)`,
+		`CREATE TABLE orders (id BIGSERIAL, user_id BIGINT, amount INT)`,
+		`INSERT INTO users (name, active) VALUES ('alice', true)`,
+		`INSERT INTO users (name) VALUES ('bob')`,
+		`INSERT INTO users (name, active) VALUES ('carol', true)`,
+		`INSERT INTO orders (user_id, amount) VALUES (1, 10)`,
+		`INSERT INTO orders (user_id, amount) VALUES (2, 20)`,
+		`INSERT INTO orders (user_id, amount) VALUES (3, 30)`,
+		`CREATE VIEW active_users AS SELECT * FROM users WHERE active = true`,
+	}
+	for _, b := range batch {
+		_, err = db.Exec(b)
+		if err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	names := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("sql.Query '%s': %s", query, err)
+		}
+		defer rows.Close()
+
+		var got []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("rows.Scan: %s", err)
+			}
+			got = append(got, name)
+		}
+		return strings.Join(got, ",")
+	}
+
+	expected := map[string]string{
+		`SELECT name FROM active_users ORDER BY name`:                                                                  "alice,carol",
+		`SELECT a.name FROM active_users AS a WHERE a.id = 3`:                                                          "carol",
+		`SELECT active_users.name FROM orders JOIN active_users ON orders.user_id = active_users.id WHERE amount > 15`: "carol",
+		`SELECT name FROM users WHERE id IN (SELECT id FROM active_users) ORDER BY name`:                               "alice,carol",
+	}
+	for query, exp := range expected {
+		if got := names(query); got != exp {
+			t.Fatalf("Expected %s with '%s', got %s", exp, query, got)
+		}
+	}
+
+	// View shows current rows of its tables
+	_, err = db.Exec(`UPDATE users SET active = true WHERE name = 'bob'`)
+	if err != nil {
+		t.Fatalf("sql.Exec: %s", err)
+	}
+	if got := names(`SELECT name FROM active_users ORDER BY name`); got != "alice,bob,carol" {
+		t.Fatalf("Expected view to show updated rows, got %s", got)
+	}
+
+	rejected := []string{
+		`CREATE VIEW ghosts AS SELECT * FROM unknown`,
+		`CREATE VIEW ghosts AS SELECT unknown FROM users`,
+		`CREATE VIEW active_users AS SELECT * FROM users`,
+		`CREATE VIEW users AS SELECT * FROM orders`,
+		`CREATE TABLE active_users (id INT)`,
+	}
+	for _, query := range rejected {
+		_, err = db.Exec(query)
+		if err == nil {
+			t.Fatalf("Expected error with '%s'", query)
+		}
+	}
+
+	_, err = db.Exec(`DROP VIEW active_users`)
+	if err != nil {
+		t.Fatalf("Cannot drop view: %s", err)
+	}
+	rows, err := db.Query(`SELECT name FROM active_users`)
+	if err == nil {
+		rows.Close()
+		t.Fatalf("Expected error selecting from dropped view")
+	}
+	_, err = db.Exec(`DROP VIEW active_users`)
+	if err == nil {
+		t.Fatalf("Expected error dropping dropped view")
+	}
+	_, err = db.Exec(`DROP VIEW IF EXISTS active_users`)
+	if err != nil {
+		t.Fatalf("Cannot drop missing view with IF EXISTS: %s", err)
+	}
+}
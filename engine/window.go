@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/proullon/ramsql/engine/parser"
+	"github.com/proullon/ramsql/engine/protocol"
+)
+
+// windowFunctions are the supported window functions
+var windowFunctions = map[string]bool{
+	"row_number": true,
+	"rank":       true,
+	"dense_rank": true,
+}
+
+// windowDecl returns the OVER clause of a window function decl, or nil if decl is not one
+func windowDecl(decl *parser.Decl) *parser.Decl {
+	if decl.Token != parser.FunctionToken {
+		return nil
+	}
+
+	for _, d := range followingDecls(decl) {
+		if d.Token == parser.OverToken {
+			return d
+		}
+	}
+
+	return nil
+}
+
+/*
+|-> row_number
+	|-> )
+	|-> over
+		|-> partition
+			|-> user_id
+		|-> order
+			|-> created_at
+				|-> desc
+*/
+// windowExecutor returns the window function computed by decl over rows of tables.
+// Its value is added to virtual rows under key.
+func windowExecutor(e *Engine, decl *parser.Decl, tables []*Table, locked map[*Relation]bool, key string) (*windowFunction, error) {
+	if !windowFunctions[decl.Lexeme] {
+		return nil, errorf(UndefinedFunction, "window function %s does not exist", decl.Lexeme)
+	}
+	if len(decl.Decl) > 0 && decl.Decl[0].Token != parser.BracketClosingToken {
+		return nil, fmt.Errorf("window function %s does not take arguments", decl.Lexeme)
+	}
+
+	w := &windowFunction{name: decl.Lexeme, key: key}
+
+	for _, d := range windowDecl(decl).Decl {
+		switch d.Token {
+		case parser.PartitionToken:
+			for _, attr := range d.Decl {
+				var tableName string
+				if len(attr.Decl) > 0 {
+					tableName = attr.Decl[0].Lexeme
+				}
+				t, err := resolveAttribute(attr.Lexeme, tableName, tables)
+				if err != nil {
+					return nil, err
+				}
+				w.partition = append(w.partition, t.name+"."+attr.Lexeme)
+			}
+		case parser.OrderToken:
+			// Rows of the window are ordered by attributes of tables, not by selected values
+			o, err := orderbyExecutor(e, d, tables, locked, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			w.order = o
+		}
+	}
+
+	return w, nil
+}
+
+// windowFunction is a window function found in select list, like
+// ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC)
+// or RANK() OVER (ORDER BY score DESC)
+type windowFunction struct {
+	name      string
+	key       string
+	partition []string
+	order     *orderbyFunctor
+}
+
+// compute adds window function value to every row, rows of each partition
+// being numbered in window order. Rows with equal ordering values are peers,
+// which have the same RANK and DENSE_RANK.
+func (w *windowFunction) compute(rows []virtualRow) error {
+	var partitions [][]int
+	index := make(map[string]int)
+	for i, row := range rows {
+		k, err := row.key(w.partition)
+		if err != nil {
+			return err
+		}
+		p, ok := index[k]
+		if !ok {
+			p = len(partitions)
+			index[k] = p
+			partitions = append(partitions, nil)
+		}
+		partitions[p] = append(partitions[p], i)
+	}
+
+	keys := make(map[int][]interface{}, len(rows))
+	for _, partition := range partitions {
+		if w.order != nil {
+			for _, i := range partition {
+				k, err := w.order.keys(rows[i])
+				if err != nil {
+					return err
+				}
+				keys[i] = k
+			}
+			sort.SliceStable(partition, func(i, j int) bool {
+				return w.order.less(keys[partition[i]], keys[partition[j]])
+			})
+		}
+
+		var v int64
+		for n, i := range partition {
+			peer := n > 0 && w.peers(keys[partition[n-1]], keys[i])
+			switch {
+			case w.name == "rank" && !peer:
+				v = int64(n + 1)
+			case w.name == "dense_rank" && !peer:
+				v++
+			case w.name == "row_number":
+				v = int64(n + 1)
+			}
+			rows[i][w.key] = Value{v: v, valid: true, lexeme: w.key}
+		}
+	}
+
+	return nil
+}
+
+// peers returns true if rows with given ordering values are tied in window order.
+// Without ORDER BY, all rows of a partition are peers.
+func (w *windowFunction) peers(a, b []interface{}) bool {
+	if w.order == nil {
+		return true
+	}
+	return !w.order.less(a, b) && !w.order.less(b, a)
+}
+
+// windowFunctor buffers all rows, computes window functions over them,
+// then feeds next functors with rows in the order they were received.
+type windowFunctor struct {
+	next    []selectFunctor
+	windows []*windowFunction
+	rows    []virtualRow
+}
+
+func (f *windowFunctor) Init(e *Engine, conn protocol.EngineConn, attr []string, alias []string) error {
+	for i := range f.next {
+		if err := f.next[i].Init(e, conn, attr, alias); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *windowFunctor) FeedVirtualRow(vrow virtualRow) error {
+	f.rows = append(f.rows, vrow)
+	return nil
+}
+
+func (f *windowFunctor) Done() error {
+	for _, w := range f.windows {
+		if err := w.compute(f.rows); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range f.rows {
+		for i := range f.next {
+			if err := f.next[i].FeedVirtualRow(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range f.next {
+		if err := f.next[i].Done(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
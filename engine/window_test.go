@@ -0,0 +1,163 @@
+package engine_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/proullon/ramsql/engine/log"
+)
+
+func TestRowNumber(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestRowNumber")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE event (id INT, user_id INT, created_at INT)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (1, 1, 10)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (2, 2, 5)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (3, 1, 30)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (4, 2, 50)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (5, 1, 20)`,
+		`INSERT INTO event (id, user_id, created_at) VALUES (6, 3, NULL)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	rows := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var id, n string
+			if err := rows.Scan(&id, &n); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, id+":"+n)
+		}
+		return strings.Join(res, ",")
+	}
+
+	// Rows are numbered in window order, within each partition
+	queries := map[string]string{
+		`SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) FROM event ORDER BY id`:                              "1:3,2:2,3:1,4:1,5:2,6:1",
+		`SELECT id, row_number() OVER (ORDER BY created_at) AS rn FROM event ORDER BY rn`:                                                  "2:1,1:2,5:3,3:4,4:5,6:6",
+		`SELECT id, row_number() OVER () FROM event WHERE user_id = 1 ORDER BY id`:                                                         "1:1,3:2,5:3",
+		`SELECT e.id, row_number() OVER (PARTITION BY e.user_id ORDER BY e.created_at) FROM event e WHERE e.created_at > 10 ORDER BY e.id`: "3:2,4:1,5:1",
+		`SELECT user_id, row_number() OVER (ORDER BY user_id DESC) FROM event GROUP BY user_id ORDER BY user_id`:                           "1:3,2:2,3:1",
+	}
+	for query, expected := range queries {
+		if res := rows(query); res != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, res)
+		}
+	}
+
+	// Latest event of each user
+	query := `SELECT id, user_id FROM (
+		SELECT id, user_id, row_number() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS rn FROM event
+	) latest WHERE rn = 1 ORDER BY user_id`
+	if res := rows(query); res != "3:1,4:2,6:3" {
+		t.Fatalf("expected latest events 3:1,4:2,6:3, got %s", res)
+	}
+
+	_, err = db.Query(`SELECT id FROM event WHERE row_number() OVER () = 1`)
+	if err == nil {
+		t.Fatalf("expected window function in WHERE clause to fail")
+	}
+	_, err = db.Query(`SELECT id, row_number(id) OVER () FROM event`)
+	if err == nil {
+		t.Fatalf("expected window function with argument to fail")
+	}
+	_, err = db.Query(`SELECT id, nope() OVER () FROM event`)
+	if err == nil {
+		t.Fatalf("expected unknown window function to fail")
+	}
+}
+
+func TestRank(t *testing.T) {
+	log.UseTestLogger(t)
+
+	db, err := sql.Open("ramsql", "TestRank")
+	if err != nil {
+		t.Fatalf("sql.Open: %s", err)
+	}
+	defer db.Close()
+
+	batch := []string{
+		`CREATE TABLE score (player TEXT, game TEXT, points INT)`,
+		`INSERT INTO score (player, game, points) VALUES ('a', 'chess', 30)`,
+		`INSERT INTO score (player, game, points) VALUES ('b', 'chess', 20)`,
+		`INSERT INTO score (player, game, points) VALUES ('c', 'chess', 30)`,
+		`INSERT INTO score (player, game, points) VALUES ('d', 'chess', 10)`,
+		`INSERT INTO score (player, game, points) VALUES ('e', 'chess', NULL)`,
+		`INSERT INTO score (player, game, points) VALUES ('f', 'go', 5)`,
+		`INSERT INTO score (player, game, points) VALUES ('g', 'go', 5)`,
+		`INSERT INTO score (player, game, points) VALUES ('h', 'go', 1)`,
+	}
+	for _, b := range batch {
+		if _, err := db.Exec(b); err != nil {
+			t.Fatalf("sql.Exec: %s", err)
+		}
+	}
+
+	ranks := func(query string) string {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		defer rows.Close()
+
+		var res []string
+		for rows.Next() {
+			var player, rank, dense string
+			if err := rows.Scan(&player, &rank, &dense); err != nil {
+				t.Fatalf("%s: cannot scan: %s", query, err)
+			}
+			res = append(res, player+":"+rank+":"+dense)
+		}
+		return strings.Join(res, ",")
+	}
+
+	// RANK leaves gaps after ties, DENSE_RANK does not
+	queries := map[string]string{
+		`SELECT player, RANK() OVER (PARTITION BY game ORDER BY points DESC), DENSE_RANK() OVER (PARTITION BY game ORDER BY points DESC) FROM score ORDER BY player`:        "a:2:2,b:4:3,c:2:2,d:5:4,e:1:1,f:1:1,g:1:1,h:3:2",
+		`SELECT player, rank() OVER (ORDER BY points DESC NULLS LAST), dense_rank() OVER (ORDER BY points DESC NULLS LAST) FROM score WHERE game = 'chess' ORDER BY player`: "a:1:1,b:3:2,c:1:1,d:4:3,e:5:4",
+		`SELECT player, rank() OVER (ORDER BY game, points) AS r, dense_rank() OVER (ORDER BY game) FROM score ORDER BY r, player`:                                          "d:1:1,b:2:1,a:3:1,c:3:1,e:5:1,h:6:2,f:7:2,g:7:2",
+		`SELECT player, rank() OVER (PARTITION BY game), dense_rank() OVER () FROM score WHERE points > 10 ORDER BY player`:                                                 "a:1:1,b:1:1,c:1:1",
+	}
+	for query, expected := range queries {
+		if res := ranks(query); res != expected {
+			t.Fatalf("%s: expected %s, got %s", query, expected, res)
+		}
+	}
+
+	// Best players of each game
+	rows, err := db.Query(`SELECT player FROM (SELECT player, rank() OVER (PARTITION BY game ORDER BY points DESC NULLS LAST) AS r FROM score) ranked WHERE r = 1 ORDER BY player`)
+	if err != nil {
+		t.Fatalf("sql.Query: %s", err)
+	}
+	defer rows.Close()
+	var players []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			t.Fatalf("cannot scan: %s", err)
+		}
+		players = append(players, p)
+	}
+	if res := strings.Join(players, ","); res != "a,c,f,g" {
+		t.Fatalf("expected a,c,f,g, got %s", res)
+	}
+}